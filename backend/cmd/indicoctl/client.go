@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/handlers"
+)
+
+// client calls the Indico HTTP API
+type client struct {
+	baseURL    string
+	clientID   string
+	adminToken string
+	http       *http.Client
+}
+
+// apiError is an error response returned by the API
+type apiError struct {
+	Status  int
+	Code    string
+	Message string
+	Details string
+}
+
+func (e *apiError) Error() string {
+	msg := fmt.Sprintf("%s (HTTP %d): %s", e.Code, e.Status, e.Message)
+	if e.Details != "" {
+		msg += " - " + e.Details
+	}
+	return msg
+}
+
+// newClient creates an API client for the server at baseURL; adminToken is
+// sent for /admin routes when set
+func newClient(baseURL, clientID, adminToken string) *client {
+	return &client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		clientID:   clientID,
+		adminToken: adminToken,
+		http:       &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// do sends a request with an optional JSON body and decodes a successful
+// JSON response into out, which may be nil
+func (c *client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.clientID != "" {
+		req.Header.Set("X-Client-ID", c.clientID)
+	}
+	if c.adminToken != "" && strings.HasPrefix(path, "/admin/") {
+		req.Header.Set(handlers.AdminTokenHeader, c.adminToken)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		var errResp errors.ErrorResponse
+		if err := json.Unmarshal(data, &errResp); err != nil || errResp.Error.Code == "" {
+			return &apiError{Status: resp.StatusCode, Code: "HTTP_ERROR", Message: strings.TrimSpace(string(data))}
+		}
+		return &apiError{
+			Status:  resp.StatusCode,
+			Code:    errResp.Error.Code,
+			Message: errResp.Error.Message,
+			Details: errResp.Error.Details,
+		}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
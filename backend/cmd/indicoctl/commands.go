@@ -0,0 +1,539 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"indico-backend/internal/database"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+	"indico-backend/internal/verify"
+
+	"github.com/google/uuid"
+)
+
+// command is one indicoctl subcommand, addressed as "<group> <name>"
+type command struct {
+	group   string
+	name    string
+	args    string
+	summary string
+	run     func(ctx context.Context, e *env, args []string) error
+}
+
+// commands lists every subcommand in the order usage shows them
+var commands = []*command{
+	{"product", "create", "-name NAME -price CENTS -stock N [-sku SKU] [-barcode CODE]", "create a product (database)", productCreate},
+	{"product", "get", "<id>", "show a product", productGet},
+	{"settlement", "queue", "-from YYYY-MM-DD [-to YYYY-MM-DD] [-split none|merchant] [-wait]", "queue a settlement job", settlementQueue},
+	{"settlement", "verify", "-from YYYY-MM-DD [-to YYYY-MM-DD] [-csv PATH]", "recompute settlements and compare totals (database)", settlementVerify},
+	{"job", "get", "[-wait] <id>", "show a job, optionally waiting for it to finish", jobGet},
+	{"job", "list", "[-status STATUS] [-type TYPE] [-limit N] [-offset N]", "list jobs, newest first", jobList},
+	{"job", "cancel", "<id>", "cancel a queued or running job", jobCancel},
+	{"job", "dead-letter", "[-limit N] [-offset N]", "list dead-lettered jobs", jobDeadLetter},
+	{"job", "redrive", "(-all | <id>...)", "re-drive dead-lettered jobs", jobRedrive},
+	{"backfill", "list", "", "list registered backfills and their remaining rows (admin)", backfillList},
+	{"backfill", "run", "[-wait] <name>", "queue a backfill job (admin)", backfillRun},
+	{"migration", "rename-column", "-table T -from OLD -to NEW -type TYPE [-not-null] -phase expand|contract [-dir DIR]", "write an expand or contract migration for a column rename", migrationRenameColumn},
+}
+
+// findCommand returns the command addressed by group and name, or nil
+func findCommand(group, name string) *command {
+	for _, cmd := range commands {
+		if cmd.group == group && cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// jobPollInterval is how often -wait checks a job's status
+const jobPollInterval = 2 * time.Second
+
+// jobStatus is the part of the job status response indicoctl acts on
+type jobStatus struct {
+	JobID       uuid.UUID        `json:"job_id"`
+	Status      models.JobStatus `json:"status"`
+	Progress    float64          `json:"progress"`
+	DownloadURL string           `json:"download_url,omitempty"`
+	Error       string           `json:"error,omitempty"`
+}
+
+// newFlagSet creates a flag set for a command that reports errors instead
+// of exiting, so main can print the command's usage line
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+// parseFlags parses command flags, wrapping failures as usage errors
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return usagef("%v", err)
+	}
+	return nil
+}
+
+// dateRange parses -from and an optional -to that defaults to -from
+func dateRange(from, to string) (time.Time, time.Time, error) {
+	start, err := time.Parse("2006-01-02", from)
+	if err != nil {
+		return time.Time{}, time.Time{}, usagef("-from is required (YYYY-MM-DD)")
+	}
+	if to == "" {
+		return start, start, nil
+	}
+
+	end, err := time.Parse("2006-01-02", to)
+	if err != nil || end.Before(start) {
+		return time.Time{}, time.Time{}, usagef("-to must be a date (YYYY-MM-DD) not before -from")
+	}
+	return start, end, nil
+}
+
+// oneID parses the single job ID argument of a command
+func oneID(args []string) (uuid.UUID, error) {
+	if len(args) != 1 {
+		return uuid.Nil, usagef("expected one job ID")
+	}
+	id, err := uuid.Parse(args[0])
+	if err != nil {
+		return uuid.Nil, usagef("invalid job ID %q", args[0])
+	}
+	return id, nil
+}
+
+// productCreate inserts a product directly, so a catalog can be loaded
+// before any server is running
+func productCreate(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet("product create")
+	name := fs.String("name", "", "product name")
+	price := fs.Int("price", 0, "price in cents")
+	stock := fs.Int("stock", 0, "initial stock")
+	sku := fs.String("sku", "", "SKU (optional)")
+	barcode := fs.String("barcode", "", "barcode (optional)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(*name) == "" {
+		return usagef("-name is required")
+	}
+	if *price <= 0 {
+		return usagef("-price must be positive")
+	}
+	if *stock < 0 {
+		return usagef("-stock must not be negative")
+	}
+
+	db, _, err := e.database()
+	if err != nil {
+		return err
+	}
+
+	product := &models.Product{
+		Name:  strings.TrimSpace(*name),
+		Stock: *stock,
+		Price: *price,
+	}
+	if *sku != "" {
+		product.SKU = sku
+	}
+	if *barcode != "" {
+		product.Barcode = barcode
+	}
+
+	if err := repository.NewProductRepository(db.DB, nil).Create(ctx, product); err != nil {
+		return err
+	}
+	return e.printJSON(product)
+}
+
+func productGet(ctx context.Context, e *env, args []string) error {
+	if len(args) != 1 {
+		return usagef("expected one product ID")
+	}
+	if _, err := strconv.Atoi(args[0]); err != nil {
+		return usagef("invalid product ID %q", args[0])
+	}
+
+	var product map[string]interface{}
+	if err := e.api.do(ctx, http.MethodGet, "/v1/products/"+args[0], nil, &product); err != nil {
+		return err
+	}
+	return e.printJSON(product)
+}
+
+func settlementQueue(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet("settlement queue")
+	from := fs.String("from", "", "first settlement date")
+	to := fs.String("to", "", "last settlement date (defaults to -from)")
+	split := fs.String("split", "", "none for one CSV, merchant for a ZIP of one CSV per merchant")
+	wait := fs.Bool("wait", false, "wait for the job to finish")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	start, end, err := dateRange(*from, *to)
+	if err != nil {
+		return err
+	}
+
+	req := &models.CreateSettlementJobRequest{
+		From:  start.Format("2006-01-02"),
+		To:    end.Format("2006-01-02"),
+		Split: models.SettlementSplit(*split),
+	}
+	var job jobStatus
+	if err := e.api.do(ctx, http.MethodPost, "/v1/jobs/settlement", req, &job); err != nil {
+		return err
+	}
+
+	if *wait {
+		return waitForJob(ctx, e, job.JobID)
+	}
+	return e.printJSON(job)
+}
+
+// settlementVerify recomputes settlements from transactions and compares
+// them to the stored ones, the same check cmd/verify runs
+func settlementVerify(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet("settlement verify")
+	from := fs.String("from", "", "first settlement date")
+	to := fs.String("to", "", "last settlement date (defaults to -from)")
+	csvPath := fs.String("csv", "", "settlement CSV generated for the same range (optional)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	start, end, err := dateRange(*from, *to)
+	if err != nil {
+		return err
+	}
+
+	db, cfg, err := e.database()
+	if err != nil {
+		return err
+	}
+
+	result, err := verify.Settlements(ctx, db, &cfg.Settlement, start, end, *csvPath)
+	if err != nil {
+		return err
+	}
+
+	result.Report(e.out)
+	if !result.OK() {
+		return fmt.Errorf("settlement totals do not match")
+	}
+	return nil
+}
+
+func jobGet(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet("job get")
+	wait := fs.Bool("wait", false, "wait for the job to finish")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	id, err := oneID(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	if *wait {
+		return waitForJob(ctx, e, id)
+	}
+
+	var job map[string]interface{}
+	if err := e.api.do(ctx, http.MethodGet, "/v1/jobs/"+id.String(), nil, &job); err != nil {
+		return err
+	}
+	return e.printJSON(job)
+}
+
+// waitForJob polls a job until it finishes, failing unless it completed
+func waitForJob(ctx context.Context, e *env, id uuid.UUID) error {
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+
+	for {
+		var job jobStatus
+		if err := e.api.do(ctx, http.MethodGet, "/v1/jobs/"+id.String(), nil, &job); err != nil {
+			return err
+		}
+
+		switch job.Status {
+		case models.JobStatusQueued, models.JobStatusRunning, models.JobStatusCancelling:
+		default:
+			if err := e.printJSON(job); err != nil {
+				return err
+			}
+			if job.Status != models.JobStatusCompleted {
+				return fmt.Errorf("job %s finished %s", id, job.Status)
+			}
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func jobCancel(ctx context.Context, e *env, args []string) error {
+	id, err := oneID(args)
+	if err != nil {
+		return err
+	}
+
+	var resp map[string]interface{}
+	if err := e.api.do(ctx, http.MethodPost, "/v1/jobs/"+id.String()+"/cancel", nil, &resp); err != nil {
+		return err
+	}
+	return e.printJSON(resp)
+}
+
+func jobList(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet("job list")
+	status := fs.String("status", "", "only jobs with this status, e.g. RUNNING")
+	jobType := fs.String("type", "", "only jobs of this type, e.g. SETTLEMENT")
+	limit := fs.Int("limit", 10, "maximum jobs to list (1-100)")
+	offset := fs.Int("offset", 0, "jobs to skip")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	if *status != "" {
+		query.Set("status", strings.ToUpper(*status))
+	}
+	if *jobType != "" {
+		query.Set("type", strings.ToUpper(*jobType))
+	}
+	query.Set("limit", strconv.Itoa(*limit))
+	query.Set("offset", strconv.Itoa(*offset))
+
+	var page struct {
+		Jobs []*models.Job `json:"jobs"`
+	}
+	if err := e.api.do(ctx, http.MethodGet, "/v1/jobs?"+query.Encode(), nil, &page); err != nil {
+		return err
+	}
+	return e.printJSON(page.Jobs)
+}
+
+// deadLetterPage is the dead-letter list response
+type deadLetterPage struct {
+	Jobs []*models.Job `json:"jobs"`
+}
+
+func jobDeadLetter(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet("job dead-letter")
+	limit := fs.Int("limit", 10, "maximum jobs to list (1-100)")
+	offset := fs.Int("offset", 0, "jobs to skip")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	page, err := listDeadLettered(ctx, e, *limit, *offset)
+	if err != nil {
+		return err
+	}
+	return e.printJSON(page.Jobs)
+}
+
+func listDeadLettered(ctx context.Context, e *env, limit, offset int) (*deadLetterPage, error) {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("offset", strconv.Itoa(offset))
+
+	var page deadLetterPage
+	if err := e.api.do(ctx, http.MethodGet, "/v1/jobs/dead-letter?"+query.Encode(), nil, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// jobRedrive re-drives the given dead-lettered jobs, or all of them with
+// -all, carrying on past failures and reporting them at the end
+func jobRedrive(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet("job redrive")
+	all := fs.Bool("all", false, "re-drive every dead-lettered job")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var ids []uuid.UUID
+	switch {
+	case *all && fs.NArg() > 0:
+		return usagef("-all cannot be combined with job IDs")
+	case *all:
+		// Collect the IDs before re-driving, since re-driven jobs leave the list
+		for offset := 0; ; offset += 100 {
+			page, err := listDeadLettered(ctx, e, 100, offset)
+			if err != nil {
+				return err
+			}
+			for _, job := range page.Jobs {
+				ids = append(ids, job.ID)
+			}
+			if len(page.Jobs) < 100 {
+				break
+			}
+		}
+	case fs.NArg() == 0:
+		return usagef("expected job IDs or -all")
+	default:
+		for _, arg := range fs.Args() {
+			id, err := uuid.Parse(arg)
+			if err != nil {
+				return usagef("invalid job ID %q", arg)
+			}
+			ids = append(ids, id)
+		}
+	}
+
+	var failed int
+	for _, id := range ids {
+		var job jobStatus
+		if err := e.api.do(ctx, http.MethodPost, "/v1/jobs/"+id.String()+"/redrive", nil, &job); err != nil {
+			fmt.Fprintf(e.out, "%s\tFAILED\t%v\n", id, err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(e.out, "%s\t%s\n", job.JobID, job.Status)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d jobs could not be re-driven", failed, len(ids))
+	}
+	return nil
+}
+
+// backfillPage is the backfill list response
+type backfillPage struct {
+	Backfills []*models.BackfillStatus `json:"backfills"`
+}
+
+func backfillList(ctx context.Context, e *env, args []string) error {
+	if len(args) != 0 {
+		return usagef("unexpected arguments")
+	}
+
+	var list backfillPage
+	if err := e.api.do(ctx, http.MethodGet, "/admin/backfills", nil, &list); err != nil {
+		return err
+	}
+	return e.printJSON(list.Backfills)
+}
+
+func backfillRun(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet("backfill run")
+	wait := fs.Bool("wait", false, "wait for the job to finish")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("expected one backfill name")
+	}
+
+	req := &models.CreateBackfillJobRequest{Name: fs.Arg(0)}
+	var job jobStatus
+	if err := e.api.do(ctx, http.MethodPost, "/admin/backfills", req, &job); err != nil {
+		return err
+	}
+
+	if *wait {
+		return waitForJob(ctx, e, job.JobID)
+	}
+	return e.printJSON(job)
+}
+
+// migrationPattern matches migration file names and captures the number
+var migrationPattern = regexp.MustCompile(`^(\d+)_.+\.up\.sql$`)
+
+// migrationRenameColumn writes the up and down files of the next migration
+// for one phase of a column rename
+func migrationRenameColumn(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet("migration rename-column")
+	table := fs.String("table", "", "table holding the column")
+	from := fs.String("from", "", "current column name")
+	to := fs.String("to", "", "new column name")
+	typ := fs.String("type", "", "SQL type of the column, e.g. VARCHAR(255)")
+	notNull := fs.Bool("not-null", false, "make the new column NOT NULL on contract")
+	phase := fs.String("phase", "", "expand or contract")
+	dir := fs.String("dir", "migrations", "migrations directory")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if *table == "" || *from == "" || *to == "" || *typ == "" {
+		return usagef("-table, -from, -to and -type are required")
+	}
+
+	rename := &database.ColumnRename{Table: *table, From: *from, To: *to, Type: *typ, NotNull: *notNull}
+	var up, down string
+	switch *phase {
+	case "expand":
+		up, down = rename.ExpandSQL(), rename.RevertExpandSQL()
+	case "contract":
+		up, down = rename.ContractSQL(), rename.RevertContractSQL()
+	default:
+		return usagef("-phase must be expand or contract")
+	}
+
+	number, err := nextMigrationNumber(*dir)
+	if err != nil {
+		return err
+	}
+
+	base := filepath.Join(*dir, fmt.Sprintf("%03d_%s_%s_%s", number, *phase, *table, *to))
+	files := []struct{ path, sql string }{
+		{base + ".up.sql", up},
+		{base + ".down.sql", down},
+	}
+	for _, f := range files {
+		if err := os.WriteFile(f.path, []byte(f.sql), 0o644); err != nil {
+			return fmt.Errorf("failed to write migration: %w", err)
+		}
+		fmt.Fprintln(e.out, f.path)
+	}
+
+	if *phase == "expand" {
+		fmt.Fprintf(e.out, "register the backfill %q in internal/service/backfill.go\n", rename.Backfill().Name)
+	}
+	return nil
+}
+
+// nextMigrationNumber returns the number after the highest migration in dir
+func nextMigrationNumber(dir string) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	var highest int
+	for _, entry := range entries {
+		match := migrationPattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		if n, _ := strconv.Atoi(match[1]); n > highest {
+			highest = n
+		}
+	}
+	return highest + 1, nil
+}
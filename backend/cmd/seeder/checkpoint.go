@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"indico-backend/internal/models"
+)
+
+// checkpoint records an in-progress seed so an interrupted run can resume.
+// Progress is derived from the table's row count against the count before
+// seeding began, so a batch committed just before a crash is never repeated.
+type checkpoint struct {
+	Options      models.SeedOptions `json:"options"`
+	BaselineRows int                `json:"baseline_rows"`
+	Inserted     int                `json:"inserted"`
+	StartedAt    time.Time          `json:"started_at"`
+	UpdatedAt    time.Time          `json:"updated_at"`
+}
+
+// loadCheckpoint reads a checkpoint file
+func loadCheckpoint(path string) (*checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %s: %w", path, err)
+	}
+
+	return &cp, nil
+}
+
+// save writes the checkpoint atomically via a temporary file
+func (cp *checkpoint) save(path string) error {
+	cp.UpdatedAt = time.Now()
+
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+// countTransactions returns the number of rows in the transactions table
+func countTransactions(ctx context.Context, db *sql.DB) (int, error) {
+	var count int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM transactions`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count transactions: %w", err)
+	}
+	return count, nil
+}
+
+// progressReporter logs insert rate and ETA at a fixed interval
+type progressReporter struct {
+	total    int
+	interval time.Duration
+
+	start      time.Time
+	startCount int
+	lastReport time.Time
+}
+
+func newProgressReporter(total, already int, interval time.Duration) *progressReporter {
+	now := time.Now()
+	return &progressReporter{
+		total:      total,
+		interval:   interval,
+		start:      now,
+		startCount: already,
+		lastReport: now,
+	}
+}
+
+// report returns a progress line when the interval has elapsed, or "" otherwise
+func (p *progressReporter) report(inserted int, force bool) string {
+	now := time.Now()
+	if !force && now.Sub(p.lastReport) < p.interval {
+		return ""
+	}
+	p.lastReport = now
+
+	elapsed := now.Sub(p.start).Seconds()
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(inserted-p.startCount) / elapsed
+	}
+
+	eta := "unknown"
+	if rate > 0 {
+		eta = (time.Duration(float64(p.total-inserted)/rate) * time.Second).Round(time.Second).String()
+	}
+
+	return fmt.Sprintf("Seeded %d/%d transactions (%.1f%%) at %.0f rows/s, ETA %s",
+		inserted, p.total, float64(inserted)/float64(p.total)*100, rate, eta)
+}
@@ -3,8 +3,12 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"flag"
 	"fmt"
-	"math/rand"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"indico-backend/internal/config"
@@ -12,9 +16,30 @@ import (
 	"indico-backend/internal/logger"
 	"indico-backend/internal/models"
 	"indico-backend/internal/repository"
+	"indico-backend/internal/seed"
 )
 
 func main() {
+	defaults := seed.DefaultOptions()
+	opts := &models.SeedOptions{}
+	flag.IntVar(&opts.Count, "count", 1000000, "number of transactions to seed")
+	flag.IntVar(&opts.Days, "days", defaults.Days, "spread transactions over this many days up to today")
+	flag.IntVar(&opts.Merchants, "merchants", defaults.Merchants, "number of distinct merchants")
+	flag.Float64Var(&opts.ZipfS, "zipf", defaults.ZipfS, "Zipf exponent for merchant popularity (must be > 1; <= 1 is uniform)")
+	flag.BoolVar(&opts.Diurnal, "diurnal", defaults.Diurnal, "follow a daily traffic curve instead of uniform hours")
+	flag.Float64Var(&opts.PendingShare, "pending", defaults.PendingShare, "share of transactions left PENDING")
+	flag.Float64Var(&opts.FailedShare, "failed", defaults.FailedShare, "share of transactions marked FAILED")
+	checkpointPath := flag.String("checkpoint", "seeder.checkpoint.json", "file recording progress of an in-flight seed")
+	resume := flag.Bool("resume", false, "resume the seed recorded in the checkpoint file")
+	truncate := flag.Bool("truncate", false, "delete all transactions before seeding")
+	reportEvery := flag.Duration("report-every", 5*time.Second, "interval between progress reports")
+	flag.Parse()
+
+	if *resume && *truncate {
+		fmt.Fprintln(os.Stderr, "seeder: -resume and -truncate cannot be combined")
+		os.Exit(2)
+	}
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
@@ -33,82 +58,87 @@ func main() {
 	}
 	defer db.Close()
 
+	// Stop after the current batch on interrupt so the run can be resumed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Initialize repository
 	txRepo := repository.NewTransactionRepository(db.DB)
 
-	// Seed transactions
-	if err := seedTransactions(txRepo); err != nil {
-		logger.Fatalf("Failed to seed transactions: %v", err)
+	cp, err := prepareCheckpoint(ctx, db.DB, txRepo, opts, *checkpointPath, *resume, *truncate)
+	if err != nil {
+		logger.Fatalf("Failed to prepare seed: %v", err)
 	}
 
-	logger.Info("Data seeding completed successfully")
-}
-
-func seedTransactions(txRepo repository.TransactionRepository) error {
-	logger.Info("Seeding transactions...")
-
-	merchants := []string{
-		"merchant_001", "merchant_002", "merchant_003", "merchant_004", "merchant_005",
-		"merchant_006", "merchant_007", "merchant_008", "merchant_009", "merchant_010",
+	// Seed transactions
+	reporter := newProgressReporter(cp.Options.Count, cp.Inserted, *reportEvery)
+	err = seed.Transactions(ctx, txRepo, &cp.Options, cp.Inserted, func(inserted int) error {
+		cp.Inserted = inserted
+		if line := reporter.report(inserted, false); line != "" {
+			logger.Info(line)
+		}
+		return cp.save(*checkpointPath)
+	})
+	if ctx.Err() != nil {
+		logger.Infof("Seeding interrupted after %d transactions; rerun with -resume to continue", cp.Inserted)
+		os.Exit(1)
+	}
+	if err != nil {
+		logger.Fatalf("Failed to seed transactions (rerun with -resume to continue): %v", err)
 	}
 
-	// Generate transactions for the last 60 days
-	endDate := time.Now()
-	startDate := endDate.AddDate(0, 0, -60)
-
-	const batchSize = 1000
-	const totalTransactions = 1000000
-
-	// Create a local RNG
-	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	logger.Info(reporter.report(cp.Inserted, true))
+	if err := os.Remove(*checkpointPath); err != nil && !os.IsNotExist(err) {
+		logger.Warnf("Failed to remove checkpoint: %v", err)
+	}
 
-	for i := 0; i < totalTransactions; i += batchSize {
-		var transactions []*models.Transaction
+	logger.Info("Data seeding completed successfully")
+}
 
-		remaining := totalTransactions - i
-		currentBatchSize := batchSize
-		if remaining < batchSize {
-			currentBatchSize = remaining
+// prepareCheckpoint starts a new seed or loads the one being resumed,
+// reconciling its progress with the rows actually committed
+func prepareCheckpoint(ctx context.Context, db *sql.DB, txRepo repository.TransactionWriter, opts *models.SeedOptions, path string, resume, truncate bool) (*checkpoint, error) {
+	if !resume {
+		if _, err := os.Stat(path); err == nil {
+			return nil, fmt.Errorf("checkpoint %s exists; use -resume to continue it or delete it to start over", path)
 		}
 
-		for j := 0; j < currentBatchSize; j++ {
-			// Random merchant
-			merchantID := merchants[rng.Intn(len(merchants))]
-
-			// Random date between start and end
-			daysDiff := int(endDate.Sub(startDate).Hours() / 24)
-			randomDays := rng.Intn(daysDiff)
-			paidAt := startDate.AddDate(0, 0, randomDays).
-				Add(time.Duration(rng.Intn(24)) * time.Hour).
-				Add(time.Duration(rng.Intn(60)) * time.Minute)
-
-			// Random amount (100 cents to 50000 cents, i.e., $1 to $500)
-			amountCents := rng.Intn(49900) + 100
-
-			// Fee is typically 2.9% + 30 cents
-			feeCents := int(float64(amountCents)*0.029) + 30
-
-			transaction := &models.Transaction{
-				MerchantID:  merchantID,
-				AmountCents: amountCents,
-				FeeCents:    feeCents,
-				Status:      models.TransactionStatusCompleted,
-				PaidAt:      paidAt,
+		if truncate {
+			logger.Info("Truncating transactions table")
+			if err := txRepo.Truncate(ctx); err != nil {
+				return nil, err
 			}
-
-			transactions = append(transactions, transaction)
 		}
 
-		// Bulk insert batch
-		if err := txRepo.BulkCreate(context.Background(), transactions); err != nil {
-			return fmt.Errorf("failed to create transaction batch: %w", err)
+		baseline, err := countTransactions(ctx, db)
+		if err != nil {
+			return nil, err
 		}
 
-		if (i+currentBatchSize)%10000 == 0 {
-			logger.Infof("Seeded %d transactions", i+currentBatchSize)
+		cp := &checkpoint{
+			Options:      *opts,
+			BaselineRows: baseline,
+			StartedAt:    time.Now(),
 		}
+		return cp, cp.save(path)
+	}
+
+	cp, err := loadCheckpoint(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := countTransactions(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	inserted := rows - cp.BaselineRows
+	if inserted < 0 || inserted > cp.Options.Count {
+		return nil, fmt.Errorf("transactions table has %d rows, inconsistent with checkpoint baseline %d; the table changed since the seed started", rows, cp.BaselineRows)
 	}
+	cp.Inserted = inserted
 
-	logger.Infof("Successfully seeded %d transactions", totalTransactions)
-	return nil
+	logger.Infof("Resuming seed at %d/%d transactions", cp.Inserted, cp.Options.Count)
+	return cp, nil
 }
@@ -3,13 +3,16 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
+	"indico-backend/internal/cache"
 	"indico-backend/internal/config"
 	"indico-backend/internal/database"
 	"indico-backend/internal/handlers"
@@ -17,7 +20,9 @@ import (
 	"indico-backend/internal/metrics"
 	"indico-backend/internal/repository"
 	"indico-backend/internal/routes"
+	"indico-backend/internal/search"
 	"indico-backend/internal/service"
+	"indico-backend/internal/storage"
 
 	"github.com/gin-gonic/gin"
 )
@@ -31,11 +36,29 @@ func main() {
 
 	// Initialize logger
 	logger.Init(cfg.Log.Level, cfg.Log.Format)
+	logger.SetRedactFields(cfg.Log.RedactFields)
 
-	logger.Info("Starting Indico Backend Service")
+	logger.WithComponent("server").WithField("env", cfg.Env).Info("Starting Indico Backend Service")
 
-	// Connect to database
-	db, err := database.New(&cfg.Database)
+	// Connect to database, waiting for it to accept connections. A degraded
+	// start serves /healthz meanwhile and keeps waiting past DB_CONNECT_WAIT.
+	startupCtx, stopStartup := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	db, err := database.Connect(startupCtx, &cfg.Database)
+
+	var server *http.Server
+	var startup *startupHandler
+	if err != nil && cfg.Server.DegradedStart && startupCtx.Err() == nil {
+		logger.WithError(err).Warn("Database unavailable, starting in degraded mode")
+
+		startup = &startupHandler{}
+		server = newHTTPServer(cfg, startup)
+		go listen(server)
+
+		forever := cfg.Database
+		forever.ConnectWait = -1
+		db, err = database.Connect(startupCtx, &forever)
+	}
+	stopStartup()
 	if err != nil {
 		logger.WithError(err).Fatal("Failed to connect to database")
 	}
@@ -44,59 +67,279 @@ func main() {
 	// Initialize metrics
 	metrics.Init()
 
+	// Prepare the hot order-path statements on the main pool
+	var stmts *repository.Statements
+	if cfg.Database.PreparedStatements {
+		stmts, err = repository.PrepareStatements(context.Background(), db.DB)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to prepare statements")
+		}
+		defer stmts.Close()
+	}
+
 	// Initialize repositories
-	productRepo := repository.NewProductRepository(db.DB)
-	orderRepo := repository.NewOrderRepository(db.DB)
+	productRepo := repository.NewProductRepository(db.DB, stmts)
+	orderRepo := repository.NewOrderRepository(db.DB, stmts)
+	bundleRepo := repository.NewBundleRepository(db.DB)
+	salesEventRepo := repository.NewSalesEventRepository(db.DB)
 	txRepo := repository.NewTransactionRepository(db.DB)
 	settleRepo := repository.NewSettlementRepository(db.DB)
 	jobRepo := repository.NewJobRepository(db.DB)
+	forecastRepo := repository.NewForecastRepository(db.DB)
+	calendarRepo := repository.NewCalendarRepository(db.DB)
+	statsRepo := repository.NewStatsRepository(db.DB)
+	analyticsRepo := repository.NewAnalyticsRepository(db.DB)
+	sagaRepo := repository.NewSagaRepository(db.DB)
+	maintenanceRepo := repository.NewMaintenanceRepository(db.DB)
+	webhookRepo := repository.NewWebhookRepository(db.DB)
+	authBanRepo := repository.NewAuthBanRepository(db.DB)
+	payoutRepo := repository.NewPayoutRepository(db.DB)
+
+	// Bind the writers to transactions for work spanning several of them
+	uow := repository.NewUnitOfWork(db, repository.Writers{
+		Products:     productRepo,
+		Orders:       orderRepo,
+		Bundles:      bundleRepo,
+		SalesEvents:  salesEventRepo,
+		Transactions: txRepo,
+		Settlements:  settleRepo,
+		Forecasts:    forecastRepo,
+		Jobs:         jobRepo,
+		Sagas:        sagaRepo,
+		Payouts:      payoutRepo,
+	})
+
+	// Load maintenance mode before the workers start, so a replica starting
+	// during maintenance doesn't pick up work
+	maintenance := service.NewMaintenanceMode(maintenanceRepo, cfg.Admin.MaintenancePollInterval)
+	maintenance.Start()
+	defer maintenance.Stop()
 
-	// Initialize job processor
-	jobProcessor := service.NewJobProcessor(db, &cfg.Jobs, txRepo, settleRepo, jobRepo)
+	// Job output files are written to and served from the same store
+	files := storage.NewLocal(cfg.Storage.LocalDir)
+
+	// Initialize job processor on the batch pool, so long job scans can't
+	// exhaust the connections order traffic needs
+	batch := db.Batch
+	jobProcessor := service.NewJobProcessor(batch, &cfg.Jobs,
+		repository.NewTransactionRepository(batch.DB),
+		repository.NewSettlementRepository(batch.DB),
+		repository.NewJobRepository(batch.DB),
+		repository.NewProductRepository(batch.DB, nil),
+		repository.NewOrderRepository(batch.DB, nil),
+		repository.NewForecastRepository(batch.DB),
+		repository.NewCalendarRepository(batch.DB),
+		repository.NewSagaRepository(batch.DB),
+		repository.NewPayoutRepository(batch.DB),
+		repository.NewWebhookRepository(batch.DB),
+		&cfg.Settlement,
+		&cfg.Webhook,
+		&cfg.Payout,
+		maintenance,
+		files)
 	jobProcessor.Start()
 	defer jobProcessor.Stop()
 
+	// Gauge the job backlog across all replicas
+	if cfg.Jobs.MetricsInterval > 0 {
+		collector := service.NewJobQueueCollector(statsRepo, cfg.Jobs.MetricsInterval)
+		collector.Start()
+		defer collector.Stop()
+	}
+
+	// Initialize saga orchestration
+	sagas := service.NewSagaOrchestrator(uow, sagaRepo)
+
+	// Singleton background tasks run only on the replica holding the
+	// background leader lock; the others take over if it goes away
+	leader := db.NewLeader("background")
+	defer leader.Resign()
+
+	// Price orders with the pricing hooks switched on by PRICING_HOOKS
+	pricing, err := service.NewPricing(&cfg.Pricing)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to set up order pricing")
+	}
+
+	// With the stock fast path, orders are accepted in Redis and written to
+	// Postgres by its writers; they start once the order saga is registered
+	var fastPath *service.StockFastPath
+	if cfg.Orders.FastPath {
+		rdb, err := cache.New(&cfg.Cache)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to connect to Redis")
+		}
+		defer rdb.Close()
+		fastPath = service.NewStockFastPath(rdb, productRepo, bundleRepo, orderRepo, sagas, pricing, &cfg.Orders, leader)
+	}
+
+	// Every replica keeps the sales event schedule orders are checked
+	// against, and loads event stock into the fast path ahead of the start
+	salesEvents := service.NewSalesEvents(salesEventRepo, fastPath, cfg.Orders.SalesEventRefreshInterval, cfg.Orders.SalesEventPrewarmLead)
+	salesEvents.Start()
+	defer salesEvents.Stop()
+
+	// Orders for the products behind the waiting room need a queue token,
+	// issued at a steady rate per product
+	var waitingRoom *service.WaitingRoom
+	if cfg.WaitingRoom.Enabled() {
+		waitingRoom, err = service.NewWaitingRoom(&cfg.WaitingRoom)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to set up the waiting room")
+		}
+		waitingRoom.Start()
+		defer waitingRoom.Stop()
+	}
+
+	// Ban clients and credentials after repeated failed authentication
+	var authGuard *service.AuthGuard
+	if cfg.Auth.Enabled() {
+		authGuard = service.NewAuthGuard(&cfg.Auth, authBanRepo)
+		authGuard.Start()
+		defer authGuard.Stop()
+	}
+
+	// In queued mode, place orders one at a time per product so hot
+	// products don't pile up on their row lock; stopped after the HTTP
+	// server, once no new orders can arrive
+	var orderQueue *service.OrderQueue
+	if cfg.Orders.Mode == config.OrderModeQueued {
+		orderQueue = service.NewOrderQueue(cfg.Orders.QueueShards, cfg.Orders.QueueDepth)
+		orderQueue.Start()
+		defer orderQueue.Stop()
+	}
+
+	// Initialize the search client when a cluster is configured
+	var searchClient *search.Client
+	if cfg.Search.Enabled() {
+		searchClient = search.New(&cfg.Search)
+	}
+
+	// Watch the main pool for saturation, which degrades the expensive
+	// listings so order creation keeps its connections
+	var pressure *database.PressureMonitor
+	if cfg.LoadShed.Enabled {
+		pressure = db.NewPressureMonitor(cfg.LoadShed.PoolWait, cfg.LoadShed.SampleInterval, cfg.LoadShed.Cooldown)
+		pressure.Start()
+		defer pressure.Stop()
+	}
+
 	// Initialize services
 	deps := &service.Dependencies{
-		DB:           db,
-		ProductRepo:  productRepo,
-		OrderRepo:    orderRepo,
-		TxRepo:       txRepo,
-		SettleRepo:   settleRepo,
-		JobRepo:      jobRepo,
-		JobProcessor: jobProcessor,
+		DB:              db,
+		ProductRepo:     productRepo,
+		OrderRepo:       orderRepo,
+		BundleRepo:      bundleRepo,
+		SalesEventRepo:  salesEventRepo,
+		TxRepo:          txRepo,
+		SettleRepo:      settleRepo,
+		JobRepo:         jobRepo,
+		ForecastRepo:    forecastRepo,
+		CalendarRepo:    calendarRepo,
+		StatsRepo:       statsRepo,
+		AnalyticsRepo:   analyticsRepo,
+		SagaRepo:        sagaRepo,
+		MaintenanceRepo: maintenanceRepo,
+		WebhookRepo:     webhookRepo,
+		AuthBanRepo:     authBanRepo,
+		PayoutRepo:      payoutRepo,
+		UnitOfWork:      uow,
+		Sagas:           sagas,
+		OrderQueue:      orderQueue,
+		FastPath:        fastPath,
+		SalesEvents:     salesEvents,
+		WaitingRoom:     waitingRoom,
+		AuthGuard:       authGuard,
+		Pricing:         pricing,
+		Maintenance:     maintenance,
+		JobProcessor:    jobProcessor,
+		JobsConfig:      &cfg.Jobs,
+		AdminConfig:     &cfg.Admin,
+		HealthConfig:    &cfg.Health,
+		OrdersConfig:    &cfg.Orders,
+		WebhookConfig:   &cfg.Webhook,
+		PayoutConfig:    &cfg.Payout,
+		Files:           files,
+		Search:          searchClient,
+		Pressure:        pressure,
 	}
 	services := service.NewServices(deps)
 
+	if fastPath != nil {
+		fastPath.Start()
+		defer fastPath.Stop()
+	}
+
+	// Compensate sagas interrupted by a previous shutdown or crash; sagas
+	// register their definitions as the services are created above
+	if leader.IsLeader(context.Background()) {
+		if _, err := sagas.Recover(context.Background()); err != nil {
+			logger.WithError(err).Error("Failed to recover unfinished sagas")
+		}
+	}
+
+	// Start daily settlement scheduling
+	if cfg.Settlement.ScheduleEnabled {
+		scheduler := service.NewSettlementScheduler(services.Job, &cfg.Settlement, leader)
+		scheduler.Start()
+		defer scheduler.Stop()
+	}
+
+	// Start automatic re-settlement of settlements invalidated by late transactions
+	if cfg.Settlement.AutoResettleEnabled {
+		monitor := service.NewStalenessMonitor(services.Settlement, services.Job, &cfg.Settlement, leader)
+		monitor.Start()
+		defer monitor.Stop()
+	}
+
+	// Start mirroring products and orders into the search indexes, reading
+	// changed rows on the batch pool
+	if searchClient != nil {
+		indexer := service.NewSearchIndexer(searchClient, repository.NewSearchRepository(batch.DB), &cfg.Search, leader)
+		indexer.Start()
+		defer indexer.Stop()
+	}
+
+	// Start maintaining the order analytics projection on the batch pool
+	if cfg.Analytics.ProjectionEnabled {
+		projector := service.NewOrderProjector(repository.NewAnalyticsRepository(batch.DB), &cfg.Analytics, leader)
+		projector.Start()
+		defer projector.Stop()
+	}
+
+	// Set Gin mode before any router is built
+	gin.SetMode(cfg.Server.Mode)
+
 	// Initialize handlers
-	h := handlers.New(services)
+	h := handlers.New(services, cfg)
 
-	// Set Gin mode
-	if cfg.Log.Level == "debug" {
-		gin.SetMode(gin.DebugMode)
-	} else {
-		gin.SetMode(gin.ReleaseMode)
+	// Serve requests made with a sandbox key from the sandbox schema
+	if cfg.Sandbox.Enabled() {
+		sandboxServices, closeSandbox, err := newSandbox(cfg, maintenance, pricing, authGuard, authBanRepo)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to start sandbox")
+		}
+		defer closeSandbox()
+		h.ServeSandbox(routes.SetupRoutes(handlers.New(sandboxServices, cfg)))
 	}
 
 	// Setup routes
 	router := routes.SetupRoutes(h)
 
-	// Create HTTP server
-	server := &http.Server{
-		Addr:         ":" + cfg.Server.Port,
-		Handler:      router,
-		ReadTimeout:  cfg.Server.ReadTimeout,
-		WriteTimeout: cfg.Server.WriteTimeout,
-		IdleTimeout:  cfg.Server.IdleTimeout,
+	// Start the HTTP server, or hand the degraded one the router
+	if startup != nil {
+		startup.ready(router)
+	} else {
+		server = newHTTPServer(cfg, router)
+		go listen(server)
 	}
 
-	// Start server in a goroutine
-	go func() {
-		logger.Infof("Starting HTTP server on port %s", cfg.Server.Port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.WithError(err).Fatal("Failed to start HTTP server")
-		}
-	}()
+	// Serve metrics and the admin API on the internal listener
+	var internalServer *http.Server
+	if cfg.Internal.Enabled() {
+		internalServer = newInternalServer(cfg, routes.SetupInternalRoutes(h))
+		go listen(internalServer)
+	}
 
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
@@ -109,9 +352,142 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	if internalServer != nil {
+		if err := internalServer.Shutdown(ctx); err != nil {
+			logger.WithError(err).Error("Internal server forced to shutdown")
+		}
+	}
 	if err := server.Shutdown(ctx); err != nil {
 		logger.WithError(err).Error("Server forced to shutdown")
 	} else {
 		logger.Info("Server shutdown complete")
 	}
 }
+
+// newHTTPServer creates the HTTP server for handler
+func newHTTPServer(cfg *config.Config, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:         ":" + cfg.Server.Port,
+		Handler:      handler,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
+}
+
+// newInternalServer creates the internal listener's server for handler,
+// serving TLS and verifying client certificates as configured
+func newInternalServer(cfg *config.Config, handler http.Handler) *http.Server {
+	server := newHTTPServer(cfg, handler)
+	server.Addr = ":" + cfg.Internal.Port
+	server.TLSConfig = cfg.Internal.TLSConfig()
+	return server
+}
+
+// listen serves HTTP, or HTTPS when the server has a TLS configuration,
+// until the server is shut down
+func listen(server *http.Server) {
+	if server.TLSConfig != nil {
+		logger.WithComponent("server").WithField("client_certs", server.TLSConfig.ClientAuth == tls.RequireAndVerifyClientCert).
+			Infof("Starting HTTPS server on %s", server.Addr)
+		if err := server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+			logger.WithError(err).Fatal("Failed to start HTTPS server")
+		}
+		return
+	}
+
+	logger.Infof("Starting HTTP server on %s", server.Addr)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.WithError(err).Fatal("Failed to start HTTP server")
+	}
+}
+
+// newSandbox creates the services sandbox requests are served from: the
+// live services over a pool pinned to the sandbox schema, with their own
+// job processor. Background work that only makes sense on live data, such
+// as scheduled settlement, search indexing and the stock fast path, is not
+// started, and sandbox orders need no queue token. Failed authentication
+// counts towards the same bans as live requests, kept in the live audit
+// trail.
+func newSandbox(cfg *config.Config, maintenance *service.MaintenanceMode, pricing *service.Pricing, authGuard *service.AuthGuard, authBanRepo repository.AuthBanRepository) (*service.Services, func(), error) {
+	db, err := database.New(cfg.Sandbox.Database(&cfg.Database))
+	if err != nil {
+		return nil, nil, fmt.Errorf("sandbox pool: %w", err)
+	}
+
+	productRepo := repository.NewProductRepository(db.DB, nil)
+	orderRepo := repository.NewOrderRepository(db.DB, nil)
+	bundleRepo := repository.NewBundleRepository(db.DB)
+	salesEventRepo := repository.NewSalesEventRepository(db.DB)
+	txRepo := repository.NewTransactionRepository(db.DB)
+	settleRepo := repository.NewSettlementRepository(db.DB)
+	jobRepo := repository.NewJobRepository(db.DB)
+	forecastRepo := repository.NewForecastRepository(db.DB)
+	calendarRepo := repository.NewCalendarRepository(db.DB)
+	sagaRepo := repository.NewSagaRepository(db.DB)
+	webhookRepo := repository.NewWebhookRepository(db.DB)
+	payoutRepo := repository.NewPayoutRepository(db.DB)
+	uow := repository.NewUnitOfWork(db, repository.Writers{
+		Products:     productRepo,
+		Orders:       orderRepo,
+		Bundles:      bundleRepo,
+		SalesEvents:  salesEventRepo,
+		Transactions: txRepo,
+		Settlements:  settleRepo,
+		Forecasts:    forecastRepo,
+		Jobs:         jobRepo,
+		Sagas:        sagaRepo,
+		Payouts:      payoutRepo,
+	})
+
+	// Sandbox job files live in their own directory, so a live job's files
+	// can't be downloaded from the sandbox
+	files := storage.NewLocal(filepath.Join(cfg.Storage.LocalDir, cfg.Sandbox.Schema))
+	jobProcessor := service.NewJobProcessor(db, &cfg.Jobs, txRepo, settleRepo, jobRepo, productRepo, orderRepo, forecastRepo, calendarRepo, sagaRepo, payoutRepo, webhookRepo, &cfg.Settlement, &cfg.Webhook, &cfg.Payout, maintenance, files)
+	jobProcessor.Start()
+
+	salesEvents := service.NewSalesEvents(salesEventRepo, nil, cfg.Orders.SalesEventRefreshInterval, 0)
+	salesEvents.Start()
+
+	services := service.NewServices(&service.Dependencies{
+		DB:              db,
+		ProductRepo:     productRepo,
+		OrderRepo:       orderRepo,
+		BundleRepo:      bundleRepo,
+		SalesEventRepo:  salesEventRepo,
+		TxRepo:          txRepo,
+		SettleRepo:      settleRepo,
+		JobRepo:         jobRepo,
+		ForecastRepo:    forecastRepo,
+		CalendarRepo:    calendarRepo,
+		StatsRepo:       repository.NewStatsRepository(db.DB),
+		AnalyticsRepo:   repository.NewAnalyticsRepository(db.DB),
+		SagaRepo:        sagaRepo,
+		MaintenanceRepo: repository.NewMaintenanceRepository(db.DB),
+		WebhookRepo:     webhookRepo,
+		AuthBanRepo:     authBanRepo,
+		PayoutRepo:      payoutRepo,
+		UnitOfWork:      uow,
+		Sagas:           service.NewSagaOrchestrator(uow, sagaRepo),
+		SalesEvents:     salesEvents,
+		AuthGuard:       authGuard,
+		Pricing:         pricing,
+		Maintenance:     maintenance,
+		JobProcessor:    jobProcessor,
+		JobsConfig:      &cfg.Jobs,
+		AdminConfig:     &cfg.Admin,
+		HealthConfig:    &cfg.Health,
+		OrdersConfig:    &cfg.Orders,
+		WebhookConfig:   &cfg.Webhook,
+		PayoutConfig:    &cfg.Payout,
+		Files:           files,
+	})
+
+	logger.Infof("Sandbox enabled on schema %s", cfg.Sandbox.Schema)
+
+	return services, func() {
+		salesEvents.Stop()
+		jobProcessor.Stop()
+		db.Close()
+	}, nil
+}
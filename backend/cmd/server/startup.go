@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+
+	"indico-backend/internal/logger"
+)
+
+// startupRetryAfter is the Retry-After hint, in seconds, sent while the
+// server waits for its dependencies
+const startupRetryAfter = "5"
+
+// startupHandler serves requests while a degraded start waits for the
+// database: /healthz reports the process alive so orchestrators don't
+// restart it, and everything else, /health included, gets 503. Once the
+// router is ready every request goes to it on the same listener.
+type startupHandler struct {
+	router atomic.Pointer[http.Handler]
+}
+
+// ready hands all further requests to router
+func (s *startupHandler) ready(router http.Handler) {
+	s.router.Store(&router)
+	logger.WithComponent("server").Info("Dependencies available, leaving degraded mode")
+}
+
+func (s *startupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if router := s.router.Load(); router != nil {
+		(*router).ServeHTTP(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Path == "/healthz" {
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+		return
+	}
+
+	w.Header().Set("Retry-After", startupRetryAfter)
+	w.WriteHeader(http.StatusServiceUnavailable)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "starting",
+		"checks": map[string]string{"database": "unavailable"},
+	})
+}
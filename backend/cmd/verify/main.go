@@ -0,0 +1,60 @@
+// Package main provides a verifier that recomputes settlements from raw
+// transactions and compares them to stored settlements and a generated CSV
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"indico-backend/internal/config"
+	"indico-backend/internal/database"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/verify"
+)
+
+func main() {
+	fromFlag := flag.String("from", "", "first settlement date to verify (YYYY-MM-DD)")
+	toFlag := flag.String("to", "", "last settlement date to verify (YYYY-MM-DD)")
+	csvPath := flag.String("csv", "", "settlement CSV generated for the same range (optional)")
+	flag.Parse()
+
+	from, err := time.Parse("2006-01-02", *fromFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "verify: -from is required (YYYY-MM-DD)")
+		os.Exit(2)
+	}
+	to, err := time.Parse("2006-01-02", *toFlag)
+	if err != nil || to.Before(from) {
+		fmt.Fprintln(os.Stderr, "verify: -to is required (YYYY-MM-DD) and must not be before -from")
+		os.Exit(2)
+	}
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		panic(fmt.Sprintf("Failed to load configuration: %v", err))
+	}
+
+	// Initialize logger
+	logger.Init("info", "text")
+
+	// Connect to database
+	db, err := database.New(&cfg.Database)
+	if err != nil {
+		logger.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	result, err := verify.Settlements(context.Background(), db, &cfg.Settlement, from, to, *csvPath)
+	if err != nil {
+		logger.Fatalf("Failed to verify settlements: %v", err)
+	}
+
+	result.Report(os.Stdout)
+	if !result.OK() {
+		os.Exit(1)
+	}
+}
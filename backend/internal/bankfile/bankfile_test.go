@@ -0,0 +1,160 @@
+package bankfile
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateRoutingNumber(t *testing.T) {
+	for _, valid := range []string{"021000021", "011000015", "121000248"} {
+		assert.NoError(t, ValidateRoutingNumber(valid), valid)
+	}
+	for _, invalid := range []string{"021000022", "02100002", "0210000210", "02100002a", ""} {
+		assert.Error(t, ValidateRoutingNumber(invalid), invalid)
+	}
+}
+
+func TestValidateIBAN(t *testing.T) {
+	for _, valid := range []string{"DE89370400440532013000", "GB82WEST12345698765432", "FR1420041010050500013M02606"} {
+		assert.NoError(t, ValidateIBAN(valid), valid)
+	}
+	for _, invalid := range []string{"DE89370400440532013001", "DE8937040044", "de89370400440532013000", "XX00"} {
+		assert.Error(t, ValidateIBAN(invalid), invalid)
+	}
+
+	assert.Equal(t, "DE89370400440532013000", NormalizeIBAN(" de89 3704 0044 0532 0130 00 "))
+}
+
+func TestValidateBICAndAccountNumber(t *testing.T) {
+	assert.NoError(t, ValidateBIC("DEUTDEFF"))
+	assert.NoError(t, ValidateBIC("COBADEFFXXX"))
+	assert.Error(t, ValidateBIC("DEUTDEF"))
+	assert.Error(t, ValidateBIC("deutdeff"))
+
+	assert.NoError(t, ValidateAccountNumber("12345678901234567"))
+	assert.Error(t, ValidateAccountNumber("123456789012345678"))
+	assert.Error(t, ValidateAccountNumber(""))
+}
+
+func nachaFile() *NACHAFile {
+	return &NACHAFile{
+		Originator: NACHAOriginator{
+			DestinationRouting: "021000021",
+			DestinationName:    "JPMorgan Chase",
+			OriginRouting:      "121000248",
+			OriginName:         "Indico",
+			CompanyName:        "Indico Payouts",
+			CompanyID:          "1234567890",
+		},
+		Credits: []NACHACredit{
+			{RoutingNumber: "011000015", AccountNumber: "12345", AmountCents: 150075, ID: "merchant_a", Name: "Merchant Ä"},
+			{RoutingNumber: "021000021", AccountNumber: "987654321", AmountCents: 25, ID: "merchant_b", Name: "Merchant B"},
+		},
+		CreatedAt:     time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC),
+		EffectiveDate: time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestWriteNACHA(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteNACHA(&buf, nachaFile()))
+
+	records := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, records, 10)
+	for i, record := range records {
+		assert.Len(t, record, recordLength, "record %d", i+1)
+	}
+
+	assert.Equal(t, "101 021000021 1210002482610160930A094101JPMORGAN CHASE         INDICO                         ", records[0])
+	assert.Equal(t, "5220INDICO PAYOUTS                      1234567890CCDPAYOUT          261019   112100024"+"0000001", records[1])
+	assert.Equal(t, "622011000015"+"12345            "+"0000150075"+"MERCHANT_A     "+"MERCHANT              "+"  0"+"121000240000001", records[2])
+	assert.Equal(t, "622021000021"+"987654321        "+"0000000025"+"MERCHANT_B     "+"MERCHANT B            "+"  0"+"121000240000002", records[3])
+
+	// The entry hash sums the receiving banks' first 8 routing digits
+	assert.Equal(t, "8220"+"000002"+"0003200003"+"000000000000"+"000000150100"+"1234567890", records[4][:54])
+	assert.Equal(t, "12100024"+"0000001", records[4][79:])
+	assert.Equal(t, "9"+"000001"+"000001"+"00000002"+"0003200003"+"000000000000"+"000000150100", records[5][:55])
+	for _, filler := range records[6:] {
+		assert.Equal(t, strings.Repeat("9", recordLength), filler)
+	}
+}
+
+func TestWriteNACHARejectsInvalidCredits(t *testing.T) {
+	tests := map[string]func(f *NACHAFile){
+		"bad routing":  func(f *NACHAFile) { f.Credits[1].RoutingNumber = "021000022" },
+		"long account": func(f *NACHAFile) { f.Credits[0].AccountNumber = strings.Repeat("1", 18) },
+		"zero amount":  func(f *NACHAFile) { f.Credits[0].AmountCents = 0 },
+		"no credits":   func(f *NACHAFile) { f.Credits = nil },
+		"bad origin":   func(f *NACHAFile) { f.Originator.OriginRouting = "123" },
+	}
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := nachaFile()
+			mutate(f)
+
+			var buf bytes.Buffer
+			assert.Error(t, WriteNACHA(&buf, f))
+			assert.Zero(t, buf.Len())
+		})
+	}
+}
+
+func TestWriteSEPA(t *testing.T) {
+	f := &SEPAFile{
+		MessageID:     "3f1c9a5e2b7d4c8e9f0a1b2c3d4e5f60",
+		CreatedAt:     time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC),
+		ExecutionDate: time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC),
+		Debtor:        SEPADebtor{Name: "Indico GmbH", IBAN: "DE89370400440532013000"},
+		Credits: []SEPACredit{
+			{EndToEndID: "p1", AmountCents: 123456, Name: "Café <Müller> & Co", IBAN: "FR1420041010050500013M02606", BIC: "COBADEFFXXX", Remittance: "Payout 2026-10-01 to 2026-10-15"},
+			{EndToEndID: "p2", AmountCents: 5, Name: "Shop", IBAN: "GB82WEST12345698765432"},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteSEPA(&buf, f))
+	assert.True(t, strings.HasPrefix(buf.String(), xml.Header))
+	assert.Contains(t, buf.String(), `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.001.001.03">`)
+
+	var doc sepaDocument
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+	header := doc.Initiation.Header
+	assert.Equal(t, "2", header.Transactions)
+	assert.Equal(t, "1234.61", header.ControlSum)
+	assert.Equal(t, "2026-10-16T09:30:00", header.CreatedAt)
+
+	payment := doc.Initiation.Payment
+	assert.Equal(t, "2026-10-19", payment.ExecutionDate)
+	assert.Equal(t, "SEPA", payment.ServiceLevel)
+	assert.Equal(t, "NOTPROVIDED", payment.DebtorAgent.Other.ID)
+	require.Len(t, payment.Credits, 2)
+
+	first := payment.Credits[0]
+	assert.Equal(t, "1234.56", first.Amount.Value)
+	assert.Equal(t, "EUR", first.Amount.Currency)
+	assert.Equal(t, "Caf   M ller    Co", first.Creditor.Name)
+	assert.Equal(t, "COBADEFFXXX", first.Agent.BIC)
+	assert.Equal(t, "Payout 2026-10-01 to 2026-10-15", first.Remittance.Unstructured)
+
+	second := payment.Credits[1]
+	assert.Equal(t, "0.05", second.Amount.Value)
+	assert.Nil(t, second.Agent)
+	assert.Nil(t, second.Remittance)
+}
+
+func TestWriteSEPARejectsInvalidCredits(t *testing.T) {
+	f := &SEPAFile{
+		Debtor:  SEPADebtor{Name: "Indico GmbH", IBAN: "DE89370400440532013000"},
+		Credits: []SEPACredit{{EndToEndID: "p1", AmountCents: 100, Name: "Shop", IBAN: "DE89370400440532013001"}},
+	}
+
+	var buf bytes.Buffer
+	assert.ErrorContains(t, WriteSEPA(&buf, f), "credit 1")
+	assert.Zero(t, buf.Len())
+}
@@ -0,0 +1,237 @@
+package bankfile
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ContentTypeSEPA is the media type SEPA files are served with
+const ContentTypeSEPA = "application/xml"
+
+// sepaNamespace is the pain.001.001.03 customer credit transfer schema,
+// the version every SEPA bank accepts
+const sepaNamespace = "urn:iso:std:iso:20022:tech:xsd:pain.001.001.03"
+
+// SEPA field lengths
+const (
+	sepaIDLength         = 35
+	sepaNameLength       = 70
+	sepaRemittanceLength = 140
+)
+
+// SEPADebtor is the account the credits are paid from
+type SEPADebtor struct {
+	Name string
+	IBAN string
+	// BIC is optional within SEPA; the bank derives it from the IBAN
+	BIC string
+}
+
+// SEPACredit is one payment to a creditor account, in euro cents
+type SEPACredit struct {
+	// EndToEndID is passed to the creditor with the payment, up to 35
+	// characters
+	EndToEndID  string
+	AmountCents int
+	Name        string
+	IBAN        string
+	BIC         string
+	Remittance  string
+}
+
+// SEPAFile is a SEPA credit transfer initiation with one payment of credits
+type SEPAFile struct {
+	// MessageID identifies the file to the bank, up to 35 characters
+	MessageID     string
+	CreatedAt     time.Time
+	ExecutionDate time.Time
+	Debtor        SEPADebtor
+	Credits       []SEPACredit
+}
+
+// WriteSEPA writes f as a pain.001.001.03 XML document. Every credit is
+// validated first, so nothing is written for an invalid one; names and
+// remittance text are reduced to the SEPA character set.
+func WriteSEPA(w io.Writer, f *SEPAFile) error {
+	if err := ValidateIBAN(f.Debtor.IBAN); err != nil {
+		return fmt.Errorf("debtor: %w", err)
+	}
+	if f.Debtor.BIC != "" {
+		if err := ValidateBIC(f.Debtor.BIC); err != nil {
+			return fmt.Errorf("debtor: %w", err)
+		}
+	}
+	if len(f.Credits) == 0 {
+		return fmt.Errorf("a SEPA file needs at least one credit")
+	}
+
+	total := 0
+	transactions := make([]sepaTransaction, 0, len(f.Credits))
+	for i, credit := range f.Credits {
+		if err := ValidateIBAN(credit.IBAN); err != nil {
+			return fmt.Errorf("credit %d: %w", i+1, err)
+		}
+		if credit.BIC != "" {
+			if err := ValidateBIC(credit.BIC); err != nil {
+				return fmt.Errorf("credit %d: %w", i+1, err)
+			}
+		}
+		if credit.AmountCents <= 0 {
+			return fmt.Errorf("credit %d: amount %d cents must be positive", i+1, credit.AmountCents)
+		}
+		total += credit.AmountCents
+
+		tx := sepaTransaction{
+			EndToEndID: sepaText(credit.EndToEndID, sepaIDLength),
+			Amount:     sepaAmount{Currency: "EUR", Value: euros(credit.AmountCents)},
+			Creditor:   sepaParty{Name: sepaText(credit.Name, sepaNameLength)},
+			Account:    sepaAccount{IBAN: credit.IBAN},
+		}
+		if credit.BIC != "" {
+			tx.Agent = &sepaAgent{BIC: credit.BIC}
+		}
+		if credit.Remittance != "" {
+			tx.Remittance = &sepaRemittance{Unstructured: sepaText(credit.Remittance, sepaRemittanceLength)}
+		}
+		transactions = append(transactions, tx)
+	}
+
+	// Without a BIC the debtor agent is marked as not provided
+	debtorAgent := sepaAgent{BIC: f.Debtor.BIC}
+	if f.Debtor.BIC == "" {
+		debtorAgent = sepaAgent{Other: &sepaOther{ID: "NOTPROVIDED"}}
+	}
+
+	messageID := sepaText(f.MessageID, sepaIDLength)
+	count := fmt.Sprint(len(transactions))
+	doc := sepaDocument{
+		Namespace: sepaNamespace,
+		Initiation: sepaInitiation{
+			Header: sepaGroupHeader{
+				MessageID:    messageID,
+				CreatedAt:    f.CreatedAt.UTC().Format("2006-01-02T15:04:05"),
+				Transactions: count,
+				ControlSum:   euros(total),
+				Initiator:    sepaParty{Name: sepaText(f.Debtor.Name, sepaNameLength)},
+			},
+			Payment: sepaPayment{
+				ID:            messageID,
+				Method:        "TRF",
+				Transactions:  count,
+				ControlSum:    euros(total),
+				ServiceLevel:  "SEPA",
+				ExecutionDate: f.ExecutionDate.Format("2006-01-02"),
+				Debtor:        sepaParty{Name: sepaText(f.Debtor.Name, sepaNameLength)},
+				DebtorAccount: sepaAccount{IBAN: f.Debtor.IBAN},
+				DebtorAgent:   debtorAgent,
+				ChargeBearer:  "SLEV",
+				Credits:       transactions,
+			},
+		},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to encode SEPA file: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+type sepaDocument struct {
+	XMLName    xml.Name       `xml:"Document"`
+	Namespace  string         `xml:"xmlns,attr"`
+	Initiation sepaInitiation `xml:"CstmrCdtTrfInitn"`
+}
+
+type sepaInitiation struct {
+	Header  sepaGroupHeader `xml:"GrpHdr"`
+	Payment sepaPayment     `xml:"PmtInf"`
+}
+
+type sepaGroupHeader struct {
+	MessageID    string    `xml:"MsgId"`
+	CreatedAt    string    `xml:"CreDtTm"`
+	Transactions string    `xml:"NbOfTxs"`
+	ControlSum   string    `xml:"CtrlSum"`
+	Initiator    sepaParty `xml:"InitgPty"`
+}
+
+type sepaPayment struct {
+	ID            string            `xml:"PmtInfId"`
+	Method        string            `xml:"PmtMtd"`
+	Transactions  string            `xml:"NbOfTxs"`
+	ControlSum    string            `xml:"CtrlSum"`
+	ServiceLevel  string            `xml:"PmtTpInf>SvcLvl>Cd"`
+	ExecutionDate string            `xml:"ReqdExctnDt"`
+	Debtor        sepaParty         `xml:"Dbtr"`
+	DebtorAccount sepaAccount       `xml:"DbtrAcct"`
+	DebtorAgent   sepaAgent         `xml:"DbtrAgt"`
+	ChargeBearer  string            `xml:"ChrgBr"`
+	Credits       []sepaTransaction `xml:"CdtTrfTxInf"`
+}
+
+type sepaTransaction struct {
+	EndToEndID string          `xml:"PmtId>EndToEndId"`
+	Amount     sepaAmount      `xml:"Amt>InstdAmt"`
+	Agent      *sepaAgent      `xml:"CdtrAgt,omitempty"`
+	Creditor   sepaParty       `xml:"Cdtr"`
+	Account    sepaAccount     `xml:"CdtrAcct"`
+	Remittance *sepaRemittance `xml:"RmtInf,omitempty"`
+}
+
+type sepaAmount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type sepaParty struct {
+	Name string `xml:"Nm"`
+}
+
+type sepaAccount struct {
+	IBAN string `xml:"Id>IBAN"`
+}
+
+type sepaAgent struct {
+	BIC   string     `xml:"FinInstnId>BIC,omitempty"`
+	Other *sepaOther `xml:"FinInstnId>Othr,omitempty"`
+}
+
+type sepaOther struct {
+	ID string `xml:"Id"`
+}
+
+type sepaRemittance struct {
+	Unstructured string `xml:"Ustrd"`
+}
+
+// euros formats cents as a decimal euro amount, e.g. 123456 as "1234.56"
+func euros(cents int) string {
+	return fmt.Sprintf("%d.%02d", cents/100, cents%100)
+}
+
+// sepaText reduces s to the Latin characters every SEPA bank accepts,
+// replacing the others with a space, and cuts it to max characters
+func sepaText(s string, max int) string {
+	cleaned := []rune(strings.TrimSpace(s))
+	for i, r := range cleaned {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("/-?:().,'+ ", r):
+		default:
+			cleaned[i] = ' '
+		}
+	}
+	if len(cleaned) > max {
+		cleaned = cleaned[:max]
+	}
+	return strings.TrimSpace(string(cleaned))
+}
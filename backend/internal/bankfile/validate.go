@@ -0,0 +1,91 @@
+// Package bankfile writes payout files for bank submission: NACHA ACH files
+// for US accounts and SEPA credit transfer XML for euro accounts
+package bankfile
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// routingNumber matches a nine-digit ABA routing number
+var routingNumber = regexp.MustCompile(`^[0-9]{9}$`)
+
+// accountNumber matches a DFI account number, which NACHA caps at 17
+// characters
+var accountNumber = regexp.MustCompile(`^[0-9A-Z-]{1,17}$`)
+
+// iban matches the shape of a normalized IBAN: a country code, two check
+// digits and up to 30 alphanumerics
+var iban = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[0-9A-Z]{11,30}$`)
+
+// bic matches an 8 or 11 character BIC: bank, country, location and an
+// optional branch
+var bic = regexp.MustCompile(`^[A-Z]{4}[A-Z]{2}[0-9A-Z]{2}([0-9A-Z]{3})?$`)
+
+// ValidateRoutingNumber checks that s is nine digits with a valid ABA
+// checksum: 3, 7 and 1 times the digits in turn must sum to a multiple of 10
+func ValidateRoutingNumber(s string) error {
+	if !routingNumber.MatchString(s) {
+		return fmt.Errorf("routing number must be 9 digits")
+	}
+
+	weights := [3]int{3, 7, 1}
+	sum := 0
+	for i := 0; i < len(s); i++ {
+		sum += int(s[i]-'0') * weights[i%3]
+	}
+	if sum%10 != 0 {
+		return fmt.Errorf("routing number checksum does not match")
+	}
+	return nil
+}
+
+// ValidateAccountNumber checks that s fits the 17-character account number
+// field of an ACH entry
+func ValidateAccountNumber(s string) error {
+	if !accountNumber.MatchString(s) {
+		return fmt.Errorf("account number must be 1-17 digits, uppercase letters or hyphens")
+	}
+	return nil
+}
+
+// NormalizeIBAN removes the spaces IBANs are usually printed with and
+// uppercases the rest
+func NormalizeIBAN(s string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(s), " ", ""))
+}
+
+// ValidateIBAN checks the shape and ISO 13616 mod-97 check digits of a
+// normalized IBAN
+func ValidateIBAN(s string) error {
+	if !iban.MatchString(s) {
+		return fmt.Errorf("IBAN must be a country code, 2 check digits and 11-30 letters or digits")
+	}
+
+	// Move the country code and check digits to the end, read letters as
+	// 10-35 and take the number mod 97 a digit at a time
+	rearranged := s[4:] + s[:4]
+	remainder := 0
+	for i := 0; i < len(rearranged); i++ {
+		c := rearranged[i]
+		if c >= 'A' && c <= 'Z' {
+			value := int(c-'A') + 10
+			remainder = (remainder*100 + value) % 97
+		} else {
+			remainder = (remainder*10 + int(c-'0')) % 97
+		}
+	}
+	if remainder != 1 {
+		return fmt.Errorf("IBAN check digits do not match")
+	}
+	return nil
+}
+
+// ValidateBIC checks that s is an 8 or 11 character BIC
+func ValidateBIC(s string) error {
+	if !bic.MatchString(s) {
+		return fmt.Errorf("BIC must be 8 or 11 uppercase letters or digits")
+	}
+	return nil
+}
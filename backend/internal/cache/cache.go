@@ -0,0 +1,36 @@
+// Package cache provides the Redis client
+package cache
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+
+	"indico-backend/internal/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// New connects to the configured Redis server and verifies the connection
+func New(cfg *config.CacheConfig) (*redis.Client, error) {
+	opts := &redis.Options{
+		Addr:        cfg.Addr,
+		Password:    cfg.Password,
+		DB:          cfg.DB,
+		DialTimeout: cfg.DialTimeout,
+	}
+	if cfg.TLS {
+		opts.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.DialTimeout)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to ping redis: %w", err)
+	}
+
+	return client, nil
+}
@@ -0,0 +1,138 @@
+// Package calendar provides business-day rules used to schedule settlements
+package calendar
+
+import (
+	"time"
+
+	"indico-backend/internal/models"
+)
+
+const dateFormat = "2006-01-02"
+
+// maxRollDays bounds how far a non-business day may roll forward, guarding
+// against a misconfigured calendar where every day is a holiday
+const maxRollDays = 31
+
+// Calendar describes the business days of a single region
+type Calendar struct {
+	Region   string
+	weekend  map[time.Weekday]bool
+	holidays map[string]string
+}
+
+// New creates a calendar for a region with Saturday/Sunday weekends and the
+// given holidays. Holidays belonging to other regions are ignored.
+func New(region string, holidays []*models.Holiday) *Calendar {
+	c := &Calendar{
+		Region:   region,
+		weekend:  map[time.Weekday]bool{time.Saturday: true, time.Sunday: true},
+		holidays: make(map[string]string),
+	}
+
+	for _, h := range holidays {
+		if h.Region == region {
+			c.holidays[h.Date.Format(dateFormat)] = h.Name
+		}
+	}
+
+	return c
+}
+
+// IsBusinessDay reports whether settlements may be dated on the given day
+func (c *Calendar) IsBusinessDay(day time.Time) bool {
+	if c == nil {
+		return true
+	}
+	if c.weekend[day.Weekday()] {
+		return false
+	}
+	_, holiday := c.holidays[day.Format(dateFormat)]
+	return !holiday
+}
+
+// SettlementDate returns the day on which activity from the given day
+// settles: the day itself when it is a business day, otherwise the next one
+func (c *Calendar) SettlementDate(day time.Time) time.Time {
+	day = truncateDay(day)
+	for i := 0; i < maxRollDays && !c.IsBusinessDay(day); i++ {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day
+}
+
+// FirstContributingDay returns the earliest day whose activity settles on
+// settlementDay, i.e. the day after the preceding business day
+func (c *Calendar) FirstContributingDay(settlementDay time.Time) time.Time {
+	day := truncateDay(settlementDay)
+	for i := 0; i < maxRollDays && !c.IsBusinessDay(day.AddDate(0, 0, -1)); i++ {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day
+}
+
+// Book resolves the calendar that applies to each merchant. Merchants with
+// no assigned region use the default calendar; a nil default calendar
+// settles every day as-is.
+type Book struct {
+	calendars map[string]*Calendar
+	merchants map[string]string
+	fallback  *Calendar
+}
+
+// NewBook creates a calendar book from holiday lists and merchant region
+// assignments. defaultRegion may be empty to disable rolling for merchants
+// without an assignment.
+func NewBook(defaultRegion string, holidays []*models.Holiday, assignments []*models.MerchantCalendar) *Book {
+	b := &Book{
+		calendars: make(map[string]*Calendar),
+		merchants: make(map[string]string),
+	}
+
+	for _, a := range assignments {
+		b.merchants[a.MerchantID] = a.Region
+		b.calendars[a.Region] = nil
+	}
+	if defaultRegion != "" {
+		b.calendars[defaultRegion] = nil
+	}
+	for region := range b.calendars {
+		b.calendars[region] = New(region, holidays)
+	}
+	if defaultRegion != "" {
+		b.fallback = b.calendars[defaultRegion]
+	}
+
+	return b
+}
+
+// ForMerchant returns the calendar that applies to a merchant
+func (b *Book) ForMerchant(merchantID string) *Calendar {
+	if region, ok := b.merchants[merchantID]; ok {
+		return b.calendars[region]
+	}
+	return b.fallback
+}
+
+// SettlementDate returns the day on which a merchant's activity from the
+// given day settles
+func (b *Book) SettlementDate(merchantID string, day time.Time) time.Time {
+	return b.ForMerchant(merchantID).SettlementDate(day)
+}
+
+// FirstContributingDay returns the earliest day, across all calendars in
+// the book, whose activity may settle on settlementDay
+func (b *Book) FirstContributingDay(settlementDay time.Time) time.Time {
+	earliest := truncateDay(settlementDay)
+	for _, c := range b.calendars {
+		if day := c.FirstContributingDay(settlementDay); day.Before(earliest) {
+			earliest = day
+		}
+	}
+	return earliest
+}
+
+// truncateDay returns the start of the UTC day containing t
+func truncateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -2,26 +2,163 @@
 package config
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
+
+	"indico-backend/internal/bankfile"
+)
+
+// Environment profiles. The profile picks defaults suited to where the server
+// runs, so a production deployment is locked down unless told otherwise and
+// a laptop works without any settings.
+const (
+	EnvDev     = "dev"
+	EnvStaging = "staging"
+	EnvProd    = "prod"
 )
 
 // Config holds all configuration for the application
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Jobs     JobsConfig
-	Log      LogConfig
+	// Env is the environment profile: EnvDev, EnvStaging or EnvProd
+	Env        string
+	Server     ServerConfig
+	Internal   InternalConfig
+	Database   DatabaseConfig
+	Orders     OrdersConfig
+	Pricing    PricingConfig
+	Jobs       JobsConfig
+	LoadShed   LoadShedConfig
+	Settlement SettlementConfig
+	Payout     PayoutConfig
+	Log        LogConfig
+	Debug      DebugConfig
+	Admin      AdminConfig
+	Auth       AuthConfig
+	Storage    StorageConfig
+	Broker     BrokerConfig
+	Cache      CacheConfig
+	Webhook    WebhookConfig
+	Search     SearchConfig
+	Analytics  AnalyticsConfig
+	Sandbox    SandboxConfig
+	Health     HealthConfig
+
+	WaitingRoom WaitingRoomConfig
+}
+
+// Server modes, matching Gin's modes
+const (
+	ServerModeDebug   = "debug"
+	ServerModeRelease = "release"
+	ServerModeTest    = "test"
+)
+
+// X-Frame-Options values
+const (
+	FrameOptionsDeny       = "DENY"
+	FrameOptionsSameOrigin = "SAMEORIGIN"
+)
+
+// defaultContentSecurityPolicy suits an API that serves no pages: nothing
+// may be loaded from its responses, and nothing may frame them
+const defaultContentSecurityPolicy = "default-src 'none'; frame-ancestors 'none'"
+
+// IsProduction reports whether the server runs under the production profile
+func (c *Config) IsProduction() bool {
+	return c.Env == EnvProd
 }
 
 // ServerConfig holds server-related configuration
 type ServerConfig struct {
+	// Mode is the Gin mode, set on its own so that debug logging doesn't
+	// bring Gin's debug output and route dumps along with it
+	Mode         string
 	Port         string
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
 	IdleTimeout  time.Duration
+	// RequestTimeout bounds API requests and DownloadTimeout file downloads;
+	// the deadline is carried by the request context into services and
+	// repositories. 0 disables the deadline.
+	RequestTimeout  time.Duration
+	DownloadTimeout time.Duration
+	// DegradedStart keeps the server up when the database is still
+	// unreachable after DB_CONNECT_WAIT: it serves /healthz and answers
+	// everything else with 503 while it keeps retrying, instead of exiting
+	DegradedStart bool
+	// CORSAllowedOrigins lists the browser origins allowed to call the API;
+	// "*" allows any origin and an empty list none
+	CORSAllowedOrigins []string
+	// TrustedProxies lists the IPs and CIDRs of the proxies whose
+	// X-Forwarded-For header names the client; empty trusts none, so the
+	// client IP is the connection's peer and can't be spoofed by a header
+	TrustedProxies []string
+	// HSTSMaxAge is how long browsers must only use HTTPS, sent in
+	// Strict-Transport-Security; 0 leaves the header out
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	// ContentSecurityPolicy and FrameOptions are sent on every response;
+	// empty leaves the header out
+	ContentSecurityPolicy string
+	FrameOptions          string
+}
+
+// InternalConfig holds the internal listener. With a port set, the admin
+// API and metrics move off the public port onto their own, which can serve
+// TLS and, with a client CA, require every caller to present a certificate
+// it signed.
+type InternalConfig struct {
+	// Port is where the internal listener listens; empty serves the admin
+	// API and metrics on the public port
+	Port string
+	// CertFile and KeyFile are the listener's PEM certificate and key;
+	// empty serves plain HTTP
+	CertFile string
+	KeyFile  string
+	// ClientCAFile holds the PEM CA certificates client certificates must
+	// be signed by; empty requires none
+	ClientCAFile string
+
+	tls *tls.Config
+}
+
+// Enabled reports whether the internal listener is configured
+func (c *InternalConfig) Enabled() bool {
+	return c.Port != ""
+}
+
+// TLSConfig returns the listener's TLS configuration loaded by Validate, or
+// nil when it serves plain HTTP
+func (c *InternalConfig) TLSConfig() *tls.Config {
+	return c.tls
+}
+
+// ClientTLSConfig holds the client certificate presented on outbound calls,
+// for receivers in zero-trust networks that require mutual TLS
+type ClientTLSConfig struct {
+	// CertFile and KeyFile are the PEM client certificate and key; empty
+	// presents none
+	CertFile string
+	KeyFile  string
+	// CAFile holds PEM CA certificates trusted besides the system ones,
+	// such as an internal CA signing the receivers' certificates
+	CAFile string
+
+	tls *tls.Config
+}
+
+// TLSConfig returns the TLS configuration loaded by Validate, or nil when
+// neither a client certificate nor extra CAs are configured
+func (c *ClientTLSConfig) TLSConfig() *tls.Config {
+	return c.tls
 }
 
 // DatabaseConfig holds database connection configuration
@@ -34,6 +171,122 @@ type DatabaseConfig struct {
 	SSLMode  string
 	MaxConns int
 	MaxIdle  int
+	// BatchMaxConns sizes a separate pool for background jobs so their long
+	// scans can't take the connections order traffic needs; 0 shares the
+	// main pool
+	BatchMaxConns int
+	BatchMaxIdle  int
+	// PreparedStatements prepares the hot order-path queries once per
+	// connection; disable it behind a transaction-pooling proxy, which
+	// can't keep prepared statements
+	PreparedStatements bool
+	// Schema, when set, is the only schema on the search path, so every
+	// unqualified table name resolves there and nowhere else
+	Schema string
+	// ConnectWait is how long startup keeps retrying an unreachable
+	// database, starting ConnectBackoff apart and doubling; 0 fails on the
+	// first error
+	ConnectWait    time.Duration
+	ConnectBackoff time.Duration
+}
+
+// Order processing modes
+const (
+	// OrderModeDirect places each order on the request goroutine
+	OrderModeDirect = "direct"
+	// OrderModeQueued places orders one at a time per product through
+	// sharded in-memory queues
+	OrderModeQueued = "queued"
+)
+
+// OrdersConfig holds order processing configuration
+type OrdersConfig struct {
+	// Mode is OrderModeDirect or OrderModeQueued
+	Mode string
+	// QueueShards is the number of queues, each with one worker, that
+	// products are hashed across; QueueDepth bounds each queue's waiting
+	// orders
+	QueueShards int
+	QueueDepth  int
+	// FastPath accepts orders by decrementing stock in Redis and writes
+	// them to Postgres asynchronously; it needs REDIS_ADDR. FastPathWriters
+	// is the number of writers per replica draining accepted orders into
+	// Postgres, and FastPathResyncInterval how often the leader requeues
+	// stalled writes and resyncs cached stock.
+	FastPath               bool
+	FastPathWriters        int
+	FastPathResyncInterval time.Duration
+	// ListMaxOffset rejects order list pages deeper than it, pointing
+	// clients at cursor paging; 0 disables the limit
+	ListMaxOffset int
+	// DedupWindow returns the order already placed when a buyer without a
+	// client reference orders the same quantity of a product again within
+	// it, absorbing double-clicks; 0 disables it
+	DedupWindow time.Duration
+	// SalesEventRefreshInterval is how often each replica reloads the
+	// sales event schedule; SalesEventPrewarmLead is how long before an
+	// event starts its products' stock is loaded into the fast path
+	SalesEventRefreshInterval time.Duration
+	SalesEventPrewarmLead     time.Duration
+}
+
+// PricingConfig holds the feature flags switching order pricing hooks on
+type PricingConfig struct {
+	// Hooks lists the enabled hooks, each as "name" to apply it to every
+	// order or "name:percent" to apply it to that share of buyers only
+	Hooks []string
+	// BulkDiscountMinQuantity and BulkDiscountPercent configure the
+	// bulk_discount hook
+	BulkDiscountMinQuantity int
+	BulkDiscountPercent     int
+}
+
+// PricingFlag is a parsed entry of PricingConfig.Hooks
+type PricingFlag struct {
+	Name    string
+	Percent int
+}
+
+// Flags parses Hooks
+func (c *PricingConfig) Flags() ([]PricingFlag, error) {
+	flags := make([]PricingFlag, 0, len(c.Hooks))
+	seen := make(map[string]bool, len(c.Hooks))
+	for _, entry := range c.Hooks {
+		flag := PricingFlag{Percent: 100}
+		name, percent, split := strings.Cut(entry, ":")
+		flag.Name = strings.TrimSpace(name)
+		if split {
+			value, err := strconv.Atoi(strings.TrimSpace(percent))
+			if err != nil || value < 1 || value > 100 {
+				return nil, fmt.Errorf("invalid PRICING_HOOKS entry %q, expected name or name:percent with a percent of 1-100", entry)
+			}
+			flag.Percent = value
+		}
+		if flag.Name == "" || seen[flag.Name] {
+			return nil, fmt.Errorf("invalid PRICING_HOOKS entry %q, names must be present and unique", entry)
+		}
+		seen[flag.Name] = true
+		flags = append(flags, flag)
+	}
+	return flags, nil
+}
+
+// LoadShedConfig holds the thresholds for degrading list endpoints while
+// the database connection pool is saturated
+type LoadShedConfig struct {
+	Enabled bool
+	// PoolWait is the average connection wait over a SampleInterval above
+	// which the pool counts as saturated; it stays so for Cooldown after the
+	// last slow sample
+	PoolWait       time.Duration
+	SampleInterval time.Duration
+	Cooldown       time.Duration
+	// MaxLimit caps list page sizes, MaxOffset rejects deeper pages with
+	// 503, and CacheTTL is the age up to which a cached response is served
+	// instead, all only while saturated
+	MaxLimit  int
+	MaxOffset int
+	CacheTTL  time.Duration
 }
 
 // JobsConfig holds job processing configuration
@@ -43,22 +296,395 @@ type JobsConfig struct {
 	QueueSize     int
 	RetryAttempts int
 	RetryDelay    time.Duration
+	// MaxActivePerClient caps the queued and running settlement jobs a
+	// single client may have; 0 disables the limit
+	MaxActivePerClient int
+	// LogMaxLines caps the log lines stored per job run; 0 stores none
+	LogMaxLines int
+	// MetricsInterval is how often the job backlog gauges are read from
+	// the database; 0 disables them
+	MetricsInterval time.Duration
+	// RecoveryAge is how long a running job must have gone without an
+	// update before startup treats it as interrupted rather than still
+	// running on another replica
+	RecoveryAge time.Duration
+}
+
+// SettlementConfig holds settlement scheduling configuration
+type SettlementConfig struct {
+	// DefaultRegion is the calendar region for merchants without an
+	// assignment; empty settles every day as-is
+	DefaultRegion   string
+	ScheduleEnabled bool
+	// ScheduleAt is the UTC time of day (HH:MM) the daily run is created
+	ScheduleAt string
+	// AutoResettleEnabled periodically scans for settlements invalidated by
+	// late transactions and queues a re-settlement job for them
+	AutoResettleEnabled  bool
+	AutoResettleInterval time.Duration
+	// StaleLookbackDays is how many settlement days back a scan covers
+	StaleLookbackDays int
+	// Cutoff is how long after the start of settlement day D (UTC) its
+	// transactions may be recorded, e.g. 30h settles D at D+1 06:00.
+	// Transactions recorded later are left out and reported as late; 0
+	// includes every transaction recorded before the settlement is generated.
+	Cutoff time.Duration
+}
+
+// PayoutConfig holds the originator details written to payout bank files;
+// a scheme whose details are not set can't be exported
+type PayoutConfig struct {
+	NACHA NACHAConfig
+	SEPA  SEPAConfig
+}
+
+// NACHAConfig identifies the banks and company in ACH files; an empty
+// DestinationRouting disables them
+type NACHAConfig struct {
+	// DestinationRouting and DestinationName identify the bank the files
+	// are submitted to
+	DestinationRouting string
+	DestinationName    string
+	// OriginRouting and OriginName identify the bank originating the
+	// credits, usually the same one
+	OriginRouting string
+	OriginName    string
+	// CompanyName and CompanyID are shown on merchants' statements; the
+	// bank assigns the ID
+	CompanyName string
+	CompanyID   string
+}
+
+// Enabled reports whether ACH files can be written
+func (c *NACHAConfig) Enabled() bool {
+	return c.DestinationRouting != ""
+}
+
+// SEPAConfig identifies the account SEPA payouts are paid from; an empty
+// DebtorIBAN disables SEPA files
+type SEPAConfig struct {
+	DebtorName string
+	DebtorIBAN string
+	// DebtorBIC is optional; the bank derives it from the IBAN
+	DebtorBIC string
+}
+
+// Enabled reports whether SEPA files can be written
+func (c *SEPAConfig) Enabled() bool {
+	return c.DebtorIBAN != ""
+}
+
+// AnalyticsConfig holds order analytics projection configuration
+type AnalyticsConfig struct {
+	// ProjectionEnabled runs the projector that copies orders into the
+	// order_analytics read model
+	ProjectionEnabled  bool
+	ProjectionInterval time.Duration
+	// ProjectionOverlap is how far back each pass re-reads to catch
+	// transactions that committed after a later change was projected
+	ProjectionOverlap   time.Duration
+	ProjectionBatchSize int
+}
+
+// HealthConfig holds health check configuration. Every dependency check
+// runs under its own timeout, derived from the probe's request context, so a
+// slow dependency is reported unhealthy rather than stalling the probe.
+type HealthConfig struct {
+	// CheckTimeout bounds each check unless CheckTimeouts names it
+	CheckTimeout time.Duration
+	// CheckTimeouts overrides the timeout per check, keyed by check name
+	CheckTimeouts map[string]time.Duration
+}
+
+// Timeout returns the timeout of the named check
+func (c *HealthConfig) Timeout(check string) time.Duration {
+	if timeout, ok := c.CheckTimeouts[check]; ok {
+		return timeout
+	}
+	return c.CheckTimeout
+}
+
+// SandboxConfig holds sandbox configuration. Requests carrying one of the
+// sandbox API keys are served from copies of the tables in a separate
+// schema, so integrators can try order and settlement flows without
+// touching live data. No keys disables the sandbox.
+type SandboxConfig struct {
+	Keys []string
+	// Schema holds the sandbox tables, created from the same migrations as
+	// the live ones
+	Schema string
+	// MaxConns sizes the sandbox pool, which also runs its jobs
+	MaxConns int
+}
+
+// Enabled reports whether sandbox keys are configured
+func (c *SandboxConfig) Enabled() bool {
+	return len(c.Keys) > 0
+}
+
+// Database returns the connection configuration of the sandbox pool: the
+// live database with the sandbox schema as its only schema
+func (c *SandboxConfig) Database(live *DatabaseConfig) *DatabaseConfig {
+	cfg := *live
+	cfg.Schema = c.Schema
+	cfg.MaxConns = c.MaxConns
+	cfg.MaxIdle = 1
+	cfg.BatchMaxConns = 0
+	cfg.PreparedStatements = false
+	return &cfg
+}
+
+// WaitingRoomConfig holds the virtual waiting room configuration. Orders for
+// the listed products need a queue token, which each replica issues at
+// Rate per second per product. No products disables the waiting room.
+type WaitingRoomConfig struct {
+	Products []string
+	Rate     int
+	// TokenTTL is how long a token stays valid once it admits its buyer;
+	// MaxWait is the longest wait a token is issued for
+	TokenTTL time.Duration
+	MaxWait  time.Duration
+	// Secret signs the tokens; every replica needs the same one
+	Secret string
+}
+
+// Enabled reports whether any product is behind the waiting room
+func (c *WaitingRoomConfig) Enabled() bool {
+	return len(c.Products) > 0
+}
+
+// ProductIDs parses Products
+func (c *WaitingRoomConfig) ProductIDs() ([]int, error) {
+	ids := make([]int, 0, len(c.Products))
+	for _, entry := range c.Products {
+		id, err := strconv.Atoi(strings.TrimSpace(entry))
+		if err != nil || id < 1 {
+			return nil, fmt.Errorf("invalid WAITING_ROOM_PRODUCTS entry %q, expected a product ID", entry)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
 }
 
 // LogConfig holds logging configuration
 type LogConfig struct {
 	Level  string
 	Format string
+	// RedactFields are structured field names masked in every log line
+	RedactFields []string
+}
+
+// DebugConfig holds request/response payload logging configuration
+type DebugConfig struct {
+	// Enabled allows payload logging and its admin endpoints at all
+	Enabled bool
+	// PayloadRoutes are route patterns (e.g. /v1/orders/:id) whose payloads
+	// are logged from startup; more can be toggled at runtime
+	PayloadRoutes []string
+	RedactFields  []string
+	MaxBodyBytes  int
+}
+
+// AdminConfig holds configuration for administrative endpoints
+type AdminConfig struct {
+	// Token authorizes admin endpoints; empty disables them
+	Token string
+	// StatsCacheTTL is how long admin stats are reused; 0 disables caching
+	StatsCacheTTL time.Duration
+	// MaintenancePollInterval is how often each replica reloads maintenance
+	// mode from the database
+	MaintenancePollInterval time.Duration
+	// SeedEnabled allows POST /admin/seed to reset and populate transactions;
+	// it is off under the production profile and can't be turned on there
+	SeedEnabled bool
+	// SeedMaxCount caps the transactions one seed request may insert
+	SeedMaxCount int
+}
+
+// AuthConfig holds the brute-force protection of the admin token and API
+// keys. Failed attempts are counted per client IP and per presented
+// credential; MaxFailures within Window bans either for BanDuration.
+type AuthConfig struct {
+	// MaxFailures is the failed attempts that trigger a ban; 0 disables
+	// the protection
+	MaxFailures int
+	Window      time.Duration
+	BanDuration time.Duration
+}
+
+// Enabled reports whether failed attempts are tracked
+func (c *AuthConfig) Enabled() bool {
+	return c.MaxFailures > 0
+}
+
+// StorageConfig holds file storage configuration
+type StorageConfig struct {
+	// Driver is "local" (files under LocalDir); "s3" is refused until it is
+	// implemented
+	Driver   string
+	LocalDir string
+	S3       S3Config
+}
+
+// S3Config holds S3-compatible object storage configuration
+type S3Config struct {
+	Bucket string
+	Region string
+	// Endpoint overrides the AWS endpoint for S3-compatible stores such as
+	// MinIO, which usually also need UsePathStyle
+	Endpoint     string
+	UsePathStyle bool
+	Prefix       string
+	// AccessKeyID and SecretAccessKey are both set or both empty; empty
+	// uses the default AWS credential chain
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// BrokerConfig holds Kafka configuration; no brokers disables publishing
+type BrokerConfig struct {
+	Brokers     []string
+	ClientID    string
+	TopicPrefix string
+	// SASLMechanism is empty, PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512
+	SASLMechanism string
+	SASLUsername  string
+	SASLPassword  string
+	TLS           bool
 }
 
+// Enabled reports whether a Kafka cluster is configured
+func (c *BrokerConfig) Enabled() bool {
+	return len(c.Brokers) > 0
+}
+
+// CacheConfig holds Redis configuration; an empty Addr disables the cache
+type CacheConfig struct {
+	Addr        string
+	Password    string
+	DB          int
+	TLS         bool
+	DialTimeout time.Duration
+}
+
+// Enabled reports whether a Redis server is configured
+func (c *CacheConfig) Enabled() bool {
+	return c.Addr != ""
+}
+
+// WebhookConfig holds webhook signing configuration
+type WebhookConfig struct {
+	// SigningKeys are HMAC keys verifying payment gateway notifications; a
+	// notification signed with any of them is accepted, so a key can be
+	// rotated by adding the new one before the gateway switches
+	SigningKeys []string
+	// TimestampTolerance is how far a signed timestamp may be from now
+	TimestampTolerance time.Duration
+	// DeliveryTimeout bounds each outgoing callback, including reading the
+	// receiver's response
+	DeliveryTimeout time.Duration
+	// ClientTLS is presented to receivers requiring a client certificate
+	ClientTLS ClientTLSConfig
+}
+
+// SearchConfig holds Elasticsearch/OpenSearch configuration; an empty URL
+// disables indexing and the search endpoint
+type SearchConfig struct {
+	URL      string
+	Username string
+	Password string
+	// IndexPrefix is prepended to the products and orders index names so
+	// several environments can share a cluster
+	IndexPrefix string
+	// SyncInterval is how often changed rows are copied to the index, and
+	// SyncOverlap how far back each pass re-reads to catch transactions
+	// that committed after a later change was indexed
+	SyncInterval  time.Duration
+	SyncOverlap   time.Duration
+	SyncBatchSize int
+	Timeout       time.Duration
+}
+
+// Enabled reports whether a search cluster is configured
+func (c *SearchConfig) Enabled() bool {
+	return c.URL != ""
+}
+
+// minSigningKeyLength is the shortest accepted webhook signing key, in bytes
+const minSigningKeyLength = 32
+
+// minWaitingRoomSecretLength is the shortest accepted waiting room token
+// secret, in bytes
+const minWaitingRoomSecretLength = 32
+
+// minSandboxKeyLength is the shortest accepted sandbox API key, in bytes
+const minSandboxKeyLength = 24
+
+// schemaName matches the schema names accepted for the sandbox, which are
+// passed to Postgres unquoted
+var schemaName = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
 // Load loads configuration from environment variables with sensible defaults
+// for the APP_ENV profile
 func Load() (*Config, error) {
+	env := getEnv("APP_ENV", EnvDev)
+	switch env {
+	case EnvDev, EnvStaging, EnvProd:
+	default:
+		return nil, fmt.Errorf("invalid APP_ENV %q, must be %s, %s or %s", env, EnvDev, EnvStaging, EnvProd)
+	}
+	dev := env == EnvDev
+	prod := env == EnvProd
+
+	// Developers read logs in a terminal; deployed logs go to a collector
+	logFormat := "json"
+	if dev {
+		logFormat = "text"
+	}
+
+	// Any origin may call a dev server; deployed servers list their frontends
+	var corsOrigins []string
+	if dev {
+		corsOrigins = []string{"*"}
+	}
+
+	serverMode := ServerModeRelease
+	if dev {
+		serverMode = ServerModeDebug
+	}
+
+	// HSTS would pin HTTPS on localhost for every other dev server too
+	hstsMaxAge := 365 * 24 * time.Hour
+	if dev {
+		hstsMaxAge = 0
+	}
+
 	cfg := &Config{
+		Env: env,
 		Server: ServerConfig{
+			Mode:         getEnv("SERVER_MODE", serverMode),
 			Port:         getEnv("SERVER_PORT", "8080"),
 			ReadTimeout:  getDurationEnv("SERVER_READ_TIMEOUT", 30*time.Second),
 			WriteTimeout: getDurationEnv("SERVER_WRITE_TIMEOUT", 30*time.Second),
 			IdleTimeout:  getDurationEnv("SERVER_IDLE_TIMEOUT", 60*time.Second),
+
+			RequestTimeout:  getDurationEnv("SERVER_REQUEST_TIMEOUT", 10*time.Second),
+			DownloadTimeout: getDurationEnv("SERVER_DOWNLOAD_TIMEOUT", 5*time.Minute),
+			DegradedStart:   getBoolEnv("SERVER_DEGRADED_START", false),
+
+			CORSAllowedOrigins: getListEnv("CORS_ALLOWED_ORIGINS", corsOrigins),
+			TrustedProxies:     getListEnv("SERVER_TRUSTED_PROXIES", nil),
+
+			HSTSMaxAge:            getDurationEnv("SERVER_HSTS_MAX_AGE", hstsMaxAge),
+			HSTSIncludeSubdomains: getBoolEnv("SERVER_HSTS_INCLUDE_SUBDOMAINS", false),
+			ContentSecurityPolicy: getEnv("SERVER_CONTENT_SECURITY_POLICY", defaultContentSecurityPolicy),
+			FrameOptions:          getEnv("SERVER_FRAME_OPTIONS", FrameOptionsDeny),
+		},
+		Internal: InternalConfig{
+			Port:         getEnv("INTERNAL_PORT", ""),
+			CertFile:     getEnv("INTERNAL_TLS_CERT_FILE", ""),
+			KeyFile:      getEnv("INTERNAL_TLS_KEY_FILE", ""),
+			ClientCAFile: getEnv("INTERNAL_TLS_CLIENT_CA_FILE", ""),
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -69,6 +695,43 @@ func Load() (*Config, error) {
 			SSLMode:  getEnv("DB_SSL_MODE", "disable"),
 			MaxConns: getIntEnv("DB_MAX_CONNS", 25),
 			MaxIdle:  getIntEnv("DB_MAX_IDLE", 5),
+
+			BatchMaxConns: getIntEnv("DB_BATCH_MAX_CONNS", 5),
+			BatchMaxIdle:  getIntEnv("DB_BATCH_MAX_IDLE", 1),
+
+			PreparedStatements: getBoolEnv("DB_PREPARED_STATEMENTS", true),
+
+			ConnectWait:    getDurationEnv("DB_CONNECT_WAIT", 30*time.Second),
+			ConnectBackoff: getDurationEnv("DB_CONNECT_BACKOFF", 500*time.Millisecond),
+		},
+		Orders: OrdersConfig{
+			Mode:        getEnv("ORDER_PROCESSING_MODE", OrderModeDirect),
+			QueueShards: getIntEnv("ORDER_QUEUE_SHARDS", 16),
+			QueueDepth:  getIntEnv("ORDER_QUEUE_DEPTH", 1024),
+
+			FastPath:               getBoolEnv("ORDER_FAST_PATH_ENABLED", false),
+			FastPathWriters:        getIntEnv("ORDER_FAST_PATH_WRITERS", 1),
+			FastPathResyncInterval: getDurationEnv("ORDER_FAST_PATH_RESYNC_INTERVAL", 30*time.Second),
+
+			ListMaxOffset: getIntEnv("ORDER_LIST_MAX_OFFSET", 10000),
+			DedupWindow:   getDurationEnv("ORDER_DEDUP_WINDOW", 0),
+
+			SalesEventRefreshInterval: getDurationEnv("SALES_EVENT_REFRESH_INTERVAL", 5*time.Second),
+			SalesEventPrewarmLead:     getDurationEnv("SALES_EVENT_PREWARM_LEAD", 5*time.Minute),
+		},
+		Pricing: PricingConfig{
+			Hooks:                   getListEnv("PRICING_HOOKS", nil),
+			BulkDiscountMinQuantity: getIntEnv("PRICING_BULK_MIN_QUANTITY", 10),
+			BulkDiscountPercent:     getIntEnv("PRICING_BULK_DISCOUNT_PERCENT", 5),
+		},
+		LoadShed: LoadShedConfig{
+			Enabled:        getBoolEnv("LOAD_SHED_ENABLED", true),
+			PoolWait:       getDurationEnv("LOAD_SHED_POOL_WAIT", 50*time.Millisecond),
+			SampleInterval: getDurationEnv("LOAD_SHED_SAMPLE_INTERVAL", time.Second),
+			Cooldown:       getDurationEnv("LOAD_SHED_COOLDOWN", 30*time.Second),
+			MaxLimit:       getIntEnv("LOAD_SHED_MAX_LIMIT", 20),
+			MaxOffset:      getIntEnv("LOAD_SHED_MAX_OFFSET", 1000),
+			CacheTTL:       getDurationEnv("LOAD_SHED_CACHE_TTL", 30*time.Second),
 		},
 		Jobs: JobsConfig{
 			Workers:       getIntEnv("JOB_WORKERS", 8),
@@ -76,22 +739,683 @@ func Load() (*Config, error) {
 			QueueSize:     getIntEnv("JOB_QUEUE_SIZE", 100),
 			RetryAttempts: getIntEnv("JOB_RETRY_ATTEMPTS", 3),
 			RetryDelay:    getDurationEnv("JOB_RETRY_DELAY", 5*time.Second),
+
+			MaxActivePerClient: getIntEnv("JOB_MAX_ACTIVE_PER_CLIENT", 3),
+			LogMaxLines:        getIntEnv("JOB_LOG_MAX_LINES", 1000),
+			MetricsInterval:    getDurationEnv("JOB_METRICS_INTERVAL", 30*time.Second),
+			RecoveryAge:        getDurationEnv("JOB_RECOVERY_AGE", 5*time.Minute),
+		},
+		Settlement: SettlementConfig{
+			DefaultRegion:   getEnv("SETTLEMENT_DEFAULT_REGION", ""),
+			ScheduleEnabled: getBoolEnv("SETTLEMENT_SCHEDULE_ENABLED", false),
+			ScheduleAt:      getEnv("SETTLEMENT_SCHEDULE_AT", "02:00"),
+
+			AutoResettleEnabled:  getBoolEnv("SETTLEMENT_AUTO_RESETTLE_ENABLED", false),
+			AutoResettleInterval: getDurationEnv("SETTLEMENT_AUTO_RESETTLE_INTERVAL", 15*time.Minute),
+			StaleLookbackDays:    getIntEnv("SETTLEMENT_STALE_LOOKBACK_DAYS", 30),
+			Cutoff:               getDurationEnv("SETTLEMENT_CUTOFF", 0),
+		},
+		Payout: PayoutConfig{
+			NACHA: NACHAConfig{
+				DestinationRouting: getEnv("PAYOUT_NACHA_DESTINATION_ROUTING", ""),
+				DestinationName:    getEnv("PAYOUT_NACHA_DESTINATION_NAME", ""),
+				OriginRouting:      getEnv("PAYOUT_NACHA_ORIGIN_ROUTING", ""),
+				OriginName:         getEnv("PAYOUT_NACHA_ORIGIN_NAME", ""),
+				CompanyName:        getEnv("PAYOUT_NACHA_COMPANY_NAME", ""),
+				CompanyID:          getEnv("PAYOUT_NACHA_COMPANY_ID", ""),
+			},
+			SEPA: SEPAConfig{
+				DebtorName: getEnv("PAYOUT_SEPA_DEBTOR_NAME", ""),
+				DebtorIBAN: bankfile.NormalizeIBAN(getEnv("PAYOUT_SEPA_DEBTOR_IBAN", "")),
+				DebtorBIC:  getEnv("PAYOUT_SEPA_DEBTOR_BIC", ""),
+			},
+		},
+		Analytics: AnalyticsConfig{
+			ProjectionEnabled:   getBoolEnv("ANALYTICS_PROJECTION_ENABLED", true),
+			ProjectionInterval:  getDurationEnv("ANALYTICS_PROJECTION_INTERVAL", 30*time.Second),
+			ProjectionOverlap:   getDurationEnv("ANALYTICS_PROJECTION_OVERLAP", 30*time.Second),
+			ProjectionBatchSize: getIntEnv("ANALYTICS_PROJECTION_BATCH_SIZE", 1000),
+		},
+		Health: HealthConfig{
+			CheckTimeout: getDurationEnv("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+		},
+		Sandbox: SandboxConfig{
+			Keys:     getListEnv("SANDBOX_API_KEYS", nil),
+			Schema:   getEnv("SANDBOX_SCHEMA", "sandbox"),
+			MaxConns: getIntEnv("SANDBOX_DB_MAX_CONNS", 5),
+		},
+		WaitingRoom: WaitingRoomConfig{
+			Products: getListEnv("WAITING_ROOM_PRODUCTS", nil),
+			Rate:     getIntEnv("WAITING_ROOM_RATE", 50),
+			TokenTTL: getDurationEnv("WAITING_ROOM_TOKEN_TTL", 2*time.Minute),
+			MaxWait:  getDurationEnv("WAITING_ROOM_MAX_WAIT", 15*time.Minute),
+			Secret:   getEnv("WAITING_ROOM_SECRET", ""),
 		},
 		Log: LogConfig{
 			Level:  getEnv("LOG_LEVEL", "info"),
-			Format: getEnv("LOG_FORMAT", "json"),
+			Format: getEnv("LOG_FORMAT", logFormat),
+
+			RedactFields: getListEnv("LOG_REDACT_FIELDS", []string{"buyer_id", "email", "token", "password", "authorization", "secret"}),
+		},
+		Debug: DebugConfig{
+			Enabled:       getBoolEnv("DEBUG_ENDPOINTS_ENABLED", !prod),
+			PayloadRoutes: getListEnv("DEBUG_PAYLOAD_ROUTES", nil),
+			RedactFields:  getListEnv("DEBUG_REDACT_FIELDS", []string{"buyer_id", "password", "token", "authorization"}),
+			MaxBodyBytes:  getIntEnv("DEBUG_MAX_BODY_BYTES", 4096),
+		},
+		Admin: AdminConfig{
+			Token:         getEnv("ADMIN_TOKEN", ""),
+			StatsCacheTTL: getDurationEnv("ADMIN_STATS_CACHE_TTL", 30*time.Second),
+
+			MaintenancePollInterval: getDurationEnv("MAINTENANCE_POLL_INTERVAL", 5*time.Second),
+
+			SeedEnabled:  getBoolEnv("ADMIN_SEED_ENABLED", !prod),
+			SeedMaxCount: getIntEnv("ADMIN_SEED_MAX_COUNT", 50000),
+		},
+		Auth: AuthConfig{
+			MaxFailures: getIntEnv("AUTH_MAX_FAILURES", 10),
+			Window:      getDurationEnv("AUTH_FAILURE_WINDOW", 5*time.Minute),
+			BanDuration: getDurationEnv("AUTH_BAN_DURATION", 15*time.Minute),
+		},
+		Storage: StorageConfig{
+			Driver:   getEnv("STORAGE_DRIVER", "local"),
+			LocalDir: getEnv("STORAGE_LOCAL_DIR", "/tmp/settlements"),
+			S3: S3Config{
+				Bucket:          getEnv("STORAGE_S3_BUCKET", ""),
+				Region:          getEnv("STORAGE_S3_REGION", ""),
+				Endpoint:        getEnv("STORAGE_S3_ENDPOINT", ""),
+				UsePathStyle:    getBoolEnv("STORAGE_S3_USE_PATH_STYLE", false),
+				Prefix:          getEnv("STORAGE_S3_PREFIX", ""),
+				AccessKeyID:     getEnv("STORAGE_S3_ACCESS_KEY_ID", ""),
+				SecretAccessKey: getEnv("STORAGE_S3_SECRET_ACCESS_KEY", ""),
+			},
+		},
+		Broker: BrokerConfig{
+			Brokers:       getListEnv("KAFKA_BROKERS", nil),
+			ClientID:      getEnv("KAFKA_CLIENT_ID", "indico-backend"),
+			TopicPrefix:   getEnv("KAFKA_TOPIC_PREFIX", "indico."),
+			SASLMechanism: getEnv("KAFKA_SASL_MECHANISM", ""),
+			SASLUsername:  getEnv("KAFKA_SASL_USERNAME", ""),
+			SASLPassword:  getEnv("KAFKA_SASL_PASSWORD", ""),
+			TLS:           getBoolEnv("KAFKA_TLS", false),
+		},
+		Cache: CacheConfig{
+			Addr:        getEnv("REDIS_ADDR", ""),
+			Password:    getEnv("REDIS_PASSWORD", ""),
+			DB:          getIntEnv("REDIS_DB", 0),
+			TLS:         getBoolEnv("REDIS_TLS", false),
+			DialTimeout: getDurationEnv("REDIS_DIAL_TIMEOUT", 5*time.Second),
+		},
+		Webhook: WebhookConfig{
+			SigningKeys:        getListEnv("WEBHOOK_SIGNING_KEYS", nil),
+			TimestampTolerance: getDurationEnv("WEBHOOK_TIMESTAMP_TOLERANCE", 5*time.Minute),
+			DeliveryTimeout:    getDurationEnv("WEBHOOK_DELIVERY_TIMEOUT", 10*time.Second),
+			ClientTLS: ClientTLSConfig{
+				CertFile: getEnv("WEBHOOK_TLS_CERT_FILE", ""),
+				KeyFile:  getEnv("WEBHOOK_TLS_KEY_FILE", ""),
+				CAFile:   getEnv("WEBHOOK_TLS_CA_FILE", ""),
+			},
+		},
+		Search: SearchConfig{
+			URL:           getEnv("SEARCH_URL", ""),
+			Username:      getEnv("SEARCH_USERNAME", ""),
+			Password:      getEnv("SEARCH_PASSWORD", ""),
+			IndexPrefix:   getEnv("SEARCH_INDEX_PREFIX", "indico-"),
+			SyncInterval:  getDurationEnv("SEARCH_SYNC_INTERVAL", 10*time.Second),
+			SyncOverlap:   getDurationEnv("SEARCH_SYNC_OVERLAP", 30*time.Second),
+			SyncBatchSize: getIntEnv("SEARCH_SYNC_BATCH_SIZE", 500),
+			Timeout:       getDurationEnv("SEARCH_TIMEOUT", 10*time.Second),
 		},
 	}
 
+	if _, err := time.Parse("15:04", cfg.Settlement.ScheduleAt); err != nil {
+		return nil, fmt.Errorf("invalid SETTLEMENT_SCHEDULE_AT %q, expected HH:MM", cfg.Settlement.ScheduleAt)
+	}
+
+	if cfg.Settlement.AutoResettleEnabled && cfg.Settlement.AutoResettleInterval <= 0 {
+		return nil, fmt.Errorf("invalid SETTLEMENT_AUTO_RESETTLE_INTERVAL %s, must be positive", cfg.Settlement.AutoResettleInterval)
+	}
+
+	if cfg.Health.CheckTimeout <= 0 {
+		return nil, fmt.Errorf("invalid HEALTH_CHECK_TIMEOUT %s, must be positive", cfg.Health.CheckTimeout)
+	}
+	checkTimeouts, err := getDurationMapEnv("HEALTH_CHECK_TIMEOUTS")
+	if err != nil {
+		return nil, err
+	}
+	cfg.Health.CheckTimeouts = checkTimeouts
+
+	if cfg.Analytics.ProjectionEnabled {
+		if cfg.Analytics.ProjectionInterval <= 0 {
+			return nil, fmt.Errorf("invalid ANALYTICS_PROJECTION_INTERVAL %s, must be positive", cfg.Analytics.ProjectionInterval)
+		}
+		if cfg.Analytics.ProjectionOverlap < 0 {
+			return nil, fmt.Errorf("invalid ANALYTICS_PROJECTION_OVERLAP %s, must not be negative", cfg.Analytics.ProjectionOverlap)
+		}
+		if cfg.Analytics.ProjectionBatchSize < 1 {
+			return nil, fmt.Errorf("invalid ANALYTICS_PROJECTION_BATCH_SIZE %d, must be positive", cfg.Analytics.ProjectionBatchSize)
+		}
+	}
+
+	for _, section := range []interface{ Validate() error }{&cfg.Server, &cfg.Internal, &cfg.Orders, &cfg.Pricing, &cfg.LoadShed, &cfg.Auth, &cfg.Storage, &cfg.Broker, &cfg.Cache, &cfg.Webhook, &cfg.Search, &cfg.Sandbox, &cfg.WaitingRoom, &cfg.Payout} {
+		if err := section.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if prod {
+		if cfg.Admin.SeedEnabled {
+			return nil, fmt.Errorf("ADMIN_SEED_ENABLED cannot be set when APP_ENV=%s", EnvProd)
+		}
+		for _, origin := range cfg.Server.CORSAllowedOrigins {
+			if origin == "*" {
+				return nil, fmt.Errorf("CORS_ALLOWED_ORIGINS cannot allow any origin when APP_ENV=%s; list the frontend origins", EnvProd)
+			}
+		}
+	}
+
+	if cfg.Internal.Enabled() && cfg.Internal.Port == cfg.Server.Port {
+		return nil, fmt.Errorf("INTERNAL_PORT %s must differ from SERVER_PORT", cfg.Internal.Port)
+	}
+
+	if cfg.Orders.FastPath && !cfg.Cache.Enabled() {
+		return nil, fmt.Errorf("REDIS_ADDR is required when ORDER_FAST_PATH_ENABLED=true")
+	}
+
 	return cfg, nil
 }
 
+// Validate checks the server mode, trusted proxies and security headers
+func (c *ServerConfig) Validate() error {
+	switch c.Mode {
+	case ServerModeDebug, ServerModeRelease, ServerModeTest:
+	default:
+		return fmt.Errorf("invalid SERVER_MODE %q, must be %s, %s or %s", c.Mode, ServerModeDebug, ServerModeRelease, ServerModeTest)
+	}
+
+	for _, proxy := range c.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				return fmt.Errorf("invalid SERVER_TRUSTED_PROXIES entry %q, expected an IP or CIDR", proxy)
+			}
+		}
+	}
+
+	if c.HSTSMaxAge < 0 {
+		return fmt.Errorf("invalid SERVER_HSTS_MAX_AGE %s, must not be negative", c.HSTSMaxAge)
+	}
+	if strings.ContainsAny(c.ContentSecurityPolicy, "\r\n") {
+		return fmt.Errorf("invalid SERVER_CONTENT_SECURITY_POLICY, must be a single line")
+	}
+	switch c.FrameOptions {
+	case "", FrameOptionsDeny, FrameOptionsSameOrigin:
+	default:
+		return fmt.Errorf("invalid SERVER_FRAME_OPTIONS %q, expected %s, %s or empty", c.FrameOptions, FrameOptionsDeny, FrameOptionsSameOrigin)
+	}
+	return nil
+}
+
+// Validate checks the internal listener's settings and loads its
+// certificates, keeping them for TLSConfig
+func (c *InternalConfig) Validate() error {
+	if !c.Enabled() {
+		if c.CertFile != "" || c.KeyFile != "" || c.ClientCAFile != "" {
+			return fmt.Errorf("INTERNAL_PORT is required when INTERNAL_TLS_* files are set")
+		}
+		return nil
+	}
+
+	if _, err := strconv.Atoi(c.Port); err != nil {
+		return fmt.Errorf("invalid INTERNAL_PORT %q, expected a port number", c.Port)
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("INTERNAL_TLS_CERT_FILE and INTERNAL_TLS_KEY_FILE must be set together")
+	}
+	if c.CertFile == "" {
+		if c.ClientCAFile != "" {
+			return fmt.Errorf("INTERNAL_TLS_CERT_FILE and INTERNAL_TLS_KEY_FILE are required when INTERNAL_TLS_CLIENT_CA_FILE is set")
+		}
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return fmt.Errorf("invalid INTERNAL_TLS_CERT_FILE or INTERNAL_TLS_KEY_FILE: %w", err)
+	}
+	c.tls = &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if c.ClientCAFile != "" {
+		pool, err := loadCertPool(c.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("invalid INTERNAL_TLS_CLIENT_CA_FILE: %w", err)
+		}
+		c.tls.ClientCAs = pool
+		c.tls.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return nil
+}
+
+// validate checks a client certificate's settings and loads its files,
+// keeping them for TLSConfig; prefix names its environment variables
+func (c *ClientTLSConfig) validate(prefix string) error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("%s_CERT_FILE and %s_KEY_FILE must be set together", prefix, prefix)
+	}
+	if c.CertFile == "" && c.CAFile == "" {
+		return nil
+	}
+
+	c.tls = &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return fmt.Errorf("invalid %s_CERT_FILE or %s_KEY_FILE: %w", prefix, prefix, err)
+		}
+		c.tls.Certificates = []tls.Certificate{cert}
+	}
+	if c.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if err := appendCerts(pool, c.CAFile); err != nil {
+			return fmt.Errorf("invalid %s_CA_FILE: %w", prefix, err)
+		}
+		c.tls.RootCAs = pool
+	}
+	return nil
+}
+
+// loadCertPool reads a pool of the PEM certificates in file
+func loadCertPool(file string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if err := appendCerts(pool, file); err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+// appendCerts adds the PEM certificates in file to pool
+func appendCerts(pool *x509.CertPool, file string) error {
+	pem, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("no PEM certificates in %s", file)
+	}
+	return nil
+}
+
+// Validate checks the order processing mode, the sizes of the queues and
+// fast path it enables, and the list offset limit
+func (c *OrdersConfig) Validate() error {
+	switch c.Mode {
+	case OrderModeDirect:
+	case OrderModeQueued:
+		if c.QueueShards < 1 {
+			return fmt.Errorf("invalid ORDER_QUEUE_SHARDS %d, must be positive", c.QueueShards)
+		}
+		if c.QueueDepth < 1 {
+			return fmt.Errorf("invalid ORDER_QUEUE_DEPTH %d, must be positive", c.QueueDepth)
+		}
+	default:
+		return fmt.Errorf("invalid ORDER_PROCESSING_MODE %q, expected direct or queued", c.Mode)
+	}
+
+	if c.FastPath {
+		if c.FastPathWriters < 1 {
+			return fmt.Errorf("invalid ORDER_FAST_PATH_WRITERS %d, must be positive", c.FastPathWriters)
+		}
+		if c.FastPathResyncInterval <= 0 {
+			return fmt.Errorf("invalid ORDER_FAST_PATH_RESYNC_INTERVAL %s, must be positive", c.FastPathResyncInterval)
+		}
+	}
+
+	if c.ListMaxOffset < 0 {
+		return fmt.Errorf("invalid ORDER_LIST_MAX_OFFSET %d, must not be negative", c.ListMaxOffset)
+	}
+	if c.DedupWindow < 0 {
+		return fmt.Errorf("invalid ORDER_DEDUP_WINDOW %s, must not be negative", c.DedupWindow)
+	}
+	if c.SalesEventRefreshInterval <= 0 {
+		return fmt.Errorf("invalid SALES_EVENT_REFRESH_INTERVAL %s, must be positive", c.SalesEventRefreshInterval)
+	}
+	if c.SalesEventPrewarmLead < 0 {
+		return fmt.Errorf("invalid SALES_EVENT_PREWARM_LEAD %s, must not be negative", c.SalesEventPrewarmLead)
+	}
+	return nil
+}
+
+// Validate checks the pricing flags and the bulk discount settings
+func (c *PricingConfig) Validate() error {
+	if _, err := c.Flags(); err != nil {
+		return err
+	}
+	if c.BulkDiscountMinQuantity < 1 {
+		return fmt.Errorf("invalid PRICING_BULK_MIN_QUANTITY %d, must be positive", c.BulkDiscountMinQuantity)
+	}
+	if c.BulkDiscountPercent < 0 || c.BulkDiscountPercent > 100 {
+		return fmt.Errorf("invalid PRICING_BULK_DISCOUNT_PERCENT %d, expected 0-100", c.BulkDiscountPercent)
+	}
+	return nil
+}
+
+// Validate checks the load shedding thresholds when shedding is enabled
+func (c *LoadShedConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.PoolWait <= 0 {
+		return fmt.Errorf("invalid LOAD_SHED_POOL_WAIT %s, must be positive", c.PoolWait)
+	}
+	if c.SampleInterval <= 0 {
+		return fmt.Errorf("invalid LOAD_SHED_SAMPLE_INTERVAL %s, must be positive", c.SampleInterval)
+	}
+	if c.Cooldown < 0 {
+		return fmt.Errorf("invalid LOAD_SHED_COOLDOWN %s, must not be negative", c.Cooldown)
+	}
+	if c.MaxLimit < 1 {
+		return fmt.Errorf("invalid LOAD_SHED_MAX_LIMIT %d, must be positive", c.MaxLimit)
+	}
+	if c.MaxOffset < 0 {
+		return fmt.Errorf("invalid LOAD_SHED_MAX_OFFSET %d, must not be negative", c.MaxOffset)
+	}
+	if c.CacheTTL < 0 {
+		return fmt.Errorf("invalid LOAD_SHED_CACHE_TTL %s, must not be negative", c.CacheTTL)
+	}
+	return nil
+}
+
+// Validate checks that the selected storage driver is supported and fully
+// configured
+func (c *StorageConfig) Validate() error {
+	switch c.Driver {
+	case "local":
+		if c.LocalDir == "" {
+			return fmt.Errorf("STORAGE_LOCAL_DIR is required when STORAGE_DRIVER=local")
+		}
+	case "s3":
+		// The S3 settings are loaded, but nothing stores job files in S3 yet
+		return fmt.Errorf("STORAGE_DRIVER=s3 is not supported yet, use local")
+	default:
+		return fmt.Errorf("invalid STORAGE_DRIVER %q, expected local or s3", c.Driver)
+	}
+	return nil
+}
+
+// Validate checks broker addresses and SASL settings when Kafka is enabled
+func (c *BrokerConfig) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	for _, broker := range c.Brokers {
+		if err := validateHostPort(broker); err != nil {
+			return fmt.Errorf("invalid KAFKA_BROKERS entry %q: %w", broker, err)
+		}
+	}
+
+	switch c.SASLMechanism {
+	case "":
+	case "PLAIN", "SCRAM-SHA-256", "SCRAM-SHA-512":
+		var missing []string
+		if c.SASLUsername == "" {
+			missing = append(missing, "KAFKA_SASL_USERNAME")
+		}
+		if c.SASLPassword == "" {
+			missing = append(missing, "KAFKA_SASL_PASSWORD")
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("%s required when KAFKA_SASL_MECHANISM=%s", requiredList(missing), c.SASLMechanism)
+		}
+	default:
+		return fmt.Errorf("invalid KAFKA_SASL_MECHANISM %q, expected PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512", c.SASLMechanism)
+	}
+	return nil
+}
+
+// Validate checks the Redis address and database when the cache is enabled
+func (c *CacheConfig) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	if err := validateHostPort(c.Addr); err != nil {
+		return fmt.Errorf("invalid REDIS_ADDR %q: %w", c.Addr, err)
+	}
+	if c.DB < 0 {
+		return fmt.Errorf("invalid REDIS_DB %d, must not be negative", c.DB)
+	}
+	if c.DialTimeout <= 0 {
+		return fmt.Errorf("invalid REDIS_DIAL_TIMEOUT %s, must be positive", c.DialTimeout)
+	}
+	return nil
+}
+
+// Validate checks that signing keys are long enough to be secure, and loads
+// the client certificate presented on callbacks
+func (c *WebhookConfig) Validate() error {
+	for i, key := range c.SigningKeys {
+		if len(key) < minSigningKeyLength {
+			return fmt.Errorf("WEBHOOK_SIGNING_KEYS entry %d is %d bytes, must be at least %d", i+1, len(key), minSigningKeyLength)
+		}
+	}
+	if c.TimestampTolerance <= 0 {
+		return fmt.Errorf("invalid WEBHOOK_TIMESTAMP_TOLERANCE %s, must be positive", c.TimestampTolerance)
+	}
+	if c.DeliveryTimeout <= 0 {
+		return fmt.Errorf("invalid WEBHOOK_DELIVERY_TIMEOUT %s, must be positive", c.DeliveryTimeout)
+	}
+	return c.ClientTLS.validate("WEBHOOK_TLS")
+}
+
+// Validate checks the cluster URL and sync settings when search is enabled
+func (c *SearchConfig) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	parsed, err := url.Parse(c.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("invalid SEARCH_URL %q, expected an http or https URL", c.URL)
+	}
+	if (c.Username == "") != (c.Password == "") {
+		return fmt.Errorf("SEARCH_USERNAME and SEARCH_PASSWORD must be set together")
+	}
+	if c.SyncInterval <= 0 {
+		return fmt.Errorf("invalid SEARCH_SYNC_INTERVAL %s, must be positive", c.SyncInterval)
+	}
+	if c.SyncOverlap < 0 {
+		return fmt.Errorf("invalid SEARCH_SYNC_OVERLAP %s, must not be negative", c.SyncOverlap)
+	}
+	if c.SyncBatchSize < 1 || c.SyncBatchSize > 10000 {
+		return fmt.Errorf("invalid SEARCH_SYNC_BATCH_SIZE %d, expected 1-10000", c.SyncBatchSize)
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("invalid SEARCH_TIMEOUT %s, must be positive", c.Timeout)
+	}
+	return nil
+}
+
+// Validate checks the keys and schema when the sandbox is enabled
+func (c *SandboxConfig) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	for i, key := range c.Keys {
+		if len(key) < minSandboxKeyLength {
+			return fmt.Errorf("SANDBOX_API_KEYS entry %d is %d bytes, must be at least %d", i+1, len(key), minSandboxKeyLength)
+		}
+	}
+	if !schemaName.MatchString(c.Schema) || c.Schema == "public" {
+		return fmt.Errorf("invalid SANDBOX_SCHEMA %q, expected a lowercase schema name other than public", c.Schema)
+	}
+	if c.MaxConns <= 0 {
+		return fmt.Errorf("invalid SANDBOX_DB_MAX_CONNS %d, must be positive", c.MaxConns)
+	}
+	return nil
+}
+
+// Validate checks the brute-force protection settings when it is enabled
+func (c *AuthConfig) Validate() error {
+	if c.MaxFailures < 0 {
+		return fmt.Errorf("invalid AUTH_MAX_FAILURES %d, must not be negative", c.MaxFailures)
+	}
+	if !c.Enabled() {
+		return nil
+	}
+
+	if c.Window <= 0 {
+		return fmt.Errorf("invalid AUTH_FAILURE_WINDOW %s, must be positive", c.Window)
+	}
+	if c.BanDuration <= 0 {
+		return fmt.Errorf("invalid AUTH_BAN_DURATION %s, must be positive", c.BanDuration)
+	}
+	return nil
+}
+
+// Validate checks the waiting room settings when it is enabled
+func (c *WaitingRoomConfig) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	if _, err := c.ProductIDs(); err != nil {
+		return err
+	}
+	if c.Rate < 1 {
+		return fmt.Errorf("invalid WAITING_ROOM_RATE %d, must be positive", c.Rate)
+	}
+	if c.TokenTTL <= 0 {
+		return fmt.Errorf("invalid WAITING_ROOM_TOKEN_TTL %s, must be positive", c.TokenTTL)
+	}
+	if c.MaxWait < 0 {
+		return fmt.Errorf("invalid WAITING_ROOM_MAX_WAIT %s, must not be negative", c.MaxWait)
+	}
+	if len(c.Secret) < minWaitingRoomSecretLength {
+		return fmt.Errorf("WAITING_ROOM_SECRET is %d bytes, must be at least %d when WAITING_ROOM_PRODUCTS is set", len(c.Secret), minWaitingRoomSecretLength)
+	}
+	return nil
+}
+
+// Validate checks the bank details of each scheme that is enabled
+func (c *PayoutConfig) Validate() error {
+	if n := &c.NACHA; n.Enabled() {
+		if err := bankfile.ValidateRoutingNumber(n.DestinationRouting); err != nil {
+			return fmt.Errorf("invalid PAYOUT_NACHA_DESTINATION_ROUTING: %w", err)
+		}
+		if err := bankfile.ValidateRoutingNumber(n.OriginRouting); err != nil {
+			return fmt.Errorf("invalid PAYOUT_NACHA_ORIGIN_ROUTING: %w", err)
+		}
+		var missing []string
+		if strings.TrimSpace(n.DestinationName) == "" {
+			missing = append(missing, "PAYOUT_NACHA_DESTINATION_NAME")
+		}
+		if strings.TrimSpace(n.OriginName) == "" {
+			missing = append(missing, "PAYOUT_NACHA_ORIGIN_NAME")
+		}
+		if strings.TrimSpace(n.CompanyName) == "" {
+			missing = append(missing, "PAYOUT_NACHA_COMPANY_NAME")
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("%s required when PAYOUT_NACHA_DESTINATION_ROUTING is set", requiredList(missing))
+		}
+		if len(n.CompanyID) < 1 || len(n.CompanyID) > 10 {
+			return fmt.Errorf("invalid PAYOUT_NACHA_COMPANY_ID %q, expected 1-10 characters", n.CompanyID)
+		}
+	}
+
+	if s := &c.SEPA; s.Enabled() {
+		if err := bankfile.ValidateIBAN(s.DebtorIBAN); err != nil {
+			return fmt.Errorf("invalid PAYOUT_SEPA_DEBTOR_IBAN: %w", err)
+		}
+		if strings.TrimSpace(s.DebtorName) == "" {
+			return fmt.Errorf("PAYOUT_SEPA_DEBTOR_NAME is required when PAYOUT_SEPA_DEBTOR_IBAN is set")
+		}
+		if s.DebtorBIC != "" {
+			if err := bankfile.ValidateBIC(s.DebtorBIC); err != nil {
+				return fmt.Errorf("invalid PAYOUT_SEPA_DEBTOR_BIC: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateHostPort checks that addr has the host:port form
+func validateHostPort(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("expected host:port")
+	}
+	if host == "" {
+		return fmt.Errorf("missing host")
+	}
+	if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	return nil
+}
+
+// requiredList phrases missing environment variables for an error message
+func requiredList(names []string) string {
+	if len(names) == 1 {
+		return names[0] + " is"
+	}
+	return strings.Join(names, " and ") + " are"
+}
+
 // ConnectionString returns the PostgreSQL connection string
 func (c *DatabaseConfig) ConnectionString() string {
 	return fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		c.Host, c.Port, c.User, c.Password, c.DBName, c.SSLMode,
-	)
+	) + c.searchPath()
+}
+
+// searchPath returns the connection parameter pinning the search path to
+// Schema, if one is set
+func (c *DatabaseConfig) searchPath() string {
+	if c.Schema == "" {
+		return ""
+	}
+	return " search_path=" + c.Schema
+}
+
+// getListEnv gets a comma-separated environment variable or returns a default value
+func getListEnv(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getDurationMapEnv parses a comma-separated list of name=duration pairs,
+// such as "database=1s,search=500ms"
+func getDurationMapEnv(key string) (map[string]time.Duration, error) {
+	values := make(map[string]time.Duration)
+	for _, item := range getListEnv(key, nil) {
+		name, value, ok := strings.Cut(item, "=")
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if !ok || strings.TrimSpace(name) == "" || err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid %s entry %q, expected name=duration with a positive duration", key, item)
+		}
+		values[strings.TrimSpace(name)] = d
+	}
+	return values, nil
 }
 
 // getEnv gets an environment variable or returns a default value
@@ -112,6 +1436,16 @@ func getIntEnv(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getBoolEnv gets a boolean environment variable or returns a default value
+func getBoolEnv(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
 // getDurationEnv gets a duration environment variable or returns a default value
 func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
@@ -0,0 +1,70 @@
+// Package csvsafe writes CSV that spreadsheets open as data, never as
+// formulas
+package csvsafe
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// Escape neutralizes a cell a spreadsheet would evaluate: one starting with
+// =, +, -, @, a tab or a carriage return gets a leading single quote, which
+// Excel and LibreOffice show as text and hide. Numbers such as -1250 are
+// left alone so amounts stay numeric.
+func Escape(cell string) string {
+	if cell == "" {
+		return cell
+	}
+	switch cell[0] {
+	case '+', '-':
+		if _, err := strconv.ParseFloat(cell, 64); err == nil {
+			return cell
+		}
+	case '=', '@', '\t', '\r':
+	default:
+		return cell
+	}
+	return "'" + cell
+}
+
+// Unescape returns the cell Escape was given, for reading back a CSV written
+// with it. A leading quote Escape would not have added is kept. Escape writes
+// "'=1" for both "=1" and "'=1", and Unescape reads it as "=1".
+func Unescape(cell string) string {
+	if len(cell) > 1 && cell[0] == '\'' && Escape(cell[1:]) == cell {
+		return cell[1:]
+	}
+	return cell
+}
+
+// Writer is a csv.Writer that escapes every cell it writes
+type Writer struct {
+	*csv.Writer
+	record []string
+}
+
+// NewWriter returns a Writer that writes to w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{Writer: csv.NewWriter(w)}
+}
+
+// Write writes a single record with its cells escaped
+func (w *Writer) Write(record []string) error {
+	w.record = w.record[:0]
+	for _, cell := range record {
+		w.record = append(w.record, Escape(cell))
+	}
+	return w.Writer.Write(w.record)
+}
+
+// WriteAll writes records with their cells escaped and flushes
+func (w *Writer) WriteAll(records [][]string) error {
+	for _, record := range records {
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,49 @@
+package csvsafe
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscape(t *testing.T) {
+	tests := map[string]string{
+		"":                         "",
+		"merchant_1":               "merchant_1",
+		"2026-11-11":               "2026-11-11",
+		"1250":                     "1250",
+		"-1250":                    "-1250",
+		"+62.5":                    "+62.5",
+		"=HYPERLINK(\"http://x\")": "'=HYPERLINK(\"http://x\")",
+		"+cmd|' /C calc'!A0":       "'+cmd|' /C calc'!A0",
+		"-2+3+cmd|' /C calc'!A0":   "'-2+3+cmd|' /C calc'!A0",
+		"@SUM(A1:A9)":              "'@SUM(A1:A9)",
+		"\t=1+1":                   "'\t=1+1",
+		"\r=1+1":                   "'\r=1+1",
+		"a=1":                      "a=1",
+	}
+	for cell, want := range tests {
+		assert.Equal(t, want, Escape(cell), "%q", cell)
+	}
+}
+
+func TestUnescape(t *testing.T) {
+	for _, cell := range []string{"", "'", "merchant_1", "-1250", "=1+1", "+cmd", "-id", "@SUM(A1:A9)", "\t=1+1", "'quoted", "''"} {
+		assert.Equal(t, cell, Unescape(Escape(cell)), "%q", cell)
+	}
+
+	// A quote Escape would not have added is data
+	assert.Equal(t, "'quoted", Unescape("'quoted"))
+	assert.Equal(t, "'1250", Unescape("'1250"))
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	require.NoError(t, w.Write([]string{"merchant_id", "net_cents"}))
+	require.NoError(t, w.WriteAll([][]string{{"=1+1", "-500"}, {"merchant_2", "700"}}))
+
+	assert.Equal(t, "merchant_id,net_cents\n'=1+1,-500\nmerchant_2,700\n", buf.String())
+}
@@ -0,0 +1,59 @@
+// Package database provides request coalescing for identical reads
+package database
+
+import (
+	"context"
+
+	"indico-backend/internal/metrics"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Coalescer collapses concurrent identical reads into one query. Callers
+// asking for the same key while a query for it is in flight wait for that
+// query and share its result instead of issuing their own.
+type Coalescer struct {
+	name  string
+	group singleflight.Group
+}
+
+// NewCoalescer creates a coalescer; name labels its metrics
+func NewCoalescer(name string) *Coalescer {
+	return &Coalescer{name: name}
+}
+
+// Coalesce runs fn for key unless a call for the same key is already in
+// flight, in which case it waits for and returns that call's result. The
+// shared call is detached from the caller's cancellation, so one caller
+// giving up does not fail the rest, but keeps its deadline. Each caller
+// still returns as soon as its own context is done.
+func Coalesce[T any](ctx context.Context, c *Coalescer, key string, fn func(context.Context) (T, error)) (T, error) {
+	var zero T
+
+	// Set only by the caller whose fn runs; read after its result arrives
+	ran := false
+	ch := c.group.DoChan(key, func() (interface{}, error) {
+		ran = true
+
+		callCtx := context.WithoutCancel(ctx)
+		if deadline, ok := ctx.Deadline(); ok {
+			var cancel context.CancelFunc
+			callCtx, cancel = context.WithDeadline(callCtx, deadline)
+			defer cancel()
+		}
+		return fn(callCtx)
+	})
+
+	select {
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	case res := <-ch:
+		if !ran {
+			metrics.CoalescedReads.WithLabelValues(c.name).Inc()
+		}
+		if res.Err != nil {
+			return zero, res.Err
+		}
+		return res.Val.(T), nil
+	}
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoalesce(t *testing.T) {
+	c := NewCoalescer("test")
+	release := make(chan struct{})
+	var calls atomic.Int32
+
+	fn := func(ctx context.Context) (int, error) {
+		calls.Add(1)
+		<-release
+		return 42, nil
+	}
+
+	// The leader's cancellation must not fail the callers sharing its query
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leaderDone := make(chan error, 1)
+	go func() {
+		_, err := Coalesce(leaderCtx, c, "key", fn)
+		leaderDone <- err
+	}()
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	const waiters = 50
+	results := make([]int, waiters)
+	var wg sync.WaitGroup
+	for i := 0; i < waiters; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			v, err := Coalesce(context.Background(), c, "key", fn)
+			assert.NoError(t, err)
+			results[i] = v
+		}(i)
+	}
+
+	// Give the waiters time to join the in-flight call
+	time.Sleep(50 * time.Millisecond)
+
+	cancelLeader()
+	assert.ErrorIs(t, <-leaderDone, context.Canceled)
+
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+	for _, v := range results {
+		assert.Equal(t, 42, v)
+	}
+}
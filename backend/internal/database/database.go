@@ -4,31 +4,120 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"indico-backend/internal/config"
 	"indico-backend/internal/logger"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+)
+
+// pgSerializationFailure is the SQLSTATE of a transaction that lost a
+// conflict under REPEATABLE READ or SERIALIZABLE isolation
+const pgSerializationFailure = "40001"
+
+// Isolation options for WithTx. Nil options run at the server default, READ
+// COMMITTED, which suits short writes like order creation; jobs that read
+// and write a consistent view of many rows opt into a stricter level.
+var (
+	RepeatableRead = &sql.TxOptions{Isolation: sql.LevelRepeatableRead}
+	Serializable   = &sql.TxOptions{Isolation: sql.LevelSerializable}
 )
 
 // DB wraps sql.DB with additional functionality
 type DB struct {
 	*sql.DB
 	config *config.DatabaseConfig
+
+	// Batch is the pool background jobs use. It is a separate pool when
+	// BatchMaxConns is set and the DB itself otherwise.
+	Batch *DB
+
+	// health coalesces concurrent health checks into one ping
+	health *Coalescer
 }
 
-// New creates a new database connection
+// maxConnectBackoff caps the delay between startup connection attempts
+const maxConnectBackoff = 10 * time.Second
+
+// Connect creates a connection like New, retrying with exponential backoff
+// for up to cfg.ConnectWait so the server can start before the database
+// accepts connections; a negative wait retries until ctx is done. It returns
+// the last connection error once it gives up.
+func Connect(ctx context.Context, cfg *config.DatabaseConfig) (*DB, error) {
+	deadline := time.Now().Add(cfg.ConnectWait)
+	backoff := cfg.ConnectBackoff
+	if backoff <= 0 {
+		backoff = 500 * time.Millisecond
+	}
+
+	for attempt := 1; ; attempt++ {
+		db, err := New(cfg)
+		if err == nil {
+			return db, nil
+		}
+
+		wait := backoff
+		if cfg.ConnectWait >= 0 {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				return nil, err
+			}
+			wait = min(wait, remaining)
+		}
+
+		logger.WithComponent("database").
+			WithError(err).
+			WithField("attempt", attempt).
+			WithField("retry_in", wait.String()).
+			Warn("Database unavailable, retrying")
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(wait):
+		}
+		backoff = min(backoff*2, maxConnectBackoff)
+	}
+}
+
+// New creates a new database connection, with a separate batch pool when
+// one is configured
 func New(cfg *config.DatabaseConfig) (*DB, error) {
+	db, err := open(cfg, cfg.MaxConns, cfg.MaxIdle)
+	if err != nil {
+		return nil, err
+	}
+	db.Batch = db
+
+	if cfg.BatchMaxConns > 0 {
+		batch, err := open(cfg, cfg.BatchMaxConns, cfg.BatchMaxIdle)
+		if err != nil {
+			db.DB.Close()
+			return nil, fmt.Errorf("batch pool: %w", err)
+		}
+		batch.Batch = batch
+		db.Batch = batch
+	}
+
+	logger.Infof("Database connection established (max_conns=%d, batch_max_conns=%d)", cfg.MaxConns, cfg.BatchMaxConns)
+
+	return db, nil
+}
+
+// open opens and verifies a connection pool with the given limits
+func open(cfg *config.DatabaseConfig, maxConns, maxIdle int) (*DB, error) {
 	db, err := sql.Open("postgres", cfg.ConnectionString())
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
 	// Configure connection pool
-	db.SetMaxOpenConns(cfg.MaxConns)
-	db.SetMaxIdleConns(cfg.MaxIdle)
+	db.SetMaxOpenConns(maxConns)
+	db.SetMaxIdleConns(maxIdle)
 	db.SetConnMaxLifetime(time.Hour)
 
 	// Test connection
@@ -36,34 +125,56 @@ func New(cfg *config.DatabaseConfig) (*DB, error) {
 	defer cancel()
 
 	if err := db.PingContext(ctx); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	logger.Info("Database connection established")
-
 	return &DB{
 		DB:     db,
 		config: cfg,
+		health: NewCoalescer("health_ping"),
 	}, nil
 }
 
-// Close closes the database connection
+// Close closes the database connection and the batch pool
 func (db *DB) Close() error {
 	logger.Info("Closing database connection")
+	if db.Batch != nil && db.Batch != db {
+		if err := db.Batch.DB.Close(); err != nil {
+			logger.WithError(err).Error("Failed to close batch pool")
+		}
+	}
 	return db.DB.Close()
 }
 
-// Health checks database connectivity
+// Health checks database connectivity of both pools. Concurrent checks
+// share one ping, so a burst of probes costs the database a single round trip.
 func (db *DB) Health(ctx context.Context) error {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
+	// Callers set the deadline; bound pings that come without one
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+	}
 
-	return db.PingContext(ctx)
+	_, err := Coalesce(ctx, db.health, "ping", func(ctx context.Context) (struct{}, error) {
+		if err := db.PingContext(ctx); err != nil {
+			return struct{}{}, err
+		}
+		if db.Batch != nil && db.Batch != db {
+			if err := db.Batch.PingContext(ctx); err != nil {
+				return struct{}{}, fmt.Errorf("batch pool: %w", err)
+			}
+		}
+		return struct{}{}, nil
+	})
+	return err
 }
 
-// WithTx executes a function within a database transaction
-func (db *DB) WithTx(ctx context.Context, fn func(*sql.Tx) error) error {
-	tx, err := db.BeginTx(ctx, nil)
+// WithTx executes a function within a database transaction begun with the
+// given options; nil options use the server's default isolation level
+func (db *DB) WithTx(ctx context.Context, opts *sql.TxOptions, fn func(*sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, opts)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
@@ -88,3 +199,45 @@ func (db *DB) WithTx(ctx context.Context, fn func(*sql.Tx) error) error {
 
 	return nil
 }
+
+// IsSerializationFailure reports whether err is a serialization failure. The
+// whole transaction must be retried; retrying a savepoint inside it fails
+// the same way.
+func IsSerializationFailure(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == pgSerializationFailure
+}
+
+// ErrTxAborted reports a savepoint that could not be rolled back; the
+// enclosing transaction can no longer be used
+var ErrTxAborted = errors.New("transaction aborted")
+
+// savepointSeq numbers savepoints so nested ones get distinct names
+var savepointSeq atomic.Uint64
+
+// WithSavepoint executes a function within a savepoint of tx, so a failing
+// step can be undone without aborting the whole transaction. If fn fails its
+// changes are rolled back and its error returned, leaving tx usable; if the
+// rollback itself fails, the error wraps ErrTxAborted. Savepoints nest.
+func WithSavepoint(ctx context.Context, tx *sql.Tx, fn func(*sql.Tx) error) error {
+	name := fmt.Sprintf("sp_%d", savepointSeq.Add(1))
+
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("%w: failed to create savepoint: %v", ErrTxAborted, err)
+	}
+
+	if err := fn(tx); err != nil {
+		if _, rbErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rbErr != nil {
+			return fmt.Errorf("%w: failed to roll back to savepoint: %v (after %v)", ErrTxAborted, rbErr, err)
+		}
+		if _, relErr := tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name); relErr != nil {
+			return fmt.Errorf("%w: failed to release savepoint: %v", ErrTxAborted, relErr)
+		}
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("%w: failed to release savepoint: %v", ErrTxAborted, err)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+// Package database provides helpers for expand/contract schema migrations
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// ColumnRename renames a column without downtime using expand/contract:
+//
+//  1. Expand: add the new column and a trigger that copies a write to either
+//     column into the other, so old and new code can run side by side
+//  2. Backfill the rows written before the trigger (a BACKFILL job)
+//  3. Deploy code that reads and writes only the new column
+//  4. Contract: drop the trigger and the old column
+//
+// Each phase is shipped as its own migration; the SQL comes from ExpandSQL,
+// ContractSQL and their Revert counterparts.
+type ColumnRename struct {
+	Table string
+	From  string
+	To    string
+	// Type is the SQL type of the new column, e.g. VARCHAR(255)
+	Type string
+	// NotNull makes the new column NOT NULL on contract, once every row has
+	// been backfilled
+	NotNull bool
+}
+
+// syncName is the name shared by the sync trigger and its function
+func (r *ColumnRename) syncName() string {
+	return fmt.Sprintf("%s_%s_to_%s_sync", r.Table, r.From, r.To)
+}
+
+// ExpandSQL adds the new column and the trigger that keeps both columns in
+// step while old and new code are deployed together
+func (r *ColumnRename) ExpandSQL() string {
+	table, from, to := pq.QuoteIdentifier(r.Table), pq.QuoteIdentifier(r.From), pq.QuoteIdentifier(r.To)
+	sync := pq.QuoteIdentifier(r.syncName())
+
+	return fmt.Sprintf(`-- Expand: add %[6]s.%[7]s alongside %[8]s and keep them in step
+ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS %[3]s %[5]s;
+
+CREATE OR REPLACE FUNCTION %[4]s() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        NEW.%[3]s := COALESCE(NEW.%[3]s, NEW.%[2]s);
+        NEW.%[2]s := COALESCE(NEW.%[2]s, NEW.%[3]s);
+    ELSIF NEW.%[2]s IS DISTINCT FROM OLD.%[2]s THEN
+        NEW.%[3]s := NEW.%[2]s;
+    ELSIF NEW.%[3]s IS DISTINCT FROM OLD.%[3]s THEN
+        NEW.%[2]s := NEW.%[3]s;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS %[4]s ON %[1]s;
+CREATE TRIGGER %[4]s BEFORE INSERT OR UPDATE ON %[1]s
+    FOR EACH ROW EXECUTE FUNCTION %[4]s();
+`, table, from, to, sync, r.Type, r.Table, r.To, r.From)
+}
+
+// RevertExpandSQL undoes ExpandSQL
+func (r *ColumnRename) RevertExpandSQL() string {
+	table, to := pq.QuoteIdentifier(r.Table), pq.QuoteIdentifier(r.To)
+	sync := pq.QuoteIdentifier(r.syncName())
+
+	return fmt.Sprintf(`DROP TRIGGER IF EXISTS %[3]s ON %[1]s;
+DROP FUNCTION IF EXISTS %[3]s();
+ALTER TABLE %[1]s DROP COLUMN IF EXISTS %[2]s;
+`, table, to, sync)
+}
+
+// ContractSQL drops the trigger and the old column once no deployed code
+// uses it
+func (r *ColumnRename) ContractSQL() string {
+	table, from, to := pq.QuoteIdentifier(r.Table), pq.QuoteIdentifier(r.From), pq.QuoteIdentifier(r.To)
+	sync := pq.QuoteIdentifier(r.syncName())
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "-- Contract: %s.%s replaces %s\n", r.Table, r.To, r.From)
+	fmt.Fprintf(&b, "DROP TRIGGER IF EXISTS %s ON %s;\n", sync, table)
+	fmt.Fprintf(&b, "DROP FUNCTION IF EXISTS %s();\n", sync)
+	if r.NotNull {
+		fmt.Fprintf(&b, "ALTER TABLE %s ALTER COLUMN %s SET NOT NULL;\n", table, to)
+	}
+	fmt.Fprintf(&b, "ALTER TABLE %s DROP COLUMN IF EXISTS %s;\n", table, from)
+	return b.String()
+}
+
+// RevertContractSQL restores the old column and the sync trigger, copying
+// the data back in a single statement since rollbacks favour safety over
+// lock time
+func (r *ColumnRename) RevertContractSQL() string {
+	table, from, to := pq.QuoteIdentifier(r.Table), pq.QuoteIdentifier(r.From), pq.QuoteIdentifier(r.To)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s;\n", table, from, r.Type)
+	fmt.Fprintf(&b, "UPDATE %s SET %s = %s WHERE %s IS DISTINCT FROM %s;\n", table, from, to, from, to)
+	if r.NotNull {
+		fmt.Fprintf(&b, "ALTER TABLE %s ALTER COLUMN %s DROP NOT NULL;\n", table, to)
+	}
+	b.WriteString("\n")
+
+	expand := r.ExpandSQL()
+	b.WriteString(expand[strings.Index(expand, "CREATE OR REPLACE FUNCTION"):])
+	return b.String()
+}
+
+// Backfill returns the backfill that copies the old column into the new one
+// for rows written before the expand migration
+func (r *ColumnRename) Backfill() *Backfill {
+	table, from, to := pq.QuoteIdentifier(r.Table), pq.QuoteIdentifier(r.From), pq.QuoteIdentifier(r.To)
+	pending := fmt.Sprintf("%s IS NULL AND %s IS NOT NULL", to, from)
+
+	return &Backfill{
+		Name:      fmt.Sprintf("%s.%s", r.Table, r.To),
+		Remaining: fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", table, pending),
+		Batch: fmt.Sprintf(`UPDATE %[1]s SET %[3]s = %[2]s
+WHERE ctid = ANY(ARRAY(SELECT ctid FROM %[1]s WHERE %[4]s LIMIT $1 FOR UPDATE SKIP LOCKED))`,
+			table, from, to, pending),
+	}
+}
+
+// Backfill migrates existing rows in batches, so a large table is never
+// locked by one long statement. Batch is run with a row limit as $1 until it
+// affects no rows; Remaining counts the rows still to do.
+type Backfill struct {
+	Name      string
+	Remaining string
+	Batch     string
+}
+
+// CountRemaining returns the number of rows still to backfill
+func (b *Backfill) CountRemaining(ctx context.Context, db *DB) (int, error) {
+	var remaining int
+	if err := db.QueryRowContext(ctx, b.Remaining).Scan(&remaining); err != nil {
+		return 0, fmt.Errorf("failed to count rows to backfill: %w", err)
+	}
+	return remaining, nil
+}
+
+// RunBatch backfills up to limit rows and returns how many it changed
+func (b *Backfill) RunBatch(ctx context.Context, db *DB, limit int) (int, error) {
+	result, err := db.ExecContext(ctx, b.Batch, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to run backfill batch: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get backfilled rows: %w", err)
+	}
+	return int(affected), nil
+}
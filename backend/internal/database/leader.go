@@ -0,0 +1,115 @@
+// Package database provides advisory-lock leader election
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"hash/fnv"
+	"sync"
+
+	"indico-backend/internal/logger"
+	"indico-backend/internal/metrics"
+)
+
+// Leader elects one replica to run singleton background tasks, such as
+// schedulers and recovery sweeps, using a Postgres session-level advisory
+// lock. The lock lives as long as the session that took it, so when the
+// leader crashes or loses its connection Postgres releases the lock and the
+// next replica to campaign takes over.
+type Leader struct {
+	db   *sql.DB
+	name string
+	key  int64
+
+	mu   sync.Mutex
+	conn *sql.Conn
+}
+
+// NewLeader creates a leader election for name on the DB's pool. Replicas
+// using the same name compete for the same lock.
+func (db *DB) NewLeader(name string) *Leader {
+	h := fnv.New64a()
+	h.Write([]byte("indico:leader:" + name))
+
+	return &Leader{
+		db:   db.DB,
+		name: name,
+		key:  int64(h.Sum64()),
+	}
+}
+
+// IsLeader reports whether this replica is the leader, campaigning for the
+// lock when it is not. Singleton tasks call it before each run rather than
+// once at startup, so a follower takes over on its next run after the
+// leader goes away.
+func (l *Leader) IsLeader(ctx context.Context) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	log := logger.WithComponent("leader").WithField("election", l.name)
+
+	if l.conn != nil {
+		// The lock is only as good as the session holding it
+		if _, err := l.conn.ExecContext(ctx, "SELECT 1"); err == nil {
+			return true
+		}
+		log.Warn("Lost leader session, campaigning again")
+		l.discard()
+	}
+
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		log.WithError(err).Error("Failed to get connection for leader election")
+		return false
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", l.key).Scan(&acquired); err != nil {
+		log.WithError(err).Error("Failed to campaign for leadership")
+		l.conn = conn
+		l.discard()
+		return false
+	}
+	if !acquired {
+		conn.Close()
+		return false
+	}
+
+	l.conn = conn
+	metrics.LeaderElected.WithLabelValues(l.name).Set(1)
+	log.Info("Acquired leadership")
+
+	return true
+}
+
+// Resign gives up leadership so another replica can take over without
+// waiting for this one's session to end
+func (l *Leader) Resign() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return
+	}
+
+	var released bool
+	if err := l.conn.QueryRowContext(context.Background(), "SELECT pg_advisory_unlock($1)", l.key).Scan(&released); err != nil || !released {
+		l.discard()
+		return
+	}
+
+	l.conn.Close()
+	l.conn = nil
+	metrics.LeaderElected.WithLabelValues(l.name).Set(0)
+	logger.WithComponent("leader").WithField("election", l.name).Info("Resigned leadership")
+}
+
+// discard closes the held connection instead of returning it to the pool,
+// ending its session and with it any advisory lock it may still hold
+func (l *Leader) discard() {
+	l.conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+	l.conn.Close()
+	l.conn = nil
+	metrics.LeaderElected.WithLabelValues(l.name).Set(0)
+}
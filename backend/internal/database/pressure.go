@@ -0,0 +1,120 @@
+// Package database provides connection pool saturation detection
+package database
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"indico-backend/internal/logger"
+	"indico-backend/internal/metrics"
+)
+
+// PressureMonitor samples the connection pool and reports it saturated when
+// requests waited on average longer than a threshold for a connection over
+// the last sample interval. It stays saturated for a cooldown after the
+// last slow sample so load shedding doesn't flap.
+type PressureMonitor struct {
+	db        *DB
+	threshold time.Duration
+	interval  time.Duration
+	cooldown  time.Duration
+
+	// saturatedUntil is the unix nanosecond time saturation ends
+	saturatedUntil atomic.Int64
+
+	lastWaitCount    int64
+	lastWaitDuration time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewPressureMonitor creates a monitor of the DB's main pool
+func (db *DB) NewPressureMonitor(threshold, interval, cooldown time.Duration) *PressureMonitor {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &PressureMonitor{
+		db:        db,
+		threshold: threshold,
+		interval:  interval,
+		cooldown:  cooldown,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Start starts sampling
+func (m *PressureMonitor) Start() {
+	stats := m.db.Stats()
+	m.lastWaitCount, m.lastWaitDuration = stats.WaitCount, stats.WaitDuration
+
+	m.wg.Add(1)
+	go m.run()
+}
+
+// Stop stops sampling
+func (m *PressureMonitor) Stop() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+// Saturated reports whether the pool is saturated; a nil monitor never is
+func (m *PressureMonitor) Saturated() bool {
+	if m == nil {
+		return false
+	}
+	return time.Now().UnixNano() < m.saturatedUntil.Load()
+}
+
+func (m *PressureMonitor) run() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.sample()
+		}
+	}
+}
+
+// sample measures the average connection wait since the previous sample
+func (m *PressureMonitor) sample() {
+	stats := m.db.Stats()
+	waits := stats.WaitCount - m.lastWaitCount
+	waited := stats.WaitDuration - m.lastWaitDuration
+	m.lastWaitCount, m.lastWaitDuration = stats.WaitCount, stats.WaitDuration
+
+	var average time.Duration
+	if waits > 0 {
+		average = waited / time.Duration(waits)
+	}
+	metrics.DatabasePoolWait.Set(average.Seconds())
+
+	wasSaturated := m.Saturated()
+	if average > m.threshold {
+		m.saturatedUntil.Store(time.Now().Add(m.cooldown).UnixNano())
+	}
+
+	saturated := m.Saturated()
+	if saturated {
+		metrics.DatabaseSaturated.Set(1)
+	} else {
+		metrics.DatabaseSaturated.Set(0)
+	}
+
+	if saturated != wasSaturated {
+		logger.WithComponent("pressure_monitor").
+			WithField("saturated", saturated).
+			WithField("average_wait", average.String()).
+			WithField("in_use", stats.InUse).
+			Warn("Database pool saturation changed")
+	}
+}
@@ -0,0 +1,164 @@
+package errors
+
+import (
+	"sort"
+)
+
+// CatalogEntry describes an error code clients can receive: the statuses
+// it comes with and the messages of the errors that carry it
+type CatalogEntry struct {
+	Code        string   `json:"code"`
+	Type        string   `json:"type"`
+	Title       string   `json:"title"`
+	Statuses    []int    `json:"statuses"`
+	Description string   `json:"description"`
+	Messages    []string `json:"messages"`
+}
+
+// registered lists every error the API returns, so the catalog is built from
+// the errors themselves. Errors made by constructors are listed with
+// placeholder arguments; only their code, status and message are used.
+var registered = []*AppError{
+	ErrProductNotFound,
+	ErrDuplicateSKU,
+	ErrDuplicateBarcode,
+	ErrProductInUse,
+	ErrClientReferenceConflict,
+	ErrOutOfStock,
+	ErrOrderQueueFull,
+	ErrOrderNotFound,
+	ErrOrderAlreadyCancelled,
+	ErrBundleNotFound,
+	ErrSalesEventNotFound,
+	ErrSalesEventOverlap,
+	ErrSaleNotStarted,
+	ErrSaleEnded,
+	ErrPurchaseLimitExceeded,
+	ErrQueueTokenRequired,
+	ErrQueueTokenInvalid,
+	ErrQueueTokenExpired,
+	ErrJobNotFound,
+	ErrJobStatsNotFound,
+	ErrJobAlreadyCancelled,
+	ErrJobNotDeadLettered,
+	ErrNoStaleSettlements,
+	ErrTransactionNotFound,
+	ErrSagaNotFound,
+	ErrEventTypeNotFound,
+	ErrErrorTypeNotFound,
+	ErrWebhookNotFound,
+	ErrSettlementRunNotFound,
+	ErrPayoutNotFound,
+	ErrBankAccountNotFound,
+	ErrPayoutOverlap,
+	ErrPayoutNotPending,
+	ErrNoApprovedPayouts,
+	ErrPayoutSchemeDisabled,
+	ErrFileNotFound,
+	ErrUnauthorized,
+	ErrAdminDisabled,
+	ErrDebugDisabled,
+	ErrSeedDisabled,
+	ErrSearchDisabled,
+	ErrInvalidAPIKey,
+	ErrPaymentWebhooksDisabled,
+	ErrInvalidWebhookSignature,
+	ErrRequestTimeout,
+	ErrInternalError,
+	NewValidationError("Invalid request"),
+	NewConcurrencyError("The resource was modified concurrently; retry"),
+	NewJobRangeLockedError(""),
+	NewQuotaExceededError(0),
+	NewQueueFullError(0),
+	NewInvalidTransitionError("", ""),
+	NewMaintenanceError(""),
+	NewLoadShedError(0, 0),
+	NewOffsetTooDeepError(0),
+	NewNotAdmittedError(0),
+	NewWaitingRoomFullError(0),
+	NewAuthBlockedError(0),
+}
+
+// descriptions explains what each error code means and what a client should
+// do about it
+var descriptions = map[string]string{
+	ErrCodeValidation:          "The request is malformed or a field is invalid; fix the request before retrying.",
+	ErrCodeNotFound:            "The addressed resource does not exist.",
+	ErrCodeFileNotFound:        "The job has no file of that name, or it has expired.",
+	ErrCodeConflict:            "The request conflicts with the current state of a resource, such as a duplicate key or an overlapping job.",
+	ErrCodeOutOfStock:          "The product does not have enough stock for the order.",
+	ErrCodeInternalError:       "An unexpected server error; safe to retry idempotent requests.",
+	ErrCodeUnauthorized:        "Credentials are missing or invalid.",
+	ErrCodeForbidden:           "The endpoint is disabled in this deployment.",
+	ErrCodeServiceUnavailable:  "A dependency is unavailable or overloaded; retry after the Retry-After delay when present.",
+	ErrCodeJobNotFound:         "The job does not exist.",
+	ErrCodeJobAlreadyCancelled: "The job was already cancelled.",
+	ErrCodeConcurrencyConflict: "The resource changed while the request was processed; retry with fresh data.",
+	ErrCodeQuotaExceeded:       "The client has too many active jobs; wait for one to finish.",
+	ErrCodeInvalidTransition:   "The status change is not allowed from the current status.",
+	ErrCodeRequestTimeout:      "The request exceeded its deadline and was cancelled.",
+	ErrCodeMaintenance:         "The API is read-only for maintenance; retry writes after the Retry-After delay.",
+	ErrCodeQueueFull:           "Too much work is queued; retry after the Retry-After delay.",
+	ErrCodeOffsetTooDeep:       "The list offset is past the paging limit; follow next_cursor from the previous page instead.",
+	ErrCodeSaleNotActive:       "The product is sold only during a sales event, and none is live; order while the event runs.",
+	ErrCodePurchaseLimit:       "The buyer already holds the sales event's per-buyer limit of the product.",
+	ErrCodeAuthBlocked:         "Too many failed attempts with an admin token or API key came from this client or credential; retry after the Retry-After delay.",
+	ErrCodeWaitingRoom:         "The product is behind a virtual waiting room; get a queue token and order with it once admitted, after the Retry-After delay when present.",
+}
+
+// Catalog returns every error code the API can return, sorted by code
+func Catalog() []CatalogEntry {
+	byCode := make(map[string]*CatalogEntry)
+	for _, err := range registered {
+		entry, ok := byCode[err.Code]
+		if !ok {
+			entry = &CatalogEntry{
+				Code:        err.Code,
+				Type:        ProblemType(err.Code),
+				Title:       problemTitle(err.Code),
+				Description: descriptions[err.Code],
+			}
+			byCode[err.Code] = entry
+		}
+		entry.Statuses = appendUnique(entry.Statuses, err.StatusCode)
+		entry.Messages = appendUniqueString(entry.Messages, err.Message)
+	}
+
+	catalog := make([]CatalogEntry, 0, len(byCode))
+	for _, entry := range byCode {
+		sort.Ints(entry.Statuses)
+		catalog = append(catalog, *entry)
+	}
+	sort.Slice(catalog, func(i, j int) bool { return catalog[i].Code < catalog[j].Code })
+
+	return catalog
+}
+
+// Lookup returns the catalog entry of a problem type slug, e.g.
+// out-of-stock, so problem type URIs resolve to their description
+func Lookup(slug string) (CatalogEntry, bool) {
+	for _, entry := range Catalog() {
+		if entry.Type == "/errors/"+slug {
+			return entry, true
+		}
+	}
+	return CatalogEntry{}, false
+}
+
+func appendUnique(values []int, value int) []int {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+func appendUniqueString(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
@@ -0,0 +1,39 @@
+package errors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCatalogDescribesEveryCode checks that every registered error code is
+// described and resolves through its problem type
+func TestCatalogDescribesEveryCode(t *testing.T) {
+	catalog := Catalog()
+	require.NotEmpty(t, catalog)
+
+	for _, entry := range catalog {
+		t.Run(entry.Code, func(t *testing.T) {
+			assert.NotEmpty(t, entry.Description, "no description for %s", entry.Code)
+			assert.NotEmpty(t, entry.Statuses)
+			assert.NotEmpty(t, entry.Messages)
+
+			found, ok := Lookup(entry.Type[len("/errors/"):])
+			require.True(t, ok)
+			assert.Equal(t, entry.Code, found.Code)
+		})
+	}
+
+	// Codes shared by several errors collect all their messages
+	for _, entry := range catalog {
+		if entry.Code == ErrCodeNotFound {
+			assert.Contains(t, entry.Messages, ErrProductNotFound.Message)
+			assert.Contains(t, entry.Messages, ErrOrderNotFound.Message)
+			assert.Equal(t, []int{404}, entry.Statuses)
+		}
+	}
+
+	_, ok := Lookup("no-such-error")
+	assert.False(t, ok)
+}
@@ -4,6 +4,10 @@ package errors
 import (
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
+
+	"indico-backend/internal/i18n"
 )
 
 // AppError represents an application error with context
@@ -13,6 +17,11 @@ type AppError struct {
 	StatusCode int    `json:"-"`
 	Details    string `json:"details,omitempty"`
 	Cause      error  `json:"-"`
+	// MessageKey selects the localized message; errors with dynamic
+	// messages leave it empty and are returned as-is
+	MessageKey string `json:"-"`
+	// RetryAfter, when positive, is sent as the Retry-After header
+	RetryAfter time.Duration `json:"-"`
 }
 
 // Error implements the error interface
@@ -32,6 +41,7 @@ func (e *AppError) Unwrap() error {
 const (
 	ErrCodeValidation          = "VALIDATION_ERROR"
 	ErrCodeNotFound            = "NOT_FOUND"
+	ErrCodeFileNotFound        = "FILE_NOT_FOUND"
 	ErrCodeConflict            = "CONFLICT"
 	ErrCodeOutOfStock          = "OUT_OF_STOCK"
 	ErrCodeInternalError       = "INTERNAL_ERROR"
@@ -42,6 +52,16 @@ const (
 	ErrCodeJobNotFound         = "JOB_NOT_FOUND"
 	ErrCodeJobAlreadyCancelled = "JOB_ALREADY_CANCELLED"
 	ErrCodeConcurrencyConflict = "CONCURRENCY_CONFLICT"
+	ErrCodeQuotaExceeded       = "QUOTA_EXCEEDED"
+	ErrCodeInvalidTransition   = "INVALID_STATUS_TRANSITION"
+	ErrCodeRequestTimeout      = "REQUEST_TIMEOUT"
+	ErrCodeMaintenance         = "MAINTENANCE_MODE"
+	ErrCodeQueueFull           = "QUEUE_FULL"
+	ErrCodeOffsetTooDeep       = "OFFSET_TOO_DEEP"
+	ErrCodeSaleNotActive       = "SALE_NOT_ACTIVE"
+	ErrCodePurchaseLimit       = "PURCHASE_LIMIT_EXCEEDED"
+	ErrCodeWaitingRoom         = "WAITING_ROOM"
+	ErrCodeAuthBlocked         = "AUTH_BLOCKED"
 )
 
 // Pre-defined errors
@@ -50,36 +70,323 @@ var (
 		Code:       ErrCodeNotFound,
 		Message:    "Product not found",
 		StatusCode: http.StatusNotFound,
+		MessageKey: "PRODUCT_NOT_FOUND",
+	}
+
+	ErrDuplicateSKU = &AppError{
+		Code:       ErrCodeConflict,
+		Message:    "A product with this SKU already exists",
+		StatusCode: http.StatusConflict,
+		MessageKey: "DUPLICATE_SKU",
+	}
+
+	ErrDuplicateBarcode = &AppError{
+		Code:       ErrCodeConflict,
+		Message:    "A product with this barcode already exists",
+		StatusCode: http.StatusConflict,
+		MessageKey: "DUPLICATE_BARCODE",
+	}
+
+	ErrProductInUse = &AppError{
+		Code:       ErrCodeConflict,
+		Message:    "The product has orders or belongs to a bundle or sales event",
+		StatusCode: http.StatusConflict,
+		MessageKey: "PRODUCT_IN_USE",
+	}
+
+	ErrClientReferenceConflict = &AppError{
+		Code:       ErrCodeConflict,
+		Message:    "An order with this client_reference already exists with different details",
+		StatusCode: http.StatusConflict,
+		MessageKey: "CLIENT_REFERENCE_CONFLICT",
 	}
 
 	ErrOutOfStock = &AppError{
 		Code:       ErrCodeOutOfStock,
 		Message:    "Insufficient stock",
 		StatusCode: http.StatusConflict,
+		MessageKey: "OUT_OF_STOCK",
+	}
+
+	ErrOrderQueueFull = &AppError{
+		Code:       ErrCodeQueueFull,
+		Message:    "Too many orders are waiting for this product; retry later",
+		StatusCode: http.StatusTooManyRequests,
+		MessageKey: "ORDER_QUEUE_FULL",
+		RetryAfter: time.Second,
 	}
 
 	ErrOrderNotFound = &AppError{
 		Code:       ErrCodeNotFound,
 		Message:    "Order not found",
 		StatusCode: http.StatusNotFound,
+		MessageKey: "ORDER_NOT_FOUND",
+	}
+
+	ErrOrderAlreadyCancelled = &AppError{
+		Code:       ErrCodeConflict,
+		Message:    "Order is already cancelled",
+		StatusCode: http.StatusConflict,
+		MessageKey: "ORDER_ALREADY_CANCELLED",
+	}
+
+	ErrBundleNotFound = &AppError{
+		Code:       ErrCodeNotFound,
+		Message:    "Bundle not found",
+		StatusCode: http.StatusNotFound,
+		MessageKey: "BUNDLE_NOT_FOUND",
+	}
+
+	ErrSalesEventNotFound = &AppError{
+		Code:       ErrCodeNotFound,
+		Message:    "Sales event not found",
+		StatusCode: http.StatusNotFound,
+		MessageKey: "SALES_EVENT_NOT_FOUND",
+	}
+
+	ErrSalesEventOverlap = &AppError{
+		Code:       ErrCodeConflict,
+		Message:    "A product is already in a sales event that overlaps this one",
+		StatusCode: http.StatusConflict,
+		MessageKey: "SALES_EVENT_OVERLAP",
+	}
+
+	ErrSaleNotStarted = &AppError{
+		Code:       ErrCodeSaleNotActive,
+		Message:    "The product's sales event has not started",
+		StatusCode: http.StatusConflict,
+		MessageKey: "SALE_NOT_STARTED",
+	}
+
+	ErrSaleEnded = &AppError{
+		Code:       ErrCodeSaleNotActive,
+		Message:    "The product's sales event has ended",
+		StatusCode: http.StatusConflict,
+		MessageKey: "SALE_ENDED",
+	}
+
+	ErrPurchaseLimitExceeded = &AppError{
+		Code:       ErrCodePurchaseLimit,
+		Message:    "The order exceeds the sales event's per-buyer limit",
+		StatusCode: http.StatusConflict,
+		MessageKey: "PURCHASE_LIMIT_EXCEEDED",
+	}
+
+	ErrQueueTokenRequired = &AppError{
+		Code:       ErrCodeWaitingRoom,
+		Message:    "The product is behind a waiting room; order with a queue token",
+		StatusCode: http.StatusForbidden,
+		MessageKey: "QUEUE_TOKEN_REQUIRED",
+	}
+
+	ErrQueueTokenInvalid = &AppError{
+		Code:       ErrCodeWaitingRoom,
+		Message:    "The queue token is invalid for this order",
+		StatusCode: http.StatusForbidden,
+		MessageKey: "QUEUE_TOKEN_INVALID",
+	}
+
+	ErrQueueTokenExpired = &AppError{
+		Code:       ErrCodeWaitingRoom,
+		Message:    "The queue token has expired; get a new one",
+		StatusCode: http.StatusForbidden,
+		MessageKey: "QUEUE_TOKEN_EXPIRED",
 	}
 
 	ErrJobNotFound = &AppError{
 		Code:       ErrCodeJobNotFound,
 		Message:    "Job not found",
 		StatusCode: http.StatusNotFound,
+		MessageKey: "JOB_NOT_FOUND",
+	}
+
+	ErrJobStatsNotFound = &AppError{
+		Code:       ErrCodeNotFound,
+		Message:    "Job has no stats until it finishes",
+		StatusCode: http.StatusNotFound,
+		MessageKey: "JOB_STATS_NOT_FOUND",
 	}
 
 	ErrJobAlreadyCancelled = &AppError{
 		Code:       ErrCodeJobAlreadyCancelled,
 		Message:    "Job is already cancelled",
 		StatusCode: http.StatusConflict,
+		MessageKey: "JOB_ALREADY_CANCELLED",
+	}
+
+	ErrJobNotDeadLettered = &AppError{
+		Code:       ErrCodeConflict,
+		Message:    "Only dead-lettered jobs can be re-driven",
+		StatusCode: http.StatusConflict,
+		MessageKey: "JOB_NOT_DEAD_LETTERED",
+	}
+
+	ErrNoStaleSettlements = &AppError{
+		Code:       ErrCodeConflict,
+		Message:    "No settlements are flagged for re-settlement",
+		StatusCode: http.StatusConflict,
+		MessageKey: "NO_STALE_SETTLEMENTS",
+	}
+
+	ErrTransactionNotFound = &AppError{
+		Code:       ErrCodeNotFound,
+		Message:    "Transaction not found",
+		StatusCode: http.StatusNotFound,
+		MessageKey: "TRANSACTION_NOT_FOUND",
+	}
+
+	ErrSagaNotFound = &AppError{
+		Code:       ErrCodeNotFound,
+		Message:    "Saga not found",
+		StatusCode: http.StatusNotFound,
+		MessageKey: "SAGA_NOT_FOUND",
+	}
+
+	ErrErrorTypeNotFound = &AppError{
+		Code:       ErrCodeNotFound,
+		Message:    "Error type not found",
+		StatusCode: http.StatusNotFound,
+		MessageKey: "ERROR_TYPE_NOT_FOUND",
+	}
+
+	ErrEventTypeNotFound = &AppError{
+		Code:       ErrCodeNotFound,
+		Message:    "Event type not found",
+		StatusCode: http.StatusNotFound,
+		MessageKey: "EVENT_TYPE_NOT_FOUND",
+	}
+
+	ErrWebhookNotFound = &AppError{
+		Code:       ErrCodeNotFound,
+		Message:    "Webhook endpoint not found",
+		StatusCode: http.StatusNotFound,
+		MessageKey: "WEBHOOK_NOT_FOUND",
+	}
+
+	ErrSettlementRunNotFound = &AppError{
+		Code:       ErrCodeNotFound,
+		Message:    "Settlement run not found",
+		StatusCode: http.StatusNotFound,
+		MessageKey: "SETTLEMENT_RUN_NOT_FOUND",
+	}
+
+	ErrPayoutNotFound = &AppError{
+		Code:       ErrCodeNotFound,
+		Message:    "Payout not found",
+		StatusCode: http.StatusNotFound,
+		MessageKey: "PAYOUT_NOT_FOUND",
+	}
+
+	ErrBankAccountNotFound = &AppError{
+		Code:       ErrCodeNotFound,
+		Message:    "The merchant has no bank account on file",
+		StatusCode: http.StatusNotFound,
+		MessageKey: "BANK_ACCOUNT_NOT_FOUND",
+	}
+
+	ErrPayoutOverlap = &AppError{
+		Code:       ErrCodeConflict,
+		Message:    "The merchant already has a payout overlapping this period",
+		StatusCode: http.StatusConflict,
+		MessageKey: "PAYOUT_OVERLAP",
+	}
+
+	ErrPayoutNotPending = &AppError{
+		Code:       ErrCodeConflict,
+		Message:    "Only pending payouts can be approved",
+		StatusCode: http.StatusConflict,
+		MessageKey: "PAYOUT_NOT_PENDING",
+	}
+
+	ErrNoApprovedPayouts = &AppError{
+		Code:       ErrCodeConflict,
+		Message:    "No approved payouts are waiting for a bank file",
+		StatusCode: http.StatusConflict,
+		MessageKey: "NO_APPROVED_PAYOUTS",
+	}
+
+	ErrPayoutSchemeDisabled = &AppError{
+		Code:       ErrCodeServiceUnavailable,
+		Message:    "Bank files are not configured for this scheme",
+		StatusCode: http.StatusServiceUnavailable,
+		MessageKey: "PAYOUT_SCHEME_DISABLED",
+	}
+
+	ErrFileNotFound = &AppError{
+		Code:       ErrCodeFileNotFound,
+		Message:    "File not found",
+		StatusCode: http.StatusNotFound,
+		MessageKey: "FILE_NOT_FOUND",
+	}
+
+	ErrUnauthorized = &AppError{
+		Code:       ErrCodeUnauthorized,
+		Message:    "Missing or invalid admin token",
+		StatusCode: http.StatusUnauthorized,
+		MessageKey: "UNAUTHORIZED",
+	}
+
+	ErrAdminDisabled = &AppError{
+		Code:       ErrCodeForbidden,
+		Message:    "Admin endpoints are disabled",
+		StatusCode: http.StatusForbidden,
+		MessageKey: "ADMIN_DISABLED",
+	}
+
+	ErrDebugDisabled = &AppError{
+		Code:       ErrCodeForbidden,
+		Message:    "Debug endpoints are disabled in this environment",
+		StatusCode: http.StatusForbidden,
+		MessageKey: "DEBUG_DISABLED",
+	}
+
+	ErrSeedDisabled = &AppError{
+		Code:       ErrCodeForbidden,
+		Message:    "Seeding is disabled in this environment",
+		StatusCode: http.StatusForbidden,
+		MessageKey: "SEED_DISABLED",
+	}
+
+	ErrSearchDisabled = &AppError{
+		Code:       ErrCodeServiceUnavailable,
+		Message:    "Search is not configured",
+		StatusCode: http.StatusServiceUnavailable,
+		MessageKey: "SEARCH_DISABLED",
+	}
+
+	ErrInvalidAPIKey = &AppError{
+		Code:       ErrCodeUnauthorized,
+		Message:    "Invalid API key",
+		StatusCode: http.StatusUnauthorized,
+		MessageKey: "INVALID_API_KEY",
+	}
+
+	ErrPaymentWebhooksDisabled = &AppError{
+		Code:       ErrCodeServiceUnavailable,
+		Message:    "Payment webhooks are not configured",
+		StatusCode: http.StatusServiceUnavailable,
+		MessageKey: "PAYMENT_WEBHOOKS_DISABLED",
+	}
+
+	ErrInvalidWebhookSignature = &AppError{
+		Code:       ErrCodeUnauthorized,
+		Message:    "Missing, invalid or expired webhook signature",
+		StatusCode: http.StatusUnauthorized,
+		MessageKey: "INVALID_WEBHOOK_SIGNATURE",
+	}
+
+	ErrRequestTimeout = &AppError{
+		Code:       ErrCodeRequestTimeout,
+		Message:    "The request took too long to complete",
+		StatusCode: http.StatusGatewayTimeout,
+		MessageKey: "REQUEST_TIMEOUT",
 	}
 
 	ErrInternalError = &AppError{
 		Code:       ErrCodeInternalError,
 		Message:    "Internal server error",
 		StatusCode: http.StatusInternalServerError,
+		MessageKey: "INTERNAL_ERROR",
 	}
 )
 
@@ -120,6 +427,128 @@ func NewConcurrencyError(message string) *AppError {
 	}
 }
 
+// NewJobRangeLockedError creates an error for a job blocked by an overlapping job
+func NewJobRangeLockedError(blockingJobID string) *AppError {
+	return &AppError{
+		Code:       ErrCodeConflict,
+		Message:    "An overlapping job is already running for this date range",
+		StatusCode: http.StatusConflict,
+		Details:    "blocking_job_id=" + blockingJobID,
+		MessageKey: "JOB_RANGE_LOCKED",
+	}
+}
+
+// NewQuotaExceededError creates an error for a client over its active job limit
+func NewQuotaExceededError(limit int) *AppError {
+	return &AppError{
+		Code:       ErrCodeQuotaExceeded,
+		Message:    "Too many active jobs for this client",
+		StatusCode: http.StatusTooManyRequests,
+		Details:    fmt.Sprintf("max_active_jobs=%d", limit),
+		MessageKey: "QUOTA_EXCEEDED",
+	}
+}
+
+// NewQueueFullError creates an error for a job rejected because the job
+// queue is full, hinting when the queue should have room again
+func NewQueueFullError(retryAfter time.Duration) *AppError {
+	return &AppError{
+		Code:       ErrCodeQueueFull,
+		Message:    "The job queue is full; retry later",
+		StatusCode: http.StatusTooManyRequests,
+		Details:    fmt.Sprintf("retry_after_seconds=%d", int(retryAfter.Seconds())),
+		MessageKey: "QUEUE_FULL",
+		RetryAfter: retryAfter,
+	}
+}
+
+// NewNotAdmittedError creates an error for an order whose queue token does
+// not admit it yet, hinting when it will
+func NewNotAdmittedError(retryAfter time.Duration) *AppError {
+	return &AppError{
+		Code:       ErrCodeWaitingRoom,
+		Message:    "The queue token does not admit the order yet; retry later",
+		StatusCode: http.StatusTooManyRequests,
+		Details:    fmt.Sprintf("retry_after_seconds=%d", int(retryAfter.Seconds())),
+		MessageKey: "QUEUE_TOKEN_NOT_ADMITTED",
+		RetryAfter: retryAfter,
+	}
+}
+
+// NewWaitingRoomFullError creates an error for a queue token refused
+// because the wait would be too long, hinting when to ask again
+func NewWaitingRoomFullError(retryAfter time.Duration) *AppError {
+	return &AppError{
+		Code:       ErrCodeWaitingRoom,
+		Message:    "The waiting room is full; retry later",
+		StatusCode: http.StatusServiceUnavailable,
+		Details:    fmt.Sprintf("retry_after_seconds=%d", int(retryAfter.Seconds())),
+		MessageKey: "WAITING_ROOM_FULL",
+		RetryAfter: retryAfter,
+	}
+}
+
+// NewAuthBlockedError creates an error for a client or credential banned
+// after repeated failed authentication, hinting when the ban ends
+func NewAuthBlockedError(retryAfter time.Duration) *AppError {
+	return &AppError{
+		Code:       ErrCodeAuthBlocked,
+		Message:    "Too many failed authentication attempts; retry later",
+		StatusCode: http.StatusTooManyRequests,
+		Details:    fmt.Sprintf("retry_after_seconds=%d", int(retryAfter.Seconds())),
+		MessageKey: "AUTH_BLOCKED",
+		RetryAfter: retryAfter,
+	}
+}
+
+// NewInvalidTransitionError creates an error for a disallowed status change
+func NewInvalidTransitionError(from, to string) *AppError {
+	return &AppError{
+		Code:       ErrCodeInvalidTransition,
+		Message:    "Invalid status transition",
+		StatusCode: http.StatusConflict,
+		Details:    fmt.Sprintf("from=%s to=%s", from, to),
+		MessageKey: "INVALID_STATUS_TRANSITION",
+	}
+}
+
+// NewMaintenanceError creates an error for a write rejected during
+// maintenance, carrying the operator's message as details
+func NewMaintenanceError(message string) *AppError {
+	return &AppError{
+		Code:       ErrCodeMaintenance,
+		Message:    "The API is in maintenance mode; only reads are available",
+		StatusCode: http.StatusServiceUnavailable,
+		Details:    message,
+		MessageKey: "MAINTENANCE_MODE",
+	}
+}
+
+// NewLoadShedError creates an error for a page too deep to serve while the
+// database is saturated, hinting when to retry
+func NewLoadShedError(maxOffset int, retryAfter time.Duration) *AppError {
+	return &AppError{
+		Code:       ErrCodeServiceUnavailable,
+		Message:    "The database is under heavy load; deep pages are temporarily unavailable",
+		StatusCode: http.StatusServiceUnavailable,
+		Details:    fmt.Sprintf("max_offset=%d", maxOffset),
+		MessageKey: "LOAD_SHED",
+		RetryAfter: retryAfter,
+	}
+}
+
+// NewOffsetTooDeepError creates an error for a list page past the offset
+// paging limit, pointing the client at cursor paging
+func NewOffsetTooDeepError(maxOffset int) *AppError {
+	return &AppError{
+		Code:       ErrCodeOffsetTooDeep,
+		Message:    "The offset is too deep; page with the cursor from next_cursor instead",
+		StatusCode: http.StatusBadRequest,
+		Details:    fmt.Sprintf("max_offset=%d", maxOffset),
+		MessageKey: "OFFSET_TOO_DEEP",
+	}
+}
+
 // IsAppError checks if an error is an AppError
 func IsAppError(err error) (*AppError, bool) {
 	if appErr, ok := err.(*AppError); ok {
@@ -146,6 +575,15 @@ type ErrorDetail struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
 	Details string `json:"details,omitempty"`
+
+	messageKey string
+}
+
+// Localize replaces the message with its translation in lang, if one exists
+func (d *ErrorDetail) Localize(lang string) {
+	if message, ok := i18n.Message(lang, d.messageKey); ok {
+		d.Message = message
+	}
 }
 
 // ToErrorResponse converts an error to an error response
@@ -153,17 +591,126 @@ func ToErrorResponse(err error) ErrorResponse {
 	if appErr, ok := IsAppError(err); ok {
 		return ErrorResponse{
 			Error: ErrorDetail{
-				Code:    appErr.Code,
-				Message: appErr.Message,
-				Details: appErr.Details,
+				Code:       appErr.Code,
+				Message:    appErr.Message,
+				Details:    appErr.Details,
+				messageKey: appErr.MessageKey,
 			},
 		}
 	}
 
 	return ErrorResponse{
 		Error: ErrorDetail{
-			Code:    ErrCodeInternalError,
-			Message: "Internal server error",
+			Code:       ErrCodeInternalError,
+			Message:    "Internal server error",
+			messageKey: ErrInternalError.MessageKey,
 		},
 	}
 }
+
+// ProblemContentType is the media type of RFC 7807 problem details
+const ProblemContentType = "application/problem+json"
+
+// ProblemDetails represents an RFC 7807 problem details response. Code and
+// Details extend the standard members so clients keep the AppError taxonomy.
+type ProblemDetails struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+	Details  string `json:"details,omitempty"`
+}
+
+// ToProblemDetails converts an error response to problem details for the
+// given status code and occurrence URI
+func (r ErrorResponse) ToProblemDetails(statusCode int, instance string) ProblemDetails {
+	return ProblemDetails{
+		Type:     ProblemType(r.Error.Code),
+		Title:    problemTitle(r.Error.Code),
+		Status:   statusCode,
+		Detail:   r.Error.Message,
+		Instance: instance,
+		Code:     r.Error.Code,
+		Details:  r.Error.Details,
+	}
+}
+
+// ProblemType returns the problem type URI for an error code,
+// e.g. OUT_OF_STOCK becomes /errors/out-of-stock
+func ProblemType(code string) string {
+	return "/errors/" + strings.ToLower(strings.ReplaceAll(code, "_", "-"))
+}
+
+// problemTitle returns a short human-readable summary of an error code,
+// e.g. OUT_OF_STOCK becomes "Out of stock"
+func problemTitle(code string) string {
+	title := strings.ToLower(strings.ReplaceAll(code, "_", " "))
+	if title == "" {
+		return title
+	}
+	return strings.ToUpper(title[:1]) + title[1:]
+}
+
+// MultiStatusResponse represents the per-item outcome of a bulk operation.
+// Items are reported individually so one bad item does not fail the batch.
+type MultiStatusResponse struct {
+	Results   []ItemStatus `json:"results"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+}
+
+// ItemStatus represents the outcome of a single item in a bulk operation
+type ItemStatus struct {
+	Index  int          `json:"index"`
+	Status int          `json:"status"`
+	Data   interface{}  `json:"data,omitempty"`
+	Error  *ErrorDetail `json:"error,omitempty"`
+}
+
+// NewMultiStatusResponse creates a multi-status response for a batch of the given size
+func NewMultiStatusResponse(size int) *MultiStatusResponse {
+	return &MultiStatusResponse{
+		Results: make([]ItemStatus, 0, size),
+	}
+}
+
+// AddSuccess records a successful item
+func (r *MultiStatusResponse) AddSuccess(index, statusCode int, data interface{}) {
+	r.Results = append(r.Results, ItemStatus{
+		Index:  index,
+		Status: statusCode,
+		Data:   data,
+	})
+	r.Succeeded++
+}
+
+// AddFailure records a failed item using the error's code and message
+func (r *MultiStatusResponse) AddFailure(index int, err error) {
+	detail := ToErrorResponse(err).Error
+	r.Results = append(r.Results, ItemStatus{
+		Index:  index,
+		Status: GetStatusCode(err),
+		Error:  &detail,
+	})
+	r.Failed++
+}
+
+// Localize translates every item error into lang
+func (r *MultiStatusResponse) Localize(lang string) {
+	for i := range r.Results {
+		if r.Results[i].Error != nil {
+			r.Results[i].Error.Localize(lang)
+		}
+	}
+}
+
+// StatusCode returns successStatus when every item succeeded and
+// 207 Multi-Status otherwise
+func (r *MultiStatusResponse) StatusCode(successStatus int) int {
+	if r.Failed == 0 {
+		return successStatus
+	}
+	return http.StatusMultiStatus
+}
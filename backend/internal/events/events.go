@@ -0,0 +1,272 @@
+// Package events defines the versioned event payloads delivered to webhook
+// consumers and the JSON schemas published for them
+package events
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"indico-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// SchemaContentType is the media type of published schemas
+const SchemaContentType = "application/schema+json"
+
+// schemaDialect is the JSON Schema draft the published schemas follow
+const schemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// SchemaPath is where the catalog is served; each event's schema is served
+// at SchemaPath + "/" + its type
+const SchemaPath = "/events/schemas"
+
+// Event types carry their payload version. Within a version, payloads only
+// gain optional fields; removing, renaming or retyping a field adds a new
+// version, delivered alongside the old one until consumers have moved.
+const (
+	OrderCreatedV1        = "order.created.v1"
+	SettlementCompletedV1 = "settlement.completed.v1"
+	JobProgressV1         = "job.progress.v1"
+	WebhookPingV1         = "webhook.ping.v1"
+)
+
+// Envelope wraps every delivered event
+type Envelope struct {
+	ID         uuid.UUID `json:"id" doc:"Unique event ID; redeliveries keep it, so consumers can deduplicate"`
+	Type       string    `json:"type" doc:"Versioned event type"`
+	OccurredAt time.Time `json:"occurred_at" doc:"When the event happened"`
+	Data       any       `json:"data"`
+}
+
+// OrderCreated is the order.created.v1 payload, sent when an order is
+// placed
+type OrderCreated struct {
+	OrderID         uuid.UUID `json:"order_id"`
+	ProductID       int       `json:"product_id"`
+	BuyerID         string    `json:"buyer_id"`
+	Quantity        int       `json:"quantity" minimum:"1"`
+	Status          string    `json:"status" enum:"PENDING,CONFIRMED,CANCELLED"`
+	TotalCents      int       `json:"total_cents" doc:"Order total in cents"`
+	ClientReference *string   `json:"client_reference,omitempty" doc:"The client's own identifier for the order"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// SettlementCompleted is the settlement.completed.v1 payload, sent when a
+// settlement run has written its settlements
+type SettlementCompleted struct {
+	RunID           uuid.UUID  `json:"run_id"`
+	JobID           *uuid.UUID `json:"job_id,omitempty" doc:"The settlement job that produced the run"`
+	From            time.Time  `json:"from" doc:"Start of the settled range, inclusive"`
+	To              time.Time  `json:"to" doc:"End of the settled range, exclusive"`
+	SettlementCount int        `json:"settlement_count" minimum:"0"`
+	CompletedAt     time.Time  `json:"completed_at"`
+}
+
+// JobProgress is the job.progress.v1 payload, sent to a job's progress
+// webhook as the job passes each of its milestones
+type JobProgress struct {
+	JobID     uuid.UUID `json:"job_id"`
+	JobType   string    `json:"job_type"`
+	Milestone int       `json:"milestone" minimum:"1" doc:"The milestone passed, in percent"`
+	Progress  float64   `json:"progress" doc:"The job's progress when the milestone was passed, in percent"`
+	Processed int       `json:"processed" minimum:"0"`
+	Total     int       `json:"total" minimum:"0"`
+}
+
+// WebhookPing is the webhook.ping.v1 payload, sent on demand to check that
+// an endpoint receives and verifies callbacks
+type WebhookPing struct {
+	WebhookID uuid.UUID `json:"webhook_id"`
+}
+
+// Definition describes one event type in the catalog
+type Definition struct {
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	SchemaURL   string `json:"schema_url"`
+
+	payload reflect.Type
+}
+
+// catalog lists every event type delivered, oldest version first
+var catalog = []Definition{
+	{
+		Type:        OrderCreatedV1,
+		Description: "An order was placed",
+		payload:     reflect.TypeOf(OrderCreated{}),
+	},
+	{
+		Type:        SettlementCompletedV1,
+		Description: "A settlement run finished writing its settlements",
+		payload:     reflect.TypeOf(SettlementCompleted{}),
+	},
+	{
+		Type:        JobProgressV1,
+		Description: "A job passed one of the progress milestones requested for it",
+		payload:     reflect.TypeOf(JobProgress{}),
+	},
+	{
+		Type:        WebhookPingV1,
+		Description: "A test callback requested for a webhook endpoint",
+		payload:     reflect.TypeOf(WebhookPing{}),
+	},
+}
+
+func init() {
+	for i := range catalog {
+		catalog[i].SchemaURL = SchemaPath + "/" + catalog[i].Type
+	}
+}
+
+// Catalog returns every event type delivered
+func Catalog() []Definition {
+	return append([]Definition(nil), catalog...)
+}
+
+// Lookup returns the definition of an event type
+func Lookup(eventType string) (Definition, bool) {
+	for _, def := range catalog {
+		if def.Type == eventType {
+			return def, true
+		}
+	}
+	return Definition{}, false
+}
+
+// Schema returns the JSON schema of the event's envelope with its payload
+func (d Definition) Schema() map[string]any {
+	schema := schemaFor(reflect.TypeOf(Envelope{}))
+	schema["$schema"] = schemaDialect
+	schema["$id"] = d.SchemaURL
+	schema["title"] = d.Type
+	schema["description"] = d.Description
+
+	properties := schema["properties"].(map[string]any)
+	properties["type"].(map[string]any)["const"] = d.Type
+	properties["data"] = schemaFor(d.payload)
+
+	return schema
+}
+
+// NewOrderCreated creates an order.created.v1 event
+func NewOrderCreated(order *models.Order) *Envelope {
+	return newEnvelope(OrderCreatedV1, &OrderCreated{
+		OrderID:         order.ID,
+		ProductID:       order.ProductID,
+		BuyerID:         order.BuyerID,
+		Quantity:        order.Quantity,
+		Status:          string(order.Status),
+		TotalCents:      order.TotalCents,
+		ClientReference: order.ClientReference,
+		CreatedAt:       order.CreatedAt,
+	})
+}
+
+// NewSettlementCompleted creates a settlement.completed.v1 event
+func NewSettlementCompleted(run *models.SettlementRun) *Envelope {
+	return newEnvelope(SettlementCompletedV1, &SettlementCompleted{
+		RunID:           run.ID,
+		JobID:           run.JobID,
+		From:            run.From,
+		To:              run.To,
+		SettlementCount: run.SettlementCount,
+		CompletedAt:     run.CreatedAt,
+	})
+}
+
+// NewJobProgress creates a job.progress.v1 event from a job's progress
+func NewJobProgress(job *models.Job, milestone int) *Envelope {
+	return newEnvelope(JobProgressV1, &JobProgress{
+		JobID:     job.ID,
+		JobType:   string(job.Type),
+		Milestone: milestone,
+		Progress:  job.Progress,
+		Processed: job.Processed,
+		Total:     job.Total,
+	})
+}
+
+// NewWebhookPing creates a webhook.ping.v1 event
+func NewWebhookPing(webhookID uuid.UUID) *Envelope {
+	return newEnvelope(WebhookPingV1, &WebhookPing{WebhookID: webhookID})
+}
+
+func newEnvelope(eventType string, data any) *Envelope {
+	return &Envelope{
+		ID:         uuid.New(),
+		Type:       eventType,
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	}
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	uuidType = reflect.TypeOf(uuid.UUID{})
+)
+
+// schemaFor derives the JSON schema of a payload type from its fields and
+// their json, doc, enum and minimum tags. Fields without omitempty are
+// required; pointers are optional and may be null.
+func schemaFor(t reflect.Type) map[string]any {
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case uuidType:
+		return map[string]any{"type": "string", "format": "uuid"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := schemaFor(t.Elem())
+		schema["type"] = []any{schema["type"], "null"}
+		return schema
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": schemaFor(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]any, t.NumField())
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+
+			property := schemaFor(field.Type)
+			if doc := field.Tag.Get("doc"); doc != "" {
+				property["description"] = doc
+			}
+			if enum := field.Tag.Get("enum"); enum != "" {
+				values := []any{}
+				for _, value := range strings.Split(enum, ",") {
+					values = append(values, value)
+				}
+				property["enum"] = values
+			}
+			if minimum, err := strconv.Atoi(field.Tag.Get("minimum")); err == nil {
+				property["minimum"] = minimum
+			}
+			properties[name] = property
+
+			if options != "omitempty" {
+				required = append(required, name)
+			}
+		}
+		return map[string]any{"type": "object", "properties": properties, "required": required}
+	default:
+		// Interface fields, such as the envelope's data, accept anything
+		return map[string]any{}
+	}
+}
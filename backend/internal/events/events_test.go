@@ -0,0 +1,74 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"indico-backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPayloadsMatchSchemas checks that every catalogued event serializes to
+// exactly the properties its published schema declares
+func TestPayloadsMatchSchemas(t *testing.T) {
+	jobID := uuid.New()
+	samples := map[string]*Envelope{
+		OrderCreatedV1: NewOrderCreated(&models.Order{
+			ID:         uuid.New(),
+			ProductID:  1,
+			BuyerID:    "buyer-1",
+			Quantity:   2,
+			Status:     models.OrderStatusConfirmed,
+			TotalCents: 2000,
+			CreatedAt:  time.Now(),
+		}),
+		SettlementCompletedV1: NewSettlementCompleted(&models.SettlementRun{
+			ID:              uuid.New(),
+			JobID:           &jobID,
+			From:            time.Now().Add(-24 * time.Hour),
+			To:              time.Now(),
+			SettlementCount: 3,
+			CreatedAt:       time.Now(),
+		}),
+		JobProgressV1: NewJobProgress(&models.Job{
+			ID:        jobID,
+			Type:      models.JobTypeSettlement,
+			Progress:  51.02,
+			Processed: 5000,
+			Total:     9800,
+		}, 50),
+		WebhookPingV1: NewWebhookPing(uuid.New()),
+	}
+
+	for _, def := range Catalog() {
+		t.Run(def.Type, func(t *testing.T) {
+			sample, ok := samples[def.Type]
+			require.True(t, ok, "no sample event for %s", def.Type)
+
+			data, err := json.Marshal(sample)
+			require.NoError(t, err)
+			var event map[string]any
+			require.NoError(t, json.Unmarshal(data, &event))
+
+			schema := def.Schema()
+			assert.Equal(t, def.Type, event["type"])
+			assertMatches(t, schema, event)
+			assertMatches(t, schema["properties"].(map[string]any)["data"].(map[string]any), event["data"].(map[string]any))
+		})
+	}
+}
+
+// assertMatches checks an object against the properties of its schema
+func assertMatches(t *testing.T, schema map[string]any, object map[string]any) {
+	properties := schema["properties"].(map[string]any)
+	for _, name := range schema["required"].([]string) {
+		assert.Contains(t, object, name, "required property %s missing", name)
+	}
+	for name := range object {
+		assert.Contains(t, properties, name, "property %s not in the schema", name)
+	}
+}
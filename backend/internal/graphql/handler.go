@@ -0,0 +1,60 @@
+package graphql
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"indico-backend/internal/config"
+	"indico-backend/internal/service"
+
+	gqlgo "github.com/graph-gophers/graphql-go"
+)
+
+// Handler serves GraphQL queries over HTTP
+type Handler struct {
+	services *service.Services
+	schema   *gqlgo.Schema
+}
+
+// graphQLRequest is the body of a GraphQL request
+type graphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// NewHandler creates a new GraphQL handler; list fields are degraded per
+// shed while the database is saturated, as the REST listings are
+func NewHandler(services *service.Services, shed *config.LoadShedConfig) *Handler {
+	r := &resolver{services: services, shed: shed}
+
+	return &Handler{
+		services: services,
+		schema:   gqlgo.MustParseSchema(schema, r, gqlgo.MaxDepth(8)),
+	}
+}
+
+// ServeHTTP executes a GraphQL request with per-request data loaders
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req graphQLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := withLoaders(r.Context(), h.services)
+	response := h.schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+	body, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// A response with errors is still a 200; keep it out of caches so a
+	// failed lookup isn't replayed
+	if len(response.Errors) > 0 {
+		w.Header().Set("Cache-Control", "no-store")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
@@ -0,0 +1,352 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"indico-backend/internal/config"
+	"indico-backend/internal/models"
+	"indico-backend/internal/service"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// catalog is a product service serving products from memory, recording
+// each batch it is asked for
+type catalog struct {
+	service.ProductService
+
+	products map[int]*models.Product
+	err      error
+
+	mu      sync.Mutex
+	batches [][]int
+}
+
+func (c *catalog) GetProducts(ctx context.Context, ids []int) ([]*models.Product, error) {
+	c.mu.Lock()
+	c.batches = append(c.batches, append([]int(nil), ids...))
+	c.mu.Unlock()
+
+	if c.err != nil {
+		return nil, c.err
+	}
+	var products []*models.Product
+	for _, id := range ids {
+		if product, ok := c.products[id]; ok {
+			products = append(products, product)
+		}
+	}
+	return products, nil
+}
+
+// orderBook is an order service listing orders from memory, recording the
+// page sizes it is asked for
+type orderBook struct {
+	service.OrderService
+	orders []*models.Order
+	limits []int
+}
+
+func (b *orderBook) ListOrders(ctx context.Context, limit, offset int) ([]*models.Order, error) {
+	b.limits = append(b.limits, limit)
+	return b.orders, nil
+}
+
+// ledger is a settlement service listing runs from memory, recording each
+// batch of runs whose settlements it is asked for
+type ledger struct {
+	service.SettlementService
+	runs        []*models.SettlementRun
+	settlements []*models.Settlement
+
+	mu      sync.Mutex
+	batches [][]uuid.UUID
+}
+
+func (l *ledger) ListRuns(ctx context.Context, limit, offset int) ([]*models.SettlementRun, error) {
+	return l.runs, nil
+}
+
+func (l *ledger) ListRunsSettlements(ctx context.Context, runIDs []uuid.UUID) ([]*models.Settlement, error) {
+	l.mu.Lock()
+	l.batches = append(l.batches, append([]uuid.UUID(nil), runIDs...))
+	l.mu.Unlock()
+
+	var settlements []*models.Settlement
+	for _, settlement := range l.settlements {
+		for _, id := range runIDs {
+			if settlement.UniqueRunID == id {
+				settlements = append(settlements, settlement)
+			}
+		}
+	}
+	return settlements, nil
+}
+
+// jobBoard is a job service serving jobs from memory, recording each batch
+// it is asked for
+type jobBoard struct {
+	service.JobService
+	jobs map[uuid.UUID]*models.Job
+
+	mu      sync.Mutex
+	batches [][]uuid.UUID
+}
+
+func (b *jobBoard) GetJobs(ctx context.Context, ids []uuid.UUID) ([]*models.Job, error) {
+	b.mu.Lock()
+	b.batches = append(b.batches, append([]uuid.UUID(nil), ids...))
+	b.mu.Unlock()
+
+	var jobs []*models.Job
+	for _, id := range ids {
+		if job, ok := b.jobs[id]; ok {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+// pressure is a pressure gauge saturated on demand
+type pressure struct {
+	saturated bool
+}
+
+func (p *pressure) Saturated() bool {
+	return p.saturated
+}
+
+// newCatalog holds products 1 to 3
+func newCatalog() *catalog {
+	products := make(map[int]*models.Product)
+	for _, name := range []string{"Widget", "Gadget", "Gizmo"} {
+		id := len(products) + 1
+		products[id] = &models.Product{ID: id, Name: name}
+	}
+	return &catalog{products: products}
+}
+
+// ordersFor returns an order for each product ID, without the product
+func ordersFor(productIDs ...int) *orderBook {
+	book := &orderBook{}
+	for _, id := range productIDs {
+		book.orders = append(book.orders, &models.Order{ID: uuid.New(), ProductID: id})
+	}
+	return book
+}
+
+// graphQLResponse is the body of a GraphQL response
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+		Path    []any  `json:"path"`
+	} `json:"errors"`
+}
+
+// query posts a GraphQL query to handler
+func query(t *testing.T, handler http.Handler, q string) graphQLResponse {
+	t.Helper()
+	body, err := json.Marshal(map[string]string{"query": q})
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body))))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp graphQLResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestOrderProductsLoadInOneBatch(t *testing.T) {
+	products := newCatalog()
+	handler := NewHandler(&service.Services{Product: products, Order: ordersFor(1, 2, 1, 3, 2)}, &config.LoadShedConfig{})
+
+	resp := query(t, handler, "{ orders { productId product { id name } } }")
+	require.Empty(t, resp.Errors)
+
+	var data struct {
+		Orders []struct {
+			ProductID int32
+			Product   struct {
+				ID   int32
+				Name string
+			}
+		}
+	}
+	require.NoError(t, json.Unmarshal(resp.Data, &data))
+	require.Len(t, data.Orders, 5)
+	for _, order := range data.Orders {
+		assert.Equal(t, order.ProductID, order.Product.ID)
+		assert.Equal(t, products.products[int(order.ProductID)].Name, order.Product.Name)
+	}
+
+	// Every order's product came from a single lookup
+	require.Len(t, products.batches, 1)
+	assert.ElementsMatch(t, []int{1, 2, 3}, unique(products.batches[0]))
+}
+
+func TestOrderProductLoadError(t *testing.T) {
+	products := newCatalog()
+	products.err = errors.New("database unavailable")
+	handler := NewHandler(&service.Services{Product: products, Order: ordersFor(1, 2)}, &config.LoadShedConfig{})
+
+	resp := query(t, handler, "{ orders { productId product { name } } }")
+
+	// Each order reports the failed lookup on its product, which is null
+	require.Len(t, resp.Errors, 2)
+	for _, e := range resp.Errors {
+		assert.Equal(t, "database unavailable", e.Message)
+		assert.Equal(t, "product", e.Path[len(e.Path)-1])
+	}
+
+	var data struct {
+		Orders []struct{ Product *struct{ Name string } }
+	}
+	require.NoError(t, json.Unmarshal(resp.Data, &data))
+	require.Len(t, data.Orders, 2)
+	for _, order := range data.Orders {
+		assert.Nil(t, order.Product)
+	}
+}
+
+func TestProductLoaderConcurrentLoads(t *testing.T) {
+	products := newCatalog()
+	loader := newProductLoader(products)
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	names := make([]string, 30)
+	errs := make([]error, len(names))
+	for i := range names {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			product, err := loader.Load(context.Background(), i%4+1)
+			errs[i] = err
+			if product != nil {
+				names[i] = product.Name
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	for i, name := range names {
+		if i%4+1 == 4 {
+			assert.Error(t, errs[i], "product 4 does not exist")
+			continue
+		}
+		require.NoError(t, errs[i])
+		assert.Equal(t, products.products[i%4+1].Name, name)
+	}
+
+	// Loads released together share batches
+	assert.Less(t, len(products.batches), len(names))
+
+	// A later load is served from what was loaded
+	batches := len(products.batches)
+	product, err := loader.Load(context.Background(), 2)
+	require.NoError(t, err)
+	assert.Equal(t, "Gadget", product.Name)
+	assert.Len(t, products.batches, batches)
+}
+
+func TestSettlementRunJobsAndSettlementsLoadInOneBatch(t *testing.T) {
+	settlements := &ledger{}
+	jobs := &jobBoard{jobs: make(map[uuid.UUID]*models.Job)}
+	for i := 0; i < 4; i++ {
+		run := &models.SettlementRun{ID: uuid.New()}
+		settlements.runs = append(settlements.runs, run)
+		// The last run has neither a job nor settlements
+		if i == 3 {
+			continue
+		}
+
+		job := &models.Job{ID: uuid.New(), Status: models.JobStatusCompleted}
+		jobs.jobs[job.ID] = job
+		run.JobID = &job.ID
+		for n := 0; n <= i; n++ {
+			settlements.settlements = append(settlements.settlements, &models.Settlement{ID: len(settlements.settlements) + 1, UniqueRunID: run.ID})
+		}
+	}
+	handler := NewHandler(&service.Services{Settlement: settlements, Job: jobs}, &config.LoadShedConfig{})
+
+	resp := query(t, handler, "{ settlementRuns { id job { id } settlements { id } } }")
+	require.Empty(t, resp.Errors)
+
+	var data struct {
+		SettlementRuns []struct {
+			ID          string
+			Job         *struct{ ID string }
+			Settlements []struct{ ID int32 }
+		}
+	}
+	require.NoError(t, json.Unmarshal(resp.Data, &data))
+	require.Len(t, data.SettlementRuns, 4)
+	for i, run := range data.SettlementRuns {
+		if i == 3 {
+			assert.Nil(t, run.Job)
+			assert.Empty(t, run.Settlements)
+			continue
+		}
+		require.NotNil(t, run.Job)
+		assert.Equal(t, settlements.runs[i].JobID.String(), run.Job.ID)
+		assert.Len(t, run.Settlements, i+1)
+	}
+
+	// Every run's job and settlements came from a single lookup each
+	require.Len(t, jobs.batches, 1)
+	assert.Len(t, jobs.batches[0], 3)
+	require.Len(t, settlements.batches, 1)
+	assert.Len(t, settlements.batches[0], 4)
+}
+
+func TestListsShedWhileSaturated(t *testing.T) {
+	orders := ordersFor(1)
+	gauge := &pressure{}
+	shed := &config.LoadShedConfig{Enabled: true, MaxLimit: 20, MaxOffset: 1000}
+	handler := NewHandler(&service.Services{Product: newCatalog(), Order: orders, Pressure: gauge}, shed)
+
+	// Outside saturation pages are served as asked
+	resp := query(t, handler, "{ orders(limit: 50, offset: 5000) { id } }")
+	require.Empty(t, resp.Errors)
+	assert.Equal(t, []int{50}, orders.limits)
+
+	gauge.saturated = true
+
+	// Deep pages are rejected
+	resp = query(t, handler, "{ orders(offset: 1001) { id } }")
+	require.Len(t, resp.Errors, 1)
+	assert.Contains(t, resp.Errors[0].Message, "heavy load")
+	assert.Len(t, orders.limits, 1)
+
+	// Page sizes are capped
+	resp = query(t, handler, "{ orders(limit: 50, offset: 1000) { id } }")
+	require.Empty(t, resp.Errors)
+	assert.Equal(t, []int{50, 20}, orders.limits)
+}
+
+// unique returns ids without repeats, in first-seen order
+func unique(ids []int) []int {
+	seen := make(map[int]bool)
+	var result []int
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
@@ -0,0 +1,171 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/models"
+	"indico-backend/internal/service"
+
+	"github.com/google/uuid"
+)
+
+// loaderWait is how long a loader collects keys before issuing a batch query
+const loaderWait = 2 * time.Millisecond
+
+type loadersKey struct{}
+
+// loaders holds the per-request data loaders
+type loaders struct {
+	product        *batchLoader[int, *models.Product]
+	job            *batchLoader[uuid.UUID, *models.Job]
+	runSettlements *batchLoader[uuid.UUID, []*models.Settlement]
+}
+
+// withLoaders attaches fresh data loaders to a request context
+func withLoaders(ctx context.Context, services *service.Services) context.Context {
+	return context.WithValue(ctx, loadersKey{}, &loaders{
+		product:        newProductLoader(services.Product),
+		job:            newJobLoader(services.Job),
+		runSettlements: newRunSettlementsLoader(services.Settlement),
+	})
+}
+
+// loadersFrom returns the data loaders attached to a context
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
+
+// newProductLoader creates a loader of products by ID
+func newProductLoader(products service.ProductService) *batchLoader[int, *models.Product] {
+	return newBatchLoader(func(ctx context.Context, ids []int) (map[int]*models.Product, error) {
+		found, err := products.GetProducts(ctx, ids)
+		byID := make(map[int]*models.Product, len(found))
+		for _, product := range found {
+			byID[product.ID] = product
+		}
+		return byID, err
+	}, errors.ErrProductNotFound)
+}
+
+// newJobLoader creates a loader of jobs by ID
+func newJobLoader(jobs service.JobService) *batchLoader[uuid.UUID, *models.Job] {
+	return newBatchLoader(func(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*models.Job, error) {
+		found, err := jobs.GetJobs(ctx, ids)
+		byID := make(map[uuid.UUID]*models.Job, len(found))
+		for _, job := range found {
+			byID[job.ID] = job
+		}
+		return byID, err
+	}, errors.ErrJobNotFound)
+}
+
+// newRunSettlementsLoader creates a loader of settlement runs' settlements
+// by run ID; a run without settlements loads as an empty list
+func newRunSettlementsLoader(settlements service.SettlementService) *batchLoader[uuid.UUID, []*models.Settlement] {
+	return newBatchLoader(func(ctx context.Context, runIDs []uuid.UUID) (map[uuid.UUID][]*models.Settlement, error) {
+		found, err := settlements.ListRunsSettlements(ctx, runIDs)
+		byRun := make(map[uuid.UUID][]*models.Settlement, len(runIDs))
+		for _, settlement := range found {
+			byRun[settlement.UniqueRunID] = append(byRun[settlement.UniqueRunID], settlement)
+		}
+		return byRun, err
+	}, nil)
+}
+
+// batchLoader batches the lookups of one kind made while resolving a single
+// request: keys requested within loaderWait of each other are fetched with
+// one call
+type batchLoader[K comparable, V any] struct {
+	fetch func(ctx context.Context, keys []K) (map[K]V, error)
+	// missing is returned for a key fetch finds nothing for; when nil the
+	// zero value is returned instead
+	missing error
+
+	mu      sync.Mutex
+	batch   *loaderBatch[K]
+	results map[K]V
+}
+
+// loaderBatch is a set of keys being loaded together
+type loaderBatch[K comparable] struct {
+	keys []K
+	done chan struct{}
+	err  error
+}
+
+// newBatchLoader creates a loader fetching batches of keys with fetch
+func newBatchLoader[K comparable, V any](fetch func(ctx context.Context, keys []K) (map[K]V, error), missing error) *batchLoader[K, V] {
+	return &batchLoader[K, V]{
+		fetch:   fetch,
+		missing: missing,
+		results: make(map[K]V),
+	}
+}
+
+// Load returns the value for key, batching concurrent calls
+func (l *batchLoader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	var zero V
+
+	l.mu.Lock()
+	if value, ok := l.results[key]; ok {
+		l.mu.Unlock()
+		return value, nil
+	}
+
+	batch := l.batch
+	if batch == nil {
+		batch = &loaderBatch[K]{done: make(chan struct{})}
+		l.batch = batch
+		time.AfterFunc(loaderWait, func() { l.dispatch(ctx, batch) })
+	}
+	batch.keys = append(batch.keys, key)
+	l.mu.Unlock()
+
+	<-batch.done
+	if batch.err != nil {
+		return zero, batch.err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	value, ok := l.results[key]
+	if !ok {
+		return zero, l.missing
+	}
+	return value, nil
+}
+
+// dispatch loads all keys in a batch with a single fetch
+func (l *batchLoader[K, V]) dispatch(ctx context.Context, batch *loaderBatch[K]) {
+	l.mu.Lock()
+	if l.batch == batch {
+		l.batch = nil
+	}
+	keys := batch.keys
+	l.mu.Unlock()
+
+	values, err := l.fetch(ctx, keys)
+
+	l.mu.Lock()
+	for key, value := range values {
+		l.results[key] = value
+	}
+	// Keys without a value are remembered as the zero value unless they are
+	// an error, so they aren't fetched again
+	if err == nil && l.missing == nil {
+		var zero V
+		for _, key := range keys {
+			if _, ok := l.results[key]; !ok {
+				l.results[key] = zero
+			}
+		}
+	}
+	l.mu.Unlock()
+
+	batch.err = err
+	close(batch.done)
+}
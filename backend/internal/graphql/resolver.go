@@ -0,0 +1,296 @@
+package graphql
+
+import (
+	"context"
+	"time"
+
+	"indico-backend/internal/config"
+	"indico-backend/internal/errors"
+	"indico-backend/internal/metrics"
+	"indico-backend/internal/models"
+	"indico-backend/internal/service"
+
+	"github.com/google/uuid"
+	gqlgo "github.com/graph-gophers/graphql-go"
+)
+
+// resolver is the root query resolver
+type resolver struct {
+	services *service.Services
+	shed     *config.LoadShedConfig
+}
+
+// pageArgs holds common pagination arguments
+type pageArgs struct {
+	Limit  int32
+	Offset int32
+}
+
+// page returns the limit and offset to list a page with. While the database
+// is saturated, pages past the max offset are rejected and page sizes are
+// capped at the max limit, as the LoadShed middleware does for REST lists.
+func (r *resolver) page(args pageArgs) (limit, offset int, err error) {
+	limit, offset = int(args.Limit), int(args.Offset)
+
+	pressure := r.services.Pressure
+	if r.shed == nil || !r.shed.Enabled || pressure == nil || !pressure.Saturated() {
+		return limit, offset, nil
+	}
+
+	if offset > r.shed.MaxOffset {
+		metrics.RequestsShed.WithLabelValues("rejected").Inc()
+		return 0, 0, errors.NewLoadShedError(r.shed.MaxOffset, r.shed.Cooldown)
+	}
+	if limit <= 0 || limit > r.shed.MaxLimit {
+		metrics.RequestsShed.WithLabelValues("clamped").Inc()
+		limit = r.shed.MaxLimit
+	}
+	return limit, offset, nil
+}
+
+// idArgs holds a UUID-based ID argument
+type idArgs struct {
+	ID gqlgo.ID
+}
+
+func (r *resolver) Product(ctx context.Context, args struct{ ID int32 }) (*productResolver, error) {
+	product, err := loadersFrom(ctx).product.Load(ctx, int(args.ID))
+	if err != nil {
+		return nil, err
+	}
+	return &productResolver{product}, nil
+}
+
+func (r *resolver) Products(ctx context.Context, args pageArgs) ([]*productResolver, error) {
+	limit, offset, err := r.page(args)
+	if err != nil {
+		return nil, err
+	}
+
+	products, err := r.services.Product.ListProducts(ctx, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	resolvers := make([]*productResolver, len(products))
+	for i, product := range products {
+		resolvers[i] = &productResolver{product}
+	}
+	return resolvers, nil
+}
+
+func (r *resolver) Order(ctx context.Context, args idArgs) (*orderResolver, error) {
+	id, err := uuid.Parse(string(args.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := r.services.Order.GetOrder(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &orderResolver{order}, nil
+}
+
+func (r *resolver) Orders(ctx context.Context, args pageArgs) ([]*orderResolver, error) {
+	limit, offset, err := r.page(args)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := r.services.Order.ListOrders(ctx, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	resolvers := make([]*orderResolver, len(orders))
+	for i, order := range orders {
+		resolvers[i] = &orderResolver{order}
+	}
+	return resolvers, nil
+}
+
+func (r *resolver) Job(ctx context.Context, args idArgs) (*jobResolver, error) {
+	id, err := uuid.Parse(string(args.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := r.services.Job.GetJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &jobResolver{job}, nil
+}
+
+func (r *resolver) SettlementRun(ctx context.Context, args idArgs) (*settlementRunResolver, error) {
+	id, err := uuid.Parse(string(args.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	run, err := r.services.Settlement.GetRun(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &settlementRunResolver{run: run}, nil
+}
+
+func (r *resolver) LatestSettlementRun(ctx context.Context) (*settlementRunResolver, error) {
+	run, err := r.services.Settlement.GetLatestRun(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &settlementRunResolver{run: run}, nil
+}
+
+func (r *resolver) SettlementRuns(ctx context.Context, args pageArgs) ([]*settlementRunResolver, error) {
+	limit, offset, err := r.page(args)
+	if err != nil {
+		return nil, err
+	}
+
+	runs, err := r.services.Settlement.ListRuns(ctx, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	resolvers := make([]*settlementRunResolver, len(runs))
+	for i, run := range runs {
+		resolvers[i] = &settlementRunResolver{run: run}
+	}
+	return resolvers, nil
+}
+
+// productResolver resolves Product fields
+type productResolver struct {
+	p *models.Product
+}
+
+func (r *productResolver) ID() int32         { return int32(r.p.ID) }
+func (r *productResolver) Name() string      { return r.p.Name }
+func (r *productResolver) SKU() *string      { return r.p.SKU }
+func (r *productResolver) Barcode() *string  { return r.p.Barcode }
+func (r *productResolver) Stock() int32      { return int32(r.p.Stock) }
+func (r *productResolver) Price() int32      { return int32(r.p.Price) }
+func (r *productResolver) Version() int32    { return int32(r.p.Version) }
+func (r *productResolver) CreatedAt() string { return formatTime(r.p.CreatedAt) }
+func (r *productResolver) UpdatedAt() string { return formatTime(r.p.UpdatedAt) }
+
+// orderResolver resolves Order fields
+type orderResolver struct {
+	o *models.Order
+}
+
+func (r *orderResolver) ID() gqlgo.ID             { return gqlgo.ID(r.o.ID.String()) }
+func (r *orderResolver) ProductID() int32         { return int32(r.o.ProductID) }
+func (r *orderResolver) BuyerID() string          { return r.o.BuyerID }
+func (r *orderResolver) Quantity() int32          { return int32(r.o.Quantity) }
+func (r *orderResolver) Status() string           { return string(r.o.Status) }
+func (r *orderResolver) TotalCents() int32        { return int32(r.o.TotalCents) }
+func (r *orderResolver) ClientReference() *string { return r.o.ClientReference }
+func (r *orderResolver) CreatedAt() string        { return formatTime(r.o.CreatedAt) }
+func (r *orderResolver) UpdatedAt() string        { return formatTime(r.o.UpdatedAt) }
+
+func (r *orderResolver) Product(ctx context.Context) (*productResolver, error) {
+	if r.o.Product != nil {
+		return &productResolver{r.o.Product}, nil
+	}
+
+	// Batch product lookups across all orders in the response
+	product, err := loadersFrom(ctx).product.Load(ctx, r.o.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	return &productResolver{product}, nil
+}
+
+// jobResolver resolves Job fields
+type jobResolver struct {
+	j *models.Job
+}
+
+func (r *jobResolver) ID() gqlgo.ID         { return gqlgo.ID(r.j.ID.String()) }
+func (r *jobResolver) Type() string         { return string(r.j.Type) }
+func (r *jobResolver) Status() string       { return string(r.j.Status) }
+func (r *jobResolver) Progress() float64    { return r.j.Progress }
+func (r *jobResolver) Processed() int32     { return int32(r.j.Processed) }
+func (r *jobResolver) Total() int32         { return int32(r.j.Total) }
+func (r *jobResolver) DownloadURL() *string { return r.j.DownloadURL }
+func (r *jobResolver) Error() *string       { return r.j.Error }
+func (r *jobResolver) CreatedAt() string    { return formatTime(r.j.CreatedAt) }
+func (r *jobResolver) UpdatedAt() string    { return formatTime(r.j.UpdatedAt) }
+
+// settlementRunResolver resolves SettlementRun fields
+type settlementRunResolver struct {
+	run *models.SettlementRun
+}
+
+func (r *settlementRunResolver) ID() gqlgo.ID           { return gqlgo.ID(r.run.ID.String()) }
+func (r *settlementRunResolver) From() string           { return formatDate(r.run.From) }
+func (r *settlementRunResolver) To() string             { return formatDate(r.run.To) }
+func (r *settlementRunResolver) SettlementCount() int32 { return int32(r.run.SettlementCount) }
+func (r *settlementRunResolver) CreatedAt() string      { return formatTime(r.run.CreatedAt) }
+
+func (r *settlementRunResolver) Job(ctx context.Context) (*jobResolver, error) {
+	if r.run.JobID == nil {
+		return nil, nil
+	}
+
+	// Batch job lookups across all runs in the response
+	job, err := loadersFrom(ctx).job.Load(ctx, *r.run.JobID)
+	if err != nil {
+		return nil, err
+	}
+	return &jobResolver{job}, nil
+}
+
+func (r *settlementRunResolver) Settlements(ctx context.Context) ([]*settlementResolver, error) {
+	settlements := r.run.Settlements
+	if settlements == nil {
+		// Batch settlement lookups across all runs in the response
+		var err error
+		settlements, err = loadersFrom(ctx).runSettlements.Load(ctx, r.run.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resolvers := make([]*settlementResolver, len(settlements))
+	for i, settlement := range settlements {
+		resolvers[i] = &settlementResolver{settlement}
+	}
+	return resolvers, nil
+}
+
+// settlementResolver resolves Settlement fields
+type settlementResolver struct {
+	s *models.Settlement
+}
+
+func (r *settlementResolver) ID() int32           { return int32(r.s.ID) }
+func (r *settlementResolver) MerchantID() string  { return r.s.MerchantID }
+func (r *settlementResolver) Date() string        { return formatDate(r.s.Date) }
+func (r *settlementResolver) GrossCents() int32   { return int32(r.s.GrossCents) }
+func (r *settlementResolver) FeeCents() int32     { return int32(r.s.FeeCents) }
+func (r *settlementResolver) NetCents() int32     { return int32(r.s.NetCents) }
+func (r *settlementResolver) TxnCount() int32     { return int32(r.s.TxnCount) }
+func (r *settlementResolver) GeneratedAt() string { return formatTime(r.s.GeneratedAt) }
+
+func (r *settlementResolver) SupersededBy() *gqlgo.ID {
+	if r.s.SupersededBy == nil {
+		return nil
+	}
+	id := gqlgo.ID(r.s.SupersededBy.String())
+	return &id
+}
+
+// formatTime formats a timestamp for GraphQL output
+func formatTime(t time.Time) string {
+	return t.Format(time.RFC3339)
+}
+
+// formatDate formats a date for GraphQL output
+func formatDate(t time.Time) string {
+	return t.Format("2006-01-02")
+}
@@ -0,0 +1,80 @@
+// Package graphql provides the GraphQL API gateway
+package graphql
+
+// schema defines the GraphQL schema exposed at /graphql
+const schema = `
+schema {
+	query: Query
+}
+
+type Query {
+	product(id: Int!): Product
+	products(limit: Int = 10, offset: Int = 0): [Product!]!
+	order(id: ID!): Order
+	orders(limit: Int = 10, offset: Int = 0): [Order!]!
+	job(id: ID!): Job
+	settlementRun(id: ID!): SettlementRun
+	latestSettlementRun: SettlementRun
+	settlementRuns(limit: Int = 10, offset: Int = 0): [SettlementRun!]!
+}
+
+type Product {
+	id: Int!
+	name: String!
+	sku: String
+	barcode: String
+	stock: Int!
+	price: Int!
+	version: Int!
+	createdAt: String!
+	updatedAt: String!
+}
+
+type Order {
+	id: ID!
+	productId: Int!
+	buyerId: String!
+	quantity: Int!
+	status: String!
+	totalCents: Int!
+	clientReference: String
+	createdAt: String!
+	updatedAt: String!
+	product: Product
+}
+
+type Job {
+	id: ID!
+	type: String!
+	status: String!
+	progress: Float!
+	processed: Int!
+	total: Int!
+	downloadUrl: String
+	error: String
+	createdAt: String!
+	updatedAt: String!
+}
+
+type SettlementRun {
+	id: ID!
+	from: String!
+	to: String!
+	settlementCount: Int!
+	createdAt: String!
+	job: Job
+	settlements: [Settlement!]!
+}
+
+type Settlement {
+	id: Int!
+	merchantId: String!
+	date: String!
+	grossCents: Int!
+	feeCents: Int!
+	netCents: Int!
+	txnCount: Int!
+	generatedAt: String!
+	supersededBy: ID
+}
+`
@@ -0,0 +1,29 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListAuthBans handles GET /admin/auth/bans
+func (h *Handlers) ListAuthBans(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	bans, err := h.services.Auth.ListAuthBans(ctx, limit, offset)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"bans":   bans,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
@@ -0,0 +1,101 @@
+package handlers_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"indico-backend/internal/config"
+	"indico-backend/internal/handlers"
+	"indico-backend/internal/models"
+	"indico-backend/internal/routes"
+	"indico-backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// banLog is an auth ban repository keeping bans in memory
+type banLog struct {
+	bans []*models.AuthBan
+}
+
+func (l *banLog) Record(ctx context.Context, ban *models.AuthBan) error {
+	l.bans = append(l.bans, ban)
+	return nil
+}
+
+func (l *banLog) List(ctx context.Context, limit, offset int) ([]*models.AuthBan, error) {
+	return l.bans, nil
+}
+
+const adminToken = "admin-token-0123456789abcdef"
+
+// newAuthRouter serves the routes with an auth guard banning after three
+// failures, and trusting trustedProxies for X-Forwarded-For
+func newAuthRouter(t *testing.T, trustedProxies []string) (*gin.Engine, *banLog) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		Server: config.ServerConfig{TrustedProxies: trustedProxies},
+		Admin:  config.AdminConfig{Token: adminToken},
+	}
+	log := &banLog{}
+	services := &service.Services{
+		Auth:      service.NewAuthService(&service.Dependencies{AuthBanRepo: log}),
+		AuthGuard: service.NewAuthGuard(&config.AuthConfig{MaxFailures: 3, Window: time.Minute, BanDuration: time.Hour}, log),
+	}
+	return routes.SetupRoutes(handlers.New(services, cfg)), log
+}
+
+// adminRequest calls an admin endpoint from remoteAddr, with forwardedFor
+// in X-Forwarded-For unless empty
+func adminRequest(router http.Handler, remoteAddr, forwardedFor, token string) int {
+	req := httptest.NewRequest(http.MethodGet, "/admin/auth/bans", nil)
+	req.RemoteAddr = remoteAddr
+	req.Header.Set(handlers.AdminTokenHeader, token)
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestAuthBanIgnoresSpoofedForwardedFor(t *testing.T) {
+	router, log := newAuthRouter(t, nil)
+
+	// An attacker rotating X-Forwarded-For, which names the victim last
+	for i := 0; i < 3; i++ {
+		code := adminRequest(router, "198.51.100.9:4000", fmt.Sprintf("10.0.0.%d, 203.0.113.7", i), fmt.Sprintf("guess-%d", i))
+		require.Equal(t, http.StatusUnauthorized, code)
+	}
+
+	// The ban lands on the attacker's own address, not on a header value
+	require.NotEmpty(t, log.bans)
+	assert.Equal(t, "198.51.100.9", log.bans[0].Subject)
+	assert.Equal(t, http.StatusTooManyRequests, adminRequest(router, "198.51.100.9:4001", "10.9.9.9", adminToken))
+
+	// The victim named in the spoofed header is unaffected
+	assert.Equal(t, http.StatusOK, adminRequest(router, "203.0.113.7:5000", "", adminToken))
+}
+
+func TestAuthBanTrustsConfiguredProxies(t *testing.T) {
+	router, log := newAuthRouter(t, []string{"10.1.0.0/16"})
+
+	// Behind the trusted proxy, the forwarded client is banned, not the proxy
+	for i := 0; i < 3; i++ {
+		adminRequest(router, "10.1.0.5:4000", "198.51.100.9", fmt.Sprintf("guess-%d", i))
+	}
+
+	require.NotEmpty(t, log.bans)
+	assert.Equal(t, "198.51.100.9", log.bans[0].Subject)
+	assert.Equal(t, http.StatusTooManyRequests, adminRequest(router, "10.1.0.5:4001", "198.51.100.9", adminToken))
+	assert.Equal(t, http.StatusOK, adminRequest(router, "10.1.0.5:4002", "203.0.113.7", adminToken))
+}
@@ -0,0 +1,50 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"net/http"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListBackfills handles GET /admin/backfills
+func (h *Handlers) ListBackfills(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	backfills, err := h.services.Job.ListBackfills(ctx)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"backfills": backfills,
+	})
+}
+
+// CreateBackfillJob handles POST /admin/backfills
+func (h *Handlers) CreateBackfillJob(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.CreateBackfillJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
+		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	job, err := h.services.Job.CreateBackfillJob(ctx, &req)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"job_id": job.ID,
+		"status": job.Status,
+	})
+}
@@ -0,0 +1,65 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetBackorderLimit handles PUT /products/:id/backorder-limit
+func (h *Handlers) SetBackorderLimit(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		h.respondWithError(c, errors.NewValidationError("Invalid product ID"))
+		return
+	}
+
+	var req models.SetBackorderLimitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
+		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	product, err := h.services.Product.SetBackorderLimit(ctx, id, *req.BackorderLimit)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, product)
+}
+
+// CreateBackorderFulfillmentJob handles POST /jobs/backorder-fulfillment. The
+// body is optional; without one every product with backorders is covered.
+func (h *Handlers) CreateBackorderFulfillmentJob(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.CreateBackorderFulfillmentJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
+		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	job, err := h.services.Job.CreateBackorderFulfillmentJob(ctx, &req)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"job_id": job.ID,
+		"status": job.Status,
+		"total":  job.Total,
+	})
+}
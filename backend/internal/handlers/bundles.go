@@ -0,0 +1,107 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateBundle handles POST /bundles
+func (h *Handlers) CreateBundle(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.CreateBundleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
+		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	bundle, err := h.services.Bundle.CreateBundle(ctx, &req)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, bundle)
+}
+
+// ListBundles handles GET /bundles
+func (h *Handlers) ListBundles(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	bundles, err := h.services.Bundle.ListBundles(ctx, limit, offset)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"bundles": bundles,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// GetBundle handles GET /bundles/:id
+func (h *Handlers) GetBundle(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	id, ok := parseBundleID(h, c)
+	if !ok {
+		return
+	}
+
+	bundle, err := h.services.Bundle.GetBundle(ctx, id)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, bundle)
+}
+
+// UpdateBundle handles PUT /bundles/:id
+func (h *Handlers) UpdateBundle(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	id, ok := parseBundleID(h, c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateBundleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
+		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	bundle, err := h.services.Bundle.UpdateBundle(ctx, id, &req)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, bundle)
+}
+
+// parseBundleID reads the bundle ID path parameter, answering 400 when it
+// is not a positive integer
+func parseBundleID(h *Handlers, c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		h.respondWithError(c, errors.NewValidationError("Invalid bundle ID"))
+		return 0, false
+	}
+	return id, true
+}
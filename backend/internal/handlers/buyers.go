@@ -0,0 +1,24 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EraseBuyerData handles DELETE /buyers/:id/data
+func (h *Handlers) EraseBuyerData(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	job, err := h.services.Job.CreateBuyerErasureJob(ctx, c.Param("id"))
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"job_id": job.ID,
+		"status": job.Status,
+	})
+}
@@ -0,0 +1,340 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"indico-backend/internal/config"
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+	"indico-backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// DebugPayloadHeader requests payload logging for a single request; it is
+	// only honoured together with a valid admin token
+	DebugPayloadHeader = "X-Debug-Payload"
+	// AdminTokenHeader carries the admin token
+	AdminTokenHeader = "X-Admin-Token"
+
+	redactedValue = "[REDACTED]"
+)
+
+// redactedHeaders are never logged verbatim
+var redactedHeaders = map[string]bool{
+	"authorization": true,
+	"cookie":        true,
+	"set-cookie":    true,
+	"x-admin-token": true,
+}
+
+// payloadDebugger decides which requests have their payloads logged and
+// redacts what is logged
+type payloadDebugger struct {
+	// enabled is false when the profile turns debug endpoints off, in which
+	// case nothing is ever logged
+	enabled bool
+
+	mu     sync.RWMutex
+	routes map[string]bool
+
+	redact       map[string]bool
+	maxBodyBytes int
+	adminToken   string
+}
+
+// newPayloadDebugger creates a payload debugger from configuration
+func newPayloadDebugger(debugCfg *config.DebugConfig, adminCfg *config.AdminConfig) *payloadDebugger {
+	d := &payloadDebugger{
+		enabled:      debugCfg.Enabled,
+		routes:       make(map[string]bool),
+		redact:       make(map[string]bool),
+		maxBodyBytes: debugCfg.MaxBodyBytes,
+		adminToken:   adminCfg.Token,
+	}
+
+	for _, route := range debugCfg.PayloadRoutes {
+		d.routes[route] = true
+	}
+	for _, field := range debugCfg.RedactFields {
+		d.redact[strings.ToLower(field)] = true
+	}
+
+	return d
+}
+
+// isAdmin reports whether the request carries the configured admin token
+func (d *payloadDebugger) isAdmin(c *gin.Context) bool {
+	if d.adminToken == "" {
+		return false
+	}
+	token := c.GetHeader(AdminTokenHeader)
+	return subtle.ConstantTimeCompare([]byte(token), []byte(d.adminToken)) == 1
+}
+
+// enabledFor reports whether payload logging applies to the request
+func (d *payloadDebugger) enabledFor(c *gin.Context) bool {
+	if !d.enabled {
+		return false
+	}
+	if c.GetHeader(DebugPayloadHeader) == "true" && d.isAdmin(c) {
+		return true
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.routes[c.FullPath()]
+}
+
+// setRoutes enables or disables payload logging for route patterns
+func (d *payloadDebugger) setRoutes(routes []string, enabled bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, route := range routes {
+		if enabled {
+			d.routes[route] = true
+		} else {
+			delete(d.routes, route)
+		}
+	}
+}
+
+// enabledRoutes returns the route patterns with payload logging enabled
+func (d *payloadDebugger) enabledRoutes() []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	routes := make([]string, 0, len(d.routes))
+	for route := range d.routes {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	return routes
+}
+
+// redactBody returns a loggable form of a body. JSON bodies are logged with
+// sensitive fields redacted; anything else, or a body over the size cap, is
+// omitted since it cannot be redacted reliably.
+func (d *payloadDebugger) redactBody(body []byte, truncated bool) interface{} {
+	if len(body) == 0 {
+		return nil
+	}
+	if truncated {
+		return "[omitted: body exceeds size cap]"
+	}
+
+	var payload interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return "[omitted: non-JSON body]"
+	}
+
+	return d.redactValue(payload)
+}
+
+func (d *payloadDebugger) redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if d.redact[strings.ToLower(key)] {
+				v[key] = redactedValue
+			} else {
+				v[key] = d.redactValue(field)
+			}
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = d.redactValue(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// redactHeaders flattens headers for logging, redacting credentials
+func (d *payloadDebugger) redactHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for name, values := range header {
+		if redactedHeaders[strings.ToLower(name)] {
+			headers[name] = redactedValue
+			continue
+		}
+		headers[name] = strings.Join(values, ", ")
+	}
+	return headers
+}
+
+// readCapped reads up to limit bytes of body and returns a replacement
+// reader that still yields the full body to handlers
+func readCapped(body io.ReadCloser, limit int) ([]byte, bool, io.ReadCloser, error) {
+	head, err := io.ReadAll(io.LimitReader(body, int64(limit)+1))
+	if err != nil {
+		return nil, false, body, err
+	}
+
+	truncated := len(head) > limit
+	restored := struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), body), body}
+
+	return head, truncated, restored, nil
+}
+
+// bodyCaptureWriter records up to limit bytes of the response body
+type bodyCaptureWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (w *bodyCaptureWriter) capture(p []byte) {
+	if remaining := w.limit - w.body.Len(); remaining < len(p) {
+		w.truncated = true
+		if remaining > 0 {
+			w.body.Write(p[:remaining])
+		}
+		return
+	}
+	w.body.Write(p)
+}
+
+func (w *bodyCaptureWriter) Write(p []byte) (int, error) {
+	w.capture(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *bodyCaptureWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// PayloadLogger middleware logs redacted request and response bodies for
+// routes with payload logging enabled, or for admin requests that ask for it
+func (h *Handlers) PayloadLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !h.debugger.enabledFor(c) {
+			c.Next()
+			return
+		}
+
+		log := logger.WithContext(c.Request.Context())
+
+		var reqBody []byte
+		var reqTruncated bool
+		if c.Request.Body != nil {
+			var err error
+			reqBody, reqTruncated, c.Request.Body, err = readCapped(c.Request.Body, h.debugger.maxBodyBytes)
+			if err != nil {
+				log.WithError(err).Warn("Failed to read request body for payload logging")
+			}
+		}
+
+		writer := &bodyCaptureWriter{ResponseWriter: c.Writer, limit: h.debugger.maxBodyBytes}
+		c.Writer = writer
+
+		c.Next()
+
+		log.WithFields(map[string]interface{}{
+			"method":           c.Request.Method,
+			"route":            c.FullPath(),
+			"path":             c.Request.URL.Path,
+			"status":           c.Writer.Status(),
+			"request_headers":  h.debugger.redactHeaders(c.Request.Header),
+			"request_body":     h.debugger.redactBody(reqBody, reqTruncated),
+			"response_headers": h.debugger.redactHeaders(writer.Header()),
+			"response_body":    h.debugger.redactBody(writer.body.Bytes(), writer.truncated),
+		}).Info("HTTP payload")
+	}
+}
+
+// AdminOnly middleware restricts a route to requests with the admin token.
+// Clients and tokens banned for repeated wrong tokens are turned away before
+// the token is checked.
+func (h *Handlers) AdminOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if h.debugger.adminToken == "" {
+			h.respondWithError(c, errors.ErrAdminDisabled)
+			c.Abort()
+			return
+		}
+
+		token := c.GetHeader(AdminTokenHeader)
+		if err := h.services.AuthGuard.Check(c.ClientIP(), token, time.Now()); err != nil {
+			h.respondWithError(c, err)
+			c.Abort()
+			return
+		}
+		if !h.debugger.isAdmin(c) {
+			// A missing token is not a guess
+			if token != "" {
+				h.services.AuthGuard.Fail(c.Request.Context(), service.AuthCredentialAdminToken, c.ClientIP(), token, time.Now())
+			}
+			h.respondWithError(c, errors.ErrUnauthorized)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// GetPayloadLogging handles GET /admin/debug/payload-logging
+func (h *Handlers) GetPayloadLogging(c *gin.Context) {
+	if !h.debugger.enabled {
+		h.respondWithError(c, errors.ErrDebugDisabled)
+		return
+	}
+
+	redactFields := make([]string, 0, len(h.debugger.redact))
+	for field := range h.debugger.redact {
+		redactFields = append(redactFields, field)
+	}
+	sort.Strings(redactFields)
+
+	c.JSON(http.StatusOK, gin.H{
+		"routes":         h.debugger.enabledRoutes(),
+		"redact_fields":  redactFields,
+		"max_body_bytes": h.debugger.maxBodyBytes,
+	})
+}
+
+// SetPayloadLogging handles PUT /admin/debug/payload-logging
+func (h *Handlers) SetPayloadLogging(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	if !h.debugger.enabled {
+		h.respondWithError(c, errors.ErrDebugDisabled)
+		return
+	}
+
+	var req models.SetPayloadLoggingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
+		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	h.debugger.setRoutes(req.Routes, *req.Enabled)
+
+	logger.WithContext(ctx).
+		WithField("routes", req.Routes).
+		WithField("enabled", *req.Enabled).
+		Warn("Payload logging toggled")
+
+	h.GetPayloadLogging(c)
+}
@@ -0,0 +1,52 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"net/http"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/events"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListEventSchemas handles GET /events/schemas
+func (h *Handlers) ListEventSchemas(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"events": events.Catalog(),
+	})
+}
+
+// GetEventSchema handles GET /events/schemas/:type
+func (h *Handlers) GetEventSchema(c *gin.Context) {
+	def, ok := events.Lookup(c.Param("type"))
+	if !ok {
+		h.respondWithError(c, errors.ErrEventTypeNotFound)
+		return
+	}
+
+	// Schemas only change with a new event version, so clients may cache them
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.Header("Content-Type", events.SchemaContentType)
+	c.JSON(http.StatusOK, def.Schema())
+}
+
+// ListErrors handles GET /errors
+func (h *Handlers) ListErrors(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.JSON(http.StatusOK, gin.H{
+		"errors": errors.Catalog(),
+	})
+}
+
+// GetError handles GET /errors/:type, the target of problem type URIs
+func (h *Handlers) GetError(c *gin.Context) {
+	entry, ok := errors.Lookup(c.Param("type"))
+	if !ok {
+		h.respondWithError(c, errors.ErrErrorTypeNotFound)
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.JSON(http.StatusOK, entry)
+}
@@ -3,226 +3,1253 @@ package handlers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math"
 	"net/http"
-	"os"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
+	"indico-backend/internal/config"
 	"indico-backend/internal/errors"
+	"indico-backend/internal/graphql"
+	"indico-backend/internal/i18n"
 	"indico-backend/internal/logger"
 	"indico-backend/internal/metrics"
 	"indico-backend/internal/models"
+	"indico-backend/internal/parquet"
 	"indico-backend/internal/service"
+	"indico-backend/internal/validation"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // Handlers contains all HTTP handlers
 type Handlers struct {
-	services *service.Services
+	services        *service.Services
+	graphql         *graphql.Handler
+	debugger        *payloadDebugger
+	shedder         *loadShedder
+	webhook         *config.WebhookConfig
+	sandbox         *sandboxRouter
+	security        *securityHeaders
+	corsOrigins     map[string]bool
+	requestTimeout  time.Duration
+	downloadTimeout time.Duration
+	// internal is set when the admin API and metrics are served on the
+	// internal listener instead of the public port
+	internal       bool
+	trustedProxies []string
 }
 
 // New creates a new handlers instance
-func New(services *service.Services) *Handlers {
-	return &Handlers{
+func New(services *service.Services, cfg *config.Config) *Handlers {
+	validation.RegisterBinding()
+
+	h := &Handlers{
 		services: services,
+		graphql:  graphql.NewHandler(services, &cfg.LoadShed),
+		debugger: newPayloadDebugger(&cfg.Debug, &cfg.Admin),
+		shedder:  newLoadShedder(&cfg.LoadShed),
+		webhook:  &cfg.Webhook,
+		sandbox:  &sandboxRouter{keys: cfg.Sandbox.Keys},
+		security: newSecurityHeaders(&cfg.Server),
+
+		corsOrigins: make(map[string]bool, len(cfg.Server.CORSAllowedOrigins)),
+
+		requestTimeout:  cfg.Server.RequestTimeout,
+		downloadTimeout: cfg.Server.DownloadTimeout,
+		internal:        cfg.Internal.Enabled(),
+		trustedProxies:  cfg.Server.TrustedProxies,
+	}
+	for _, origin := range cfg.Server.CORSAllowedOrigins {
+		h.corsOrigins[origin] = true
+	}
+	return h
+}
+
+// TrustedProxies lists the proxies whose X-Forwarded-For header names the
+// client, and so decides the client IP that bans and limits apply to
+func (h *Handlers) TrustedProxies() []string {
+	return h.trustedProxies
+}
+
+// ServesInternal reports whether the admin API and metrics belong on the
+// internal listener rather than the public port
+func (h *Handlers) ServesInternal() bool {
+	return h.internal
+}
+
+// Product handlers
+
+// GetProduct handles GET /products/:id
+func (h *Handlers) GetProduct(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		h.respondWithError(c, errors.NewValidationError("Invalid product ID"))
+		return
+	}
+
+	product, err := h.services.Product.GetProduct(ctx, id)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	h.respondWithConditionalJSON(c, product, product.UpdatedAt, cacheControlRevalidate)
+}
+
+// GetProductBySKU handles GET /products/by-sku/:sku
+func (h *Handlers) GetProductBySKU(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	sku := strings.TrimSpace(c.Param("sku"))
+	if sku == "" {
+		h.respondWithError(c, errors.NewValidationError("Invalid SKU"))
+		return
+	}
+
+	product, err := h.services.Product.GetProductBySKU(ctx, sku)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	h.respondWithConditionalJSON(c, product, product.UpdatedAt, cacheControlRevalidate)
+}
+
+// GetProductSnapshot handles GET /products/snapshot
+func (h *Handlers) GetProductSnapshot(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	products, err := h.services.Product.GetSnapshot(ctx, c.Query("updated_since"), limit, offset)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"products": products,
+		"limit":    limit,
+		"offset":   offset,
+	})
+}
+
+// ListProducts handles GET /products
+func (h *Handlers) ListProducts(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	products, err := h.services.Product.ListProducts(ctx, limit, offset)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"products": products,
+		"limit":    limit,
+		"offset":   offset,
+	})
+}
+
+// CreateProduct handles POST /products
+func (h *Handlers) CreateProduct(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.CreateProductRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
+		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	product, err := h.services.Product.CreateProduct(ctx, &req)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, product)
+}
+
+// UpdateProduct handles PUT /products/:id
+func (h *Handlers) UpdateProduct(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		h.respondWithError(c, errors.NewValidationError("Invalid product ID"))
+		return
+	}
+
+	var req models.UpdateProductRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
+		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	product, err := h.services.Product.UpdateProduct(ctx, id, &req)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, product)
+}
+
+// DeleteProduct handles DELETE /products/:id
+func (h *Handlers) DeleteProduct(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		h.respondWithError(c, errors.NewValidationError("Invalid product ID"))
+		return
+	}
+
+	if err := h.services.Product.DeleteProduct(ctx, id); err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Order handlers
+
+// CreateOrder handles POST /orders
+func (h *Handlers) CreateOrder(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.CreateOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
+		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	order, err := h.services.Order.CreateOrder(ctx, &req)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	metrics.OrdersCreated.Inc()
+
+	c.JSON(orderCreatedStatus(order), order)
+}
+
+// orderCreatedStatus is 201 for a placed order and 202 for one the stock
+// fast path accepted but has yet to place
+func orderCreatedStatus(order *models.Order) int {
+	if order.Status == models.OrderStatusPending {
+		return http.StatusAccepted
+	}
+	return http.StatusCreated
+}
+
+// BulkCreateOrders handles POST /orders/bulk
+func (h *Handlers) BulkCreateOrders(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.BulkCreateOrdersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
+		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	// Each order is placed in its own transaction; failures are reported per item
+	result := errors.NewMultiStatusResponse(len(req.Orders))
+	for i := range req.Orders {
+		item := &req.Orders[i]
+		if err := binding.Validator.ValidateStruct(item); err != nil {
+			result.AddFailure(i, errors.NewValidationError("Invalid order: "+err.Error()))
+			continue
+		}
+
+		order, err := h.services.Order.CreateOrder(ctx, item)
+		if err != nil {
+			result.AddFailure(i, err)
+			continue
+		}
+
+		metrics.OrdersCreated.Inc()
+		result.AddSuccess(i, orderCreatedStatus(order), order)
+	}
+
+	h.respondWithMultiStatus(c, result, http.StatusCreated)
+}
+
+// GetOrder handles GET /orders/:id
+func (h *Handlers) GetOrder(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	idParam := c.Param("id")
+	id, err := uuid.Parse(idParam)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid order ID")
+		h.respondWithError(c, errors.NewValidationError("Invalid order ID"))
+		return
+	}
+
+	order, err := h.services.Order.GetOrder(ctx, id)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// GetOrderEvents handles GET /orders/:id/events
+func (h *Handlers) GetOrderEvents(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.respondWithError(c, errors.NewValidationError("Invalid order ID"))
+		return
+	}
+
+	events, err := h.services.Order.GetOrderEvents(ctx, id)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"order_id": id,
+		"events":   events,
+	})
+}
+
+// CancelOrder handles POST /orders/:id/cancel
+func (h *Handlers) CancelOrder(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.respondWithError(c, errors.NewValidationError("Invalid order ID"))
+		return
+	}
+
+	order, err := h.services.Order.CancelOrder(ctx, id)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	metrics.OrdersCancelled.Inc()
+
+	c.JSON(http.StatusOK, order)
+}
+
+// ListOrders handles GET /orders
+func (h *Handlers) ListOrders(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// Parse query parameters
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	// A client reference matches at most one order
+	if clientReference := c.Query("client_reference"); clientReference != "" {
+		orders := []*models.Order{}
+		order, err := h.services.Order.GetOrderByClientReference(ctx, clientReference)
+		if err != nil && err != errors.ErrOrderNotFound {
+			h.respondWithError(c, err)
+			return
+		}
+		if order != nil {
+			orders = append(orders, order)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"orders": orders,
+			"limit":  limit,
+			"offset": offset,
+		})
+		return
+	}
+
+	if format := listFormat(c); format != gin.MIMEJSON {
+		// Streamed extracts default to every row, up to the service cap
+		limit, _ = strconv.Atoi(c.DefaultQuery("limit", "0"))
+		streamRows(h, c, format, orderRows, func(fn func(*models.Order) error) error {
+			return h.services.Order.StreamOrders(ctx, limit, offset, fn)
+		})
+		return
+	}
+
+	page, err := h.services.Order.ListOrdersPage(ctx, &models.ListOrdersRequest{
+		Limit:  limit,
+		Offset: offset,
+		Cursor: c.Query("cursor"),
+	})
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	response := gin.H{
+		"orders": page.Orders,
+		"limit":  limit,
+		"offset": offset,
+	}
+	if page.NextCursor != "" {
+		response["next_cursor"] = page.NextCursor
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// CountOrders handles GET /orders/count
+func (h *Handlers) CountOrders(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	count, err := h.services.Order.CountOrders(ctx, &models.ListOrdersRequest{
+		ClientReference: c.Query("client_reference"),
+	})
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"count": count})
+}
+
+// Job handlers
+
+// CreateSettlementJob handles POST /jobs/settlement
+func (h *Handlers) CreateSettlementJob(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.CreateSettlementJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
+		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	job, err := h.services.Job.CreateSettlementJob(ctx, &req)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	response := gin.H{
+		"job_id": job.ID,
+		"status": job.Status,
+	}
+
+	c.JSON(http.StatusAccepted, response)
+}
+
+// CreateReorderForecastJob handles POST /jobs/reorder-forecast
+func (h *Handlers) CreateReorderForecastJob(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.CreateReorderForecastJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
+		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	job, err := h.services.Job.CreateReorderForecastJob(ctx, &req)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"job_id": job.ID,
+		"status": job.Status,
+	})
+}
+
+// CreateOrdersExportJob handles POST /jobs/orders-export
+func (h *Handlers) CreateOrdersExportJob(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.CreateOrdersExportJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
+		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	job, err := h.services.Job.CreateOrdersExportJob(ctx, &req)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"job_id": job.ID,
+		"status": job.Status,
+	})
+}
+
+// CreateMerchantStatementJob handles POST /jobs/merchant-statement
+func (h *Handlers) CreateMerchantStatementJob(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.CreateMerchantStatementJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
+		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	job, err := h.services.Job.CreateMerchantStatementJob(ctx, &req)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"job_id": job.ID,
+		"status": job.Status,
+	})
+}
+
+// CreateResettleJob handles POST /jobs/resettle
+func (h *Handlers) CreateResettleJob(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	job, err := h.services.Job.CreateResettleJob(ctx)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"job_id": job.ID,
+		"status": job.Status,
+		"total":  job.Total,
+	})
+}
+
+// GetReorderRecommendations handles GET /jobs/:id/reorder-recommendations
+func (h *Handlers) GetReorderRecommendations(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	idParam := c.Param("id")
+	id, err := uuid.Parse(idParam)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid job ID")
+		h.respondWithError(c, errors.NewValidationError("Invalid job ID"))
+		return
+	}
+
+	recommendations, err := h.services.Job.GetReorderRecommendations(ctx, id)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"job_id":          id,
+		"recommendations": recommendations,
+	})
+}
+
+// GetJobStats handles GET /jobs/:id/stats
+func (h *Handlers) GetJobStats(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	idParam := c.Param("id")
+	id, err := uuid.Parse(idParam)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid job ID")
+		h.respondWithError(c, errors.NewValidationError("Invalid job ID"))
+		return
+	}
+
+	stats, err := h.services.Job.GetJobStats(ctx, id)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetJobLogs handles GET /jobs/:id/logs
+func (h *Handlers) GetJobLogs(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	idParam := c.Param("id")
+	id, err := uuid.Parse(idParam)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid job ID")
+		h.respondWithError(c, errors.NewValidationError("Invalid job ID"))
+		return
+	}
+
+	after, _ := strconv.ParseInt(c.DefaultQuery("after", "0"), 10, 64)
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+
+	logs, err := h.services.Job.GetJobLogs(ctx, id, c.Query("level"), after, limit)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, logs)
+}
+
+// GetJob handles GET /jobs/:id
+func (h *Handlers) GetJob(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	idParam := c.Param("id")
+	id, err := uuid.Parse(idParam)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid job ID")
+		h.respondWithError(c, errors.NewValidationError("Invalid job ID"))
+		return
+	}
+
+	job, err := h.services.Job.GetJob(ctx, id)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	response := gin.H{
+		"job_id":    job.ID,
+		"status":    job.Status,
+		"progress":  job.Progress,
+		"processed": job.Processed,
+		"total":     job.Total,
+		"attempts":  job.Attempts,
+	}
+
+	// Add live state if job is running
+	if job.Live != nil {
+		response["current_batch"] = job.Live.CurrentBatch
+		response["rows_per_second"] = job.Live.RowsPerSecond
+		if job.Live.ETASeconds != nil {
+			response["eta_seconds"] = *job.Live.ETASeconds
+		}
+	}
+
+	// Add download URL if job is completed
+	if job.Status == models.JobStatusCompleted && job.DownloadURL != nil {
+		response["download_url"] = *job.DownloadURL
+	}
+	if len(job.Files) > 0 {
+		response["files"] = job.Files
+	}
+
+	// Add error if job failed
+	if (job.Status == models.JobStatusFailed || job.Status == models.JobStatusDeadLettered) && job.Error != nil {
+		response["error"] = *job.Error
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// CancelJob handles POST /jobs/:id/cancel
+func (h *Handlers) CancelJob(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	idParam := c.Param("id")
+	id, err := uuid.Parse(idParam)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid job ID")
+		h.respondWithError(c, errors.NewValidationError("Invalid job ID"))
+		return
+	}
+
+	status, err := h.services.Job.CancelJob(ctx, id)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"job_id":  id,
+		"status":  status,
+		"message": "Job cancellation requested",
+	})
+}
+
+// ListJobs handles GET /jobs
+func (h *Handlers) ListJobs(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// Parse query parameters
+	filter := &models.JobFilter{
+		Status: models.JobStatus(c.Query("status")),
+		Type:   models.JobType(c.Query("type")),
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	jobs, err := h.services.Job.ListJobs(ctx, filter, limit, offset)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"jobs":   jobs,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// ListDeadLetteredJobs handles GET /jobs/dead-letter
+func (h *Handlers) ListDeadLetteredJobs(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// Parse query parameters
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	jobs, err := h.services.Job.ListDeadLetteredJobs(ctx, limit, offset)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"jobs":   jobs,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// RedriveJob handles POST /jobs/:id/redrive
+func (h *Handlers) RedriveJob(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	idParam := c.Param("id")
+	id, err := uuid.Parse(idParam)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid job ID")
+		h.respondWithError(c, errors.NewValidationError("Invalid job ID"))
+		return
+	}
+
+	job, err := h.services.Job.RedriveJob(ctx, id)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"job_id": job.ID,
+		"status": job.Status,
+	})
+}
+
+// downloadContentTypes maps the job output extensions that can be downloaded
+// to the content type they are served with
+var downloadContentTypes = map[string]string{
+	".csv":     "application/octet-stream",
+	".json":    "application/json",
+	".pdf":     "application/pdf",
+	".zip":     "application/zip",
+	".parquet": parquet.ContentType,
+}
+
+// DownloadSettlement handles GET /downloads/:filename. The filename only
+// names a job and a format, <job id>.<ext>; the file served is the one the
+// job recorded, looked up in storage by its job ID.
+func (h *Handlers) DownloadSettlement(c *gin.Context) {
+	jobIDStr, ext, ok := strings.Cut(c.Param("filename"), ".")
+	contentType, known := downloadContentTypes["."+ext]
+	if !ok || !known {
+		h.respondWithError(c, errors.NewValidationError("Invalid filename"))
+		return
+	}
+	jobID, err := uuid.Parse(jobIDStr)
+	if err != nil {
+		h.respondWithError(c, errors.NewValidationError("Invalid job ID in filename"))
+		return
+	}
+
+	// Jobs with several output files are zipped on the fly
+	if ext == "zip" {
+		h.streamJobFilesZip(c, jobID, jobID.String()+"."+ext)
+		return
+	}
+
+	h.serveJobResult(c, jobID, ext, contentType)
+}
+
+// serveJobResult sends the job's recorded output file in the ext format as
+// an attachment named <job id>.<ext>
+func (h *Handlers) serveJobResult(c *gin.Context, jobID uuid.UUID, ext, contentType string) {
+	object, file, err := h.services.Job.OpenJobResult(c.Request.Context(), jobID, ext)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+	defer object.Close()
+
+	// Set headers for file download
+	c.Header("Content-Description", "File Transfer")
+	c.Header("Content-Transfer-Encoding", "binary")
+	c.Header("Content-Disposition", "attachment; filename="+jobID.String()+"."+ext)
+	c.Header("Content-Type", contentType)
+
+	http.ServeContent(c.Writer, c.Request, file.Name, file.CreatedAt, object)
+}
+
+// ListJobFiles handles GET /jobs/:id/files
+func (h *Handlers) ListJobFiles(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	idParam := c.Param("id")
+	id, err := uuid.Parse(idParam)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid job ID")
+		h.respondWithError(c, errors.NewValidationError("Invalid job ID"))
+		return
+	}
+
+	files, err := h.services.Job.ListJobFiles(ctx, id)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"job_id": id,
+		"files":  files,
+	})
+}
+
+// DownloadJobFile handles GET /jobs/:id/files/:name
+func (h *Handlers) DownloadJobFile(c *gin.Context) {
+	h.serveJobFile(c, func(ctx context.Context, id uuid.UUID) (io.ReadCloser, *models.JobFile, error) {
+		return h.services.Job.OpenJobFile(ctx, id, c.Param("name"))
+	})
+}
+
+// DownloadMerchantSettlement handles GET /jobs/:id/merchants/:merchant_id/download,
+// serving one merchant's file from a settlement job split by merchant
+func (h *Handlers) DownloadMerchantSettlement(c *gin.Context) {
+	h.serveJobFile(c, func(ctx context.Context, id uuid.UUID) (io.ReadCloser, *models.JobFile, error) {
+		return h.services.Job.OpenMerchantSettlement(ctx, id, c.Param("merchant_id"))
+	})
+}
+
+// serveJobFile sends the output file that open finds for the job in the :id
+// parameter
+func (h *Handlers) serveJobFile(c *gin.Context, open func(ctx context.Context, id uuid.UUID) (io.ReadCloser, *models.JobFile, error)) {
+	ctx := c.Request.Context()
+
+	idParam := c.Param("id")
+	id, err := uuid.Parse(idParam)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid job ID")
+		h.respondWithError(c, errors.NewValidationError("Invalid job ID"))
+		return
+	}
+
+	reader, file, err := open(ctx, id)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+	defer reader.Close()
+
+	c.DataFromReader(http.StatusOK, file.SizeBytes, file.ContentType, reader, map[string]string{
+		"Content-Description":       "File Transfer",
+		"Content-Transfer-Encoding": "binary",
+		"Content-Disposition":       "attachment; filename=" + id.String() + "_" + url.PathEscape(file.Name),
+	})
+}
+
+// streamJobFilesZip sends every output file of a job as one ZIP, built while
+// it is sent
+func (h *Handlers) streamJobFilesZip(c *gin.Context, id uuid.UUID, filename string) {
+	ctx := c.Request.Context()
+
+	files, err := h.services.Job.ListJobFiles(ctx, id)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+	if len(files) == 0 {
+		h.respondWithError(c, errors.ErrFileNotFound)
+		return
+	}
+
+	c.Header("Content-Description", "File Transfer")
+	c.Header("Content-Transfer-Encoding", "binary")
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Header("Content-Type", downloadContentTypes[".zip"])
+	c.Status(http.StatusOK)
+
+	// The status is already sent, so a failure can only cut the ZIP short
+	if err := h.services.Job.WriteJobFilesZip(c.Writer, id, files); err != nil {
+		logger.WithContext(ctx).WithError(err).WithField("job_id", id).Error("Failed to stream job files")
+	}
+}
+
+// Transaction handlers
+
+// ListTransactions handles GET /transactions
+func (h *Handlers) ListTransactions(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// Parse query parameters
+	req := &models.ListTransactionsRequest{
+		MerchantID: c.Query("merchant_id"),
+		Status:     models.TransactionStatus(c.Query("status")),
+		From:       c.Query("from"),
+		To:         c.Query("to"),
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	if format := listFormat(c); format != gin.MIMEJSON {
+		limit, _ = strconv.Atoi(c.DefaultQuery("limit", "0"))
+		streamRows(h, c, format, transactionRows, func(fn func(*models.Transaction) error) error {
+			return h.services.Transaction.StreamTransactions(ctx, req, limit, offset, fn)
+		})
+		return
+	}
+
+	transactions, err := h.services.Transaction.ListTransactions(ctx, req, limit, offset)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transactions": transactions,
+		"limit":        limit,
+		"offset":       offset,
+	})
+}
+
+// SampleTransactions handles GET /transactions/sample
+func (h *Handlers) SampleTransactions(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	req := &models.ListTransactionsRequest{
+		MerchantID: c.Query("merchant_id"),
+		Status:     models.TransactionStatus(c.Query("status")),
+		From:       c.Query("from"),
+		To:         c.Query("to"),
+	}
+	n, _ := strconv.Atoi(c.DefaultQuery("n", "100"))
+
+	transactions, err := h.services.Transaction.SampleTransactions(ctx, req, n)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
 	}
-}
 
-// Order handlers
+	c.JSON(http.StatusOK, gin.H{
+		"transactions": transactions,
+		"count":        len(transactions),
+	})
+}
 
-// CreateOrder handles POST /orders
-func (h *Handlers) CreateOrder(c *gin.Context) {
-	start := time.Now()
+// UpdateTransactionStatus handles PATCH /transactions/:id/status
+func (h *Handlers) UpdateTransactionStatus(c *gin.Context) {
 	ctx := c.Request.Context()
 
-	var req models.CreateOrderRequest
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		h.respondWithError(c, errors.NewValidationError("Invalid transaction ID"))
+		return
+	}
+
+	var req models.UpdateTransactionStatusRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
-		metrics.HTTPRequestsTotal.WithLabelValues("POST", "/orders", "400").Inc()
 		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
 		return
 	}
 
-	order, err := h.services.Order.CreateOrder(ctx, &req)
+	change, err := h.services.Transaction.UpdateStatus(ctx, id, &req)
 	if err != nil {
-		metrics.HTTPRequestsTotal.WithLabelValues("POST", "/orders", "500").Inc()
 		h.respondWithError(c, err)
 		return
 	}
 
-	metrics.HTTPRequestsTotal.WithLabelValues("POST", "/orders", "201").Inc()
-	metrics.HTTPRequestDuration.WithLabelValues("POST", "/orders").Observe(time.Since(start).Seconds())
-	metrics.OrdersCreated.Inc()
-
-	c.JSON(http.StatusCreated, order)
+	c.JSON(http.StatusOK, change)
 }
 
-// GetOrder handles GET /orders/:id
-func (h *Handlers) GetOrder(c *gin.Context) {
+// Settlement handlers
+
+// ListSettlements handles GET /settlements
+func (h *Handlers) ListSettlements(c *gin.Context) {
 	ctx := c.Request.Context()
 
-	idParam := c.Param("id")
-	id, err := uuid.Parse(idParam)
-	if err != nil {
-		logger.WithContext(ctx).WithError(err).Error("Invalid order ID")
-		h.respondWithError(c, errors.NewValidationError("Invalid order ID"))
+	// Parse query parameters
+	req := &models.ListSettlementsRequest{
+		MerchantID: c.Query("merchant_id"),
+		From:       c.Query("from"),
+		To:         c.Query("to"),
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	if format := listFormat(c); format != gin.MIMEJSON {
+		limit, _ = strconv.Atoi(c.DefaultQuery("limit", "0"))
+		streamRows(h, c, format, settlementRows, func(fn func(*models.Settlement) error) error {
+			return h.services.Settlement.StreamSettlements(ctx, req, limit, offset, fn)
+		})
 		return
 	}
 
-	order, err := h.services.Order.GetOrder(ctx, id)
+	settlements, err := h.services.Settlement.ListSettlements(ctx, req, limit, offset)
 	if err != nil {
 		h.respondWithError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, order)
+	c.JSON(http.StatusOK, gin.H{
+		"settlements": settlements,
+		"limit":       limit,
+		"offset":      offset,
+	})
 }
 
-// ListOrders handles GET /orders
-func (h *Handlers) ListOrders(c *gin.Context) {
+// ListStaleSettlements handles GET /settlements/stale
+func (h *Handlers) ListStaleSettlements(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	// Parse query parameters
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
 
-	orders, err := h.services.Order.ListOrders(ctx, limit, offset)
+	settlements, err := h.services.Settlement.ListStale(ctx, limit, offset)
 	if err != nil {
 		h.respondWithError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"orders": orders,
-		"limit":  limit,
-		"offset": offset,
+		"settlements": settlements,
+		"limit":       limit,
+		"offset":      offset,
 	})
 }
 
-// Job handlers
-
-// CreateSettlementJob handles POST /jobs/settlement
-func (h *Handlers) CreateSettlementJob(c *gin.Context) {
+// DetectStaleSettlements handles POST /settlements/stale/detect
+func (h *Handlers) DetectStaleSettlements(c *gin.Context) {
 	ctx := c.Request.Context()
 
-	var req models.CreateSettlementJobRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	// The body is optional; an empty one scans the default lookback window
+	var req models.DetectStaleSettlementsRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
 		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
 		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
 		return
 	}
 
-	job, err := h.services.Job.CreateSettlementJob(ctx, &req)
+	detection, err := h.services.Settlement.DetectStale(ctx, &req)
 	if err != nil {
 		h.respondWithError(c, err)
 		return
 	}
 
-	response := gin.H{
-		"job_id": job.ID,
-		"status": job.Status,
+	c.JSON(http.StatusOK, detection)
+}
+
+// ListLateTransactions handles GET /settlements/late-transactions
+func (h *Handlers) ListLateTransactions(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	report, err := h.services.Settlement.LateTransactions(ctx, c.Query("from"), c.Query("to"))
+	if err != nil {
+		h.respondWithError(c, err)
+		return
 	}
 
-	c.JSON(http.StatusAccepted, response)
+	c.JSON(http.StatusOK, report)
 }
 
-// GetJob handles GET /jobs/:id
-func (h *Handlers) GetJob(c *gin.Context) {
+// CreateSettlementAdjustment handles POST /settlements/adjustments
+func (h *Handlers) CreateSettlementAdjustment(c *gin.Context) {
 	ctx := c.Request.Context()
 
-	idParam := c.Param("id")
-	id, err := uuid.Parse(idParam)
-	if err != nil {
-		logger.WithContext(ctx).WithError(err).Error("Invalid job ID")
-		h.respondWithError(c, errors.NewValidationError("Invalid job ID"))
+	var req models.CreateSettlementAdjustmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
+		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
 		return
 	}
 
-	job, err := h.services.Job.GetJob(ctx, id)
+	adjustment, err := h.services.Settlement.CreateAdjustment(ctx, &req)
 	if err != nil {
 		h.respondWithError(c, err)
 		return
 	}
 
-	response := gin.H{
-		"job_id":    job.ID,
-		"status":    job.Status,
-		"progress":  job.Progress,
-		"processed": job.Processed,
-		"total":     job.Total,
+	c.JSON(http.StatusCreated, adjustment)
+}
+
+// ListSettlementAdjustments handles GET /settlements/adjustments
+func (h *Handlers) ListSettlementAdjustments(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	req := &models.ListSettlementsRequest{
+		MerchantID: c.Query("merchant_id"),
+		From:       c.Query("from"),
+		To:         c.Query("to"),
 	}
 
-	// Add download URL if job is completed
-	if job.Status == models.JobStatusCompleted && job.DownloadURL != nil {
-		response["download_url"] = *job.DownloadURL
+	adjustments, err := h.services.Settlement.ListAdjustments(ctx, req)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
 	}
 
-	// Add error if job failed
-	if job.Status == models.JobStatusFailed && job.Error != nil {
-		response["error"] = *job.Error
+	c.JSON(http.StatusOK, gin.H{"adjustments": adjustments})
+}
+
+// ListSettlementRuns handles GET /settlements/runs
+func (h *Handlers) ListSettlementRuns(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// Parse query parameters
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	runs, err := h.services.Settlement.ListRuns(ctx, limit, offset)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
 	}
 
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusOK, gin.H{
+		"runs":   runs,
+		"limit":  limit,
+		"offset": offset,
+	})
 }
 
-// CancelJob handles POST /jobs/:id/cancel
-func (h *Handlers) CancelJob(c *gin.Context) {
+// GetLatestSettlementRun handles GET /settlements/runs/latest
+func (h *Handlers) GetLatestSettlementRun(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	run, err := h.services.Settlement.GetLatestRun(ctx)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	h.respondWithConditionalJSON(c, run, run.CreatedAt, cacheControlRevalidate)
+}
+
+// GetSettlementRun handles GET /settlements/runs/:id
+func (h *Handlers) GetSettlementRun(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	idParam := c.Param("id")
 	id, err := uuid.Parse(idParam)
 	if err != nil {
-		logger.WithContext(ctx).WithError(err).Error("Invalid job ID")
-		h.respondWithError(c, errors.NewValidationError("Invalid job ID"))
+		logger.WithContext(ctx).WithError(err).Error("Invalid settlement run ID")
+		h.respondWithError(c, errors.NewValidationError("Invalid settlement run ID"))
 		return
 	}
 
-	err = h.services.Job.CancelJob(ctx, id)
+	run, err := h.services.Settlement.GetRun(ctx, id)
 	if err != nil {
 		h.respondWithError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Job cancellation requested",
-	})
+	// Runs are immutable, so clients may reuse them without revalidating
+	h.respondWithConditionalJSON(c, run, run.CreatedAt, cacheControlImmutable)
 }
 
-// DownloadSettlement handles GET /downloads/:filename
-func (h *Handlers) DownloadSettlement(c *gin.Context) {
-	filename := c.Param("filename")
+// GetMerchantDashboard handles GET /merchants/:id/dashboard
+func (h *Handlers) GetMerchantDashboard(c *gin.Context) {
+	ctx := c.Request.Context()
 
-	// Validate filename format (should be UUID.csv)
-	if len(filename) < 40 || filename[len(filename)-4:] != ".csv" {
-		h.respondWithError(c, errors.NewValidationError("Invalid filename"))
+	dashboard, err := h.services.Merchant.GetDashboard(ctx, c.Param("id"), c.Query("from"), c.Query("to"))
+	if err != nil {
+		h.respondWithError(c, err)
 		return
 	}
 
-	// Extract job ID from filename
-	jobIDStr := filename[:len(filename)-4]
-	if _, err := uuid.Parse(jobIDStr); err != nil {
-		h.respondWithError(c, errors.NewValidationError("Invalid job ID in filename"))
+	c.JSON(http.StatusOK, dashboard)
+}
+
+// SetMerchantCalendar handles PUT /merchants/:id/settlement-calendar
+func (h *Handlers) SetMerchantCalendar(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.SetMerchantCalendarRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
+		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	assignment, err := h.services.Calendar.SetMerchantCalendar(ctx, c.Param("id"), &req)
+	if err != nil {
+		h.respondWithError(c, err)
 		return
 	}
 
-	filePath := "/tmp/settlements/" + filename
+	c.JSON(http.StatusOK, assignment)
+}
+
+// ListHolidays handles GET /calendar/holidays
+func (h *Handlers) ListHolidays(c *gin.Context) {
+	ctx := c.Request.Context()
 
-	// Check if file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		h.respondWithError(c, errors.NewAppError("FILE_NOT_FOUND", "Settlement file not found", http.StatusNotFound))
+	holidays, err := h.services.Calendar.ListHolidays(ctx, c.Query("region"))
+	if err != nil {
+		h.respondWithError(c, err)
 		return
 	}
 
-	// Set headers for file download
-	c.Header("Content-Description", "File Transfer")
-	c.Header("Content-Transfer-Encoding", "binary")
-	c.Header("Content-Disposition", "attachment; filename="+filename)
-	c.Header("Content-Type", "application/octet-stream")
+	c.JSON(http.StatusOK, gin.H{
+		"holidays": holidays,
+	})
+}
+
+// CreateHoliday handles POST /calendar/holidays
+func (h *Handlers) CreateHoliday(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.CreateHolidayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
+		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	holiday, err := h.services.Calendar.CreateHoliday(ctx, &req)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
 
-	c.File(filePath)
+	c.JSON(http.StatusCreated, holiday)
 }
 
 // Health handlers
@@ -245,15 +1272,26 @@ func (h *Handlers) Health(c *gin.Context) {
 	c.JSON(statusCode, health)
 }
 
+// Liveness handles GET /healthz. It reports only that the process is up and
+// serving, without checking dependencies, so orchestrators restart it only
+// when it is stuck; readiness belongs to /health.
+func (h *Handlers) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
 // Middleware
 
-// RequestID middleware adds a request ID to the context
+// RequestID middleware adds a request ID to the context, along with the
+// trace ID of a W3C traceparent header when the caller sends one
 func (h *Handlers) RequestID() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		requestID := uuid.New().String()
 
 		// Add to context
 		ctx := context.WithValue(c.Request.Context(), logger.RequestIDKey, requestID)
+		if traceID, ok := parseTraceparent(c.GetHeader("traceparent")); ok {
+			ctx = context.WithValue(ctx, logger.TraceIDKey, traceID)
+		}
 		c.Request = c.Request.WithContext(ctx)
 
 		// Add to response header
@@ -263,6 +1301,97 @@ func (h *Handlers) RequestID() gin.HandlerFunc {
 	}
 }
 
+// parseTraceparent returns the trace ID of a W3C traceparent header
+// (version-traceid-parentid-flags), rejecting malformed and all-zero IDs
+func parseTraceparent(header string) (string, bool) {
+	parts := strings.Split(header, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "", false
+	}
+
+	traceID := strings.ToLower(parts[1])
+	if _, err := hex.DecodeString(traceID); err != nil || traceID == strings.Repeat("0", 32) {
+		return "", false
+	}
+	return traceID, true
+}
+
+// ClientIDHeader identifies the calling client for per-client limits
+const ClientIDHeader = "X-Client-ID"
+
+// Principal middleware identifies the calling client by its X-Client-ID
+// header, falling back to the client IP. There is no authentication, so the
+// principal only supports soft, best-effort limits.
+func (h *Handlers) Principal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := strings.TrimSpace(c.GetHeader(ClientIDHeader))
+		if principal == "" {
+			principal = "ip:" + c.ClientIP()
+		}
+
+		ctx := context.WithValue(c.Request.Context(), logger.PrincipalKey, principal)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
+
+// APIVersionKey is the gin context key holding the API version of a request
+const APIVersionKey = "api_version"
+
+// APIVersion middleware tags the request and response with the API version
+func (h *Handlers) APIVersion(version string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(APIVersionKey, version)
+		c.Header("API-Version", version)
+
+		c.Next()
+	}
+}
+
+// Deprecated middleware marks responses from deprecated routes and points
+// clients at the successor route under the given prefix
+func (h *Handlers) Deprecated(successorPrefix string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Deprecation", "true")
+		c.Header("Link", "<"+successorPrefix+c.Request.URL.Path+">; rel=\"successor-version\"")
+
+		c.Next()
+	}
+}
+
+// RequestTimeout middleware bounds API requests with the request timeout
+func (h *Handlers) RequestTimeout() gin.HandlerFunc {
+	return h.timeout(h.requestTimeout)
+}
+
+// DownloadTimeout middleware bounds file downloads with the longer download timeout
+func (h *Handlers) DownloadTimeout() gin.HandlerFunc {
+	return h.timeout(h.downloadTimeout)
+}
+
+// timeout bounds a request with a deadline carried by its context, so
+// services and repositories stop working on it once the deadline passes. The
+// connection's write deadline is moved to match, which lets downloads outlive
+// the server-wide write timeout while still cutting off slow clients.
+func (h *Handlers) timeout(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if d <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		// Writers that don't support deadlines keep the server's write timeout
+		_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(d))
+
+		c.Next()
+	}
+}
+
 // Logger middleware logs HTTP requests
 func (h *Handlers) Logger() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -293,6 +1422,30 @@ func (h *Handlers) Logger() gin.HandlerFunc {
 	}
 }
 
+// unmatchedRoute labels metrics for requests that matched no route, keeping
+// arbitrary paths out of the label values
+const unmatchedRoute = "unmatched"
+
+// Metrics middleware records the count and duration of every request by
+// route pattern and the status actually sent. Errors go through
+// respondWithError, so their status is the one errors.GetStatusCode assigns.
+func (h *Handlers) Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+
+		method := c.Request.Method
+		metrics.HTTPRequestsTotal.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		metrics.ObserveDuration(c.Request.Context(), metrics.HTTPRequestDuration.WithLabelValues(method, route), start)
+	}
+}
+
 // ErrorHandler middleware handles panics and converts them to errors
 func (h *Handlers) ErrorHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -311,13 +1464,23 @@ func (h *Handlers) ErrorHandler() gin.HandlerFunc {
 	}
 }
 
-// CORS middleware handles Cross-Origin Resource Sharing
+// CORS middleware handles Cross-Origin Resource Sharing for the origins in
+// CORS_ALLOWED_ORIGINS
 func (h *Handlers) CORS() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-Request-ID")
-		c.Header("Access-Control-Expose-Headers", "X-Request-ID")
+		// Browsers from origins that aren't listed get no CORS headers, so
+		// they can't read responses
+		origin := c.GetHeader("Origin")
+		switch {
+		case h.corsOrigins["*"]:
+			c.Header("Access-Control-Allow-Origin", "*")
+		case origin != "" && h.corsOrigins[origin]:
+			c.Header("Access-Control-Allow-Origin", origin)
+			c.Writer.Header().Add("Vary", "Origin")
+		}
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-Request-ID, If-None-Match, If-Modified-Since, X-Admin-Token, X-API-Key, X-Debug-Payload, traceparent")
+		c.Header("Access-Control-Expose-Headers", "X-Request-ID, API-Version, Deprecation, Link, ETag, Last-Modified, X-Indico-Sandbox")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusNoContent)
@@ -328,17 +1491,125 @@ func (h *Handlers) CORS() gin.HandlerFunc {
 	}
 }
 
-// MetricsHandler returns Prometheus metrics
+// MetricsHandler returns Prometheus metrics, in the OpenMetrics format when
+// the scraper asks for it so that exemplars are exposed
 func (h *Handlers) MetricsHandler() gin.HandlerFunc {
-	return gin.WrapH(promhttp.Handler())
+	return gin.WrapH(promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}),
+	))
+}
+
+// GraphQL handles POST /graphql
+func (h *Handlers) GraphQL() gin.HandlerFunc {
+	return gin.WrapH(h.graphql)
 }
 
 // Helper methods
 
+// Cache-Control policies for conditional responses
+const (
+	cacheControlRevalidate = "private, no-cache"
+	cacheControlImmutable  = "public, max-age=86400, immutable"
+)
+
+// respondWithConditionalJSON responds with an ETag and Last-Modified and
+// answers 304 Not Modified when the client's cached copy is still current
+func (h *Handlers) respondWithConditionalJSON(c *gin.Context, body interface{}, lastModified time.Time, cacheControl string) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		h.respondWithError(c, errors.ErrInternalError)
+		return
+	}
+
+	sum := sha256.Sum256(payload)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	lastModified = lastModified.UTC().Truncate(time.Second)
+
+	c.Header("ETag", etag)
+	c.Header("Last-Modified", lastModified.Format(http.TimeFormat))
+	c.Header("Cache-Control", cacheControl)
+
+	if notModified(c.Request, etag, lastModified) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json; charset=utf-8", payload)
+}
+
+// notModified reports whether the request's validators match the current representation
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	// If-None-Match takes precedence over If-Modified-Since (RFC 9110 13.2.2)
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		if t, err := http.ParseTime(ims); err == nil {
+			return !lastModified.After(t)
+		}
+	}
+
+	return false
+}
+
 // respondWithError responds with an error in a consistent format
 func (h *Handlers) respondWithError(c *gin.Context, err error) {
+	// A query cut short by the route deadline fails with a driver error
+	// rather than the context's, so report unexpected errors as a timeout
+	// whenever the deadline has passed
+	if _, ok := errors.IsAppError(err); !ok && c.Request.Context().Err() == context.DeadlineExceeded {
+		logger.WithContext(c.Request.Context()).WithError(err).Warn("Request deadline exceeded")
+		err = errors.ErrRequestTimeout
+	}
+
 	statusCode := errors.GetStatusCode(err)
 	response := errors.ToErrorResponse(err)
 
+	if appErr, ok := errors.IsAppError(err); ok && appErr.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(appErr.RetryAfter.Seconds()))))
+	}
+
+	lang := h.negotiateLanguage(c)
+	response.Error.Localize(lang)
+
+	// Clients standardizing on RFC 7807 opt in via the Accept header
+	if c.NegotiateFormat(gin.MIMEJSON, errors.ProblemContentType) == errors.ProblemContentType {
+		var instance string
+		if requestID, ok := c.Request.Context().Value(logger.RequestIDKey).(string); ok {
+			instance = "urn:uuid:" + requestID
+		}
+
+		// c.JSON keeps an explicitly set Content-Type
+		c.Header("Content-Type", errors.ProblemContentType)
+		c.JSON(statusCode, response.ToProblemDetails(statusCode, instance))
+		return
+	}
+
 	c.JSON(statusCode, response)
 }
+
+// respondWithMultiStatus sends a bulk operation result, using 207 Multi-Status
+// when any item failed
+func (h *Handlers) respondWithMultiStatus(c *gin.Context, result *errors.MultiStatusResponse, successStatus int) {
+	lang := h.negotiateLanguage(c)
+	result.Localize(lang)
+
+	c.JSON(result.StatusCode(successStatus), result)
+}
+
+// negotiateLanguage picks the response language from Accept-Language and
+// advertises it via Content-Language
+func (h *Handlers) negotiateLanguage(c *gin.Context) string {
+	lang := i18n.Negotiate(c.GetHeader("Accept-Language"))
+	c.Header("Content-Language", lang)
+	c.Header("Vary", "Accept-Language")
+	return lang
+}
@@ -0,0 +1,59 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SyncStock handles PUT /products/stock-sync
+func (h *Handlers) SyncStock(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.StockSyncRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
+		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	result, err := h.services.Product.SyncStock(ctx, &req)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ListInventoryMovements handles GET /products/:id/movements
+func (h *Handlers) ListInventoryMovements(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		h.respondWithError(c, errors.NewValidationError("Invalid product ID"))
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	movements, err := h.services.Product.ListMovements(ctx, id, limit, offset)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"movements": movements,
+		"limit":     limit,
+		"offset":    offset,
+	})
+}
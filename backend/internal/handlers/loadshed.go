@@ -0,0 +1,183 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"indico-backend/internal/config"
+	"indico-backend/internal/errors"
+	"indico-backend/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// loadShedMaxEntries bounds the number of list responses kept for
+	// serving while the database is saturated
+	loadShedMaxEntries = 1024
+	// loadShedMaxBodyBytes is the largest response body kept; bigger pages
+	// are always served from the database
+	loadShedMaxBodyBytes = 256 << 10
+	// loadShedMaxQueryBytes is the largest request body, such as a GraphQL
+	// query, a response is cached for
+	loadShedMaxQueryBytes = 64 << 10
+)
+
+// loadShedHeader tells clients how a list response was degraded
+const loadShedHeader = "X-Load-Shed"
+
+// cachedResponse is a list response kept for serving while saturated
+type cachedResponse struct {
+	contentType string
+	body        []byte
+	storedAt    time.Time
+}
+
+// loadShedder keeps recent list responses and the thresholds applied to
+// list requests while the database is saturated
+type loadShedder struct {
+	config *config.LoadShedConfig
+
+	mu        sync.Mutex
+	responses map[string]*cachedResponse
+}
+
+// newLoadShedder creates a load shedder from configuration
+func newLoadShedder(cfg *config.LoadShedConfig) *loadShedder {
+	return &loadShedder{
+		config:    cfg,
+		responses: make(map[string]*cachedResponse),
+	}
+}
+
+// cacheKey identifies a list request; the Accept header picks the format
+// and a body, such as a GraphQL query, is part of the request. A request
+// whose body is too big to key by is not cacheable.
+func cacheKey(c *gin.Context) (string, bool) {
+	key := c.Request.URL.Path + "?" + c.Request.URL.RawQuery + "|" + c.GetHeader("Accept")
+	if c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return key, true
+	}
+
+	var body []byte
+	var truncated bool
+	var err error
+	body, truncated, c.Request.Body, err = readCapped(c.Request.Body, loadShedMaxQueryBytes)
+	if err != nil || truncated {
+		return "", false
+	}
+	sum := sha256.Sum256(body)
+	return key + "|" + hex.EncodeToString(sum[:]), true
+}
+
+// get returns the response cached for key if it is fresh enough to serve
+func (s *loadShedder) get(key string) (*cachedResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	response, ok := s.responses[key]
+	if !ok || time.Since(response.storedAt) > s.config.CacheTTL {
+		return nil, false
+	}
+	return response, true
+}
+
+// put caches a response, making room by dropping stale entries first and
+// an arbitrary one if none are stale
+func (s *loadShedder) put(key string, response *cachedResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.responses[key]; !ok && len(s.responses) >= loadShedMaxEntries {
+		for k, r := range s.responses {
+			if time.Since(r.storedAt) > s.config.CacheTTL {
+				delete(s.responses, k)
+			}
+		}
+		for k := range s.responses {
+			if len(s.responses) < loadShedMaxEntries {
+				break
+			}
+			delete(s.responses, k)
+		}
+	}
+	s.responses[key] = response
+}
+
+// LoadShed middleware degrades an expensive list endpoint while the
+// database connection pool is saturated, so the connections left go to
+// order creation: pages past the max offset are rejected with 503, a
+// recent response to the same request is served from memory, and page
+// sizes are capped at the max limit. defaultLimit is the page size the
+// route uses when the client gives none; streamed formats default to every
+// row. Outside saturation the middleware only records responses.
+func (h *Handlers) LoadShed(defaultLimit int) gin.HandlerFunc {
+	cfg := h.shedder.config
+
+	return func(c *gin.Context) {
+		if !cfg.Enabled {
+			c.Next()
+			return
+		}
+
+		key, cacheable := cacheKey(c)
+
+		if pressure := h.services.Pressure; pressure != nil && pressure.Saturated() {
+			// Read the query directly: gin caches it on first use, which
+			// would hide the clamped limit from the handler
+			query := c.Request.URL.Query()
+
+			if offset, _ := strconv.Atoi(query.Get("offset")); offset > cfg.MaxOffset {
+				metrics.RequestsShed.WithLabelValues("rejected").Inc()
+				h.respondWithError(c, errors.NewLoadShedError(cfg.MaxOffset, cfg.Cooldown))
+				c.Abort()
+				return
+			}
+
+			if response, ok := h.shedder.get(key); cacheable && ok {
+				metrics.RequestsShed.WithLabelValues("cached").Inc()
+				c.Header(loadShedHeader, "cached")
+				c.Header("Age", strconv.Itoa(int(time.Since(response.storedAt).Seconds())))
+				c.Header("Vary", "Accept")
+				c.Data(http.StatusOK, response.contentType, response.body)
+				c.Abort()
+				return
+			}
+
+			limit := defaultLimit
+			if c.NegotiateFormat(gin.MIMEJSON, MIMECSV, MIMENDJSON, MIMEParquet) != gin.MIMEJSON {
+				limit = 0
+			}
+			if query.Has("limit") {
+				limit, _ = strconv.Atoi(query.Get("limit"))
+			}
+			if limit <= 0 || limit > cfg.MaxLimit {
+				metrics.RequestsShed.WithLabelValues("clamped").Inc()
+				query.Set("limit", strconv.Itoa(cfg.MaxLimit))
+				c.Request.URL.RawQuery = query.Encode()
+				c.Header(loadShedHeader, "clamped")
+			}
+		}
+
+		writer := &bodyCaptureWriter{ResponseWriter: c.Writer, limit: loadShedMaxBodyBytes}
+		c.Writer = writer
+
+		c.Next()
+
+		// Responses marked no-store, such as GraphQL responses with errors,
+		// are not replayed
+		if cacheable && writer.Status() == http.StatusOK && !writer.truncated &&
+			writer.Header().Get("Cache-Control") != "no-store" {
+			h.shedder.put(key, &cachedResponse{
+				contentType: writer.Header().Get("Content-Type"),
+				body:        writer.body.Bytes(),
+				storedAt:    time.Now(),
+			})
+		}
+	}
+}
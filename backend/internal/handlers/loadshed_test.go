@@ -0,0 +1,208 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"indico-backend/internal/config"
+	"indico-backend/internal/errors"
+	"indico-backend/internal/handlers"
+	"indico-backend/internal/models"
+	"indico-backend/internal/routes"
+	"indico-backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pressure is a pressure gauge saturated on demand
+type pressure struct {
+	saturated bool
+}
+
+func (p *pressure) Saturated() bool {
+	return p.saturated
+}
+
+// orderPages is an order service recording the page sizes it is asked for
+type orderPages struct {
+	service.OrderService
+	limits []int
+}
+
+func (p *orderPages) ListOrdersPage(ctx context.Context, req *models.ListOrdersRequest) (*models.OrderPage, error) {
+	p.limits = append(p.limits, req.Limit)
+	return &models.OrderPage{Orders: []*models.Order{{ID: uuid.New()}}}, nil
+}
+
+func (p *orderPages) ListOrders(ctx context.Context, limit, offset int) ([]*models.Order, error) {
+	p.limits = append(p.limits, limit)
+	return []*models.Order{{ID: uuid.New()}}, nil
+}
+
+// newLoadShedRouter serves the routes with load shedding configured as in
+// production, or disabled
+func newLoadShedRouter(t *testing.T, enabled bool) (*gin.Engine, *pressure, *orderPages) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		LoadShed: config.LoadShedConfig{
+			Enabled:   enabled,
+			Cooldown:  30 * time.Second,
+			MaxLimit:  20,
+			MaxOffset: 1000,
+			CacheTTL:  time.Minute,
+		},
+	}
+	gauge := &pressure{}
+	orders := &orderPages{}
+	services := &service.Services{Order: orders, Pressure: gauge}
+	return routes.SetupRoutes(handlers.New(services, cfg)), gauge, orders
+}
+
+// listOrders lists orders with the given query
+func listOrders(router http.Handler, query string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/orders?"+query, nil))
+	return rec
+}
+
+func TestLoadShedPassesThroughUnsaturated(t *testing.T) {
+	router, _, orders := newLoadShedRouter(t, true)
+
+	rec := listOrders(router, "limit=500&offset=5000")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("X-Load-Shed"))
+	assert.Equal(t, []int{500}, orders.limits)
+}
+
+func TestLoadShedDisabled(t *testing.T) {
+	router, gauge, orders := newLoadShedRouter(t, false)
+	gauge.saturated = true
+
+	// Nothing is degraded however saturated the pool is
+	for i := 0; i < 2; i++ {
+		rec := listOrders(router, "limit=500&offset=5000")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("X-Load-Shed"))
+	}
+	assert.Equal(t, []int{500, 500}, orders.limits)
+}
+
+func TestLoadShedRejectsDeepOffsets(t *testing.T) {
+	router, gauge, orders := newLoadShedRouter(t, true)
+	gauge.saturated = true
+
+	rec := listOrders(router, "offset=1001")
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "30", rec.Header().Get("Retry-After"))
+
+	var body errors.ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, errors.ErrCodeServiceUnavailable, body.Error.Code)
+	assert.Equal(t, "max_offset=1000", body.Error.Details)
+	assert.Empty(t, orders.limits)
+
+	// The max offset itself is still served
+	assert.Equal(t, http.StatusOK, listOrders(router, "offset=1000").Code)
+}
+
+func TestLoadShedClampsLimits(t *testing.T) {
+	tests := map[string]struct {
+		query   string
+		limit   int
+		clamped bool
+	}{
+		"route default":     {query: "", limit: 10},
+		"within max":        {query: "limit=20", limit: 20},
+		"above max":         {query: "limit=500", limit: 20, clamped: true},
+		"zero asks for all": {query: "limit=0", limit: 20, clamped: true},
+		"negative":          {query: "limit=-1", limit: 20, clamped: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			router, gauge, orders := newLoadShedRouter(t, true)
+			gauge.saturated = true
+
+			rec := listOrders(router, tt.query)
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, []int{tt.limit}, orders.limits)
+			if tt.clamped {
+				assert.Equal(t, "clamped", rec.Header().Get("X-Load-Shed"))
+			} else {
+				assert.Empty(t, rec.Header().Get("X-Load-Shed"))
+			}
+		})
+	}
+}
+
+// queryGraphQL posts a GraphQL query
+func queryGraphQL(router http.Handler, query string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(map[string]string{"query": query})
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestLoadShedCachesGraphQLPerQuery(t *testing.T) {
+	router, gauge, orders := newLoadShedRouter(t, true)
+
+	first := queryGraphQL(router, "{ orders(limit: 5) { id } }")
+	require.Equal(t, http.StatusOK, first.Code)
+	require.Len(t, orders.limits, 1)
+
+	// While saturated, the same query is served from memory
+	gauge.saturated = true
+	rec := queryGraphQL(router, "{ orders(limit: 5) { id } }")
+	assert.Equal(t, "cached", rec.Header().Get("X-Load-Shed"))
+	assert.Equal(t, first.Body.String(), rec.Body.String())
+	assert.Len(t, orders.limits, 1)
+
+	// A different query on the same URL still reaches the database
+	rec = queryGraphQL(router, "{ orders(limit: 6) { id } }")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("X-Load-Shed"))
+	assert.Len(t, orders.limits, 2)
+
+	// A rejected deep page is not cached
+	for i := 0; i < 2; i++ {
+		rec = queryGraphQL(router, "{ orders(offset: 5000) { id } }")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("X-Load-Shed"))
+		assert.Contains(t, rec.Body.String(), "errors")
+	}
+}
+
+func TestLoadShedServesCachedResponses(t *testing.T) {
+	router, gauge, orders := newLoadShedRouter(t, true)
+
+	// A response recorded before saturation
+	first := listOrders(router, "limit=5")
+	require.Equal(t, http.StatusOK, first.Code)
+	require.Len(t, orders.limits, 1)
+
+	// While saturated, the same request is served from memory
+	gauge.saturated = true
+	rec := listOrders(router, "limit=5")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "cached", rec.Header().Get("X-Load-Shed"))
+	assert.Equal(t, first.Body.String(), rec.Body.String())
+	assert.Equal(t, first.Header().Get("Content-Type"), rec.Header().Get("Content-Type"))
+	assert.Len(t, orders.limits, 1)
+
+	// A different request still reaches the database
+	rec = listOrders(router, "limit=6")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("X-Load-Shed"))
+	assert.Len(t, orders.limits, 2)
+}
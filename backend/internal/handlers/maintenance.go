@@ -0,0 +1,105 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+	"indico-backend/internal/seed"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maintenanceRetryAfter is the Retry-After hint, in seconds, sent with writes
+// rejected during maintenance
+const maintenanceRetryAfter = "60"
+
+// Maintenance middleware rejects writes with 503 while maintenance mode is
+// on. Reads keep working, as do GraphQL (which only has queries), search
+// queries and the admin endpoints used to lift maintenance mode.
+func (h *Handlers) Maintenance() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		path := c.Request.URL.Path
+		if path == "/graphql" || path == "/admin" || strings.HasPrefix(path, "/admin/") || strings.HasSuffix(c.FullPath(), "/search/:index") {
+			c.Next()
+			return
+		}
+
+		state := h.services.Maintenance.State()
+		if !state.Enabled {
+			c.Next()
+			return
+		}
+
+		c.Header("Retry-After", maintenanceRetryAfter)
+		h.respondWithError(c, errors.NewMaintenanceError(state.Message))
+		c.Abort()
+	}
+}
+
+// GetMaintenance handles GET /admin/maintenance
+func (h *Handlers) GetMaintenance(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	status, err := h.services.Maintenance.GetMaintenance(ctx)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// SetMaintenance handles PUT /admin/maintenance
+func (h *Handlers) SetMaintenance(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.SetMaintenanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
+		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	status, err := h.services.Maintenance.SetMaintenance(ctx, &req)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	logger.WithContext(ctx).
+		WithField("enabled", status.Enabled).
+		WithField("message", status.Message).
+		Warn("Maintenance mode toggled")
+
+	c.JSON(http.StatusOK, status)
+}
+
+// Seed handles POST /admin/seed
+func (h *Handlers) Seed(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	req := models.SeedRequest{SeedOptions: seed.DefaultOptions()}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
+		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	result, err := h.services.Seed.Seed(ctx, &req)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,178 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"indico-backend/internal/bankfile"
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// payoutFileContentTypes maps the payout bank file extensions to the
+// content type they are served with. Bank files hold account numbers, so
+// they are only served on the admin route, never on /downloads.
+var payoutFileContentTypes = map[string]string{
+	".ach": bankfile.ContentTypeNACHA,
+	".xml": bankfile.ContentTypeSEPA,
+}
+
+// SetMerchantBankAccount handles PUT /merchants/:id/bank-account
+func (h *Handlers) SetMerchantBankAccount(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.SetMerchantBankAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
+		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	account, err := h.services.Payout.SetBankAccount(ctx, c.Param("id"), &req)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, account)
+}
+
+// GetMerchantBankAccount handles GET /merchants/:id/bank-account
+func (h *Handlers) GetMerchantBankAccount(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	account, err := h.services.Payout.GetBankAccount(ctx, c.Param("id"))
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, account)
+}
+
+// CreatePayout handles POST /payouts
+func (h *Handlers) CreatePayout(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.CreatePayoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
+		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	payout, err := h.services.Payout.CreatePayout(ctx, &req)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, payout)
+}
+
+// ListPayouts handles GET /payouts
+func (h *Handlers) ListPayouts(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// Parse query parameters
+	status := models.PayoutStatus(strings.ToUpper(c.Query("status")))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	payouts, err := h.services.Payout.ListPayouts(ctx, status, limit, offset)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"payouts": payouts,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// GetPayout handles GET /payouts/:id
+func (h *Handlers) GetPayout(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.respondWithError(c, errors.NewValidationError("Invalid payout ID"))
+		return
+	}
+
+	payout, err := h.services.Payout.GetPayout(ctx, id)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, payout)
+}
+
+// ApprovePayout handles POST /payouts/:id/approve
+func (h *Handlers) ApprovePayout(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.respondWithError(c, errors.NewValidationError("Invalid payout ID"))
+		return
+	}
+
+	payout, err := h.services.Payout.ApprovePayout(ctx, id)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, payout)
+}
+
+// CreatePayoutFileJob handles POST /jobs/payout-file
+func (h *Handlers) CreatePayoutFileJob(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.CreatePayoutFileJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
+		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	job, err := h.services.Job.CreatePayoutFileJob(ctx, &req)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"job_id": job.ID,
+		"status": job.Status,
+	})
+}
+
+// DownloadPayoutFile handles GET /payouts/files/:filename, serving the bank
+// file a payout file job wrote, named <job id>.ach or <job id>.xml
+func (h *Handlers) DownloadPayoutFile(c *gin.Context) {
+	jobIDStr, ext, ok := strings.Cut(c.Param("filename"), ".")
+	contentType, known := payoutFileContentTypes["."+ext]
+	if !ok || !known {
+		h.respondWithError(c, errors.NewValidationError("Invalid filename"))
+		return
+	}
+	jobID, err := uuid.Parse(jobIDStr)
+	if err != nil {
+		h.respondWithError(c, errors.NewValidationError("Invalid job ID in filename"))
+		return
+	}
+
+	h.serveJobResult(c, jobID, ext, contentType)
+}
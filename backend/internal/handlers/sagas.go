@@ -0,0 +1,57 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ListSagas handles GET /admin/sagas
+func (h *Handlers) ListSagas(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// Parse query parameters
+	status := models.SagaStatus(c.Query("status"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	sagas, err := h.services.Saga.ListSagas(ctx, status, limit, offset)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sagas":  sagas,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// GetSaga handles GET /admin/sagas/:id
+func (h *Handlers) GetSaga(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	idParam := c.Param("id")
+	id, err := uuid.Parse(idParam)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid saga ID")
+		h.respondWithError(c, errors.NewValidationError("Invalid saga ID"))
+		return
+	}
+
+	saga, err := h.services.Saga.GetSaga(ctx, id)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, saga)
+}
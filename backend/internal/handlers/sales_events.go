@@ -0,0 +1,117 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateSalesEvent handles POST /sales-events
+func (h *Handlers) CreateSalesEvent(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.CreateSalesEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
+		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	event, err := h.services.SalesEvent.CreateSalesEvent(ctx, &req)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, event)
+}
+
+// ListSalesEvents handles GET /sales-events
+func (h *Handlers) ListSalesEvents(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	events, err := h.services.SalesEvent.ListSalesEvents(ctx, limit, offset)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sales_events": events,
+		"limit":        limit,
+		"offset":       offset,
+	})
+}
+
+// GetSalesEvent handles GET /sales-events/:id
+func (h *Handlers) GetSalesEvent(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	id, ok := parseSalesEventID(h, c)
+	if !ok {
+		return
+	}
+
+	event, err := h.services.SalesEvent.GetSalesEvent(ctx, id)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, event)
+}
+
+// DeleteSalesEvent handles DELETE /sales-events/:id
+func (h *Handlers) DeleteSalesEvent(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	id, ok := parseSalesEventID(h, c)
+	if !ok {
+		return
+	}
+
+	if err := h.services.SalesEvent.DeleteSalesEvent(ctx, id); err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetSalesEventStats handles GET /sales-events/:id/stats
+func (h *Handlers) GetSalesEventStats(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	id, ok := parseSalesEventID(h, c)
+	if !ok {
+		return
+	}
+
+	stats, err := h.services.SalesEvent.GetSalesEventStats(ctx, id)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// parseSalesEventID reads the sales event ID path parameter, answering 400
+// when it is not a positive integer
+func parseSalesEventID(h *Handlers, c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		h.respondWithError(c, errors.NewValidationError("Invalid sales event ID"))
+		return 0, false
+	}
+	return id, true
+}
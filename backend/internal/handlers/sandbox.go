@@ -0,0 +1,74 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"time"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Sandbox headers: requests present a sandbox key in APIKeyHeader, and
+// every response served from the sandbox carries SandboxHeader
+const (
+	APIKeyHeader  = "X-API-Key"
+	SandboxHeader = "X-Indico-Sandbox"
+)
+
+// sandboxRouter hands requests made with a sandbox key to the routes served
+// over the sandbox services
+type sandboxRouter struct {
+	keys   []string
+	router http.Handler
+}
+
+// isKey reports whether key is one of the sandbox keys, in constant time
+func (s *sandboxRouter) isKey(key string) bool {
+	match := 0
+	for _, candidate := range s.keys {
+		match |= subtle.ConstantTimeCompare([]byte(key), []byte(candidate))
+	}
+	return match == 1
+}
+
+// ServeSandbox serves requests made with a sandbox key from router, which
+// serves the same routes over services backed by the sandbox schema
+func (h *Handlers) ServeSandbox(router http.Handler) {
+	h.sandbox.router = router
+}
+
+// Sandbox middleware hands requests made with a sandbox API key to the
+// sandbox router. It runs ahead of the other middleware, so sandbox requests
+// are logged and counted once, by the sandbox router. There are no live API
+// keys, so any other key is rejected rather than served live, where a
+// mistyped sandbox key would write real data. Clients and keys banned for
+// repeated invalid keys are turned away before the key is checked.
+func (h *Handlers) Sandbox() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(APIKeyHeader)
+		if h.sandbox.router == nil || key == "" {
+			c.Next()
+			return
+		}
+
+		if err := h.services.AuthGuard.Check(c.ClientIP(), key, time.Now()); err != nil {
+			h.respondWithError(c, err)
+			c.Abort()
+			return
+		}
+		if !h.sandbox.isKey(key) {
+			h.services.AuthGuard.Fail(c.Request.Context(), service.AuthCredentialAPIKey, c.ClientIP(), key, time.Now())
+			h.respondWithError(c, errors.ErrInvalidAPIKey)
+			c.Abort()
+			return
+		}
+
+		c.Header(SandboxHeader, "true")
+		h.sandbox.router.ServeHTTP(c.Writer, c.Request)
+		c.Abort()
+	}
+}
@@ -0,0 +1,32 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"net/http"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Search handles POST /search/:index
+func (h *Handlers) Search(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.SearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
+		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	result, err := h.services.Search.Search(ctx, c.Param("index"), &req)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,107 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"indico-backend/internal/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetOrdersPerMinute handles GET /admin/stats/orders-per-minute
+func (h *Handlers) GetOrdersPerMinute(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	minutes, _ := strconv.Atoi(c.DefaultQuery("minutes", "60"))
+
+	stats, err := h.services.Stats.OrdersPerMinute(ctx, minutes)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetJobThroughput handles GET /admin/stats/job-throughput
+func (h *Handlers) GetJobThroughput(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	hours, _ := strconv.Atoi(c.DefaultQuery("hours", "24"))
+
+	stats, err := h.services.Stats.JobThroughput(ctx, hours)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetJobQueue handles GET /admin/stats/job-queue
+func (h *Handlers) GetJobQueue(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	stats, err := h.services.Stats.JobQueue(ctx)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetErrorRates handles GET /admin/stats/error-rates
+func (h *Handlers) GetErrorRates(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	hours, _ := strconv.Atoi(c.DefaultQuery("hours", "24"))
+
+	stats, err := h.services.Stats.ErrorRates(ctx, hours)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetTopMerchants handles GET /admin/stats/top-merchants
+func (h *Handlers) GetTopMerchants(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	stats, err := h.services.Stats.TopMerchants(ctx, c.Query("from"), c.Query("to"), limit)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetOrderAnalytics handles GET /admin/stats/order-analytics
+func (h *Handlers) GetOrderAnalytics(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	productID := 0
+	if value := c.Query("product_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			h.respondWithError(c, errors.NewValidationError("Invalid product ID"))
+			return
+		}
+		productID = id
+	}
+
+	stats, err := h.services.Stats.OrderAnalytics(ctx, c.Query("from"), c.Query("to"), productID)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
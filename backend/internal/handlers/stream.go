@@ -0,0 +1,304 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"indico-backend/internal/csvsafe"
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+	"indico-backend/internal/parquet"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Row formats list endpoints stream when asked for them via Accept
+const (
+	MIMECSV     = "text/csv"
+	MIMENDJSON  = "application/x-ndjson"
+	MIMEParquet = parquet.ContentType
+)
+
+// streamFlushRows is how many rows are written between flushes
+const streamFlushRows = 100
+
+// listFormat negotiates a list endpoint's format from the Accept header,
+// defaulting to JSON
+func listFormat(c *gin.Context) string {
+	c.Writer.Header().Add("Vary", "Accept")
+
+	switch format := c.NegotiateFormat(gin.MIMEJSON, MIMECSV, MIMENDJSON, MIMEParquet); format {
+	case MIMECSV, MIMENDJSON, MIMEParquet:
+		return format
+	default:
+		return gin.MIMEJSON
+	}
+}
+
+// rowFormat describes how the rows of a listing are written as CSV and
+// Parquet; both use the column names of the Parquet schema
+type rowFormat[T any] struct {
+	columns []parquet.Column
+	record  func(T) []string
+	values  func(T) []interface{}
+}
+
+// header returns the CSV header row
+func (f rowFormat[T]) header() []string {
+	names := make([]string, len(f.columns))
+	for i, column := range f.columns {
+		names[i] = column.Name
+	}
+	return names
+}
+
+// streamRows writes the rows produced by stream as CSV, NDJSON or Parquet
+// while they are read. The response starts with the first row, so errors
+// before it get a normal error response. A later error can only cut the body
+// short; NDJSON responses then end with an {"error": ...} line, and Parquet
+// responses lack the footer that makes them readable.
+func streamRows[T any](h *Handlers, c *gin.Context, format string, rowsFormat rowFormat[T], stream func(fn func(T) error) error) {
+	var (
+		rows       int
+		csvOut     *csvsafe.Writer
+		jsonOut    *json.Encoder
+		parquetOut *parquet.Writer
+	)
+
+	start := func() error {
+		switch format {
+		case MIMEParquet:
+			c.Header("Content-Type", format)
+		default:
+			c.Header("Content-Type", format+"; charset=utf-8")
+		}
+		c.Status(http.StatusOK)
+
+		var err error
+		switch format {
+		case MIMECSV:
+			csvOut = csvsafe.NewWriter(c.Writer)
+			err = csvOut.Write(rowsFormat.header())
+		case MIMEParquet:
+			parquetOut, err = parquet.NewWriter(c.Writer, rowsFormat.columns)
+		default:
+			jsonOut = json.NewEncoder(c.Writer)
+		}
+		return err
+	}
+
+	write := func(row T) error {
+		if rows == 0 {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		switch {
+		case csvOut != nil:
+			err = csvOut.Write(rowsFormat.record(row))
+		case parquetOut != nil:
+			err = parquetOut.Write(rowsFormat.values(row))
+		default:
+			err = jsonOut.Encode(row)
+		}
+		if err != nil {
+			return err
+		}
+
+		rows++
+		if rows%streamFlushRows == 0 {
+			if csvOut != nil {
+				csvOut.Flush()
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	}
+
+	err := stream(write)
+	if err != nil && rows == 0 {
+		h.respondWithError(c, err)
+		return
+	}
+	if err != nil {
+		logger.WithContext(c.Request.Context()).WithError(err).
+			WithField("rows_written", rows).
+			Error("List stream ended early")
+
+		if jsonOut != nil {
+			// Clients can't see a status change mid-body, so report in-band
+			_ = jsonOut.Encode(errors.ToErrorResponse(err))
+		}
+	}
+
+	// An empty listing still gets the CSV header row or an empty Parquet file
+	if rows == 0 {
+		if err := start(); err != nil {
+			return
+		}
+	}
+	if csvOut != nil {
+		csvOut.Flush()
+	}
+	// A cut-short Parquet body is left without its footer, so it can't be
+	// mistaken for a complete file
+	if parquetOut != nil && err == nil {
+		if err := parquetOut.Close(); err != nil {
+			logger.WithContext(c.Request.Context()).WithError(err).Error("Failed to finish Parquet stream")
+		}
+	}
+}
+
+// orderRows are the columns of a streamed order listing
+var orderRows = rowFormat[*models.Order]{
+	columns: []parquet.Column{
+		{Name: "id", Type: parquet.String},
+		{Name: "product_id", Type: parquet.Int32},
+		{Name: "buyer_id", Type: parquet.String},
+		{Name: "quantity", Type: parquet.Int32},
+		{Name: "status", Type: parquet.String},
+		{Name: "total_cents", Type: parquet.Int32},
+		{Name: "created_at", Type: parquet.Timestamp},
+		{Name: "updated_at", Type: parquet.Timestamp},
+	},
+	record: orderRecord,
+	values: orderValues,
+}
+
+func orderRecord(order *models.Order) []string {
+	return []string{
+		order.ID.String(),
+		strconv.Itoa(order.ProductID),
+		order.BuyerID,
+		strconv.Itoa(order.Quantity),
+		string(order.Status),
+		strconv.Itoa(order.TotalCents),
+		order.CreatedAt.UTC().Format(time.RFC3339),
+		order.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func orderValues(order *models.Order) []interface{} {
+	return []interface{}{
+		order.ID.String(),
+		order.ProductID,
+		order.BuyerID,
+		order.Quantity,
+		string(order.Status),
+		order.TotalCents,
+		order.CreatedAt,
+		order.UpdatedAt,
+	}
+}
+
+// transactionRows are the columns of a streamed transaction listing
+var transactionRows = rowFormat[*models.Transaction]{
+	columns: []parquet.Column{
+		{Name: "id", Type: parquet.Int32},
+		{Name: "merchant_id", Type: parquet.String},
+		{Name: "amount_cents", Type: parquet.Int32},
+		{Name: "fee_cents", Type: parquet.Int32},
+		{Name: "status", Type: parquet.String},
+		{Name: "paid_at", Type: parquet.Timestamp},
+		{Name: "created_at", Type: parquet.Timestamp},
+	},
+	record: transactionRecord,
+	values: transactionValues,
+}
+
+func transactionRecord(txn *models.Transaction) []string {
+	return []string{
+		strconv.Itoa(txn.ID),
+		txn.MerchantID,
+		strconv.Itoa(txn.AmountCents),
+		strconv.Itoa(txn.FeeCents),
+		string(txn.Status),
+		txn.PaidAt.UTC().Format(time.RFC3339),
+		txn.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func transactionValues(txn *models.Transaction) []interface{} {
+	return []interface{}{
+		txn.ID,
+		txn.MerchantID,
+		txn.AmountCents,
+		txn.FeeCents,
+		string(txn.Status),
+		txn.PaidAt,
+		txn.CreatedAt,
+	}
+}
+
+// settlementRows are the columns of a streamed settlement listing
+var settlementRows = rowFormat[*models.Settlement]{
+	columns: []parquet.Column{
+		{Name: "id", Type: parquet.Int32},
+		{Name: "merchant_id", Type: parquet.String},
+		{Name: "date", Type: parquet.Date},
+		{Name: "gross_cents", Type: parquet.Int32},
+		{Name: "fee_cents", Type: parquet.Int32},
+		{Name: "net_cents", Type: parquet.Int32},
+		{Name: "txn_count", Type: parquet.Int32},
+		{Name: "generated_at", Type: parquet.Timestamp},
+		{Name: "unique_run_id", Type: parquet.String},
+		{Name: "stale_since", Type: parquet.Timestamp, Optional: true},
+		{Name: "stale_reason", Type: parquet.String, Optional: true},
+	},
+	record: settlementRecord,
+	values: settlementValues,
+}
+
+func settlementRecord(settlement *models.Settlement) []string {
+	var staleSince, staleReason string
+	if settlement.StaleSince != nil {
+		staleSince = settlement.StaleSince.UTC().Format(time.RFC3339)
+	}
+	if settlement.StaleReason != nil {
+		staleReason = *settlement.StaleReason
+	}
+
+	return []string{
+		strconv.Itoa(settlement.ID),
+		settlement.MerchantID,
+		settlement.Date.Format("2006-01-02"),
+		strconv.Itoa(settlement.GrossCents),
+		strconv.Itoa(settlement.FeeCents),
+		strconv.Itoa(settlement.NetCents),
+		strconv.Itoa(settlement.TxnCount),
+		settlement.GeneratedAt.UTC().Format(time.RFC3339),
+		settlement.UniqueRunID.String(),
+		staleSince,
+		staleReason,
+	}
+}
+
+func settlementValues(settlement *models.Settlement) []interface{} {
+	var staleSince, staleReason interface{}
+	if settlement.StaleSince != nil {
+		staleSince = *settlement.StaleSince
+	}
+	if settlement.StaleReason != nil {
+		staleReason = *settlement.StaleReason
+	}
+
+	return []interface{}{
+		settlement.ID,
+		settlement.MerchantID,
+		settlement.Date,
+		settlement.GrossCents,
+		settlement.FeeCents,
+		settlement.NetCents,
+		settlement.TxnCount,
+		settlement.GeneratedAt,
+		settlement.UniqueRunID.String(),
+		staleSince,
+		staleReason,
+	}
+}
@@ -0,0 +1,32 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"net/http"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateQueueToken handles POST /waiting-room/tokens
+func (h *Handlers) CreateQueueToken(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.CreateQueueTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
+		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	token, err := h.services.WaitingRoom.CreateQueueToken(ctx, &req)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, token)
+}
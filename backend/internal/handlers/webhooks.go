@@ -0,0 +1,146 @@
+// Package handlers provides HTTP request handlers
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/metrics"
+	"indico-backend/internal/models"
+	"indico-backend/internal/webhook"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+)
+
+// CreateWebhookEndpoint handles POST /webhooks
+func (h *Handlers) CreateWebhookEndpoint(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.CreateWebhookEndpointRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
+		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	endpoint, err := h.services.Webhook.CreateEndpoint(ctx, &req)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, endpoint)
+}
+
+// ListWebhookEndpoints handles GET /webhooks
+func (h *Handlers) ListWebhookEndpoints(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	endpoints, err := h.services.Webhook.ListEndpoints(ctx)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"webhooks": endpoints,
+	})
+}
+
+// DeleteWebhookEndpoint handles DELETE /webhooks/:id
+func (h *Handlers) DeleteWebhookEndpoint(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	id, ok := parseWebhookID(h, c)
+	if !ok {
+		return
+	}
+
+	if err := h.services.Webhook.DeleteEndpoint(ctx, id); err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// PingWebhookEndpoint handles POST /webhooks/:id/ping
+func (h *Handlers) PingWebhookEndpoint(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	id, ok := parseWebhookID(h, c)
+	if !ok {
+		return
+	}
+
+	ping, err := h.services.Webhook.Ping(ctx, id)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ping)
+}
+
+// parseWebhookID reads the endpoint ID path parameter, responding with a
+// validation error if it is not a UUID
+func parseWebhookID(h *Handlers, c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		logger.WithContext(c.Request.Context()).WithError(err).Error("Invalid webhook ID")
+		h.respondWithError(c, errors.NewValidationError("Invalid webhook ID"))
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// maxPaymentWebhookBytes caps gateway notification bodies, which are read
+// whole to verify their signature
+const maxPaymentWebhookBytes = 64 << 10
+
+// ReceivePaymentWebhook handles POST /webhooks/payments. The signature is
+// checked over the raw body before it is parsed; a 2xx tells the gateway the
+// notification needs no redelivery, including when it was a duplicate.
+func (h *Handlers) ReceivePaymentWebhook(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	if len(h.webhook.SigningKeys) == 0 {
+		h.respondWithError(c, errors.ErrPaymentWebhooksDisabled)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxPaymentWebhookBytes))
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
+		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	err = webhook.Verify(h.webhook.SigningKeys, c.GetHeader(webhook.TimestampHeader), c.GetHeader(webhook.SignatureHeader), body, h.webhook.TimestampTolerance, time.Now())
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Warn("Rejected payment webhook")
+		metrics.PaymentEvents.WithLabelValues("unknown", "rejected").Inc()
+		h.respondWithError(c, errors.ErrInvalidWebhookSignature)
+		return
+	}
+
+	var event models.PaymentEvent
+	if err := binding.JSON.BindBody(body, &event); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Invalid request body")
+		h.respondWithError(c, errors.NewValidationError("Invalid request body: "+err.Error()))
+		return
+	}
+
+	result, err := h.services.Transaction.ApplyPaymentEvent(ctx, &event)
+	if err != nil {
+		h.respondWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,168 @@
+// Package i18n provides localized messages and Accept-Language negotiation
+package i18n
+
+import (
+	"golang.org/x/text/language"
+)
+
+// DefaultLanguage is used when a client accepts none of the supported languages
+const DefaultLanguage = "en"
+
+// supported lists the available catalogs; the first entry is the fallback
+var supported = []language.Tag{
+	language.English,
+	language.Indonesian,
+}
+
+var matcher = language.NewMatcher(supported)
+
+// catalogs maps a language to its messages keyed by error code, or by a more
+// specific key for errors that share a generic code such as NOT_FOUND
+var catalogs = map[string]map[string]string{
+	"en": {
+		"PRODUCT_NOT_FOUND":         "Product not found",
+		"DUPLICATE_SKU":             "A product with this SKU already exists",
+		"DUPLICATE_BARCODE":         "A product with this barcode already exists",
+		"PRODUCT_IN_USE":            "The product has orders or belongs to a bundle or sales event",
+		"CLIENT_REFERENCE_CONFLICT": "An order with this client_reference already exists with different details",
+		"OUT_OF_STOCK":              "Insufficient stock",
+		"ORDER_QUEUE_FULL":          "Too many orders are waiting for this product; retry later",
+		"ORDER_NOT_FOUND":           "Order not found",
+		"ORDER_ALREADY_CANCELLED":   "Order is already cancelled",
+		"BUNDLE_NOT_FOUND":          "Bundle not found",
+		"SALES_EVENT_NOT_FOUND":     "Sales event not found",
+		"SALES_EVENT_OVERLAP":       "A product is already in a sales event that overlaps this one",
+		"SALE_NOT_STARTED":          "The product's sales event has not started",
+		"SALE_ENDED":                "The product's sales event has ended",
+		"PURCHASE_LIMIT_EXCEEDED":   "The order exceeds the sales event's per-buyer limit",
+		"QUEUE_TOKEN_REQUIRED":      "The product is behind a waiting room; order with a queue token",
+		"QUEUE_TOKEN_INVALID":       "The queue token is invalid for this order",
+		"QUEUE_TOKEN_EXPIRED":       "The queue token has expired; get a new one",
+		"QUEUE_TOKEN_NOT_ADMITTED":  "The queue token does not admit the order yet; retry later",
+		"WAITING_ROOM_FULL":         "The waiting room is full; retry later",
+		"JOB_NOT_FOUND":             "Job not found",
+		"JOB_STATS_NOT_FOUND":       "Job has no stats until it finishes",
+		"JOB_ALREADY_CANCELLED":     "Job is already cancelled",
+		"JOB_NOT_DEAD_LETTERED":     "Only dead-lettered jobs can be re-driven",
+		"JOB_RANGE_LOCKED":          "An overlapping job is already running for this date range",
+		"SETTLEMENT_RUN_NOT_FOUND":  "Settlement run not found",
+		"PAYOUT_NOT_FOUND":          "Payout not found",
+		"BANK_ACCOUNT_NOT_FOUND":    "The merchant has no bank account on file",
+		"PAYOUT_OVERLAP":            "The merchant already has a payout overlapping this period",
+		"PAYOUT_NOT_PENDING":        "Only pending payouts can be approved",
+		"NO_APPROVED_PAYOUTS":       "No approved payouts are waiting for a bank file",
+		"PAYOUT_SCHEME_DISABLED":    "Bank files are not configured for this scheme",
+		"SAGA_NOT_FOUND":            "Saga not found",
+		"EVENT_TYPE_NOT_FOUND":      "Event type not found",
+		"ERROR_TYPE_NOT_FOUND":      "Error type not found",
+		"WEBHOOK_NOT_FOUND":         "Webhook endpoint not found",
+		"NO_STALE_SETTLEMENTS":      "No settlements are flagged for re-settlement",
+		"TRANSACTION_NOT_FOUND":     "Transaction not found",
+		"FILE_NOT_FOUND":            "File not found",
+		"INVALID_STATUS_TRANSITION": "Invalid status transition",
+		"QUOTA_EXCEEDED":            "Too many active jobs for this client",
+		"QUEUE_FULL":                "The job queue is full; retry later",
+		"UNAUTHORIZED":              "Missing or invalid admin token",
+		"ADMIN_DISABLED":            "Admin endpoints are disabled",
+		"SEED_DISABLED":             "Seeding is disabled in this environment",
+		"DEBUG_DISABLED":            "Debug endpoints are disabled in this environment",
+		"SEARCH_DISABLED":           "Search is not configured",
+		"PAYMENT_WEBHOOKS_DISABLED": "Payment webhooks are not configured",
+		"INVALID_WEBHOOK_SIGNATURE": "Missing, invalid or expired webhook signature",
+		"INVALID_API_KEY":           "Invalid API key",
+		"AUTH_BLOCKED":              "Too many failed authentication attempts; retry later",
+		"REQUEST_TIMEOUT":           "The request took too long to complete",
+		"MAINTENANCE_MODE":          "The API is in maintenance mode; only reads are available",
+		"LOAD_SHED":                 "The database is under heavy load; deep pages are temporarily unavailable",
+		"OFFSET_TOO_DEEP":           "The offset is too deep; page with the cursor from next_cursor instead",
+		"INTERNAL_ERROR":            "Internal server error",
+	},
+	"id": {
+		"PRODUCT_NOT_FOUND":         "Produk tidak ditemukan",
+		"DUPLICATE_SKU":             "Produk dengan SKU ini sudah ada",
+		"DUPLICATE_BARCODE":         "Produk dengan barcode ini sudah ada",
+		"PRODUCT_IN_USE":            "Produk memiliki pesanan atau termasuk dalam bundel atau acara penjualan",
+		"CLIENT_REFERENCE_CONFLICT": "Pesanan dengan client_reference ini sudah ada dengan detail berbeda",
+		"OUT_OF_STOCK":              "Stok tidak mencukupi",
+		"ORDER_QUEUE_FULL":          "Terlalu banyak pesanan menunggu untuk produk ini; coba lagi nanti",
+		"ORDER_NOT_FOUND":           "Pesanan tidak ditemukan",
+		"ORDER_ALREADY_CANCELLED":   "Pesanan sudah dibatalkan",
+		"BUNDLE_NOT_FOUND":          "Paket produk tidak ditemukan",
+		"SALES_EVENT_NOT_FOUND":     "Acara penjualan tidak ditemukan",
+		"SALES_EVENT_OVERLAP":       "Produk sudah termasuk dalam acara penjualan lain yang waktunya tumpang tindih",
+		"SALE_NOT_STARTED":          "Acara penjualan produk ini belum dimulai",
+		"SALE_ENDED":                "Acara penjualan produk ini sudah berakhir",
+		"PURCHASE_LIMIT_EXCEEDED":   "Pesanan melebihi batas pembelian per pembeli pada acara penjualan",
+		"QUEUE_TOKEN_REQUIRED":      "Produk ini memakai ruang tunggu; pesan dengan token antrean",
+		"QUEUE_TOKEN_INVALID":       "Token antrean tidak berlaku untuk pesanan ini",
+		"QUEUE_TOKEN_EXPIRED":       "Token antrean sudah kedaluwarsa; minta token baru",
+		"QUEUE_TOKEN_NOT_ADMITTED":  "Token antrean belum mengizinkan pesanan; coba lagi nanti",
+		"WAITING_ROOM_FULL":         "Ruang tunggu penuh; coba lagi nanti",
+		"JOB_NOT_FOUND":             "Job tidak ditemukan",
+		"JOB_STATS_NOT_FOUND":       "Statistik job belum tersedia sampai job selesai",
+		"JOB_ALREADY_CANCELLED":     "Job sudah dibatalkan",
+		"JOB_NOT_DEAD_LETTERED":     "Hanya job dead-letter yang dapat dijalankan ulang",
+		"JOB_RANGE_LOCKED":          "Job lain untuk rentang tanggal ini sedang berjalan",
+		"SETTLEMENT_RUN_NOT_FOUND":  "Settlement run tidak ditemukan",
+		"PAYOUT_NOT_FOUND":          "Payout tidak ditemukan",
+		"BANK_ACCOUNT_NOT_FOUND":    "Merchant belum memiliki rekening bank",
+		"PAYOUT_OVERLAP":            "Merchant sudah memiliki payout yang periodenya tumpang tindih",
+		"PAYOUT_NOT_PENDING":        "Hanya payout yang tertunda yang dapat disetujui",
+		"NO_APPROVED_PAYOUTS":       "Tidak ada payout disetujui yang menunggu berkas bank",
+		"PAYOUT_SCHEME_DISABLED":    "Berkas bank belum dikonfigurasi untuk skema ini",
+		"SAGA_NOT_FOUND":            "Saga tidak ditemukan",
+		"EVENT_TYPE_NOT_FOUND":      "Jenis event tidak ditemukan",
+		"ERROR_TYPE_NOT_FOUND":      "Jenis error tidak ditemukan",
+		"WEBHOOK_NOT_FOUND":         "Endpoint webhook tidak ditemukan",
+		"NO_STALE_SETTLEMENTS":      "Tidak ada settlement yang perlu dihitung ulang",
+		"TRANSACTION_NOT_FOUND":     "Transaksi tidak ditemukan",
+		"FILE_NOT_FOUND":            "Berkas tidak ditemukan",
+		"INVALID_STATUS_TRANSITION": "Perubahan status tidak diizinkan",
+		"QUOTA_EXCEEDED":            "Terlalu banyak job aktif untuk klien ini",
+		"QUEUE_FULL":                "Antrean job penuh; coba lagi nanti",
+		"UNAUTHORIZED":              "Token admin tidak ada atau tidak valid",
+		"ADMIN_DISABLED":            "Endpoint admin dinonaktifkan",
+		"SEED_DISABLED":             "Seeding dinonaktifkan di lingkungan ini",
+		"DEBUG_DISABLED":            "Endpoint debug dinonaktifkan di lingkungan ini",
+		"SEARCH_DISABLED":           "Pencarian belum dikonfigurasi",
+		"PAYMENT_WEBHOOKS_DISABLED": "Webhook pembayaran belum dikonfigurasi",
+		"INVALID_WEBHOOK_SIGNATURE": "Tanda tangan webhook tidak ada, tidak valid, atau kedaluwarsa",
+		"INVALID_API_KEY":           "Kunci API tidak valid",
+		"AUTH_BLOCKED":              "Terlalu banyak percobaan autentikasi yang gagal; coba lagi nanti",
+		"REQUEST_TIMEOUT":           "Permintaan terlalu lama untuk diselesaikan",
+		"MAINTENANCE_MODE":          "API sedang dalam mode pemeliharaan; hanya pembacaan yang tersedia",
+		"LOAD_SHED":                 "Database sedang sibuk; halaman yang dalam untuk sementara tidak tersedia",
+		"OFFSET_TOO_DEEP":           "Offset terlalu dalam; gunakan cursor dari next_cursor",
+		"INTERNAL_ERROR":            "Terjadi kesalahan pada server",
+	},
+}
+
+// Negotiate picks the best supported language for an Accept-Language header
+func Negotiate(acceptLanguage string) string {
+	if acceptLanguage == "" {
+		return DefaultLanguage
+	}
+
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return DefaultLanguage
+	}
+
+	_, index, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return DefaultLanguage
+	}
+
+	base, _ := supported[index].Base()
+	return base.String()
+}
+
+// Message returns the message for key in lang, reporting whether one exists
+func Message(lang, key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+
+	message, ok := catalogs[lang][key]
+	return message, ok
+}
@@ -21,6 +21,7 @@ const (
 	RequestIDKey ContextKey = "request_id"
 	UserIDKey    ContextKey = "user_id"
 	TraceIDKey   ContextKey = "trace_id"
+	PrincipalKey ContextKey = "principal"
 )
 
 // New creates a new logger instance
@@ -34,17 +35,19 @@ func New(level, format string) *Logger {
 	}
 	log.SetLevel(lvl)
 
-	// Set formatter
+	// Set formatter, masking personal data until SetRedactFields says otherwise
+	var formatter logrus.Formatter
 	if format == "json" {
-		log.SetFormatter(&logrus.JSONFormatter{
+		formatter = &logrus.JSONFormatter{
 			TimestampFormat: time.RFC3339,
-		})
+		}
 	} else {
-		log.SetFormatter(&logrus.TextFormatter{
+		formatter = &logrus.TextFormatter{
 			FullTimestamp:   true,
 			TimestampFormat: time.RFC3339,
-		})
+		}
 	}
+	log.SetFormatter(newRedactingFormatter(formatter, DefaultRedactFields))
 
 	log.SetOutput(os.Stdout)
 
@@ -67,6 +70,10 @@ func (l *Logger) WithContext(ctx context.Context) *logrus.Entry {
 		entry = entry.WithField("trace_id", traceID)
 	}
 
+	if principal := ctx.Value(PrincipalKey); principal != nil {
+		entry = entry.WithField("principal", principal)
+	}
+
 	return entry
 }
 
@@ -110,6 +117,25 @@ func GetLogger() *Logger {
 	return globalLogger
 }
 
+// AddHook adds a hook to the global logger
+func AddHook(hook logrus.Hook) {
+	GetLogger().AddHook(hook)
+}
+
+// RemoveHook removes a hook from the global logger
+func RemoveHook(hook logrus.Hook) {
+	log := GetLogger()
+	hooks := make(logrus.LevelHooks)
+	for level, levelHooks := range log.Hooks {
+		for _, h := range levelHooks {
+			if h != hook {
+				hooks[level] = append(hooks[level], h)
+			}
+		}
+	}
+	log.ReplaceHooks(hooks)
+}
+
 // Convenience functions for global logger
 func WithContext(ctx context.Context) *logrus.Entry {
 	return GetLogger().WithContext(ctx)
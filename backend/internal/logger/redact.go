@@ -0,0 +1,127 @@
+// Package logger provides redaction of personal data in log fields
+package logger
+
+import (
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultRedactFields are the field names masked in every log line unless
+// SetRedactFields configures others
+var DefaultRedactFields = []string{"buyer_id", "email", "token", "password", "authorization", "secret"}
+
+// redactedValue replaces the value of a redacted field
+const redactedValue = "[REDACTED]"
+
+// redactingFormatter masks sensitive fields before the wrapped formatter
+// renders an entry, so a new log line can't leak them by accident
+type redactingFormatter struct {
+	logrus.Formatter
+	fields []string
+}
+
+// newRedactingFormatter wraps inner, replacing any redaction it already has
+func newRedactingFormatter(inner logrus.Formatter, fields []string) *redactingFormatter {
+	if r, ok := inner.(*redactingFormatter); ok {
+		inner = r.Formatter
+	}
+
+	normalized := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if field = strings.ToLower(strings.TrimSpace(field)); field != "" {
+			normalized = append(normalized, field)
+		}
+	}
+
+	return &redactingFormatter{Formatter: inner, fields: normalized}
+}
+
+// Format implements logrus.Formatter
+func (f *redactingFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	if len(f.fields) == 0 || !f.needsRedaction(entry.Data) {
+		return f.Formatter.Format(entry)
+	}
+
+	// Redact a copy so hooks and other formatters still see the entry as logged
+	redacted := *entry
+	redacted.Data = f.redactFields(entry.Data)
+	return f.Formatter.Format(&redacted)
+}
+
+// sensitive reports whether a field name matches a redacted name, either
+// exactly or as a prefix or suffix word, so buyer_email and token_hash are
+// caught by email and token
+func (f *redactingFormatter) sensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, field := range f.fields {
+		if key == field || strings.HasSuffix(key, "_"+field) || strings.HasPrefix(key, field+"_") {
+			return true
+		}
+	}
+	return false
+}
+
+// needsRedaction reports whether any field, at any depth, is sensitive
+func (f *redactingFormatter) needsRedaction(data map[string]interface{}) bool {
+	for key, value := range data {
+		if f.sensitive(key) {
+			return true
+		}
+		if nested, ok := value.(map[string]interface{}); ok && f.needsRedaction(nested) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactFields returns a copy of data with sensitive values masked
+func (f *redactingFormatter) redactFields(data map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		switch nested := value.(type) {
+		case map[string]interface{}:
+			if f.sensitive(key) {
+				redacted[key] = redactedValue
+			} else {
+				redacted[key] = f.redactFields(nested)
+			}
+		default:
+			if f.sensitive(key) && value != nil {
+				redacted[key] = redactedValue
+			} else {
+				redacted[key] = value
+			}
+		}
+	}
+	return redacted
+}
+
+// Redact returns a copy of data with the fields log lines mask masked, for
+// log data stored elsewhere than the log output
+func (l *Logger) Redact(data map[string]interface{}) map[string]interface{} {
+	if f, ok := l.Logger.Formatter.(*redactingFormatter); ok && len(f.fields) > 0 {
+		return f.redactFields(data)
+	}
+	redacted := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		redacted[key] = value
+	}
+	return redacted
+}
+
+// Redact masks data with the global logger's redacted fields
+func Redact(data map[string]interface{}) map[string]interface{} {
+	return GetLogger().Redact(data)
+}
+
+// SetRedactFields sets the field names masked in log lines; an empty list
+// turns redaction off
+func (l *Logger) SetRedactFields(fields []string) {
+	l.Logger.SetFormatter(newRedactingFormatter(l.Logger.Formatter, fields))
+}
+
+// SetRedactFields sets the field names the global logger masks
+func SetRedactFields(fields []string) {
+	GetLogger().SetRedactFields(fields)
+}
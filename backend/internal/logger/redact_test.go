@@ -0,0 +1,45 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactingFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	log := New("info", "json")
+	log.SetOutput(&buf)
+
+	log.WithFields(logrus.Fields{
+		"buyer_id":       "buyer_123",
+		"customer_email": "jane@example.com",
+		"token_hash":     "abc",
+		"order_id":       "o-1",
+		"payload":        map[string]interface{}{"Password": "hunter2", "quantity": 2},
+	}).Info("Order created")
+
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+
+	assert.Equal(t, redactedValue, line["buyer_id"])
+	assert.Equal(t, redactedValue, line["customer_email"])
+	assert.Equal(t, redactedValue, line["token_hash"])
+	assert.Equal(t, "o-1", line["order_id"])
+	assert.Equal(t, map[string]interface{}{"Password": redactedValue, "quantity": float64(2)}, line["payload"])
+	assert.NotContains(t, buf.String(), "buyer_123")
+
+	// Configured fields replace the defaults
+	buf.Reset()
+	log.SetRedactFields([]string{"order_id"})
+	log.WithField("buyer_id", "buyer_123").WithField("order_id", "o-1").Info("Order created")
+
+	line = nil
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "buyer_123", line["buyer_id"])
+	assert.Equal(t, redactedValue, line["order_id"])
+}
@@ -0,0 +1,27 @@
+// Package metrics provides exemplars linking latency histograms to traces
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"indico-backend/internal/logger"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ObserveDuration records the time since start on a histogram. When ctx
+// carries a trace ID the observation is recorded with it as an exemplar, so
+// a slow bucket in Grafana leads to the trace behind it.
+func ObserveDuration(ctx context.Context, observer prometheus.Observer, start time.Time) {
+	seconds := time.Since(start).Seconds()
+
+	if traceID, ok := ctx.Value(logger.TraceIDKey).(string); ok && traceID != "" {
+		if eo, ok := observer.(prometheus.ExemplarObserver); ok {
+			eo.ObserveWithExemplar(seconds, prometheus.Labels{"trace_id": traceID})
+			return
+		}
+	}
+
+	observer.Observe(seconds)
+}
@@ -2,6 +2,8 @@
 package metrics
 
 import (
+	"strconv"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -33,11 +35,163 @@ var (
 		},
 	)
 
-	OrdersOutOfStock = promauto.NewCounter(
+	OrdersCancelled = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "orders_cancelled_total",
+			Help: "Total number of orders cancelled on request",
+		},
+	)
+
+	OrdersOutOfStock = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "orders_out_of_stock_total",
 			Help: "Total number of orders that failed due to out of stock",
 		},
+		[]string{"product_bucket"},
+	)
+
+	OrderConflicts = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_concurrency_conflicts_total",
+			Help: "Total number of order attempts that lost a concurrent stock update",
+		},
+		[]string{"product_bucket"},
+	)
+
+	OrderRetries = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_retries_total",
+			Help: "Total number of order attempts retried after a concurrency conflict",
+		},
+		[]string{"product_bucket"},
+	)
+
+	OrdersDeduplicated = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "orders_deduplicated_total",
+			Help: "Total number of repeated order requests answered with the order already placed",
+		},
+		[]string{"source"},
+	)
+
+	OrderQueueDepth = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "order_queue_depth",
+			Help: "Number of orders waiting in the per-product order queues",
+		},
+	)
+
+	OrderQueueWait = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "order_queue_wait_seconds",
+			Help:    "Time orders wait in a per-product order queue before being placed",
+			Buckets: []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		},
+	)
+
+	OrdersQueueRejected = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "orders_queue_rejected_total",
+			Help: "Total number of orders rejected because their order queue was full",
+		},
+	)
+
+	// Stock fast path metrics
+	FastPathReservations = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "stock_fast_path_reservations_total",
+			Help: "Total number of orders the Redis stock fast path accepted or rejected, by result",
+		},
+		[]string{"result"},
+	)
+
+	FastPathWrites = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "stock_fast_path_writes_total",
+			Help: "Total number of fast path orders written to Postgres, by result (placed, cancelled, failed)",
+		},
+		[]string{"result"},
+	)
+
+	FastPathBacklog = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "stock_fast_path_backlog",
+			Help: "Number of fast path orders queued in Redis and not yet written",
+		},
+	)
+
+	FastPathRequeued = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "stock_fast_path_requeued_total",
+			Help: "Total number of fast path orders requeued after stalling mid-write",
+		},
+	)
+
+	FastPathCorrections = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "stock_fast_path_corrections_total",
+			Help: "Total number of cached product stock values reset to the database value",
+		},
+	)
+
+	// Sales event metrics
+	SalesEventOrders = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sales_event_orders_total",
+			Help: "Total number of orders for sales event products, by result (accepted, not_started, ended, limit_exceeded)",
+		},
+		[]string{"result"},
+	)
+
+	// Waiting room metrics
+	WaitingRoomTokens = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "waiting_room_tokens_total",
+			Help: "Total number of queue token requests, by result (issued, reissued, full)",
+		},
+		[]string{"result"},
+	)
+
+	WaitingRoomChecks = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "waiting_room_checks_total",
+			Help: "Total number of orders checked by the waiting room, by result (admitted, missing, invalid, early, expired)",
+		},
+		[]string{"result"},
+	)
+
+	// Authentication metrics
+	AuthFailures = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "auth_failures_total",
+			Help: "Total number of requests with an invalid admin token or API key, by credential",
+		},
+		[]string{"credential"},
+	)
+
+	AuthBans = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "auth_bans_total",
+			Help: "Total number of bans for repeated failed authentication, by scope (ip, credential)",
+		},
+		[]string{"scope"},
+	)
+
+	AuthBlocked = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "auth_blocked_total",
+			Help: "Total number of requests rejected while their client or credential was banned, by scope (ip, credential)",
+		},
+		[]string{"scope"},
+	)
+
+	// Saga metrics
+	SagasFinished = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sagas_finished_total",
+			Help: "Total number of sagas that completed, were compensated or failed compensation",
+		},
+		[]string{"type", "status"},
 	)
 
 	// Job metrics
@@ -66,6 +220,159 @@ var (
 		[]string{"type"},
 	)
 
+	JobRetries = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "job_retries_total",
+			Help: "Total number of failed job attempts that were retried",
+		},
+		[]string{"type"},
+	)
+
+	JobsDeadLettered = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "jobs_dead_lettered_total",
+			Help: "Total number of jobs dead-lettered after exhausting their retries",
+		},
+		[]string{"type"},
+	)
+
+	JobsRedriven = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "jobs_redriven_total",
+			Help: "Total number of dead-lettered jobs re-driven",
+		},
+		[]string{"type"},
+	)
+
+	JobsRecovered = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "jobs_recovered_total",
+			Help: "Total number of jobs left queued or running by a previous process and picked up at startup",
+		},
+		[]string{"type", "outcome"},
+	)
+
+	DeadLetterJobs = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "jobs_dead_letter_size",
+			Help: "Number of jobs currently dead-lettered",
+		},
+	)
+
+	JobLogLinesDropped = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "job_log_lines_dropped_total",
+			Help: "Total number of job log lines not stored, by reason (limit, buffer_full)",
+		},
+		[]string{"reason"},
+	)
+
+	JobProgressWebhooks = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "job_progress_webhooks_total",
+			Help: "Total number of job progress callbacks, by result (delivered, failed, dropped)",
+		},
+		[]string{"result"},
+	)
+
+	JobsRejected = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "jobs_rejected_total",
+			Help: "Total number of jobs rejected because the queue was full",
+		},
+		[]string{"type"},
+	)
+
+	JobQueueDepth = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "job_queue_depth",
+			Help: "Number of jobs waiting in the in-memory queue",
+		},
+	)
+
+	JobQueueWait = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "job_queue_wait_seconds",
+			Help:    "Time from a job's creation until a worker started it",
+			Buckets: []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300, 600, 1800, 3600},
+		},
+		[]string{"type"},
+	)
+
+	JobsByStatus = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "jobs_by_status",
+			Help: "Number of jobs queued or running across all replicas, read from the database",
+		},
+		[]string{"type", "status"},
+	)
+
+	JobsFailedLastHour = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "jobs_failed_last_hour",
+			Help: "Number of jobs that failed or were dead-lettered in the last hour",
+		},
+		[]string{"type"},
+	)
+
+	JobOldestQueued = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "job_oldest_queued_seconds",
+			Help: "Age of the oldest queued job",
+		},
+		[]string{"type"},
+	)
+
+	LeaderElected = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "leader_elected",
+			Help: "Whether this replica is the leader for a singleton task (1) or not (0)",
+		},
+		[]string{"election"},
+	)
+
+	// Settlement metrics
+	SettlementsSkipped = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "settlements_skipped_total",
+			Help: "Total number of settlements a run failed to write and left to the previous run",
+		},
+	)
+
+	// Search metrics
+	SearchDocumentsIndexed = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "search_documents_indexed_total",
+			Help: "Total number of documents sent to the search index",
+		},
+		[]string{"index"},
+	)
+
+	SearchSyncErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "search_sync_errors_total",
+			Help: "Total number of failed search index sync passes",
+		},
+		[]string{"index"},
+	)
+
+	// Projection metrics
+	ProjectedRows = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "projection_rows_total",
+			Help: "Total number of rows upserted into read-model projections",
+		},
+		[]string{"projection"},
+	)
+
+	ProjectionErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "projection_errors_total",
+			Help: "Total number of failed projection passes",
+		},
+		[]string{"projection"},
+	)
+
 	// Database metrics
 	DatabaseConnections = promauto.NewGauge(
 		prometheus.GaugeOpts{
@@ -81,8 +388,77 @@ var (
 		},
 		[]string{"operation"},
 	)
+
+	DatabaseQueryDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "database_query_duration_seconds",
+			Help:    "Duration of hot order-path queries by path (prepared or adhoc)",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5},
+		},
+		[]string{"query", "path"},
+	)
+
+	PricingHookVariants = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_pricing_hook_evaluations_total",
+			Help: "Total number of order pricings per pricing hook, by whether the buyer got the hook (treatment) or not (control)",
+		},
+		[]string{"hook", "variant"},
+	)
+
+	PaymentEvents = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "payment_webhook_events_total",
+			Help: "Total number of payment gateway notifications received, by type and outcome",
+		},
+		[]string{"type", "outcome"},
+	)
+
+	DatabasePoolWait = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "database_pool_wait_seconds",
+			Help: "Average wait for a main-pool connection over the last sample interval",
+		},
+	)
+
+	DatabaseSaturated = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "database_saturated",
+			Help: "Whether the main pool is saturated and list endpoints are shedding load (1) or not (0)",
+		},
+	)
+
+	RequestsShed = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_requests_shed_total",
+			Help: "Total number of list requests degraded while the database was saturated, by action",
+		},
+		[]string{"action"},
+	)
+
+	CoalescedReads = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "database_coalesced_reads_total",
+			Help: "Total number of reads served by another caller's in-flight query",
+		},
+		[]string{"operation"},
+	)
 )
 
+// productBucketSize is how many consecutive product IDs share a bucket label
+const productBucketSize = 1000
+
+// ProductBucket labels a product by the range of IDs it falls in, e.g.
+// "1000-1999", so stock contention can be located without a series per
+// product
+func ProductBucket(productID int) string {
+	if productID < 0 {
+		return "invalid"
+	}
+	start := productID / productBucketSize * productBucketSize
+	return strconv.Itoa(start) + "-" + strconv.Itoa(start+productBucketSize-1)
+}
+
 // Init initializes metrics (using promauto, metrics are auto-registered)
 func Init() {
 	// Metrics are automatically registered by promauto
@@ -2,6 +2,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -9,13 +10,49 @@ import (
 
 // Product represents a product in the system
 type Product struct {
-	ID        int       `json:"id" db:"id"`
-	Name      string    `json:"name" db:"name"`
-	Stock     int       `json:"stock" db:"stock"`
-	Price     int       `json:"price" db:"price"`     // in cents
-	Version   int       `json:"version" db:"version"` // for optimistic locking
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID             int       `json:"id" db:"id"`
+	Name           string    `json:"name" db:"name"`
+	SKU            *string   `json:"sku,omitempty" db:"sku"`
+	Barcode        *string   `json:"barcode,omitempty" db:"barcode"`
+	Stock          int       `json:"stock" db:"stock"`
+	Price          int       `json:"price" db:"price"`                     // in cents
+	Version        int       `json:"version" db:"version"`                 // for optimistic locking
+	BackorderLimit int       `json:"backorder_limit" db:"backorder_limit"` // units orderable beyond stock, 0 for none
+	Backordered    int       `json:"backordered" db:"backordered"`         // units on orders awaiting stock
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// ProductSnapshot is the compact form of a product served to downstream
+// caches and search indexes
+type ProductSnapshot struct {
+	ID        int       `json:"id"`
+	SKU       *string   `json:"sku,omitempty"`
+	Price     int       `json:"price"` // in cents
+	Stock     int       `json:"stock"`
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// MovementReason explains why a product's stock changed
+type MovementReason string
+
+const (
+	MovementReasonStockSync MovementReason = "STOCK_SYNC"
+	// MovementReasonAdjustment is a stock level set through the product API
+	MovementReasonAdjustment MovementReason = "ADJUSTMENT"
+)
+
+// InventoryMovement records one change to a product's stock level
+type InventoryMovement struct {
+	ID            int64          `json:"id" db:"id"`
+	ProductID     int            `json:"product_id" db:"product_id"`
+	SKU           string         `json:"sku" db:"sku"`
+	PreviousStock int            `json:"previous_stock" db:"previous_stock"`
+	NewStock      int            `json:"new_stock" db:"new_stock"`
+	Delta         int            `json:"delta" db:"delta"`
+	Reason        MovementReason `json:"reason" db:"reason"`
+	CreatedAt     time.Time      `json:"created_at" db:"created_at"`
 }
 
 // Order represents an order in the system
@@ -26,9 +63,100 @@ type Order struct {
 	Quantity   int         `json:"quantity" db:"quantity"`
 	Status     OrderStatus `json:"status" db:"status"`
 	TotalCents int         `json:"total_cents" db:"total_cents"`
-	CreatedAt  time.Time   `json:"created_at" db:"created_at"`
-	UpdatedAt  time.Time   `json:"updated_at" db:"updated_at"`
-	Product    *Product    `json:"product,omitempty"` // for joins
+	// ClientReference is the client's own identifier for the order, unique
+	// across orders
+	ClientReference *string   `json:"client_reference,omitempty" db:"client_reference"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
+	Product         *Product  `json:"product,omitempty"` // for joins
+	// Components is the stock a bundle order took from each component
+	Components []*OrderComponent `json:"components,omitempty"`
+	// SalesEventID is the sales event the order was placed in
+	SalesEventID *int `json:"sales_event_id,omitempty" db:"sales_event_id"`
+}
+
+// OrderComponent is the stock a bundle order took from one component
+// product: the bundle's component quantity times the order quantity
+type OrderComponent struct {
+	ProductID int `json:"product_id" db:"product_id"`
+	Quantity  int `json:"quantity" db:"quantity"`
+}
+
+// BundleComponent is one product a bundle is made of, with the quantity of
+// it each bundle takes
+type BundleComponent struct {
+	ProductID int `json:"product_id" db:"product_id" binding:"required,min=1"`
+	Quantity  int `json:"quantity" db:"quantity" binding:"required,min=1"`
+}
+
+// Bundle is a product sold as a set of component products. It has no stock
+// of its own; Available is how many bundles the components' stock covers.
+type Bundle struct {
+	ID         int                `json:"id"`
+	Name       string             `json:"name"`
+	SKU        *string            `json:"sku,omitempty"`
+	Price      int                `json:"price"` // in cents
+	Components []*BundleComponent `json:"components"`
+	Available  int                `json:"available"`
+	CreatedAt  time.Time          `json:"created_at"`
+	UpdatedAt  time.Time          `json:"updated_at"`
+}
+
+// SalesEvent is a flash sale: its products are sold only between StartsAt
+// and EndsAt, and each buyer takes at most PerBuyerLimit units of each
+type SalesEvent struct {
+	ID            int       `json:"id" db:"id"`
+	Name          string    `json:"name" db:"name"`
+	StartsAt      time.Time `json:"starts_at" db:"starts_at"`
+	EndsAt        time.Time `json:"ends_at" db:"ends_at"`
+	PerBuyerLimit int       `json:"per_buyer_limit" db:"per_buyer_limit"`
+	ProductIDs    []int     `json:"product_ids"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// SalesEventStatus is where a sales event is relative to its window
+type SalesEventStatus string
+
+const (
+	SalesEventScheduled SalesEventStatus = "SCHEDULED"
+	SalesEventLive      SalesEventStatus = "LIVE"
+	SalesEventEnded     SalesEventStatus = "ENDED"
+)
+
+// Status returns where the event is relative to its window at now
+func (e *SalesEvent) Status(now time.Time) SalesEventStatus {
+	switch {
+	case now.Before(e.StartsAt):
+		return SalesEventScheduled
+	case now.Before(e.EndsAt):
+		return SalesEventLive
+	default:
+		return SalesEventEnded
+	}
+}
+
+// SalesEventStats reports a sales event's orders so far. Orders accepted
+// on the stock fast path count once they are written to the database.
+type SalesEventStats struct {
+	EventID      int                       `json:"event_id"`
+	Status       SalesEventStatus          `json:"status"`
+	Orders       int                       `json:"orders"`
+	Units        int                       `json:"units"`
+	RevenueCents int64                     `json:"revenue_cents"`
+	Products     []*SalesEventProductStats `json:"products"`
+	GeneratedAt  time.Time                 `json:"generated_at"`
+}
+
+// SalesEventProductStats reports one product's orders in a sales event;
+// cancelled orders are left out
+type SalesEventProductStats struct {
+	ProductID    int   `json:"product_id" db:"product_id"`
+	Orders       int   `json:"orders" db:"orders"`
+	Units        int   `json:"units" db:"units"`
+	Buyers       int   `json:"buyers" db:"buyers"`
+	RevenueCents int64 `json:"revenue_cents" db:"revenue_cents"`
+	Stock        int   `json:"stock" db:"stock"`
 }
 
 // OrderStatus represents the status of an order
@@ -38,6 +166,69 @@ const (
 	OrderStatusPending   OrderStatus = "PENDING"
 	OrderStatusConfirmed OrderStatus = "CONFIRMED"
 	OrderStatusCancelled OrderStatus = "CANCELLED"
+	// OrderStatusBackordered is an order placed beyond a product's stock,
+	// confirmed by a fulfillment job once stock arrives
+	OrderStatusBackordered OrderStatus = "BACKORDERED"
+)
+
+// OrderEventType names a step in an order's history
+type OrderEventType string
+
+const (
+	OrderEventCreated         OrderEventType = "ORDER_CREATED"
+	OrderEventStockReserved   OrderEventType = "STOCK_RESERVED"
+	OrderEventConfirmed       OrderEventType = "ORDER_CONFIRMED"
+	OrderEventStockReleased   OrderEventType = "STOCK_RELEASED"
+	OrderEventCancelled       OrderEventType = "ORDER_CANCELLED"
+	OrderEventPaymentCaptured OrderEventType = "PAYMENT_CAPTURED"
+	OrderEventPaymentRefunded OrderEventType = "PAYMENT_REFUNDED"
+	OrderEventPaymentFailed   OrderEventType = "PAYMENT_FAILED"
+	OrderEventPaymentPending  OrderEventType = "PAYMENT_PENDING"
+	OrderEventBackordered     OrderEventType = "ORDER_BACKORDERED"
+)
+
+// OrderEvent is one entry of an order's timeline, recorded in the
+// transaction that took the step
+type OrderEvent struct {
+	ID         int64          `json:"id" db:"id"`
+	OrderID    uuid.UUID      `json:"order_id" db:"order_id"`
+	Type       OrderEventType `json:"type" db:"type"`
+	Detail     string         `json:"detail,omitempty" db:"detail"`
+	OccurredAt time.Time      `json:"occurred_at" db:"occurred_at"`
+}
+
+// Saga represents the persisted state of a multi-step workflow whose
+// completed steps are undone by compensating actions if a later step fails
+type Saga struct {
+	ID             uuid.UUID       `json:"id" db:"id"`
+	Type           SagaType        `json:"type" db:"type"`
+	Status         SagaStatus      `json:"status" db:"status"`
+	Payload        json.RawMessage `json:"payload" db:"payload"`
+	CompletedSteps int             `json:"completed_steps" db:"completed_steps"`
+	FailedStep     *string         `json:"failed_step,omitempty" db:"failed_step"`
+	Error          *string         `json:"error,omitempty" db:"error"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// SagaType identifies a saga definition
+type SagaType string
+
+const (
+	SagaTypeOrderPlacement SagaType = "ORDER_PLACEMENT"
+)
+
+// SagaStatus represents the status of a saga
+type SagaStatus string
+
+const (
+	SagaStatusRunning      SagaStatus = "RUNNING"
+	SagaStatusCompleted    SagaStatus = "COMPLETED"
+	SagaStatusCompensating SagaStatus = "COMPENSATING"
+	// SagaStatusCompensated marks a failed saga whose completed steps were all undone
+	SagaStatusCompensated SagaStatus = "COMPENSATED"
+	// SagaStatusFailed marks a saga whose compensation failed and needs attention
+	SagaStatusFailed SagaStatus = "FAILED"
 )
 
 // Transaction represents a financial transaction
@@ -49,6 +240,10 @@ type Transaction struct {
 	Status      TransactionStatus `json:"status" db:"status"`
 	PaidAt      time.Time         `json:"paid_at" db:"paid_at"`
 	CreatedAt   time.Time         `json:"created_at" db:"created_at"`
+	// PaymentID is the payment gateway's ID for transactions it reported,
+	// and OrderID the order paid for when the gateway sent it
+	PaymentID *string    `json:"payment_id,omitempty" db:"payment_id"`
+	OrderID   *uuid.UUID `json:"order_id,omitempty" db:"order_id"`
 }
 
 // TransactionStatus represents the status of a transaction
@@ -58,46 +253,454 @@ const (
 	TransactionStatusPending   TransactionStatus = "PENDING"
 	TransactionStatusCompleted TransactionStatus = "COMPLETED"
 	TransactionStatusFailed    TransactionStatus = "FAILED"
+	TransactionStatusRefunded  TransactionStatus = "REFUNDED"
 )
 
+// TransactionStatusChange represents the outcome of a transaction status
+// transition and the settlements it invalidated
+type TransactionStatusChange struct {
+	Transaction      *Transaction      `json:"transaction"`
+	PreviousStatus   TransactionStatus `json:"previous_status"`
+	StaleSettlements []*Settlement     `json:"stale_settlements"`
+}
+
 // Settlement represents an aggregated settlement
 type Settlement struct {
-	ID          int       `json:"id" db:"id"`
-	MerchantID  string    `json:"merchant_id" db:"merchant_id"`
-	Date        time.Time `json:"date" db:"date"`
-	GrossCents  int       `json:"gross_cents" db:"gross_cents"`
-	FeeCents    int       `json:"fee_cents" db:"fee_cents"`
-	NetCents    int       `json:"net_cents" db:"net_cents"`
-	TxnCount    int       `json:"txn_count" db:"txn_count"`
-	GeneratedAt time.Time `json:"generated_at" db:"generated_at"`
-	UniqueRunID uuid.UUID `json:"unique_run_id" db:"unique_run_id"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	ID           int        `json:"id" db:"id"`
+	MerchantID   string     `json:"merchant_id" db:"merchant_id"`
+	Date         time.Time  `json:"date" db:"date"`
+	GrossCents   int        `json:"gross_cents" db:"gross_cents"`
+	FeeCents     int        `json:"fee_cents" db:"fee_cents"`
+	NetCents     int        `json:"net_cents" db:"net_cents"`
+	TxnCount     int        `json:"txn_count" db:"txn_count"`
+	GeneratedAt  time.Time  `json:"generated_at" db:"generated_at"`
+	UniqueRunID  uuid.UUID  `json:"unique_run_id" db:"unique_run_id"`
+	SupersededBy *uuid.UUID `json:"superseded_by,omitempty" db:"superseded_by"`
+	StaleSince   *time.Time `json:"stale_since,omitempty" db:"stale_since"`
+	StaleReason  *string    `json:"stale_reason,omitempty" db:"stale_reason"`
+	// CutoffAt is the watermark the settlement was computed with: only
+	// transactions recorded by then are included. Nil includes every
+	// transaction recorded before GeneratedAt.
+	CutoffAt  *time.Time `json:"cutoff_at,omitempty" db:"cutoff_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
 }
 
-// Job represents a background job
-type Job struct {
-	ID          uuid.UUID  `json:"id" db:"id"`
-	Type        JobType    `json:"type" db:"type"`
-	Status      JobStatus  `json:"status" db:"status"`
-	Progress    float64    `json:"progress" db:"progress"`
-	Processed   int        `json:"processed" db:"processed"`
-	Total       int        `json:"total" db:"total"`
-	Parameters  string     `json:"parameters" db:"parameters"` // JSON
-	ResultPath  *string    `json:"result_path,omitempty" db:"result_path"`
-	DownloadURL *string    `json:"download_url,omitempty" db:"download_url"`
-	Error       *string    `json:"error,omitempty" db:"error"`
-	StartedAt   *time.Time `json:"started_at,omitempty" db:"started_at"`
-	CompletedAt *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+// AdjustmentReason classifies a settlement adjustment
+type AdjustmentReason string
+
+const (
+	AdjustmentReasonDispute       AdjustmentReason = "DISPUTE"
+	AdjustmentReasonChargeback    AdjustmentReason = "CHARGEBACK"
+	AdjustmentReasonFeeCorrection AdjustmentReason = "FEE_CORRECTION"
+	AdjustmentReasonCorrection    AdjustmentReason = "CORRECTION"
+)
+
+// SettlementAdjustment represents a manual correction to a merchant's
+// settlement day, added to its net amount. AmountCents is negative for a
+// debit from the merchant.
+type SettlementAdjustment struct {
+	ID          int              `json:"id" db:"id"`
+	MerchantID  string           `json:"merchant_id" db:"merchant_id"`
+	Date        time.Time        `json:"date" db:"date"`
+	AmountCents int              `json:"amount_cents" db:"amount_cents"`
+	ReasonCode  AdjustmentReason `json:"reason_code" db:"reason_code"`
+	Note        string           `json:"note" db:"note"`
+	CreatedAt   time.Time        `json:"created_at" db:"created_at"`
+}
+
+// MerchantDayActivity represents the most recent completed transaction
+// recorded for a merchant on one UTC payment day
+type MerchantDayActivity struct {
+	MerchantID    string    `json:"merchant_id" db:"merchant_id"`
+	Date          time.Time `json:"date" db:"date"`
+	LastCreatedAt time.Time `json:"last_created_at" db:"last_created_at"`
+}
+
+// StaleDetection represents the outcome of a staleness scan over a range of
+// settlement dates
+type StaleDetection struct {
+	From    string        `json:"from"`
+	To      string        `json:"to"`
+	Checked int           `json:"checked"`
+	Flagged []*Settlement `json:"flagged"`
+}
+
+// LateTransaction represents a completed transaction recorded after the
+// cutoff of the day it settles on, and so left out of that day's settlement
+type LateTransaction struct {
+	Transaction    *Transaction `json:"transaction"`
+	SettlementDate time.Time    `json:"settlement_date"`
+	CutoffAt       time.Time    `json:"cutoff_at"`
+}
+
+// LateTransactionReport represents the late transactions of a range of
+// settlement dates
+type LateTransactionReport struct {
+	From         string             `json:"from"`
+	To           string             `json:"to"`
+	Transactions []*LateTransaction `json:"transactions"`
+}
+
+// MinuteCount represents the number of events in one minute
+type MinuteCount struct {
+	Minute time.Time `json:"minute" db:"minute"`
+	Count  int       `json:"count" db:"count"`
+}
+
+// OrderRateStats represents order creation per minute over a recent window
+type OrderRateStats struct {
+	WindowMinutes int            `json:"window_minutes"`
+	Total         int            `json:"total"`
+	PerMinute     float64        `json:"per_minute"`
+	Minutes       []*MinuteCount `json:"minutes"`
+	GeneratedAt   time.Time      `json:"generated_at"`
+}
+
+// JobTypeThroughput represents the jobs of one type that finished in a window
+type JobTypeThroughput struct {
+	JobType            JobType `json:"job_type" db:"type"`
+	Completed          int     `json:"completed" db:"completed"`
+	Failed             int     `json:"failed" db:"failed"`
+	DeadLettered       int     `json:"dead_lettered" db:"dead_lettered"`
+	Cancelled          int     `json:"cancelled" db:"cancelled"`
+	AvgDurationSeconds float64 `json:"avg_duration_seconds" db:"avg_duration_seconds"`
+}
+
+// JobThroughputStats represents job throughput by type over a recent window
+type JobThroughputStats struct {
+	WindowHours int                  `json:"window_hours"`
+	Types       []*JobTypeThroughput `json:"types"`
+	GeneratedAt time.Time            `json:"generated_at"`
+}
+
+// JobTypeQueue represents the jobs of one type waiting for, or holding, a
+// worker, and how many failed in the last hour
+type JobTypeQueue struct {
+	JobType JobType `json:"job_type" db:"type"`
+	Queued  int     `json:"queued" db:"queued"`
+	Running int     `json:"running" db:"running"`
+	// FailedLastHour counts FAILED and DEAD_LETTERED jobs
+	FailedLastHour      int     `json:"failed_last_hour" db:"failed_last_hour"`
+	OldestQueuedSeconds float64 `json:"oldest_queued_seconds" db:"oldest_queued_seconds"`
+}
+
+// JobQueueStats represents the job backlog by type
+type JobQueueStats struct {
+	Types       []*JobTypeQueue `json:"types"`
+	GeneratedAt time.Time       `json:"generated_at"`
+}
+
+// ErrorRate represents how many of a set of records ended in error
+type ErrorRate struct {
+	Total  int     `json:"total"`
+	Errors int     `json:"errors"`
+	Rate   float64 `json:"rate"`
+}
+
+// ErrorRateStats represents failure rates across the system over a recent
+// window: failed or dead-lettered jobs, failed transactions and cancelled orders
+type ErrorRateStats struct {
+	WindowHours  int       `json:"window_hours"`
+	Jobs         ErrorRate `json:"jobs"`
+	Transactions ErrorRate `json:"transactions"`
+	Orders       ErrorRate `json:"orders"`
+	GeneratedAt  time.Time `json:"generated_at"`
+}
+
+// MerchantVolume represents a merchant's completed transaction volume
+type MerchantVolume struct {
+	MerchantID string `json:"merchant_id" db:"merchant_id"`
+	GrossCents int    `json:"gross_cents" db:"gross_cents"`
+	TxnCount   int    `json:"txn_count" db:"txn_count"`
+}
+
+// TopMerchantsStats represents the merchants with the highest volume in a period
+type TopMerchantsStats struct {
+	PeriodFrom  string            `json:"period_from"`
+	PeriodTo    string            `json:"period_to"`
+	Merchants   []*MerchantVolume `json:"merchants"`
+	GeneratedAt time.Time         `json:"generated_at"`
+}
+
+// OrderAnalyticsDay summarizes one product's projected orders on one day.
+// Orders, units and revenue count confirmed orders only.
+type OrderAnalyticsDay struct {
+	Date            time.Time `json:"date"`
+	ProductID       int       `json:"product_id"`
+	SKU             *string   `json:"sku,omitempty"`
+	ProductName     string    `json:"product_name"`
+	Orders          int       `json:"orders"`
+	Units           int       `json:"units"`
+	RevenueCents    int64     `json:"revenue_cents"`
+	CancelledOrders int       `json:"cancelled_orders"`
+}
+
+// OrderAnalyticsStats represents order analytics read from the projection.
+// ProjectedUntil is how far the projection has read; orders changed after it
+// may not be reflected yet.
+type OrderAnalyticsStats struct {
+	PeriodFrom     string               `json:"period_from"`
+	PeriodTo       string               `json:"period_to"`
+	ProductID      int                  `json:"product_id,omitempty"`
+	Days           []*OrderAnalyticsDay `json:"days"`
+	ProjectedUntil *time.Time           `json:"projected_until"`
+	GeneratedAt    time.Time            `json:"generated_at"`
+}
+
+// SettlementRun represents an immutable settlement run
+type SettlementRun struct {
+	ID              uuid.UUID     `json:"id" db:"id"`
+	JobID           *uuid.UUID    `json:"job_id,omitempty" db:"job_id"`
+	From            time.Time     `json:"from" db:"range_from"`
+	To              time.Time     `json:"to" db:"range_to"`
+	SettlementCount int           `json:"settlement_count" db:"settlement_count"`
+	CreatedAt       time.Time     `json:"created_at" db:"created_at"`
+	Settlements     []*Settlement `json:"settlements,omitempty"`
+	// Skipped lists the merchant-days the run failed to write
+	Skipped []SkippedSettlement `json:"skipped,omitempty" db:"skipped"`
+}
+
+// SkippedSettlement is a merchant-day a settlement run failed to write. The
+// day's previous settlement stays current, marked stale so it is settled
+// again.
+type SkippedSettlement struct {
+	MerchantID string    `json:"merchant_id"`
+	Date       time.Time `json:"date"`
+	Error      string    `json:"error"`
+}
+
+// Holiday represents a non-business day in a settlement region
+type Holiday struct {
+	Region    string    `json:"region" db:"region"`
+	Date      time.Time `json:"date" db:"date"`
+	Name      string    `json:"name" db:"name"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// MerchantCalendar represents the settlement region assigned to a merchant
+type MerchantCalendar struct {
+	MerchantID string    `json:"merchant_id" db:"merchant_id"`
+	Region     string    `json:"region" db:"region"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// PayoutScheme is the bank transfer scheme a merchant is paid through
+type PayoutScheme string
+
+const (
+	// PayoutSchemeNACHA pays US accounts by ACH credit
+	PayoutSchemeNACHA PayoutScheme = "NACHA"
+	// PayoutSchemeSEPA pays euro accounts by SEPA credit transfer
+	PayoutSchemeSEPA PayoutScheme = "SEPA"
+)
+
+// MerchantBankAccount represents the account a merchant's payouts are sent
+// to: a routing and account number for NACHA, or an IBAN for SEPA
+type MerchantBankAccount struct {
+	MerchantID    string       `json:"merchant_id" db:"merchant_id"`
+	Scheme        PayoutScheme `json:"scheme" db:"scheme"`
+	AccountName   string       `json:"account_name" db:"account_name"`
+	RoutingNumber string       `json:"routing_number,omitempty" db:"routing_number"`
+	AccountNumber string       `json:"account_number,omitempty" db:"account_number"`
+	IBAN          string       `json:"iban,omitempty" db:"iban"`
+	BIC           string       `json:"bic,omitempty" db:"bic"`
+	UpdatedAt     time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// PayoutStatus represents where a payout is on its way to the bank
+type PayoutStatus string
+
+const (
+	PayoutStatusPending  PayoutStatus = "PENDING"
+	PayoutStatusApproved PayoutStatus = "APPROVED"
+	// PayoutStatusExported marks a payout written to a bank file
+	PayoutStatusExported PayoutStatus = "EXPORTED"
+)
+
+// Payout represents the amount owed to a merchant for a period: the net of
+// its current settlements and adjustments dated in it
+type Payout struct {
+	ID          uuid.UUID    `json:"id" db:"id"`
+	MerchantID  string       `json:"merchant_id" db:"merchant_id"`
+	PeriodFrom  time.Time    `json:"period_from" db:"period_from"`
+	PeriodTo    time.Time    `json:"period_to" db:"period_to"`
+	AmountCents int          `json:"amount_cents" db:"amount_cents"`
+	Status      PayoutStatus `json:"status" db:"status"`
+	ApprovedAt  *time.Time   `json:"approved_at,omitempty" db:"approved_at"`
+	ExportedAt  *time.Time   `json:"exported_at,omitempty" db:"exported_at"`
+	// ExportJobID is the bank file job that exported the payout
+	ExportJobID *uuid.UUID `json:"export_job_id,omitempty" db:"export_job_id"`
 	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
 }
 
+// PayoutExport is a payout claimed by a bank file job, with the account it
+// is paid to
+type PayoutExport struct {
+	Payout  *Payout
+	Account *MerchantBankAccount
+}
+
+// MerchantDashboard represents a merchant's summary for a period
+type MerchantDashboard struct {
+	MerchantID   string                     `json:"merchant_id"`
+	PeriodFrom   string                     `json:"period_from"`
+	PeriodTo     string                     `json:"period_to"`
+	Transactions MerchantTransactionSummary `json:"transactions"`
+	Settlements  MerchantSettlementSummary  `json:"settlements"`
+	Unsettled    MerchantUnsettledSummary   `json:"unsettled"`
+	// PendingPayoutCents is settled net plus adjustments less the payouts
+	// exported for periods within the dashboard period
+	PendingPayoutCents int       `json:"pending_payout_cents"`
+	GeneratedAt        time.Time `json:"generated_at"`
+}
+
+// MerchantTransactionSummary represents transaction totals for a merchant
+type MerchantTransactionSummary struct {
+	GrossCents     int `json:"gross_cents"`
+	FeeCents       int `json:"fee_cents"`
+	NetCents       int `json:"net_cents"`
+	CompletedCount int `json:"completed_count"`
+	PendingCount   int `json:"pending_count"`
+	FailedCount    int `json:"failed_count"`
+}
+
+// MerchantSettlementSummary represents current settlement totals for a merchant
+type MerchantSettlementSummary struct {
+	SettledDays int `json:"settled_days"`
+	GrossCents  int `json:"gross_cents"`
+	FeeCents    int `json:"fee_cents"`
+	NetCents    int `json:"net_cents"`
+	TxnCount    int `json:"txn_count"`
+	// AdjustmentCents sums the adjustments dated in the period, whether or
+	// not the day has been settled
+	AdjustmentCents int        `json:"adjustment_cents"`
+	LastSettledDate *time.Time `json:"last_settled_date,omitempty"`
+	LastGeneratedAt *time.Time `json:"last_generated_at,omitempty"`
+	LastRunID       *uuid.UUID `json:"last_run_id,omitempty"`
+}
+
+// MerchantUnsettledSummary represents completed transactions on days that
+// have no current settlement yet
+type MerchantUnsettledSummary struct {
+	NetCents int `json:"net_cents"`
+	TxnCount int `json:"txn_count"`
+}
+
+// Job represents a background job
+type Job struct {
+	ID             uuid.UUID     `json:"id" db:"id"`
+	Type           JobType       `json:"type" db:"type"`
+	Status         JobStatus     `json:"status" db:"status"`
+	Progress       float64       `json:"progress" db:"progress"`
+	Processed      int           `json:"processed" db:"processed"`
+	Total          int           `json:"total" db:"total"`
+	Parameters     string        `json:"parameters" db:"parameters"` // JSON
+	CreatedBy      *string       `json:"created_by,omitempty" db:"created_by"`
+	Attempts       int           `json:"attempts" db:"attempts"`
+	ResultPath     *string       `json:"result_path,omitempty" db:"result_path"`
+	DownloadURL    *string       `json:"download_url,omitempty" db:"download_url"`
+	Error          *string       `json:"error,omitempty" db:"error"`
+	StartedAt      *time.Time    `json:"started_at,omitempty" db:"started_at"`
+	CompletedAt    *time.Time    `json:"completed_at,omitempty" db:"completed_at"`
+	DeadLetteredAt *time.Time    `json:"dead_lettered_at,omitempty" db:"dead_lettered_at"`
+	CreatedAt      time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at" db:"updated_at"`
+	Live           *JobLiveState `json:"live,omitempty"` // in-memory state while running
+	Files          []*JobFile    `json:"files,omitempty"`
+	// ProgressWebhookID is the endpoint sent job.progress.v1 as the job
+	// passes each of ProgressMilestones
+	ProgressWebhookID  *uuid.UUID `json:"progress_webhook_id,omitempty" db:"progress_webhook_id"`
+	ProgressMilestones []int      `json:"progress_milestones,omitempty" db:"progress_milestones"`
+}
+
+// ProgressWebhook asks for a job's progress to be sent to a registered
+// webhook endpoint at milestones, in percent; 25, 50, 75 and 100 by default
+type ProgressWebhook struct {
+	WebhookID  uuid.UUID `json:"webhook_id" binding:"required"`
+	Milestones []int     `json:"milestones" binding:"omitempty,max=20,dive,min=1,max=100"`
+}
+
+// JobFile represents one output file of a job that produces several
+type JobFile struct {
+	Name        string    `json:"name" db:"name"`
+	Path        string    `json:"-" db:"path"`
+	ContentType string    `json:"content_type" db:"content_type"`
+	SizeBytes   int64     `json:"size_bytes" db:"size_bytes"`
+	DownloadURL string    `json:"download_url"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// JobStats are the execution stats of a finished job. DBTimeMS covers the
+// job's batch reads and writes and ComputeTimeMS the rest of its run.
+// PeakMemoryBytes is the largest heap seen while it ran, shared with
+// whatever else the process was doing.
+type JobStats struct {
+	JobID           uuid.UUID `json:"job_id" db:"job_id"`
+	JobType         JobType   `json:"job_type" db:"job_type"`
+	Status          JobStatus `json:"status" db:"status"`
+	Attempts        int       `json:"attempts" db:"attempts"`
+	RowsProcessed   int64     `json:"rows_processed" db:"rows_processed"`
+	Batches         int       `json:"batches" db:"batches"`
+	DurationMS      int64     `json:"duration_ms" db:"duration_ms"`
+	DBTimeMS        int64     `json:"db_time_ms" db:"db_time_ms"`
+	ComputeTimeMS   int64     `json:"compute_time_ms" db:"compute_time_ms"`
+	RowsPerSecond   float64   `json:"rows_per_second" db:"rows_per_second"`
+	PeakMemoryBytes uint64    `json:"peak_memory_bytes" db:"peak_memory_bytes"`
+	StartedAt       time.Time `json:"started_at" db:"started_at"`
+	FinishedAt      time.Time `json:"finished_at" db:"finished_at"`
+}
+
+// JobLogEntry is a log line written while a job ran. Fields holds the
+// line's structured fields, with personal data masked as in server logs.
+type JobLogEntry struct {
+	ID       int64                  `json:"id" db:"id"`
+	JobID    uuid.UUID              `json:"job_id" db:"job_id"`
+	Level    string                 `json:"level" db:"level"`
+	Message  string                 `json:"message" db:"message"`
+	Fields   map[string]interface{} `json:"fields,omitempty" db:"fields"`
+	LoggedAt time.Time              `json:"logged_at" db:"logged_at"`
+}
+
+// JobLogs is a page of a job's log lines, oldest first. NextAfter is the ID
+// to pass as after for the lines that follow.
+type JobLogs struct {
+	Logs      []*JobLogEntry `json:"logs"`
+	NextAfter int64          `json:"next_after"`
+}
+
+// JobLiveState represents the in-memory state of a running job
+type JobLiveState struct {
+	Progress      float64   `json:"progress"`
+	Processed     int       `json:"processed"`
+	Total         int       `json:"total"`
+	CurrentBatch  int       `json:"current_batch"`
+	RowsPerSecond float64   `json:"rows_per_second"`
+	ETASeconds    *float64  `json:"eta_seconds,omitempty"`
+	StartedAt     time.Time `json:"started_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
 // JobType represents the type of job
 type JobType string
 
 const (
-	JobTypeSettlement JobType = "SETTLEMENT"
+	JobTypeSettlement      JobType = "SETTLEMENT"
+	JobTypeReorderForecast JobType = "REORDER_FORECAST"
+	JobTypeOrdersExport    JobType = "ORDERS_EXPORT"
+	JobTypeResettle        JobType = "RESETTLE"
+	// JobTypeMerchantStatement renders a merchant's monthly statement PDF
+	JobTypeMerchantStatement JobType = "MERCHANT_STATEMENT"
+	// JobTypeBackfill migrates existing rows for an expand/contract migration
+	JobTypeBackfill JobType = "BACKFILL"
+	// JobTypeBuyerErasure anonymizes a buyer's personal data on request
+	JobTypeBuyerErasure JobType = "BUYER_ERASURE"
+	// JobTypeBackorderFulfillment confirms backorders that stock now covers
+	JobTypeBackorderFulfillment JobType = "BACKORDER_FULFILLMENT"
+	// JobTypePayoutFile writes the approved payouts of one scheme to a bank
+	// file
+	JobTypePayoutFile JobType = "PAYOUT_FILE"
 )
 
 // JobStatus represents the status of a job
@@ -109,32 +712,595 @@ const (
 	JobStatusCompleted JobStatus = "COMPLETED"
 	JobStatusFailed    JobStatus = "FAILED"
 	JobStatusCancelled JobStatus = "CANCELLED"
+	// JobStatusCancelling marks a running job asked to stop; it becomes
+	// CANCELLED once its worker has actually stopped
+	JobStatusCancelling JobStatus = "CANCELLING"
+	// JobStatusDeadLettered marks a job that failed every retry attempt
+	JobStatusDeadLettered JobStatus = "DEAD_LETTERED"
 )
 
+// JobFilter selects jobs to list; zero values match every job
+type JobFilter struct {
+	Status JobStatus
+	Type   JobType
+}
+
+// JobLock represents a lock held by a running job over a date range
+type JobLock struct {
+	JobID      uuid.UUID `json:"job_id" db:"job_id"`
+	JobType    JobType   `json:"job_type" db:"job_type"`
+	RangeFrom  time.Time `json:"range_from" db:"range_from"`
+	RangeTo    time.Time `json:"range_to" db:"range_to"`
+	AcquiredAt time.Time `json:"acquired_at" db:"acquired_at"`
+}
+
 // SettlementJobParams represents parameters for settlement job
 type SettlementJobParams struct {
-	From string `json:"from"`
-	To   string `json:"to"`
+	From   string          `json:"from"`
+	To     string          `json:"to"`
+	Split  SettlementSplit `json:"split,omitempty"`
+	Format ExportFormat    `json:"format,omitempty"`
+}
+
+// SettlementSplit controls how a settlement job's output file is divided
+type SettlementSplit string
+
+const (
+	// SettlementSplitNone writes every merchant to one file
+	SettlementSplitNone SettlementSplit = "none"
+	// SettlementSplitMerchant writes one file per merchant into a ZIP, so each
+	// merchant can be sent only their own rows
+	SettlementSplitMerchant SettlementSplit = "merchant"
+)
+
+// MerchantStatementJobParams represents parameters for merchant statement job
+type MerchantStatementJobParams struct {
+	MerchantID string `json:"merchant_id"`
+	Month      string `json:"month"` // YYYY-MM
+}
+
+// PayoutFileJobParams represents parameters for a payout bank file job
+type PayoutFileJobParams struct {
+	Scheme PayoutScheme `json:"scheme"`
+}
+
+// BackfillJobParams represents parameters for a backfill job
+type BackfillJobParams struct {
+	Name string `json:"name"`
+}
+
+// BuyerErasureJobParams represents parameters for a buyer erasure job. Once
+// the job completes, BuyerID holds the pseudonym too.
+type BuyerErasureJobParams struct {
+	BuyerID   string `json:"buyer_id"`
+	Pseudonym string `json:"pseudonym"`
+}
+
+// BackorderFulfillmentJobParams represents parameters for a backorder
+// fulfillment job; no product IDs covers every product with backorders
+type BackorderFulfillmentJobParams struct {
+	ProductIDs []int `json:"product_ids,omitempty"`
+}
+
+// ResettleJobParams represents parameters for a re-settlement job
+type ResettleJobParams struct {
+	MerchantDays []MerchantDay `json:"merchant_days"`
+}
+
+// MerchantDay identifies one merchant's settlement for one day
+type MerchantDay struct {
+	MerchantID string `json:"merchant_id"`
+	Date       string `json:"date"`
+}
+
+// ReorderForecastJobParams represents parameters for reorder forecast job
+type ReorderForecastJobParams struct {
+	Model        ForecastModel `json:"model"`
+	LookbackDays int           `json:"lookback_days"`
+	LeadTimeDays int           `json:"lead_time_days"`
+	SafetyDays   int           `json:"safety_days"`
+	ReviewDays   int           `json:"review_days"`
+	Alpha        float64       `json:"alpha,omitempty"` // smoothing factor for EMA
+}
+
+// OrdersExportJobParams represents parameters for orders export job
+type OrdersExportJobParams struct {
+	Status  OrderStatus  `json:"status,omitempty"`
+	BuyerID string       `json:"buyer_id,omitempty"`
+	From    string       `json:"from,omitempty"`
+	To      string       `json:"to,omitempty"`
+	Format  ExportFormat `json:"format"`
+}
+
+// ExportFormat represents the file format of an export
+type ExportFormat string
+
+const (
+	ExportFormatCSV  ExportFormat = "csv"
+	ExportFormatJSON ExportFormat = "json"
+	// ExportFormatParquet writes typed columns for loading into Spark or
+	// BigQuery without parsing
+	ExportFormatParquet ExportFormat = "parquet"
+)
+
+// OrderExportFilter narrows the orders included in an export. Zero values
+// match every order; the date range is [From, To).
+type OrderExportFilter struct {
+	Status  OrderStatus
+	BuyerID string
+	From    *time.Time
+	To      *time.Time
+}
+
+// ListOrdersRequest represents order list paging and filters. Cursor, a
+// previous page's next_cursor, pages by keyset instead of Offset.
+type ListOrdersRequest struct {
+	ClientReference string
+	Limit           int
+	Offset          int
+	Cursor          string
+}
+
+// OrderCursor is the position of an order in the order list, newest first
+type OrderCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// OrderPage is one page of the order list; NextCursor is empty on the last
+// page
+type OrderPage struct {
+	Orders     []*Order
+	NextCursor string
+}
+
+// ListTransactionsRequest represents transaction list filters; From and To
+// are inclusive YYYY-MM-DD dates
+type ListTransactionsRequest struct {
+	MerchantID string
+	Status     TransactionStatus
+	From       string
+	To         string
+}
+
+// ListSettlementsRequest represents settlement list filters; From and To
+// are inclusive YYYY-MM-DD dates
+type ListSettlementsRequest struct {
+	MerchantID string
+	From       string
+	To         string
+}
+
+// TransactionFilter selects transactions to list; From and To bound paid_at
+// as [From, To)
+type TransactionFilter struct {
+	MerchantID string
+	Status     TransactionStatus
+	From       *time.Time
+	To         *time.Time
+}
+
+// SettlementFilter selects current settlements to list; From and To bound
+// the settlement date as [From, To)
+type SettlementFilter struct {
+	MerchantID string
+	From       *time.Time
+	To         *time.Time
+}
+
+// ForecastModel represents the demand model used by a reorder forecast
+type ForecastModel string
+
+const (
+	ForecastModelSMA ForecastModel = "SMA" // simple moving average
+	ForecastModelEMA ForecastModel = "EMA" // exponential moving average
+)
+
+// ProductDailySales represents units of a product sold on one day
+type ProductDailySales struct {
+	ProductID int       `json:"product_id" db:"product_id"`
+	Date      time.Time `json:"date" db:"date"`
+	Quantity  int       `json:"quantity" db:"quantity"`
+}
+
+// ReorderRecommendation represents a recommended reorder for a product
+type ReorderRecommendation struct {
+	ID                  int       `json:"id" db:"id"`
+	JobID               uuid.UUID `json:"job_id" db:"job_id"`
+	ProductID           int       `json:"product_id" db:"product_id"`
+	DailyVelocity       float64   `json:"daily_velocity" db:"daily_velocity"`
+	CurrentStock        int       `json:"current_stock" db:"current_stock"`
+	ReorderPoint        int       `json:"reorder_point" db:"reorder_point"`
+	RecommendedQuantity int       `json:"recommended_quantity" db:"recommended_quantity"`
+	CreatedAt           time.Time `json:"created_at" db:"created_at"`
+}
+
+// CreateProductRequest represents a request to add a product to the
+// catalog
+type CreateProductRequest struct {
+	Name    string  `json:"name" binding:"required,max=255,printable"`
+	SKU     *string `json:"sku" binding:"omitempty,max=64,identifier"`
+	Barcode *string `json:"barcode" binding:"omitempty,max=64,identifier"`
+	Stock   int     `json:"stock" binding:"min=0"`
+	Price   int     `json:"price" binding:"min=0"`
+}
+
+// UpdateProductRequest represents a request to replace a product's name,
+// SKU, barcode and price. Stock is a pointer so leaving it out keeps the
+// current level, while an explicit zero empties it.
+type UpdateProductRequest struct {
+	Name    string  `json:"name" binding:"required,max=255,printable"`
+	SKU     *string `json:"sku" binding:"omitempty,max=64,identifier"`
+	Barcode *string `json:"barcode" binding:"omitempty,max=64,identifier"`
+	Stock   *int    `json:"stock" binding:"omitempty,min=0"`
+	Price   int     `json:"price" binding:"min=0"`
 }
 
 // CreateOrderRequest represents a request to create an order
 type CreateOrderRequest struct {
 	ProductID int    `json:"product_id" binding:"required,min=1"`
 	Quantity  int    `json:"quantity" binding:"required,min=1"`
-	BuyerID   string `json:"buyer_id" binding:"required"`
+	BuyerID   string `json:"buyer_id" binding:"required,max=255,identifier"`
+	// ClientReference makes the request idempotent: repeating it returns
+	// the order already placed under the reference
+	ClientReference string `json:"client_reference,omitempty" binding:"omitempty,max=255,printable"`
+	// QueueToken admits the order to a product behind the waiting room
+	QueueToken string `json:"queue_token,omitempty" binding:"omitempty,max=1024,printable"`
+}
+
+// CreateQueueTokenRequest represents a request for a place in a product's
+// waiting room
+type CreateQueueTokenRequest struct {
+	ProductID int    `json:"product_id" binding:"required,min=1"`
+	BuyerID   string `json:"buyer_id" binding:"required,max=255,identifier"`
+}
+
+// QueueToken is a buyer's place in a product's waiting room: orders carrying
+// Token are admitted from AdmitAt until ExpiresAt
+type QueueToken struct {
+	Token     string    `json:"token"`
+	ProductID int       `json:"product_id"`
+	BuyerID   string    `json:"buyer_id"`
+	AdmitAt   time.Time `json:"admit_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// CreateBundleRequest represents a request to create a bundle
+type CreateBundleRequest struct {
+	Name       string             `json:"name" binding:"required,max=255,printable"`
+	SKU        *string            `json:"sku" binding:"omitempty,max=64,identifier"`
+	Price      int                `json:"price" binding:"min=0"`
+	Components []*BundleComponent `json:"components" binding:"required,min=1,max=50,dive"`
+}
+
+// CreateSalesEventRequest represents a request to schedule a sales event
+type CreateSalesEventRequest struct {
+	Name          string    `json:"name" binding:"required,max=255,printable"`
+	StartsAt      time.Time `json:"starts_at" binding:"required"`
+	EndsAt        time.Time `json:"ends_at" binding:"required"`
+	PerBuyerLimit int       `json:"per_buyer_limit" binding:"required,min=1"`
+	ProductIDs    []int     `json:"product_ids" binding:"required,min=1,max=100,dive,min=1"`
+}
+
+// UpdateBundleRequest represents a request to replace a bundle's name,
+// price and components
+type UpdateBundleRequest struct {
+	Name       string             `json:"name" binding:"required,max=255,printable"`
+	Price      int                `json:"price" binding:"min=0"`
+	Components []*BundleComponent `json:"components" binding:"required,min=1,max=50,dive"`
+}
+
+// BulkCreateOrdersRequest represents a request to create several orders.
+// Items are validated individually so each can fail on its own.
+type BulkCreateOrdersRequest struct {
+	Orders []CreateOrderRequest `json:"orders" binding:"required,min=1,max=100"`
+}
+
+// StockSyncItem sets the stock of the product with the given SKU.
+// AbsoluteStock is a pointer so an explicit zero is not rejected as missing.
+type StockSyncItem struct {
+	SKU           string `json:"sku" binding:"required,max=64,printable"`
+	AbsoluteStock *int   `json:"absolute_stock" binding:"required,min=0"`
+}
+
+// StockSyncRequest carries absolute stock levels from the warehouse system.
+// The items are applied together or not at all.
+type StockSyncRequest struct {
+	Items []StockSyncItem `json:"items" binding:"required,min=1,max=1000,dive"`
+}
+
+// StockSyncResult reports the outcome of a stock sync
+type StockSyncResult struct {
+	Updated   int                  `json:"updated"`
+	Unchanged int                  `json:"unchanged"`
+	Movements []*InventoryMovement `json:"movements"`
+	// FulfillmentJobID is the job queued to confirm backorders the new
+	// stock may cover
+	FulfillmentJobID *uuid.UUID `json:"fulfillment_job_id,omitempty"`
+}
+
+// SetBackorderLimitRequest sets how many units of a product may be ordered
+// beyond its stock; 0 stops taking backorders
+type SetBackorderLimitRequest struct {
+	BackorderLimit *int `json:"backorder_limit" binding:"required,min=0,max=1000000"`
+}
+
+// SearchRequest is a structured query against a search index. Query and
+// Sort use the Elasticsearch/OpenSearch query DSL.
+type SearchRequest struct {
+	Query json.RawMessage `json:"query" binding:"required"`
+	Sort  json.RawMessage `json:"sort,omitempty"`
+	From  int             `json:"from" binding:"min=0"`
+	Size  int             `json:"size" binding:"min=0,max=100"`
+}
+
+// SearchHit is one matching document, as indexed
+type SearchHit struct {
+	ID       string          `json:"id"`
+	Score    *float64        `json:"score"`
+	Document json.RawMessage `json:"document"`
+}
+
+// SearchResult is a page of search hits
+type SearchResult struct {
+	Total int          `json:"total"`
+	Hits  []*SearchHit `json:"hits"`
 }
 
 // CreateSettlementJobRequest represents a request to create a settlement job
 type CreateSettlementJobRequest struct {
-	From string `json:"from" binding:"required"`
-	To   string `json:"to" binding:"required"`
+	From            string           `json:"from" binding:"required"`
+	To              string           `json:"to" binding:"required"`
+	Split           SettlementSplit  `json:"split"`
+	Format          ExportFormat     `json:"format"`
+	ProgressWebhook *ProgressWebhook `json:"progress_webhook"`
+}
+
+// CreateReorderForecastJobRequest represents a request to create a reorder forecast job
+type CreateReorderForecastJobRequest struct {
+	Model           ForecastModel    `json:"model"`
+	LookbackDays    int              `json:"lookback_days" binding:"omitempty,min=1,max=365"`
+	LeadTimeDays    int              `json:"lead_time_days" binding:"omitempty,min=0,max=365"`
+	SafetyDays      int              `json:"safety_days" binding:"omitempty,min=0,max=365"`
+	ReviewDays      int              `json:"review_days" binding:"omitempty,min=0,max=365"`
+	Alpha           float64          `json:"alpha" binding:"omitempty,gt=0,lte=1"`
+	ProgressWebhook *ProgressWebhook `json:"progress_webhook"`
+}
+
+// CreateOrdersExportJobRequest represents a request to create an orders export job
+type CreateOrdersExportJobRequest struct {
+	Status          OrderStatus      `json:"status"`
+	BuyerID         string           `json:"buyer_id" binding:"omitempty,max=255,identifier"`
+	From            string           `json:"from"`
+	To              string           `json:"to"`
+	Format          ExportFormat     `json:"format"`
+	ProgressWebhook *ProgressWebhook `json:"progress_webhook"`
+}
+
+// CreateMerchantStatementJobRequest represents a request to create a merchant statement job
+type CreateMerchantStatementJobRequest struct {
+	MerchantID      string           `json:"merchant_id" binding:"required,max=255,identifier"`
+	Month           string           `json:"month" binding:"required,max=7"`
+	ProgressWebhook *ProgressWebhook `json:"progress_webhook"`
+}
+
+// CreateBackorderFulfillmentJobRequest represents a request to confirm the
+// backorders stock now covers, for the listed products or every product
+// with backorders
+type CreateBackorderFulfillmentJobRequest struct {
+	ProductIDs []int `json:"product_ids" binding:"max=1000,dive,min=1"`
+}
+
+// CreateBackfillJobRequest represents a request to run a registered backfill
+type CreateBackfillJobRequest struct {
+	Name string `json:"name" binding:"required,max=100,printable"`
+}
+
+// BackfillStatus reports how many rows a registered backfill has left
+type BackfillStatus struct {
+	Name      string `json:"name"`
+	Remaining int    `json:"remaining"`
+}
+
+// DetectStaleSettlementsRequest represents a request to scan settlements for
+// late-arriving transactions. Both dates are optional.
+type DetectStaleSettlementsRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// UpdateTransactionStatusRequest represents a request to change a transaction's status
+type UpdateTransactionStatusRequest struct {
+	Status TransactionStatus `json:"status" binding:"required"`
+}
+
+// Payment gateway notification types
+const (
+	PaymentEventCaptured = "payment.captured"
+	PaymentEventRefunded = "payment.refunded"
+)
+
+// PaymentEvent is a notification from the payment gateway. A capture of an
+// unknown payment creates its transaction, so it carries the merchant and
+// amounts; a refund only needs the payment ID.
+type PaymentEvent struct {
+	ID          string     `json:"id" binding:"required,max=255,printable"`
+	Type        string     `json:"type" binding:"required,max=50"`
+	PaymentID   string     `json:"payment_id" binding:"required,max=255,printable"`
+	OrderID     *uuid.UUID `json:"order_id"`
+	MerchantID  string     `json:"merchant_id" binding:"omitempty,max=255,identifier"`
+	AmountCents int        `json:"amount_cents"`
+	FeeCents    int        `json:"fee_cents"`
+	OccurredAt  time.Time  `json:"occurred_at" binding:"required"`
+}
+
+// PaymentEventResult is the outcome of a gateway notification. Duplicate
+// events, and events that don't change the transaction, are acknowledged
+// with Applied false.
+type PaymentEventResult struct {
+	EventID          string            `json:"event_id"`
+	Applied          bool              `json:"applied"`
+	Duplicate        bool              `json:"duplicate"`
+	Transaction      *Transaction      `json:"transaction,omitempty"`
+	PreviousStatus   TransactionStatus `json:"previous_status,omitempty"`
+	StaleSettlements []*Settlement     `json:"stale_settlements"`
+}
+
+// CreateSettlementAdjustmentRequest represents a request to post an
+// adjustment against a merchant's settlement day
+type CreateSettlementAdjustmentRequest struct {
+	MerchantID  string           `json:"merchant_id" binding:"required,max=255,identifier"`
+	Date        string           `json:"date" binding:"required,max=10"`
+	AmountCents int              `json:"amount_cents" binding:"required"`
+	ReasonCode  AdjustmentReason `json:"reason_code" binding:"required,max=50"`
+	Note        string           `json:"note" binding:"max=1000,printable"`
+}
+
+// CreateHolidayRequest represents a request to add a holiday to a region
+type CreateHolidayRequest struct {
+	Region string `json:"region" binding:"required,max=16,identifier"`
+	Date   string `json:"date" binding:"required,max=10"`
+	Name   string `json:"name" binding:"required,max=255,printable"`
+}
+
+// SetMerchantCalendarRequest represents a request to assign a merchant's settlement region
+type SetMerchantCalendarRequest struct {
+	Region string `json:"region" binding:"required,max=16,identifier"`
+}
+
+// SetMerchantBankAccountRequest represents a request to set the account a
+// merchant's payouts are sent to
+type SetMerchantBankAccountRequest struct {
+	Scheme        PayoutScheme `json:"scheme" binding:"required,max=10"`
+	AccountName   string       `json:"account_name" binding:"required,max=255,printable"`
+	RoutingNumber string       `json:"routing_number" binding:"max=9"`
+	AccountNumber string       `json:"account_number" binding:"max=17"`
+	IBAN          string       `json:"iban" binding:"max=42"`
+	BIC           string       `json:"bic" binding:"max=11"`
+}
+
+// CreatePayoutRequest represents a request to pay a merchant for the
+// settlement days from From to To, inclusive
+type CreatePayoutRequest struct {
+	MerchantID string `json:"merchant_id" binding:"required,max=255,identifier"`
+	From       string `json:"from" binding:"required,max=10"`
+	To         string `json:"to" binding:"required,max=10"`
+}
+
+// CreatePayoutFileJobRequest represents a request to write the approved
+// payouts of one scheme to a bank file
+type CreatePayoutFileJobRequest struct {
+	Scheme          PayoutScheme     `json:"scheme" binding:"required,max=10"`
+	ProgressWebhook *ProgressWebhook `json:"progress_webhook"`
+}
+
+// SetPayloadLoggingRequest represents a request to toggle payload logging for routes
+type SetPayloadLoggingRequest struct {
+	Routes  []string `json:"routes" binding:"required,min=1,max=100,dive,max=255,printable"`
+	Enabled *bool    `json:"enabled" binding:"required"`
+}
+
+// MaintenanceState is the maintenance mode shared by every replica
+type MaintenanceState struct {
+	Enabled   bool      `json:"enabled" db:"enabled"`
+	Message   string    `json:"message,omitempty" db:"message"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// MaintenanceStatus is the maintenance state with the number of jobs still
+// running across all replicas
+type MaintenanceStatus struct {
+	MaintenanceState
+	RunningJobs int `json:"running_jobs"`
+}
+
+// SetMaintenanceRequest represents a request to turn maintenance mode on or off
+type SetMaintenanceRequest struct {
+	Enabled *bool  `json:"enabled" binding:"required"`
+	Message string `json:"message" binding:"max=500,printable"`
+}
+
+// AuthBan records a client banned for repeated failed authentication.
+// Subject is the client IP for the "ip" scope, and a fingerprint of the
+// presented credential for the "credential" scope.
+type AuthBan struct {
+	ID          int64     `json:"id" db:"id"`
+	Scope       string    `json:"scope" db:"scope"`
+	Subject     string    `json:"subject" db:"subject"`
+	Credential  string    `json:"credential" db:"credential"`
+	Failures    int       `json:"failures" db:"failures"`
+	ClientIP    string    `json:"client_ip" db:"client_ip"`
+	RequestID   *string   `json:"request_id,omitempty" db:"request_id"`
+	BannedUntil time.Time `json:"banned_until" db:"banned_until"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// SeedOptions controls the volume and shape of seeded test transactions
+type SeedOptions struct {
+	Count        int     `json:"count"`
+	Days         int     `json:"days"`
+	Merchants    int     `json:"merchants"`
+	ZipfS        float64 `json:"zipf"`
+	Diurnal      bool    `json:"diurnal"`
+	PendingShare float64 `json:"pending"`
+	FailedShare  float64 `json:"failed"`
+}
+
+// SeedRequest represents a request to seed test transactions, optionally
+// deleting every existing transaction first
+type SeedRequest struct {
+	SeedOptions
+	Reset bool `json:"reset"`
+}
+
+// SeedResult reports a completed seed
+type SeedResult struct {
+	Options  SeedOptions `json:"options"`
+	Reset    bool        `json:"reset"`
+	Inserted int         `json:"inserted"`
+	Duration string      `json:"duration"`
+}
+
+// WebhookEndpoint is a receiver of outgoing webhooks. Its secret signs
+// every callback to it and is only returned when the endpoint is created.
+type WebhookEndpoint struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	URL         string    `json:"url" db:"url"`
+	Secret      string    `json:"secret,omitempty" db:"secret"`
+	Description string    `json:"description,omitempty" db:"description"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// CreateWebhookEndpointRequest represents a request to register a webhook receiver
+type CreateWebhookEndpointRequest struct {
+	URL         string `json:"url" binding:"required,max=2048"`
+	Description string `json:"description" binding:"max=500,printable"`
+}
+
+// WebhookPing is the outcome of sending a test callback to an endpoint.
+// Delivered is true when the receiver answered with a 2xx status.
+type WebhookPing struct {
+	EventID    uuid.UUID `json:"event_id"`
+	Delivered  bool      `json:"delivered"`
+	StatusCode int       `json:"status_code,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
 }
 
 // HealthCheck represents the health status of the service
 type HealthCheck struct {
-	Status    string            `json:"status"`
-	Version   string            `json:"version"`
-	Checks    map[string]string `json:"checks"`
-	Uptime    string            `json:"uptime"`
-	Timestamp time.Time         `json:"timestamp"`
+	Status    string                       `json:"status"`
+	Version   string                       `json:"version"`
+	Checks    map[string]string            `json:"checks"`
+	Results   map[string]HealthCheckResult `json:"results"`
+	Uptime    string                       `json:"uptime"`
+	Timestamp time.Time                    `json:"timestamp"`
+}
+
+// HealthCheckResult is the outcome of one dependency check and how long it
+// took
+type HealthCheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
 }
@@ -0,0 +1,520 @@
+// Package parquet provides a minimal Apache Parquet writer for flat,
+// typed exports
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// ContentType is the media type of Parquet files
+const ContentType = "application/vnd.apache.parquet"
+
+// magic starts and ends every Parquet file
+const magic = "PAR1"
+
+// RowGroupRows is the number of rows buffered before a row group is written.
+// Each column of a row group is one GZIP-compressed data page.
+const RowGroupRows = 64 * 1024
+
+// createdBy identifies the writer in the file metadata
+const createdBy = "indico-backend parquet writer"
+
+// Type is the logical type of a column
+type Type int
+
+const (
+	// Int32 is a 32-bit signed integer; values are int or int32
+	Int32 Type = iota
+	// Int64 is a 64-bit signed integer; values are int or int64
+	Int64
+	// String is a UTF-8 string; values are string
+	String
+	// Date is a calendar day; values are time.Time, truncated to the UTC day
+	Date
+	// Timestamp is an instant with millisecond precision, adjusted to UTC;
+	// values are time.Time
+	Timestamp
+)
+
+// Physical types, converted types, repetitions, encodings and codecs as
+// numbered in the Parquet format's Thrift definition
+const (
+	physicalInt32     = 1
+	physicalInt64     = 2
+	physicalByteArray = 6
+
+	convertedUTF8            = 0
+	convertedDate            = 6
+	convertedTimestampMillis = 9
+
+	repetitionRequired = 0
+	repetitionOptional = 1
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecGzip = 2
+
+	pageTypeData = 0
+)
+
+// physicalType returns the type values of t are stored as
+func (t Type) physicalType() int32 {
+	switch t {
+	case Int64, Timestamp:
+		return physicalInt64
+	case String:
+		return physicalByteArray
+	default:
+		return physicalInt32
+	}
+}
+
+// convertedType returns the annotation of t, if it has one
+func (t Type) convertedType() (int32, bool) {
+	switch t {
+	case String:
+		return convertedUTF8, true
+	case Date:
+		return convertedDate, true
+	case Timestamp:
+		return convertedTimestampMillis, true
+	default:
+		return 0, false
+	}
+}
+
+// Column describes one column of a file. Optional columns accept nil values.
+type Column struct {
+	Name     string
+	Type     Type
+	Optional bool
+}
+
+// columnChunk is the metadata of one column written in a row group
+type columnChunk struct {
+	offset       int64
+	values       int64
+	uncompressed int64
+	compressed   int64
+}
+
+// rowGroup is the metadata of one written row group
+type rowGroup struct {
+	rows    int64
+	size    int64
+	columns []columnChunk
+}
+
+// columnBuffer holds the encoded values and definition levels of one column
+// in the current row group
+type columnBuffer struct {
+	values bytes.Buffer
+	levels []byte
+}
+
+// Writer writes rows to a Parquet file. Rows are buffered into row groups, so
+// the underlying writer needs no seeking and may be a network stream; the
+// file is only readable once Close has written the footer.
+type Writer struct {
+	w       io.Writer
+	columns []Column
+	buffers []columnBuffer
+	rows    int
+	offset  int64
+	groups  []rowGroup
+
+	page bytes.Buffer
+	zip  *gzip.Writer
+}
+
+// NewWriter starts a Parquet file with the given columns
+func NewWriter(w io.Writer, columns []Column) (*Writer, error) {
+	pw := &Writer{
+		w:       w,
+		columns: columns,
+		buffers: make([]columnBuffer, len(columns)),
+	}
+	pw.zip = gzip.NewWriter(&pw.page)
+
+	if err := pw.write([]byte(magic)); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+// Write adds one row, with a value for every column in order. After an
+// error the writer is unusable.
+func (pw *Writer) Write(row []interface{}) error {
+	if len(row) != len(pw.columns) {
+		return fmt.Errorf("parquet: row has %d values, expected %d", len(row), len(pw.columns))
+	}
+
+	for i, value := range row {
+		if err := pw.buffers[i].append(pw.columns[i], value); err != nil {
+			return err
+		}
+	}
+
+	pw.rows++
+	if pw.rows == RowGroupRows {
+		return pw.flush()
+	}
+	return nil
+}
+
+// Close writes any buffered rows and the file footer. It does not close the
+// underlying writer.
+func (pw *Writer) Close() error {
+	if pw.rows > 0 {
+		if err := pw.flush(); err != nil {
+			return err
+		}
+	}
+
+	var footer thriftWriter
+	pw.encodeFileMetaData(&footer)
+	if err := pw.write(footer.buf.Bytes()); err != nil {
+		return err
+	}
+
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(footer.buf.Len()))
+	if err := pw.write(length[:]); err != nil {
+		return err
+	}
+	return pw.write([]byte(magic))
+}
+
+// write writes p and tracks the file offset
+func (pw *Writer) write(p []byte) error {
+	n, err := pw.w.Write(p)
+	pw.offset += int64(n)
+	if err != nil {
+		return fmt.Errorf("parquet: failed to write: %w", err)
+	}
+	return nil
+}
+
+// append encodes one value of column into the buffer
+func (b *columnBuffer) append(column Column, value interface{}) error {
+	if value == nil {
+		if !column.Optional {
+			return fmt.Errorf("parquet: column %s is required", column.Name)
+		}
+		b.levels = append(b.levels, 0)
+		return nil
+	}
+	b.levels = append(b.levels, 1)
+
+	var scratch [8]byte
+	switch column.Type {
+	case Int32:
+		n, ok := toInt64(value)
+		if !ok || n < math.MinInt32 || n > math.MaxInt32 {
+			return fmt.Errorf("parquet: column %s expects an int32, got %v", column.Name, value)
+		}
+		binary.LittleEndian.PutUint32(scratch[:4], uint32(int32(n)))
+		b.values.Write(scratch[:4])
+	case Int64:
+		n, ok := toInt64(value)
+		if !ok {
+			return fmt.Errorf("parquet: column %s expects an int64, got %v", column.Name, value)
+		}
+		binary.LittleEndian.PutUint64(scratch[:], uint64(n))
+		b.values.Write(scratch[:])
+	case String:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("parquet: column %s expects a string, got %T", column.Name, value)
+		}
+		binary.LittleEndian.PutUint32(scratch[:4], uint32(len(s)))
+		b.values.Write(scratch[:4])
+		b.values.WriteString(s)
+	case Date:
+		t, ok := value.(time.Time)
+		if !ok {
+			return fmt.Errorf("parquet: column %s expects a time.Time, got %T", column.Name, value)
+		}
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		binary.LittleEndian.PutUint32(scratch[:4], uint32(int32(day.Unix()/86400)))
+		b.values.Write(scratch[:4])
+	case Timestamp:
+		t, ok := value.(time.Time)
+		if !ok {
+			return fmt.Errorf("parquet: column %s expects a time.Time, got %T", column.Name, value)
+		}
+		binary.LittleEndian.PutUint64(scratch[:], uint64(t.UnixMilli()))
+		b.values.Write(scratch[:])
+	}
+	return nil
+}
+
+// toInt64 widens the integer types a column accepts
+func toInt64(value interface{}) (int64, bool) {
+	switch n := value.(type) {
+	case int:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+// flush writes the buffered rows as one row group
+func (pw *Writer) flush() error {
+	group := rowGroup{rows: int64(pw.rows)}
+
+	for i := range pw.columns {
+		chunk, err := pw.writeColumn(pw.columns[i], &pw.buffers[i])
+		if err != nil {
+			return err
+		}
+		group.columns = append(group.columns, chunk)
+		group.size += chunk.uncompressed
+
+		pw.buffers[i].values.Reset()
+		pw.buffers[i].levels = pw.buffers[i].levels[:0]
+	}
+
+	pw.groups = append(pw.groups, group)
+	pw.rows = 0
+	return nil
+}
+
+// writeColumn writes a column's buffered values as a single data page
+func (pw *Writer) writeColumn(column Column, buffer *columnBuffer) (columnChunk, error) {
+	// Page body: definition levels of optional columns, then plain values
+	var body bytes.Buffer
+	if column.Optional {
+		levels := encodeLevels(buffer.levels)
+		var length [4]byte
+		binary.LittleEndian.PutUint32(length[:], uint32(len(levels)))
+		body.Write(length[:])
+		body.Write(levels)
+	}
+	body.Write(buffer.values.Bytes())
+
+	pw.page.Reset()
+	pw.zip.Reset(&pw.page)
+	if _, err := pw.zip.Write(body.Bytes()); err != nil {
+		return columnChunk{}, fmt.Errorf("parquet: failed to compress page: %w", err)
+	}
+	if err := pw.zip.Close(); err != nil {
+		return columnChunk{}, fmt.Errorf("parquet: failed to compress page: %w", err)
+	}
+
+	var header thriftWriter
+	header.structBegin()
+	header.i32Field(1, pageTypeData)
+	header.i32Field(2, int32(body.Len()))
+	header.i32Field(3, int32(pw.page.Len()))
+	header.structField(5)
+	header.i32Field(1, int32(len(buffer.levels)))
+	header.i32Field(2, encodingPlain)
+	header.i32Field(3, encodingRLE)
+	header.i32Field(4, encodingRLE)
+	header.structEnd()
+	header.structEnd()
+
+	chunk := columnChunk{
+		offset:       pw.offset,
+		values:       int64(len(buffer.levels)),
+		uncompressed: int64(header.buf.Len() + body.Len()),
+		compressed:   int64(header.buf.Len() + pw.page.Len()),
+	}
+	if err := pw.write(header.buf.Bytes()); err != nil {
+		return columnChunk{}, err
+	}
+	if err := pw.write(pw.page.Bytes()); err != nil {
+		return columnChunk{}, err
+	}
+	return chunk, nil
+}
+
+// encodeLevels encodes definition levels of bit width 1 with the RLE/bit-
+// packing hybrid, using only RLE runs
+func encodeLevels(levels []byte) []byte {
+	var out []byte
+	var varint [binary.MaxVarintLen64]byte
+
+	for start := 0; start < len(levels); {
+		end := start
+		for end < len(levels) && levels[end] == levels[start] {
+			end++
+		}
+		n := binary.PutUvarint(varint[:], uint64(end-start)<<1)
+		out = append(out, varint[:n]...)
+		out = append(out, levels[start])
+		start = end
+	}
+	return out
+}
+
+// encodeFileMetaData encodes the footer describing the schema and every row
+// group written
+func (pw *Writer) encodeFileMetaData(t *thriftWriter) {
+	var rows int64
+	for _, group := range pw.groups {
+		rows += group.rows
+	}
+
+	t.structBegin()
+	t.i32Field(1, 1)
+
+	// The schema is a root group followed by its leaf columns
+	t.listField(2, thriftStruct, len(pw.columns)+1)
+	t.structBegin()
+	t.stringField(4, "schema")
+	t.i32Field(5, int32(len(pw.columns)))
+	t.structEnd()
+	for _, column := range pw.columns {
+		repetition := int32(repetitionRequired)
+		if column.Optional {
+			repetition = repetitionOptional
+		}
+
+		t.structBegin()
+		t.i32Field(1, column.Type.physicalType())
+		t.i32Field(3, repetition)
+		t.stringField(4, column.Name)
+		if converted, ok := column.Type.convertedType(); ok {
+			t.i32Field(6, converted)
+		}
+		t.structEnd()
+	}
+
+	t.i64Field(3, rows)
+
+	t.listField(4, thriftStruct, len(pw.groups))
+	for _, group := range pw.groups {
+		t.structBegin()
+		t.listField(1, thriftStruct, len(group.columns))
+		for i, chunk := range group.columns {
+			column := pw.columns[i]
+
+			t.structBegin()
+			t.i64Field(2, chunk.offset)
+			t.structField(3)
+			t.i32Field(1, column.Type.physicalType())
+			t.listField(2, thriftI32, 2)
+			t.i32(encodingPlain)
+			t.i32(encodingRLE)
+			t.listField(3, thriftBinary, 1)
+			t.string(column.Name)
+			t.i32Field(4, codecGzip)
+			t.i64Field(5, chunk.values)
+			t.i64Field(6, chunk.uncompressed)
+			t.i64Field(7, chunk.compressed)
+			t.i64Field(9, chunk.offset)
+			t.structEnd()
+			t.structEnd()
+		}
+		t.i64Field(2, group.size)
+		t.i64Field(3, group.rows)
+		t.structEnd()
+	}
+
+	t.stringField(6, createdBy)
+	t.structEnd()
+}
+
+// Thrift compact protocol type codes
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes the Thrift compact protocol subset the footer and page
+// headers need
+type thriftWriter struct {
+	buf    bytes.Buffer
+	last   int16
+	parent []int16
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	var scratch [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(scratch[:], v)
+	t.buf.Write(scratch[:n])
+}
+
+func (t *thriftWriter) zigzag(v int64) {
+	t.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (t *thriftWriter) fieldHeader(id int16, typ byte) {
+	if delta := id - t.last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.zigzag(int64(id))
+	}
+	t.last = id
+}
+
+func (t *thriftWriter) i32(v int32) {
+	t.zigzag(int64(v))
+}
+
+func (t *thriftWriter) string(s string) {
+	t.varint(uint64(len(s)))
+	t.buf.WriteString(s)
+}
+
+func (t *thriftWriter) i32Field(id int16, v int32) {
+	t.fieldHeader(id, thriftI32)
+	t.i32(v)
+}
+
+func (t *thriftWriter) i64Field(id int16, v int64) {
+	t.fieldHeader(id, thriftI64)
+	t.zigzag(v)
+}
+
+func (t *thriftWriter) stringField(id int16, s string) {
+	t.fieldHeader(id, thriftBinary)
+	t.string(s)
+}
+
+// listField writes a list header; the caller then writes size elements
+func (t *thriftWriter) listField(id int16, elem byte, size int) {
+	t.fieldHeader(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elem)
+		return
+	}
+	t.buf.WriteByte(0xF0 | elem)
+	t.varint(uint64(size))
+}
+
+// structField writes a struct field header and begins the nested struct
+func (t *thriftWriter) structField(id int16) {
+	t.fieldHeader(id, thriftStruct)
+	t.structBegin()
+}
+
+// structBegin starts a struct, whose field IDs are relative to its own
+func (t *thriftWriter) structBegin() {
+	t.parent = append(t.parent, t.last)
+	t.last = 0
+}
+
+// structEnd writes the field stop and returns to the enclosing struct
+func (t *thriftWriter) structEnd() {
+	t.buf.WriteByte(0)
+	t.last = t.parent[len(t.parent)-1]
+	t.parent = t.parent[:len(t.parent)-1]
+}
@@ -0,0 +1,156 @@
+// Package pdf provides a minimal PDF writer for text-based reports
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A4 page size in points
+const (
+	PageWidth  = 595.0
+	PageHeight = 842.0
+)
+
+// Font selects one of the standard fonts every PDF reader provides
+type Font int
+
+const (
+	Regular Font = iota
+	Bold
+)
+
+// resourceName returns the name a font is registered under in page resources
+func (f Font) resourceName() string {
+	if f == Bold {
+		return "F2"
+	}
+	return "F1"
+}
+
+// helveticaWidths holds the advance widths of printable ASCII characters in
+// Helvetica, in thousandths of the font size, starting at the space character
+var helveticaWidths = [...]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+// TextWidth returns the width in points of s set in Helvetica at the given
+// size. Digits and separators are the same width in Helvetica-Bold, so it is
+// also exact for right-aligning numbers in bold.
+func TextWidth(s string, size float64) float64 {
+	var units int
+	for _, r := range sanitize(s) {
+		units += helveticaWidths[r-' ']
+	}
+	return float64(units) * size / 1000
+}
+
+// Document is a PDF document built page by page. Coordinates are in points
+// with the origin at the bottom-left corner of the page.
+type Document struct {
+	pages []*bytes.Buffer
+}
+
+// New creates an empty document
+func New() *Document {
+	return &Document{}
+}
+
+// AddPage starts a new page; subsequent drawing goes to it
+func (d *Document) AddPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+}
+
+// PageCount returns the number of pages added so far
+func (d *Document) PageCount() int {
+	return len(d.pages)
+}
+
+// Text draws s with its baseline starting at (x, y)
+func (d *Document) Text(x, y float64, font Font, size float64, s string) {
+	fmt.Fprintf(d.page(), "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font.resourceName(), size, x, y, escape(sanitize(s)))
+}
+
+// TextRight draws s so that it ends at x
+func (d *Document) TextRight(x, y float64, font Font, size float64, s string) {
+	d.Text(x-TextWidth(s, size), y, font, size, s)
+}
+
+// Line draws a straight line of the given width
+func (d *Document) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(d.page(), "%.2f w %.2f %.2f m %.2f %.2f l S\n", width, x1, y1, x2, y2)
+}
+
+// page returns the current page, starting one if none exists
+func (d *Document) page() *bytes.Buffer {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+	return d.pages[len(d.pages)-1]
+}
+
+// WriteTo writes the document in PDF format
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	// Objects 1-4 are the catalog, page tree and fonts; each page then adds a
+	// page object followed by its content stream
+	var buf bytes.Buffer
+	offsets := make([]int, 0, 4+2*len(d.pages))
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, content := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			PageWidth, PageHeight, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return buf.WriteTo(w)
+}
+
+// sanitize replaces characters outside printable ASCII, which the standard
+// fonts are only measured for here, with '?'
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || r > '~' {
+			return '?'
+		}
+		return r
+	}, s)
+}
+
+// escape escapes the characters with special meaning in PDF string literals
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`).Replace(s)
+}
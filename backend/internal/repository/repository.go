@@ -5,82 +5,379 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
+	"indico-backend/internal/database"
 	"indico-backend/internal/errors"
+	"indico-backend/internal/metrics"
 	"indico-backend/internal/models"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
-// ProductRepository handles product data operations
-type ProductRepository interface {
+// pgUniqueViolation is the PostgreSQL error code for unique constraint violations
+const pgUniqueViolation = "23505"
+
+// pgForeignKeyViolation is the PostgreSQL error code for foreign key violations
+const pgForeignKeyViolation = "23503"
+
+// Names of the hot statements on the order path
+const (
+	stmtProductByID       = "product_by_id"
+	stmtProductForUpdate  = "product_for_update"
+	stmtUpdateStock       = "update_stock"
+	stmtCreateOrder       = "create_order"
+	stmtUpdateOrderStatus = "update_order_status"
+)
+
+// hotQueries holds the SQL of every hot statement. With Statements they are
+// parsed and planned once per connection instead of on every call.
+var hotQueries = map[string]string{
+	stmtProductByID: `
+		SELECT id, name, sku, barcode, stock, price, version, backorder_limit, backordered, created_at, updated_at
+		FROM products
+		WHERE id = $1`,
+	stmtProductForUpdate: `
+		SELECT id, name, sku, barcode, stock, price, version, backorder_limit, backordered, created_at, updated_at
+		FROM products
+		WHERE id = $1
+		FOR UPDATE`,
+	stmtUpdateStock: `
+		UPDATE products
+		SET stock = stock - $1, version = version + 1, updated_at = NOW()
+		WHERE id = $2 AND version = $3 AND stock >= $1`,
+	stmtCreateOrder: `
+		INSERT INTO orders (id, product_id, buyer_id, quantity, status, total_cents, client_reference, sales_event_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW())
+		RETURNING created_at, updated_at`,
+	stmtUpdateOrderStatus: `UPDATE orders SET status = $1, updated_at = NOW() WHERE id = $2 AND status = $3`,
+}
+
+// ProductReader is the read-only side of ProductRepository. Read replicas, caches and
+// test doubles can implement it without the write path.
+type ProductReader interface {
 	GetByID(ctx context.Context, id int) (*models.Product, error)
+	GetBySKU(ctx context.Context, sku string) (*models.Product, error)
+	GetByIDs(ctx context.Context, ids []int) ([]*models.Product, error)
+	List(ctx context.Context, limit, offset int) ([]*models.Product, error)
+	Snapshot(ctx context.Context, updatedSince time.Time, limit, offset int) ([]*models.ProductSnapshot, error)
+	ListMovements(ctx context.Context, productID int, limit, offset int) ([]*models.InventoryMovement, error)
+	ListBackordered(ctx context.Context) ([]int, error)
+}
+
+// ProductWriter mutates product data. Reads that take row locks inside a
+// transaction live here because they are only meaningful ahead of a write.
+type ProductWriter interface {
 	GetByIDForUpdate(ctx context.Context, tx *sql.Tx, id int) (*models.Product, error)
 	UpdateStock(ctx context.Context, tx *sql.Tx, id int, quantity int, version int) error
+	ReleaseStock(ctx context.Context, tx *sql.Tx, id int, quantity int) error
+	GetBySKUsForUpdate(ctx context.Context, tx *sql.Tx, skus []string) ([]*models.Product, error)
+	SetStock(ctx context.Context, tx *sql.Tx, id int, stock int) error
+	CreateMovements(ctx context.Context, tx *sql.Tx, movements []*models.InventoryMovement) error
+	SetBackorderLimit(ctx context.Context, tx *sql.Tx, id int, limit int) error
+	Backorder(ctx context.Context, tx *sql.Tx, id int, quantity int) error
+	ReleaseBackorder(ctx context.Context, tx *sql.Tx, id int, quantity int) error
+	Update(ctx context.Context, tx *sql.Tx, product *models.Product) error
+	Delete(ctx context.Context, tx *sql.Tx, id int) error
 	Create(ctx context.Context, product *models.Product) error
 }
 
-// OrderRepository handles order data operations
-type OrderRepository interface {
-	Create(ctx context.Context, tx *sql.Tx, order *models.Order) error
+// ProductRepository handles product data operations
+type ProductRepository interface {
+	ProductReader
+	ProductWriter
+}
+
+// OrderReader is the read-only side of OrderRepository
+type OrderReader interface {
 	GetByID(ctx context.Context, id uuid.UUID) (*models.Order, error)
+	GetByClientReference(ctx context.Context, clientReference string) (*models.Order, error)
+	GetRecentDuplicate(ctx context.Context, buyerID string, productID, quantity int, within time.Duration) (*models.Order, error)
 	List(ctx context.Context, limit, offset int) ([]*models.Order, error)
+	Stream(ctx context.Context, limit, offset int, fn func(*models.Order) error) error
+	ListAfter(ctx context.Context, after *models.OrderCursor, limit int) ([]*models.Order, error)
+	Count(ctx context.Context) (int, error)
+	ListEvents(ctx context.Context, orderID uuid.UUID) ([]*models.OrderEvent, error)
+	DailySalesByProduct(ctx context.Context, from, to time.Time) ([]*models.ProductDailySales, error)
+	CountForExport(ctx context.Context, filter *models.OrderExportFilter) (int, error)
+	ListForExport(ctx context.Context, filter *models.OrderExportFilter, after *models.OrderCursor, limit int) ([]*models.Order, error)
 }
 
-// TransactionRepository handles transaction data operations
-type TransactionRepository interface {
-	GetBatch(ctx context.Context, offset, limit int, from, to time.Time) ([]*models.Transaction, error)
+// OrderWriter mutates order data
+type OrderWriter interface {
+	Create(ctx context.Context, tx *sql.Tx, order *models.Order) error
+	UpdateStatus(ctx context.Context, tx *sql.Tx, id uuid.UUID, from, to models.OrderStatus) error
+	AddEvent(ctx context.Context, tx *sql.Tx, event *models.OrderEvent) error
+	AddComponents(ctx context.Context, tx *sql.Tx, orderID uuid.UUID, components []*models.OrderComponent) error
+	ListBackorderedForUpdate(ctx context.Context, tx *sql.Tx, productID int) ([]*models.Order, error)
+	GetByIDForUpdate(ctx context.Context, tx *sql.Tx, id uuid.UUID) (*models.Order, error)
+	BuyerEventQuantity(ctx context.Context, tx *sql.Tx, eventID int, buyerID string, productID int) (int, error)
+	AnonymizeBuyer(ctx context.Context, buyerID, pseudonym string, limit int) (int, error)
+}
+
+// OrderRepository handles order data operations
+type OrderRepository interface {
+	OrderReader
+	OrderWriter
+}
+
+// TransactionReader is the read-only side of TransactionRepository
+type TransactionReader interface {
+	List(ctx context.Context, filter *models.TransactionFilter, limit, offset int) ([]*models.Transaction, error)
+	Stream(ctx context.Context, filter *models.TransactionFilter, limit, offset int, fn func(*models.Transaction) error) error
+	Sample(ctx context.Context, filter *models.TransactionFilter, n int) ([]*models.Transaction, error)
+	StreamForSettlement(ctx context.Context, from, to time.Time, fn func(*models.Transaction) error) error
 	GetTotalCount(ctx context.Context, from, to time.Time) (int, error)
+	SummarizeByMerchant(ctx context.Context, merchantID string, from, to time.Time) (*models.MerchantTransactionSummary, error)
+	SummarizeUnsettledByMerchant(ctx context.Context, merchantID string, from, to time.Time) (*models.MerchantUnsettledSummary, error)
+	AggregateByMerchantAndDay(ctx context.Context, merchantID string, from, to time.Time, cutoffAt *time.Time) ([]*models.Settlement, error)
+	LatestActivityDaily(ctx context.Context, from, to time.Time) ([]*models.MerchantDayActivity, error)
+	ListRecordedAfterPaidDay(ctx context.Context, from, to time.Time, after time.Duration) ([]*models.Transaction, error)
+	GetByID(ctx context.Context, id int) (*models.Transaction, error)
+}
+
+// TransactionWriter mutates transaction data
+type TransactionWriter interface {
 	Create(ctx context.Context, tx *models.Transaction) error
 	BulkCreate(ctx context.Context, transactions []*models.Transaction) error
+	Truncate(ctx context.Context) error
+	UpdateStatus(ctx context.Context, tx *sql.Tx, id int, from, to models.TransactionStatus) error
+	GetByPaymentID(ctx context.Context, tx *sql.Tx, paymentID string) (*models.Transaction, error)
+	CreateForPayment(ctx context.Context, tx *sql.Tx, txn *models.Transaction) error
+	RecordPaymentEvent(ctx context.Context, tx *sql.Tx, event *models.PaymentEvent) (bool, error)
+}
+
+// TransactionRepository handles transaction data operations
+type TransactionRepository interface {
+	TransactionReader
+	TransactionWriter
+}
+
+// SettlementReader is the read-only side of SettlementRepository
+type SettlementReader interface {
+	GetByMerchantAndDate(ctx context.Context, merchantID string, date time.Time) (*models.Settlement, error)
+	ListByRun(ctx context.Context, runID uuid.UUID) ([]*models.Settlement, error)
+	ListByRuns(ctx context.Context, runIDs []uuid.UUID) ([]*models.Settlement, error)
+	GetRun(ctx context.Context, id uuid.UUID) (*models.SettlementRun, error)
+	GetLatestRun(ctx context.Context) (*models.SettlementRun, error)
+	ListRuns(ctx context.Context, limit, offset int) ([]*models.SettlementRun, error)
+	SummarizeByMerchant(ctx context.Context, merchantID string, from, to time.Time) (*models.MerchantSettlementSummary, error)
+	ListCurrent(ctx context.Context, from, to time.Time) ([]*models.Settlement, error)
+	ListCurrentByMerchant(ctx context.Context, merchantID string, from, to time.Time) ([]*models.Settlement, error)
+	ListStale(ctx context.Context, limit, offset int) ([]*models.Settlement, error)
+	List(ctx context.Context, filter *models.SettlementFilter, limit, offset int) ([]*models.Settlement, error)
+	Stream(ctx context.Context, filter *models.SettlementFilter, limit, offset int, fn func(*models.Settlement) error) error
+	ListAdjustments(ctx context.Context, filter *models.SettlementFilter) ([]*models.SettlementAdjustment, error)
+}
+
+// SettlementWriter mutates settlement data
+type SettlementWriter interface {
+	Create(ctx context.Context, tx *sql.Tx, settlement *models.Settlement) error
+	CreateBatch(ctx context.Context, tx *sql.Tx, settlements []*models.Settlement) error
+	CreateRun(ctx context.Context, tx *sql.Tx, run *models.SettlementRun) error
+	SupersedeRange(ctx context.Context, tx *sql.Tx, runID uuid.UUID, from, to time.Time, keep []*models.Settlement) error
+	Supersede(ctx context.Context, tx *sql.Tx, runID uuid.UUID, merchantID string, date time.Time) error
+	MarkStale(ctx context.Context, tx *sql.Tx, merchantID string, date time.Time, reason string) (*models.Settlement, error)
+	CreateAdjustment(ctx context.Context, adjustment *models.SettlementAdjustment) error
 }
 
 // SettlementRepository handles settlement data operations
 type SettlementRepository interface {
-	Upsert(ctx context.Context, tx *sql.Tx, settlement *models.Settlement) error
-	GetByMerchantAndDate(ctx context.Context, merchantID string, date time.Time) (*models.Settlement, error)
+	SettlementReader
+	SettlementWriter
+}
+
+// ForecastRepository handles reorder forecast data operations
+type ForecastRepository interface {
+	CreateRecommendations(ctx context.Context, tx *sql.Tx, recommendations []*models.ReorderRecommendation) error
+	ListRecommendationsByJob(ctx context.Context, jobID uuid.UUID) ([]*models.ReorderRecommendation, error)
+}
+
+// BundleRepository handles product bundle data operations. A bundle is a
+// products row with components; it takes no stock of its own.
+type BundleRepository interface {
+	GetByID(ctx context.Context, id int) (*models.Bundle, error)
+	List(ctx context.Context, limit, offset int) ([]*models.Bundle, error)
+	ListBundleIDs(ctx context.Context, productIDs []int) ([]int, error)
+	GetComponents(ctx context.Context, tx *sql.Tx, bundleID int) ([]*models.BundleComponent, error)
+	Create(ctx context.Context, tx *sql.Tx, bundle *models.Bundle) error
+	Update(ctx context.Context, tx *sql.Tx, bundle *models.Bundle) error
+}
+
+// SalesEventRepository handles sales event data operations
+type SalesEventRepository interface {
+	GetByID(ctx context.Context, id int) (*models.SalesEvent, error)
+	List(ctx context.Context, limit, offset int) ([]*models.SalesEvent, error)
+	ListSchedule(ctx context.Context) ([]*models.SalesEvent, error)
+	Stats(ctx context.Context, id int) ([]*models.SalesEventProductStats, error)
+	Create(ctx context.Context, tx *sql.Tx, event *models.SalesEvent) error
+	Delete(ctx context.Context, id int) error
+}
+
+// CalendarRepository handles settlement calendar data operations
+type CalendarRepository interface {
+	ListHolidays(ctx context.Context, region string) ([]*models.Holiday, error)
+	CreateHoliday(ctx context.Context, holiday *models.Holiday) error
+	ListMerchantCalendars(ctx context.Context) ([]*models.MerchantCalendar, error)
+	SetMerchantCalendar(ctx context.Context, assignment *models.MerchantCalendar) error
+}
+
+// PayoutRepository handles merchant bank accounts and the payouts sent to
+// them
+type PayoutRepository interface {
+	SetBankAccount(ctx context.Context, account *models.MerchantBankAccount) error
+	GetBankAccount(ctx context.Context, merchantID string) (*models.MerchantBankAccount, error)
+	GetBankAccountForUpdate(ctx context.Context, tx *sql.Tx, merchantID string) (*models.MerchantBankAccount, error)
+	Create(ctx context.Context, tx *sql.Tx, payout *models.Payout) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Payout, error)
+	List(ctx context.Context, status models.PayoutStatus, limit, offset int) ([]*models.Payout, error)
+	Approve(ctx context.Context, id uuid.UUID) (*models.Payout, error)
+	CountApproved(ctx context.Context, scheme models.PayoutScheme) (int, error)
+	ClaimForExport(ctx context.Context, tx *sql.Tx, jobID uuid.UUID, scheme models.PayoutScheme) ([]*models.PayoutExport, error)
+	SumExportedByMerchant(ctx context.Context, merchantID string, from, to time.Time) (int, error)
 }
 
 // JobRepository handles job data operations
 type JobRepository interface {
 	Create(ctx context.Context, job *models.Job) error
 	GetByID(ctx context.Context, id uuid.UUID) (*models.Job, error)
+	GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*models.Job, error)
 	UpdateStatus(ctx context.Context, id uuid.UUID, status models.JobStatus) error
 	UpdateProgress(ctx context.Context, id uuid.UUID, progress float64, processed int) error
 	UpdateResult(ctx context.Context, id uuid.UUID, resultPath, downloadURL string) error
 	UpdateError(ctx context.Context, id uuid.UUID, errMsg string) error
-	MarkStarted(ctx context.Context, id uuid.UUID) error
+	MarkStarted(ctx context.Context, id uuid.UUID) (bool, error)
 	MarkCompleted(ctx context.Context, id uuid.UUID) error
-	Cancel(ctx context.Context, id uuid.UUID) error
+	MarkFailed(ctx context.Context, id uuid.UUID, errMsg string) error
+	Cancel(ctx context.Context, id uuid.UUID) (models.JobStatus, error)
+	MarkCancelled(ctx context.Context, id uuid.UUID) error
 	IsCancelled(ctx context.Context, id uuid.UUID) (bool, error)
+	CountActiveByPrincipal(ctx context.Context, principal string, jobType models.JobType) (int, error)
+	MarkRetrying(ctx context.Context, id uuid.UUID, errMsg string) error
+	MarkDeadLettered(ctx context.Context, id uuid.UUID, errMsg string) error
+	List(ctx context.Context, filter *models.JobFilter, limit, offset int) ([]*models.Job, error)
+	ListDeadLettered(ctx context.Context, limit, offset int) ([]*models.Job, error)
+	ListByStatus(ctx context.Context, statuses []models.JobStatus, updatedBefore time.Time) ([]*models.Job, error)
+	CountByStatus(ctx context.Context, status models.JobStatus) (int, error)
+	Redrive(ctx context.Context, id uuid.UUID) error
+	FindOverlappingLock(ctx context.Context, jobType models.JobType, from, to time.Time) (*models.JobLock, error)
+	AcquireLock(ctx context.Context, tx *sql.Tx, lock *models.JobLock) error
+	ReleaseLock(ctx context.Context, id uuid.UUID) error
+	AnonymizeBuyer(ctx context.Context, buyerID, pseudonym string) ([]string, error)
+	SaveFiles(ctx context.Context, tx *sql.Tx, jobID uuid.UUID, files []*models.JobFile) error
+	ListFiles(ctx context.Context, jobID uuid.UUID) ([]*models.JobFile, error)
+	SaveStats(ctx context.Context, stats *models.JobStats) error
+	GetStats(ctx context.Context, jobID uuid.UUID) (*models.JobStats, error)
+	AppendLogs(ctx context.Context, entries []*models.JobLogEntry) error
+	ListLogs(ctx context.Context, jobID uuid.UUID, levels []string, after int64, limit int) ([]*models.JobLogEntry, error)
+}
+
+// SagaRepository handles saga state persistence
+type SagaRepository interface {
+	Create(ctx context.Context, saga *models.Saga) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Saga, error)
+	SetProgress(ctx context.Context, tx *sql.Tx, id uuid.UUID, completedSteps int, status models.SagaStatus, payload []byte) error
+	SetStatus(ctx context.Context, id uuid.UUID, status models.SagaStatus, failedStep, errMsg string) error
+	ListByStatus(ctx context.Context, status models.SagaStatus, limit, offset int) ([]*models.Saga, error)
+	ListUnfinished(ctx context.Context, updatedBefore time.Time) ([]*models.Saga, error)
+	AnonymizeBuyer(ctx context.Context, buyerID, pseudonym string) (int, error)
+}
+
+// AuthBanRepository keeps the audit trail of authentication bans
+type AuthBanRepository interface {
+	Record(ctx context.Context, ban *models.AuthBan) error
+	List(ctx context.Context, limit, offset int) ([]*models.AuthBan, error)
+}
+
+// MaintenanceRepository stores the maintenance mode shared by all replicas
+type MaintenanceRepository interface {
+	Get(ctx context.Context) (*models.MaintenanceState, error)
+	Set(ctx context.Context, enabled bool, message string) (*models.MaintenanceState, error)
+}
+
+// SearchRepository reads changed rows for the search indexer and tracks how
+// far each index has been synced
+type SearchRepository interface {
+	Now(ctx context.Context) (time.Time, error)
+	GetSyncCursor(ctx context.Context, index string) (time.Time, error)
+	SaveSyncCursor(ctx context.Context, index string, syncedUntil time.Time) error
+	ProductsChangedAfter(ctx context.Context, updatedAt time.Time, id int, limit int) ([]*models.Product, error)
+	OrdersChangedAfter(ctx context.Context, updatedAt time.Time, id uuid.UUID, limit int) ([]*models.Order, error)
+}
+
+// AnalyticsRepository maintains the order analytics projection and serves
+// dashboard queries from it
+type AnalyticsRepository interface {
+	Now(ctx context.Context) (time.Time, error)
+	GetCursor(ctx context.Context, name string) (time.Time, error)
+	SaveCursor(ctx context.Context, name string, syncedUntil time.Time) error
+	ProjectOrders(ctx context.Context, updatedAt time.Time, id uuid.UUID, limit int) (int, time.Time, uuid.UUID, error)
+	DailyProductSales(ctx context.Context, from, to time.Time, productID int) ([]*models.OrderAnalyticsDay, error)
+}
+
+// StatsRepository computes operational aggregates for admin dashboards
+type StatsRepository interface {
+	OrdersPerMinute(ctx context.Context, since time.Time) ([]*models.MinuteCount, error)
+	JobThroughput(ctx context.Context, since time.Time) ([]*models.JobTypeThroughput, error)
+	JobQueue(ctx context.Context, failedSince time.Time) ([]*models.JobTypeQueue, error)
+	ErrorRates(ctx context.Context, since time.Time) (*models.ErrorRateStats, error)
+	TopMerchants(ctx context.Context, from, to time.Time, limit int) ([]*models.MerchantVolume, error)
+}
+
+// WebhookRepository stores the receivers of outgoing webhooks
+type WebhookRepository interface {
+	Create(ctx context.Context, endpoint *models.WebhookEndpoint) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.WebhookEndpoint, error)
+	List(ctx context.Context) ([]*models.WebhookEndpoint, error)
+	Delete(ctx context.Context, id uuid.UUID) error
 }
 
 // productRepository implements ProductRepository
 type productRepository struct {
-	db *sql.DB
+	db    *sql.DB
+	stmts *Statements
+
+	// byID coalesces concurrent reads of the same product
+	byID *database.Coalescer
 }
 
-// NewProductRepository creates a new product repository
-func NewProductRepository(db *sql.DB) ProductRepository {
-	return &productRepository{db: db}
+// NewProductRepository creates a new product repository; with nil statements
+// every query runs ad hoc
+func NewProductRepository(db *sql.DB, stmts *Statements) ProductRepository {
+	return &productRepository{db: db, stmts: stmts, byID: database.NewCoalescer(stmtProductByID)}
 }
 
+// GetByID reads a product. Concurrent reads of the same product, such as a
+// hot product during a drop, share one query; each caller gets its own copy.
 func (r *productRepository) GetByID(ctx context.Context, id int) (*models.Product, error) {
-	query := `
-		SELECT id, name, stock, price, version, created_at, updated_at
-		FROM products 
-		WHERE id = $1`
+	shared, err := database.Coalesce(ctx, r.byID, strconv.Itoa(id), func(ctx context.Context) (*models.Product, error) {
+		return r.getByID(ctx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	product := *shared
+	return &product, nil
+}
 
+func (r *productRepository) getByID(ctx context.Context, id int) (*models.Product, error) {
 	var product models.Product
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
+	err := r.stmts.queryRow(ctx, r.db, nil, stmtProductByID, id).Scan(
 		&product.ID,
 		&product.Name,
+		&product.SKU,
+		&product.Barcode,
 		&product.Stock,
 		&product.Price,
 		&product.Version,
+		&product.BackorderLimit,
+		&product.Backordered,
 		&product.CreatedAt,
 		&product.UpdatedAt,
 	)
@@ -95,20 +392,23 @@ func (r *productRepository) GetByID(ctx context.Context, id int) (*models.Produc
 	return &product, nil
 }
 
-func (r *productRepository) GetByIDForUpdate(ctx context.Context, tx *sql.Tx, id int) (*models.Product, error) {
+func (r *productRepository) GetBySKU(ctx context.Context, sku string) (*models.Product, error) {
 	query := `
-		SELECT id, name, stock, price, version, created_at, updated_at
-		FROM products 
-		WHERE id = $1
-		FOR UPDATE`
+		SELECT id, name, sku, barcode, stock, price, version, backorder_limit, backordered, created_at, updated_at
+		FROM products
+		WHERE sku = $1`
 
 	var product models.Product
-	err := tx.QueryRowContext(ctx, query, id).Scan(
+	err := r.db.QueryRowContext(ctx, query, sku).Scan(
 		&product.ID,
 		&product.Name,
+		&product.SKU,
+		&product.Barcode,
 		&product.Stock,
 		&product.Price,
 		&product.Version,
+		&product.BackorderLimit,
+		&product.Backordered,
 		&product.CreatedAt,
 		&product.UpdatedAt,
 	)
@@ -117,19 +417,125 @@ func (r *productRepository) GetByIDForUpdate(ctx context.Context, tx *sql.Tx, id
 		return nil, errors.ErrProductNotFound
 	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get product for update: %w", err)
+		return nil, fmt.Errorf("failed to get product by sku: %w", err)
 	}
 
 	return &product, nil
 }
 
-func (r *productRepository) UpdateStock(ctx context.Context, tx *sql.Tx, id int, quantity int, version int) error {
+func (r *productRepository) GetByIDs(ctx context.Context, ids []int) ([]*models.Product, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
 	query := `
-		UPDATE products 
-		SET stock = stock - $1, version = version + 1, updated_at = NOW()
-		WHERE id = $2 AND version = $3 AND stock >= $1`
+		SELECT id, name, sku, barcode, stock, price, version, backorder_limit, backordered, created_at, updated_at
+		FROM products
+		WHERE id = ANY($1)`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get products: %w", err)
+	}
+	defer rows.Close()
+
+	var products []*models.Product
+	for rows.Next() {
+		var product models.Product
+		err := rows.Scan(
+			&product.ID,
+			&product.Name,
+			&product.SKU,
+			&product.Barcode,
+			&product.Stock,
+			&product.Price,
+			&product.Version,
+			&product.BackorderLimit,
+			&product.Backordered,
+			&product.CreatedAt,
+			&product.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan product: %w", err)
+		}
+		products = append(products, &product)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate product rows: %w", err)
+	}
+
+	return products, nil
+}
+
+func (r *productRepository) List(ctx context.Context, limit, offset int) ([]*models.Product, error) {
+	query := `
+		SELECT id, name, sku, barcode, stock, price, version, backorder_limit, backordered, created_at, updated_at
+		FROM products
+		ORDER BY id
+		LIMIT $1 OFFSET $2`
+
+	rows, err := r.db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list products: %w", err)
+	}
+	defer rows.Close()
+
+	var products []*models.Product
+	for rows.Next() {
+		var product models.Product
+		err := rows.Scan(
+			&product.ID,
+			&product.Name,
+			&product.SKU,
+			&product.Barcode,
+			&product.Stock,
+			&product.Price,
+			&product.Version,
+			&product.BackorderLimit,
+			&product.Backordered,
+			&product.CreatedAt,
+			&product.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan product: %w", err)
+		}
+		products = append(products, &product)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate product rows: %w", err)
+	}
+
+	return products, nil
+}
+
+func (r *productRepository) GetByIDForUpdate(ctx context.Context, tx *sql.Tx, id int) (*models.Product, error) {
+	var product models.Product
+	err := r.stmts.queryRow(ctx, r.db, tx, stmtProductForUpdate, id).Scan(
+		&product.ID,
+		&product.Name,
+		&product.SKU,
+		&product.Barcode,
+		&product.Stock,
+		&product.Price,
+		&product.Version,
+		&product.BackorderLimit,
+		&product.Backordered,
+		&product.CreatedAt,
+		&product.UpdatedAt,
+	)
 
-	result, err := tx.ExecContext(ctx, query, quantity, id, version)
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrProductNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product for update: %w", err)
+	}
+
+	return &product, nil
+}
+
+func (r *productRepository) UpdateStock(ctx context.Context, tx *sql.Tx, id int, quantity int, version int) error {
+	result, err := r.stmts.exec(ctx, r.db, tx, stmtUpdateStock, quantity, id, version)
 	if err != nil {
 		return fmt.Errorf("failed to update stock: %w", err)
 	}
@@ -157,497 +563,5062 @@ func (r *productRepository) UpdateStock(ctx context.Context, tx *sql.Tx, id int,
 	return nil
 }
 
-func (r *productRepository) Create(ctx context.Context, product *models.Product) error {
+// ReleaseStock returns previously reserved units to a product's stock
+func (r *productRepository) ReleaseStock(ctx context.Context, tx *sql.Tx, id int, quantity int) error {
 	query := `
-		INSERT INTO products (name, stock, price, version, created_at, updated_at)
-		VALUES ($1, $2, $3, 1, NOW(), NOW())
-		RETURNING id, created_at, updated_at`
+		UPDATE products
+		SET stock = stock + $1, version = version + 1, updated_at = NOW()
+		WHERE id = $2`
 
-	err := r.db.QueryRowContext(ctx, query, product.Name, product.Stock, product.Price).Scan(
-		&product.ID,
-		&product.CreatedAt,
-		&product.UpdatedAt,
-	)
+	result, err := tx.ExecContext(ctx, query, quantity, id)
 	if err != nil {
-		return fmt.Errorf("failed to create product: %w", err)
+		return fmt.Errorf("failed to release stock: %w", err)
 	}
 
-	product.Version = 1
-	return nil
-}
-
-// orderRepository implements OrderRepository
-type orderRepository struct {
-	db *sql.DB
-}
-
-// NewOrderRepository creates a new order repository
-func NewOrderRepository(db *sql.DB) OrderRepository {
-	return &orderRepository{db: db}
-}
-
-func (r *orderRepository) Create(ctx context.Context, tx *sql.Tx, order *models.Order) error {
-	query := `
-		INSERT INTO orders (id, product_id, buyer_id, quantity, status, total_cents, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
-		RETURNING created_at, updated_at`
-
-	err := tx.QueryRowContext(ctx, query,
-		order.ID,
-		order.ProductID,
-		order.BuyerID,
-		order.Quantity,
-		order.Status,
-		order.TotalCents,
-	).Scan(&order.CreatedAt, &order.UpdatedAt)
-
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to create order: %w", err)
+		return fmt.Errorf("failed to get rows affected: %w", err)
 	}
 
-	return nil
-}
-
-func (r *orderRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Order, error) {
-	query := `
-		SELECT o.id, o.product_id, o.buyer_id, o.quantity, o.status, o.total_cents, 
-			   o.created_at, o.updated_at,
-			   p.id, p.name, p.stock, p.price, p.version, p.created_at, p.updated_at
-		FROM orders o
-		JOIN products p ON o.product_id = p.id
-		WHERE o.id = $1`
-
-	var order models.Order
-	var product models.Product
-
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&order.ID,
-		&order.ProductID,
-		&order.BuyerID,
-		&order.Quantity,
-		&order.Status,
-		&order.TotalCents,
-		&order.CreatedAt,
-		&order.UpdatedAt,
-		&product.ID,
-		&product.Name,
-		&product.Stock,
-		&product.Price,
-		&product.Version,
-		&product.CreatedAt,
-		&product.UpdatedAt,
-	)
-
-	if err == sql.ErrNoRows {
-		return nil, errors.ErrOrderNotFound
-	}
-	if err != nil {
-		return nil, fmt.Errorf("failed to get order: %w", err)
+	if rowsAffected == 0 {
+		return errors.ErrProductNotFound
 	}
 
-	order.Product = &product
-	return &order, nil
+	return nil
 }
 
-func (r *orderRepository) List(ctx context.Context, limit, offset int) ([]*models.Order, error) {
+// Snapshot pages through products updated at or after updatedSince, oldest
+// change first, so a poller can resume from the last updated_at it saw
+func (r *productRepository) Snapshot(ctx context.Context, updatedSince time.Time, limit, offset int) ([]*models.ProductSnapshot, error) {
 	query := `
-		SELECT id, product_id, buyer_id, quantity, status, total_cents, created_at, updated_at
-		FROM orders
-		ORDER BY created_at DESC
-		LIMIT $1 OFFSET $2`
+		SELECT id, sku, price, stock, version, updated_at
+		FROM products
+		WHERE updated_at >= $1
+		ORDER BY updated_at, id
+		LIMIT $2 OFFSET $3`
 
-	rows, err := r.db.QueryContext(ctx, query, limit, offset)
+	rows, err := r.db.QueryContext(ctx, query, updatedSince, limit, offset)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list orders: %w", err)
+		return nil, fmt.Errorf("failed to get product snapshot: %w", err)
 	}
 	defer rows.Close()
 
-	var orders []*models.Order
+	snapshots := []*models.ProductSnapshot{}
 	for rows.Next() {
-		var order models.Order
+		var snapshot models.ProductSnapshot
 		err := rows.Scan(
-			&order.ID,
-			&order.ProductID,
-			&order.BuyerID,
-			&order.Quantity,
-			&order.Status,
-			&order.TotalCents,
-			&order.CreatedAt,
-			&order.UpdatedAt,
+			&snapshot.ID,
+			&snapshot.SKU,
+			&snapshot.Price,
+			&snapshot.Stock,
+			&snapshot.Version,
+			&snapshot.UpdatedAt,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan order: %w", err)
+			return nil, fmt.Errorf("failed to scan product snapshot: %w", err)
 		}
-		orders = append(orders, &order)
+		snapshots = append(snapshots, &snapshot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate product snapshot rows: %w", err)
 	}
 
-	return orders, nil
-}
-
-// transactionRepository implements TransactionRepository
-type transactionRepository struct {
-	db *sql.DB
+	return snapshots, nil
 }
 
-// NewTransactionRepository creates a new transaction repository
-func NewTransactionRepository(db *sql.DB) TransactionRepository {
-	return &transactionRepository{db: db}
-}
+// GetBySKUsForUpdate locks the products with the given SKUs in ID order, so
+// concurrent syncs touching the same products cannot deadlock
+func (r *productRepository) GetBySKUsForUpdate(ctx context.Context, tx *sql.Tx, skus []string) ([]*models.Product, error) {
+	if len(skus) == 0 {
+		return nil, nil
+	}
 
-func (r *transactionRepository) GetBatch(ctx context.Context, offset, limit int, from, to time.Time) ([]*models.Transaction, error) {
 	query := `
-		SELECT id, merchant_id, amount_cents, fee_cents, status, paid_at, created_at
-		FROM transactions
-		WHERE paid_at >= $1 AND paid_at < $2 AND status = 'COMPLETED'
+		SELECT id, name, sku, barcode, stock, price, version, backorder_limit, backordered, created_at, updated_at
+		FROM products
+		WHERE sku = ANY($1)
 		ORDER BY id
-		LIMIT $3 OFFSET $4`
+		FOR UPDATE`
 
-	rows, err := r.db.QueryContext(ctx, query, from, to, limit, offset)
+	rows, err := tx.QueryContext(ctx, query, pq.Array(skus))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get transaction batch: %w", err)
+		return nil, fmt.Errorf("failed to get products for update: %w", err)
 	}
 	defer rows.Close()
 
-	var transactions []*models.Transaction
+	var products []*models.Product
 	for rows.Next() {
-		var tx models.Transaction
+		var product models.Product
 		err := rows.Scan(
-			&tx.ID,
-			&tx.MerchantID,
-			&tx.AmountCents,
-			&tx.FeeCents,
-			&tx.Status,
-			&tx.PaidAt,
-			&tx.CreatedAt,
+			&product.ID,
+			&product.Name,
+			&product.SKU,
+			&product.Barcode,
+			&product.Stock,
+			&product.Price,
+			&product.Version,
+			&product.BackorderLimit,
+			&product.Backordered,
+			&product.CreatedAt,
+			&product.UpdatedAt,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan transaction: %w", err)
+			return nil, fmt.Errorf("failed to scan product: %w", err)
 		}
-		transactions = append(transactions, &tx)
+		products = append(products, &product)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate product rows: %w", err)
 	}
 
-	return transactions, nil
+	return products, nil
+}
+
+// SetStock overwrites a product's stock and bumps its version, so orders
+// that read the old version retry against the new level
+func (r *productRepository) SetStock(ctx context.Context, tx *sql.Tx, id int, stock int) error {
+	query := `
+		UPDATE products
+		SET stock = $1, version = version + 1, updated_at = NOW()
+		WHERE id = $2`
+
+	result, err := tx.ExecContext(ctx, query, stock, id)
+	if err != nil {
+		return fmt.Errorf("failed to set stock: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.ErrProductNotFound
+	}
+
+	return nil
+}
+
+// CreateMovements records stock changes in the inventory ledger
+func (r *productRepository) CreateMovements(ctx context.Context, tx *sql.Tx, movements []*models.InventoryMovement) error {
+	query := `
+		INSERT INTO inventory_movements (product_id, sku, previous_stock, new_stock, delta, reason, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		RETURNING id, created_at`
+
+	for _, movement := range movements {
+		err := tx.QueryRowContext(ctx, query,
+			movement.ProductID,
+			movement.SKU,
+			movement.PreviousStock,
+			movement.NewStock,
+			movement.Delta,
+			movement.Reason,
+		).Scan(&movement.ID, &movement.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to create inventory movement: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ListMovements returns a product's inventory movements, newest first
+func (r *productRepository) ListMovements(ctx context.Context, productID int, limit, offset int) ([]*models.InventoryMovement, error) {
+	query := `
+		SELECT id, product_id, sku, previous_stock, new_stock, delta, reason, created_at
+		FROM inventory_movements
+		WHERE product_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := r.db.QueryContext(ctx, query, productID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list inventory movements: %w", err)
+	}
+	defer rows.Close()
+
+	var movements []*models.InventoryMovement
+	for rows.Next() {
+		var movement models.InventoryMovement
+		err := rows.Scan(
+			&movement.ID,
+			&movement.ProductID,
+			&movement.SKU,
+			&movement.PreviousStock,
+			&movement.NewStock,
+			&movement.Delta,
+			&movement.Reason,
+			&movement.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan inventory movement: %w", err)
+		}
+		movements = append(movements, &movement)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate inventory movement rows: %w", err)
+	}
+
+	return movements, nil
+}
+
+// ListBackordered returns the IDs of products with backorders awaiting
+// stock
+func (r *productRepository) ListBackordered(ctx context.Context) ([]int, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id FROM products WHERE backordered > 0 ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backordered products: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan backordered product: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate backordered product rows: %w", err)
+	}
+
+	return ids, nil
+}
+
+// SetBackorderLimit sets how many units of a product may be ordered beyond
+// its stock. Lowering it below the units already backordered only stops
+// new backorders.
+func (r *productRepository) SetBackorderLimit(ctx context.Context, tx *sql.Tx, id int, limit int) error {
+	query := `
+		UPDATE products
+		SET backorder_limit = $1, version = version + 1, updated_at = NOW()
+		WHERE id = $2`
+
+	return r.execProduct(ctx, tx, "set backorder limit", query, limit, id)
+}
+
+// Backorder counts units ordered beyond a product's stock. The version is
+// left alone: the count does not change the stock orders compete for.
+func (r *productRepository) Backorder(ctx context.Context, tx *sql.Tx, id int, quantity int) error {
+	query := `
+		UPDATE products
+		SET backordered = backordered + $1, updated_at = NOW()
+		WHERE id = $2`
+
+	return r.execProduct(ctx, tx, "backorder", query, quantity, id)
+}
+
+// ReleaseBackorder stops counting units of a product as backordered, once
+// their order is confirmed or cancelled
+func (r *productRepository) ReleaseBackorder(ctx context.Context, tx *sql.Tx, id int, quantity int) error {
+	query := `
+		UPDATE products
+		SET backordered = backordered - $1, updated_at = NOW()
+		WHERE id = $2`
+
+	return r.execProduct(ctx, tx, "release backorder", query, quantity, id)
+}
+
+// execProduct runs an update of a single product, reporting a missing one
+func (r *productRepository) execProduct(ctx context.Context, tx *sql.Tx, action, query string, args ...interface{}) error {
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to %s: %w", action, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.ErrProductNotFound
+	}
+
+	return nil
+}
+
+func (r *productRepository) Create(ctx context.Context, product *models.Product) error {
+	query := `
+		INSERT INTO products (name, sku, barcode, stock, price, version, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, 1, NOW(), NOW())
+		RETURNING id, created_at, updated_at`
+
+	err := r.db.QueryRowContext(ctx, query, product.Name, product.SKU, product.Barcode, product.Stock, product.Price).Scan(
+		&product.ID,
+		&product.CreatedAt,
+		&product.UpdatedAt,
+	)
+	if err != nil {
+		if dupErr := productUniqueViolation(err); dupErr != nil {
+			return dupErr
+		}
+		return fmt.Errorf("failed to create product: %w", err)
+	}
+
+	product.Version = 1
+	return nil
+}
+
+// Update replaces a product's name, SKU, barcode, price and stock, bumping
+// its version so orders that read the old one retry
+func (r *productRepository) Update(ctx context.Context, tx *sql.Tx, product *models.Product) error {
+	query := `
+		UPDATE products
+		SET name = $1, sku = $2, barcode = $3, price = $4, stock = $5, version = version + 1, updated_at = NOW()
+		WHERE id = $6
+		RETURNING version, updated_at`
+
+	err := tx.QueryRowContext(ctx, query,
+		product.Name,
+		product.SKU,
+		product.Barcode,
+		product.Price,
+		product.Stock,
+		product.ID,
+	).Scan(&product.Version, &product.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return errors.ErrProductNotFound
+	}
+	if err != nil {
+		if dupErr := productUniqueViolation(err); dupErr != nil {
+			return dupErr
+		}
+		return fmt.Errorf("failed to update product: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes a product with its inventory movements, and a bundle with
+// its component list. A product that has orders, is a bundle component or
+// is in a sales event is kept and ErrProductInUse returned.
+func (r *productRepository) Delete(ctx context.Context, tx *sql.Tx, id int) error {
+	for _, query := range []string{
+		"DELETE FROM bundle_components WHERE bundle_id = $1",
+		"DELETE FROM inventory_movements WHERE product_id = $1",
+	} {
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			return fmt.Errorf("failed to delete product: %w", err)
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM products WHERE id = $1", id)
+	if err != nil {
+		var pqErr *pq.Error
+		if stderrors.As(err, &pqErr) && pqErr.Code == pgForeignKeyViolation {
+			return errors.ErrProductInUse
+		}
+		return fmt.Errorf("failed to delete product: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.ErrProductNotFound
+	}
+
+	return nil
+}
+
+// productUniqueViolation maps a unique constraint violation on products to
+// the matching conflict error, or returns nil for any other error
+func productUniqueViolation(err error) error {
+	var pqErr *pq.Error
+	if !stderrors.As(err, &pqErr) || pqErr.Code != pgUniqueViolation {
+		return nil
+	}
+
+	switch pqErr.Constraint {
+	case "idx_products_sku":
+		return errors.ErrDuplicateSKU
+	case "idx_products_barcode":
+		return errors.ErrDuplicateBarcode
+	default:
+		return nil
+	}
+}
+
+// bundleRepository implements BundleRepository
+type bundleRepository struct {
+	db *sql.DB
+}
+
+// NewBundleRepository creates a new bundle repository
+func NewBundleRepository(db *sql.DB) BundleRepository {
+	return &bundleRepository{db: db}
+}
+
+// bundleQuery selects bundles with the number of each its components'
+// stock covers; callers add the WHERE clause, grouping and paging
+const bundleQuery = `
+	SELECT b.id, b.name, b.sku, b.price, b.created_at, b.updated_at,
+	       MIN(p.stock / c.quantity)
+	FROM products b
+	JOIN bundle_components c ON c.bundle_id = b.id
+	JOIN products p ON p.id = c.product_id`
+
+// GetByID reads a bundle with its components
+func (r *bundleRepository) GetByID(ctx context.Context, id int) (*models.Bundle, error) {
+	bundles, err := r.list(ctx, bundleQuery+" WHERE b.id = $1 GROUP BY b.id", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(bundles) == 0 {
+		return nil, errors.ErrBundleNotFound
+	}
+	return bundles[0], nil
+}
+
+// List returns a page of bundles with their components, by ID
+func (r *bundleRepository) List(ctx context.Context, limit, offset int) ([]*models.Bundle, error) {
+	return r.list(ctx, bundleQuery+" GROUP BY b.id ORDER BY b.id LIMIT $1 OFFSET $2", limit, offset)
+}
+
+func (r *bundleRepository) list(ctx context.Context, query string, args ...interface{}) ([]*models.Bundle, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bundles: %w", err)
+	}
+	defer rows.Close()
+
+	bundles := []*models.Bundle{}
+	byID := make(map[int]*models.Bundle)
+	var ids []int
+	for rows.Next() {
+		var bundle models.Bundle
+		err := rows.Scan(
+			&bundle.ID,
+			&bundle.Name,
+			&bundle.SKU,
+			&bundle.Price,
+			&bundle.CreatedAt,
+			&bundle.UpdatedAt,
+			&bundle.Available,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan bundle: %w", err)
+		}
+		bundles = append(bundles, &bundle)
+		byID[bundle.ID] = &bundle
+		ids = append(ids, bundle.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate bundle rows: %w", err)
+	}
+	if len(ids) == 0 {
+		return bundles, nil
+	}
+
+	components, err := r.db.QueryContext(ctx, `
+		SELECT bundle_id, product_id, quantity
+		FROM bundle_components
+		WHERE bundle_id = ANY($1)
+		ORDER BY bundle_id, product_id`, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bundle components: %w", err)
+	}
+	defer components.Close()
+
+	for components.Next() {
+		var bundleID int
+		var component models.BundleComponent
+		if err := components.Scan(&bundleID, &component.ProductID, &component.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan bundle component: %w", err)
+		}
+		byID[bundleID].Components = append(byID[bundleID].Components, &component)
+	}
+	if err := components.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate bundle component rows: %w", err)
+	}
+
+	return bundles, nil
+}
+
+// ListBundleIDs returns which of productIDs are bundles
+func (r *bundleRepository) ListBundleIDs(ctx context.Context, productIDs []int) ([]int, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT DISTINCT bundle_id FROM bundle_components WHERE bundle_id = ANY($1)", pq.Array(productIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bundle IDs: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan bundle ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate bundle ID rows: %w", err)
+	}
+
+	return ids, nil
+}
+
+// GetComponents returns a bundle's components by product ID, the order
+// their rows are locked in when it is ordered; a product that is not a
+// bundle has none. A nil tx reads outside a transaction.
+func (r *bundleRepository) GetComponents(ctx context.Context, tx *sql.Tx, bundleID int) ([]*models.BundleComponent, error) {
+	query := `
+		SELECT product_id, quantity
+		FROM bundle_components
+		WHERE bundle_id = $1
+		ORDER BY product_id`
+
+	var rows *sql.Rows
+	var err error
+	if tx != nil {
+		rows, err = tx.QueryContext(ctx, query, bundleID)
+	} else {
+		rows, err = r.db.QueryContext(ctx, query, bundleID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bundle components: %w", err)
+	}
+	defer rows.Close()
+
+	var components []*models.BundleComponent
+	for rows.Next() {
+		var component models.BundleComponent
+		if err := rows.Scan(&component.ProductID, &component.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan bundle component: %w", err)
+		}
+		components = append(components, &component)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate bundle component rows: %w", err)
+	}
+
+	return components, nil
+}
+
+// Create inserts the bundle's products row, with no stock, and its
+// components
+func (r *bundleRepository) Create(ctx context.Context, tx *sql.Tx, bundle *models.Bundle) error {
+	query := `
+		INSERT INTO products (name, sku, stock, price, version, created_at, updated_at)
+		VALUES ($1, $2, 0, $3, 1, NOW(), NOW())
+		RETURNING id, created_at, updated_at`
+
+	err := tx.QueryRowContext(ctx, query, bundle.Name, bundle.SKU, bundle.Price).Scan(
+		&bundle.ID,
+		&bundle.CreatedAt,
+		&bundle.UpdatedAt,
+	)
+	if err != nil {
+		if dupErr := productUniqueViolation(err); dupErr != nil {
+			return dupErr
+		}
+		return fmt.Errorf("failed to create bundle: %w", err)
+	}
+
+	return r.insertComponents(ctx, tx, bundle)
+}
+
+// Update replaces a bundle's name, price and components. Updating its
+// products row locks it, so orders for the bundle wait for the new
+// components.
+func (r *bundleRepository) Update(ctx context.Context, tx *sql.Tx, bundle *models.Bundle) error {
+	query := `
+		UPDATE products
+		SET name = $1, price = $2, version = version + 1, updated_at = NOW()
+		WHERE id = $3 AND EXISTS (SELECT 1 FROM bundle_components WHERE bundle_id = $3)
+		RETURNING sku, created_at, updated_at`
+
+	err := tx.QueryRowContext(ctx, query, bundle.Name, bundle.Price, bundle.ID).Scan(
+		&bundle.SKU,
+		&bundle.CreatedAt,
+		&bundle.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return errors.ErrBundleNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update bundle: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM bundle_components WHERE bundle_id = $1", bundle.ID); err != nil {
+		return fmt.Errorf("failed to delete bundle components: %w", err)
+	}
+	return r.insertComponents(ctx, tx, bundle)
+}
+
+func (r *bundleRepository) insertComponents(ctx context.Context, tx *sql.Tx, bundle *models.Bundle) error {
+	query := `
+		INSERT INTO bundle_components (bundle_id, product_id, quantity)
+		VALUES ($1, $2, $3)`
+
+	for _, component := range bundle.Components {
+		if _, err := tx.ExecContext(ctx, query, bundle.ID, component.ProductID, component.Quantity); err != nil {
+			return fmt.Errorf("failed to create bundle component: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// salesEventRepository implements SalesEventRepository
+type salesEventRepository struct {
+	db *sql.DB
+}
+
+// NewSalesEventRepository creates a new sales event repository
+func NewSalesEventRepository(db *sql.DB) SalesEventRepository {
+	return &salesEventRepository{db: db}
+}
+
+// salesEventQuery selects sales events with their product IDs; callers add
+// the WHERE clause, grouping and paging
+const salesEventQuery = `
+	SELECT e.id, e.name, e.starts_at, e.ends_at, e.per_buyer_limit, e.created_at, e.updated_at,
+	       ARRAY_AGG(sp.product_id ORDER BY sp.product_id)
+	FROM sales_events e
+	JOIN sales_event_products sp ON sp.event_id = e.id`
+
+// GetByID reads a sales event with its products
+func (r *salesEventRepository) GetByID(ctx context.Context, id int) (*models.SalesEvent, error) {
+	events, err := r.list(ctx, salesEventQuery+" WHERE e.id = $1 GROUP BY e.id", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, errors.ErrSalesEventNotFound
+	}
+	return events[0], nil
+}
+
+// List returns a page of sales events, latest start first
+func (r *salesEventRepository) List(ctx context.Context, limit, offset int) ([]*models.SalesEvent, error) {
+	return r.list(ctx, salesEventQuery+" GROUP BY e.id ORDER BY e.starts_at DESC, e.id DESC LIMIT $1 OFFSET $2", limit, offset)
+}
+
+// ListSchedule returns the events that decide whether their products can
+// be ordered: every event that has not ended, and each product's last
+// ended event. Products are listed only under the events that matter for
+// them.
+func (r *salesEventRepository) ListSchedule(ctx context.Context) ([]*models.SalesEvent, error) {
+	return r.list(ctx, salesEventQuery+`
+		WHERE e.ends_at > NOW() OR e.ends_at = (
+			SELECT MAX(e2.ends_at)
+			FROM sales_events e2
+			JOIN sales_event_products sp2 ON sp2.event_id = e2.id
+			WHERE sp2.product_id = sp.product_id
+		)
+		GROUP BY e.id
+		ORDER BY e.starts_at, e.id`)
+}
+
+func (r *salesEventRepository) list(ctx context.Context, query string, args ...interface{}) ([]*models.SalesEvent, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sales events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []*models.SalesEvent{}
+	for rows.Next() {
+		var event models.SalesEvent
+		var productIDs pq.Int64Array
+		err := rows.Scan(
+			&event.ID,
+			&event.Name,
+			&event.StartsAt,
+			&event.EndsAt,
+			&event.PerBuyerLimit,
+			&event.CreatedAt,
+			&event.UpdatedAt,
+			&productIDs,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sales event: %w", err)
+		}
+		for _, id := range productIDs {
+			event.ProductIDs = append(event.ProductIDs, int(id))
+		}
+		events = append(events, &event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate sales event rows: %w", err)
+	}
+
+	return events, nil
+}
+
+// Stats returns each of a sales event's products with its orders in the
+// event that are not cancelled, by product ID
+func (r *salesEventRepository) Stats(ctx context.Context, id int) ([]*models.SalesEventProductStats, error) {
+	query := `
+		SELECT sp.product_id,
+		       COUNT(o.id),
+		       COALESCE(SUM(o.quantity), 0),
+		       COUNT(DISTINCT o.buyer_id),
+		       COALESCE(SUM(o.total_cents), 0),
+		       p.stock
+		FROM sales_event_products sp
+		JOIN products p ON p.id = sp.product_id
+		LEFT JOIN orders o ON o.sales_event_id = sp.event_id AND o.product_id = sp.product_id AND o.status <> $2
+		WHERE sp.event_id = $1
+		GROUP BY sp.product_id, p.stock
+		ORDER BY sp.product_id`
+
+	rows, err := r.db.QueryContext(ctx, query, id, models.OrderStatusCancelled)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sales event stats: %w", err)
+	}
+	defer rows.Close()
+
+	stats := []*models.SalesEventProductStats{}
+	for rows.Next() {
+		var s models.SalesEventProductStats
+		if err := rows.Scan(&s.ProductID, &s.Orders, &s.Units, &s.Buyers, &s.RevenueCents, &s.Stock); err != nil {
+			return nil, fmt.Errorf("failed to scan sales event stats: %w", err)
+		}
+		stats = append(stats, &s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate sales event stats rows: %w", err)
+	}
+
+	return stats, nil
+}
+
+// Create inserts a sales event and its products. It fails with
+// ErrSalesEventOverlap if a product is already in an event whose window
+// overlaps this one's; callers lock the products first so two overlapping
+// events cannot both pass the check.
+func (r *salesEventRepository) Create(ctx context.Context, tx *sql.Tx, event *models.SalesEvent) error {
+	var overlap bool
+	err := tx.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1
+			FROM sales_events e
+			JOIN sales_event_products sp ON sp.event_id = e.id
+			WHERE sp.product_id = ANY($1) AND e.starts_at < $3 AND e.ends_at > $2
+		)`, pq.Array(event.ProductIDs), event.StartsAt, event.EndsAt).Scan(&overlap)
+	if err != nil {
+		return fmt.Errorf("failed to check sales event overlap: %w", err)
+	}
+	if overlap {
+		return errors.ErrSalesEventOverlap
+	}
+
+	query := `
+		INSERT INTO sales_events (name, starts_at, ends_at, per_buyer_limit, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+		RETURNING id, created_at, updated_at`
+
+	err = tx.QueryRowContext(ctx, query, event.Name, event.StartsAt, event.EndsAt, event.PerBuyerLimit).Scan(
+		&event.ID,
+		&event.CreatedAt,
+		&event.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create sales event: %w", err)
+	}
+
+	for _, productID := range event.ProductIDs {
+		_, err := tx.ExecContext(ctx, "INSERT INTO sales_event_products (event_id, product_id) VALUES ($1, $2)", event.ID, productID)
+		if err != nil {
+			return fmt.Errorf("failed to add sales event product: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Delete removes a sales event; its orders keep their details but no
+// longer point at it
+func (r *salesEventRepository) Delete(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM sales_events WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete sales event: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return errors.ErrSalesEventNotFound
+	}
+
+	return nil
+}
+
+// orderRepository implements OrderRepository
+type orderRepository struct {
+	db    *sql.DB
+	stmts *Statements
+}
+
+// NewOrderRepository creates a new order repository; with nil statements
+// every query runs ad hoc
+func NewOrderRepository(db *sql.DB, stmts *Statements) OrderRepository {
+	return &orderRepository{db: db, stmts: stmts}
+}
+
+func (r *orderRepository) Create(ctx context.Context, tx *sql.Tx, order *models.Order) error {
+	err := r.stmts.queryRow(ctx, r.db, tx, stmtCreateOrder,
+		order.ID,
+		order.ProductID,
+		order.BuyerID,
+		order.Quantity,
+		order.Status,
+		order.TotalCents,
+		order.ClientReference,
+		order.SalesEventID,
+	).Scan(&order.CreatedAt, &order.UpdatedAt)
+
+	if err != nil {
+		var pqErr *pq.Error
+		if stderrors.As(err, &pqErr) && pqErr.Code == pgUniqueViolation && pqErr.Constraint == "idx_orders_client_reference" {
+			return errors.ErrClientReferenceConflict
+		}
+		return fmt.Errorf("failed to create order: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateStatus moves an order from one status to another. It returns a
+// concurrency error if the order is no longer in the expected status.
+func (r *orderRepository) UpdateStatus(ctx context.Context, tx *sql.Tx, id uuid.UUID, from, to models.OrderStatus) error {
+	result, err := r.stmts.exec(ctx, r.db, tx, stmtUpdateOrderStatus, to, id, from)
+	if err != nil {
+		return fmt.Errorf("failed to update order status: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.NewConcurrencyError("order status was modified by another request")
+	}
+
+	return nil
+}
+
+// AddEvent appends a step to an order's timeline; a nil tx writes it on its
+// own
+func (r *orderRepository) AddEvent(ctx context.Context, tx *sql.Tx, event *models.OrderEvent) error {
+	query := `
+		INSERT INTO order_events (order_id, type, detail)
+		VALUES ($1, $2, $3)
+		RETURNING id, occurred_at`
+
+	var row *sql.Row
+	if tx != nil {
+		row = tx.QueryRowContext(ctx, query, event.OrderID, event.Type, event.Detail)
+	} else {
+		row = r.db.QueryRowContext(ctx, query, event.OrderID, event.Type, event.Detail)
+	}
+	if err := row.Scan(&event.ID, &event.OccurredAt); err != nil {
+		return fmt.Errorf("failed to add order event: %w", err)
+	}
+
+	return nil
+}
+
+// AddComponents records the stock a bundle order took from each component
+func (r *orderRepository) AddComponents(ctx context.Context, tx *sql.Tx, orderID uuid.UUID, components []*models.OrderComponent) error {
+	query := `
+		INSERT INTO order_components (order_id, product_id, quantity)
+		VALUES ($1, $2, $3)`
+
+	for _, component := range components {
+		if _, err := tx.ExecContext(ctx, query, orderID, component.ProductID, component.Quantity); err != nil {
+			return fmt.Errorf("failed to add order component: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ListBackorderedForUpdate locks a product's backordered orders, oldest
+// first, the order in which they are confirmed
+func (r *orderRepository) ListBackorderedForUpdate(ctx context.Context, tx *sql.Tx, productID int) ([]*models.Order, error) {
+	query := `
+		SELECT id, product_id, buyer_id, quantity, status, total_cents, client_reference, created_at, updated_at
+		FROM orders
+		WHERE product_id = $1 AND status = $2
+		ORDER BY created_at, id
+		FOR UPDATE`
+
+	rows, err := tx.QueryContext(ctx, query, productID, models.OrderStatusBackordered)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backordered orders: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []*models.Order
+	for rows.Next() {
+		var order models.Order
+		err := rows.Scan(
+			&order.ID,
+			&order.ProductID,
+			&order.BuyerID,
+			&order.Quantity,
+			&order.Status,
+			&order.TotalCents,
+			&order.ClientReference,
+			&order.CreatedAt,
+			&order.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan backordered order: %w", err)
+		}
+		orders = append(orders, &order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate backordered order rows: %w", err)
+	}
+
+	return orders, nil
+}
+
+// GetByIDForUpdate locks an order, returning it with the stock it took
+// from each component when it is a bundle order
+func (r *orderRepository) GetByIDForUpdate(ctx context.Context, tx *sql.Tx, id uuid.UUID) (*models.Order, error) {
+	query := `
+		SELECT id, product_id, buyer_id, quantity, status, total_cents, client_reference,
+			   sales_event_id, created_at, updated_at
+		FROM orders
+		WHERE id = $1
+		FOR UPDATE`
+
+	var order models.Order
+	err := tx.QueryRowContext(ctx, query, id).Scan(
+		&order.ID,
+		&order.ProductID,
+		&order.BuyerID,
+		&order.Quantity,
+		&order.Status,
+		&order.TotalCents,
+		&order.ClientReference,
+		&order.SalesEventID,
+		&order.CreatedAt,
+		&order.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order for update: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT product_id, quantity
+		FROM order_components
+		WHERE order_id = $1
+		ORDER BY product_id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list order components: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var component models.OrderComponent
+		if err := rows.Scan(&component.ProductID, &component.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan order component: %w", err)
+		}
+		order.Components = append(order.Components, &component)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate order component rows: %w", err)
+	}
+
+	return &order, nil
+}
+
+// BuyerEventQuantity returns the units of a product a buyer holds in
+// orders of a sales event that are not cancelled; a nil tx reads outside a
+// transaction
+func (r *orderRepository) BuyerEventQuantity(ctx context.Context, tx *sql.Tx, eventID int, buyerID string, productID int) (int, error) {
+	query := `
+		SELECT COALESCE(SUM(quantity), 0)
+		FROM orders
+		WHERE sales_event_id = $1 AND product_id = $2 AND buyer_id = $3 AND status <> $4`
+
+	var row *sql.Row
+	if tx != nil {
+		row = tx.QueryRowContext(ctx, query, eventID, productID, buyerID, models.OrderStatusCancelled)
+	} else {
+		row = r.db.QueryRowContext(ctx, query, eventID, productID, buyerID, models.OrderStatusCancelled)
+	}
+
+	var quantity int
+	if err := row.Scan(&quantity); err != nil {
+		return 0, fmt.Errorf("failed to get buyer event quantity: %w", err)
+	}
+
+	return quantity, nil
+}
+
+// ListEvents returns an order's timeline, oldest first
+func (r *orderRepository) ListEvents(ctx context.Context, orderID uuid.UUID) ([]*models.OrderEvent, error) {
+	query := `
+		SELECT id, order_id, type, detail, occurred_at
+		FROM order_events
+		WHERE order_id = $1
+		ORDER BY occurred_at, id`
+
+	rows, err := r.db.QueryContext(ctx, query, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list order events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []*models.OrderEvent{}
+	for rows.Next() {
+		var event models.OrderEvent
+		if err := rows.Scan(&event.ID, &event.OrderID, &event.Type, &event.Detail, &event.OccurredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan order event: %w", err)
+		}
+		events = append(events, &event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate order event rows: %w", err)
+	}
+
+	return events, nil
+}
+
+func (r *orderRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Order, error) {
+	return r.getOrder(ctx, "o.id = $1", id)
+}
+
+// GetByClientReference retrieves the order a client placed under its own
+// reference
+func (r *orderRepository) GetByClientReference(ctx context.Context, clientReference string) (*models.Order, error) {
+	return r.getOrder(ctx, "o.client_reference = $1", clientReference)
+}
+
+// GetRecentDuplicate retrieves the newest order that is not cancelled for
+// the same buyer, product and quantity placed within the last within, as
+// measured by the database clock
+func (r *orderRepository) GetRecentDuplicate(ctx context.Context, buyerID string, productID, quantity int, within time.Duration) (*models.Order, error) {
+	return r.getOrder(ctx, `o.buyer_id = $1 AND o.product_id = $2 AND o.quantity = $3
+			AND o.status <> 'CANCELLED' AND o.created_at >= NOW() - $4 * INTERVAL '1 millisecond'
+		ORDER BY o.created_at DESC
+		LIMIT 1`, buyerID, productID, quantity, within.Milliseconds())
+}
+
+// getOrder retrieves the single order matching a condition, with its product
+func (r *orderRepository) getOrder(ctx context.Context, condition string, args ...interface{}) (*models.Order, error) {
+	query := `
+		SELECT o.id, o.product_id, o.buyer_id, o.quantity, o.status, o.total_cents, o.client_reference,
+			   o.sales_event_id, o.created_at, o.updated_at,
+			   p.id, p.name, p.sku, p.barcode, p.stock, p.price, p.version, p.created_at, p.updated_at
+		FROM orders o
+		JOIN products p ON o.product_id = p.id
+		WHERE ` + condition
+
+	var order models.Order
+	var product models.Product
+
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(
+		&order.ID,
+		&order.ProductID,
+		&order.BuyerID,
+		&order.Quantity,
+		&order.Status,
+		&order.TotalCents,
+		&order.ClientReference,
+		&order.SalesEventID,
+		&order.CreatedAt,
+		&order.UpdatedAt,
+		&product.ID,
+		&product.Name,
+		&product.SKU,
+		&product.Barcode,
+		&product.Stock,
+		&product.Price,
+		&product.Version,
+		&product.CreatedAt,
+		&product.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	order.Product = &product
+
+	components, err := r.listComponents(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	order.Components = components
+	return &order, nil
+}
+
+// listComponents returns the stock a bundle order took from each component
+func (r *orderRepository) listComponents(ctx context.Context, orderID uuid.UUID) ([]*models.OrderComponent, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT product_id, quantity
+		FROM order_components
+		WHERE order_id = $1
+		ORDER BY product_id`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list order components: %w", err)
+	}
+	defer rows.Close()
+
+	var components []*models.OrderComponent
+	for rows.Next() {
+		var component models.OrderComponent
+		if err := rows.Scan(&component.ProductID, &component.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan order component: %w", err)
+		}
+		components = append(components, &component)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate order component rows: %w", err)
+	}
+
+	return components, nil
+}
+
+func (r *orderRepository) List(ctx context.Context, limit, offset int) ([]*models.Order, error) {
+	var orders []*models.Order
+	err := r.Stream(ctx, limit, offset, func(order *models.Order) error {
+		row := *order
+		orders = append(orders, &row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// Stream calls fn for each order, newest first, while reading them from a
+// single cursor. The order passed to fn is reused for the next row, so fn
+// must copy it to keep it. A non-positive limit streams every order.
+func (r *orderRepository) Stream(ctx context.Context, limit, offset int, fn func(*models.Order) error) error {
+	page, args := limitOffset(nil, limit, offset)
+	return r.stream(ctx, "", page, args, fn)
+}
+
+// ListAfter lists up to limit orders that follow after in the order Stream
+// returns them. The keyset condition uses the (created_at, id) index, so a
+// deep page costs the same as the first.
+func (r *orderRepository) ListAfter(ctx context.Context, after *models.OrderCursor, limit int) ([]*models.Order, error) {
+	var orders []*models.Order
+	err := r.stream(ctx, "WHERE (created_at, id) < ($1, $2)", "LIMIT $3", []interface{}{after.CreatedAt, after.ID, limit}, func(order *models.Order) error {
+		row := *order
+		orders = append(orders, &row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// Count returns the total number of orders
+func (r *orderRepository) Count(ctx context.Context) (int, error) {
+	var count int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM orders").Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count orders: %w", err)
+	}
+	return count, nil
+}
+
+// stream calls fn for each order matching where, newest first, with ties on
+// created_at broken by ID so offset and keyset pages agree
+func (r *orderRepository) stream(ctx context.Context, where, page string, args []interface{}, fn func(*models.Order) error) error {
+	query := `
+		SELECT id, product_id, buyer_id, quantity, status, total_cents, client_reference, created_at, updated_at
+		FROM orders
+		` + where + `
+		ORDER BY created_at DESC, id DESC
+		` + page
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to list orders: %w", err)
+	}
+	defer rows.Close()
+
+	var order models.Order
+	for rows.Next() {
+		err := rows.Scan(
+			&order.ID,
+			&order.ProductID,
+			&order.BuyerID,
+			&order.Quantity,
+			&order.Status,
+			&order.TotalCents,
+			&order.ClientReference,
+			&order.CreatedAt,
+			&order.UpdatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to scan order: %w", err)
+		}
+		if err := fn(&order); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate order rows: %w", err)
+	}
+
+	return nil
+}
+
+// limitOffset appends LIMIT and OFFSET placeholders for a page to a query's
+// arguments; a non-positive limit leaves the page unbounded
+func limitOffset(args []interface{}, limit, offset int) (string, []interface{}) {
+	var clause string
+	if limit > 0 {
+		args = append(args, limit)
+		clause = fmt.Sprintf("LIMIT $%d ", len(args))
+	}
+
+	args = append(args, offset)
+	return clause + fmt.Sprintf("OFFSET $%d", len(args)), args
+}
+
+// exportWhere builds the WHERE clause and arguments for an order export filter
+func exportWhere(filter *models.OrderExportFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if filter.BuyerID != "" {
+		args = append(args, filter.BuyerID)
+		conditions = append(conditions, fmt.Sprintf("buyer_id = $%d", len(args)))
+	}
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func (r *orderRepository) CountForExport(ctx context.Context, filter *models.OrderExportFilter) (int, error) {
+	where, args := exportWhere(filter)
+	query := "SELECT COUNT(*) FROM orders " + where
+
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count orders for export: %w", err)
+	}
+
+	return count, nil
+}
+
+// ListForExport lists up to limit orders matching filter, oldest first,
+// that follow after; a nil after starts from the first. The keyset
+// condition uses the (created_at, id) index, so the last page of a large
+// export costs the same as the first.
+func (r *orderRepository) ListForExport(ctx context.Context, filter *models.OrderExportFilter, after *models.OrderCursor, limit int) ([]*models.Order, error) {
+	where, args := exportWhere(filter)
+	if after != nil {
+		args = append(args, after.CreatedAt, after.ID)
+		keyset := fmt.Sprintf("(created_at, id) > ($%d, $%d)", len(args)-1, len(args))
+		if where == "" {
+			where = "WHERE " + keyset
+		} else {
+			where += " AND " + keyset
+		}
+	}
+	args = append(args, limit)
+	query := fmt.Sprintf(`
+		SELECT id, product_id, buyer_id, quantity, status, total_cents, created_at, updated_at
+		FROM orders
+		%s
+		ORDER BY created_at, id
+		LIMIT $%d`, where, len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list orders for export: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []*models.Order
+	for rows.Next() {
+		var order models.Order
+		err := rows.Scan(
+			&order.ID,
+			&order.ProductID,
+			&order.BuyerID,
+			&order.Quantity,
+			&order.Status,
+			&order.TotalCents,
+			&order.CreatedAt,
+			&order.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
+		}
+		orders = append(orders, &order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate order rows: %w", err)
+	}
+
+	return orders, nil
+}
+
+func (r *orderRepository) DailySalesByProduct(ctx context.Context, from, to time.Time) ([]*models.ProductDailySales, error) {
+	query := `
+		SELECT product_id, date_trunc('day', created_at) AS day, SUM(quantity)
+		FROM orders
+		WHERE created_at >= $1 AND created_at < $2 AND status = $3
+		GROUP BY product_id, day
+		ORDER BY product_id, day`
+
+	rows, err := r.db.QueryContext(ctx, query, from, to, models.OrderStatusConfirmed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get daily sales: %w", err)
+	}
+	defer rows.Close()
+
+	var sales []*models.ProductDailySales
+	for rows.Next() {
+		var sale models.ProductDailySales
+		if err := rows.Scan(&sale.ProductID, &sale.Date, &sale.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan daily sales: %w", err)
+		}
+		sales = append(sales, &sale)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate daily sales rows: %w", err)
+	}
+
+	return sales, nil
+}
+
+// AnonymizeBuyer replaces up to limit of a buyer's order references with
+// pseudonym, leaving amounts untouched, and returns how many it replaced
+func (r *orderRepository) AnonymizeBuyer(ctx context.Context, buyerID, pseudonym string, limit int) (int, error) {
+	query := `
+		UPDATE orders SET buyer_id = $1, updated_at = NOW()
+		WHERE id IN (SELECT id FROM orders WHERE buyer_id = $2 LIMIT $3 FOR UPDATE)`
+
+	result, err := r.db.ExecContext(ctx, query, pseudonym, buyerID, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to anonymize orders: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get anonymized orders: %w", err)
+	}
+
+	return int(affected), nil
+}
+
+// transactionRepository implements TransactionRepository
+type transactionRepository struct {
+	db *sql.DB
+}
+
+// NewTransactionRepository creates a new transaction repository
+func NewTransactionRepository(db *sql.DB) TransactionRepository {
+	return &transactionRepository{db: db}
+}
+
+// transactionWhere builds the WHERE clause and arguments for a transaction filter
+func transactionWhere(filter *models.TransactionFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.MerchantID != "" {
+		args = append(args, filter.MerchantID)
+		conditions = append(conditions, fmt.Sprintf("merchant_id = $%d", len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		conditions = append(conditions, fmt.Sprintf("paid_at >= $%d", len(args)))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		conditions = append(conditions, fmt.Sprintf("paid_at < $%d", len(args)))
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func (r *transactionRepository) List(ctx context.Context, filter *models.TransactionFilter, limit, offset int) ([]*models.Transaction, error) {
+	var transactions []*models.Transaction
+	err := r.Stream(ctx, filter, limit, offset, func(tx *models.Transaction) error {
+		row := *tx
+		transactions = append(transactions, &row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return transactions, nil
+}
+
+// Stream calls fn for each matching transaction, by ID, while reading them
+// from a single cursor. The transaction passed to fn is reused for the next
+// row, so fn must copy it to keep it. A non-positive limit streams every match.
+func (r *transactionRepository) Stream(ctx context.Context, filter *models.TransactionFilter, limit, offset int, fn func(*models.Transaction) error) error {
+	where, args := transactionWhere(filter)
+	page, args := limitOffset(args, limit, offset)
+	query := fmt.Sprintf(`
+		SELECT id, merchant_id, amount_cents, fee_cents, status, paid_at, created_at, payment_id, order_id
+		FROM transactions
+		%s
+		ORDER BY id
+		%s`, where, page)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to list transactions: %w", err)
+	}
+	defer rows.Close()
+
+	var tx models.Transaction
+	for rows.Next() {
+		err := rows.Scan(
+			&tx.ID,
+			&tx.MerchantID,
+			&tx.AmountCents,
+			&tx.FeeCents,
+			&tx.Status,
+			&tx.PaidAt,
+			&tx.CreatedAt,
+			&tx.PaymentID,
+			&tx.OrderID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to scan transaction: %w", err)
+		}
+		if err := fn(&tx); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate transaction rows: %w", err)
+	}
+
+	return nil
+}
+
+// Sample returns up to n matching transactions picked at random. Postgres
+// keeps only the n rows with the lowest random keys while scanning the
+// matches, so memory stays bounded by n however many rows the filter covers.
+func (r *transactionRepository) Sample(ctx context.Context, filter *models.TransactionFilter, n int) ([]*models.Transaction, error) {
+	where, args := transactionWhere(filter)
+	args = append(args, n)
+	query := fmt.Sprintf(`
+		SELECT id, merchant_id, amount_cents, fee_cents, status, paid_at, created_at, payment_id, order_id
+		FROM transactions
+		%s
+		ORDER BY random()
+		LIMIT $%d`, where, len(args))
+
+	var transactions []*models.Transaction
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sample transactions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var txn models.Transaction
+		err := rows.Scan(
+			&txn.ID,
+			&txn.MerchantID,
+			&txn.AmountCents,
+			&txn.FeeCents,
+			&txn.Status,
+			&txn.PaidAt,
+			&txn.CreatedAt,
+			&txn.PaymentID,
+			&txn.OrderID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transaction: %w", err)
+		}
+		transactions = append(transactions, &txn)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate transaction rows: %w", err)
+	}
+
+	return transactions, nil
+}
+
+// StreamForSettlement calls fn for each completed transaction paid in
+// [from, to), reading only the columns settlement needs: MerchantID,
+// AmountCents, FeeCents, PaidAt and CreatedAt are set and every other field
+// is zero.
+// Rows come in no particular order, which with the covering index lets the
+// scan skip the table and any sort. The transaction passed to fn is reused
+// for the next row.
+func (r *transactionRepository) StreamForSettlement(ctx context.Context, from, to time.Time, fn func(*models.Transaction) error) error {
+	query := `
+		SELECT merchant_id, amount_cents, fee_cents, paid_at, created_at
+		FROM transactions
+		WHERE status = $1 AND paid_at >= $2 AND paid_at < $3`
+
+	rows, err := r.db.QueryContext(ctx, query, models.TransactionStatusCompleted, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to stream transactions for settlement: %w", err)
+	}
+	defer rows.Close()
+
+	var tx models.Transaction
+	for rows.Next() {
+		if err := rows.Scan(&tx.MerchantID, &tx.AmountCents, &tx.FeeCents, &tx.PaidAt, &tx.CreatedAt); err != nil {
+			return fmt.Errorf("failed to scan transaction: %w", err)
+		}
+		if err := fn(&tx); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate transaction rows: %w", err)
+	}
+
+	return nil
+}
+
+func (r *transactionRepository) GetByID(ctx context.Context, id int) (*models.Transaction, error) {
+	query := `
+		SELECT id, merchant_id, amount_cents, fee_cents, status, paid_at, created_at, payment_id, order_id
+		FROM transactions
+		WHERE id = $1`
+
+	return scanTransaction(r.db.QueryRowContext(ctx, query, id))
+}
+
+// GetByPaymentID returns the transaction of a gateway payment, locked for
+// the rest of tx
+func (r *transactionRepository) GetByPaymentID(ctx context.Context, tx *sql.Tx, paymentID string) (*models.Transaction, error) {
+	query := `
+		SELECT id, merchant_id, amount_cents, fee_cents, status, paid_at, created_at, payment_id, order_id
+		FROM transactions
+		WHERE payment_id = $1
+		FOR UPDATE`
+
+	return scanTransaction(tx.QueryRowContext(ctx, query, paymentID))
+}
+
+// scanTransaction reads a single transaction row
+func scanTransaction(row *sql.Row) (*models.Transaction, error) {
+	var tx models.Transaction
+	err := row.Scan(
+		&tx.ID,
+		&tx.MerchantID,
+		&tx.AmountCents,
+		&tx.FeeCents,
+		&tx.Status,
+		&tx.PaidAt,
+		&tx.CreatedAt,
+		&tx.PaymentID,
+		&tx.OrderID,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrTransactionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction: %w", err)
+	}
+
+	return &tx, nil
+}
+
+// CreateForPayment inserts a transaction reported by the payment gateway
+func (r *transactionRepository) CreateForPayment(ctx context.Context, tx *sql.Tx, txn *models.Transaction) error {
+	query := `
+		INSERT INTO transactions (merchant_id, amount_cents, fee_cents, status, paid_at, payment_id, order_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		RETURNING id, created_at`
+
+	err := tx.QueryRowContext(ctx, query,
+		txn.MerchantID,
+		txn.AmountCents,
+		txn.FeeCents,
+		txn.Status,
+		txn.PaidAt,
+		txn.PaymentID,
+		txn.OrderID,
+	).Scan(&txn.ID, &txn.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+
+	return nil
+}
+
+// RecordPaymentEvent records a gateway notification as applied, reporting
+// false if it already was. A concurrent delivery of the same event waits
+// for the first to commit or roll back.
+func (r *transactionRepository) RecordPaymentEvent(ctx context.Context, tx *sql.Tx, event *models.PaymentEvent) (bool, error) {
+	query := `
+		INSERT INTO payment_events (event_id, type, payment_id, received_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (event_id) DO NOTHING`
+
+	result, err := tx.ExecContext(ctx, query, event.ID, event.Type, event.PaymentID)
+	if err != nil {
+		return false, fmt.Errorf("failed to record payment event: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// UpdateStatus moves a transaction from one status to another, failing with
+// a concurrency error if its status changed in the meantime
+func (r *transactionRepository) UpdateStatus(ctx context.Context, tx *sql.Tx, id int, from, to models.TransactionStatus) error {
+	query := `UPDATE transactions SET status = $1 WHERE id = $2 AND status = $3`
+
+	result, err := tx.ExecContext(ctx, query, to, id, from)
+	if err != nil {
+		return fmt.Errorf("failed to update transaction status: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.NewConcurrencyError("transaction status was changed by another request")
+	}
+
+	return nil
 }
 
 func (r *transactionRepository) GetTotalCount(ctx context.Context, from, to time.Time) (int, error) {
 	query := `
-		SELECT COUNT(*)
+		SELECT COUNT(*)
+		FROM transactions
+		WHERE paid_at >= $1 AND paid_at < $2 AND status = 'COMPLETED'`
+
+	var count int
+	err := r.db.QueryRowContext(ctx, query, from, to).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get transaction count: %w", err)
+	}
+
+	return count, nil
+}
+
+func (r *transactionRepository) Create(ctx context.Context, tx *models.Transaction) error {
+	query := `
+		INSERT INTO transactions (merchant_id, amount_cents, fee_cents, status, paid_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		RETURNING id, created_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		tx.MerchantID,
+		tx.AmountCents,
+		tx.FeeCents,
+		tx.Status,
+		tx.PaidAt,
+	).Scan(&tx.ID, &tx.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Truncate removes every transaction and restarts their IDs, for resetting
+// test environments before seeding
+func (r *transactionRepository) Truncate(ctx context.Context) error {
+	if _, err := r.db.ExecContext(ctx, `TRUNCATE transactions RESTART IDENTITY`); err != nil {
+		return fmt.Errorf("failed to truncate transactions: %w", err)
+	}
+	return nil
+}
+
+func (r *transactionRepository) BulkCreate(ctx context.Context, transactions []*models.Transaction) error {
+	if len(transactions) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO transactions (merchant_id, amount_cents, fee_cents, status, paid_at, created_at)
+		VALUES `
+
+	args := make([]interface{}, 0, len(transactions)*5)
+	placeholders := make([]string, 0, len(transactions))
+
+	for i, tx := range transactions {
+		placeholderGroup := fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, NOW())",
+			i*5+1, i*5+2, i*5+3, i*5+4, i*5+5)
+		placeholders = append(placeholders, placeholderGroup)
+
+		args = append(args,
+			tx.MerchantID,
+			tx.AmountCents,
+			tx.FeeCents,
+			tx.Status,
+			tx.PaidAt,
+		)
+	}
+
+	query += strings.Join(placeholders, ",")
+
+	_, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to bulk create transactions: %w", err)
+	}
+
+	return nil
+}
+
+func (r *transactionRepository) SummarizeByMerchant(ctx context.Context, merchantID string, from, to time.Time) (*models.MerchantTransactionSummary, error) {
+	query := `
+		SELECT
+			COALESCE(SUM(amount_cents) FILTER (WHERE status = 'COMPLETED'), 0),
+			COALESCE(SUM(fee_cents) FILTER (WHERE status = 'COMPLETED'), 0),
+			COUNT(*) FILTER (WHERE status = 'COMPLETED'),
+			COUNT(*) FILTER (WHERE status = 'PENDING'),
+			COUNT(*) FILTER (WHERE status = 'FAILED')
+		FROM transactions
+		WHERE merchant_id = $1 AND paid_at >= $2 AND paid_at < $3`
+
+	var summary models.MerchantTransactionSummary
+	err := r.db.QueryRowContext(ctx, query, merchantID, from, to).Scan(
+		&summary.GrossCents,
+		&summary.FeeCents,
+		&summary.CompletedCount,
+		&summary.PendingCount,
+		&summary.FailedCount,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize merchant transactions: %w", err)
+	}
+
+	summary.NetCents = summary.GrossCents - summary.FeeCents
+	return &summary, nil
+}
+
+func (r *transactionRepository) SummarizeUnsettledByMerchant(ctx context.Context, merchantID string, from, to time.Time) (*models.MerchantUnsettledSummary, error) {
+	query := `
+		SELECT COALESCE(SUM(t.amount_cents - t.fee_cents), 0), COUNT(*)
+		FROM transactions t
+		WHERE t.merchant_id = $1 AND t.paid_at >= $2 AND t.paid_at < $3 AND t.status = 'COMPLETED'
+		  AND NOT EXISTS (
+			SELECT 1 FROM settlements s
+			WHERE s.merchant_id = t.merchant_id
+			  AND s.date = (t.paid_at AT TIME ZONE 'UTC')::date
+			  AND s.superseded_by IS NULL
+		  )`
+
+	var summary models.MerchantUnsettledSummary
+	err := r.db.QueryRowContext(ctx, query, merchantID, from, to).Scan(&summary.NetCents, &summary.TxnCount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize unsettled merchant transactions: %w", err)
+	}
+
+	return &summary, nil
+}
+
+// AggregateByMerchantAndDay totals completed transactions per merchant and
+// UTC paid day over [from, to) in the database, without the job's batching.
+// An empty merchantID totals every merchant, and transactions recorded after
+// cutoffAt are left out unless it is nil.
+func (r *transactionRepository) AggregateByMerchantAndDay(ctx context.Context, merchantID string, from, to time.Time, cutoffAt *time.Time) ([]*models.Settlement, error) {
+	args := []interface{}{from, to}
+	conditions := []string{"paid_at >= $1", "paid_at < $2", "status = 'COMPLETED'"}
+	if merchantID != "" {
+		args = append(args, merchantID)
+		conditions = append(conditions, fmt.Sprintf("merchant_id = $%d", len(args)))
+	}
+	if cutoffAt != nil {
+		args = append(args, *cutoffAt)
+		conditions = append(conditions, fmt.Sprintf("created_at <= $%d", len(args)))
+	}
+
+	query := `
+		SELECT merchant_id, (paid_at AT TIME ZONE 'UTC')::date AS day,
+			   SUM(amount_cents), SUM(fee_cents), COUNT(*)
+		FROM transactions
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		GROUP BY merchant_id, day
+		ORDER BY merchant_id, day`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate transactions: %w", err)
+	}
+	defer rows.Close()
+
+	var settlements []*models.Settlement
+	for rows.Next() {
+		var settlement models.Settlement
+		err := rows.Scan(
+			&settlement.MerchantID,
+			&settlement.Date,
+			&settlement.GrossCents,
+			&settlement.FeeCents,
+			&settlement.TxnCount,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transaction aggregate: %w", err)
+		}
+		settlement.NetCents = settlement.GrossCents - settlement.FeeCents
+		settlements = append(settlements, &settlement)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate transaction aggregate rows: %w", err)
+	}
+
+	return settlements, nil
+}
+
+// LatestActivityDaily returns, for each merchant and UTC payment day in
+// [from, to), when the most recent completed transaction was recorded
+func (r *transactionRepository) LatestActivityDaily(ctx context.Context, from, to time.Time) ([]*models.MerchantDayActivity, error) {
+	query := `
+		SELECT merchant_id, (paid_at AT TIME ZONE 'UTC')::date AS day, MAX(created_at)
+		FROM transactions
+		WHERE paid_at >= $1 AND paid_at < $2 AND status = 'COMPLETED'
+		GROUP BY merchant_id, day
+		ORDER BY merchant_id, day`
+
+	rows, err := r.db.QueryContext(ctx, query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction activity: %w", err)
+	}
+	defer rows.Close()
+
+	var activity []*models.MerchantDayActivity
+	for rows.Next() {
+		var day models.MerchantDayActivity
+		if err := rows.Scan(&day.MerchantID, &day.Date, &day.LastCreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan transaction activity: %w", err)
+		}
+		activity = append(activity, &day)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate transaction activity rows: %w", err)
+	}
+
+	return activity, nil
+}
+
+// ListRecordedAfterPaidDay returns the completed transactions paid in
+// [from, to) that were recorded more than after past the start of their UTC
+// payment day, by payment time
+func (r *transactionRepository) ListRecordedAfterPaidDay(ctx context.Context, from, to time.Time, after time.Duration) ([]*models.Transaction, error) {
+	query := `
+		SELECT id, merchant_id, amount_cents, fee_cents, status, paid_at, created_at, payment_id, order_id
+		FROM transactions
+		WHERE paid_at >= $1 AND paid_at < $2 AND status = 'COMPLETED'
+		  AND created_at > (date_trunc('day', paid_at AT TIME ZONE 'UTC') AT TIME ZONE 'UTC') + $3 * INTERVAL '1 second'
+		ORDER BY paid_at, id`
+
+	rows, err := r.db.QueryContext(ctx, query, from, to, after.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list late transactions: %w", err)
+	}
+	defer rows.Close()
+
+	var transactions []*models.Transaction
+	for rows.Next() {
+		var txn models.Transaction
+		err := rows.Scan(
+			&txn.ID,
+			&txn.MerchantID,
+			&txn.AmountCents,
+			&txn.FeeCents,
+			&txn.Status,
+			&txn.PaidAt,
+			&txn.CreatedAt,
+			&txn.PaymentID,
+			&txn.OrderID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transaction: %w", err)
+		}
+		transactions = append(transactions, &txn)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate transaction rows: %w", err)
+	}
+
+	return transactions, nil
+}
+
+// settlementRepository implements SettlementRepository
+type settlementRepository struct {
+	db *sql.DB
+}
+
+// NewSettlementRepository creates a new settlement repository
+func NewSettlementRepository(db *sql.DB) SettlementRepository {
+	return &settlementRepository{db: db}
+}
+
+// Create inserts a new immutable settlement version, superseding the
+// current version for the same merchant and date
+func (r *settlementRepository) Create(ctx context.Context, tx *sql.Tx, settlement *models.Settlement) error {
+	supersedeQuery := `
+		UPDATE settlements
+		SET superseded_by = $1, updated_at = NOW()
+		WHERE merchant_id = $2 AND date = $3 AND superseded_by IS NULL AND unique_run_id <> $1`
+
+	if _, err := tx.ExecContext(ctx, supersedeQuery, settlement.UniqueRunID, settlement.MerchantID, settlement.Date); err != nil {
+		return fmt.Errorf("failed to supersede settlement: %w", err)
+	}
+
+	query := `
+		INSERT INTO settlements (merchant_id, date, gross_cents, fee_cents, net_cents, txn_count, generated_at, unique_run_id, cutoff_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW(), NOW())
+		RETURNING id, created_at, updated_at`
+
+	err := tx.QueryRowContext(ctx, query,
+		settlement.MerchantID,
+		settlement.Date,
+		settlement.GrossCents,
+		settlement.FeeCents,
+		settlement.NetCents,
+		settlement.TxnCount,
+		settlement.GeneratedAt,
+		settlement.UniqueRunID,
+		settlement.CutoffAt,
+	).Scan(&settlement.ID, &settlement.CreatedAt, &settlement.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create settlement: %w", err)
+	}
+
+	return nil
+}
+
+// settlementBatchSize is the number of settlements CreateBatch writes per
+// statement
+const settlementBatchSize = 5000
+
+// CreateBatch creates settlements like Create, settlementBatchSize rows per
+// round trip: one statement supersedes the current rows they replace and a
+// second inserts them. The two can't share a statement, since the insert
+// would still see the superseded rows in the current-settlement index.
+func (r *settlementRepository) CreateBatch(ctx context.Context, tx *sql.Tx, settlements []*models.Settlement) error {
+	for start := 0; start < len(settlements); start += settlementBatchSize {
+		end := start + settlementBatchSize
+		if end > len(settlements) {
+			end = len(settlements)
+		}
+		if err := r.createChunk(ctx, tx, settlements[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createChunk writes one CreateBatch statement pair
+func (r *settlementRepository) createChunk(ctx context.Context, tx *sql.Tx, settlements []*models.Settlement) error {
+	n := len(settlements)
+	merchantIDs := make([]string, n)
+	dates := make([]string, n)
+	gross := make([]int64, n)
+	fees := make([]int64, n)
+	net := make([]int64, n)
+	counts := make([]int64, n)
+	generatedAt := make([]string, n)
+	runIDs := make([]string, n)
+	cutoffs := make([]sql.NullString, n)
+	for i, settlement := range settlements {
+		merchantIDs[i] = settlement.MerchantID
+		dates[i] = settlement.Date.Format("2006-01-02")
+		gross[i] = int64(settlement.GrossCents)
+		fees[i] = int64(settlement.FeeCents)
+		net[i] = int64(settlement.NetCents)
+		counts[i] = int64(settlement.TxnCount)
+		generatedAt[i] = settlement.GeneratedAt.Format(time.RFC3339Nano)
+		runIDs[i] = settlement.UniqueRunID.String()
+		if settlement.CutoffAt != nil {
+			cutoffs[i] = sql.NullString{String: settlement.CutoffAt.Format(time.RFC3339Nano), Valid: true}
+		}
+	}
+
+	supersedeQuery := `
+		UPDATE settlements s
+		SET superseded_by = input.unique_run_id, updated_at = NOW()
+		FROM unnest($1::text[], $2::date[], $3::uuid[]) AS input(merchant_id, date, unique_run_id)
+		WHERE s.merchant_id = input.merchant_id AND s.date = input.date
+			AND s.superseded_by IS NULL AND s.unique_run_id <> input.unique_run_id`
+
+	if _, err := tx.ExecContext(ctx, supersedeQuery, pq.Array(merchantIDs), pq.Array(dates), pq.Array(runIDs)); err != nil {
+		return fmt.Errorf("failed to supersede settlements: %w", err)
+	}
+
+	query := `
+		INSERT INTO settlements (merchant_id, date, gross_cents, fee_cents, net_cents, txn_count, generated_at, unique_run_id, cutoff_at, created_at, updated_at)
+		SELECT merchant_id, date, gross_cents, fee_cents, net_cents, txn_count, generated_at, unique_run_id, cutoff_at, NOW(), NOW()
+		FROM unnest($1::text[], $2::date[], $3::int[], $4::int[], $5::int[], $6::int[], $7::timestamptz[], $8::uuid[], $9::timestamptz[])
+			AS input(merchant_id, date, gross_cents, fee_cents, net_cents, txn_count, generated_at, unique_run_id, cutoff_at)
+		RETURNING id, merchant_id, date, unique_run_id, created_at, updated_at`
+
+	rows, err := tx.QueryContext(ctx, query,
+		pq.Array(merchantIDs),
+		pq.Array(dates),
+		pq.Array(gross),
+		pq.Array(fees),
+		pq.Array(net),
+		pq.Array(counts),
+		pq.Array(generatedAt),
+		pq.Array(runIDs),
+		pq.Array(cutoffs),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create settlements: %w", err)
+	}
+	defer rows.Close()
+
+	// Match the generated columns back by their unique key, as RETURNING
+	// order isn't guaranteed
+	byKey := make(map[string]*models.Settlement, n)
+	for i, settlement := range settlements {
+		byKey[merchantIDs[i]+"|"+dates[i]+"|"+runIDs[i]] = settlement
+	}
+
+	for rows.Next() {
+		var (
+			id                   int
+			merchantID           string
+			date                 time.Time
+			runID                uuid.UUID
+			createdAt, updatedAt time.Time
+		)
+		if err := rows.Scan(&id, &merchantID, &date, &runID, &createdAt, &updatedAt); err != nil {
+			return fmt.Errorf("failed to scan settlement: %w", err)
+		}
+		if settlement, ok := byKey[merchantID+"|"+date.Format("2006-01-02")+"|"+runID.String()]; ok {
+			settlement.ID = id
+			settlement.CreatedAt = createdAt
+			settlement.UpdatedAt = updatedAt
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate settlement rows: %w", err)
+	}
+
+	return nil
+}
+
+func (r *settlementRepository) GetByMerchantAndDate(ctx context.Context, merchantID string, date time.Time) (*models.Settlement, error) {
+	query := `
+		SELECT id, merchant_id, date, gross_cents, fee_cents, net_cents, txn_count, generated_at, unique_run_id, superseded_by, stale_since, stale_reason, cutoff_at, created_at, updated_at
+		FROM settlements
+		WHERE merchant_id = $1 AND date = $2 AND superseded_by IS NULL`
+
+	var settlement models.Settlement
+	err := r.db.QueryRowContext(ctx, query, merchantID, date).Scan(
+		&settlement.ID,
+		&settlement.MerchantID,
+		&settlement.Date,
+		&settlement.GrossCents,
+		&settlement.FeeCents,
+		&settlement.NetCents,
+		&settlement.TxnCount,
+		&settlement.GeneratedAt,
+		&settlement.UniqueRunID,
+		&settlement.SupersededBy,
+		&settlement.StaleSince,
+		&settlement.StaleReason,
+		&settlement.CutoffAt,
+		&settlement.CreatedAt,
+		&settlement.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil // Settlement not found is not an error in this case
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get settlement: %w", err)
+	}
+
+	return &settlement, nil
+}
+
+func (r *settlementRepository) ListByRun(ctx context.Context, runID uuid.UUID) ([]*models.Settlement, error) {
+	return r.ListByRuns(ctx, []uuid.UUID{runID})
+}
+
+// ListByRuns lists the settlements of several runs with one query, by run,
+// merchant and date
+func (r *settlementRepository) ListByRuns(ctx context.Context, runIDs []uuid.UUID) ([]*models.Settlement, error) {
+	if len(runIDs) == 0 {
+		return nil, nil
+	}
+
+	query := `
+		SELECT id, merchant_id, date, gross_cents, fee_cents, net_cents, txn_count, generated_at, unique_run_id, superseded_by, stale_since, stale_reason, cutoff_at, created_at, updated_at
+		FROM settlements
+		WHERE unique_run_id = ANY($1::uuid[])
+		ORDER BY unique_run_id, merchant_id, date`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(uuidStrings(runIDs)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list settlements by run: %w", err)
+	}
+	defer rows.Close()
+
+	var settlements []*models.Settlement
+	for rows.Next() {
+		var settlement models.Settlement
+		err := rows.Scan(
+			&settlement.ID,
+			&settlement.MerchantID,
+			&settlement.Date,
+			&settlement.GrossCents,
+			&settlement.FeeCents,
+			&settlement.NetCents,
+			&settlement.TxnCount,
+			&settlement.GeneratedAt,
+			&settlement.UniqueRunID,
+			&settlement.SupersededBy,
+			&settlement.StaleSince,
+			&settlement.StaleReason,
+			&settlement.CutoffAt,
+			&settlement.CreatedAt,
+			&settlement.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan settlement: %w", err)
+		}
+		settlements = append(settlements, &settlement)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate settlement rows: %w", err)
+	}
+
+	return settlements, nil
+}
+
+// SupersedeRange marks current settlements dated in [from, to) that a run did
+// not reproduce as superseded by it, e.g. a day whose only transaction was
+// refunded. The merchant-days of keep stay current.
+func (r *settlementRepository) SupersedeRange(ctx context.Context, tx *sql.Tx, runID uuid.UUID, from, to time.Time, keep []*models.Settlement) error {
+	keepMerchants := make([]string, len(keep))
+	keepDates := make([]string, len(keep))
+	for i, settlement := range keep {
+		keepMerchants[i] = settlement.MerchantID
+		keepDates[i] = settlement.Date.Format("2006-01-02")
+	}
+
+	query := `
+		UPDATE settlements
+		SET superseded_by = $1, updated_at = NOW()
+		WHERE date >= $2 AND date < $3 AND superseded_by IS NULL AND unique_run_id <> $1
+			AND (merchant_id, date) NOT IN (SELECT * FROM unnest($4::text[], $5::date[]))`
+
+	if _, err := tx.ExecContext(ctx, query, runID, from, to, pq.Array(keepMerchants), pq.Array(keepDates)); err != nil {
+		return fmt.Errorf("failed to supersede settlements: %w", err)
+	}
+
+	return nil
+}
+
+// Supersede marks the current settlement for a merchant and date as
+// superseded by a run that found nothing left to settle for it
+func (r *settlementRepository) Supersede(ctx context.Context, tx *sql.Tx, runID uuid.UUID, merchantID string, date time.Time) error {
+	query := `
+		UPDATE settlements
+		SET superseded_by = $1, updated_at = NOW()
+		WHERE merchant_id = $2 AND date = $3 AND superseded_by IS NULL AND unique_run_id <> $1`
+
+	if _, err := tx.ExecContext(ctx, query, runID, merchantID, date); err != nil {
+		return fmt.Errorf("failed to supersede settlement: %w", err)
+	}
+
+	return nil
+}
+
+// MarkStale flags the current settlement for a merchant and date as needing
+// re-settlement. It returns nil if the day has not been settled yet. A
+// settlement that is already stale keeps its original timestamp and reason.
+func (r *settlementRepository) MarkStale(ctx context.Context, tx *sql.Tx, merchantID string, date time.Time, reason string) (*models.Settlement, error) {
+	query := `
+		UPDATE settlements
+		SET stale_since = COALESCE(stale_since, NOW()), stale_reason = COALESCE(stale_reason, $3), updated_at = NOW()
+		WHERE merchant_id = $1 AND date = $2 AND superseded_by IS NULL
+		RETURNING id, merchant_id, date, gross_cents, fee_cents, net_cents, txn_count, generated_at, unique_run_id, superseded_by, stale_since, stale_reason, cutoff_at, created_at, updated_at`
+
+	var settlement models.Settlement
+	err := tx.QueryRowContext(ctx, query, merchantID, date, reason).Scan(
+		&settlement.ID,
+		&settlement.MerchantID,
+		&settlement.Date,
+		&settlement.GrossCents,
+		&settlement.FeeCents,
+		&settlement.NetCents,
+		&settlement.TxnCount,
+		&settlement.GeneratedAt,
+		&settlement.UniqueRunID,
+		&settlement.SupersededBy,
+		&settlement.StaleSince,
+		&settlement.StaleReason,
+		&settlement.CutoffAt,
+		&settlement.CreatedAt,
+		&settlement.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil // Nothing settled for this day yet
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark settlement stale: %w", err)
+	}
+
+	return &settlement, nil
+}
+
+// ListStale returns the current settlements flagged as needing re-settlement,
+// oldest first
+func (r *settlementRepository) ListStale(ctx context.Context, limit, offset int) ([]*models.Settlement, error) {
+	query := `
+		SELECT id, merchant_id, date, gross_cents, fee_cents, net_cents, txn_count, generated_at, unique_run_id, superseded_by, stale_since, stale_reason, cutoff_at, created_at, updated_at
+		FROM settlements
+		WHERE stale_since IS NOT NULL AND superseded_by IS NULL
+		ORDER BY date, merchant_id
+		LIMIT $1 OFFSET $2`
+
+	rows, err := r.db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stale settlements: %w", err)
+	}
+	defer rows.Close()
+
+	var settlements []*models.Settlement
+	for rows.Next() {
+		var settlement models.Settlement
+		err := rows.Scan(
+			&settlement.ID,
+			&settlement.MerchantID,
+			&settlement.Date,
+			&settlement.GrossCents,
+			&settlement.FeeCents,
+			&settlement.NetCents,
+			&settlement.TxnCount,
+			&settlement.GeneratedAt,
+			&settlement.UniqueRunID,
+			&settlement.SupersededBy,
+			&settlement.StaleSince,
+			&settlement.StaleReason,
+			&settlement.CutoffAt,
+			&settlement.CreatedAt,
+			&settlement.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan settlement: %w", err)
+		}
+		settlements = append(settlements, &settlement)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate settlement rows: %w", err)
+	}
+
+	return settlements, nil
+}
+
+// settlementWhere builds the WHERE clause and arguments for a settlement
+// filter; only current (not superseded) settlements match
+func settlementWhere(filter *models.SettlementFilter) (string, []interface{}) {
+	conditions := []string{"superseded_by IS NULL"}
+	var args []interface{}
+
+	if filter.MerchantID != "" {
+		args = append(args, filter.MerchantID)
+		conditions = append(conditions, fmt.Sprintf("merchant_id = $%d", len(args)))
+	}
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		conditions = append(conditions, fmt.Sprintf("date >= $%d", len(args)))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		conditions = append(conditions, fmt.Sprintf("date < $%d", len(args)))
+	}
+
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func (r *settlementRepository) List(ctx context.Context, filter *models.SettlementFilter, limit, offset int) ([]*models.Settlement, error) {
+	var settlements []*models.Settlement
+	err := r.Stream(ctx, filter, limit, offset, func(settlement *models.Settlement) error {
+		row := *settlement
+		settlements = append(settlements, &row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return settlements, nil
+}
+
+// Stream calls fn for each matching current settlement, by date, while
+// reading them from a single cursor. The settlement passed to fn is reused for
+// the next row, so fn must copy it to keep it. A non-positive limit streams
+// every match.
+func (r *settlementRepository) Stream(ctx context.Context, filter *models.SettlementFilter, limit, offset int, fn func(*models.Settlement) error) error {
+	where, args := settlementWhere(filter)
+	page, args := limitOffset(args, limit, offset)
+	query := fmt.Sprintf(`
+		SELECT id, merchant_id, date, gross_cents, fee_cents, net_cents, txn_count, generated_at, unique_run_id, superseded_by, stale_since, stale_reason, cutoff_at, created_at, updated_at
+		FROM settlements
+		%s
+		ORDER BY date, merchant_id
+		%s`, where, page)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to list settlements: %w", err)
+	}
+	defer rows.Close()
+
+	var settlement models.Settlement
+	for rows.Next() {
+		err := rows.Scan(
+			&settlement.ID,
+			&settlement.MerchantID,
+			&settlement.Date,
+			&settlement.GrossCents,
+			&settlement.FeeCents,
+			&settlement.NetCents,
+			&settlement.TxnCount,
+			&settlement.GeneratedAt,
+			&settlement.UniqueRunID,
+			&settlement.SupersededBy,
+			&settlement.StaleSince,
+			&settlement.StaleReason,
+			&settlement.CutoffAt,
+			&settlement.CreatedAt,
+			&settlement.UpdatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to scan settlement: %w", err)
+		}
+		if err := fn(&settlement); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate settlement rows: %w", err)
+	}
+
+	return nil
+}
+
+// ListCurrent returns the current (not superseded) settlements dated in [from, to)
+func (r *settlementRepository) ListCurrent(ctx context.Context, from, to time.Time) ([]*models.Settlement, error) {
+	query := `
+		SELECT id, merchant_id, date, gross_cents, fee_cents, net_cents, txn_count, generated_at, unique_run_id, superseded_by, stale_since, stale_reason, cutoff_at, created_at, updated_at
+		FROM settlements
+		WHERE date >= $1 AND date < $2 AND superseded_by IS NULL
+		ORDER BY merchant_id, date`
+
+	rows, err := r.db.QueryContext(ctx, query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list current settlements: %w", err)
+	}
+	defer rows.Close()
+
+	var settlements []*models.Settlement
+	for rows.Next() {
+		var settlement models.Settlement
+		err := rows.Scan(
+			&settlement.ID,
+			&settlement.MerchantID,
+			&settlement.Date,
+			&settlement.GrossCents,
+			&settlement.FeeCents,
+			&settlement.NetCents,
+			&settlement.TxnCount,
+			&settlement.GeneratedAt,
+			&settlement.UniqueRunID,
+			&settlement.SupersededBy,
+			&settlement.StaleSince,
+			&settlement.StaleReason,
+			&settlement.CutoffAt,
+			&settlement.CreatedAt,
+			&settlement.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan settlement: %w", err)
+		}
+		settlements = append(settlements, &settlement)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate settlement rows: %w", err)
+	}
+
+	return settlements, nil
+}
+
+// ListCurrentByMerchant returns a merchant's current settlements dated in
+// [from, to), oldest first
+func (r *settlementRepository) ListCurrentByMerchant(ctx context.Context, merchantID string, from, to time.Time) ([]*models.Settlement, error) {
+	query := `
+		SELECT id, merchant_id, date, gross_cents, fee_cents, net_cents, txn_count, generated_at, unique_run_id, superseded_by, stale_since, stale_reason, cutoff_at, created_at, updated_at
+		FROM settlements
+		WHERE merchant_id = $1 AND date >= $2 AND date < $3 AND superseded_by IS NULL
+		ORDER BY date`
+
+	rows, err := r.db.QueryContext(ctx, query, merchantID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list merchant settlements: %w", err)
+	}
+	defer rows.Close()
+
+	var settlements []*models.Settlement
+	for rows.Next() {
+		var settlement models.Settlement
+		err := rows.Scan(
+			&settlement.ID,
+			&settlement.MerchantID,
+			&settlement.Date,
+			&settlement.GrossCents,
+			&settlement.FeeCents,
+			&settlement.NetCents,
+			&settlement.TxnCount,
+			&settlement.GeneratedAt,
+			&settlement.UniqueRunID,
+			&settlement.SupersededBy,
+			&settlement.StaleSince,
+			&settlement.StaleReason,
+			&settlement.CutoffAt,
+			&settlement.CreatedAt,
+			&settlement.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan settlement: %w", err)
+		}
+		settlements = append(settlements, &settlement)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate settlement rows: %w", err)
+	}
+
+	return settlements, nil
+}
+
+func (r *settlementRepository) CreateRun(ctx context.Context, tx *sql.Tx, run *models.SettlementRun) error {
+	query := `
+		INSERT INTO settlement_runs (id, job_id, range_from, range_to, settlement_count, skipped, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		RETURNING created_at`
+
+	skipped := run.Skipped
+	if skipped == nil {
+		skipped = []models.SkippedSettlement{}
+	}
+	skippedJSON, err := json.Marshal(skipped)
+	if err != nil {
+		return fmt.Errorf("failed to marshal skipped settlements: %w", err)
+	}
+
+	err = tx.QueryRowContext(ctx, query,
+		run.ID,
+		run.JobID,
+		run.From,
+		run.To,
+		run.SettlementCount,
+		skippedJSON,
+	).Scan(&run.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create settlement run: %w", err)
+	}
+
+	return nil
+}
+
+func (r *settlementRepository) GetRun(ctx context.Context, id uuid.UUID) (*models.SettlementRun, error) {
+	query := `
+		SELECT id, job_id, range_from, range_to, settlement_count, skipped, created_at
+		FROM settlement_runs
+		WHERE id = $1`
+
+	var run models.SettlementRun
+	var skipped []byte
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&run.ID,
+		&run.JobID,
+		&run.From,
+		&run.To,
+		&run.SettlementCount,
+		&skipped,
+		&run.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrSettlementRunNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get settlement run: %w", err)
+	}
+	if err := json.Unmarshal(skipped, &run.Skipped); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal skipped settlements: %w", err)
+	}
+
+	return &run, nil
+}
+
+func (r *settlementRepository) GetLatestRun(ctx context.Context) (*models.SettlementRun, error) {
+	query := `
+		SELECT id, job_id, range_from, range_to, settlement_count, skipped, created_at
+		FROM settlement_runs
+		ORDER BY created_at DESC
+		LIMIT 1`
+
+	var run models.SettlementRun
+	var skipped []byte
+	err := r.db.QueryRowContext(ctx, query).Scan(
+		&run.ID,
+		&run.JobID,
+		&run.From,
+		&run.To,
+		&run.SettlementCount,
+		&skipped,
+		&run.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrSettlementRunNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest settlement run: %w", err)
+	}
+	if err := json.Unmarshal(skipped, &run.Skipped); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal skipped settlements: %w", err)
+	}
+
+	return &run, nil
+}
+
+func (r *settlementRepository) ListRuns(ctx context.Context, limit, offset int) ([]*models.SettlementRun, error) {
+	query := `
+		SELECT id, job_id, range_from, range_to, settlement_count, skipped, created_at
+		FROM settlement_runs
+		ORDER BY created_at DESC
+		LIMIT $1 OFFSET $2`
+
+	rows, err := r.db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list settlement runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []*models.SettlementRun
+	for rows.Next() {
+		var run models.SettlementRun
+		var skipped []byte
+		err := rows.Scan(
+			&run.ID,
+			&run.JobID,
+			&run.From,
+			&run.To,
+			&run.SettlementCount,
+			&skipped,
+			&run.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan settlement run: %w", err)
+		}
+		if err := json.Unmarshal(skipped, &run.Skipped); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal skipped settlements: %w", err)
+		}
+		runs = append(runs, &run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate settlement run rows: %w", err)
+	}
+
+	return runs, nil
+}
+
+func (r *settlementRepository) SummarizeByMerchant(ctx context.Context, merchantID string, from, to time.Time) (*models.MerchantSettlementSummary, error) {
+	query := `
+		SELECT COUNT(*), COALESCE(SUM(gross_cents), 0), COALESCE(SUM(fee_cents), 0),
+			   COALESCE(SUM(net_cents), 0), COALESCE(SUM(txn_count), 0),
+			   MAX(date), MAX(generated_at),
+			   (ARRAY_AGG(unique_run_id ORDER BY generated_at DESC))[1],
+			   (SELECT COALESCE(SUM(amount_cents), 0) FROM settlement_adjustments
+				WHERE merchant_id = $1 AND date >= $2 AND date < $3)
+		FROM settlements
+		WHERE merchant_id = $1 AND date >= $2 AND date < $3 AND superseded_by IS NULL`
+
+	var summary models.MerchantSettlementSummary
+	err := r.db.QueryRowContext(ctx, query, merchantID, from, to).Scan(
+		&summary.SettledDays,
+		&summary.GrossCents,
+		&summary.FeeCents,
+		&summary.NetCents,
+		&summary.TxnCount,
+		&summary.LastSettledDate,
+		&summary.LastGeneratedAt,
+		&summary.LastRunID,
+		&summary.AdjustmentCents,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize merchant settlements: %w", err)
+	}
+
+	return &summary, nil
+}
+
+// CreateAdjustment records an adjustment against a merchant's settlement day
+func (r *settlementRepository) CreateAdjustment(ctx context.Context, adjustment *models.SettlementAdjustment) error {
+	query := `
+		INSERT INTO settlement_adjustments (merchant_id, date, amount_cents, reason_code, note, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		RETURNING id, created_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		adjustment.MerchantID,
+		adjustment.Date,
+		adjustment.AmountCents,
+		adjustment.ReasonCode,
+		adjustment.Note,
+	).Scan(&adjustment.ID, &adjustment.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create settlement adjustment: %w", err)
+	}
+
+	return nil
+}
+
+// ListAdjustments returns the adjustments matching a settlement filter, by
+// merchant, date and the order they were posted in
+func (r *settlementRepository) ListAdjustments(ctx context.Context, filter *models.SettlementFilter) ([]*models.SettlementAdjustment, error) {
+	conditions := []string{"TRUE"}
+	var args []interface{}
+
+	if filter.MerchantID != "" {
+		args = append(args, filter.MerchantID)
+		conditions = append(conditions, fmt.Sprintf("merchant_id = $%d", len(args)))
+	}
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		conditions = append(conditions, fmt.Sprintf("date >= $%d", len(args)))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		conditions = append(conditions, fmt.Sprintf("date < $%d", len(args)))
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, merchant_id, date, amount_cents, reason_code, note, created_at
+		FROM settlement_adjustments
+		WHERE %s
+		ORDER BY merchant_id, date, id`, strings.Join(conditions, " AND "))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list settlement adjustments: %w", err)
+	}
+	defer rows.Close()
+
+	var adjustments []*models.SettlementAdjustment
+	for rows.Next() {
+		var adjustment models.SettlementAdjustment
+		err := rows.Scan(
+			&adjustment.ID,
+			&adjustment.MerchantID,
+			&adjustment.Date,
+			&adjustment.AmountCents,
+			&adjustment.ReasonCode,
+			&adjustment.Note,
+			&adjustment.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan settlement adjustment: %w", err)
+		}
+		adjustments = append(adjustments, &adjustment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate settlement adjustment rows: %w", err)
+	}
+
+	return adjustments, nil
+}
+
+// forecastRepository implements ForecastRepository
+type forecastRepository struct {
+	db *sql.DB
+}
+
+// NewForecastRepository creates a new forecast repository
+func NewForecastRepository(db *sql.DB) ForecastRepository {
+	return &forecastRepository{db: db}
+}
+
+func (r *forecastRepository) CreateRecommendations(ctx context.Context, tx *sql.Tx, recommendations []*models.ReorderRecommendation) error {
+	query := `
+		INSERT INTO reorder_recommendations (job_id, product_id, daily_velocity, current_stock, reorder_point, recommended_quantity, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		RETURNING id, created_at`
+
+	for _, rec := range recommendations {
+		err := tx.QueryRowContext(ctx, query,
+			rec.JobID,
+			rec.ProductID,
+			rec.DailyVelocity,
+			rec.CurrentStock,
+			rec.ReorderPoint,
+			rec.RecommendedQuantity,
+		).Scan(&rec.ID, &rec.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to create reorder recommendation: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (r *forecastRepository) ListRecommendationsByJob(ctx context.Context, jobID uuid.UUID) ([]*models.ReorderRecommendation, error) {
+	query := `
+		SELECT id, job_id, product_id, daily_velocity, current_stock, reorder_point, recommended_quantity, created_at
+		FROM reorder_recommendations
+		WHERE job_id = $1
+		ORDER BY recommended_quantity DESC, product_id`
+
+	rows, err := r.db.QueryContext(ctx, query, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reorder recommendations: %w", err)
+	}
+	defer rows.Close()
+
+	var recommendations []*models.ReorderRecommendation
+	for rows.Next() {
+		var rec models.ReorderRecommendation
+		err := rows.Scan(
+			&rec.ID,
+			&rec.JobID,
+			&rec.ProductID,
+			&rec.DailyVelocity,
+			&rec.CurrentStock,
+			&rec.ReorderPoint,
+			&rec.RecommendedQuantity,
+			&rec.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan reorder recommendation: %w", err)
+		}
+		recommendations = append(recommendations, &rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate reorder recommendation rows: %w", err)
+	}
+
+	return recommendations, nil
+}
+
+// calendarRepository implements CalendarRepository
+type calendarRepository struct {
+	db *sql.DB
+}
+
+// NewCalendarRepository creates a new calendar repository
+func NewCalendarRepository(db *sql.DB) CalendarRepository {
+	return &calendarRepository{db: db}
+}
+
+// ListHolidays returns the holidays of a region, or of every region when
+// region is empty
+func (r *calendarRepository) ListHolidays(ctx context.Context, region string) ([]*models.Holiday, error) {
+	query := `
+		SELECT region, date, name, created_at
+		FROM holidays
+		WHERE $1 = '' OR region = $1
+		ORDER BY region, date`
+
+	rows, err := r.db.QueryContext(ctx, query, region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list holidays: %w", err)
+	}
+	defer rows.Close()
+
+	var holidays []*models.Holiday
+	for rows.Next() {
+		var holiday models.Holiday
+		err := rows.Scan(
+			&holiday.Region,
+			&holiday.Date,
+			&holiday.Name,
+			&holiday.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan holiday: %w", err)
+		}
+		holidays = append(holidays, &holiday)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate holiday rows: %w", err)
+	}
+
+	return holidays, nil
+}
+
+func (r *calendarRepository) CreateHoliday(ctx context.Context, holiday *models.Holiday) error {
+	query := `
+		INSERT INTO holidays (region, date, name, created_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (region, date) DO UPDATE SET name = EXCLUDED.name
+		RETURNING created_at`
+
+	err := r.db.QueryRowContext(ctx, query, holiday.Region, holiday.Date, holiday.Name).Scan(&holiday.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create holiday: %w", err)
+	}
+
+	return nil
+}
+
+func (r *calendarRepository) ListMerchantCalendars(ctx context.Context) ([]*models.MerchantCalendar, error) {
+	query := `SELECT merchant_id, region, updated_at FROM merchant_calendars ORDER BY merchant_id`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list merchant calendars: %w", err)
+	}
+	defer rows.Close()
+
+	var assignments []*models.MerchantCalendar
+	for rows.Next() {
+		var assignment models.MerchantCalendar
+		if err := rows.Scan(&assignment.MerchantID, &assignment.Region, &assignment.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan merchant calendar: %w", err)
+		}
+		assignments = append(assignments, &assignment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate merchant calendar rows: %w", err)
+	}
+
+	return assignments, nil
+}
+
+func (r *calendarRepository) SetMerchantCalendar(ctx context.Context, assignment *models.MerchantCalendar) error {
+	query := `
+		INSERT INTO merchant_calendars (merchant_id, region, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (merchant_id) DO UPDATE SET region = EXCLUDED.region, updated_at = NOW()
+		RETURNING updated_at`
+
+	err := r.db.QueryRowContext(ctx, query, assignment.MerchantID, assignment.Region).Scan(&assignment.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to set merchant calendar: %w", err)
+	}
+
+	return nil
+}
+
+// payoutRepository implements PayoutRepository
+type payoutRepository struct {
+	db *sql.DB
+}
+
+// NewPayoutRepository creates a new payout repository
+func NewPayoutRepository(db *sql.DB) PayoutRepository {
+	return &payoutRepository{db: db}
+}
+
+// bankAccountColumns are the merchant_bank_accounts columns scanned by
+// scanBankAccount, with the unused fields of the other scheme read as empty
+const bankAccountColumns = `merchant_id, scheme, account_name, COALESCE(routing_number, ''), COALESCE(account_number, ''), COALESCE(iban, ''), COALESCE(bic, ''), updated_at`
+
+// payoutColumns are the payouts columns scanned by scanPayout
+const payoutColumns = `id, merchant_id, period_from, period_to, amount_cents, status, approved_at, exported_at, export_job_id, created_at, updated_at`
+
+// scanBankAccount reads a merchant_bank_accounts row
+func scanBankAccount(row interface{ Scan(...interface{}) error }) (*models.MerchantBankAccount, error) {
+	var account models.MerchantBankAccount
+	err := row.Scan(
+		&account.MerchantID,
+		&account.Scheme,
+		&account.AccountName,
+		&account.RoutingNumber,
+		&account.AccountNumber,
+		&account.IBAN,
+		&account.BIC,
+		&account.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// scanPayout reads a payouts row
+func scanPayout(row interface{ Scan(...interface{}) error }) (*models.Payout, error) {
+	var payout models.Payout
+	err := row.Scan(
+		&payout.ID,
+		&payout.MerchantID,
+		&payout.PeriodFrom,
+		&payout.PeriodTo,
+		&payout.AmountCents,
+		&payout.Status,
+		&payout.ApprovedAt,
+		&payout.ExportedAt,
+		&payout.ExportJobID,
+		&payout.CreatedAt,
+		&payout.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &payout, nil
+}
+
+// SetBankAccount creates or replaces a merchant's bank account, clearing
+// the fields the account's scheme doesn't use
+func (r *payoutRepository) SetBankAccount(ctx context.Context, account *models.MerchantBankAccount) error {
+	query := `
+		INSERT INTO merchant_bank_accounts (merchant_id, scheme, account_name, routing_number, account_number, iban, bic, updated_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), NOW())
+		ON CONFLICT (merchant_id) DO UPDATE SET
+			scheme = EXCLUDED.scheme,
+			account_name = EXCLUDED.account_name,
+			routing_number = EXCLUDED.routing_number,
+			account_number = EXCLUDED.account_number,
+			iban = EXCLUDED.iban,
+			bic = EXCLUDED.bic,
+			updated_at = NOW()
+		RETURNING updated_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		account.MerchantID,
+		account.Scheme,
+		account.AccountName,
+		account.RoutingNumber,
+		account.AccountNumber,
+		account.IBAN,
+		account.BIC,
+	).Scan(&account.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to set merchant bank account: %w", err)
+	}
+
+	return nil
+}
+
+func (r *payoutRepository) GetBankAccount(ctx context.Context, merchantID string) (*models.MerchantBankAccount, error) {
+	query := `SELECT ` + bankAccountColumns + ` FROM merchant_bank_accounts WHERE merchant_id = $1`
+
+	account, err := scanBankAccount(r.db.QueryRowContext(ctx, query, merchantID))
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrBankAccountNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get merchant bank account: %w", err)
+	}
+
+	return account, nil
+}
+
+// GetBankAccountForUpdate returns a merchant's bank account, locked for the
+// rest of tx so the merchant's payouts can be checked and created without
+// another request creating one in between
+func (r *payoutRepository) GetBankAccountForUpdate(ctx context.Context, tx *sql.Tx, merchantID string) (*models.MerchantBankAccount, error) {
+	query := `SELECT ` + bankAccountColumns + ` FROM merchant_bank_accounts WHERE merchant_id = $1 FOR UPDATE`
+
+	account, err := scanBankAccount(tx.QueryRowContext(ctx, query, merchantID))
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrBankAccountNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock merchant bank account: %w", err)
+	}
+
+	return account, nil
+}
+
+// Create inserts a pending payout, returning ErrPayoutOverlap if the
+// merchant already has a payout for any day of its period; callers lock
+// the merchant's bank account first so two overlapping payouts can't both
+// be created
+func (r *payoutRepository) Create(ctx context.Context, tx *sql.Tx, payout *models.Payout) error {
+	var overlap bool
+	err := tx.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM payouts
+			WHERE merchant_id = $1 AND period_from <= $3 AND period_to >= $2
+		)`, payout.MerchantID, payout.PeriodFrom, payout.PeriodTo).Scan(&overlap)
+	if err != nil {
+		return fmt.Errorf("failed to check payout overlap: %w", err)
+	}
+	if overlap {
+		return errors.ErrPayoutOverlap
+	}
+
+	query := `
+		INSERT INTO payouts (id, merchant_id, period_from, period_to, amount_cents, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+		RETURNING created_at, updated_at`
+
+	err = tx.QueryRowContext(ctx, query,
+		payout.ID,
+		payout.MerchantID,
+		payout.PeriodFrom,
+		payout.PeriodTo,
+		payout.AmountCents,
+		payout.Status,
+	).Scan(&payout.CreatedAt, &payout.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create payout: %w", err)
+	}
+
+	return nil
+}
+
+func (r *payoutRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Payout, error) {
+	query := `SELECT ` + payoutColumns + ` FROM payouts WHERE id = $1`
+
+	payout, err := scanPayout(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrPayoutNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payout: %w", err)
+	}
+
+	return payout, nil
+}
+
+// List returns payouts newest first, only those with the given status
+// unless it is empty
+func (r *payoutRepository) List(ctx context.Context, status models.PayoutStatus, limit, offset int) ([]*models.Payout, error) {
+	query := `
+		SELECT ` + payoutColumns + `
+		FROM payouts
+		WHERE $1 = '' OR status = $1
+		ORDER BY created_at DESC, id
+		LIMIT $2 OFFSET $3`
+
+	rows, err := r.db.QueryContext(ctx, query, status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list payouts: %w", err)
+	}
+	defer rows.Close()
+
+	payouts := []*models.Payout{}
+	for rows.Next() {
+		payout, err := scanPayout(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan payout: %w", err)
+		}
+		payouts = append(payouts, payout)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate payout rows: %w", err)
+	}
+
+	return payouts, nil
+}
+
+// Approve moves a pending payout to APPROVED, returning ErrPayoutNotPending
+// for a payout in any other status
+func (r *payoutRepository) Approve(ctx context.Context, id uuid.UUID) (*models.Payout, error) {
+	query := `
+		UPDATE payouts
+		SET status = $2, approved_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND status = $3
+		RETURNING ` + payoutColumns
+
+	payout, err := scanPayout(r.db.QueryRowContext(ctx, query, id, models.PayoutStatusApproved, models.PayoutStatusPending))
+	if err == sql.ErrNoRows {
+		if _, err := r.GetByID(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, errors.ErrPayoutNotPending
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to approve payout: %w", err)
+	}
+
+	return payout, nil
+}
+
+// CountApproved counts the approved payouts to accounts of a scheme
+func (r *payoutRepository) CountApproved(ctx context.Context, scheme models.PayoutScheme) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM payouts p
+		JOIN merchant_bank_accounts a ON a.merchant_id = p.merchant_id
+		WHERE p.status = $1 AND a.scheme = $2`
+
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, models.PayoutStatusApproved, scheme).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count approved payouts: %w", err)
+	}
+
+	return count, nil
+}
+
+// ClaimForExport marks the approved payouts to accounts of a scheme as
+// exported by a job and returns them with their accounts, by merchant.
+// Payouts the job already claimed are returned again, so a retried job
+// writes the same file.
+func (r *payoutRepository) ClaimForExport(ctx context.Context, tx *sql.Tx, jobID uuid.UUID, scheme models.PayoutScheme) ([]*models.PayoutExport, error) {
+	query := `
+		WITH claimed AS (
+			UPDATE payouts p
+			SET status = $3, exported_at = COALESCE(p.exported_at, NOW()), export_job_id = $1, updated_at = NOW()
+			FROM merchant_bank_accounts a
+			WHERE a.merchant_id = p.merchant_id
+				AND ((p.status = $4 AND a.scheme = $2) OR p.export_job_id = $1)
+			RETURNING p.id, p.merchant_id, p.period_from, p.period_to, p.amount_cents, p.status, p.approved_at, p.exported_at, p.export_job_id, p.created_at, p.updated_at,
+				a.scheme, a.account_name, COALESCE(a.routing_number, '') AS routing_number, COALESCE(a.account_number, '') AS account_number,
+				COALESCE(a.iban, '') AS iban, COALESCE(a.bic, '') AS bic, a.updated_at AS account_updated_at
+		)
+		SELECT * FROM claimed
+		ORDER BY merchant_id, period_from, id`
+
+	rows, err := tx.QueryContext(ctx, query, jobID, scheme, models.PayoutStatusExported, models.PayoutStatusApproved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim payouts: %w", err)
+	}
+	defer rows.Close()
+
+	var exports []*models.PayoutExport
+	for rows.Next() {
+		var payout models.Payout
+		var account models.MerchantBankAccount
+		err := rows.Scan(
+			&payout.ID,
+			&payout.MerchantID,
+			&payout.PeriodFrom,
+			&payout.PeriodTo,
+			&payout.AmountCents,
+			&payout.Status,
+			&payout.ApprovedAt,
+			&payout.ExportedAt,
+			&payout.ExportJobID,
+			&payout.CreatedAt,
+			&payout.UpdatedAt,
+			&account.Scheme,
+			&account.AccountName,
+			&account.RoutingNumber,
+			&account.AccountNumber,
+			&account.IBAN,
+			&account.BIC,
+			&account.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan claimed payout: %w", err)
+		}
+		account.MerchantID = payout.MerchantID
+		exports = append(exports, &models.PayoutExport{Payout: &payout, Account: &account})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate claimed payout rows: %w", err)
+	}
+
+	return exports, nil
+}
+
+// SumExportedByMerchant totals a merchant's exported payouts whose whole
+// period lies in [from, to)
+func (r *payoutRepository) SumExportedByMerchant(ctx context.Context, merchantID string, from, to time.Time) (int, error) {
+	query := `
+		SELECT COALESCE(SUM(amount_cents), 0)
+		FROM payouts
+		WHERE merchant_id = $1 AND status = $2 AND period_from >= $3 AND period_to < $4`
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, query, merchantID, models.PayoutStatusExported, from, to).Scan(&total); err != nil {
+		return 0, fmt.Errorf("failed to sum exported payouts: %w", err)
+	}
+
+	return total, nil
+}
+
+// jobRepository implements JobRepository
+type jobRepository struct {
+	db *sql.DB
+}
+
+// NewJobRepository creates a new job repository
+func NewJobRepository(db *sql.DB) JobRepository {
+	return &jobRepository{db: db}
+}
+
+func (r *jobRepository) Create(ctx context.Context, job *models.Job) error {
+	milestones := make(pq.Int64Array, 0, len(job.ProgressMilestones))
+	for _, milestone := range job.ProgressMilestones {
+		milestones = append(milestones, int64(milestone))
+	}
+
+	query := `
+		INSERT INTO jobs (id, type, status, progress, processed, total, parameters, created_by, progress_webhook_id, progress_milestones, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW(), NOW())
+		RETURNING created_at, updated_at`
+
+	// Parameters is already JSON; marshalling it again would store a JSON
+	// string that no job can parse back
+	err := r.db.QueryRowContext(ctx, query,
+		job.ID,
+		job.Type,
+		job.Status,
+		job.Progress,
+		job.Processed,
+		job.Total,
+		job.Parameters,
+		job.CreatedBy,
+		job.ProgressWebhookID,
+		milestones,
+	).Scan(&job.CreatedAt, &job.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create job: %w", err)
+	}
+
+	return nil
+}
+
+func (r *jobRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Job, error) {
+	query := `
+		SELECT id, type, status, progress, processed, total, parameters, created_by, attempts, result_path, download_url, error, started_at, completed_at, dead_lettered_at, created_at, updated_at, progress_webhook_id, progress_milestones
+		FROM jobs
+		WHERE id = $1`
+
+	var job models.Job
+	var params string
+	var milestones pq.Int64Array
+
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&job.ID,
+		&job.Type,
+		&job.Status,
+		&job.Progress,
+		&job.Processed,
+		&job.Total,
+		&params,
+		&job.CreatedBy,
+		&job.Attempts,
+		&job.ResultPath,
+		&job.DownloadURL,
+		&job.Error,
+		&job.StartedAt,
+		&job.CompletedAt,
+		&job.DeadLetteredAt,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+		&job.ProgressWebhookID,
+		&milestones,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+
+	job.Parameters = params
+	job.ProgressMilestones = progressMilestones(milestones)
+	return &job, nil
+}
+
+// GetByIDs returns the jobs with the given IDs, skipping any that don't exist
+func (r *jobRepository) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*models.Job, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	query := `
+		SELECT id, type, status, progress, processed, total, parameters, created_by, attempts, result_path, download_url, error, started_at, completed_at, dead_lettered_at, created_at, updated_at, progress_webhook_id, progress_milestones
+		FROM jobs
+		WHERE id = ANY($1::uuid[])`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(uuidStrings(ids)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*models.Job
+	for rows.Next() {
+		var job models.Job
+		var milestones pq.Int64Array
+		err := rows.Scan(
+			&job.ID,
+			&job.Type,
+			&job.Status,
+			&job.Progress,
+			&job.Processed,
+			&job.Total,
+			&job.Parameters,
+			&job.CreatedBy,
+			&job.Attempts,
+			&job.ResultPath,
+			&job.DownloadURL,
+			&job.Error,
+			&job.StartedAt,
+			&job.CompletedAt,
+			&job.DeadLetteredAt,
+			&job.CreatedAt,
+			&job.UpdatedAt,
+			&job.ProgressWebhookID,
+			&milestones,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		job.ProgressMilestones = progressMilestones(milestones)
+		jobs = append(jobs, &job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate job rows: %w", err)
+	}
+
+	return jobs, nil
+}
+
+// uuidStrings formats ids for a uuid[] parameter
+func uuidStrings(ids []uuid.UUID) []string {
+	formatted := make([]string, len(ids))
+	for i, id := range ids {
+		formatted[i] = id.String()
+	}
+	return formatted
+}
+
+// progressMilestones converts the scanned milestones of a job
+func progressMilestones(milestones pq.Int64Array) []int {
+	if len(milestones) == 0 {
+		return nil
+	}
+	converted := make([]int, len(milestones))
+	for i, milestone := range milestones {
+		converted[i] = int(milestone)
+	}
+	return converted
+}
+
+func (r *jobRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status models.JobStatus) error {
+	query := `UPDATE jobs SET status = $1, updated_at = NOW() WHERE id = $2`
+
+	_, err := r.db.ExecContext(ctx, query, status, id)
+	if err != nil {
+		return fmt.Errorf("failed to update job status: %w", err)
+	}
+
+	return nil
+}
+
+func (r *jobRepository) UpdateProgress(ctx context.Context, id uuid.UUID, progress float64, processed int) error {
+	query := `UPDATE jobs SET progress = $1, processed = $2, updated_at = NOW() WHERE id = $3`
+
+	_, err := r.db.ExecContext(ctx, query, progress, processed, id)
+	if err != nil {
+		return fmt.Errorf("failed to update job progress: %w", err)
+	}
+
+	return nil
+}
+
+func (r *jobRepository) UpdateResult(ctx context.Context, id uuid.UUID, resultPath, downloadURL string) error {
+	query := `UPDATE jobs SET result_path = $1, download_url = $2, updated_at = NOW() WHERE id = $3`
+
+	_, err := r.db.ExecContext(ctx, query, resultPath, downloadURL, id)
+	if err != nil {
+		return fmt.Errorf("failed to update job result: %w", err)
+	}
+
+	return nil
+}
+
+func (r *jobRepository) UpdateError(ctx context.Context, id uuid.UUID, errMsg string) error {
+	query := `UPDATE jobs SET error = $1, updated_at = NOW() WHERE id = $2`
+
+	_, err := r.db.ExecContext(ctx, query, errMsg, id)
+	if err != nil {
+		return fmt.Errorf("failed to update job error: %w", err)
+	}
+
+	return nil
+}
+
+// MarkStarted moves a queued job to RUNNING for a new attempt. It reports
+// false, leaving the job alone, when the job is no longer queued, e.g.
+// because it was cancelled while waiting.
+func (r *jobRepository) MarkStarted(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `
+		UPDATE jobs
+		SET status = $1, attempts = attempts + 1, started_at = NOW(), updated_at = NOW()
+		WHERE id = $2 AND status = $3`
+
+	result, err := r.db.ExecContext(ctx, query, models.JobStatusRunning, id, models.JobStatusQueued)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark job as started: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// MarkCompleted completes a running job; a job being cancelled is left for
+// MarkCancelled
+func (r *jobRepository) MarkCompleted(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE jobs SET status = $1, completed_at = NOW(), updated_at = NOW() WHERE id = $2 AND status = $3`
+
+	_, err := r.db.ExecContext(ctx, query, models.JobStatusCompleted, id, models.JobStatusRunning)
+	if err != nil {
+		return fmt.Errorf("failed to mark job as completed: %w", err)
+	}
+
+	return nil
+}
+
+// MarkFailed fails a running job with its error; a job being cancelled is
+// left for MarkCancelled
+func (r *jobRepository) MarkFailed(ctx context.Context, id uuid.UUID, errMsg string) error {
+	query := `UPDATE jobs SET status = $1, error = $2, updated_at = NOW() WHERE id = $3 AND status = $4`
+
+	_, err := r.db.ExecContext(ctx, query, models.JobStatusFailed, errMsg, id, models.JobStatusRunning)
+	if err != nil {
+		return fmt.Errorf("failed to mark job as failed: %w", err)
+	}
+
+	return nil
+}
+
+// Cancel requests cancellation of a job and returns its new status. A
+// queued job has no worker to wait for and is CANCELLED at once; a running
+// one becomes CANCELLING until its worker stops.
+func (r *jobRepository) Cancel(ctx context.Context, id uuid.UUID) (models.JobStatus, error) {
+	query := `
+		UPDATE jobs
+		SET status = CASE WHEN status = $1 THEN $2 ELSE $3 END,
+			completed_at = CASE WHEN status = $1 THEN NOW() ELSE completed_at END,
+			updated_at = NOW()
+		WHERE id = $4 AND status IN ($1, $5)
+		RETURNING status`
+
+	var status models.JobStatus
+	err := r.db.QueryRowContext(ctx, query,
+		models.JobStatusQueued, models.JobStatusCancelled, models.JobStatusCancelling, id, models.JobStatusRunning,
+	).Scan(&status)
+	if err == sql.ErrNoRows {
+		return "", errors.ErrJobAlreadyCancelled
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to cancel job: %w", err)
+	}
+
+	return status, nil
+}
+
+// MarkCancelled settles a CANCELLING job as CANCELLED once its worker has
+// stopped; jobs in any other status are left alone
+func (r *jobRepository) MarkCancelled(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE jobs SET status = $1, completed_at = NOW(), updated_at = NOW() WHERE id = $2 AND status = $3`
+
+	_, err := r.db.ExecContext(ctx, query, models.JobStatusCancelled, id, models.JobStatusCancelling)
+	if err != nil {
+		return fmt.Errorf("failed to mark job as cancelled: %w", err)
+	}
+
+	return nil
+}
+
+// IsCancelled reports whether cancellation of the job has been requested
+func (r *jobRepository) IsCancelled(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `SELECT status FROM jobs WHERE id = $1`
+
+	var status models.JobStatus
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&status)
+	if err == sql.ErrNoRows {
+		return false, errors.ErrJobNotFound
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check job status: %w", err)
+	}
+
+	return status == models.JobStatusCancelling || status == models.JobStatusCancelled, nil
+}
+
+func (r *jobRepository) CountActiveByPrincipal(ctx context.Context, principal string, jobType models.JobType) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM jobs
+		WHERE created_by = $1 AND type = $2 AND status IN ($3, $4, $5)`
+
+	var count int
+	err := r.db.QueryRowContext(ctx, query, principal, jobType, models.JobStatusQueued, models.JobStatusRunning, models.JobStatusCancelling).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count active jobs: %w", err)
+	}
+
+	return count, nil
+}
+
+func (r *jobRepository) MarkRetrying(ctx context.Context, id uuid.UUID, errMsg string) error {
+	query := `UPDATE jobs SET status = $1, error = $2, updated_at = NOW() WHERE id = $3 AND status = $4`
+
+	_, err := r.db.ExecContext(ctx, query, models.JobStatusQueued, errMsg, id, models.JobStatusRunning)
+	if err != nil {
+		return fmt.Errorf("failed to mark job for retry: %w", err)
+	}
+
+	return nil
+}
+
+func (r *jobRepository) MarkDeadLettered(ctx context.Context, id uuid.UUID, errMsg string) error {
+	query := `
+		UPDATE jobs
+		SET status = $1, error = $2, dead_lettered_at = NOW(), updated_at = NOW()
+		WHERE id = $3 AND status = $4`
+
+	_, err := r.db.ExecContext(ctx, query, models.JobStatusDeadLettered, errMsg, id, models.JobStatusRunning)
+	if err != nil {
+		return fmt.Errorf("failed to dead-letter job: %w", err)
+	}
+
+	return nil
+}
+
+// List returns the jobs matching filter, newest first
+func (r *jobRepository) List(ctx context.Context, filter *models.JobFilter, limit, offset int) ([]*models.Job, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if filter.Type != "" {
+		args = append(args, filter.Type)
+		conditions = append(conditions, fmt.Sprintf("type = $%d", len(args)))
+	}
+	var where string
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	page, args := limitOffset(args, limit, offset)
+
+	query := fmt.Sprintf(`
+		SELECT id, type, status, progress, processed, total, parameters, created_by, attempts, result_path, download_url, error, started_at, completed_at, dead_lettered_at, created_at, updated_at, progress_webhook_id, progress_milestones
+		FROM jobs
+		%s
+		ORDER BY created_at DESC, id
+		%s`, where, page)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*models.Job
+	for rows.Next() {
+		var job models.Job
+		var milestones pq.Int64Array
+		err := rows.Scan(
+			&job.ID,
+			&job.Type,
+			&job.Status,
+			&job.Progress,
+			&job.Processed,
+			&job.Total,
+			&job.Parameters,
+			&job.CreatedBy,
+			&job.Attempts,
+			&job.ResultPath,
+			&job.DownloadURL,
+			&job.Error,
+			&job.StartedAt,
+			&job.CompletedAt,
+			&job.DeadLetteredAt,
+			&job.CreatedAt,
+			&job.UpdatedAt,
+			&job.ProgressWebhookID,
+			&milestones,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		job.ProgressMilestones = progressMilestones(milestones)
+		jobs = append(jobs, &job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate job rows: %w", err)
+	}
+
+	return jobs, nil
+}
+
+func (r *jobRepository) ListDeadLettered(ctx context.Context, limit, offset int) ([]*models.Job, error) {
+	query := `
+		SELECT id, type, status, progress, processed, total, parameters, created_by, attempts, result_path, download_url, error, started_at, completed_at, dead_lettered_at, created_at, updated_at, progress_webhook_id, progress_milestones
+		FROM jobs
+		WHERE status = $1
+		ORDER BY dead_lettered_at DESC, id
+		LIMIT $2 OFFSET $3`
+
+	rows, err := r.db.QueryContext(ctx, query, models.JobStatusDeadLettered, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead-lettered jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*models.Job
+	for rows.Next() {
+		var job models.Job
+		var milestones pq.Int64Array
+		err := rows.Scan(
+			&job.ID,
+			&job.Type,
+			&job.Status,
+			&job.Progress,
+			&job.Processed,
+			&job.Total,
+			&job.Parameters,
+			&job.CreatedBy,
+			&job.Attempts,
+			&job.ResultPath,
+			&job.DownloadURL,
+			&job.Error,
+			&job.StartedAt,
+			&job.CompletedAt,
+			&job.DeadLetteredAt,
+			&job.CreatedAt,
+			&job.UpdatedAt,
+			&job.ProgressWebhookID,
+			&milestones,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		job.ProgressMilestones = progressMilestones(milestones)
+		jobs = append(jobs, &job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate job rows: %w", err)
+	}
+
+	return jobs, nil
+}
+
+// ListByStatus returns the jobs in any of statuses last updated before
+// updatedBefore, oldest first
+func (r *jobRepository) ListByStatus(ctx context.Context, statuses []models.JobStatus, updatedBefore time.Time) ([]*models.Job, error) {
+	query := `
+		SELECT id, type, status, progress, processed, total, parameters, created_by, attempts, result_path, download_url, error, started_at, completed_at, dead_lettered_at, created_at, updated_at, progress_webhook_id, progress_milestones
+		FROM jobs
+		WHERE status = ANY($1) AND updated_at < $2
+		ORDER BY created_at, id`
+
+	names := make([]string, len(statuses))
+	for i, status := range statuses {
+		names[i] = string(status)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(names), updatedBefore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs by status: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*models.Job
+	for rows.Next() {
+		var job models.Job
+		var milestones pq.Int64Array
+		err := rows.Scan(
+			&job.ID,
+			&job.Type,
+			&job.Status,
+			&job.Progress,
+			&job.Processed,
+			&job.Total,
+			&job.Parameters,
+			&job.CreatedBy,
+			&job.Attempts,
+			&job.ResultPath,
+			&job.DownloadURL,
+			&job.Error,
+			&job.StartedAt,
+			&job.CompletedAt,
+			&job.DeadLetteredAt,
+			&job.CreatedAt,
+			&job.UpdatedAt,
+			&job.ProgressWebhookID,
+			&milestones,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		job.ProgressMilestones = progressMilestones(milestones)
+		jobs = append(jobs, &job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate job rows: %w", err)
+	}
+
+	return jobs, nil
+}
+
+func (r *jobRepository) CountByStatus(ctx context.Context, status models.JobStatus) (int, error) {
+	query := `SELECT COUNT(*) FROM jobs WHERE status = $1`
+
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, status).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count jobs: %w", err)
+	}
+
+	return count, nil
+}
+
+func (r *jobRepository) Redrive(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE jobs
+		SET status = $1, attempts = 0, progress = 0, processed = 0, error = NULL,
+			started_at = NULL, dead_lettered_at = NULL, updated_at = NOW()
+		WHERE id = $2 AND status = $3`
+
+	result, err := r.db.ExecContext(ctx, query, models.JobStatusQueued, id, models.JobStatusDeadLettered)
+	if err != nil {
+		return fmt.Errorf("failed to redrive job: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.ErrJobNotDeadLettered
+	}
+
+	return nil
+}
+
+// FindOverlappingLock returns the oldest lock held by a running or
+// cancelling job of jobType whose inclusive date range overlaps [from, to],
+// or nil if there is none. A lock covers a job type and a date range across
+// every merchant, so jobs over the same days block each other whichever
+// merchants they touch.
+func (r *jobRepository) FindOverlappingLock(ctx context.Context, jobType models.JobType, from, to time.Time) (*models.JobLock, error) {
+	query := `
+		SELECT l.job_id, l.job_type, l.range_from, l.range_to, l.acquired_at
+		FROM job_locks l
+		JOIN jobs j ON j.id = l.job_id
+		WHERE l.job_type = $1 AND l.range_from <= $3 AND l.range_to >= $2
+		  AND j.status IN ($4, $5)
+		ORDER BY l.acquired_at
+		LIMIT 1`
+
+	var lock models.JobLock
+	err := r.db.QueryRowContext(ctx, query, jobType, from, to, models.JobStatusRunning, models.JobStatusCancelling).Scan(
+		&lock.JobID,
+		&lock.JobType,
+		&lock.RangeFrom,
+		&lock.RangeTo,
+		&lock.AcquiredAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil // No overlapping lock is not an error
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find overlapping job lock: %w", err)
+	}
+
+	return &lock, nil
+}
+
+// AcquireLock records lock for its job within tx. It fails with a conflict
+// error naming the blocking job when the range overlaps a lock of the same
+// job type held by another running or cancelling job; locks of jobs that
+// have finished are ignored. Re-acquiring a job's own lock refreshes it.
+func (r *jobRepository) AcquireLock(ctx context.Context, tx *sql.Tx, lock *models.JobLock) error {
+	// Serialize lock acquisition so two jobs can't both see an empty range
+	if _, err := tx.ExecContext(ctx, `LOCK TABLE job_locks IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return fmt.Errorf("failed to lock job_locks table: %w", err)
+	}
+
+	// Locks held by jobs that are no longer running are stale and ignored; a
+	// job being cancelled still holds its range until its worker stops
+	checkQuery := `
+		SELECT l.job_id
+		FROM job_locks l
+		JOIN jobs j ON j.id = l.job_id
+		WHERE l.job_type = $1 AND l.range_from <= $3 AND l.range_to >= $2
+		  AND l.job_id <> $4 AND j.status IN ($5, $6)
+		LIMIT 1`
+
+	var blockingID uuid.UUID
+	err := tx.QueryRowContext(ctx, checkQuery,
+		lock.JobType, lock.RangeFrom, lock.RangeTo, lock.JobID, models.JobStatusRunning, models.JobStatusCancelling,
+	).Scan(&blockingID)
+	if err == nil {
+		return errors.NewJobRangeLockedError(blockingID.String())
+	}
+	if err != sql.ErrNoRows {
+		return fmt.Errorf("failed to check overlapping job locks: %w", err)
+	}
+
+	query := `
+		INSERT INTO job_locks (job_id, job_type, range_from, range_to, acquired_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (job_id) DO UPDATE SET acquired_at = NOW()
+		RETURNING acquired_at`
+
+	err = tx.QueryRowContext(ctx, query, lock.JobID, lock.JobType, lock.RangeFrom, lock.RangeTo).
+		Scan(&lock.AcquiredAt)
+	if err != nil {
+		return fmt.Errorf("failed to acquire job lock: %w", err)
+	}
+
+	return nil
+}
+
+// ReleaseLock drops the lock held by job id, if any
+func (r *jobRepository) ReleaseLock(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM job_locks WHERE job_id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to release job lock: %w", err)
+	}
+
+	return nil
+}
+
+// AnonymizeBuyer replaces a buyer's ID in job parameters with pseudonym. The
+// result files of order exports filtered to the buyer are detached from their
+// jobs, and their paths are returned so the files can be removed. Only object
+// parameters are matched, as jsonb_set fails on anything else.
+func (r *jobRepository) AnonymizeBuyer(ctx context.Context, buyerID, pseudonym string) ([]string, error) {
+	query := `
+		WITH matched AS (
+			SELECT id, result_path FROM jobs
+			WHERE jsonb_typeof(parameters) = 'object' AND parameters->>'buyer_id' = $1
+			FOR UPDATE
+		)
+		UPDATE jobs j
+		SET parameters = jsonb_set(j.parameters, '{buyer_id}', to_jsonb($2::text)),
+			result_path = CASE WHEN j.type = $3 THEN NULL ELSE j.result_path END,
+			download_url = CASE WHEN j.type = $3 THEN NULL ELSE j.download_url END,
+			updated_at = NOW()
+		FROM matched
+		WHERE j.id = matched.id
+		RETURNING CASE WHEN j.type = $3 THEN matched.result_path END`
+
+	rows, err := r.db.QueryContext(ctx, query, buyerID, pseudonym, models.JobTypeOrdersExport)
+	if err != nil {
+		return nil, fmt.Errorf("failed to anonymize jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var paths []string
+	for rows.Next() {
+		var path sql.NullString
+		if err := rows.Scan(&path); err != nil {
+			return nil, fmt.Errorf("failed to scan job result path: %w", err)
+		}
+		if path.Valid {
+			paths = append(paths, path.String)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate job rows: %w", err)
+	}
+
+	return paths, nil
+}
+
+// SaveFiles records a job's output files, replacing any recorded by an
+// earlier attempt
+func (r *jobRepository) SaveFiles(ctx context.Context, tx *sql.Tx, jobID uuid.UUID, files []*models.JobFile) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM job_files WHERE job_id = $1`, jobID); err != nil {
+		return fmt.Errorf("failed to clear job files: %w", err)
+	}
+
+	query := `
+		INSERT INTO job_files (job_id, name, path, content_type, size_bytes)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at`
+
+	for _, file := range files {
+		err := tx.QueryRowContext(ctx, query, jobID, file.Name, file.Path, file.ContentType, file.SizeBytes).Scan(&file.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to save job file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ListFiles returns a job's output files ordered by name
+func (r *jobRepository) ListFiles(ctx context.Context, jobID uuid.UUID) ([]*models.JobFile, error) {
+	query := `
+		SELECT name, path, content_type, size_bytes, created_at
+		FROM job_files
+		WHERE job_id = $1
+		ORDER BY name`
+
+	rows, err := r.db.QueryContext(ctx, query, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list job files: %w", err)
+	}
+	defer rows.Close()
+
+	var files []*models.JobFile
+	for rows.Next() {
+		var file models.JobFile
+		if err := rows.Scan(&file.Name, &file.Path, &file.ContentType, &file.SizeBytes, &file.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan job file: %w", err)
+		}
+		files = append(files, &file)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate job file rows: %w", err)
+	}
+
+	return files, nil
+}
+
+// SaveStats records the execution stats of a finished job, replacing those
+// of an earlier run when a job is re-driven
+func (r *jobRepository) SaveStats(ctx context.Context, stats *models.JobStats) error {
+	query := `
+		INSERT INTO job_stats (
+			job_id, job_type, status, attempts, rows_processed, batches, duration_ms,
+			db_time_ms, compute_time_ms, rows_per_second, peak_memory_bytes, started_at, finished_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		ON CONFLICT (job_id) DO UPDATE SET
+			status = EXCLUDED.status,
+			attempts = EXCLUDED.attempts,
+			rows_processed = EXCLUDED.rows_processed,
+			batches = EXCLUDED.batches,
+			duration_ms = EXCLUDED.duration_ms,
+			db_time_ms = EXCLUDED.db_time_ms,
+			compute_time_ms = EXCLUDED.compute_time_ms,
+			rows_per_second = EXCLUDED.rows_per_second,
+			peak_memory_bytes = EXCLUDED.peak_memory_bytes,
+			started_at = EXCLUDED.started_at,
+			finished_at = EXCLUDED.finished_at`
+
+	_, err := r.db.ExecContext(ctx, query,
+		stats.JobID, stats.JobType, stats.Status, stats.Attempts, stats.RowsProcessed, stats.Batches,
+		stats.DurationMS, stats.DBTimeMS, stats.ComputeTimeMS, stats.RowsPerSecond,
+		int64(stats.PeakMemoryBytes), stats.StartedAt, stats.FinishedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save job stats: %w", err)
+	}
+
+	return nil
+}
+
+// GetStats retrieves the execution stats of a finished job
+func (r *jobRepository) GetStats(ctx context.Context, jobID uuid.UUID) (*models.JobStats, error) {
+	query := `
+		SELECT job_id, job_type, status, attempts, rows_processed, batches, duration_ms,
+			db_time_ms, compute_time_ms, rows_per_second, peak_memory_bytes, started_at, finished_at
+		FROM job_stats
+		WHERE job_id = $1`
+
+	var stats models.JobStats
+	var peakMemory int64
+	err := r.db.QueryRowContext(ctx, query, jobID).Scan(
+		&stats.JobID, &stats.JobType, &stats.Status, &stats.Attempts, &stats.RowsProcessed, &stats.Batches,
+		&stats.DurationMS, &stats.DBTimeMS, &stats.ComputeTimeMS, &stats.RowsPerSecond,
+		&peakMemory, &stats.StartedAt, &stats.FinishedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrJobStatsNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job stats: %w", err)
+	}
+	stats.PeakMemoryBytes = uint64(peakMemory)
+
+	return &stats, nil
+}
+
+// AppendLogs stores job log lines in one statement
+func (r *jobRepository) AppendLogs(ctx context.Context, entries []*models.JobLogEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO job_logs (job_id, level, message, fields, logged_at)
+		VALUES `
+
+	args := make([]interface{}, 0, len(entries)*5)
+	placeholders := make([]string, 0, len(entries))
+
+	for i, entry := range entries {
+		fields, err := json.Marshal(entry.Fields)
+		if err != nil {
+			return fmt.Errorf("failed to marshal job log fields: %w", err)
+		}
+
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d)",
+			i*5+1, i*5+2, i*5+3, i*5+4, i*5+5))
+		args = append(args, entry.JobID, entry.Level, entry.Message, fields, entry.LoggedAt)
+	}
+
+	query += strings.Join(placeholders, ",")
+
+	if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to append job logs: %w", err)
+	}
+
+	return nil
+}
+
+// ListLogs returns a job's log lines at the given levels after a line ID,
+// oldest first
+func (r *jobRepository) ListLogs(ctx context.Context, jobID uuid.UUID, levels []string, after int64, limit int) ([]*models.JobLogEntry, error) {
+	query := `
+		SELECT id, job_id, level, message, fields, logged_at
+		FROM job_logs
+		WHERE job_id = $1 AND level = ANY($2) AND id > $3
+		ORDER BY id
+		LIMIT $4`
+
+	rows, err := r.db.QueryContext(ctx, query, jobID, pq.Array(levels), after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list job logs: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*models.JobLogEntry{}
+	for rows.Next() {
+		var entry models.JobLogEntry
+		var fields []byte
+		if err := rows.Scan(&entry.ID, &entry.JobID, &entry.Level, &entry.Message, &fields, &entry.LoggedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan job log: %w", err)
+		}
+		if err := json.Unmarshal(fields, &entry.Fields); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal job log fields: %w", err)
+		}
+		entries = append(entries, &entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate job log rows: %w", err)
+	}
+
+	return entries, nil
+}
+
+// statsRepository implements StatsRepository
+type statsRepository struct {
+	db *sql.DB
+}
+
+// NewStatsRepository creates a new stats repository
+func NewStatsRepository(db *sql.DB) StatsRepository {
+	return &statsRepository{db: db}
+}
+
+// OrdersPerMinute counts orders created since the given time by minute.
+// Minutes without orders are omitted.
+func (r *statsRepository) OrdersPerMinute(ctx context.Context, since time.Time) ([]*models.MinuteCount, error) {
+	query := `
+		SELECT date_trunc('minute', created_at) AS minute, COUNT(*)
+		FROM orders
+		WHERE created_at >= $1
+		GROUP BY minute
+		ORDER BY minute`
+
+	rows, err := r.db.QueryContext(ctx, query, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count orders per minute: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []*models.MinuteCount
+	for rows.Next() {
+		var count models.MinuteCount
+		if err := rows.Scan(&count.Minute, &count.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan order count: %w", err)
+		}
+		counts = append(counts, &count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate order count rows: %w", err)
+	}
+
+	return counts, nil
+}
+
+// JobThroughput summarizes, by job type, the jobs that reached a final
+// status since the given time
+func (r *statsRepository) JobThroughput(ctx context.Context, since time.Time) ([]*models.JobTypeThroughput, error) {
+	query := `
+		SELECT type,
+			   COUNT(*) FILTER (WHERE status = 'COMPLETED'),
+			   COUNT(*) FILTER (WHERE status = 'FAILED'),
+			   COUNT(*) FILTER (WHERE status = 'DEAD_LETTERED'),
+			   COUNT(*) FILTER (WHERE status = 'CANCELLED'),
+			   COALESCE(AVG(EXTRACT(EPOCH FROM completed_at - started_at)) FILTER (WHERE status = 'COMPLETED'), 0)
+		FROM jobs
+		WHERE updated_at >= $1 AND status IN ('COMPLETED', 'FAILED', 'DEAD_LETTERED', 'CANCELLED')
+		GROUP BY type
+		ORDER BY type`
+
+	rows, err := r.db.QueryContext(ctx, query, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize job throughput: %w", err)
+	}
+	defer rows.Close()
+
+	var throughput []*models.JobTypeThroughput
+	for rows.Next() {
+		var t models.JobTypeThroughput
+		err := rows.Scan(
+			&t.JobType,
+			&t.Completed,
+			&t.Failed,
+			&t.DeadLettered,
+			&t.Cancelled,
+			&t.AvgDurationSeconds,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job throughput: %w", err)
+		}
+		throughput = append(throughput, &t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate job throughput rows: %w", err)
+	}
+
+	return throughput, nil
+}
+
+// JobQueue counts, by job type, the queued and running jobs and the jobs
+// that failed since the given time, with the age of the oldest queued job
+func (r *statsRepository) JobQueue(ctx context.Context, failedSince time.Time) ([]*models.JobTypeQueue, error) {
+	query := `
+		SELECT type,
+			   COUNT(*) FILTER (WHERE status = 'QUEUED'),
+			   COUNT(*) FILTER (WHERE status IN ('RUNNING', 'CANCELLING')),
+			   COUNT(*) FILTER (WHERE status IN ('FAILED', 'DEAD_LETTERED')),
+			   COALESCE(EXTRACT(EPOCH FROM NOW() - MIN(created_at) FILTER (WHERE status = 'QUEUED')), 0)
+		FROM jobs
+		WHERE status IN ('QUEUED', 'RUNNING', 'CANCELLING')
+		   OR (status IN ('FAILED', 'DEAD_LETTERED') AND updated_at >= $1)
+		GROUP BY type
+		ORDER BY type`
+
+	rows, err := r.db.QueryContext(ctx, query, failedSince)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize job queue: %w", err)
+	}
+	defer rows.Close()
+
+	var queue []*models.JobTypeQueue
+	for rows.Next() {
+		var q models.JobTypeQueue
+		err := rows.Scan(
+			&q.JobType,
+			&q.Queued,
+			&q.Running,
+			&q.FailedLastHour,
+			&q.OldestQueuedSeconds,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job queue: %w", err)
+		}
+		queue = append(queue, &q)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate job queue rows: %w", err)
+	}
+
+	return queue, nil
+}
+
+// ErrorRates counts finished jobs, settled transactions and orders created
+// since the given time together with how many of each ended in error
+func (r *statsRepository) ErrorRates(ctx context.Context, since time.Time) (*models.ErrorRateStats, error) {
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM jobs WHERE updated_at >= $1 AND status IN ('COMPLETED', 'FAILED', 'DEAD_LETTERED')),
+			(SELECT COUNT(*) FROM jobs WHERE updated_at >= $1 AND status IN ('FAILED', 'DEAD_LETTERED')),
+			(SELECT COUNT(*) FROM transactions WHERE created_at >= $1 AND status <> 'PENDING'),
+			(SELECT COUNT(*) FROM transactions WHERE created_at >= $1 AND status = 'FAILED'),
+			(SELECT COUNT(*) FROM orders WHERE created_at >= $1),
+			(SELECT COUNT(*) FROM orders WHERE created_at >= $1 AND status = 'CANCELLED')`
+
+	var stats models.ErrorRateStats
+	err := r.db.QueryRowContext(ctx, query, since).Scan(
+		&stats.Jobs.Total,
+		&stats.Jobs.Errors,
+		&stats.Transactions.Total,
+		&stats.Transactions.Errors,
+		&stats.Orders.Total,
+		&stats.Orders.Errors,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count error rates: %w", err)
+	}
+
+	return &stats, nil
+}
+
+// TopMerchants returns the merchants with the highest completed transaction
+// volume paid in [from, to)
+func (r *statsRepository) TopMerchants(ctx context.Context, from, to time.Time, limit int) ([]*models.MerchantVolume, error) {
+	query := `
+		SELECT merchant_id, SUM(amount_cents) AS gross_cents, COUNT(*)
 		FROM transactions
-		WHERE paid_at >= $1 AND paid_at < $2 AND status = 'COMPLETED'`
+		WHERE paid_at >= $1 AND paid_at < $2 AND status = 'COMPLETED'
+		GROUP BY merchant_id
+		ORDER BY gross_cents DESC, merchant_id
+		LIMIT $3`
 
-	var count int
-	err := r.db.QueryRowContext(ctx, query, from, to).Scan(&count)
+	rows, err := r.db.QueryContext(ctx, query, from, to, limit)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get transaction count: %w", err)
+		return nil, fmt.Errorf("failed to list top merchants: %w", err)
 	}
+	defer rows.Close()
 
-	return count, nil
+	var merchants []*models.MerchantVolume
+	for rows.Next() {
+		var merchant models.MerchantVolume
+		if err := rows.Scan(&merchant.MerchantID, &merchant.GrossCents, &merchant.TxnCount); err != nil {
+			return nil, fmt.Errorf("failed to scan merchant volume: %w", err)
+		}
+		merchants = append(merchants, &merchant)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate merchant volume rows: %w", err)
+	}
+
+	return merchants, nil
 }
 
-func (r *transactionRepository) Create(ctx context.Context, tx *models.Transaction) error {
+// sagaRepository implements SagaRepository
+type sagaRepository struct {
+	db *sql.DB
+}
+
+// NewSagaRepository creates a new saga repository
+func NewSagaRepository(db *sql.DB) SagaRepository {
+	return &sagaRepository{db: db}
+}
+
+func (r *sagaRepository) Create(ctx context.Context, saga *models.Saga) error {
 	query := `
-		INSERT INTO transactions (merchant_id, amount_cents, fee_cents, status, paid_at, created_at)
-		VALUES ($1, $2, $3, $4, $5, NOW())
-		RETURNING id, created_at`
+		INSERT INTO sagas (id, type, status, payload, completed_steps, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+		RETURNING created_at, updated_at`
 
 	err := r.db.QueryRowContext(ctx, query,
-		tx.MerchantID,
-		tx.AmountCents,
-		tx.FeeCents,
-		tx.Status,
-		tx.PaidAt,
-	).Scan(&tx.ID, &tx.CreatedAt)
+		saga.ID,
+		saga.Type,
+		saga.Status,
+		[]byte(saga.Payload),
+		saga.CompletedSteps,
+	).Scan(&saga.CreatedAt, &saga.UpdatedAt)
 
 	if err != nil {
-		return fmt.Errorf("failed to create transaction: %w", err)
+		return fmt.Errorf("failed to create saga: %w", err)
 	}
 
 	return nil
 }
 
-func (r *transactionRepository) BulkCreate(ctx context.Context, transactions []*models.Transaction) error {
-	if len(transactions) == 0 {
-		return nil
+func (r *sagaRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Saga, error) {
+	query := `
+		SELECT id, type, status, payload, completed_steps, failed_step, error, created_at, updated_at
+		FROM sagas
+		WHERE id = $1`
+
+	var saga models.Saga
+	var payload []byte
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&saga.ID,
+		&saga.Type,
+		&saga.Status,
+		&payload,
+		&saga.CompletedSteps,
+		&saga.FailedStep,
+		&saga.Error,
+		&saga.CreatedAt,
+		&saga.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrSagaNotFound
 	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get saga: %w", err)
+	}
+
+	saga.Payload = payload
+	return &saga, nil
+}
 
+// SetProgress records how many steps of a saga have taken effect, in the
+// same transaction as the step itself, together with the updated payload
+func (r *sagaRepository) SetProgress(ctx context.Context, tx *sql.Tx, id uuid.UUID, completedSteps int, status models.SagaStatus, payload []byte) error {
 	query := `
-		INSERT INTO transactions (merchant_id, amount_cents, fee_cents, status, paid_at, created_at)
-		VALUES `
+		UPDATE sagas
+		SET completed_steps = $1, status = $2, payload = $3, updated_at = NOW()
+		WHERE id = $4`
 
-	args := make([]interface{}, 0, len(transactions)*5)
-	placeholders := make([]string, 0, len(transactions))
+	if _, err := tx.ExecContext(ctx, query, completedSteps, status, payload, id); err != nil {
+		return fmt.Errorf("failed to update saga progress: %w", err)
+	}
 
-	for i, tx := range transactions {
-		placeholderGroup := fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, NOW())",
-			i*5+1, i*5+2, i*5+3, i*5+4, i*5+5)
-		placeholders = append(placeholders, placeholderGroup)
+	return nil
+}
 
-		args = append(args,
-			tx.MerchantID,
-			tx.AmountCents,
-			tx.FeeCents,
-			tx.Status,
-			tx.PaidAt,
+// SetStatus updates a saga's status, recording the failed step and error
+// when given
+func (r *sagaRepository) SetStatus(ctx context.Context, id uuid.UUID, status models.SagaStatus, failedStep, errMsg string) error {
+	query := `
+		UPDATE sagas
+		SET status = $1,
+			failed_step = COALESCE(NULLIF($2, ''), failed_step),
+			error = COALESCE(NULLIF($3, ''), error),
+			updated_at = NOW()
+		WHERE id = $4`
+
+	if _, err := r.db.ExecContext(ctx, query, status, failedStep, errMsg, id); err != nil {
+		return fmt.Errorf("failed to update saga status: %w", err)
+	}
+
+	return nil
+}
+
+// ListByStatus returns sagas with the given status, newest first
+func (r *sagaRepository) ListByStatus(ctx context.Context, status models.SagaStatus, limit, offset int) ([]*models.Saga, error) {
+	query := `
+		SELECT id, type, status, payload, completed_steps, failed_step, error, created_at, updated_at
+		FROM sagas
+		WHERE status = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := r.db.QueryContext(ctx, query, status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sagas: %w", err)
+	}
+	defer rows.Close()
+
+	var sagas []*models.Saga
+	for rows.Next() {
+		var saga models.Saga
+		var payload []byte
+		err := rows.Scan(
+			&saga.ID,
+			&saga.Type,
+			&saga.Status,
+			&payload,
+			&saga.CompletedSteps,
+			&saga.FailedStep,
+			&saga.Error,
+			&saga.CreatedAt,
+			&saga.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan saga: %w", err)
+		}
+		saga.Payload = payload
+		sagas = append(sagas, &saga)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate saga rows: %w", err)
+	}
+
+	return sagas, nil
+}
+
+// ListUnfinished returns sagas that were interrupted before completing or
+// finishing compensation and have not been touched since updatedBefore
+func (r *sagaRepository) ListUnfinished(ctx context.Context, updatedBefore time.Time) ([]*models.Saga, error) {
+	query := `
+		SELECT id, type, status, payload, completed_steps, failed_step, error, created_at, updated_at
+		FROM sagas
+		WHERE status IN ($1, $2, $3) AND updated_at < $4
+		ORDER BY updated_at`
+
+	rows, err := r.db.QueryContext(ctx, query,
+		models.SagaStatusRunning, models.SagaStatusCompensating, models.SagaStatusFailed, updatedBefore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unfinished sagas: %w", err)
+	}
+	defer rows.Close()
+
+	var sagas []*models.Saga
+	for rows.Next() {
+		var saga models.Saga
+		var payload []byte
+		err := rows.Scan(
+			&saga.ID,
+			&saga.Type,
+			&saga.Status,
+			&payload,
+			&saga.CompletedSteps,
+			&saga.FailedStep,
+			&saga.Error,
+			&saga.CreatedAt,
+			&saga.UpdatedAt,
 		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan saga: %w", err)
+		}
+		saga.Payload = payload
+		sagas = append(sagas, &saga)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate saga rows: %w", err)
+	}
+
+	return sagas, nil
+}
+
+// AnonymizeBuyer replaces a buyer's ID in saga payloads with pseudonym and
+// returns how many sagas it changed
+func (r *sagaRepository) AnonymizeBuyer(ctx context.Context, buyerID, pseudonym string) (int, error) {
+	query := `
+		UPDATE sagas
+		SET payload = jsonb_set(payload, '{buyer_id}', to_jsonb($1::text))
+		WHERE payload->>'buyer_id' = $2`
+
+	result, err := r.db.ExecContext(ctx, query, pseudonym, buyerID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to anonymize sagas: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get anonymized sagas: %w", err)
+	}
+
+	return int(affected), nil
+}
+
+// maintenanceRepository implements MaintenanceRepository
+type maintenanceRepository struct {
+	db *sql.DB
+}
+
+// NewMaintenanceRepository creates a new maintenance repository
+func NewMaintenanceRepository(db *sql.DB) MaintenanceRepository {
+	return &maintenanceRepository{db: db}
+}
+
+func (r *maintenanceRepository) Get(ctx context.Context) (*models.MaintenanceState, error) {
+	query := `SELECT enabled, message, updated_at FROM maintenance_mode WHERE id`
+
+	var state models.MaintenanceState
+	err := r.db.QueryRowContext(ctx, query).Scan(&state.Enabled, &state.Message, &state.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return &models.MaintenanceState{}, nil
+		}
+		return nil, fmt.Errorf("failed to get maintenance mode: %w", err)
 	}
 
-	query += strings.Join(placeholders, ",")
+	return &state, nil
+}
 
-	_, err := r.db.ExecContext(ctx, query, args...)
+func (r *maintenanceRepository) Set(ctx context.Context, enabled bool, message string) (*models.MaintenanceState, error) {
+	query := `
+		INSERT INTO maintenance_mode (id, enabled, message, updated_at)
+		VALUES (TRUE, $1, $2, NOW())
+		ON CONFLICT (id) DO UPDATE
+		SET enabled = EXCLUDED.enabled, message = EXCLUDED.message, updated_at = EXCLUDED.updated_at
+		RETURNING enabled, message, updated_at`
+
+	var state models.MaintenanceState
+	err := r.db.QueryRowContext(ctx, query, enabled, message).Scan(&state.Enabled, &state.Message, &state.UpdatedAt)
 	if err != nil {
-		return fmt.Errorf("failed to bulk create transactions: %w", err)
+		return nil, fmt.Errorf("failed to set maintenance mode: %w", err)
 	}
 
-	return nil
+	return &state, nil
 }
 
-// settlementRepository implements SettlementRepository
-type settlementRepository struct {
+// authBanRepository implements AuthBanRepository
+type authBanRepository struct {
 	db *sql.DB
 }
 
-// NewSettlementRepository creates a new settlement repository
-func NewSettlementRepository(db *sql.DB) SettlementRepository {
-	return &settlementRepository{db: db}
+// NewAuthBanRepository creates a new auth ban repository
+func NewAuthBanRepository(db *sql.DB) AuthBanRepository {
+	return &authBanRepository{db: db}
 }
 
-func (r *settlementRepository) Upsert(ctx context.Context, tx *sql.Tx, settlement *models.Settlement) error {
+func (r *authBanRepository) Record(ctx context.Context, ban *models.AuthBan) error {
 	query := `
-		INSERT INTO settlements (merchant_id, date, gross_cents, fee_cents, net_cents, txn_count, generated_at, unique_run_id, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW())
-		ON CONFLICT (merchant_id, date)
-		DO UPDATE SET 
-			gross_cents = settlements.gross_cents + EXCLUDED.gross_cents,
-			fee_cents = settlements.fee_cents + EXCLUDED.fee_cents,
-			net_cents = settlements.net_cents + EXCLUDED.net_cents,
-			txn_count = settlements.txn_count + EXCLUDED.txn_count,
-			updated_at = NOW()
-		RETURNING id, created_at, updated_at`
-
-	err := tx.QueryRowContext(ctx, query,
-		settlement.MerchantID,
-		settlement.Date,
-		settlement.GrossCents,
-		settlement.FeeCents,
-		settlement.NetCents,
-		settlement.TxnCount,
-		settlement.GeneratedAt,
-		settlement.UniqueRunID,
-	).Scan(&settlement.ID, &settlement.CreatedAt, &settlement.UpdatedAt)
+		INSERT INTO auth_bans (scope, subject, credential, failures, client_ip, request_id, banned_until, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		RETURNING id, created_at`
 
+	err := r.db.QueryRowContext(ctx, query,
+		ban.Scope, ban.Subject, ban.Credential, ban.Failures, ban.ClientIP, ban.RequestID, ban.BannedUntil,
+	).Scan(&ban.ID, &ban.CreatedAt)
 	if err != nil {
-		return fmt.Errorf("failed to upsert settlement: %w", err)
+		return fmt.Errorf("failed to record auth ban: %w", err)
 	}
 
 	return nil
 }
 
-func (r *settlementRepository) GetByMerchantAndDate(ctx context.Context, merchantID string, date time.Time) (*models.Settlement, error) {
+// List returns bans newest first
+func (r *authBanRepository) List(ctx context.Context, limit, offset int) ([]*models.AuthBan, error) {
 	query := `
-		SELECT id, merchant_id, date, gross_cents, fee_cents, net_cents, txn_count, generated_at, unique_run_id, created_at, updated_at
-		FROM settlements
-		WHERE merchant_id = $1 AND date = $2`
+		SELECT id, scope, subject, credential, failures, client_ip, request_id, banned_until, created_at
+		FROM auth_bans
+		ORDER BY created_at DESC, id DESC
+		LIMIT $1 OFFSET $2`
 
-	var settlement models.Settlement
-	err := r.db.QueryRowContext(ctx, query, merchantID, date).Scan(
-		&settlement.ID,
-		&settlement.MerchantID,
-		&settlement.Date,
-		&settlement.GrossCents,
-		&settlement.FeeCents,
-		&settlement.NetCents,
-		&settlement.TxnCount,
-		&settlement.GeneratedAt,
-		&settlement.UniqueRunID,
-		&settlement.CreatedAt,
-		&settlement.UpdatedAt,
-	)
+	rows, err := r.db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list auth bans: %w", err)
+	}
+	defer rows.Close()
 
-	if err == sql.ErrNoRows {
-		return nil, nil // Settlement not found is not an error in this case
+	bans := []*models.AuthBan{}
+	for rows.Next() {
+		var ban models.AuthBan
+		err := rows.Scan(
+			&ban.ID,
+			&ban.Scope,
+			&ban.Subject,
+			&ban.Credential,
+			&ban.Failures,
+			&ban.ClientIP,
+			&ban.RequestID,
+			&ban.BannedUntil,
+			&ban.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan auth ban: %w", err)
+		}
+		bans = append(bans, &ban)
 	}
-	if err != nil {
-		return nil, fmt.Errorf("failed to get settlement: %w", err)
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate auth ban rows: %w", err)
 	}
 
-	return &settlement, nil
+	return bans, nil
 }
 
-// jobRepository implements JobRepository
-type jobRepository struct {
+// webhookRepository implements WebhookRepository
+type webhookRepository struct {
 	db *sql.DB
 }
 
-// NewJobRepository creates a new job repository
-func NewJobRepository(db *sql.DB) JobRepository {
-	return &jobRepository{db: db}
+// NewWebhookRepository creates a new webhook repository
+func NewWebhookRepository(db *sql.DB) WebhookRepository {
+	return &webhookRepository{db: db}
 }
 
-func (r *jobRepository) Create(ctx context.Context, job *models.Job) error {
-	paramsJSON, err := json.Marshal(job.Parameters)
-	if err != nil {
-		return fmt.Errorf("failed to marshal job parameters: %w", err)
-	}
-
+func (r *webhookRepository) Create(ctx context.Context, endpoint *models.WebhookEndpoint) error {
 	query := `
-		INSERT INTO jobs (id, type, status, progress, processed, total, parameters, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW())
-		RETURNING created_at, updated_at`
-
-	err = r.db.QueryRowContext(ctx, query,
-		job.ID,
-		job.Type,
-		job.Status,
-		job.Progress,
-		job.Processed,
-		job.Total,
-		string(paramsJSON),
-	).Scan(&job.CreatedAt, &job.UpdatedAt)
+		INSERT INTO webhook_endpoints (id, url, secret, description, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		RETURNING created_at`
 
+	err := r.db.QueryRowContext(ctx, query, endpoint.ID, endpoint.URL, endpoint.Secret, endpoint.Description).Scan(&endpoint.CreatedAt)
 	if err != nil {
-		return fmt.Errorf("failed to create job: %w", err)
+		return fmt.Errorf("failed to create webhook endpoint: %w", err)
 	}
 
 	return nil
 }
 
-func (r *jobRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Job, error) {
+// GetByID returns an endpoint with its secret
+func (r *webhookRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.WebhookEndpoint, error) {
 	query := `
-		SELECT id, type, status, progress, processed, total, parameters, result_path, download_url, error, started_at, completed_at, created_at, updated_at
-		FROM jobs
+		SELECT id, url, secret, description, created_at
+		FROM webhook_endpoints
 		WHERE id = $1`
 
-	var job models.Job
-	var params string
-
+	var endpoint models.WebhookEndpoint
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&job.ID,
-		&job.Type,
-		&job.Status,
-		&job.Progress,
-		&job.Processed,
-		&job.Total,
-		&params,
-		&job.ResultPath,
-		&job.DownloadURL,
-		&job.Error,
-		&job.StartedAt,
-		&job.CompletedAt,
-		&job.CreatedAt,
-		&job.UpdatedAt,
+		&endpoint.ID,
+		&endpoint.URL,
+		&endpoint.Secret,
+		&endpoint.Description,
+		&endpoint.CreatedAt,
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, errors.ErrJobNotFound
+		return nil, errors.ErrWebhookNotFound
 	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get job: %w", err)
+		return nil, fmt.Errorf("failed to get webhook endpoint: %w", err)
 	}
 
-	job.Parameters = params
-	return &job, nil
+	return &endpoint, nil
 }
 
-func (r *jobRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status models.JobStatus) error {
-	query := `UPDATE jobs SET status = $1, updated_at = NOW() WHERE id = $2`
+// List returns every endpoint without its secret
+func (r *webhookRepository) List(ctx context.Context) ([]*models.WebhookEndpoint, error) {
+	query := `
+		SELECT id, url, description, created_at
+		FROM webhook_endpoints
+		ORDER BY created_at, id`
 
-	_, err := r.db.ExecContext(ctx, query, status, id)
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
-		return fmt.Errorf("failed to update job status: %w", err)
+		return nil, fmt.Errorf("failed to list webhook endpoints: %w", err)
 	}
+	defer rows.Close()
 
-	return nil
+	endpoints := []*models.WebhookEndpoint{}
+	for rows.Next() {
+		var endpoint models.WebhookEndpoint
+		if err := rows.Scan(&endpoint.ID, &endpoint.URL, &endpoint.Description, &endpoint.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook endpoint: %w", err)
+		}
+		endpoints = append(endpoints, &endpoint)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate webhook endpoint rows: %w", err)
+	}
+
+	return endpoints, nil
 }
 
-func (r *jobRepository) UpdateProgress(ctx context.Context, id uuid.UUID, progress float64, processed int) error {
-	query := `UPDATE jobs SET progress = $1, processed = $2, updated_at = NOW() WHERE id = $3`
+func (r *webhookRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM webhook_endpoints WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook endpoint: %w", err)
+	}
 
-	_, err := r.db.ExecContext(ctx, query, progress, processed, id)
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to update job progress: %w", err)
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return errors.ErrWebhookNotFound
 	}
 
 	return nil
 }
 
-func (r *jobRepository) UpdateResult(ctx context.Context, id uuid.UUID, resultPath, downloadURL string) error {
-	query := `UPDATE jobs SET result_path = $1, download_url = $2, updated_at = NOW() WHERE id = $3`
+// searchRepository implements SearchRepository
+type searchRepository struct {
+	db *sql.DB
+}
 
-	_, err := r.db.ExecContext(ctx, query, resultPath, downloadURL, id)
+// NewSearchRepository creates a new search repository
+func NewSearchRepository(db *sql.DB) SearchRepository {
+	return &searchRepository{db: db}
+}
+
+// Now returns the database clock, which stamps updated_at, so sync cursors
+// don't depend on the application host's clock
+func (r *searchRepository) Now(ctx context.Context) (time.Time, error) {
+	var now time.Time
+	if err := r.db.QueryRowContext(ctx, "SELECT NOW()").Scan(&now); err != nil {
+		return time.Time{}, fmt.Errorf("failed to read database time: %w", err)
+	}
+	return now, nil
+}
+
+// GetSyncCursor returns how far an index has been synced; the zero time
+// means it has never been synced
+func (r *searchRepository) GetSyncCursor(ctx context.Context, index string) (time.Time, error) {
+	query := `SELECT synced_until FROM search_sync_state WHERE index_name = $1`
+
+	var syncedUntil time.Time
+	err := r.db.QueryRowContext(ctx, query, index).Scan(&syncedUntil)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to update job result: %w", err)
+		return time.Time{}, fmt.Errorf("failed to get search sync cursor: %w", err)
+	}
+
+	return syncedUntil, nil
+}
+
+func (r *searchRepository) SaveSyncCursor(ctx context.Context, index string, syncedUntil time.Time) error {
+	query := `
+		INSERT INTO search_sync_state (index_name, synced_until, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (index_name) DO UPDATE
+		SET synced_until = EXCLUDED.synced_until, updated_at = EXCLUDED.updated_at`
+
+	if _, err := r.db.ExecContext(ctx, query, index, syncedUntil); err != nil {
+		return fmt.Errorf("failed to save search sync cursor: %w", err)
 	}
 
 	return nil
 }
 
-func (r *jobRepository) UpdateError(ctx context.Context, id uuid.UUID, errMsg string) error {
-	query := `UPDATE jobs SET error = $1, updated_at = NOW() WHERE id = $2`
+// ProductsChangedAfter pages through products in (updated_at, id) order,
+// starting after the given position
+func (r *searchRepository) ProductsChangedAfter(ctx context.Context, updatedAt time.Time, id int, limit int) ([]*models.Product, error) {
+	query := `
+		SELECT id, name, sku, barcode, stock, price, version, created_at, updated_at
+		FROM products
+		WHERE (updated_at, id) > ($1, $2)
+		ORDER BY updated_at, id
+		LIMIT $3`
 
-	_, err := r.db.ExecContext(ctx, query, errMsg, id)
+	rows, err := r.db.QueryContext(ctx, query, updatedAt, id, limit)
 	if err != nil {
-		return fmt.Errorf("failed to update job error: %w", err)
+		return nil, fmt.Errorf("failed to list changed products: %w", err)
 	}
+	defer rows.Close()
 
-	return nil
+	var products []*models.Product
+	for rows.Next() {
+		var product models.Product
+		err := rows.Scan(
+			&product.ID,
+			&product.Name,
+			&product.SKU,
+			&product.Barcode,
+			&product.Stock,
+			&product.Price,
+			&product.Version,
+			&product.CreatedAt,
+			&product.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan product: %w", err)
+		}
+		products = append(products, &product)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate product rows: %w", err)
+	}
+
+	return products, nil
 }
 
-func (r *jobRepository) MarkStarted(ctx context.Context, id uuid.UUID) error {
-	query := `UPDATE jobs SET status = $1, started_at = NOW(), updated_at = NOW() WHERE id = $2`
+// OrdersChangedAfter pages through orders in (updated_at, id) order,
+// starting after the given position
+func (r *searchRepository) OrdersChangedAfter(ctx context.Context, updatedAt time.Time, id uuid.UUID, limit int) ([]*models.Order, error) {
+	query := `
+		SELECT id, product_id, buyer_id, quantity, status, total_cents, client_reference, created_at, updated_at
+		FROM orders
+		WHERE (updated_at, id) > ($1, $2)
+		ORDER BY updated_at, id
+		LIMIT $3`
 
-	_, err := r.db.ExecContext(ctx, query, models.JobStatusRunning, id)
+	rows, err := r.db.QueryContext(ctx, query, updatedAt, id, limit)
 	if err != nil {
-		return fmt.Errorf("failed to mark job as started: %w", err)
+		return nil, fmt.Errorf("failed to list changed orders: %w", err)
 	}
+	defer rows.Close()
 
-	return nil
+	var orders []*models.Order
+	for rows.Next() {
+		var order models.Order
+		err := rows.Scan(
+			&order.ID,
+			&order.ProductID,
+			&order.BuyerID,
+			&order.Quantity,
+			&order.Status,
+			&order.TotalCents,
+			&order.ClientReference,
+			&order.CreatedAt,
+			&order.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
+		}
+		orders = append(orders, &order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate order rows: %w", err)
+	}
+
+	return orders, nil
 }
 
-func (r *jobRepository) MarkCompleted(ctx context.Context, id uuid.UUID) error {
-	query := `UPDATE jobs SET status = $1, completed_at = NOW(), updated_at = NOW() WHERE id = $2`
+// analyticsRepository implements AnalyticsRepository
+type analyticsRepository struct {
+	db *sql.DB
+}
+
+// NewAnalyticsRepository creates a new analytics repository
+func NewAnalyticsRepository(db *sql.DB) AnalyticsRepository {
+	return &analyticsRepository{db: db}
+}
+
+// Now returns the database clock, which stamps updated_at
+func (r *analyticsRepository) Now(ctx context.Context) (time.Time, error) {
+	var now time.Time
+	if err := r.db.QueryRowContext(ctx, "SELECT NOW()").Scan(&now); err != nil {
+		return time.Time{}, fmt.Errorf("failed to read database time: %w", err)
+	}
+	return now, nil
+}
 
-	_, err := r.db.ExecContext(ctx, query, models.JobStatusCompleted, id)
+// GetCursor returns how far a projection has read; the zero time means it
+// has never run
+func (r *analyticsRepository) GetCursor(ctx context.Context, name string) (time.Time, error) {
+	query := `SELECT synced_until FROM projection_state WHERE name = $1`
+
+	var syncedUntil time.Time
+	err := r.db.QueryRowContext(ctx, query, name).Scan(&syncedUntil)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to mark job as completed: %w", err)
+		return time.Time{}, fmt.Errorf("failed to get projection cursor: %w", err)
+	}
+
+	return syncedUntil, nil
+}
+
+func (r *analyticsRepository) SaveCursor(ctx context.Context, name string, syncedUntil time.Time) error {
+	query := `
+		INSERT INTO projection_state (name, synced_until, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (name) DO UPDATE
+		SET synced_until = EXCLUDED.synced_until, updated_at = EXCLUDED.updated_at`
+
+	if _, err := r.db.ExecContext(ctx, query, name, syncedUntil); err != nil {
+		return fmt.Errorf("failed to save projection cursor: %w", err)
 	}
 
 	return nil
 }
 
-func (r *jobRepository) Cancel(ctx context.Context, id uuid.UUID) error {
+// ProjectOrders upserts the next batch of orders, in (updated_at, id) order
+// after the given position, into order_analytics. It returns how many rows
+// were projected and the position of the last one.
+func (r *analyticsRepository) ProjectOrders(ctx context.Context, updatedAt time.Time, id uuid.UUID, limit int) (int, time.Time, uuid.UUID, error) {
 	query := `
-		UPDATE jobs 
-		SET status = $1, updated_at = NOW() 
-		WHERE id = $2 AND status IN ($3, $4)`
+		WITH changed AS (
+			SELECT o.id, o.product_id, p.sku, p.name, o.status, o.quantity, o.total_cents,
+			       (o.created_at AT TIME ZONE 'UTC')::date AS order_day, o.created_at, o.updated_at
+			FROM orders o
+			JOIN products p ON p.id = o.product_id
+			WHERE (o.updated_at, o.id) > ($1, $2)
+			ORDER BY o.updated_at, o.id
+			LIMIT $3
+		), projected AS (
+			INSERT INTO order_analytics (order_id, product_id, product_sku, product_name, status, quantity,
+			                             total_cents, order_day, order_created_at, order_updated_at, projected_at)
+			SELECT id, product_id, sku, name, status, quantity, total_cents, order_day, created_at, updated_at, NOW()
+			FROM changed
+			ON CONFLICT (order_id) DO UPDATE
+			SET product_sku = EXCLUDED.product_sku,
+			    product_name = EXCLUDED.product_name,
+			    status = EXCLUDED.status,
+			    quantity = EXCLUDED.quantity,
+			    total_cents = EXCLUDED.total_cents,
+			    order_updated_at = EXCLUDED.order_updated_at,
+			    projected_at = EXCLUDED.projected_at
+			RETURNING 1
+		)
+		SELECT (SELECT COUNT(*) FROM projected), updated_at, id
+		FROM changed
+		ORDER BY updated_at DESC, id DESC
+		LIMIT 1`
 
-	result, err := r.db.ExecContext(ctx, query, models.JobStatusCancelled, id, models.JobStatusQueued, models.JobStatusRunning)
+	var projected int
+	var lastUpdatedAt time.Time
+	var lastID uuid.UUID
+	err := r.db.QueryRowContext(ctx, query, updatedAt, id, limit).Scan(&projected, &lastUpdatedAt, &lastID)
+	if err == sql.ErrNoRows {
+		return 0, updatedAt, id, nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to cancel job: %w", err)
+		return 0, time.Time{}, uuid.Nil, fmt.Errorf("failed to project orders: %w", err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	return projected, lastUpdatedAt, lastID, nil
+}
+
+// DailyProductSales summarizes projected orders by day and product for
+// days in [from, to); productID 0 includes every product
+func (r *analyticsRepository) DailyProductSales(ctx context.Context, from, to time.Time, productID int) ([]*models.OrderAnalyticsDay, error) {
+	query := `
+		SELECT order_day, product_id, MAX(product_sku), MAX(product_name),
+		       COUNT(*) FILTER (WHERE status = 'CONFIRMED'),
+		       COALESCE(SUM(quantity) FILTER (WHERE status = 'CONFIRMED'), 0),
+		       COALESCE(SUM(total_cents) FILTER (WHERE status = 'CONFIRMED'), 0),
+		       COUNT(*) FILTER (WHERE status = 'CANCELLED')
+		FROM order_analytics
+		WHERE order_day >= $1 AND order_day < $2 AND ($3 = 0 OR product_id = $3)
+		GROUP BY order_day, product_id
+		ORDER BY order_day, product_id`
+
+	rows, err := r.db.QueryContext(ctx, query, from, to, productID)
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return nil, fmt.Errorf("failed to summarize order analytics: %w", err)
 	}
+	defer rows.Close()
 
-	if rowsAffected == 0 {
-		return errors.ErrJobAlreadyCancelled
+	var days []*models.OrderAnalyticsDay
+	for rows.Next() {
+		var day models.OrderAnalyticsDay
+		err := rows.Scan(
+			&day.Date,
+			&day.ProductID,
+			&day.SKU,
+			&day.ProductName,
+			&day.Orders,
+			&day.Units,
+			&day.RevenueCents,
+			&day.CancelledOrders,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order analytics day: %w", err)
+		}
+		days = append(days, &day)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate order analytics rows: %w", err)
 	}
 
-	return nil
+	return days, nil
 }
 
-func (r *jobRepository) IsCancelled(ctx context.Context, id uuid.UUID) (bool, error) {
-	query := `SELECT status FROM jobs WHERE id = $1`
+// Statements caches the hot statements prepared on a pool. database/sql
+// prepares each on one connection up front and again on any other connection
+// the first time it runs there, keeping it for the connection's lifetime. A
+// nil *Statements runs every hot query ad hoc, so both paths can be compared
+// through the database_query_duration_seconds histogram.
+type Statements struct {
+	stmts map[string]*sql.Stmt
+}
 
-	var status models.JobStatus
-	err := r.db.QueryRowContext(ctx, query, id).Scan(&status)
-	if err == sql.ErrNoRows {
-		return false, errors.ErrJobNotFound
+// PrepareStatements prepares every hot statement on db
+func PrepareStatements(ctx context.Context, db *sql.DB) (*Statements, error) {
+	s := &Statements{stmts: make(map[string]*sql.Stmt, len(hotQueries))}
+	for name, query := range hotQueries {
+		stmt, err := db.PrepareContext(ctx, query)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("failed to prepare %s: %w", name, err)
+		}
+		s.stmts[name] = stmt
 	}
-	if err != nil {
-		return false, fmt.Errorf("failed to check job status: %w", err)
+	return s, nil
+}
+
+// Close closes every prepared statement
+func (s *Statements) Close() error {
+	if s == nil {
+		return nil
+	}
+
+	var firstErr error
+	for _, stmt := range s.stmts {
+		if err := stmt.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// stmt returns the prepared statement for name, bound to tx when given, or
+// nil when statements aren't prepared
+func (s *Statements) stmt(ctx context.Context, tx *sql.Tx, name string) *sql.Stmt {
+	if s == nil {
+		return nil
+	}
+	stmt := s.stmts[name]
+	if stmt != nil && tx != nil {
+		// Closed with the transaction
+		stmt = tx.StmtContext(ctx, stmt)
+	}
+	return stmt
+}
+
+// queryRow runs a hot query returning one row, in tx when given and on db
+// otherwise
+func (s *Statements) queryRow(ctx context.Context, db *sql.DB, tx *sql.Tx, name string, args ...interface{}) *sql.Row {
+	start := time.Now()
+
+	var row *sql.Row
+	path := "prepared"
+	if stmt := s.stmt(ctx, tx, name); stmt != nil {
+		row = stmt.QueryRowContext(ctx, args...)
+	} else {
+		path = "adhoc"
+		if tx != nil {
+			row = tx.QueryRowContext(ctx, hotQueries[name], args...)
+		} else {
+			row = db.QueryRowContext(ctx, hotQueries[name], args...)
+		}
+	}
+
+	metrics.DatabaseQueryDuration.WithLabelValues(name, path).Observe(time.Since(start).Seconds())
+	return row
+}
+
+// exec runs a hot statement, in tx when given and on db otherwise
+func (s *Statements) exec(ctx context.Context, db *sql.DB, tx *sql.Tx, name string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+
+	var (
+		result sql.Result
+		err    error
+	)
+	path := "prepared"
+	if stmt := s.stmt(ctx, tx, name); stmt != nil {
+		result, err = stmt.ExecContext(ctx, args...)
+	} else {
+		path = "adhoc"
+		if tx != nil {
+			result, err = tx.ExecContext(ctx, hotQueries[name], args...)
+		} else {
+			result, err = db.ExecContext(ctx, hotQueries[name], args...)
+		}
 	}
 
-	return status == models.JobStatusCancelled, nil
+	metrics.DatabaseQueryDuration.WithLabelValues(name, path).Observe(time.Since(start).Seconds())
+	return result, err
 }
@@ -0,0 +1,404 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"indico-backend/internal/database"
+	"indico-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ProductTx is the transactional side of ProductWriter, bound to a unit of
+// work's transaction
+type ProductTx interface {
+	GetByIDForUpdate(ctx context.Context, id int) (*models.Product, error)
+	UpdateStock(ctx context.Context, id int, quantity int, version int) error
+	ReleaseStock(ctx context.Context, id int, quantity int) error
+	GetBySKUsForUpdate(ctx context.Context, skus []string) ([]*models.Product, error)
+	SetStock(ctx context.Context, id int, stock int) error
+	CreateMovements(ctx context.Context, movements []*models.InventoryMovement) error
+	SetBackorderLimit(ctx context.Context, id int, limit int) error
+	Backorder(ctx context.Context, id int, quantity int) error
+	ReleaseBackorder(ctx context.Context, id int, quantity int) error
+	Update(ctx context.Context, product *models.Product) error
+	Delete(ctx context.Context, id int) error
+}
+
+// OrderTx is the transactional side of OrderWriter
+type OrderTx interface {
+	Create(ctx context.Context, order *models.Order) error
+	UpdateStatus(ctx context.Context, id uuid.UUID, from, to models.OrderStatus) error
+	AddEvent(ctx context.Context, event *models.OrderEvent) error
+	AddComponents(ctx context.Context, orderID uuid.UUID, components []*models.OrderComponent) error
+	ListBackorderedForUpdate(ctx context.Context, productID int) ([]*models.Order, error)
+	GetByIDForUpdate(ctx context.Context, id uuid.UUID) (*models.Order, error)
+	BuyerEventQuantity(ctx context.Context, eventID int, buyerID string, productID int) (int, error)
+}
+
+// BundleTx is the transactional side of BundleRepository
+type BundleTx interface {
+	GetComponents(ctx context.Context, bundleID int) ([]*models.BundleComponent, error)
+	Create(ctx context.Context, bundle *models.Bundle) error
+	Update(ctx context.Context, bundle *models.Bundle) error
+}
+
+// SalesEventTx is the transactional side of SalesEventRepository
+type SalesEventTx interface {
+	Create(ctx context.Context, event *models.SalesEvent) error
+}
+
+// TransactionTx is the transactional side of TransactionWriter
+type TransactionTx interface {
+	UpdateStatus(ctx context.Context, id int, from, to models.TransactionStatus) error
+	GetByPaymentID(ctx context.Context, paymentID string) (*models.Transaction, error)
+	CreateForPayment(ctx context.Context, txn *models.Transaction) error
+	RecordPaymentEvent(ctx context.Context, event *models.PaymentEvent) (bool, error)
+}
+
+// SettlementTx is the transactional side of SettlementWriter
+type SettlementTx interface {
+	Create(ctx context.Context, settlement *models.Settlement) error
+	CreateBatch(ctx context.Context, settlements []*models.Settlement) error
+	CreateRun(ctx context.Context, run *models.SettlementRun) error
+	SupersedeRange(ctx context.Context, runID uuid.UUID, from, to time.Time, keep []*models.Settlement) error
+	Supersede(ctx context.Context, runID uuid.UUID, merchantID string, date time.Time) error
+	MarkStale(ctx context.Context, merchantID string, date time.Time, reason string) (*models.Settlement, error)
+}
+
+// ForecastTx is the transactional side of ForecastRepository
+type ForecastTx interface {
+	CreateRecommendations(ctx context.Context, recommendations []*models.ReorderRecommendation) error
+}
+
+// JobTx is the transactional side of JobRepository
+type JobTx interface {
+	AcquireLock(ctx context.Context, lock *models.JobLock) error
+	SaveFiles(ctx context.Context, jobID uuid.UUID, files []*models.JobFile) error
+}
+
+// PayoutTx is the transactional side of PayoutRepository
+type PayoutTx interface {
+	GetBankAccountForUpdate(ctx context.Context, merchantID string) (*models.MerchantBankAccount, error)
+	Create(ctx context.Context, payout *models.Payout) error
+	ClaimForExport(ctx context.Context, jobID uuid.UUID, scheme models.PayoutScheme) ([]*models.PayoutExport, error)
+}
+
+// SagaTx is the transactional side of SagaRepository
+type SagaTx interface {
+	SetProgress(ctx context.Context, id uuid.UUID, completedSteps int, status models.SagaStatus, payload []byte) error
+}
+
+// Writers are the repositories a unit of work binds to its transactions. A
+// nil writer leaves the matching Tx field nil.
+type Writers struct {
+	Products     ProductWriter
+	Orders       OrderWriter
+	Bundles      BundleRepository
+	SalesEvents  SalesEventRepository
+	Transactions TransactionWriter
+	Settlements  SettlementWriter
+	Forecasts    ForecastRepository
+	Jobs         JobRepository
+	Sagas        SagaRepository
+	Payouts      PayoutRepository
+}
+
+// Tx is a set of repositories bound to one database transaction, so work
+// across several of them commits or rolls back together without a raw
+// *sql.Tx being passed around. Tests can build one from fakes directly.
+type Tx struct {
+	Products     ProductTx
+	Orders       OrderTx
+	Bundles      BundleTx
+	SalesEvents  SalesEventTx
+	Transactions TransactionTx
+	Settlements  SettlementTx
+	Forecasts    ForecastTx
+	Jobs         JobTx
+	Sagas        SagaTx
+	Payouts      PayoutTx
+
+	tx      *sql.Tx
+	writers *Writers
+}
+
+// Savepoint runs fn within a savepoint of the transaction, so a failing step
+// can be undone without aborting the rest; see database.WithSavepoint. On a
+// Tx not backed by a database transaction fn simply runs.
+func (t *Tx) Savepoint(ctx context.Context, fn func(*Tx) error) error {
+	if t.tx == nil {
+		return fn(t)
+	}
+	return database.WithSavepoint(ctx, t.tx, func(tx *sql.Tx) error {
+		return fn(bind(tx, t.writers))
+	})
+}
+
+// UnitOfWork runs work in a transaction through repositories bound to it
+type UnitOfWork interface {
+	// Do runs fn in a transaction begun with opts, committing if it
+	// returns nil and rolling back otherwise; nil opts use the server's
+	// default isolation level
+	Do(ctx context.Context, opts *sql.TxOptions, fn func(tx *Tx) error) error
+}
+
+type unitOfWork struct {
+	db      *database.DB
+	writers Writers
+}
+
+// NewUnitOfWork creates a unit of work that binds writers to transactions on db
+func NewUnitOfWork(db *database.DB, writers Writers) UnitOfWork {
+	return &unitOfWork{db: db, writers: writers}
+}
+
+func (u *unitOfWork) Do(ctx context.Context, opts *sql.TxOptions, fn func(tx *Tx) error) error {
+	return u.db.WithTx(ctx, opts, func(tx *sql.Tx) error {
+		return fn(bind(tx, &u.writers))
+	})
+}
+
+// bind returns the repositories of w bound to tx
+func bind(tx *sql.Tx, w *Writers) *Tx {
+	t := &Tx{tx: tx, writers: w}
+	if w.Products != nil {
+		t.Products = productTx{w.Products, tx}
+	}
+	if w.Orders != nil {
+		t.Orders = orderTx{w.Orders, tx}
+	}
+	if w.Bundles != nil {
+		t.Bundles = bundleTx{w.Bundles, tx}
+	}
+	if w.SalesEvents != nil {
+		t.SalesEvents = salesEventTx{w.SalesEvents, tx}
+	}
+	if w.Transactions != nil {
+		t.Transactions = transactionTx{w.Transactions, tx}
+	}
+	if w.Settlements != nil {
+		t.Settlements = settlementTx{w.Settlements, tx}
+	}
+	if w.Forecasts != nil {
+		t.Forecasts = forecastTx{w.Forecasts, tx}
+	}
+	if w.Jobs != nil {
+		t.Jobs = jobTx{w.Jobs, tx}
+	}
+	if w.Sagas != nil {
+		t.Sagas = sagaTx{w.Sagas, tx}
+	}
+	if w.Payouts != nil {
+		t.Payouts = payoutTx{w.Payouts, tx}
+	}
+	return t
+}
+
+type productTx struct {
+	repo ProductWriter
+	tx   *sql.Tx
+}
+
+func (p productTx) GetByIDForUpdate(ctx context.Context, id int) (*models.Product, error) {
+	return p.repo.GetByIDForUpdate(ctx, p.tx, id)
+}
+
+func (p productTx) UpdateStock(ctx context.Context, id int, quantity int, version int) error {
+	return p.repo.UpdateStock(ctx, p.tx, id, quantity, version)
+}
+
+func (p productTx) ReleaseStock(ctx context.Context, id int, quantity int) error {
+	return p.repo.ReleaseStock(ctx, p.tx, id, quantity)
+}
+
+func (p productTx) GetBySKUsForUpdate(ctx context.Context, skus []string) ([]*models.Product, error) {
+	return p.repo.GetBySKUsForUpdate(ctx, p.tx, skus)
+}
+
+func (p productTx) SetStock(ctx context.Context, id int, stock int) error {
+	return p.repo.SetStock(ctx, p.tx, id, stock)
+}
+
+func (p productTx) CreateMovements(ctx context.Context, movements []*models.InventoryMovement) error {
+	return p.repo.CreateMovements(ctx, p.tx, movements)
+}
+
+func (p productTx) SetBackorderLimit(ctx context.Context, id int, limit int) error {
+	return p.repo.SetBackorderLimit(ctx, p.tx, id, limit)
+}
+
+func (p productTx) Backorder(ctx context.Context, id int, quantity int) error {
+	return p.repo.Backorder(ctx, p.tx, id, quantity)
+}
+
+func (p productTx) ReleaseBackorder(ctx context.Context, id int, quantity int) error {
+	return p.repo.ReleaseBackorder(ctx, p.tx, id, quantity)
+}
+
+func (p productTx) Update(ctx context.Context, product *models.Product) error {
+	return p.repo.Update(ctx, p.tx, product)
+}
+
+func (p productTx) Delete(ctx context.Context, id int) error {
+	return p.repo.Delete(ctx, p.tx, id)
+}
+
+type orderTx struct {
+	repo OrderWriter
+	tx   *sql.Tx
+}
+
+func (o orderTx) Create(ctx context.Context, order *models.Order) error {
+	return o.repo.Create(ctx, o.tx, order)
+}
+
+func (o orderTx) UpdateStatus(ctx context.Context, id uuid.UUID, from, to models.OrderStatus) error {
+	return o.repo.UpdateStatus(ctx, o.tx, id, from, to)
+}
+
+func (o orderTx) AddEvent(ctx context.Context, event *models.OrderEvent) error {
+	return o.repo.AddEvent(ctx, o.tx, event)
+}
+
+func (o orderTx) AddComponents(ctx context.Context, orderID uuid.UUID, components []*models.OrderComponent) error {
+	return o.repo.AddComponents(ctx, o.tx, orderID, components)
+}
+
+func (o orderTx) ListBackorderedForUpdate(ctx context.Context, productID int) ([]*models.Order, error) {
+	return o.repo.ListBackorderedForUpdate(ctx, o.tx, productID)
+}
+
+func (o orderTx) GetByIDForUpdate(ctx context.Context, id uuid.UUID) (*models.Order, error) {
+	return o.repo.GetByIDForUpdate(ctx, o.tx, id)
+}
+
+func (o orderTx) BuyerEventQuantity(ctx context.Context, eventID int, buyerID string, productID int) (int, error) {
+	return o.repo.BuyerEventQuantity(ctx, o.tx, eventID, buyerID, productID)
+}
+
+type bundleTx struct {
+	repo BundleRepository
+	tx   *sql.Tx
+}
+
+func (b bundleTx) GetComponents(ctx context.Context, bundleID int) ([]*models.BundleComponent, error) {
+	return b.repo.GetComponents(ctx, b.tx, bundleID)
+}
+
+func (b bundleTx) Create(ctx context.Context, bundle *models.Bundle) error {
+	return b.repo.Create(ctx, b.tx, bundle)
+}
+
+func (b bundleTx) Update(ctx context.Context, bundle *models.Bundle) error {
+	return b.repo.Update(ctx, b.tx, bundle)
+}
+
+type salesEventTx struct {
+	repo SalesEventRepository
+	tx   *sql.Tx
+}
+
+func (s salesEventTx) Create(ctx context.Context, event *models.SalesEvent) error {
+	return s.repo.Create(ctx, s.tx, event)
+}
+
+type transactionTx struct {
+	repo TransactionWriter
+	tx   *sql.Tx
+}
+
+func (t transactionTx) UpdateStatus(ctx context.Context, id int, from, to models.TransactionStatus) error {
+	return t.repo.UpdateStatus(ctx, t.tx, id, from, to)
+}
+
+func (t transactionTx) GetByPaymentID(ctx context.Context, paymentID string) (*models.Transaction, error) {
+	return t.repo.GetByPaymentID(ctx, t.tx, paymentID)
+}
+
+func (t transactionTx) CreateForPayment(ctx context.Context, txn *models.Transaction) error {
+	return t.repo.CreateForPayment(ctx, t.tx, txn)
+}
+
+func (t transactionTx) RecordPaymentEvent(ctx context.Context, event *models.PaymentEvent) (bool, error) {
+	return t.repo.RecordPaymentEvent(ctx, t.tx, event)
+}
+
+type settlementTx struct {
+	repo SettlementWriter
+	tx   *sql.Tx
+}
+
+func (s settlementTx) Create(ctx context.Context, settlement *models.Settlement) error {
+	return s.repo.Create(ctx, s.tx, settlement)
+}
+
+func (s settlementTx) CreateBatch(ctx context.Context, settlements []*models.Settlement) error {
+	return s.repo.CreateBatch(ctx, s.tx, settlements)
+}
+
+func (s settlementTx) CreateRun(ctx context.Context, run *models.SettlementRun) error {
+	return s.repo.CreateRun(ctx, s.tx, run)
+}
+
+func (s settlementTx) SupersedeRange(ctx context.Context, runID uuid.UUID, from, to time.Time, keep []*models.Settlement) error {
+	return s.repo.SupersedeRange(ctx, s.tx, runID, from, to, keep)
+}
+
+func (s settlementTx) Supersede(ctx context.Context, runID uuid.UUID, merchantID string, date time.Time) error {
+	return s.repo.Supersede(ctx, s.tx, runID, merchantID, date)
+}
+
+func (s settlementTx) MarkStale(ctx context.Context, merchantID string, date time.Time, reason string) (*models.Settlement, error) {
+	return s.repo.MarkStale(ctx, s.tx, merchantID, date, reason)
+}
+
+type forecastTx struct {
+	repo ForecastRepository
+	tx   *sql.Tx
+}
+
+func (f forecastTx) CreateRecommendations(ctx context.Context, recommendations []*models.ReorderRecommendation) error {
+	return f.repo.CreateRecommendations(ctx, f.tx, recommendations)
+}
+
+type jobTx struct {
+	repo JobRepository
+	tx   *sql.Tx
+}
+
+func (j jobTx) AcquireLock(ctx context.Context, lock *models.JobLock) error {
+	return j.repo.AcquireLock(ctx, j.tx, lock)
+}
+
+func (j jobTx) SaveFiles(ctx context.Context, jobID uuid.UUID, files []*models.JobFile) error {
+	return j.repo.SaveFiles(ctx, j.tx, jobID, files)
+}
+
+type sagaTx struct {
+	repo SagaRepository
+	tx   *sql.Tx
+}
+
+func (s sagaTx) SetProgress(ctx context.Context, id uuid.UUID, completedSteps int, status models.SagaStatus, payload []byte) error {
+	return s.repo.SetProgress(ctx, s.tx, id, completedSteps, status, payload)
+}
+
+type payoutTx struct {
+	repo PayoutRepository
+	tx   *sql.Tx
+}
+
+func (p payoutTx) GetBankAccountForUpdate(ctx context.Context, merchantID string) (*models.MerchantBankAccount, error) {
+	return p.repo.GetBankAccountForUpdate(ctx, p.tx, merchantID)
+}
+
+func (p payoutTx) Create(ctx context.Context, payout *models.Payout) error {
+	return p.repo.Create(ctx, p.tx, payout)
+}
+
+func (p payoutTx) ClaimForExport(ctx context.Context, jobID uuid.UUID, scheme models.PayoutScheme) ([]*models.PayoutExport, error) {
+	return p.repo.ClaimForExport(ctx, p.tx, jobID, scheme)
+}
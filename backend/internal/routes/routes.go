@@ -7,41 +7,292 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// apiVersion describes the routes served under one API version prefix
+type apiVersion struct {
+	name     string
+	register func(*gin.RouterGroup, *handlers.Handlers)
+}
+
+// apiVersions lists every served API version, oldest first. Adding /v2 means
+// appending an entry here; earlier versions keep their own handlers.
+var apiVersions = []apiVersion{
+	{name: "v1", register: registerV1},
+}
+
+// legacyVersion is the version served on the deprecated unprefixed routes
+const legacyVersion = "v1"
+
+// newEngine creates a Gin engine that takes the client IP from
+// X-Forwarded-For only on requests from the trusted proxies, and from the
+// connection otherwise
+func newEngine(h *handlers.Handlers) *gin.Engine {
+	router := gin.New()
+	if err := router.SetTrustedProxies(h.TrustedProxies()); err != nil {
+		// The configuration validates the proxies; trust none rather than
+		// every caller should one slip through
+		_ = router.SetTrustedProxies(nil)
+	}
+	return router
+}
+
 // SetupRoutes configures all HTTP routes
 func SetupRoutes(h *handlers.Handlers) *gin.Engine {
 	// Create Gin router
-	router := gin.New()
+	router := newEngine(h)
 
-	// Add middleware
+	// Add middleware; security headers go on every response, including
+	// sandbox ones, and sandbox requests then leave for the sandbox router
+	router.Use(h.SecurityHeaders())
+	router.Use(h.Sandbox())
 	router.Use(h.RequestID())
+	router.Use(h.Principal())
 	router.Use(h.Logger())
+	router.Use(h.Metrics())
+	router.Use(h.PayloadLogger())
 	router.Use(h.ErrorHandler())
 	router.Use(h.CORS())
+	router.Use(h.Maintenance())
 
-	// Health check
+	// Health checks: readiness with dependency checks, and bare liveness
 	router.GET("/health", h.Health)
+	router.GET("/healthz", h.Liveness)
+
+	// Webhook event catalog; event types carry their own versions, so the
+	// schemas are served outside the API versions
+	router.GET("/events/schemas", h.ListEventSchemas)
+	router.GET("/events/schemas/:type", h.GetEventSchema)
+
+	// Error catalog; problem type URIs such as /errors/out-of-stock resolve
+	// to their entry
+	router.GET("/errors", h.ListErrors)
+	router.GET("/errors/:type", h.GetError)
 
+	// GraphQL gateway
+	router.POST("/graphql", h.RequestTimeout(), h.LoadShed(10), h.GraphQL())
+
+	// Metrics and the admin API, unless the internal listener serves them
+	if !h.ServesInternal() {
+		registerInternal(router, h)
+	}
+
+	// Versioned API routes
+	for _, version := range apiVersions {
+		group := router.Group("/"+version.name, h.APIVersion(version.name))
+		version.register(group, h)
+	}
+
+	// Unprefixed routes are kept for existing clients but deprecated
+	for _, version := range apiVersions {
+		if version.name == legacyVersion {
+			legacy := router.Group("", h.APIVersion(version.name), h.Deprecated("/"+version.name))
+			version.register(legacy, h)
+		}
+	}
+
+	return router
+}
+
+// SetupInternalRoutes configures the routes of the internal listener:
+// health checks, metrics and the admin API. Requests reach it from inside
+// the network only, so there is no sandbox, CORS or maintenance mode.
+func SetupInternalRoutes(h *handlers.Handlers) *gin.Engine {
+	router := newEngine(h)
+
+	router.Use(h.SecurityHeaders())
+	router.Use(h.RequestID())
+	router.Use(h.Principal())
+	router.Use(h.Logger())
+	router.Use(h.Metrics())
+	router.Use(h.ErrorHandler())
+
+	router.GET("/health", h.Health)
+	router.GET("/healthz", h.Liveness)
+	registerInternal(router, h)
+
+	return router
+}
+
+// registerInternal registers the metrics endpoint and the admin API
+func registerInternal(router *gin.Engine, h *handlers.Handlers) {
 	// Metrics endpoint
 	router.GET("/metrics", h.MetricsHandler())
 
-	// Order routes
-	orderGroup := router.Group("/orders")
+	// Admin routes
+	adminGroup := router.Group("/admin", h.AdminOnly(), h.RequestTimeout())
+	{
+		adminGroup.GET("/debug/payload-logging", h.GetPayloadLogging)
+		adminGroup.PUT("/debug/payload-logging", h.SetPayloadLogging)
+		adminGroup.GET("/stats/orders-per-minute", h.GetOrdersPerMinute)
+		adminGroup.GET("/stats/job-throughput", h.GetJobThroughput)
+		adminGroup.GET("/stats/job-queue", h.GetJobQueue)
+		adminGroup.GET("/stats/error-rates", h.GetErrorRates)
+		adminGroup.GET("/stats/top-merchants", h.GetTopMerchants)
+		adminGroup.GET("/stats/order-analytics", h.GetOrderAnalytics)
+		adminGroup.GET("/auth/bans", h.ListAuthBans)
+		adminGroup.GET("/sagas", h.ListSagas)
+		adminGroup.GET("/sagas/:id", h.GetSaga)
+		adminGroup.GET("/maintenance", h.GetMaintenance)
+		adminGroup.PUT("/maintenance", h.SetMaintenance)
+		adminGroup.POST("/seed", h.Seed)
+		adminGroup.GET("/backfills", h.ListBackfills)
+		adminGroup.POST("/backfills", h.CreateBackfillJob)
+	}
+}
+
+// registerV1 configures the v1 API routes
+func registerV1(rg *gin.RouterGroup, h *handlers.Handlers) {
+	// Product routes; changing the catalog needs the admin token
+	productGroup := rg.Group("/products", h.RequestTimeout())
+	{
+		productGroup.GET("", h.LoadShed(10), h.ListProducts)
+		productGroup.POST("", h.AdminOnly(), h.CreateProduct)
+		productGroup.GET("/by-sku/:sku", h.GetProductBySKU)
+		productGroup.GET("/snapshot", h.LoadShed(100), h.GetProductSnapshot)
+		productGroup.PUT("/stock-sync", h.AdminOnly(), h.SyncStock)
+		productGroup.GET("/:id", h.GetProduct)
+		productGroup.PUT("/:id", h.AdminOnly(), h.UpdateProduct)
+		productGroup.DELETE("/:id", h.AdminOnly(), h.DeleteProduct)
+		productGroup.GET("/:id/movements", h.LoadShed(10), h.ListInventoryMovements)
+		productGroup.PUT("/:id/backorder-limit", h.AdminOnly(), h.SetBackorderLimit)
+	}
+
+	// Bundle routes; bundles are ordered through the order routes by their
+	// product ID, and changing them needs the admin token
+	bundleGroup := rg.Group("/bundles", h.RequestTimeout())
+	{
+		bundleGroup.GET("", h.LoadShed(10), h.ListBundles)
+		bundleGroup.POST("", h.AdminOnly(), h.CreateBundle)
+		bundleGroup.GET("/:id", h.GetBundle)
+		bundleGroup.PUT("/:id", h.AdminOnly(), h.UpdateBundle)
+	}
+
+	// Sales event routes; scheduling and deleting events needs the admin
+	// token, and orders for their products go through the order routes
+	salesEventGroup := rg.Group("/sales-events", h.RequestTimeout())
+	{
+		salesEventGroup.GET("", h.LoadShed(10), h.ListSalesEvents)
+		salesEventGroup.POST("", h.AdminOnly(), h.CreateSalesEvent)
+		salesEventGroup.GET("/:id", h.GetSalesEvent)
+		salesEventGroup.DELETE("/:id", h.AdminOnly(), h.DeleteSalesEvent)
+		salesEventGroup.GET("/:id/stats", h.GetSalesEventStats)
+	}
+
+	// Waiting room routes; a queue token admits its buyer's order for a
+	// product behind the waiting room
+	waitingRoomGroup := rg.Group("/waiting-room", h.RequestTimeout())
+	{
+		waitingRoomGroup.POST("/tokens", h.CreateQueueToken)
+	}
+
+	// Order routes; the listings shed load while the database is saturated,
+	// order creation is never degraded
+	orderGroup := rg.Group("/orders", h.RequestTimeout())
 	{
 		orderGroup.POST("", h.CreateOrder)
+		orderGroup.POST("/bulk", h.BulkCreateOrders)
+		orderGroup.GET("/count", h.LoadShed(10), h.CountOrders)
 		orderGroup.GET("/:id", h.GetOrder)
-		orderGroup.GET("", h.ListOrders)
+		orderGroup.GET("/:id/events", h.GetOrderEvents)
+		orderGroup.POST("/:id/cancel", h.CancelOrder)
+		orderGroup.GET("", h.LoadShed(10), h.ListOrders)
 	}
 
 	// Job routes
-	jobGroup := router.Group("/jobs")
+	jobGroup := rg.Group("/jobs", h.RequestTimeout())
 	{
 		jobGroup.POST("/settlement", h.CreateSettlementJob)
+		jobGroup.POST("/reorder-forecast", h.CreateReorderForecastJob)
+		jobGroup.POST("/orders-export", h.CreateOrdersExportJob)
+		jobGroup.POST("/resettle", h.CreateResettleJob)
+		jobGroup.POST("/merchant-statement", h.CreateMerchantStatementJob)
+		jobGroup.POST("/backorder-fulfillment", h.CreateBackorderFulfillmentJob)
+		jobGroup.POST("/payout-file", h.AdminOnly(), h.CreatePayoutFileJob)
+		jobGroup.GET("", h.LoadShed(10), h.ListJobs)
+		jobGroup.GET("/dead-letter", h.LoadShed(10), h.ListDeadLetteredJobs)
 		jobGroup.GET("/:id", h.GetJob)
+		jobGroup.GET("/:id/reorder-recommendations", h.GetReorderRecommendations)
+		jobGroup.GET("/:id/stats", h.GetJobStats)
+		jobGroup.GET("/:id/logs", h.GetJobLogs)
+		jobGroup.GET("/:id/files", h.ListJobFiles)
+		jobGroup.GET("/:id/files/:name", h.DownloadTimeout(), h.DownloadJobFile)
+		jobGroup.GET("/:id/merchants/:merchant_id/download", h.DownloadTimeout(), h.DownloadMerchantSettlement)
 		jobGroup.POST("/:id/cancel", h.CancelJob)
+		jobGroup.POST("/:id/redrive", h.AdminOnly(), h.RedriveJob)
 	}
 
-	// Download routes
-	router.GET("/downloads/:filename", h.DownloadSettlement)
+	// Settlement routes
+	settlementGroup := rg.Group("/settlements", h.RequestTimeout())
+	{
+		settlementGroup.GET("", h.LoadShed(10), h.ListSettlements)
+		settlementGroup.GET("/runs", h.LoadShed(10), h.ListSettlementRuns)
+		settlementGroup.GET("/runs/latest", h.GetLatestSettlementRun)
+		settlementGroup.GET("/runs/:id", h.GetSettlementRun)
+		settlementGroup.GET("/stale", h.LoadShed(10), h.ListStaleSettlements)
+		settlementGroup.POST("/stale/detect", h.AdminOnly(), h.DetectStaleSettlements)
+		settlementGroup.GET("/late-transactions", h.ListLateTransactions)
+		settlementGroup.GET("/adjustments", h.ListSettlementAdjustments)
+		settlementGroup.POST("/adjustments", h.AdminOnly(), h.CreateSettlementAdjustment)
+	}
 
-	return router
+	// Transaction routes
+	transactionGroup := rg.Group("/transactions", h.RequestTimeout())
+	{
+		transactionGroup.GET("", h.LoadShed(10), h.ListTransactions)
+		transactionGroup.GET("/sample", h.SampleTransactions)
+		transactionGroup.PATCH("/:id/status", h.AdminOnly(), h.UpdateTransactionStatus)
+	}
+
+	// Merchant routes
+	merchantGroup := rg.Group("/merchants", h.RequestTimeout())
+	{
+		merchantGroup.GET("/:id/dashboard", h.GetMerchantDashboard)
+		merchantGroup.PUT("/:id/settlement-calendar", h.AdminOnly(), h.SetMerchantCalendar)
+		merchantGroup.GET("/:id/bank-account", h.AdminOnly(), h.GetMerchantBankAccount)
+		merchantGroup.PUT("/:id/bank-account", h.AdminOnly(), h.SetMerchantBankAccount)
+	}
+
+	// Payout routes; payouts move money to merchants' bank accounts, so every
+	// route needs the admin token
+	payoutGroup := rg.Group("/payouts", h.AdminOnly())
+	{
+		payoutGroup.POST("", h.RequestTimeout(), h.CreatePayout)
+		payoutGroup.GET("", h.RequestTimeout(), h.LoadShed(10), h.ListPayouts)
+		payoutGroup.GET("/:id", h.RequestTimeout(), h.GetPayout)
+		payoutGroup.POST("/:id/approve", h.RequestTimeout(), h.ApprovePayout)
+		payoutGroup.GET("/files/:filename", h.DownloadTimeout(), h.DownloadPayoutFile)
+	}
+
+	// Buyer routes; erasure is irreversible, so it needs the admin token
+	buyerGroup := rg.Group("/buyers", h.RequestTimeout())
+	{
+		buyerGroup.DELETE("/:id/data", h.AdminOnly(), h.EraseBuyerData)
+	}
+
+	// Search routes; queries are POSTed because they carry a JSON body
+	searchGroup := rg.Group("/search", h.RequestTimeout())
+	{
+		searchGroup.POST("/:index", h.Search)
+	}
+
+	// Settlement calendar routes
+	calendarGroup := rg.Group("/calendar", h.RequestTimeout())
+	{
+		calendarGroup.GET("/holidays", h.ListHolidays)
+		calendarGroup.POST("/holidays", h.AdminOnly(), h.CreateHoliday)
+	}
+
+	// Webhook routes; endpoint secrets sign our callbacks, so managing
+	// endpoints needs the admin token. Gateway notifications are
+	// authenticated by their signature instead.
+	webhookGroup := rg.Group("/webhooks", h.RequestTimeout())
+	{
+		webhookGroup.POST("", h.AdminOnly(), h.CreateWebhookEndpoint)
+		webhookGroup.GET("", h.AdminOnly(), h.ListWebhookEndpoints)
+		webhookGroup.DELETE("/:id", h.AdminOnly(), h.DeleteWebhookEndpoint)
+		webhookGroup.POST("/:id/ping", h.AdminOnly(), h.PingWebhookEndpoint)
+		webhookGroup.POST("/payments", h.ReceivePaymentWebhook)
+	}
+
+	// Download routes get a longer deadline than the OLTP routes above
+	rg.GET("/downloads/:filename", h.DownloadTimeout(), h.DownloadSettlement)
 }
@@ -0,0 +1,237 @@
+// Package search provides a minimal Elasticsearch/OpenSearch client for the
+// product and order search indexes
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"indico-backend/internal/config"
+)
+
+// Index names, before the configured prefix
+const (
+	IndexProducts = "products"
+	IndexOrders   = "orders"
+)
+
+// Mappings holds the explicit field mappings of each index. Identifiers are
+// keywords so they match exactly; names are also analysed for full text.
+var Mappings = map[string]string{
+	IndexProducts: `{
+		"mappings": {
+			"properties": {
+				"id":         {"type": "integer"},
+				"name":       {"type": "text", "fields": {"keyword": {"type": "keyword"}}},
+				"sku":        {"type": "keyword"},
+				"barcode":    {"type": "keyword"},
+				"stock":      {"type": "integer"},
+				"price":      {"type": "integer"},
+				"version":    {"type": "integer"},
+				"created_at": {"type": "date"},
+				"updated_at": {"type": "date"}
+			}
+		}
+	}`,
+	IndexOrders: `{
+		"mappings": {
+			"properties": {
+				"id":               {"type": "keyword"},
+				"product_id":       {"type": "integer"},
+				"buyer_id":         {"type": "keyword"},
+				"quantity":         {"type": "integer"},
+				"status":           {"type": "keyword"},
+				"total_cents":      {"type": "long"},
+				"client_reference": {"type": "keyword"},
+				"created_at":       {"type": "date"},
+				"updated_at":       {"type": "date"}
+			}
+		}
+	}`,
+}
+
+// Document is one source document to index under ID
+type Document struct {
+	ID   string
+	Body interface{}
+}
+
+// Query is a search request body in the cluster's query DSL
+type Query struct {
+	Query json.RawMessage `json:"query"`
+	Sort  json.RawMessage `json:"sort,omitempty"`
+	From  int             `json:"from"`
+	Size  int             `json:"size"`
+}
+
+// Hit is one matching document
+type Hit struct {
+	ID     string          `json:"_id"`
+	Score  *float64        `json:"_score"`
+	Source json.RawMessage `json:"_source"`
+}
+
+// Result is the part of a search response the API exposes
+type Result struct {
+	Hits struct {
+		Total struct {
+			Value int `json:"value"`
+		} `json:"total"`
+		Hits []Hit `json:"hits"`
+	} `json:"hits"`
+}
+
+// Error is an error response from the cluster
+type Error struct {
+	StatusCode int
+	Type       string
+	Reason     string
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	return fmt.Sprintf("search cluster returned %d: %s: %s", e.StatusCode, e.Type, e.Reason)
+}
+
+// Client talks to the cluster's REST API
+type Client struct {
+	baseURL  string
+	username string
+	password string
+	prefix   string
+	http     *http.Client
+}
+
+// New creates a client for the configured cluster
+func New(cfg *config.SearchConfig) *Client {
+	return &Client{
+		baseURL:  strings.TrimRight(cfg.URL, "/"),
+		username: cfg.Username,
+		password: cfg.Password,
+		prefix:   cfg.IndexPrefix,
+		http:     &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// indexName returns the prefixed name of an index
+func (c *Client) indexName(index string) string {
+	return c.prefix + index
+}
+
+// EnsureIndex creates an index with its mapping unless it already exists
+func (c *Client) EnsureIndex(ctx context.Context, index string) error {
+	err := c.do(ctx, http.MethodPut, "/"+c.indexName(index), "application/json", strings.NewReader(Mappings[index]), nil)
+	if searchErr, ok := err.(*Error); ok && searchErr.Type == "resource_already_exists_exception" {
+		return nil
+	}
+	return err
+}
+
+// Bulk indexes documents, replacing any existing document with the same ID
+func (c *Client) Bulk(ctx context.Context, index string, docs []Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, doc := range docs {
+		action := map[string]map[string]string{
+			"index": {"_index": c.indexName(index), "_id": doc.ID},
+		}
+		if err := encoder.Encode(action); err != nil {
+			return fmt.Errorf("failed to encode bulk action: %w", err)
+		}
+		if err := encoder.Encode(doc.Body); err != nil {
+			return fmt.Errorf("failed to encode document %s: %w", doc.ID, err)
+		}
+	}
+
+	var response struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int `json:"status"`
+			Error  *struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/_bulk", "application/x-ndjson", &body, &response); err != nil {
+		return err
+	}
+	if !response.Errors {
+		return nil
+	}
+
+	// Report the first failure; the whole batch is retried on the next pass
+	for _, item := range response.Items {
+		for _, result := range item {
+			if result.Error != nil {
+				return &Error{StatusCode: result.Status, Type: result.Error.Type, Reason: result.Error.Reason}
+			}
+		}
+	}
+	return fmt.Errorf("bulk request reported errors")
+}
+
+// Search runs a query against an index
+func (c *Client) Search(ctx context.Context, index string, query *Query) (*Result, error) {
+	body, err := json.Marshal(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode search query: %w", err)
+	}
+
+	var result Result
+	if err := c.do(ctx, http.MethodPost, "/"+c.indexName(index)+"/_search", "application/json", bytes.NewReader(body), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Ping checks that the cluster answers
+func (c *Client) Ping(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/_cluster/health", "application/json", nil, nil)
+}
+
+// do sends a request and decodes a successful response into out, if given
+func (c *Client) do(ctx context.Context, method, path, contentType string, body io.Reader, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create search request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach search cluster: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var failure struct {
+			Error struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&failure)
+		return &Error{StatusCode: resp.StatusCode, Type: failure.Error.Type, Reason: failure.Error.Reason}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode search response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package seed
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"indico-backend/internal/models"
+)
+
+// diurnalWeights is the relative share of payments per UTC hour, shaped like
+// a typical consumer day: quiet overnight, a lunch peak, and an evening peak
+var diurnalWeights = [24]float64{
+	0.6, 0.4, 0.3, 0.2, 0.2, 0.3, // 00-05
+	0.7, 1.2, 1.8, 2.2, 2.6, 3.2, // 06-11
+	3.8, 3.6, 3.0, 2.8, 2.9, 3.2, // 12-17
+	3.6, 4.2, 4.4, 3.8, 2.6, 1.4, // 18-23
+}
+
+// distribution draws merchants, payment times, and statuses for seeded
+// transactions
+type distribution struct {
+	rng       *rand.Rand
+	merchants []string
+	zipf      *rand.Zipf
+
+	start time.Time
+	days  int
+
+	diurnal      bool
+	hourCDF      []float64
+	pendingShare float64
+	failedShare  float64
+}
+
+// newDistribution creates a distribution from seed options
+func newDistribution(rng *rand.Rand, opts *models.SeedOptions, end time.Time) (*distribution, error) {
+	if opts.Merchants < 1 {
+		return nil, fmt.Errorf("merchants must be at least 1")
+	}
+	if opts.Days < 1 {
+		return nil, fmt.Errorf("days must be at least 1")
+	}
+	if opts.PendingShare < 0 || opts.FailedShare < 0 || opts.PendingShare+opts.FailedShare > 1 {
+		return nil, fmt.Errorf("pending and failed shares must be non-negative and sum to at most 1")
+	}
+
+	d := &distribution{
+		rng:          rng,
+		merchants:    make([]string, opts.Merchants),
+		start:        end.AddDate(0, 0, -opts.Days),
+		days:         opts.Days,
+		diurnal:      opts.Diurnal,
+		pendingShare: opts.PendingShare,
+		failedShare:  opts.FailedShare,
+	}
+
+	for i := range d.merchants {
+		d.merchants[i] = fmt.Sprintf("merchant_%03d", i+1)
+	}
+
+	// rand.Zipf requires s > 1; anything lower means uniform popularity
+	if opts.ZipfS > 1 && opts.Merchants > 1 {
+		d.zipf = rand.NewZipf(rng, opts.ZipfS, 1, uint64(opts.Merchants-1))
+	}
+
+	var total float64
+	for _, w := range diurnalWeights {
+		total += w
+		d.hourCDF = append(d.hourCDF, total)
+	}
+	for i := range d.hourCDF {
+		d.hourCDF[i] /= total
+	}
+	d.hourCDF[len(d.hourCDF)-1] = 1 // guard against rounding
+
+	return d, nil
+}
+
+// merchant returns a merchant ID; with Zipf enabled merchant_001 is the most
+// popular and popularity falls off by rank
+func (d *distribution) merchant() string {
+	if d.zipf != nil {
+		return d.merchants[d.zipf.Uint64()]
+	}
+	return d.merchants[d.rng.Intn(len(d.merchants))]
+}
+
+// paidAt returns a payment time within the seeded window
+func (d *distribution) paidAt() time.Time {
+	day := d.start.AddDate(0, 0, d.rng.Intn(d.days))
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+
+	hour := d.rng.Intn(24)
+	if d.diurnal {
+		hour = sort.SearchFloat64s(d.hourCDF, d.rng.Float64())
+	}
+
+	return day.
+		Add(time.Duration(hour) * time.Hour).
+		Add(time.Duration(d.rng.Intn(3600)) * time.Second)
+}
+
+// status returns a transaction status according to the configured shares
+func (d *distribution) status() models.TransactionStatus {
+	p := d.rng.Float64()
+	switch {
+	case p < d.failedShare:
+		return models.TransactionStatusFailed
+	case p < d.failedShare+d.pendingShare:
+		return models.TransactionStatusPending
+	default:
+		return models.TransactionStatusCompleted
+	}
+}
@@ -0,0 +1,100 @@
+// Package seed generates realistic test transactions for the seeder command
+// and the admin seed endpoint
+package seed
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+)
+
+// batchSize is the number of transactions inserted per statement
+const batchSize = 1000
+
+// DefaultOptions returns the seed shape used unless overridden; Count is
+// left for the caller, which knows how much data it wants
+func DefaultOptions() models.SeedOptions {
+	return models.SeedOptions{
+		Days:         60,
+		Merchants:    10,
+		ZipfS:        1.2,
+		Diurnal:      true,
+		PendingShare: 0.02,
+		FailedShare:  0.01,
+	}
+}
+
+// Validate checks options before any rows are written
+func Validate(opts *models.SeedOptions) error {
+	_, err := newDistribution(rand.New(rand.NewSource(1)), opts, time.Now().UTC())
+	return err
+}
+
+// Transactions inserts opts.Count transactions, starting from the start-th
+// so an interrupted seed can resume, and calls onBatch with the running
+// total after each committed batch
+func Transactions(ctx context.Context, txRepo repository.TransactionWriter, opts *models.SeedOptions, start int, onBatch func(inserted int) error) error {
+	logger.Info("Seeding transactions...")
+
+	totalTransactions := opts.Count
+
+	// Create a local RNG
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	dist, err := newDistribution(rng, opts, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	logger.Infof("Seeding %d transactions over %d days for %d merchants (zipf=%.2f, diurnal=%t, pending=%.2f, failed=%.2f)",
+		totalTransactions, opts.Days, opts.Merchants, opts.ZipfS, opts.Diurnal, opts.PendingShare, opts.FailedShare)
+
+	for i := start; i < totalTransactions; i += batchSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var transactions []*models.Transaction
+
+		remaining := totalTransactions - i
+		currentBatchSize := batchSize
+		if remaining < batchSize {
+			currentBatchSize = remaining
+		}
+
+		for j := 0; j < currentBatchSize; j++ {
+			// Random amount (100 cents to 50000 cents, i.e., $1 to $500)
+			amountCents := rng.Intn(49900) + 100
+
+			// Fee is typically 2.9% + 30 cents
+			feeCents := int(float64(amountCents)*0.029) + 30
+
+			transaction := &models.Transaction{
+				MerchantID:  dist.merchant(),
+				AmountCents: amountCents,
+				FeeCents:    feeCents,
+				Status:      dist.status(),
+				PaidAt:      dist.paidAt(),
+			}
+
+			transactions = append(transactions, transaction)
+		}
+
+		// Bulk insert batch; use a background context so an interrupt never
+		// leaves a batch half-written
+		if err := txRepo.BulkCreate(context.Background(), transactions); err != nil {
+			return fmt.Errorf("failed to create transaction batch: %w", err)
+		}
+
+		if err := onBatch(i + currentBatchSize); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
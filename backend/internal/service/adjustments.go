@@ -0,0 +1,87 @@
+// Package service provides settlement adjustments
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+)
+
+// maxAdjustmentNoteLength caps the free-text note on an adjustment
+const maxAdjustmentNoteLength = 1000
+
+// CreateAdjustment posts a manual correction against a merchant's settlement
+// day. It is added to the day's net amount in exports, statements and the
+// merchant's pending payout, and stays with the day when it is re-settled.
+func (s *settlementService) CreateAdjustment(ctx context.Context, req *models.CreateSettlementAdjustmentRequest) (*models.SettlementAdjustment, error) {
+	merchantID := strings.TrimSpace(req.MerchantID)
+	if merchantID == "" {
+		return nil, errors.NewValidationError("merchant_id is required")
+	}
+
+	date, err := time.Parse("2006-01-02", req.Date)
+	if err != nil {
+		return nil, errors.NewValidationError("invalid date format, expected YYYY-MM-DD")
+	}
+
+	if req.AmountCents == 0 {
+		return nil, errors.NewValidationError("amount_cents must not be zero")
+	}
+
+	switch req.ReasonCode {
+	case models.AdjustmentReasonDispute, models.AdjustmentReasonChargeback, models.AdjustmentReasonFeeCorrection, models.AdjustmentReasonCorrection:
+	default:
+		return nil, errors.NewValidationError("invalid reason_code, expected DISPUTE, CHARGEBACK, FEE_CORRECTION or CORRECTION")
+	}
+
+	note := strings.TrimSpace(req.Note)
+	if len(note) > maxAdjustmentNoteLength {
+		return nil, errors.NewValidationError("note is too long")
+	}
+
+	adjustment := &models.SettlementAdjustment{
+		MerchantID:  merchantID,
+		Date:        date,
+		AmountCents: req.AmountCents,
+		ReasonCode:  req.ReasonCode,
+		Note:        note,
+	}
+	if err := s.settleRepo.CreateAdjustment(ctx, adjustment); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to create settlement adjustment")
+		return nil, err
+	}
+
+	logger.WithContext(ctx).
+		WithField("adjustment_id", adjustment.ID).
+		WithField("merchant_id", merchantID).
+		WithField("date", req.Date).
+		WithField("amount_cents", adjustment.AmountCents).
+		WithField("reason_code", adjustment.ReasonCode).
+		Info("Settlement adjustment created")
+
+	return adjustment, nil
+}
+
+// ListAdjustments lists adjustments by merchant and date, optionally filtered
+// by merchant and settlement date
+func (s *settlementService) ListAdjustments(ctx context.Context, req *models.ListSettlementsRequest) ([]*models.SettlementAdjustment, error) {
+	filter, err := settlementFilter(req)
+	if err != nil {
+		return nil, err
+	}
+
+	adjustments, err := s.settleRepo.ListAdjustments(ctx, filter)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to list settlement adjustments")
+		return nil, err
+	}
+	if adjustments == nil {
+		adjustments = []*models.SettlementAdjustment{}
+	}
+
+	return adjustments, nil
+}
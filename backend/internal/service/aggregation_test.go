@@ -0,0 +1,319 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"indico-backend/internal/calendar"
+	"indico-backend/internal/models"
+
+	"pgregory.net/rapid"
+)
+
+var (
+	aggregationFrom = time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	aggregationTo   = time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC)
+)
+
+// transactionGen generates completed transactions paid within
+// [aggregationFrom, aggregationTo), expressed in arbitrary UTC offsets and
+// biased towards the seconds around midnight UTC
+func transactionGen() *rapid.Generator[*models.Transaction] {
+	return rapid.Custom(func(t *rapid.T) *models.Transaction {
+		days := int(aggregationTo.Sub(aggregationFrom).Hours() / 24)
+		day := aggregationFrom.AddDate(0, 0, rapid.IntRange(0, days-1).Draw(t, "day"))
+
+		var paidAt time.Time
+		if rapid.Bool().Draw(t, "near_midnight") {
+			// First or last second of the UTC day
+			offset := time.Duration(rapid.IntRange(0, 999).Draw(t, "ms")) * time.Millisecond
+			if rapid.Bool().Draw(t, "end_of_day") {
+				paidAt = day.Add(24*time.Hour - time.Second).Add(offset)
+			} else {
+				paidAt = day.Add(offset)
+			}
+		} else {
+			paidAt = day.Add(time.Duration(rapid.Int64Range(0, int64(24*time.Hour)-1).Draw(t, "ns")))
+		}
+
+		// The same instant seen from a random zone must settle identically
+		zoneOffset := rapid.IntRange(-12*60, 14*60).Draw(t, "zone_minutes") * 60
+		paidAt = paidAt.In(time.FixedZone("gen", zoneOffset))
+
+		amount := rapid.IntRange(0, 1_000_000).Draw(t, "amount")
+		return &models.Transaction{
+			MerchantID:  rapid.SampledFrom([]string{"m1", "m2", "m3"}).Draw(t, "merchant"),
+			AmountCents: amount,
+			FeeCents:    rapid.IntRange(0, amount).Draw(t, "fee"),
+			Status:      models.TransactionStatusCompleted,
+			PaidAt:      paidAt,
+		}
+	})
+}
+
+// aggregate runs transactions through the job's aggregation one row at a
+// time, reusing a single row the way the repository's stream does
+func aggregate(transactions []*models.Transaction, book *calendar.Book, from, to time.Time) map[string]*models.Settlement {
+	settlements, _ := aggregateWithCutoff(transactions, book, from, to, 0)
+	return settlements
+}
+
+// aggregateWithCutoff is aggregate with a settlement cutoff, also returning
+// how many transactions were left out as late
+func aggregateWithCutoff(transactions []*models.Transaction, book *calendar.Book, from, to time.Time, cutoff time.Duration) (map[string]*models.Settlement, int) {
+	settlements := make(map[string]*models.Settlement)
+	generatedAt := time.Now()
+
+	var row models.Transaction
+	var late int
+	for _, tx := range transactions {
+		row = *tx
+		if accumulateSettlement(settlements, &row, book, from, to, cutoff, generatedAt) {
+			late++
+		}
+	}
+
+	return settlements, late
+}
+
+func settlementKeyFor(merchantID string, date time.Time) string {
+	return fmt.Sprintf("%s_%s", merchantID, date.UTC().Format("2006-01-02"))
+}
+
+func TestAggregationTotalsMatchInput(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		transactions := rapid.SliceOf(transactionGen()).Draw(t, "transactions")
+		settlements := aggregate(transactions, calendar.NewBook("", nil, nil), aggregationFrom, aggregationTo)
+
+		var gross, fees, count int
+		for _, tx := range transactions {
+			gross += tx.AmountCents
+			fees += tx.FeeCents
+			count++
+		}
+
+		var gotGross, gotFees, gotCount int
+		for key, s := range settlements {
+			if s.NetCents != s.GrossCents-s.FeeCents {
+				t.Fatalf("%s: net %d != gross %d - fees %d", key, s.NetCents, s.GrossCents, s.FeeCents)
+			}
+			if s.TxnCount <= 0 {
+				t.Fatalf("%s: empty settlement", key)
+			}
+			gotGross += s.GrossCents
+			gotFees += s.FeeCents
+			gotCount += s.TxnCount
+		}
+
+		if gotGross != gross {
+			t.Fatalf("gross %d != sum of amounts %d", gotGross, gross)
+		}
+		if gotFees != fees {
+			t.Fatalf("fees %d != sum of fees %d", gotFees, fees)
+		}
+		if gotCount != count {
+			t.Fatalf("txn_count %d != input rows %d", gotCount, count)
+		}
+	})
+}
+
+func TestAggregationGroupsByUTCDay(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		transactions := rapid.SliceOf(transactionGen()).Draw(t, "transactions")
+
+		settlements := aggregate(transactions, calendar.NewBook("", nil, nil), aggregationFrom, aggregationTo)
+
+		// Recompute each merchant/day independently of the job's code path
+		want := make(map[string]int)
+		rows := make(map[string]int)
+		for _, tx := range transactions {
+			key := settlementKeyFor(tx.MerchantID, tx.PaidAt)
+			want[key] += tx.AmountCents
+			rows[key]++
+		}
+
+		if len(settlements) != len(want) {
+			t.Fatalf("got %d settlements, want %d", len(settlements), len(want))
+		}
+		for key, gross := range want {
+			s, ok := settlements[key]
+			if !ok {
+				t.Fatalf("missing settlement %s", key)
+			}
+			if s.GrossCents != gross || s.TxnCount != rows[key] {
+				t.Fatalf("%s: got gross %d over %d rows, want %d over %d", key, s.GrossCents, s.TxnCount, gross, rows[key])
+			}
+			if settlementKeyFor(s.MerchantID, s.Date) != key {
+				t.Fatalf("%s: settlement dated %s", key, s.Date)
+			}
+		}
+	})
+}
+
+func TestAggregationRespectsRangeBoundaries(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		transactions := rapid.SliceOf(transactionGen()).Draw(t, "transactions")
+
+		// Settle a random sub-range; the rest must be left out entirely
+		days := int(aggregationTo.Sub(aggregationFrom).Hours() / 24)
+		first := rapid.IntRange(0, days-1).Draw(t, "first")
+		last := rapid.IntRange(first, days-1).Draw(t, "last")
+		from := aggregationFrom.AddDate(0, 0, first)
+		to := aggregationFrom.AddDate(0, 0, last+1)
+
+		settlements := aggregate(transactions, calendar.NewBook("", nil, nil), from, to)
+
+		var inRange int
+		for _, tx := range transactions {
+			if !tx.PaidAt.Before(from) && tx.PaidAt.Before(to) {
+				inRange++
+			}
+		}
+
+		var count int
+		for key, s := range settlements {
+			if s.Date.Before(from) || !s.Date.Before(to) {
+				t.Fatalf("%s: settlement dated %s outside [%s, %s)", key, s.Date, from, to)
+			}
+			count += s.TxnCount
+		}
+		if count != inRange {
+			t.Fatalf("txn_count %d != rows paid in range %d", count, inRange)
+		}
+	})
+}
+
+func TestAggregationRollsToBusinessDays(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		transactions := rapid.SliceOf(transactionGen()).Draw(t, "transactions")
+
+		holidays := []*models.Holiday{
+			{Region: "ID", Date: time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC), Name: "Holiday"},
+		}
+		book := calendar.NewBook("ID", holidays, nil)
+
+		// A window wide enough that every generated day settles inside it
+		settlements := aggregate(transactions, book, aggregationFrom, aggregationTo.AddDate(0, 0, 7))
+
+		var gross, count int
+		for _, tx := range transactions {
+			gross += tx.AmountCents
+			count++
+		}
+
+		var gotGross, gotCount int
+		for key, s := range settlements {
+			if !book.ForMerchant(s.MerchantID).IsBusinessDay(s.Date) {
+				t.Fatalf("%s: settlement dated on non-business day %s", key, s.Date.Format("Mon 2006-01-02"))
+			}
+			gotGross += s.GrossCents
+			gotCount += s.TxnCount
+		}
+
+		if gotGross != gross || gotCount != count {
+			t.Fatalf("rolling changed totals: gross %d/%d, rows %d/%d", gotGross, gross, gotCount, count)
+		}
+	})
+}
+
+func TestAggregationLeavesOutLateTransactions(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		transactions := rapid.SliceOf(transactionGen()).Draw(t, "transactions")
+
+		// Record each transaction up to three days after it was paid
+		for i, tx := range transactions {
+			delay := time.Duration(rapid.Int64Range(0, int64(72*time.Hour)).Draw(t, fmt.Sprintf("delay_%d", i)))
+			tx.CreatedAt = tx.PaidAt.Add(delay)
+		}
+
+		cutoff := 30 * time.Hour
+		settlements, late := aggregateWithCutoff(transactions, calendar.NewBook("", nil, nil), aggregationFrom, aggregationTo, cutoff)
+
+		var wantLate, wantCount int
+		for _, tx := range transactions {
+			day := time.Date(tx.PaidAt.UTC().Year(), tx.PaidAt.UTC().Month(), tx.PaidAt.UTC().Day(), 0, 0, 0, 0, time.UTC)
+			if tx.CreatedAt.After(day.Add(cutoff)) {
+				wantLate++
+			} else {
+				wantCount++
+			}
+		}
+
+		var count int
+		for key, s := range settlements {
+			if s.CutoffAt == nil || !s.CutoffAt.Equal(s.Date.Add(cutoff)) {
+				t.Fatalf("%s: cutoff %v, want %s", key, s.CutoffAt, s.Date.Add(cutoff))
+			}
+			count += s.TxnCount
+		}
+
+		if late != wantLate {
+			t.Fatalf("%d transactions left out as late, want %d", late, wantLate)
+		}
+		if count != wantCount {
+			t.Fatalf("txn_count %d != rows recorded by the cutoff %d", count, wantCount)
+		}
+	})
+}
+
+func TestDailyAggregationMatchesRows(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		transactions := rapid.SliceOf(transactionGen()).Draw(t, "transactions")
+
+		holidays := []*models.Holiday{
+			{Region: "ID", Date: time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC), Name: "Holiday"},
+		}
+		book := calendar.NewBook("ID", holidays, nil)
+
+		days := int(aggregationTo.Sub(aggregationFrom).Hours() / 24)
+		first := rapid.IntRange(0, days-1).Draw(t, "first")
+		last := rapid.IntRange(first, days-1).Draw(t, "last")
+		from := aggregationFrom.AddDate(0, 0, first)
+		to := aggregationFrom.AddDate(0, 0, last+1)
+
+		// Total each merchant's UTC paid day the way AggregateByMerchantAndDay does
+		daily := make(map[string]*models.Settlement)
+		for _, tx := range transactions {
+			paid := tx.PaidAt.UTC()
+			key := settlementKeyFor(tx.MerchantID, paid)
+			day, ok := daily[key]
+			if !ok {
+				day = &models.Settlement{
+					MerchantID: tx.MerchantID,
+					Date:       time.Date(paid.Year(), paid.Month(), paid.Day(), 0, 0, 0, 0, time.UTC),
+				}
+				daily[key] = day
+			}
+			day.GrossCents += tx.AmountCents
+			day.FeeCents += tx.FeeCents
+			day.NetCents += tx.AmountCents - tx.FeeCents
+			day.TxnCount++
+		}
+
+		got := make(map[string]*models.Settlement)
+		generatedAt := time.Now()
+		for _, day := range daily {
+			accumulateDailySettlement(got, day, book, from, to, generatedAt)
+		}
+		want := aggregate(transactions, book, from, to)
+
+		if len(got) != len(want) {
+			t.Fatalf("got %d settlements, want %d", len(got), len(want))
+		}
+		for key, w := range want {
+			g, ok := got[key]
+			if !ok {
+				t.Fatalf("missing settlement %s", key)
+			}
+			if g.GrossCents != w.GrossCents || g.FeeCents != w.FeeCents || g.NetCents != w.NetCents || g.TxnCount != w.TxnCount {
+				t.Fatalf("%s: got %d/%d/%d over %d rows, want %d/%d/%d over %d", key,
+					g.GrossCents, g.FeeCents, g.NetCents, g.TxnCount,
+					w.GrossCents, w.FeeCents, w.NetCents, w.TxnCount)
+			}
+			if g.CutoffAt != nil {
+				t.Fatalf("%s: cutoff %s without one configured", key, g.CutoffAt)
+			}
+		}
+	})
+}
@@ -0,0 +1,189 @@
+// Package service provides the order analytics projection
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"indico-backend/internal/config"
+	"indico-backend/internal/database"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/metrics"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// orderAnalyticsProjection names the order analytics cursor in projection_state
+const orderAnalyticsProjection = "order_analytics"
+
+// OrderAnalytics reports confirmed orders, units and revenue per day and
+// product, read from the order_analytics projection rather than the orders
+// table. The period defaults to the current calendar month (UTC); from and
+// to are inclusive YYYY-MM-DD dates. productID 0 includes every product.
+func (s *statsService) OrderAnalytics(ctx context.Context, from, to string, productID int) (*models.OrderAnalyticsStats, error) {
+	now := time.Now().UTC()
+	periodFrom, periodTo, err := dashboardPeriod(from, to, now)
+	if err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("order_analytics:%s:%s:%d", periodFrom.Format("2006-01-02"), periodTo.Format("2006-01-02"), productID)
+	return cached(s.cache, key, func() (*models.OrderAnalyticsStats, error) {
+		days, err := s.analyticsRepo.DailyProductSales(ctx, periodFrom, periodTo.AddDate(0, 0, 1), productID)
+		if err != nil {
+			logger.WithContext(ctx).WithError(err).Error("Failed to summarize order analytics")
+			return nil, err
+		}
+		if days == nil {
+			days = []*models.OrderAnalyticsDay{}
+		}
+
+		stats := &models.OrderAnalyticsStats{
+			PeriodFrom:  periodFrom.Format("2006-01-02"),
+			PeriodTo:    periodTo.Format("2006-01-02"),
+			ProductID:   productID,
+			Days:        days,
+			GeneratedAt: now,
+		}
+
+		cursor, err := s.analyticsRepo.GetCursor(ctx, orderAnalyticsProjection)
+		if err != nil {
+			logger.WithContext(ctx).WithError(err).Error("Failed to get order analytics cursor")
+			return nil, err
+		}
+		if !cursor.IsZero() {
+			stats.ProjectedUntil = &cursor
+		}
+
+		return stats, nil
+	})
+}
+
+// OrderProjector keeps the order_analytics projection up to date. Each pass
+// upserts the orders whose updated_at is at or after the cursor, joined with
+// their product and day, then moves the cursor to the database time minus
+// the configured overlap. Orders near the cursor are projected again on the
+// next pass, which picks up transactions that committed late; the upsert is
+// idempotent. With a leader, only the replica holding leadership projects.
+type OrderProjector struct {
+	analyticsRepo repository.AnalyticsRepository
+	config        *config.AnalyticsConfig
+	leader        *database.Leader
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewOrderProjector creates a new order projector; a nil leader projects on
+// every tick
+func NewOrderProjector(analyticsRepo repository.AnalyticsRepository, cfg *config.AnalyticsConfig, leader *database.Leader) *OrderProjector {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &OrderProjector{
+		analyticsRepo: analyticsRepo,
+		config:        cfg,
+		leader:        leader,
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+}
+
+// Start starts the projector loop
+func (p *OrderProjector) Start() {
+	logger.WithComponent("order_projector").
+		WithField("interval", p.config.ProjectionInterval.String()).
+		WithField("overlap", p.config.ProjectionOverlap.String()).
+		Info("Starting order analytics projector")
+
+	p.wg.Add(1)
+	go p.run()
+}
+
+// Stop stops the projector loop
+func (p *OrderProjector) Stop() {
+	p.cancel()
+	p.wg.Wait()
+
+	logger.WithComponent("order_projector").Info("Order analytics projector stopped")
+}
+
+func (p *OrderProjector) run() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.config.ProjectionInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			p.project()
+		}
+	}
+}
+
+// project runs one pass; a failed pass is retried from the unchanged cursor
+// on the next tick
+func (p *OrderProjector) project() {
+	log := logger.WithComponent("order_projector")
+
+	if p.leader != nil && !p.leader.IsLeader(p.ctx) {
+		log.Debug("Not the leader, skipping order projection")
+		return
+	}
+
+	projected, err := p.Project(p.ctx)
+	if err != nil {
+		metrics.ProjectionErrors.WithLabelValues(orderAnalyticsProjection).Inc()
+		log.WithError(err).Error("Failed to project orders")
+		return
+	}
+	if projected > 0 {
+		log.WithField("orders", projected).Debug("Order analytics projected")
+	}
+}
+
+// Project brings the projection up to date and returns how many orders it
+// upserted
+func (p *OrderProjector) Project(ctx context.Context) (int, error) {
+	// Read the clock before the rows so nothing committed during the pass
+	// can fall behind the new cursor
+	now, err := p.analyticsRepo.Now(ctx)
+	if err != nil {
+		return 0, err
+	}
+	cursor, err := p.analyticsRepo.GetCursor(ctx, orderAnalyticsProjection)
+	if err != nil {
+		return 0, err
+	}
+
+	afterTime, afterID := cursor, uuid.Nil
+	total := 0
+	for {
+		projected, lastTime, lastID, err := p.analyticsRepo.ProjectOrders(ctx, afterTime, afterID, p.config.ProjectionBatchSize)
+		if err != nil {
+			return total, err
+		}
+		total += projected
+		metrics.ProjectedRows.WithLabelValues(orderAnalyticsProjection).Add(float64(projected))
+
+		if projected < p.config.ProjectionBatchSize {
+			break
+		}
+		afterTime, afterID = lastTime, lastID
+	}
+
+	if next := now.Add(-p.config.ProjectionOverlap); next.After(cursor) {
+		if err := p.analyticsRepo.SaveCursor(ctx, orderAnalyticsProjection, next); err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
@@ -0,0 +1,257 @@
+// Package service provides brute-force protection of the admin token and API keys
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"indico-backend/internal/config"
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/metrics"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+)
+
+// Credentials the AuthGuard counts failures of
+const (
+	AuthCredentialAdminToken = "admin_token"
+	AuthCredentialAPIKey     = "api_key"
+)
+
+// Scopes an AuthGuard bans in: a client IP, or a credential presented from
+// any number of IPs
+const (
+	authScopeIP         = "ip"
+	authScopeCredential = "credential"
+)
+
+// AuthGuard contains credential stuffing. It counts failed attempts with
+// the admin token or an API key per client IP and per presented
+// credential, and once either reaches the configured failures within the
+// window it is banned for the ban duration: its requests are rejected
+// before their credentials are checked, even correct ones. Bans are kept
+// in memory on each replica and recorded in the auth_bans audit trail.
+type AuthGuard struct {
+	config *config.AuthConfig
+	bans   repository.AuthBanRepository
+
+	mu       sync.Mutex
+	subjects map[string]*authSubject
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// authSubject tracks the failures of one IP or credential
+type authSubject struct {
+	failures    int
+	windowStart time.Time
+	bannedUntil time.Time
+}
+
+// NewAuthGuard creates a guard recording its bans in bans
+func NewAuthGuard(cfg *config.AuthConfig, bans repository.AuthBanRepository) *AuthGuard {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &AuthGuard{
+		config:   cfg,
+		bans:     bans,
+		subjects: make(map[string]*authSubject),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Start starts dropping expired failure counts and bans
+func (g *AuthGuard) Start() {
+	logger.WithComponent("auth_guard").
+		WithField("max_failures", g.config.MaxFailures).
+		WithField("window", g.config.Window).
+		WithField("ban_duration", g.config.BanDuration).
+		Info("Starting auth guard")
+
+	g.wg.Add(1)
+	go g.run()
+}
+
+// Stop stops dropping expired failure counts and bans
+func (g *AuthGuard) Stop() {
+	g.cancel()
+	g.wg.Wait()
+}
+
+func (g *AuthGuard) run() {
+	defer g.wg.Done()
+
+	ticker := time.NewTicker(g.config.Window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-g.ctx.Done():
+			return
+		case now := <-ticker.C:
+			g.prune(now)
+		}
+	}
+}
+
+// prune forgets subjects whose window and ban are both over
+func (g *AuthGuard) prune(now time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for key, subject := range g.subjects {
+		if now.Sub(subject.windowStart) >= g.config.Window && !now.Before(subject.bannedUntil) {
+			delete(g.subjects, key)
+		}
+	}
+}
+
+// Check fails with an auth blocked error when the client IP, or the
+// credential if one was presented, is banned. A nil AuthGuard bans nothing.
+func (g *AuthGuard) Check(ip, credential string, now time.Time) error {
+	if g == nil {
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var until time.Time
+	scope := ""
+	for _, s := range g.scopes(ip, credential) {
+		if subject := g.subjects[s.key]; subject != nil && now.Before(subject.bannedUntil) && subject.bannedUntil.After(until) {
+			until = subject.bannedUntil
+			scope = s.scope
+		}
+	}
+	if scope == "" {
+		return nil
+	}
+
+	metrics.AuthBlocked.WithLabelValues(scope).Inc()
+	return errors.NewAuthBlockedError(until.Sub(now))
+}
+
+// Fail counts a failed attempt with credential, of the given kind, from ip,
+// banning the IP or the credential once it reaches the configured failures
+func (g *AuthGuard) Fail(ctx context.Context, kind, ip, credential string, now time.Time) {
+	if g == nil {
+		return
+	}
+	metrics.AuthFailures.WithLabelValues(kind).Inc()
+
+	var banned []*models.AuthBan
+	g.mu.Lock()
+	for _, s := range g.scopes(ip, credential) {
+		subject := g.subjects[s.key]
+		if subject == nil {
+			subject = &authSubject{}
+			g.subjects[s.key] = subject
+		}
+		if now.Before(subject.bannedUntil) {
+			continue
+		}
+		if now.Sub(subject.windowStart) >= g.config.Window {
+			subject.failures = 0
+			subject.windowStart = now
+		}
+
+		subject.failures++
+		if subject.failures < g.config.MaxFailures {
+			continue
+		}
+		subject.bannedUntil = now.Add(g.config.BanDuration)
+		banned = append(banned, &models.AuthBan{
+			Scope:       s.scope,
+			Subject:     s.subject,
+			Credential:  kind,
+			Failures:    subject.failures,
+			ClientIP:    ip,
+			BannedUntil: subject.bannedUntil.UTC(),
+		})
+		subject.failures = 0
+	}
+	g.mu.Unlock()
+
+	log := logger.WithContext(ctx).WithField("client_ip", ip).WithField("credential", kind)
+	log.Warn("Authentication failed")
+
+	for _, ban := range banned {
+		if requestID, ok := ctx.Value(logger.RequestIDKey).(string); ok {
+			ban.RequestID = &requestID
+		}
+
+		metrics.AuthBans.WithLabelValues(ban.Scope).Inc()
+		log.WithField("scope", ban.Scope).
+			WithField("subject", ban.Subject).
+			WithField("banned_until", ban.BannedUntil).
+			Warn("Banned after repeated failed authentication")
+
+		if err := g.bans.Record(ctx, ban); err != nil {
+			log.WithError(err).Error("Failed to record auth ban")
+		}
+	}
+}
+
+// authScope names the subject a failure counts against in one scope
+type authScope struct {
+	scope   string
+	subject string
+	key     string
+}
+
+// scopes lists the subjects of a request: its IP, and its credential when
+// one was presented
+func (g *AuthGuard) scopes(ip, credential string) []authScope {
+	scopes := []authScope{{scope: authScopeIP, subject: ip, key: authScopeIP + ":" + ip}}
+	if credential != "" {
+		fingerprint := credentialFingerprint(credential)
+		scopes = append(scopes, authScope{
+			scope:   authScopeCredential,
+			subject: fingerprint,
+			key:     authScopeCredential + ":" + fingerprint,
+		})
+	}
+	return scopes
+}
+
+// credentialFingerprint identifies a credential in memory, logs and the
+// audit trail without revealing it
+func credentialFingerprint(credential string) string {
+	sum := sha256.Sum256([]byte(credential))
+	return hex.EncodeToString(sum[:8])
+}
+
+// authService implements AuthService
+type authService struct {
+	bans repository.AuthBanRepository
+}
+
+// NewAuthService creates a new auth service
+func NewAuthService(deps *Dependencies) AuthService {
+	return &authService{bans: deps.AuthBanRepo}
+}
+
+func (s *authService) ListAuthBans(ctx context.Context, limit, offset int) ([]*models.AuthBan, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 10 // Default limit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	bans, err := s.bans.List(ctx, limit, offset)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to list auth bans")
+		return nil, err
+	}
+
+	return bans, nil
+}
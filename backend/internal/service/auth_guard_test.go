@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"indico-backend/internal/config"
+	apperrors "indico-backend/internal/errors"
+	"indico-backend/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// banLog is an auth ban repository keeping bans in memory
+type banLog struct {
+	bans []*models.AuthBan
+}
+
+func (l *banLog) Record(ctx context.Context, ban *models.AuthBan) error {
+	l.bans = append(l.bans, ban)
+	return nil
+}
+
+func (l *banLog) List(ctx context.Context, limit, offset int) ([]*models.AuthBan, error) {
+	return l.bans, nil
+}
+
+func TestAuthGuard(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	log := &banLog{}
+	g := NewAuthGuard(&config.AuthConfig{MaxFailures: 3, Window: time.Minute, BanDuration: 10 * time.Minute}, log)
+
+	// Failures spread past the window never add up to a ban
+	for i := 0; i < 4; i++ {
+		g.Fail(ctx, AuthCredentialAdminToken, "10.0.0.1", "guess", now.Add(time.Duration(i)*time.Minute))
+	}
+	require.NoError(t, g.Check("10.0.0.1", "", now.Add(4*time.Minute)))
+	assert.Empty(t, log.bans)
+
+	// The third failure within the window bans the IP, for any credential
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		g.Fail(ctx, AuthCredentialAdminToken, "10.0.0.2", fmt.Sprintf("guess-%d", i), now)
+	}
+	err := g.Check("10.0.0.2", "the-right-token", now.Add(time.Minute))
+	var appErr *apperrors.AppError
+	require.ErrorAs(t, err, &appErr)
+	assert.Equal(t, apperrors.ErrCodeAuthBlocked, appErr.Code)
+	assert.Equal(t, 9*time.Minute, appErr.RetryAfter)
+	require.NoError(t, g.Check("10.0.0.3", "the-right-token", now))
+
+	require.Len(t, log.bans, 1)
+	assert.Equal(t, authScopeIP, log.bans[0].Scope)
+	assert.Equal(t, "10.0.0.2", log.bans[0].Subject)
+	assert.Equal(t, 3, log.bans[0].Failures)
+
+	// One key tried from many IPs bans the key, without storing it
+	for i := 0; i < 3; i++ {
+		g.Fail(ctx, AuthCredentialAPIKey, fmt.Sprintf("10.1.0.%d", i), "leaked-key", now)
+	}
+	require.Error(t, g.Check("10.2.0.1", "leaked-key", now))
+	require.NoError(t, g.Check("10.2.0.1", "another-key", now))
+	require.Len(t, log.bans, 2)
+	assert.Equal(t, authScopeCredential, log.bans[1].Scope)
+	assert.Equal(t, credentialFingerprint("leaked-key"), log.bans[1].Subject)
+	assert.NotContains(t, log.bans[1].Subject, "leaked")
+
+	// Bans end, and pruning forgets them
+	require.NoError(t, g.Check("10.0.0.2", "", now.Add(10*time.Minute)))
+	g.prune(now.Add(10 * time.Minute))
+	assert.Empty(t, g.subjects)
+
+	// A nil guard lets everything through
+	var none *AuthGuard
+	none.Fail(ctx, AuthCredentialAPIKey, "10.0.0.2", "guess", now)
+	assert.NoError(t, none.Check("10.0.0.2", "guess", now))
+}
@@ -0,0 +1,186 @@
+// Package service provides backfills for expand/contract schema migrations
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"indico-backend/internal/database"
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// backfills lists the backfills of expand migrations that have not been
+// contracted yet. Add an entry alongside the expand migration, e.g.
+//
+//	(&database.ColumnRename{Table: "orders", From: "buyer_id", To: "customer_id", Type: "VARCHAR(255)"}).Backfill(),
+//
+// and remove it with the contract migration.
+var backfills = []*database.Backfill{}
+
+// RegisterBackfill makes a backfill available to BACKFILL jobs
+func (jp *JobProcessor) RegisterBackfill(b *database.Backfill) {
+	jp.backfillsMu.Lock()
+	defer jp.backfillsMu.Unlock()
+
+	jp.backfills[b.Name] = b
+}
+
+// backfill returns the registered backfill with the given name
+func (jp *JobProcessor) backfill(name string) (*database.Backfill, bool) {
+	jp.backfillsMu.RLock()
+	defer jp.backfillsMu.RUnlock()
+
+	b, ok := jp.backfills[name]
+	return b, ok
+}
+
+// backfillNames returns the names of the registered backfills in order
+func (jp *JobProcessor) backfillNames() []string {
+	jp.backfillsMu.RLock()
+	defer jp.backfillsMu.RUnlock()
+
+	names := make([]string, 0, len(jp.backfills))
+	for name := range jp.backfills {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// backfillNotFound is returned for a backfill name that is not registered
+func backfillNotFound(name string) error {
+	return errors.NewAppError(errors.ErrCodeNotFound, fmt.Sprintf("backfill %q is not registered", name), http.StatusNotFound)
+}
+
+// ListBackfills returns the registered backfills with the rows each has left
+func (s *jobService) ListBackfills(ctx context.Context) ([]*models.BackfillStatus, error) {
+	names := s.jobProcessor.backfillNames()
+
+	statuses := make([]*models.BackfillStatus, 0, len(names))
+	for _, name := range names {
+		b, _ := s.jobProcessor.backfill(name)
+		remaining, err := b.CountRemaining(ctx, s.db)
+		if err != nil {
+			logger.WithContext(ctx).WithError(err).WithField("backfill", name).Error("Failed to count backfill rows")
+			return nil, err
+		}
+		statuses = append(statuses, &models.BackfillStatus{Name: name, Remaining: remaining})
+	}
+	return statuses, nil
+}
+
+// CreateBackfillJob queues a job that runs a registered backfill to
+// completion in batches
+func (s *jobService) CreateBackfillJob(ctx context.Context, req *models.CreateBackfillJobRequest) (*models.Job, error) {
+	if _, ok := s.jobProcessor.backfill(req.Name); !ok {
+		return nil, backfillNotFound(req.Name)
+	}
+
+	paramsJSON, err := json.Marshal(models.BackfillJobParams{Name: req.Name})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job parameters: %w", err)
+	}
+
+	job := &models.Job{
+		ID:         uuid.New(),
+		Type:       models.JobTypeBackfill,
+		Status:     models.JobStatusQueued,
+		Parameters: string(paramsJSON),
+	}
+
+	if err := s.enqueue(ctx, job); err != nil {
+		return nil, err
+	}
+
+	logger.WithContext(ctx).
+		WithField("job_id", job.ID).
+		WithField("backfill", req.Name).
+		Info("Backfill job created and queued")
+
+	return job, nil
+}
+
+// processBackfillJob runs batches of a backfill until none are left. Each
+// batch commits on its own, so a cancelled or failed job keeps its progress
+// and a retry picks up the remaining rows.
+func (jp *JobProcessor) processBackfillJob(ctx context.Context, job *models.Job) error {
+	log := logger.WithJobID(job.ID.String())
+
+	var params models.BackfillJobParams
+	if err := json.Unmarshal([]byte(job.Parameters), &params); err != nil {
+		return fmt.Errorf("failed to parse job parameters: %w", err)
+	}
+
+	b, ok := jp.backfill(params.Name)
+	if !ok {
+		return backfillNotFound(params.Name)
+	}
+
+	log.WithField("backfill", params.Name).Info("Processing backfill job")
+
+	totalCount, err := b.CountRemaining(ctx, jp.db)
+	if err != nil {
+		return err
+	}
+
+	live := jp.liveState(job.ID)
+	live.setTotal(totalCount)
+
+	var processed int
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("Job processing cancelled")
+			return ctx.Err()
+		default:
+		}
+
+		cancelled, err := jp.jobRepo.IsCancelled(ctx, job.ID)
+		if err != nil {
+			log.WithError(err).Error("Failed to check job cancellation status")
+		} else if cancelled {
+			log.Info("Job was cancelled via API")
+			return errJobCancelled
+		}
+
+		batchStart := time.Now()
+		n, err := b.RunBatch(ctx, jp.db, jp.batchSize)
+		if err != nil {
+			return err
+		}
+		live.trackDB(batchStart)
+		if n == 0 {
+			break
+		}
+
+		processed += n
+		live.recordBatch(n)
+
+		// Rows written by old code during the run can push past the estimate
+		progress := 99.0
+		if totalCount > 0 && processed < totalCount {
+			progress = float64(processed) / float64(totalCount) * 100
+		}
+		if err := jp.updateProgress(ctx, job, progress, processed); err != nil {
+			log.WithError(err).Error("Failed to update job progress")
+		}
+	}
+
+	if err := jp.updateProgress(ctx, job, 100, processed); err != nil {
+		log.WithError(err).Error("Failed to update job progress")
+	}
+
+	log.WithField("backfill", params.Name).
+		WithField("rows", processed).
+		Info("Backfill job completed")
+
+	return nil
+}
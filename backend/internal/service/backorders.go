@@ -0,0 +1,230 @@
+// Package service provides product backorders
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// SetBackorderLimit sets how many units of a product may be ordered beyond
+// its stock. Orders past the stock are placed as BACKORDERED and confirmed,
+// oldest first, by a backorder fulfillment job once stock arrives.
+func (s *productService) SetBackorderLimit(ctx context.Context, id int, limit int) (*models.Product, error) {
+	err := s.uow.Do(ctx, nil, func(tx *repository.Tx) error {
+		if _, err := tx.Products.GetByIDForUpdate(ctx, id); err != nil {
+			return err
+		}
+
+		// A bundle's stock is its components'; those take backorders
+		// themselves or not at all
+		components, err := tx.Bundles.GetComponents(ctx, id)
+		if err != nil {
+			return err
+		}
+		if len(components) > 0 {
+			return errors.NewValidationError("bundles cannot take backorders")
+		}
+
+		return tx.Products.SetBackorderLimit(ctx, id, limit)
+	})
+	if err != nil {
+		if _, ok := errors.IsAppError(err); !ok {
+			logger.WithContext(ctx).WithError(err).WithField("product_id", id).Error("Failed to set backorder limit")
+		}
+		return nil, err
+	}
+
+	logger.WithContext(ctx).
+		WithField("product_id", id).
+		WithField("backorder_limit", limit).
+		Info("Backorder limit set")
+
+	return s.GetProduct(ctx, id)
+}
+
+// fulfillRestocked queues a fulfillment job for products whose stock rose
+// while they had backorders waiting. The stock is already committed, so a
+// failure is only logged; the backorders wait for the next job. A nil jobs
+// queues nothing.
+func fulfillRestocked(ctx context.Context, jobs JobService, productIDs []int) *uuid.UUID {
+	if len(productIDs) == 0 || jobs == nil {
+		return nil
+	}
+
+	job, err := jobs.CreateBackorderFulfillmentJob(ctx, &models.CreateBackorderFulfillmentJobRequest{ProductIDs: productIDs})
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to queue backorder fulfillment job")
+		return nil
+	}
+	return &job.ID
+}
+
+// CreateBackorderFulfillmentJob queues a job that confirms the backorders
+// stock now covers, for the listed products or every product with
+// backorders
+func (s *jobService) CreateBackorderFulfillmentJob(ctx context.Context, req *models.CreateBackorderFulfillmentJobRequest) (*models.Job, error) {
+	seen := make(map[int]bool, len(req.ProductIDs))
+	params := models.BackorderFulfillmentJobParams{}
+	for _, id := range req.ProductIDs {
+		if id <= 0 {
+			return nil, errors.NewValidationError("product_ids must be positive")
+		}
+		if !seen[id] {
+			seen[id] = true
+			params.ProductIDs = append(params.ProductIDs, id)
+		}
+	}
+	sort.Ints(params.ProductIDs)
+
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job parameters: %w", err)
+	}
+
+	job := &models.Job{
+		ID:         uuid.New(),
+		Type:       models.JobTypeBackorderFulfillment,
+		Status:     models.JobStatusQueued,
+		Total:      len(params.ProductIDs),
+		Parameters: string(paramsJSON),
+	}
+
+	if err := s.enqueue(ctx, job); err != nil {
+		return nil, err
+	}
+
+	logger.WithContext(ctx).
+		WithField("job_id", job.ID).
+		WithField("products", len(params.ProductIDs)).
+		Info("Backorder fulfillment job created and queued")
+
+	return job, nil
+}
+
+// processBackorderFulfillmentJob confirms backorders product by product,
+// each in its own transaction
+func (jp *JobProcessor) processBackorderFulfillmentJob(ctx context.Context, job *models.Job) error {
+	log := logger.WithJobID(job.ID.String())
+
+	var params models.BackorderFulfillmentJobParams
+	if err := json.Unmarshal([]byte(job.Parameters), &params); err != nil {
+		return fmt.Errorf("failed to parse job parameters: %w", err)
+	}
+
+	productIDs := params.ProductIDs
+	if len(productIDs) == 0 {
+		ids, err := jp.productRepo.ListBackordered(ctx)
+		if err != nil {
+			return err
+		}
+		productIDs = ids
+	}
+
+	log.WithField("products", len(productIDs)).Info("Processing backorder fulfillment job")
+
+	live := jp.liveState(job.ID)
+	live.setTotal(len(productIDs))
+
+	var confirmed int
+	for i, productID := range productIDs {
+		select {
+		case <-ctx.Done():
+			log.Info("Job processing cancelled")
+			return ctx.Err()
+		default:
+		}
+
+		n, err := jp.fulfillBackorders(ctx, productID)
+		if err == errors.ErrProductNotFound {
+			log.WithField("product_id", productID).Warn("Skipping backorders of a missing product")
+		} else if err != nil {
+			return err
+		}
+		confirmed += n
+		live.recordBatch(1)
+
+		if err := jp.updateProgress(ctx, job, float64(i+1)/float64(len(productIDs))*100, i+1); err != nil {
+			log.WithError(err).Error("Failed to update job progress")
+		}
+	}
+
+	if len(productIDs) == 0 {
+		if err := jp.updateProgress(ctx, job, 100, 0); err != nil {
+			log.WithError(err).Error("Failed to update job progress")
+		}
+	}
+
+	log.WithField("products", len(productIDs)).
+		WithField("confirmed", confirmed).
+		Info("Backorder fulfillment job completed")
+
+	return nil
+}
+
+// fulfillBackorders confirms a product's backorders oldest first while its
+// stock covers them, returning how many were confirmed. It stops at the
+// first one the stock cannot cover, so a smaller later backorder never
+// overtakes it.
+func (jp *JobProcessor) fulfillBackorders(ctx context.Context, productID int) (int, error) {
+	var confirmed int
+	err := jp.uow.Do(ctx, nil, func(tx *repository.Tx) error {
+		confirmed = 0
+
+		product, err := tx.Products.GetByIDForUpdate(ctx, productID)
+		if err != nil {
+			return err
+		}
+		orders, err := tx.Orders.ListBackorderedForUpdate(ctx, productID)
+		if err != nil {
+			return err
+		}
+
+		for _, order := range orders {
+			if product.Stock < order.Quantity {
+				break
+			}
+			if err := tx.Products.UpdateStock(ctx, product.ID, order.Quantity, product.Version); err != nil {
+				return err
+			}
+			product.Stock -= order.Quantity
+			product.Version++
+
+			if err := tx.Products.ReleaseBackorder(ctx, product.ID, order.Quantity); err != nil {
+				return err
+			}
+			if err := tx.Orders.UpdateStatus(ctx, order.ID, models.OrderStatusBackordered, models.OrderStatusConfirmed); err != nil {
+				return err
+			}
+			for _, event := range []*models.OrderEvent{
+				{OrderID: order.ID, Type: models.OrderEventStockReserved, Detail: fmt.Sprintf("product_id=%d quantity=%d", product.ID, order.Quantity)},
+				{OrderID: order.ID, Type: models.OrderEventConfirmed, Detail: "backorder fulfilled"},
+			} {
+				if err := tx.Orders.AddEvent(ctx, event); err != nil {
+					return err
+				}
+			}
+			confirmed++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if confirmed > 0 {
+		logger.WithComponent("job_processor").
+			WithField("product_id", productID).
+			WithField("confirmed", confirmed).
+			Info("Confirmed backorders")
+	}
+	return confirmed, nil
+}
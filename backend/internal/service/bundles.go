@@ -0,0 +1,169 @@
+// Package service provides product bundle management
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+)
+
+// bundleService implements BundleService
+type bundleService struct {
+	uow         repository.UnitOfWork
+	bundleRepo  repository.BundleRepository
+	productRepo repository.ProductReader
+}
+
+// NewBundleService creates a new bundle service
+func NewBundleService(deps *Dependencies) BundleService {
+	return &bundleService{
+		uow:         deps.unitOfWork(),
+		bundleRepo:  deps.BundleRepo,
+		productRepo: deps.ProductRepo,
+	}
+}
+
+// CreateBundle creates a bundle of existing products. It is ordered like
+// any product, by its ID.
+func (s *bundleService) CreateBundle(ctx context.Context, req *models.CreateBundleRequest) (*models.Bundle, error) {
+	bundle := &models.Bundle{
+		Name:       strings.TrimSpace(req.Name),
+		SKU:        req.SKU,
+		Price:      req.Price,
+		Components: req.Components,
+	}
+	if err := s.validate(ctx, bundle); err != nil {
+		return nil, err
+	}
+
+	err := s.uow.Do(ctx, nil, func(tx *repository.Tx) error {
+		return tx.Bundles.Create(ctx, bundle)
+	})
+	if err != nil {
+		if _, ok := errors.IsAppError(err); !ok {
+			logger.WithContext(ctx).WithError(err).Error("Failed to create bundle")
+		}
+		return nil, err
+	}
+
+	logger.WithContext(ctx).
+		WithField("bundle_id", bundle.ID).
+		WithField("components", len(bundle.Components)).
+		Info("Bundle created")
+
+	return s.GetBundle(ctx, bundle.ID)
+}
+
+// UpdateBundle replaces a bundle's name, price and components. Orders
+// already placed keep the components they took stock from.
+func (s *bundleService) UpdateBundle(ctx context.Context, id int, req *models.UpdateBundleRequest) (*models.Bundle, error) {
+	bundle := &models.Bundle{
+		ID:         id,
+		Name:       strings.TrimSpace(req.Name),
+		Price:      req.Price,
+		Components: req.Components,
+	}
+	if err := s.validate(ctx, bundle); err != nil {
+		return nil, err
+	}
+
+	err := s.uow.Do(ctx, nil, func(tx *repository.Tx) error {
+		return tx.Bundles.Update(ctx, bundle)
+	})
+	if err != nil {
+		if err != errors.ErrBundleNotFound {
+			logger.WithContext(ctx).WithError(err).Error("Failed to update bundle")
+		}
+		return nil, err
+	}
+
+	logger.WithContext(ctx).
+		WithField("bundle_id", bundle.ID).
+		WithField("components", len(bundle.Components)).
+		Info("Bundle updated")
+
+	return s.GetBundle(ctx, bundle.ID)
+}
+
+func (s *bundleService) GetBundle(ctx context.Context, id int) (*models.Bundle, error) {
+	bundle, err := s.bundleRepo.GetByID(ctx, id)
+	if err != nil {
+		if err != errors.ErrBundleNotFound {
+			logger.WithContext(ctx).WithError(err).Error("Failed to get bundle")
+		}
+		return nil, err
+	}
+
+	return bundle, nil
+}
+
+func (s *bundleService) ListBundles(ctx context.Context, limit, offset int) ([]*models.Bundle, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 10 // Default limit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	bundles, err := s.bundleRepo.List(ctx, limit, offset)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to list bundles")
+		return nil, err
+	}
+
+	return bundles, nil
+}
+
+// validate checks that a bundle is named and made of distinct existing
+// products that are not bundles themselves
+func (s *bundleService) validate(ctx context.Context, bundle *models.Bundle) error {
+	if bundle.Name == "" {
+		return errors.NewValidationError("name must not be blank")
+	}
+
+	ids := make([]int, 0, len(bundle.Components))
+	seen := make(map[int]bool, len(bundle.Components))
+	for _, component := range bundle.Components {
+		if component.ProductID == bundle.ID {
+			return errors.NewValidationError("a bundle cannot contain itself")
+		}
+		if seen[component.ProductID] {
+			return errors.NewValidationError(fmt.Sprintf("product %d is listed more than once", component.ProductID))
+		}
+		seen[component.ProductID] = true
+		ids = append(ids, component.ProductID)
+	}
+
+	products, err := s.productRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to get bundle components")
+		return err
+	}
+	if len(products) != len(ids) {
+		found := make(map[int]bool, len(products))
+		for _, product := range products {
+			found[product.ID] = true
+		}
+		for _, id := range ids {
+			if !found[id] {
+				return errors.NewValidationError(fmt.Sprintf("product %d does not exist", id))
+			}
+		}
+	}
+
+	bundles, err := s.bundleRepo.ListBundleIDs(ctx, ids)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to check bundle components")
+		return err
+	}
+	if len(bundles) > 0 {
+		return errors.NewValidationError(fmt.Sprintf("product %d is a bundle; bundles cannot contain bundles", bundles[0]))
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+// Package service provides business logic implementation
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+	"indico-backend/internal/validation"
+)
+
+// calendarService implements CalendarService
+type calendarService struct {
+	calendarRepo repository.CalendarRepository
+}
+
+// NewCalendarService creates a new calendar service
+func NewCalendarService(deps *Dependencies) CalendarService {
+	return &calendarService{
+		calendarRepo: deps.CalendarRepo,
+	}
+}
+
+func (s *calendarService) ListHolidays(ctx context.Context, region string) ([]*models.Holiday, error) {
+	holidays, err := s.calendarRepo.ListHolidays(ctx, normalizeRegion(region))
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to list holidays")
+		return nil, err
+	}
+
+	return holidays, nil
+}
+
+func (s *calendarService) CreateHoliday(ctx context.Context, req *models.CreateHolidayRequest) (*models.Holiday, error) {
+	date, err := time.Parse("2006-01-02", req.Date)
+	if err != nil {
+		return nil, errors.NewValidationError("invalid date format, expected YYYY-MM-DD")
+	}
+
+	region := normalizeRegion(req.Region)
+	if region == "" {
+		return nil, errors.NewValidationError("region is required")
+	}
+
+	holiday := &models.Holiday{
+		Region: region,
+		Date:   date,
+		Name:   strings.TrimSpace(req.Name),
+	}
+	if err := s.calendarRepo.CreateHoliday(ctx, holiday); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to create holiday")
+		return nil, err
+	}
+
+	logger.WithContext(ctx).
+		WithField("region", holiday.Region).
+		WithField("date", req.Date).
+		Info("Holiday created")
+
+	return holiday, nil
+}
+
+func (s *calendarService) SetMerchantCalendar(ctx context.Context, merchantID string, req *models.SetMerchantCalendarRequest) (*models.MerchantCalendar, error) {
+	merchantID = strings.TrimSpace(merchantID)
+	if merchantID == "" {
+		return nil, errors.NewValidationError("merchant id is required")
+	}
+	if err := validation.ID("merchant id", merchantID); err != nil {
+		return nil, err
+	}
+
+	region := normalizeRegion(req.Region)
+	if region == "" {
+		return nil, errors.NewValidationError("region is required")
+	}
+
+	assignment := &models.MerchantCalendar{
+		MerchantID: merchantID,
+		Region:     region,
+	}
+	if err := s.calendarRepo.SetMerchantCalendar(ctx, assignment); err != nil {
+		logger.WithContext(ctx).WithError(err).WithField("merchant_id", merchantID).Error("Failed to set merchant calendar")
+		return nil, err
+	}
+
+	return assignment, nil
+}
+
+// normalizeRegion canonicalises region codes such as "id" to "ID"
+func normalizeRegion(region string) string {
+	return strings.ToUpper(strings.TrimSpace(region))
+}
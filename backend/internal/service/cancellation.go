@@ -0,0 +1,140 @@
+// Package service provides order cancellation
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// checkCancellable reports why an order in status cannot be cancelled. A
+// PENDING order is still being placed; its saga cancels it if placing
+// fails.
+func checkCancellable(status models.OrderStatus) error {
+	switch status {
+	case models.OrderStatusConfirmed, models.OrderStatusBackordered:
+		return nil
+	case models.OrderStatusCancelled:
+		return errors.ErrOrderAlreadyCancelled
+	default:
+		return errors.NewInvalidTransitionError(string(status), string(models.OrderStatusCancelled))
+	}
+}
+
+// cancellation describes an order being cancelled in the terms its
+// placement used, so it releases what the placement reserved
+func cancellation(order *models.Order) *orderPlacement {
+	p := &orderPlacement{
+		OrderID:    order.ID,
+		ProductID:  order.ProductID,
+		BuyerID:    order.BuyerID,
+		Quantity:   order.Quantity,
+		Status:     order.Status,
+		Components: order.Components,
+	}
+	if order.SalesEventID != nil {
+		p.SalesEvent = &placementSalesEvent{ID: *order.SalesEventID}
+	}
+	return p
+}
+
+// CancelOrder cancels a CONFIRMED or BACKORDERED order and gives back what
+// it held in the same transaction: a confirmed order's units return to the
+// product's stock, or to each component of a bundle, and a backorder stops
+// counting against the product's backorder limit. The products are locked
+// before the order, as placing and fulfilling orders lock them, so the
+// three cannot deadlock. Backorders the freed stock may now cover are
+// queued for fulfillment.
+func (s *orderService) CancelOrder(ctx context.Context, id uuid.UUID) (*models.Order, error) {
+	current, err := s.orderRepo.GetByID(ctx, id)
+	if err != nil {
+		if err != errors.ErrOrderNotFound {
+			logger.WithContext(ctx).WithError(err).WithField("order_id", id).Error("Failed to get order")
+		}
+		return nil, err
+	}
+	if err := checkCancellable(current.Status); err != nil {
+		return nil, err
+	}
+
+	var p *orderPlacement
+	var restocked []int
+	err = s.uow.Do(ctx, nil, func(tx *repository.Tx) error {
+		restocked = nil
+
+		product, err := tx.Products.GetByIDForUpdate(ctx, current.ProductID)
+		if err != nil {
+			return err
+		}
+		locked := map[int]*models.Product{product.ID: product}
+		for _, component := range current.Components {
+			componentProduct, err := tx.Products.GetByIDForUpdate(ctx, component.ProductID)
+			if err != nil {
+				return err
+			}
+			locked[componentProduct.ID] = componentProduct
+		}
+
+		// The order may have been confirmed or cancelled since it was read
+		order, err := tx.Orders.GetByIDForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := checkCancellable(order.Status); err != nil {
+			return err
+		}
+		p = cancellation(order)
+
+		if order.Status == models.OrderStatusBackordered {
+			if err := tx.Products.ReleaseBackorder(ctx, product.ID, order.Quantity); err != nil {
+				return err
+			}
+			// Backorders behind this one may now be covered
+			if product.Backordered > order.Quantity {
+				restocked = append(restocked, product.ID)
+			}
+		}
+		for _, r := range p.reserved() {
+			if err := tx.Products.ReleaseStock(ctx, r.ProductID, r.Quantity); err != nil {
+				return err
+			}
+			released := fmt.Sprintf("product_id=%d quantity=%d", r.ProductID, r.Quantity)
+			if err := tx.Orders.AddEvent(ctx, p.event(models.OrderEventStockReleased, released)); err != nil {
+				return err
+			}
+			if released := locked[r.ProductID]; released != nil && released.Backordered > 0 {
+				restocked = append(restocked, r.ProductID)
+			}
+		}
+
+		if err := tx.Orders.UpdateStatus(ctx, order.ID, order.Status, models.OrderStatusCancelled); err != nil {
+			return err
+		}
+		return tx.Orders.AddEvent(ctx, p.event(models.OrderEventCancelled, fmt.Sprintf("cancelled from %s", order.Status)))
+	})
+	if err != nil {
+		if _, ok := errors.IsAppError(err); !ok {
+			logger.WithContext(ctx).WithError(err).WithField("order_id", id).Error("Failed to cancel order")
+		}
+		return nil, err
+	}
+
+	if s.fastPath != nil {
+		s.fastPath.Release(ctx, p)
+	}
+
+	logger.WithContext(ctx).
+		WithField("order_id", id).
+		WithField("product_id", p.ProductID).
+		WithField("previous_status", p.Status).
+		Info("Order cancelled")
+
+	fulfillRestocked(ctx, s.jobs, restocked)
+	return s.GetOrder(ctx, id)
+}
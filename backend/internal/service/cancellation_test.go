@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	apperrors "indico-backend/internal/errors"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeOrderReader reads the orders a fake saga placed
+type fakeOrderReader struct {
+	repository.OrderReader
+	orders *fakeOrders
+}
+
+func (f fakeOrderReader) GetByID(ctx context.Context, id uuid.UUID) (*models.Order, error) {
+	return f.orders.GetByIDForUpdate(ctx, id)
+}
+
+func newFakeCancellation(t *testing.T) (*orderService, *fakeProducts, *fakeOrders, func(productID, quantity int) uuid.UUID) {
+	sagas, products, orders, uow := newFakeOrderSagaWithUnitOfWork(5, nil)
+	s := &orderService{uow: uow, orderRepo: fakeOrderReader{orders: orders}, sagas: sagas}
+
+	place := func(productID, quantity int) uuid.UUID {
+		payload := &orderPlacement{OrderID: uuid.New(), ProductID: productID, BuyerID: "buyer_1", Quantity: quantity}
+		_, err := sagas.Run(context.Background(), models.SagaTypeOrderPlacement, payload)
+		require.NoError(t, err)
+		return payload.OrderID
+	}
+	return s, products, orders, place
+}
+
+func TestCancelOrderRestoresStock(t *testing.T) {
+	s, products, orders, place := newFakeCancellation(t)
+	id := place(1, 2)
+	require.Equal(t, 3, products.products[1].Stock)
+	orders.events = nil
+
+	order, err := s.CancelOrder(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderStatusCancelled, order.Status)
+	assert.Equal(t, 5, products.products[1].Stock)
+	assert.Equal(t, []models.OrderEventType{models.OrderEventStockReleased, models.OrderEventCancelled}, orders.events)
+
+	// Cancelling again restores nothing
+	_, err = s.CancelOrder(context.Background(), id)
+	assert.ErrorIs(t, err, apperrors.ErrOrderAlreadyCancelled)
+	assert.Equal(t, 5, products.products[1].Stock)
+
+	_, err = s.CancelOrder(context.Background(), uuid.New())
+	assert.ErrorIs(t, err, apperrors.ErrOrderNotFound)
+}
+
+func TestCancelOrderRestoresBundleComponentStock(t *testing.T) {
+	s, products, _, place := newFakeCancellation(t)
+	id := place(10, 2)
+	require.Equal(t, 1, products.products[2].Stock)
+	require.Equal(t, 3, products.products[3].Stock)
+
+	_, err := s.CancelOrder(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, 5, products.products[2].Stock)
+	assert.Equal(t, 5, products.products[3].Stock)
+}
+
+func TestCancelOrderReleasesBackorder(t *testing.T) {
+	s, products, _, place := newFakeCancellation(t)
+	id := place(4, 2)
+	require.Equal(t, 2, products.products[4].Backordered)
+
+	order, err := s.CancelOrder(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderStatusCancelled, order.Status)
+	assert.Equal(t, 0, products.products[4].Backordered)
+	assert.Equal(t, 0, products.products[4].Stock)
+}
+
+func TestCancelOrderRefusesPendingOrder(t *testing.T) {
+	s, _, orders, _ := newFakeCancellation(t)
+	id := uuid.New()
+	orders.orders[id] = &models.Order{ID: id, ProductID: 1, Quantity: 1, Status: models.OrderStatusPending}
+
+	_, err := s.CancelOrder(context.Background(), id)
+	appErr, ok := apperrors.IsAppError(err)
+	require.True(t, ok)
+	assert.Equal(t, apperrors.ErrCodeInvalidTransition, appErr.Code)
+	assert.Equal(t, models.OrderStatusPending, orders.orders[id].Status)
+}
@@ -0,0 +1,137 @@
+// Package service provides product catalog management
+package service
+
+import (
+	"context"
+	"strings"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+)
+
+// CreateProduct adds a product to the catalog with its initial stock
+func (s *productService) CreateProduct(ctx context.Context, req *models.CreateProductRequest) (*models.Product, error) {
+	product := &models.Product{
+		Name:    strings.TrimSpace(req.Name),
+		SKU:     req.SKU,
+		Barcode: req.Barcode,
+		Stock:   req.Stock,
+		Price:   req.Price,
+	}
+	if product.Name == "" {
+		return nil, errors.NewValidationError("name must not be blank")
+	}
+
+	if err := s.productRepo.Create(ctx, product); err != nil {
+		if _, ok := errors.IsAppError(err); !ok {
+			logger.WithContext(ctx).WithError(err).Error("Failed to create product")
+		}
+		return nil, err
+	}
+
+	logger.WithContext(ctx).
+		WithField("product_id", product.ID).
+		WithField("stock", product.Stock).
+		Info("Product created")
+
+	return s.GetProduct(ctx, product.ID)
+}
+
+// UpdateProduct replaces a product's name, SKU, barcode and price, and its
+// stock when given. A stock change is recorded as an inventory movement,
+// and raising the stock of a product with backorders waiting queues a job
+// to confirm them. Bundles keep no stock of their own, so theirs can't be
+// set.
+func (s *productService) UpdateProduct(ctx context.Context, id int, req *models.UpdateProductRequest) (*models.Product, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.NewValidationError("name must not be blank")
+	}
+
+	var product *models.Product
+	var restocked []int
+
+	err := s.uow.Do(ctx, nil, func(tx *repository.Tx) error {
+		restocked = nil
+
+		current, err := tx.Products.GetByIDForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		product = &models.Product{
+			ID:      id,
+			Name:    name,
+			SKU:     req.SKU,
+			Barcode: req.Barcode,
+			Stock:   current.Stock,
+			Price:   req.Price,
+		}
+		if req.Stock != nil && *req.Stock != current.Stock {
+			components, err := tx.Bundles.GetComponents(ctx, id)
+			if err != nil {
+				return err
+			}
+			if len(components) > 0 {
+				return errors.NewValidationError("bundles keep no stock; set their components' stock instead")
+			}
+			product.Stock = *req.Stock
+		}
+
+		if err := tx.Products.Update(ctx, product); err != nil {
+			return err
+		}
+		if product.Stock == current.Stock {
+			return nil
+		}
+
+		if current.Backordered > 0 && product.Stock > current.Stock {
+			restocked = []int{id}
+		}
+		movement := &models.InventoryMovement{
+			ProductID:     id,
+			PreviousStock: current.Stock,
+			NewStock:      product.Stock,
+			Delta:         product.Stock - current.Stock,
+			Reason:        models.MovementReasonAdjustment,
+		}
+		if product.SKU != nil {
+			movement.SKU = *product.SKU
+		}
+		return tx.Products.CreateMovements(ctx, []*models.InventoryMovement{movement})
+	})
+	if err != nil {
+		if _, ok := errors.IsAppError(err); !ok {
+			logger.WithContext(ctx).WithError(err).WithField("product_id", id).Error("Failed to update product")
+		}
+		return nil, err
+	}
+
+	logger.WithContext(ctx).
+		WithField("product_id", id).
+		WithField("stock", product.Stock).
+		Info("Product updated")
+
+	fulfillRestocked(ctx, s.jobs, restocked)
+	return s.GetProduct(ctx, id)
+}
+
+// DeleteProduct removes a product from the catalog. Products with orders,
+// bundle components and products in a sales event are kept, so order
+// history stays intact.
+func (s *productService) DeleteProduct(ctx context.Context, id int) error {
+	err := s.uow.Do(ctx, nil, func(tx *repository.Tx) error {
+		return tx.Products.Delete(ctx, id)
+	})
+	if err != nil {
+		if _, ok := errors.IsAppError(err); !ok {
+			logger.WithContext(ctx).WithError(err).WithField("product_id", id).Error("Failed to delete product")
+		}
+		return err
+	}
+
+	logger.WithContext(ctx).WithField("product_id", id).Info("Product deleted")
+	return nil
+}
@@ -0,0 +1,153 @@
+// Package service provides buyer data erasure
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+	"indico-backend/internal/validation"
+
+	"github.com/google/uuid"
+)
+
+// erasedBuyerPrefix starts the pseudonym that replaces an erased buyer's ID
+const erasedBuyerPrefix = "erased-"
+
+// CreateBuyerErasureJob queues a job that replaces a buyer's ID everywhere it
+// is stored with a pseudonym. Orders keep their amounts, so revenue and
+// settlement figures are unchanged; only the link to the person is removed.
+func (s *jobService) CreateBuyerErasureJob(ctx context.Context, buyerID string) (*models.Job, error) {
+	if strings.TrimSpace(buyerID) == "" {
+		return nil, errors.NewValidationError("buyer_id must be 1-255 characters")
+	}
+	if err := validation.ID("buyer_id", buyerID); err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(buyerID, erasedBuyerPrefix) {
+		return nil, errors.NewValidationError("buyer has already been erased")
+	}
+
+	jobID := uuid.New()
+	params := models.BuyerErasureJobParams{
+		BuyerID:   buyerID,
+		Pseudonym: erasedBuyerPrefix + jobID.String(),
+	}
+
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job parameters: %w", err)
+	}
+
+	job := &models.Job{
+		ID:         jobID,
+		Type:       models.JobTypeBuyerErasure,
+		Status:     models.JobStatusQueued,
+		Parameters: string(paramsJSON),
+	}
+
+	if err := s.enqueue(ctx, job); err != nil {
+		return nil, err
+	}
+
+	// The buyer ID is deliberately left out of the log
+	logger.WithContext(ctx).
+		WithField("job_id", job.ID).
+		WithField("pseudonym", params.Pseudonym).
+		Info("Buyer erasure job created and queued")
+
+	return job, nil
+}
+
+// processBuyerErasureJob anonymizes a buyer's orders in batches, then saga
+// payloads and job parameters, including this job's own. Order exports
+// filtered to the buyer have their files deleted.
+func (jp *JobProcessor) processBuyerErasureJob(ctx context.Context, job *models.Job) error {
+	log := logger.WithJobID(job.ID.String())
+
+	var params models.BuyerErasureJobParams
+	if err := json.Unmarshal([]byte(job.Parameters), &params); err != nil {
+		return fmt.Errorf("failed to parse job parameters: %w", err)
+	}
+
+	// An earlier erasure of the same buyer already replaced the ID here
+	if strings.HasPrefix(params.BuyerID, erasedBuyerPrefix) {
+		log.Info("Buyer was already erased")
+		return nil
+	}
+
+	log.WithField("pseudonym", params.Pseudonym).Info("Processing buyer erasure job")
+
+	totalCount, err := jp.orderRepo.CountForExport(ctx, &models.OrderExportFilter{BuyerID: params.BuyerID})
+	if err != nil {
+		return fmt.Errorf("failed to count orders: %w", err)
+	}
+
+	live := jp.liveState(job.ID)
+	live.setTotal(totalCount)
+
+	var orders int
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("Job processing cancelled")
+			return ctx.Err()
+		default:
+		}
+
+		batchStart := time.Now()
+		n, err := jp.orderRepo.AnonymizeBuyer(ctx, params.BuyerID, params.Pseudonym, jp.batchSize)
+		if err != nil {
+			return err
+		}
+		live.trackDB(batchStart)
+		if n == 0 {
+			break
+		}
+
+		orders += n
+		live.recordBatch(n)
+
+		progress := 99.0
+		if totalCount > 0 && orders < totalCount {
+			progress = float64(orders) / float64(totalCount) * 100
+		}
+		if err := jp.updateProgress(ctx, job, progress, orders); err != nil {
+			log.WithError(err).Error("Failed to update job progress")
+		}
+	}
+
+	sagas, err := jp.sagaRepo.AnonymizeBuyer(ctx, params.BuyerID, params.Pseudonym)
+	if err != nil {
+		return err
+	}
+
+	// Scrubs this job's parameters too, so this runs last
+	exports, err := jp.jobRepo.AnonymizeBuyer(ctx, params.BuyerID, params.Pseudonym)
+	if err != nil {
+		return err
+	}
+	for _, path := range exports {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.WithError(err).WithField("file_path", path).Error("Failed to delete export file")
+		}
+	}
+
+	if err := jp.updateProgress(ctx, job, 100, orders); err != nil {
+		log.WithError(err).Error("Failed to update job progress")
+	}
+
+	log.WithField("pseudonym", params.Pseudonym).
+		WithField("orders", orders).
+		WithField("sagas", sagas).
+		WithField("export_files", len(exports)).
+		Info("Buyer erasure job completed")
+
+	return nil
+}
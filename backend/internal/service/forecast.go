@@ -0,0 +1,243 @@
+// Package service provides reorder point forecasting
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"indico-backend/internal/csvsafe"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+)
+
+// Default reorder forecast parameters
+const (
+	defaultLookbackDays = 28
+	defaultLeadTimeDays = 7
+	defaultSafetyDays   = 3
+	defaultReviewDays   = 14
+	defaultEMAAlpha     = 0.3
+	forecastPageSize    = 1000
+)
+
+// applyForecastDefaults fills in unset reorder forecast parameters
+func applyForecastDefaults(params *models.ReorderForecastJobParams) {
+	if params.Model == "" {
+		params.Model = models.ForecastModelSMA
+	}
+	if params.LookbackDays <= 0 {
+		params.LookbackDays = defaultLookbackDays
+	}
+	if params.LeadTimeDays <= 0 {
+		params.LeadTimeDays = defaultLeadTimeDays
+	}
+	if params.SafetyDays <= 0 {
+		params.SafetyDays = defaultSafetyDays
+	}
+	if params.ReviewDays <= 0 {
+		params.ReviewDays = defaultReviewDays
+	}
+	if params.Model == models.ForecastModelEMA && params.Alpha <= 0 {
+		params.Alpha = defaultEMAAlpha
+	}
+}
+
+// forecastDailyVelocity estimates units sold per day from a daily sales
+// series ordered oldest first
+func forecastDailyVelocity(series []int, model models.ForecastModel, alpha float64) float64 {
+	if len(series) == 0 {
+		return 0
+	}
+
+	switch model {
+	case models.ForecastModelEMA:
+		velocity := float64(series[0])
+		for _, units := range series[1:] {
+			velocity = alpha*float64(units) + (1-alpha)*velocity
+		}
+		return velocity
+	default:
+		total := 0
+		for _, units := range series {
+			total += units
+		}
+		return float64(total) / float64(len(series))
+	}
+}
+
+// recommendReorder computes the reorder point for a product and how many
+// units to order when current stock has fallen to or below it
+func recommendReorder(velocity float64, stock int, params *models.ReorderForecastJobParams) (int, int) {
+	reorderPoint := int(math.Ceil(velocity * float64(params.LeadTimeDays+params.SafetyDays)))
+	if velocity == 0 || stock > reorderPoint {
+		return reorderPoint, 0
+	}
+
+	target := int(math.Ceil(velocity * float64(params.LeadTimeDays+params.SafetyDays+params.ReviewDays)))
+	quantity := target - stock
+	if quantity < 0 {
+		quantity = 0
+	}
+	return reorderPoint, quantity
+}
+
+// processReorderForecastJob processes a reorder forecast job
+func (jp *JobProcessor) processReorderForecastJob(ctx context.Context, job *models.Job) error {
+	log := logger.WithJobID(job.ID.String())
+
+	// Parse job parameters
+	var params models.ReorderForecastJobParams
+	if err := json.Unmarshal([]byte(job.Parameters), &params); err != nil {
+		return fmt.Errorf("failed to parse job parameters: %w", err)
+	}
+	applyForecastDefaults(&params)
+
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	from := to.AddDate(0, 0, -params.LookbackDays)
+
+	log.WithField("model", params.Model).
+		WithField("from", from).
+		WithField("to", to).
+		Info("Processing reorder forecast job")
+
+	// Build a zero-filled daily series per product over the lookback window
+	sales, err := jp.orderRepo.DailySalesByProduct(ctx, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to get daily sales: %w", err)
+	}
+
+	series := make(map[int][]int)
+	for _, sale := range sales {
+		daily, ok := series[sale.ProductID]
+		if !ok {
+			daily = make([]int, params.LookbackDays)
+			series[sale.ProductID] = daily
+		}
+		day := int(sale.Date.UTC().Sub(from).Hours() / 24)
+		if day >= 0 && day < len(daily) {
+			daily[day] += sale.Quantity
+		}
+	}
+
+	// Compute recommendations for every product, page by page
+	live := jp.liveState(job.ID)
+	var recommendations []*models.ReorderRecommendation
+	for offset := 0; ; offset += forecastPageSize {
+		select {
+		case <-ctx.Done():
+			log.Info("Job processing cancelled")
+			return ctx.Err()
+		default:
+		}
+
+		fetchStart := time.Now()
+		products, err := jp.productRepo.List(ctx, forecastPageSize, offset)
+		if err != nil {
+			return fmt.Errorf("failed to list products: %w", err)
+		}
+		live.trackDB(fetchStart)
+		if len(products) == 0 {
+			break
+		}
+
+		for _, product := range products {
+			daily, ok := series[product.ID]
+			if !ok {
+				daily = make([]int, params.LookbackDays)
+			}
+
+			velocity := forecastDailyVelocity(daily, params.Model, params.Alpha)
+			reorderPoint, quantity := recommendReorder(velocity, product.Stock, &params)
+
+			recommendations = append(recommendations, &models.ReorderRecommendation{
+				JobID:               job.ID,
+				ProductID:           product.ID,
+				DailyVelocity:       velocity,
+				CurrentStock:        product.Stock,
+				ReorderPoint:        reorderPoint,
+				RecommendedQuantity: quantity,
+			})
+		}
+
+		live.setTotal(len(recommendations))
+		live.recordBatch(len(products))
+	}
+
+	// Save recommendations so they can be queried via the API
+	saveStart := time.Now()
+	if err := jp.uow.Do(ctx, nil, func(tx *repository.Tx) error {
+		return tx.Forecasts.CreateRecommendations(ctx, recommendations)
+	}); err != nil {
+		return fmt.Errorf("failed to save reorder recommendations: %w", err)
+	}
+	live.trackDB(saveStart)
+
+	if err := jp.updateProgress(ctx, job, 100, len(recommendations)); err != nil {
+		log.WithError(err).Error("Failed to update job progress")
+	}
+
+	// Create CSV file
+	csvPath, downloadURL, err := jp.resultLocation(job.ID, "csv")
+	if err != nil {
+		return err
+	}
+	if err := jp.createReorderCSV(recommendations, csvPath); err != nil {
+		return fmt.Errorf("failed to create CSV: %w", err)
+	}
+
+	if err := jp.jobRepo.UpdateResult(ctx, job.ID, csvPath, downloadURL); err != nil {
+		return fmt.Errorf("failed to update job result: %w", err)
+	}
+
+	log.WithField("products_count", len(recommendations)).
+		WithField("csv_path", csvPath).
+		Info("Reorder forecast job completed")
+
+	return nil
+}
+
+// createReorderCSV creates a CSV file from reorder recommendations
+func (jp *JobProcessor) createReorderCSV(recommendations []*models.ReorderRecommendation, filePath string) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create CSV file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csvsafe.NewWriter(file)
+	defer writer.Flush()
+
+	// Write CSV header
+	header := []string{
+		"product_id",
+		"daily_velocity",
+		"current_stock",
+		"reorder_point",
+		"recommended_quantity",
+	}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	// Write recommendation data
+	for _, rec := range recommendations {
+		record := []string{
+			strconv.Itoa(rec.ProductID),
+			strconv.FormatFloat(rec.DailyVelocity, 'f', 4, 64),
+			strconv.Itoa(rec.CurrentStock),
+			strconv.Itoa(rec.ReorderPoint),
+			strconv.Itoa(rec.RecommendedQuantity),
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV record: %w", err)
+		}
+	}
+
+	return nil
+}
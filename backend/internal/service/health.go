@@ -0,0 +1,126 @@
+// Package service provides dependency health checks
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"indico-backend/internal/config"
+	"indico-backend/internal/models"
+)
+
+// defaultHealthCheckTimeout bounds each check when no health config is given
+const defaultHealthCheckTimeout = 2 * time.Second
+
+var startTime = time.Now()
+
+// healthCheck is one dependency check
+type healthCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// healthService implements HealthService
+type healthService struct {
+	checks []healthCheck
+	config *config.HealthConfig
+}
+
+// NewHealthService creates a new health service checking the database, the
+// job result storage, and Redis and the search cluster when they are used
+func NewHealthService(deps *Dependencies) HealthService {
+	cfg := deps.HealthConfig
+	if cfg == nil {
+		cfg = &config.HealthConfig{CheckTimeout: defaultHealthCheckTimeout}
+	}
+
+	s := &healthService{config: cfg}
+	s.checks = append(s.checks,
+		healthCheck{name: "database", check: deps.DB.Health},
+		healthCheck{name: "storage", check: deps.resultStore().Ping},
+	)
+	if deps.FastPath != nil {
+		s.checks = append(s.checks, healthCheck{name: "redis", check: deps.FastPath.Ping})
+	}
+	if deps.Search != nil {
+		s.checks = append(s.checks, healthCheck{name: "search", check: deps.Search.Ping})
+	}
+
+	return s
+}
+
+// Check runs every dependency check in parallel, each under its own timeout
+// derived from ctx, so the probe's deadline applies to all of them and one
+// slow dependency can't hold up the others
+func (s *healthService) Check(ctx context.Context) (*models.HealthCheck, error) {
+	results := make(map[string]models.HealthCheckResult, len(s.checks))
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, hc := range s.checks {
+		wg.Add(1)
+		go func(hc healthCheck) {
+			defer wg.Done()
+
+			result := runHealthCheck(ctx, hc, s.config.Timeout(hc.name))
+
+			mu.Lock()
+			results[hc.name] = result
+			mu.Unlock()
+		}(hc)
+	}
+	wg.Wait()
+
+	// Checks keeps the one-line summary per dependency older clients read
+	status := "healthy"
+	checks := make(map[string]string, len(results))
+	for name, result := range results {
+		checks[name] = result.Status
+		if result.Error != "" {
+			checks[name] += ": " + result.Error
+		}
+		if result.Status != "healthy" {
+			status = "unhealthy"
+		}
+	}
+
+	return &models.HealthCheck{
+		Status:    status,
+		Version:   "1.0.0",
+		Checks:    checks,
+		Results:   results,
+		Uptime:    time.Since(startTime).String(),
+		Timestamp: time.Now(),
+	}, nil
+}
+
+// runHealthCheck runs one check under timeout and times it. A check that
+// ignores its context is abandoned at the timeout rather than awaited.
+func runHealthCheck(ctx context.Context, hc healthCheck, timeout time.Duration) models.HealthCheckResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- hc.check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := models.HealthCheckResult{
+		Status:    "healthy",
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = "unhealthy"
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"indico-backend/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthChecksRunInParallelUnderTheirTimeouts(t *testing.T) {
+	hang := func(ctx context.Context) error {
+		// Ignores its context, like a stuck filesystem call
+		time.Sleep(time.Second)
+		return nil
+	}
+	slow := func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+			return nil
+		}
+	}
+
+	s := &healthService{
+		checks: []healthCheck{
+			{name: "database", check: slow},
+			{name: "search", check: slow},
+			{name: "storage", check: hang},
+			{name: "redis", check: func(ctx context.Context) error { return errors.New("connection refused") }},
+		},
+		config: &config.HealthConfig{
+			CheckTimeout:  200 * time.Millisecond,
+			CheckTimeouts: map[string]time.Duration{"search": 10 * time.Millisecond},
+		},
+	}
+
+	start := time.Now()
+	health, err := s.Check(context.Background())
+	require.NoError(t, err)
+
+	// The checks overlap, and the one ignoring its context is abandoned
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, "unhealthy", health.Status)
+
+	assert.Equal(t, "healthy", health.Results["database"].Status)
+	assert.GreaterOrEqual(t, health.Results["database"].LatencyMS, 50.0)
+	assert.Equal(t, "healthy", health.Checks["database"])
+
+	// The per-check override cuts the search check short
+	assert.Equal(t, "unhealthy", health.Results["search"].Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), health.Results["search"].Error)
+
+	assert.Equal(t, "unhealthy", health.Results["storage"].Status)
+	assert.Equal(t, "unhealthy: connection refused", health.Checks["redis"])
+}
+
+func TestHealthChecksShareTheCallersDeadline(t *testing.T) {
+	s := &healthService{
+		checks: []healthCheck{{name: "database", check: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}}},
+		config: &config.HealthConfig{CheckTimeout: time.Minute},
+	}
+
+	// An orchestrator probe with a short deadline is not outlived by the
+	// longer check timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	health, err := s.Check(ctx)
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, "unhealthy", health.Status)
+}
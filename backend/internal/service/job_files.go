@@ -0,0 +1,261 @@
+// Package service provides multi-file job outputs
+package service
+
+import (
+	"archive/zip"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+	"indico-backend/internal/storage"
+	"indico-backend/internal/validation"
+
+	"github.com/google/uuid"
+)
+
+// resultKey is the storage key of a job's single output file; it depends
+// only on the job, so no client input ever becomes part of a path
+func resultKey(jobID uuid.UUID, ext string) string {
+	return jobID.String() + "." + ext
+}
+
+// jobFileKey is the storage key of one output file of a job that writes
+// several, named as the job recorded it
+func jobFileKey(jobID uuid.UUID, name string) string {
+	return jobID.String() + "/" + name
+}
+
+// jobFileURL is where one output file of a job can be downloaded
+func jobFileURL(jobID uuid.UUID, name string) string {
+	return fmt.Sprintf("/v1/jobs/%s/files/%s", jobID, url.PathEscape(name))
+}
+
+// jobFileLocation ensures the job's output directory exists and returns the
+// path for one of its files
+func (jp *JobProcessor) jobFileLocation(jobID uuid.UUID, name string) (string, error) {
+	dir := filepath.Join(jp.files.Dir(), jobID.String())
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create result directory: %w", err)
+	}
+	return filepath.Join(jp.files.Dir(), filepath.FromSlash(jobFileKey(jobID, name))), nil
+}
+
+// writeJobFile creates one output file of a job, filling it with write
+func (jp *JobProcessor) writeJobFile(jobID uuid.UUID, name, contentType string, write func(io.Writer) error) (*models.JobFile, error) {
+	path, err := jp.jobFileLocation(jobID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", name, err)
+	}
+	defer file.Close()
+
+	if err := write(file); err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", name, err)
+	}
+	if err := file.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", name, err)
+	}
+
+	return &models.JobFile{
+		Name:        name,
+		Path:        path,
+		ContentType: contentType,
+		SizeBytes:   info.Size(),
+	}, nil
+}
+
+// saveJobFiles records a job's output files and points its download URL at
+// a ZIP of all of them, built when it is downloaded
+func (jp *JobProcessor) saveJobFiles(ctx context.Context, jobID uuid.UUID, files []*models.JobFile) error {
+	err := jp.uow.Do(ctx, nil, func(tx *repository.Tx) error {
+		return tx.Jobs.SaveFiles(ctx, jobID, files)
+	})
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(jp.files.Dir(), jobID.String())
+	if err := jp.jobRepo.UpdateResult(ctx, jobID, dir, fmt.Sprintf("/v1/downloads/%s.zip", jobID)); err != nil {
+		return fmt.Errorf("failed to update job result: %w", err)
+	}
+	return nil
+}
+
+// ListJobFiles returns the output files of a job that produces several
+func (s *jobService) ListJobFiles(ctx context.Context, id uuid.UUID) ([]*models.JobFile, error) {
+	if _, err := s.jobRepo.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.jobFiles(ctx, id)
+}
+
+// jobFiles loads a job's output files with their download URLs
+func (s *jobService) jobFiles(ctx context.Context, id uuid.UUID) ([]*models.JobFile, error) {
+	files, err := s.jobRepo.ListFiles(ctx, id)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).WithField("job_id", id).Error("Failed to list job files")
+		return nil, err
+	}
+
+	for _, file := range files {
+		file.DownloadURL = jobFileURL(id, file.Name)
+	}
+	return files, nil
+}
+
+// OpenJobFile opens one output file of a job
+func (s *jobService) OpenJobFile(ctx context.Context, id uuid.UUID, name string) (io.ReadCloser, *models.JobFile, error) {
+	files, err := s.ListJobFiles(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, file := range files {
+		if file.Name != name {
+			continue
+		}
+
+		reader, err := s.open(jobFileKey(id, file.Name))
+		if err != nil {
+			return nil, nil, err
+		}
+		return reader, file, nil
+	}
+
+	return nil, nil, errors.ErrFileNotFound
+}
+
+// OpenJobResult opens the single output file of a job, the one its
+// download URL names. ext is checked against that URL and the file is
+// looked up by the job's own storage key, never by a client-supplied path.
+func (s *jobService) OpenJobResult(ctx context.Context, id uuid.UUID, ext string) (storage.Object, *models.JobFile, error) {
+	job, err := s.jobRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key := resultKey(id, strings.TrimPrefix(ext, "."))
+	if job.DownloadURL == nil || path.Base(*job.DownloadURL) != key {
+		return nil, nil, errors.ErrFileNotFound
+	}
+
+	object, err := s.open(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := object.Stat()
+	if err != nil {
+		object.Close()
+		return nil, nil, fmt.Errorf("failed to stat job result: %w", err)
+	}
+
+	return object, &models.JobFile{
+		Name:      key,
+		SizeBytes: info.Size(),
+		CreatedAt: info.ModTime(),
+	}, nil
+}
+
+// open opens a stored job file, reporting a missing one as ErrFileNotFound
+func (s *jobService) open(key string) (storage.Object, error) {
+	object, err := s.files.Open(key)
+	if err != nil {
+		if stderrors.Is(err, fs.ErrNotExist) {
+			return nil, errors.ErrFileNotFound
+		}
+		return nil, fmt.Errorf("failed to open job file: %w", err)
+	}
+	return object, nil
+}
+
+// OpenMerchantSettlement opens one merchant's file of a settlement job split
+// by merchant. The format is taken from the files the job recorded rather
+// than from its parameters, so the file is found however the job was run.
+func (s *jobService) OpenMerchantSettlement(ctx context.Context, id uuid.UUID, merchantID string) (io.ReadCloser, *models.JobFile, error) {
+	if err := validation.ID("merchant_id", merchantID); err != nil {
+		return nil, nil, err
+	}
+
+	files, err := s.ListJobFiles(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, format := range []models.ExportFormat{models.ExportFormatCSV, models.ExportFormatJSON, models.ExportFormatParquet} {
+		name := MerchantSettlementFilename(merchantID, format)
+		for _, file := range files {
+			if file.Name != name {
+				continue
+			}
+
+			reader, err := s.open(jobFileKey(id, file.Name))
+			if err != nil {
+				return nil, nil, err
+			}
+			return reader, file, nil
+		}
+	}
+
+	return nil, nil, errors.ErrFileNotFound
+}
+
+// WriteJobFilesZip streams a job's files into a ZIP written to w. The
+// archive is built as it is sent, so nothing but the individual files is
+// stored.
+func (s *jobService) WriteJobFilesZip(w io.Writer, id uuid.UUID, files []*models.JobFile) error {
+	archive := zip.NewWriter(w)
+
+	for _, file := range files {
+		if err := s.addZipEntry(archive, id, file); err != nil {
+			return err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to finish ZIP: %w", err)
+	}
+	return nil
+}
+
+// addZipEntry copies one of a job's files into a ZIP archive
+func (s *jobService) addZipEntry(archive *zip.Writer, id uuid.UUID, file *models.JobFile) error {
+	src, err := s.files.Open(jobFileKey(id, file.Name))
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", file.Name, err)
+	}
+	defer src.Close()
+
+	entry, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     file.Name,
+		Method:   zip.Deflate,
+		Modified: file.CreatedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add %s: %w", file.Name, err)
+	}
+
+	if _, err := io.Copy(entry, src); err != nil {
+		return fmt.Errorf("failed to write %s: %w", file.Name, err)
+	}
+	return nil
+}
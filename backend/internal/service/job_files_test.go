@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+	"indico-backend/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordedFiles is a job repository holding one job and the files it recorded
+type recordedFiles struct {
+	repository.JobRepository
+	job   *models.Job
+	files []*models.JobFile
+}
+
+func (r *recordedFiles) GetByID(ctx context.Context, id uuid.UUID) (*models.Job, error) {
+	if id != r.job.ID {
+		return nil, errors.ErrJobNotFound
+	}
+	return r.job, nil
+}
+
+func (r *recordedFiles) ListFiles(ctx context.Context, jobID uuid.UUID) ([]*models.JobFile, error) {
+	return r.files, nil
+}
+
+func TestOpenMerchantSettlementUsesRecordedFormat(t *testing.T) {
+	dir := t.TempDir()
+	job := &models.Job{
+		ID:   uuid.New(),
+		Type: models.JobTypeSettlement,
+		// Parameters the file lookup must not depend on
+		Parameters: `"{\"format\":\"csv\"}"`,
+	}
+	jobs := &recordedFiles{job: job}
+	for _, name := range []string{"merchant_a.parquet", "merchant_a.b.csv"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, job.ID.String()), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, jobFileKey(job.ID, name)), []byte(name), 0o644))
+		jobs.files = append(jobs.files, &models.JobFile{Name: name})
+	}
+	s := &jobService{jobRepo: jobs, files: storage.NewLocal(dir)}
+
+	reader, file, err := s.OpenMerchantSettlement(context.Background(), job.ID, "merchant_a")
+	require.NoError(t, err)
+	content, err := io.ReadAll(reader)
+	reader.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "merchant_a.parquet", file.Name)
+	assert.Equal(t, "merchant_a.parquet", string(content))
+
+	// A merchant whose ID extends another's gets its own file
+	reader, file, err = s.OpenMerchantSettlement(context.Background(), job.ID, "merchant_a.b")
+	require.NoError(t, err)
+	reader.Close()
+	assert.Equal(t, "merchant_a.b.csv", file.Name)
+
+	_, _, err = s.OpenMerchantSettlement(context.Background(), job.ID, "merchant_x")
+	assert.ErrorIs(t, err, errors.ErrFileNotFound)
+
+	_, _, err = s.OpenMerchantSettlement(context.Background(), uuid.New(), "merchant_a")
+	assert.ErrorIs(t, err, errors.ErrJobNotFound)
+}
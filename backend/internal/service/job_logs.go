@@ -0,0 +1,213 @@
+// Package service provides persistent job logs
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/metrics"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Job log lines are written in batches of up to jobLogBatchSize lines, at
+// least every jobLogFlushInterval; jobLogBufferSize lines can wait meanwhile
+const (
+	jobLogBatchSize     = 100
+	jobLogFlushInterval = time.Second
+	jobLogBufferSize    = 1000
+)
+
+// jobLogCapture copies the log lines of the jobs a processor is running into
+// job_logs. It hooks into the global logger and picks out lines carrying the
+// job_id of a tracked job, so lines are stored by the processor that ran the
+// job and not by one sharing the logger.
+type jobLogCapture struct {
+	jobRepo  repository.JobRepository
+	maxLines int
+
+	// jobs maps the ID of each tracked job to its stored line count
+	jobs  sync.Map // map[uuid.UUID]*int64
+	lines chan *models.JobLogEntry
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newJobLogCapture creates a capture storing at most maxLines lines per job;
+// 0 stores none
+func newJobLogCapture(jobRepo repository.JobRepository, maxLines int) *jobLogCapture {
+	return &jobLogCapture{
+		jobRepo:  jobRepo,
+		maxLines: maxLines,
+		lines:    make(chan *models.JobLogEntry, jobLogBufferSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// start hooks into the logger and starts the writer
+func (c *jobLogCapture) start() {
+	if c.maxLines <= 0 {
+		close(c.done)
+		return
+	}
+	logger.AddHook(c)
+	go c.write()
+}
+
+// close unhooks from the logger and waits for waiting lines to be written
+func (c *jobLogCapture) close() {
+	if c.maxLines > 0 {
+		logger.RemoveHook(c)
+	}
+	close(c.stop)
+	<-c.done
+}
+
+// track starts capturing a job's lines. The limit applies per run, so a
+// re-driven job can store as many lines again.
+func (c *jobLogCapture) track(jobID uuid.UUID) {
+	c.jobs.Store(jobID, new(int64))
+}
+
+// untrack stops capturing a job's lines
+func (c *jobLogCapture) untrack(jobID uuid.UUID) {
+	c.jobs.Delete(jobID)
+}
+
+// Levels captures lines at every level the logger writes
+func (c *jobLogCapture) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire queues a tracked job's line for writing. It never blocks the caller:
+// lines past a job's limit, or that find the buffer full, are dropped.
+func (c *jobLogCapture) Fire(entry *logrus.Entry) error {
+	jobID, ok := entryJobID(entry)
+	if !ok {
+		return nil
+	}
+	value, ok := c.jobs.Load(jobID)
+	if !ok {
+		return nil
+	}
+
+	if atomic.AddInt64(value.(*int64), 1) > int64(c.maxLines) {
+		metrics.JobLogLinesDropped.WithLabelValues("limit").Inc()
+		return nil
+	}
+
+	fields := logger.Redact(entry.Data)
+	delete(fields, "job_id")
+	if err, ok := fields[logrus.ErrorKey].(error); ok {
+		fields[logrus.ErrorKey] = err.Error()
+	}
+
+	line := &models.JobLogEntry{
+		JobID:    jobID,
+		Level:    entry.Level.String(),
+		Message:  entry.Message,
+		Fields:   fields,
+		LoggedAt: entry.Time.UTC(),
+	}
+	select {
+	case c.lines <- line:
+	default:
+		metrics.JobLogLinesDropped.WithLabelValues("buffer_full").Inc()
+	}
+	return nil
+}
+
+// entryJobID reads the job_id field of a log line, set as a string by
+// logger.WithJobID or as a UUID
+func entryJobID(entry *logrus.Entry) (uuid.UUID, bool) {
+	switch value := entry.Data["job_id"].(type) {
+	case uuid.UUID:
+		return value, true
+	case string:
+		id, err := uuid.Parse(value)
+		return id, err == nil
+	default:
+		return uuid.Nil, false
+	}
+}
+
+// jobLogLevels lists the levels at least as severe as level, every level
+// for an empty one
+func jobLogLevels(level string) ([]string, error) {
+	minLevel := logrus.TraceLevel
+	if level != "" {
+		parsed, err := logrus.ParseLevel(level)
+		if err != nil {
+			return nil, errors.NewValidationError("level must be one of error, warning, info, debug or trace")
+		}
+		minLevel = parsed
+	}
+
+	levels := []string{}
+	for _, l := range logrus.AllLevels {
+		if l <= minLevel {
+			levels = append(levels, l.String())
+		}
+	}
+	return levels, nil
+}
+
+// write stores queued lines in batches until the capture is closed, then
+// stores the lines still waiting
+func (c *jobLogCapture) write() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(jobLogFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*models.JobLogEntry, 0, jobLogBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		// The processor's context is cancelled by the time the last lines
+		// are written
+		if err := c.jobRepo.AppendLogs(context.Background(), batch); err != nil {
+			// No job_id, so this line isn't captured in turn
+			logger.WithComponent("job_logs").
+				WithError(err).
+				WithField("lines", len(batch)).
+				Error("Failed to store job log lines")
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case line := <-c.lines:
+			batch = append(batch, line)
+			if len(batch) >= jobLogBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-c.stop:
+			for {
+				select {
+				case line := <-c.lines:
+					batch = append(batch, line)
+					if len(batch) >= jobLogBatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
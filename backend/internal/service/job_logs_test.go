@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// logStore is a job repository that only stores log lines
+type logStore struct {
+	repository.JobRepository
+
+	mu    sync.Mutex
+	lines []*models.JobLogEntry
+}
+
+func (s *logStore) AppendLogs(ctx context.Context, entries []*models.JobLogEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lines = append(s.lines, entries...)
+	return nil
+}
+
+func TestJobLogCapture(t *testing.T) {
+	logger.Init("info", "json")
+	logger.GetLogger().SetOutput(io.Discard)
+	logger.GetLogger().SetRedactFields([]string{"email"})
+
+	store := &logStore{}
+	capture := newJobLogCapture(store, 2)
+	capture.start()
+
+	tracked, other := uuid.New(), uuid.New()
+	capture.track(tracked)
+
+	logger.WithJobID(tracked.String()).WithField("email", "buyer@example.com").Info("Batch processed")
+	logger.WithJobID(other.String()).Info("Another processor's job")
+	logger.WithComponent("job_processor").WithField("job_id", tracked).Warn("Row skipped")
+	logger.WithJobID(tracked.String()).Debug("Below the log level")
+	logger.WithJobID(tracked.String()).Error("Past the limit")
+
+	capture.untrack(tracked)
+	logger.WithJobID(tracked.String()).Info("After the job finished")
+	capture.close()
+
+	require.Len(t, store.lines, 2)
+	assert.Equal(t, tracked, store.lines[0].JobID)
+	assert.Equal(t, "info", store.lines[0].Level)
+	assert.Equal(t, "Batch processed", store.lines[0].Message)
+	assert.NotEqual(t, "buyer@example.com", store.lines[0].Fields["email"])
+	assert.NotContains(t, store.lines[0].Fields, "job_id")
+	assert.Equal(t, "warning", store.lines[1].Level)
+	assert.Equal(t, "job_processor", store.lines[1].Fields["component"])
+}
+
+func TestJobLogLevels(t *testing.T) {
+	levels, err := jobLogLevels("warn")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"panic", "fatal", "error", "warning"}, levels)
+
+	levels, err = jobLogLevels("")
+	require.NoError(t, err)
+	assert.Len(t, levels, 7)
+
+	_, err = jobLogLevels("verbose")
+	assert.Error(t, err)
+}
@@ -0,0 +1,102 @@
+// Package service provides the job queue metrics collector
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"indico-backend/internal/logger"
+	"indico-backend/internal/metrics"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+)
+
+// jobFailureWindow is how far back failed jobs are counted
+const jobFailureWindow = time.Hour
+
+// JobQueueCollector periodically reads the job backlog from the database
+// into gauges. The in-memory queue depth only covers one replica's workers;
+// these cover every job, so a growing backlog shows up whichever replica is
+// scraped. Every replica reports the same values.
+type JobQueueCollector struct {
+	statsRepo repository.StatsRepository
+	interval  time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewJobQueueCollector creates a collector reading the backlog every interval
+func NewJobQueueCollector(statsRepo repository.StatsRepository, interval time.Duration) *JobQueueCollector {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &JobQueueCollector{
+		statsRepo: statsRepo,
+		interval:  interval,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Start starts the collector loop
+func (c *JobQueueCollector) Start() {
+	logger.WithComponent("job_queue_collector").
+		WithField("interval", c.interval.String()).
+		Info("Starting job queue collector")
+
+	c.wg.Add(1)
+	go c.run()
+}
+
+// Stop stops the collector loop
+func (c *JobQueueCollector) Stop() {
+	c.cancel()
+	c.wg.Wait()
+
+	logger.WithComponent("job_queue_collector").Info("Job queue collector stopped")
+}
+
+func (c *JobQueueCollector) run() {
+	defer c.wg.Done()
+
+	c.collect()
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			c.collect()
+		}
+	}
+}
+
+// collect reads the backlog once; after a failed read the gauges keep their
+// previous values
+func (c *JobQueueCollector) collect() {
+	queue, err := c.statsRepo.JobQueue(c.ctx, time.Now().Add(-jobFailureWindow))
+	if err != nil {
+		if c.ctx.Err() == nil {
+			logger.WithComponent("job_queue_collector").WithError(err).Error("Failed to read job queue")
+		}
+		return
+	}
+
+	// Types without jobs drop out rather than keep their last value
+	metrics.JobsByStatus.Reset()
+	metrics.JobsFailedLastHour.Reset()
+	metrics.JobOldestQueued.Reset()
+
+	for _, q := range queue {
+		jobType := string(q.JobType)
+		metrics.JobsByStatus.WithLabelValues(jobType, string(models.JobStatusQueued)).Set(float64(q.Queued))
+		metrics.JobsByStatus.WithLabelValues(jobType, string(models.JobStatusRunning)).Set(float64(q.Running))
+		metrics.JobsFailedLastHour.WithLabelValues(jobType).Set(float64(q.FailedLastHour))
+		metrics.JobOldestQueued.WithLabelValues(jobType).Set(q.OldestQueuedSeconds)
+	}
+}
@@ -3,81 +3,165 @@ package service
 
 import (
 	"context"
-	"database/sql"
-	"encoding/csv"
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strconv"
 	"sync"
 	"time"
 
+	"indico-backend/internal/calendar"
 	"indico-backend/internal/config"
+	"indico-backend/internal/csvsafe"
 	"indico-backend/internal/database"
+	"indico-backend/internal/errors"
 	"indico-backend/internal/logger"
 	"indico-backend/internal/metrics"
 	"indico-backend/internal/models"
+	"indico-backend/internal/parquet"
 	"indico-backend/internal/repository"
+	"indico-backend/internal/storage"
 
 	"github.com/google/uuid"
-	"golang.org/x/sync/errgroup"
 )
 
 // JobProcessor handles background job processing
 type JobProcessor struct {
-	db         *database.DB
-	config     *config.JobsConfig
-	txRepo     repository.TransactionRepository
-	settleRepo repository.SettlementRepository
-	jobRepo    repository.JobRepository
-
-	jobQueue  chan *models.Job
-	cancelMap sync.Map // map[uuid.UUID]context.CancelFunc
-	workers   int
-	batchSize int
-
-	ctx    context.Context
-	cancel context.CancelFunc
-	wg     sync.WaitGroup
+	db           *database.DB
+	uow          repository.UnitOfWork
+	config       *config.JobsConfig
+	txRepo       repository.TransactionRepository
+	settleRepo   repository.SettlementRepository
+	jobRepo      repository.JobRepository
+	productRepo  repository.ProductRepository
+	orderRepo    repository.OrderRepository
+	forecastRepo repository.ForecastRepository
+	calendarRepo repository.CalendarRepository
+	sagaRepo     repository.SagaRepository
+	settleCfg    *config.SettlementConfig
+	payoutCfg    *config.PayoutConfig
+	maintenance  *MaintenanceMode
+	files        *storage.Local
+
+	jobQueue   chan *models.Job
+	cancelMap  sync.Map // map[uuid.UUID]context.CancelFunc
+	liveStates sync.Map // map[uuid.UUID]*liveJobState
+	workers    int
+	batchSize  int
+
+	backfillsMu sync.RWMutex
+	backfills   map[string]*database.Backfill
+
+	drain drainRate
+
+	logs     *jobLogCapture
+	progress *progressNotifier
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	recovery sync.WaitGroup
 }
 
-// NewJobProcessor creates a new job processor
+// NewJobProcessor creates a new job processor; workers hold new work while
+// maintenance is enabled, and a nil maintenance never pauses them. Progress
+// webhooks are looked up in webhookRepo and sent as webhookCfg configures,
+// and payout bank files are written for the banks payoutCfg configures.
+// Output files are written under files' directory, or the default result
+// directory when files is nil.
 func NewJobProcessor(
 	db *database.DB,
 	cfg *config.JobsConfig,
 	txRepo repository.TransactionRepository,
 	settleRepo repository.SettlementRepository,
 	jobRepo repository.JobRepository,
+	productRepo repository.ProductRepository,
+	orderRepo repository.OrderRepository,
+	forecastRepo repository.ForecastRepository,
+	calendarRepo repository.CalendarRepository,
+	sagaRepo repository.SagaRepository,
+	payoutRepo repository.PayoutRepository,
+	webhookRepo repository.WebhookRepository,
+	settleCfg *config.SettlementConfig,
+	webhookCfg *config.WebhookConfig,
+	payoutCfg *config.PayoutConfig,
+	maintenance *MaintenanceMode,
+	files *storage.Local,
 ) *JobProcessor {
 	ctx, cancel := context.WithCancel(context.Background())
+	if files == nil {
+		files = storage.NewLocal(defaultResultDir)
+	}
 
-	return &JobProcessor{
-		db:         db,
-		config:     cfg,
-		txRepo:     txRepo,
-		settleRepo: settleRepo,
-		jobRepo:    jobRepo,
-		jobQueue:   make(chan *models.Job, cfg.QueueSize),
-		workers:    cfg.Workers,
-		batchSize:  cfg.BatchSize,
-		ctx:        ctx,
-		cancel:     cancel,
+	jp := &JobProcessor{
+		db:     db,
+		config: cfg,
+		uow: repository.NewUnitOfWork(db, repository.Writers{
+			Products:     productRepo,
+			Orders:       orderRepo,
+			Transactions: txRepo,
+			Settlements:  settleRepo,
+			Forecasts:    forecastRepo,
+			Jobs:         jobRepo,
+			Payouts:      payoutRepo,
+		}),
+		txRepo:       txRepo,
+		settleRepo:   settleRepo,
+		jobRepo:      jobRepo,
+		productRepo:  productRepo,
+		orderRepo:    orderRepo,
+		forecastRepo: forecastRepo,
+		calendarRepo: calendarRepo,
+		sagaRepo:     sagaRepo,
+		settleCfg:    settleCfg,
+		payoutCfg:    payoutCfg,
+		maintenance:  maintenance,
+		files:        files,
+		jobQueue:     make(chan *models.Job, cfg.QueueSize),
+		workers:      cfg.Workers,
+		batchSize:    cfg.BatchSize,
+		backfills:    make(map[string]*database.Backfill),
+		logs:         newJobLogCapture(jobRepo, cfg.LogMaxLines),
+		progress:     newProgressNotifier(webhookRepo, webhookCfg),
+		ctx:          ctx,
+		cancel:       cancel,
 	}
+	for _, b := range backfills {
+		jp.RegisterBackfill(b)
+	}
+	return jp
 }
 
-// Start starts the job processor workers
+// Start starts the job processor workers, queueing first the jobs a
+// previous process left queued or running
 func (jp *JobProcessor) Start() {
+	since := time.Now()
+
 	logger.WithComponent("job_processor").
 		WithField("workers", jp.workers).
 		WithField("batch_size", jp.batchSize).
 		Info("Starting job processor")
 
+	jp.logs.start()
+	jp.progress.start()
+
 	for i := 0; i < jp.workers; i++ {
 		jp.wg.Add(1)
 		go jp.worker(i)
 	}
+
+	if recovered := jp.recoverJobs(jp.ctx, since); len(recovered) > 0 {
+		jp.recovery.Add(1)
+		go jp.requeue(recovered)
+	}
+
+	jp.refreshDeadLetterGauge(jp.ctx)
 }
 
 // Stop stops the job processor
@@ -85,23 +169,83 @@ func (jp *JobProcessor) Stop() {
 	logger.WithComponent("job_processor").Info("Stopping job processor")
 
 	jp.cancel()
+	jp.recovery.Wait()
 	close(jp.jobQueue)
 	jp.wg.Wait()
+	jp.progress.close()
+	jp.logs.close()
 
 	logger.WithComponent("job_processor").Info("Job processor stopped")
 }
 
-// QueueJob queues a job for processing
+// QueueJob queues a job for processing. A full queue is rejected with a
+// QUEUE_FULL error hinting when a slot should free up.
 func (jp *JobProcessor) QueueJob(ctx context.Context, job *models.Job) error {
 	select {
 	case jp.jobQueue <- job:
+		metrics.JobQueueDepth.Set(float64(len(jp.jobQueue)))
 		logger.WithJobID(job.ID.String()).Info("Job queued for processing")
 		return nil
 	case <-ctx.Done():
 		return ctx.Err()
 	default:
-		return fmt.Errorf("job queue is full")
+		metrics.JobsRejected.WithLabelValues(string(job.Type)).Inc()
+		return errors.NewQueueFullError(jp.drain.retryAfter())
+	}
+}
+
+// Bounds and fallback of the Retry-After hint for a full queue
+const (
+	minQueueRetryAfter     = time.Second
+	maxQueueRetryAfter     = 5 * time.Minute
+	defaultQueueRetryAfter = 30 * time.Second
+)
+
+// drainRate tracks how quickly workers take jobs off the queue as a moving
+// average of the gap between dequeues
+type drainRate struct {
+	mu          sync.Mutex
+	last        time.Time
+	avgInterval time.Duration
+}
+
+// record notes that a worker took a job off the queue
+func (d *drainRate) record() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	if !d.last.IsZero() {
+		interval := now.Sub(d.last)
+		if d.avgInterval == 0 {
+			d.avgInterval = interval
+		} else {
+			// Weight recent gaps so the hint follows changes in job mix
+			d.avgInterval = (d.avgInterval*4 + interval) / 5
+		}
 	}
+	d.last = now
+}
+
+// retryAfter estimates when the next slot frees up: one average gap after
+// the last dequeue. Before two dequeues have been seen it falls back to a
+// fixed default.
+func (d *drainRate) retryAfter() time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.avgInterval == 0 {
+		return defaultQueueRetryAfter
+	}
+
+	wait := d.avgInterval - time.Since(d.last)
+	if wait < minQueueRetryAfter {
+		wait = minQueueRetryAfter
+	}
+	if wait > maxQueueRetryAfter {
+		wait = maxQueueRetryAfter
+	}
+	return wait
 }
 
 // CancelJob cancels a running job by cancelling its context
@@ -114,6 +258,141 @@ func (jp *JobProcessor) CancelJob(jobID uuid.UUID) {
 	}
 }
 
+// LiveState returns a snapshot of the in-memory state of a running job
+func (jp *JobProcessor) LiveState(jobID uuid.UUID) (*models.JobLiveState, bool) {
+	value, ok := jp.liveStates.Load(jobID)
+	if !ok {
+		return nil, false
+	}
+	return value.(*liveJobState).snapshot(), true
+}
+
+// liveJobState tracks the progress of a running job between DB flushes
+type liveJobState struct {
+	mu           sync.RWMutex
+	total        int
+	processed    int
+	currentBatch int
+	startedAt    time.Time
+	updatedAt    time.Time
+
+	// dbTime and peakHeap feed the stats saved when the job finishes
+	dbTime   time.Duration
+	peakHeap uint64
+}
+
+// newLiveJobState creates a live state for a job that has just started
+func newLiveJobState() *liveJobState {
+	now := time.Now()
+	return &liveJobState{startedAt: now, updatedAt: now}
+}
+
+// setTotal records the total number of rows the job will process
+func (s *liveJobState) setTotal(total int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.total = total
+	s.updatedAt = time.Now()
+}
+
+// recordBatch records a processed batch
+func (s *liveJobState) recordBatch(rows int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.currentBatch++
+	s.processed += rows
+	s.updatedAt = time.Now()
+	s.samplePeakHeap()
+}
+
+// trackDB adds the time since start to the job's database time
+func (s *liveJobState) trackDB(start time.Time) {
+	s.addDBTime(time.Since(start))
+}
+
+// addDBTime adds d to the job's database time
+func (s *liveJobState) addDBTime(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.dbTime += d
+}
+
+// samplePeakHeap raises the peak heap size to the current one; callers hold mu
+func (s *liveJobState) samplePeakHeap() {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	if mem.HeapAlloc > s.peakHeap {
+		s.peakHeap = mem.HeapAlloc
+	}
+}
+
+// stats summarizes the state into the stats of a job that ran from start
+// until now
+func (s *liveJobState) stats(job *models.Job, status models.JobStatus, attempts int, start time.Time) *models.JobStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.samplePeakHeap()
+
+	finished := time.Now()
+	duration := finished.Sub(start)
+	compute := duration - s.dbTime
+	if compute < 0 {
+		compute = 0
+	}
+
+	stats := &models.JobStats{
+		JobID:           job.ID,
+		JobType:         job.Type,
+		Status:          status,
+		Attempts:        attempts,
+		RowsProcessed:   int64(s.processed),
+		Batches:         s.currentBatch,
+		DurationMS:      duration.Milliseconds(),
+		DBTimeMS:        s.dbTime.Milliseconds(),
+		ComputeTimeMS:   compute.Milliseconds(),
+		PeakMemoryBytes: s.peakHeap,
+		StartedAt:       start,
+		FinishedAt:      finished,
+	}
+	if seconds := duration.Seconds(); seconds > 0 {
+		stats.RowsPerSecond = float64(s.processed) / seconds
+	}
+	return stats
+}
+
+// snapshot returns a copy of the state with derived throughput and ETA
+func (s *liveJobState) snapshot() *models.JobLiveState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state := &models.JobLiveState{
+		Processed:    s.processed,
+		Total:        s.total,
+		CurrentBatch: s.currentBatch,
+		StartedAt:    s.startedAt,
+		UpdatedAt:    s.updatedAt,
+	}
+
+	if s.total > 0 {
+		state.Progress = float64(s.processed) / float64(s.total) * 100
+	}
+
+	if elapsed := s.updatedAt.Sub(s.startedAt).Seconds(); elapsed > 0 && s.processed > 0 {
+		state.RowsPerSecond = float64(s.processed) / elapsed
+		eta := float64(s.total-s.processed) / state.RowsPerSecond
+		if eta < 0 {
+			eta = 0
+		}
+		state.ETASeconds = &eta
+	}
+
+	return state
+}
+
 // worker processes jobs from the queue
 func (jp *JobProcessor) worker(workerID int) {
 	defer jp.wg.Done()
@@ -128,6 +407,14 @@ func (jp *JobProcessor) worker(workerID int) {
 				log.Info("Worker stopped - job queue closed")
 				return
 			}
+			metrics.JobQueueDepth.Set(float64(len(jp.jobQueue)))
+			jp.drain.record()
+
+			// The job stays QUEUED until maintenance mode is lifted
+			if !jp.waitOutMaintenance(workerID) {
+				log.Info("Worker stopped - context cancelled")
+				return
+			}
 
 			jp.processJob(job, workerID)
 
@@ -138,59 +425,233 @@ func (jp *JobProcessor) worker(workerID int) {
 	}
 }
 
+// maintenancePollInterval is how often a paused worker checks whether
+// maintenance mode has been lifted
+const maintenancePollInterval = time.Second
+
+// waitOutMaintenance blocks while maintenance mode is on, reporting false if
+// the processor stops first
+func (jp *JobProcessor) waitOutMaintenance(workerID int) bool {
+	if !jp.maintenance.Enabled() {
+		return true
+	}
+
+	log := logger.WithComponent("job_processor").WithField("worker_id", workerID)
+	log.Info("Worker paused for maintenance")
+
+	ticker := time.NewTicker(maintenancePollInterval)
+	defer ticker.Stop()
+
+	for jp.maintenance.Enabled() {
+		select {
+		case <-jp.ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+
+	log.Info("Worker resumed after maintenance")
+	return true
+}
+
 // processJob processes a single job
 func (jp *JobProcessor) processJob(job *models.Job, workerID int) {
 	start := time.Now()
 	log := logger.WithJobID(job.ID.String()).WithField("worker_id", workerID)
-	log.Info("Processing job")
 
 	// Create cancellable context for this job
 	jobCtx, jobCancel := context.WithCancel(jp.ctx)
 	jp.cancelMap.Store(job.ID, jobCancel)
+	jp.liveStates.Store(job.ID, newLiveJobState())
+	jp.logs.track(job.ID)
+	jp.progress.track(job)
 	defer func() {
+		jp.progress.untrack(job.ID)
+		jp.logs.untrack(job.ID)
 		jp.cancelMap.Delete(job.ID)
+		jp.liveStates.Delete(job.ID)
 		jobCancel()
 	}()
 
-	// Mark job as started
-	if err := jp.jobRepo.MarkStarted(jobCtx, job.ID); err != nil {
-		log.WithError(err).Error("Failed to mark job as started")
-		return
-	}
+	log.Info("Processing job")
 
-	// Process based on job type
+	// Run the job, retrying transient failures up to the configured attempts
+	maxAttempts := 1 + jp.config.RetryAttempts
 	var err error
-	status := "success"
-	switch job.Type {
-	case models.JobTypeSettlement:
-		err = jp.processSettlementJob(jobCtx, job)
-	default:
-		err = fmt.Errorf("unknown job type: %s", job.Type)
-	}
+	var attempt int
+	for attempt = 1; ; attempt++ {
+		// Mark job as started, unless it was cancelled while it waited
+		started, startErr := jp.jobRepo.MarkStarted(jobCtx, job.ID)
+		if startErr != nil {
+			log.WithError(startErr).Error("Failed to mark job as started")
+			return
+		}
+		if !started {
+			if attempt == 1 {
+				log.Info("Job is no longer queued, skipping")
+				return
+			}
+			err = errJobCancelled
+			break
+		}
+		if attempt == 1 && job.DeadLetteredAt == nil && job.StartedAt == nil {
+			// Re-driven and recovered jobs were created long before they
+			// were queued again
+			metrics.JobQueueWait.WithLabelValues(string(job.Type)).Observe(time.Since(job.CreatedAt).Seconds())
+		}
 
-	// Update job status based on result
-	if err != nil {
-		status = "failed"
-		log.WithError(err).Error("Job processing failed")
+		err = jp.runJob(jobCtx, job)
+		if err == nil || jobCtx.Err() != nil || isPermanentJobError(err) || attempt >= maxAttempts {
+			break
+		}
+
+		log.WithError(err).
+			WithField("attempt", attempt).
+			WithField("max_attempts", maxAttempts).
+			Warn("Job attempt failed, retrying")
+		metrics.JobRetries.WithLabelValues(string(job.Type)).Inc()
 
-		if err := jp.jobRepo.UpdateStatus(jobCtx, job.ID, models.JobStatusFailed); err != nil {
-			log.WithError(err).Error("Failed to update job status to failed")
+		if err := jp.jobRepo.MarkRetrying(jobCtx, job.ID, err.Error()); err != nil {
+			log.WithError(err).Error("Failed to mark job for retry")
 		}
 
-		if err := jp.jobRepo.UpdateError(jobCtx, job.ID, err.Error()); err != nil {
-			log.WithError(err).Error("Failed to update job error")
+		select {
+		case <-time.After(jp.config.RetryDelay):
+		case <-jobCtx.Done():
 		}
-	} else {
+		if jobCtx.Err() != nil {
+			err = jobCtx.Err()
+			break
+		}
+		jp.liveStates.Store(job.ID, newLiveJobState())
+		jp.progress.track(job)
+	}
+
+	// A cancel request wins over however the job ended. The check uses a
+	// fresh context because cancelling the job also cancels jobCtx.
+	cancelled, cancelErr := jp.jobRepo.IsCancelled(context.Background(), job.ID)
+	if cancelErr != nil {
+		log.WithError(cancelErr).Error("Failed to check job cancellation status")
+	}
+
+	// Update job status based on result
+	status := "success"
+	finalStatus := models.JobStatusCompleted
+	switch {
+	case cancelled || stderrors.Is(err, errJobCancelled):
+		status = "cancelled"
+		finalStatus = models.JobStatusCancelled
+		log.Info("Job processing stopped after cancellation")
+
+	case err == nil:
 		log.Info("Job processing completed successfully")
 
 		if err := jp.jobRepo.MarkCompleted(jobCtx, job.ID); err != nil {
 			log.WithError(err).Error("Failed to mark job as completed")
 		}
+
+		// A job that finishes without reporting 100%, such as one with
+		// nothing to process, still passes its last milestone
+		live := jp.liveState(job.ID).snapshot()
+		jp.progress.reached(job, 100, live.Processed, live.Total)
+
+	case jobCtx.Err() == nil && !isPermanentJobError(err):
+		// Every attempt failed; park the job so it can be inspected and re-driven
+		status = "dead_lettered"
+		finalStatus = models.JobStatusDeadLettered
+		log.WithError(err).Error("Job exhausted its retries and was dead-lettered")
+
+		if err := jp.jobRepo.MarkDeadLettered(jobCtx, job.ID, err.Error()); err != nil {
+			log.WithError(err).Error("Failed to dead-letter job")
+		}
+		metrics.JobsDeadLettered.WithLabelValues(string(job.Type)).Inc()
+		jp.refreshDeadLetterGauge(jobCtx)
+
+	default:
+		status = "failed"
+		finalStatus = models.JobStatusFailed
+		log.WithError(err).Error("Job processing failed")
+
+		if err := jp.jobRepo.MarkFailed(jobCtx, job.ID, err.Error()); err != nil {
+			log.WithError(err).Error("Failed to mark job as failed")
+		}
+	}
+
+	// The worker has stopped, so a CANCELLING job is now CANCELLED. This also
+	// settles a cancel that arrived after the check above, which the
+	// transitions to COMPLETED, FAILED and DEAD_LETTERED skip.
+	if err := jp.jobRepo.MarkCancelled(context.Background(), job.ID); err != nil {
+		log.WithError(err).Error("Failed to mark job as cancelled")
 	}
 
 	// Record metrics
 	metrics.JobsCompleted.WithLabelValues(string(job.Type), status).Inc()
-	metrics.JobDuration.WithLabelValues(string(job.Type)).Observe(time.Since(start).Seconds())
+	metrics.ObserveDuration(jobCtx, metrics.JobDuration.WithLabelValues(string(job.Type)), start)
+
+	// Keep the stats past metrics retention; a fresh context saves them even
+	// for a cancelled job. Rows and batches are those of the last attempt.
+	stats := jp.liveState(job.ID).stats(job, finalStatus, attempt, start)
+	if err := jp.jobRepo.SaveStats(context.Background(), stats); err != nil {
+		log.WithError(err).Error("Failed to save job stats")
+	}
+}
+
+// updateProgress saves a job's progress and notifies its progress webhook
+// of any milestone passed
+func (jp *JobProcessor) updateProgress(ctx context.Context, job *models.Job, progress float64, processed int) error {
+	if err := jp.jobRepo.UpdateProgress(ctx, job.ID, progress, processed); err != nil {
+		return err
+	}
+
+	jp.progress.reached(job, progress, processed, jp.liveState(job.ID).snapshot().Total)
+	return nil
+}
+
+// runJob runs a single attempt of a job based on its type
+func (jp *JobProcessor) runJob(ctx context.Context, job *models.Job) error {
+	switch job.Type {
+	case models.JobTypeSettlement:
+		return jp.processSettlementJob(ctx, job)
+	case models.JobTypeReorderForecast:
+		return jp.processReorderForecastJob(ctx, job)
+	case models.JobTypeOrdersExport:
+		return jp.processOrdersExportJob(ctx, job)
+	case models.JobTypeResettle:
+		return jp.processResettleJob(ctx, job)
+	case models.JobTypeMerchantStatement:
+		return jp.processMerchantStatementJob(ctx, job)
+	case models.JobTypeBackfill:
+		return jp.processBackfillJob(ctx, job)
+	case models.JobTypeBuyerErasure:
+		return jp.processBuyerErasureJob(ctx, job)
+	case models.JobTypeBackorderFulfillment:
+		return jp.processBackorderFulfillmentJob(ctx, job)
+	case models.JobTypePayoutFile:
+		return jp.processPayoutFileJob(ctx, job)
+	default:
+		return fmt.Errorf("unknown job type: %s", job.Type)
+	}
+}
+
+// errJobCancelled is returned by a job that notices it was cancelled via the API
+var errJobCancelled = stderrors.New("job was cancelled")
+
+// isPermanentJobError reports whether a job error would recur on retry.
+// Application errors such as validation failures or an overlapping run are
+// deterministic; anything else, like a database hiccup, is worth retrying.
+func isPermanentJobError(err error) bool {
+	var appErr *errors.AppError
+	return stderrors.Is(err, errJobCancelled) || stderrors.As(err, &appErr)
+}
+
+// refreshDeadLetterGauge sets the dead-letter gauge from the jobs table
+func (jp *JobProcessor) refreshDeadLetterGauge(ctx context.Context) {
+	count, err := jp.jobRepo.CountByStatus(ctx, models.JobStatusDeadLettered)
+	if err != nil {
+		logger.WithComponent("job_processor").WithError(err).Error("Failed to count dead-lettered jobs")
+		return
+	}
+	metrics.DeadLetterJobs.Set(float64(count))
 }
 
 // processSettlementJob processes a settlement job
@@ -214,36 +675,74 @@ func (jp *JobProcessor) processSettlementJob(ctx context.Context, job *models.Jo
 		return fmt.Errorf("invalid to date: %w", err)
 	}
 
+	// Prevent overlapping settlement runs over the same date range
+	lock := &models.JobLock{
+		JobID:     job.ID,
+		JobType:   job.Type,
+		RangeFrom: from,
+		RangeTo:   to,
+	}
+	if err := jp.uow.Do(ctx, nil, func(tx *repository.Tx) error {
+		return tx.Jobs.AcquireLock(ctx, lock)
+	}); err != nil {
+		return err
+	}
+	defer func() {
+		// Use a fresh context so the lock is released even if the job was cancelled
+		if err := jp.jobRepo.ReleaseLock(context.Background(), job.ID); err != nil {
+			log.WithError(err).Error("Failed to release job lock")
+		}
+	}()
+
 	// Add one day to 'to' date to make it inclusive
 	to = to.AddDate(0, 0, 1)
 
-	log.WithField("from", from).WithField("to", to).Info("Processing settlement job")
+	// Weekend and holiday activity settles on the next business day, so read
+	// from the earliest day that can roll into the range
+	book, err := jp.calendarBook(ctx)
+	if err != nil {
+		return err
+	}
+	fetchFrom := book.FirstContributingDay(from)
+
+	log.WithField("from", from).WithField("to", to).WithField("fetch_from", fetchFrom).Info("Processing settlement job")
 
 	// Get total transaction count for progress tracking
-	totalCount, err := jp.txRepo.GetTotalCount(ctx, from, to)
+	totalCount, err := jp.txRepo.GetTotalCount(ctx, fetchFrom, to)
 	if err != nil {
 		return fmt.Errorf("failed to get total transaction count: %w", err)
 	}
 
 	log.WithField("total_transactions", totalCount).Info("Total transactions to process")
 
+	live := jp.liveState(job.ID)
+	live.setTotal(totalCount)
+
 	// Update job total
-	if err := jp.jobRepo.UpdateProgress(ctx, job.ID, 0, 0); err != nil {
+	if err := jp.updateProgress(ctx, job, 0, 0); err != nil {
 		log.WithError(err).Error("Failed to update job total")
 	}
 
-	// Process transactions in batches
+	// Aggregate transactions, checking for cancellation and reporting
+	// progress after every batch of rows
 	settlements := make(map[string]*models.Settlement) // key: merchantID_date
-	var processed int
-	var offset int
+	var processed, batch, late int
+	generatedAt := time.Now()
+
+	checkpoint := func() error {
+		if batch > 0 {
+			live.recordBatch(batch)
+			batch = 0
+
+			// Update progress
+			progress := float64(processed) / float64(totalCount) * 100
+			if err := jp.updateProgress(ctx, job, progress, processed); err != nil {
+				log.WithError(err).Error("Failed to update job progress")
+			}
 
-	for {
-		// Check for cancellation
-		select {
-		case <-ctx.Done():
-			log.Info("Job processing cancelled")
-			return ctx.Err()
-		default:
+			log.WithField("processed", processed).
+				WithField("progress", fmt.Sprintf("%.2f%%", progress)).
+				Debug("Progress updated")
 		}
 
 		// Check if job was cancelled via API
@@ -252,156 +751,456 @@ func (jp *JobProcessor) processSettlementJob(ctx context.Context, job *models.Jo
 			log.WithError(err).Error("Failed to check job cancellation status")
 		} else if cancelled {
 			log.Info("Job was cancelled via API")
-			return fmt.Errorf("job was cancelled")
+			return errJobCancelled
 		}
+		return nil
+	}
+
+	if err := checkpoint(); err != nil {
+		return err
+	}
+
+	if jp.settleCfg.Cutoff <= 0 {
+		// Without a cutoff no row needs looking at on its own: the database
+		// totals each merchant's paid day and only those totals are rolled
+		// onto settlement dates
+		log.Info("Aggregating transactions in the database")
 
-		// Get batch of transactions
-		transactions, err := jp.txRepo.GetBatch(ctx, offset, jp.batchSize, from, to)
+		aggregateStart := time.Now()
+		daily, err := jp.txRepo.AggregateByMerchantAndDay(ctx, "", fetchFrom, to, nil)
+		live.trackDB(aggregateStart)
+		if ctx.Err() != nil {
+			log.Info("Job processing cancelled")
+			return ctx.Err()
+		}
 		if err != nil {
-			return fmt.Errorf("failed to get transaction batch: %w", err)
+			return fmt.Errorf("failed to aggregate transactions: %w", err)
 		}
 
-		if len(transactions) == 0 {
-			break // No more transactions
+		for _, day := range daily {
+			accumulateDailySettlement(settlements, day, book, from, to, generatedAt)
+			processed += day.TxnCount
+			batch += day.TxnCount
 		}
+	} else {
+		// Rows arrive through one cursor, so the time spent aggregating them is
+		// measured and the rest of the stream counted as database time
+		var aggregating time.Duration
+		streamStart := time.Now()
+		err = jp.txRepo.StreamForSettlement(ctx, fetchFrom, to, func(tx *models.Transaction) error {
+			rowStart := time.Now()
+			if accumulateSettlement(settlements, tx, book, from, to, jp.settleCfg.Cutoff, generatedAt) {
+				late++
+			}
+			aggregating += time.Since(rowStart)
+			processed++
+			batch++
 
-		log.WithField("batch_size", len(transactions)).
-			WithField("offset", offset).
-			Debug("Processing transaction batch")
-
-		// Process batch using worker pool
-		if err := jp.processBatch(ctx, transactions, settlements); err != nil {
-			return fmt.Errorf("failed to process batch: %w", err)
+			if batch == jp.batchSize {
+				return checkpoint()
+			}
+			return nil
+		})
+		live.addDBTime(time.Since(streamStart) - aggregating)
+		if ctx.Err() != nil {
+			log.Info("Job processing cancelled")
+			return ctx.Err()
 		}
-
-		processed += len(transactions)
-		offset += jp.batchSize
-
-		// Update progress
-		progress := float64(processed) / float64(totalCount) * 100
-		if err := jp.jobRepo.UpdateProgress(ctx, job.ID, progress, processed); err != nil {
-			log.WithError(err).Error("Failed to update job progress")
+		if err != nil {
+			if err == errJobCancelled {
+				return err
+			}
+			return fmt.Errorf("failed to stream transactions: %w", err)
 		}
-
-		log.WithField("processed", processed).
-			WithField("progress", fmt.Sprintf("%.2f%%", progress)).
-			Debug("Progress updated")
+	}
+	if err := checkpoint(); err != nil {
+		return err
+	}
+	if late > 0 {
+		log.WithField("late_transactions", late).Info("Left out transactions recorded after their settlement cutoff")
 	}
 
-	// Save settlements to database
-	if err := jp.saveSettlements(ctx, settlements, job.ID); err != nil {
+	// Save settlements to database as a new immutable run
+	jobID := job.ID
+	run := &models.SettlementRun{
+		ID:    job.ID,
+		JobID: &jobID,
+		From:  from,
+		To:    to.AddDate(0, 0, -1),
+	}
+	saveStart := time.Now()
+	if err := jp.saveSettlements(ctx, settlements, run); err != nil {
 		return fmt.Errorf("failed to save settlements: %w", err)
 	}
+	live.trackDB(saveStart)
 
-	// Ensure /tmp/settlements directory exists as per assignment requirements
-	dirPath := "/tmp/settlements"
-	if err := os.MkdirAll(dirPath, 0755); err != nil {
-		return fmt.Errorf("failed to create settlements directory: %w", err)
+	// Adjustments dated in the range follow the settlements as their own lines
+	adjustments, err := jp.settleRepo.ListAdjustments(ctx, &models.SettlementFilter{From: &from, To: &to})
+	if err != nil {
+		return err
 	}
 
-	// Create CSV file
-	csvPath := filepath.Join(dirPath, fmt.Sprintf("%s.csv", job.ID.String()))
-	if err := jp.createSettlementCSV(settlements, csvPath); err != nil {
-		return fmt.Errorf("failed to create CSV: %w", err)
+	format := params.Format
+	if format == "" {
+		format = models.ExportFormatCSV
+	}
+
+	// Split jobs write one file per merchant, downloadable alone or as a ZIP
+	if params.Split == models.SettlementSplitMerchant {
+		files, err := jp.createMerchantSettlementFiles(job.ID, settlements, adjustments, format)
+		if err != nil {
+			return fmt.Errorf("failed to create merchant files: %w", err)
+		}
+		if err := jp.saveJobFiles(ctx, job.ID, files); err != nil {
+			return err
+		}
+
+		log.WithField("settlements_count", len(settlements)).
+			WithField("skipped_count", len(run.Skipped)).
+			WithField("files", len(files)).
+			Info("Settlement job completed")
+		return nil
+	}
+
+	// Create the settlement file
+	filePath, downloadURL, err := jp.resultLocation(job.ID, string(format))
+	if err != nil {
+		return err
+	}
+	if err := jp.createSettlementFile(settlements, adjustments, filePath, format); err != nil {
+		return fmt.Errorf("failed to create settlement file: %w", err)
 	}
 
 	// Update job with result path and download URL
-	downloadURL := fmt.Sprintf("/downloads/%s.csv", job.ID.String())
-	if err := jp.jobRepo.UpdateResult(ctx, job.ID, csvPath, downloadURL); err != nil {
+	if err := jp.jobRepo.UpdateResult(ctx, job.ID, filePath, downloadURL); err != nil {
 		return fmt.Errorf("failed to update job result: %w", err)
 	}
 
 	log.WithField("settlements_count", len(settlements)).
-		WithField("csv_path", csvPath).
+		WithField("skipped_count", len(run.Skipped)).
+		WithField("file_path", filePath).
 		Info("Settlement job completed")
 
 	return nil
 }
 
-// processBatch processes a batch of transactions using worker pool
-func (jp *JobProcessor) processBatch(ctx context.Context, transactions []*models.Transaction, settlements map[string]*models.Settlement) error {
-	// Use errgroup for concurrent processing with limited concurrency
-	g, _ := errgroup.WithContext(ctx)
-	g.SetLimit(4) // Limit concurrent goroutines
-
-	// Mutex to protect settlements map
-	var mu sync.Mutex
-
-	for _, tx := range transactions {
-		tx := tx // capture loop variable
-
-		g.Go(func() error {
-			// Aggregate transaction
-			date := tx.PaidAt.Truncate(24 * time.Hour) // Start of day
-			key := fmt.Sprintf("%s_%s", tx.MerchantID, date.Format("2006-01-02"))
-
-			mu.Lock()
-			settlement, exists := settlements[key]
-			if !exists {
-				settlement = &models.Settlement{
-					MerchantID:  tx.MerchantID,
-					Date:        date,
-					GrossCents:  0,
-					FeeCents:    0,
-					NetCents:    0,
-					TxnCount:    0,
-					GeneratedAt: time.Now(),
-					UniqueRunID: uuid.New(),
-				}
-				settlements[key] = settlement
-			}
+// defaultResultDir is where job output files are written and served from
+// when no store is configured
+const defaultResultDir = "/tmp/settlements"
 
-			settlement.GrossCents += tx.AmountCents
-			settlement.FeeCents += tx.FeeCents
-			settlement.NetCents += (tx.AmountCents - tx.FeeCents)
-			settlement.TxnCount++
-			mu.Unlock()
+// resultLocation ensures the result directory exists and returns the file
+// path and download URL for a job's output file with the given extension
+func (jp *JobProcessor) resultLocation(jobID uuid.UUID, ext string) (string, string, error) {
+	dir := jp.files.Dir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create result directory: %w", err)
+	}
 
-			return nil
-		})
+	key := resultKey(jobID, ext)
+	return filepath.Join(dir, key), "/v1/downloads/" + key, nil
+}
+
+// liveState returns the live state registered for a job, or a detached one
+// if the job is not being tracked
+func (jp *JobProcessor) liveState(jobID uuid.UUID) *liveJobState {
+	if value, ok := jp.liveStates.Load(jobID); ok {
+		return value.(*liveJobState)
 	}
+	return newLiveJobState()
+}
 
-	return g.Wait()
+// accumulateSettlement adds a transaction to the settlement for its merchant
+// and settlement date, skipping transactions that settle outside [from, to).
+// A transaction recorded after its settlement date's cutoff is skipped too,
+// and reported as late by the return value. Only the goroutine reading the
+// job's cursor calls it, so the map is not locked. Rows arrive one at a time
+// from that cursor, and adding one up costs far less than reading it
+// (compare compute_time_ms with db_time_ms in the job's stats), so sharding
+// the map across goroutines would add locking without making the job faster.
+func accumulateSettlement(settlements map[string]*models.Settlement, tx *models.Transaction, book *calendar.Book, from, to time.Time, cutoff time.Duration, generatedAt time.Time) bool {
+	// Aggregate transaction on the day it settles
+	date := book.SettlementDate(tx.MerchantID, tx.PaidAt)
+	if date.Before(from) || !date.Before(to) {
+		return false
+	}
+	cutoffAt := settlementCutoff(date, cutoff)
+	if cutoffAt != nil && tx.CreatedAt.After(*cutoffAt) {
+		return true
+	}
+
+	settlement := settlementFor(settlements, tx.MerchantID, date, generatedAt, cutoffAt)
+	settlement.GrossCents += tx.AmountCents
+	settlement.FeeCents += tx.FeeCents
+	settlement.NetCents += (tx.AmountCents - tx.FeeCents)
+	settlement.TxnCount++
+	return false
 }
 
-// saveSettlements saves settlements to database
-func (jp *JobProcessor) saveSettlements(ctx context.Context, settlements map[string]*models.Settlement, _ uuid.UUID) error {
-	// Save settlements in transaction
-	return jp.db.WithTx(ctx, func(tx *sql.Tx) error {
-		for _, settlement := range settlements {
-			if err := jp.settleRepo.Upsert(ctx, tx, settlement); err != nil {
-				return fmt.Errorf("failed to upsert settlement: %w", err)
-			}
+// accumulateDailySettlement adds one merchant's totals for a UTC paid day,
+// as returned by AggregateByMerchantAndDay, to the settlement for its
+// settlement date, skipping days that settle outside [from, to). Without
+// per-row created_at it applies no cutoff, so it is only used when none is
+// configured.
+func accumulateDailySettlement(settlements map[string]*models.Settlement, day *models.Settlement, book *calendar.Book, from, to time.Time, generatedAt time.Time) {
+	date := book.SettlementDate(day.MerchantID, day.Date)
+	if date.Before(from) || !date.Before(to) {
+		return
+	}
+
+	settlement := settlementFor(settlements, day.MerchantID, date, generatedAt, nil)
+	settlement.GrossCents += day.GrossCents
+	settlement.FeeCents += day.FeeCents
+	settlement.NetCents += day.NetCents
+	settlement.TxnCount += day.TxnCount
+}
+
+// settlementFor returns the settlement for a merchant and settlement date,
+// adding an empty one if there is none yet
+func settlementFor(settlements map[string]*models.Settlement, merchantID string, date time.Time, generatedAt time.Time, cutoffAt *time.Time) *models.Settlement {
+	key := fmt.Sprintf("%s_%s", merchantID, date.Format("2006-01-02"))
+
+	settlement, exists := settlements[key]
+	if !exists {
+		settlement = &models.Settlement{
+			MerchantID:  merchantID,
+			Date:        date,
+			GeneratedAt: generatedAt,
+			CutoffAt:    cutoffAt,
 		}
+		settlements[key] = settlement
+	}
+	return settlement
+}
+
+// settlementCutoff returns when transactions stop counting towards the
+// settlement of date, or nil without a cutoff
+func settlementCutoff(date time.Time, cutoff time.Duration) *time.Time {
+	if cutoff <= 0 {
 		return nil
+	}
+	cutoffAt := date.Add(cutoff)
+	return &cutoffAt
+}
+
+// calendarBook loads the settlement calendars that apply to merchants
+func (jp *JobProcessor) calendarBook(ctx context.Context) (*calendar.Book, error) {
+	holidays, err := jp.calendarRepo.ListHolidays(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	assignments, err := jp.calendarRepo.ListMerchantCalendars(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return calendar.NewBook(normalizeRegion(jp.settleCfg.DefaultRegion), holidays, assignments), nil
+}
+
+// saveSettlements saves settlements to database as versions of the given run,
+// superseding any previously reported values for the run's date range
+func (jp *JobProcessor) saveSettlements(ctx context.Context, settlements map[string]*models.Settlement, run *models.SettlementRun) error {
+	run.SettlementCount = len(settlements)
+
+	// Save settlements in a repeatable-read transaction, so a settlement
+	// another transaction changed after this one started fails the run for
+	// a retry instead of being superseded unseen
+	return jp.uow.Do(ctx, database.RepeatableRead, func(tx *repository.Tx) error {
+		sorted := sortedSettlements(settlements)
+		for _, settlement := range sorted {
+			settlement.UniqueRunID = run.ID
+		}
+		skipped, err := jp.createSettlements(ctx, tx, run, sorted)
+		if err != nil {
+			return err
+		}
+
+		// Skipped days keep their previous settlement and drop out of the run
+		for _, settlement := range skipped {
+			delete(settlements, merchantDayKey(settlement.MerchantID, settlement.Date))
+		}
+		run.SettlementCount = len(settlements)
+		if err := tx.Settlements.CreateRun(ctx, run); err != nil {
+			return err
+		}
+
+		// Days in the range that no longer have settled activity drop out
+		return tx.Settlements.SupersedeRange(ctx, run.ID, run.From, run.To.AddDate(0, 0, 1), skipped)
+	})
+}
+
+// settlementWriteFailedReason is recorded on a settlement left current because
+// a run failed to write its replacement
+const settlementWriteFailedReason = "settlement run failed to write a replacement"
+
+// createSettlements writes a run's settlements in one batch. If the batch
+// fails it is rolled back to a savepoint and the settlements are retried one
+// at a time, each in its own savepoint. Those that still fail are skipped,
+// recorded on the run and returned, and the day's previous settlement is
+// marked stale so it is settled again. A day with no previous settlement
+// can't be skipped without losing it, so it fails the run instead, as do
+// errors that leave the transaction unusable, including a serialization
+// failure.
+func (jp *JobProcessor) createSettlements(ctx context.Context, tx *repository.Tx, run *models.SettlementRun, settlements []*models.Settlement) ([]*models.Settlement, error) {
+	batchErr := tx.Savepoint(ctx, func(tx *repository.Tx) error {
+		return tx.Settlements.CreateBatch(ctx, settlements)
 	})
+	if batchErr == nil {
+		return nil, nil
+	}
+	if stderrors.Is(batchErr, database.ErrTxAborted) || database.IsSerializationFailure(batchErr) || ctx.Err() != nil {
+		return nil, batchErr
+	}
+
+	log := logger.WithJobID(run.ID.String())
+	log.WithError(batchErr).Warn("Settlement batch failed, retrying settlements one at a time")
+
+	var skipped []*models.Settlement
+	for _, settlement := range settlements {
+		err := tx.Savepoint(ctx, func(tx *repository.Tx) error {
+			return tx.Settlements.Create(ctx, settlement)
+		})
+		if err == nil {
+			continue
+		}
+		if stderrors.Is(err, database.ErrTxAborted) || database.IsSerializationFailure(err) || ctx.Err() != nil {
+			return nil, err
+		}
+
+		previous, staleErr := tx.Settlements.MarkStale(ctx, settlement.MerchantID, settlement.Date, settlementWriteFailedReason)
+		if staleErr != nil {
+			return nil, staleErr
+		}
+		if previous == nil {
+			return nil, fmt.Errorf("settlement for merchant %s on %s failed to write and has no previous settlement to keep: %w",
+				settlement.MerchantID, settlement.Date.Format("2006-01-02"), err)
+		}
+
+		log.WithError(err).
+			WithField("merchant_id", settlement.MerchantID).
+			WithField("date", settlement.Date.Format("2006-01-02")).
+			Error("Skipping settlement that failed to write")
+		metrics.SettlementsSkipped.Inc()
+
+		skipped = append(skipped, settlement)
+		run.Skipped = append(run.Skipped, models.SkippedSettlement{
+			MerchantID: settlement.MerchantID,
+			Date:       settlement.Date,
+			Error:      err.Error(),
+		})
+	}
+
+	if len(skipped) > 0 {
+		log.WithField("skipped", len(skipped)).Warn("Settlement run skipped settlements that failed to write")
+	}
+	return skipped, nil
 }
 
-// createSettlementCSV creates a CSV file from settlements
-func (jp *JobProcessor) createSettlementCSV(settlements map[string]*models.Settlement, filePath string) error {
-	// Convert map to slice for consistent ordering
-	settlementSlice := make([]*models.Settlement, 0, len(settlements))
+// sortedSettlements returns settlements ordered by merchant ID and date
+func sortedSettlements(settlements map[string]*models.Settlement) []*models.Settlement {
+	sorted := make([]*models.Settlement, 0, len(settlements))
 	for _, settlement := range settlements {
-		settlementSlice = append(settlementSlice, settlement)
+		sorted = append(sorted, settlement)
 	}
 
-	// Sort by merchant ID and date for consistent output
-	sort.Slice(settlementSlice, func(i, j int) bool {
-		if settlementSlice[i].MerchantID != settlementSlice[j].MerchantID {
-			return settlementSlice[i].MerchantID < settlementSlice[j].MerchantID
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].MerchantID != sorted[j].MerchantID {
+			return sorted[i].MerchantID < sorted[j].MerchantID
 		}
-		return settlementSlice[i].Date.Before(settlementSlice[j].Date)
+		return sorted[i].Date.Before(sorted[j].Date)
 	})
+	return sorted
+}
 
-	// Create the CSV file
+// createSettlementFile writes every settlement, then every adjustment, to one
+// file in the given format
+func (jp *JobProcessor) createSettlementFile(settlements map[string]*models.Settlement, adjustments []*models.SettlementAdjustment, filePath string, format models.ExportFormat) error {
 	file, err := os.Create(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to create CSV file: %w", err)
+		return fmt.Errorf("failed to create settlement file: %w", err)
 	}
 	defer file.Close()
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+	if err := writeSettlements(file, sortedSettlements(settlements), adjustments, format); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// createMerchantSettlementFiles writes one file per merchant with
+// settlements or adjustments, named by MerchantSettlementFilename
+func (jp *JobProcessor) createMerchantSettlementFiles(jobID uuid.UUID, settlements map[string]*models.Settlement, adjustments []*models.SettlementAdjustment, format models.ExportFormat) ([]*models.JobFile, error) {
+	var files []*models.JobFile
+
+	contentType := "text/csv"
+	if format == models.ExportFormatParquet {
+		contentType = parquet.ContentType
+	}
+
+	rowsByMerchant := make(map[string][]*models.Settlement)
+	for _, settlement := range sortedSettlements(settlements) {
+		rowsByMerchant[settlement.MerchantID] = append(rowsByMerchant[settlement.MerchantID], settlement)
+	}
+	adjustmentsByMerchant := make(map[string][]*models.SettlementAdjustment)
+	for _, adjustment := range adjustments {
+		adjustmentsByMerchant[adjustment.MerchantID] = append(adjustmentsByMerchant[adjustment.MerchantID], adjustment)
+	}
+
+	merchantIDs := make([]string, 0, len(rowsByMerchant))
+	for merchantID := range rowsByMerchant {
+		merchantIDs = append(merchantIDs, merchantID)
+	}
+	for merchantID := range adjustmentsByMerchant {
+		if _, ok := rowsByMerchant[merchantID]; !ok {
+			merchantIDs = append(merchantIDs, merchantID)
+		}
+	}
+	sort.Strings(merchantIDs)
+
+	for _, merchantID := range merchantIDs {
+		rows, merchantAdjustments := rowsByMerchant[merchantID], adjustmentsByMerchant[merchantID]
+		file, err := jp.writeJobFile(jobID, MerchantSettlementFilename(merchantID, format), contentType, func(w io.Writer) error {
+			return writeSettlements(w, rows, merchantAdjustments, format)
+		})
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// MerchantSettlementFilename is the name of a merchant's file in a settlement
+// job split by merchant. The merchant ID is path-escaped so it can't name
+// another directory.
+func MerchantSettlementFilename(merchantID string, format models.ExportFormat) string {
+	if format == "" {
+		format = models.ExportFormatCSV
+	}
+	return url.PathEscape(merchantID) + "." + string(format)
+}
+
+// Settlement file line types; an adjustment line has no gross, fees or
+// transactions, and its net amount is the adjustment
+const (
+	settlementLineType = "settlement"
+	adjustmentLineType = "adjustment"
+)
+
+// writeSettlements writes settlements and then adjustments, each in the given
+// order, in the given format
+func writeSettlements(w io.Writer, settlements []*models.Settlement, adjustments []*models.SettlementAdjustment, format models.ExportFormat) error {
+	if format == models.ExportFormatParquet {
+		return writeSettlementParquet(w, settlements, adjustments)
+	}
+	return writeSettlementCSV(w, settlements, adjustments)
+}
+
+// writeSettlementCSV writes settlements and then adjustments as CSV in the
+// given order
+func writeSettlementCSV(w io.Writer, settlements []*models.Settlement, adjustments []*models.SettlementAdjustment) error {
+	writer := csvsafe.NewWriter(w)
 
 	// Write CSV header
 	header := []string{
@@ -413,13 +1212,15 @@ func (jp *JobProcessor) createSettlementCSV(settlements map[string]*models.Settl
 		"transaction_count",
 		"generated_at",
 		"unique_run_id",
+		"line_type",
+		"reason_code",
 	}
 	if err := writer.Write(header); err != nil {
 		return fmt.Errorf("failed to write CSV header: %w", err)
 	}
 
 	// Write settlement data
-	for _, settlement := range settlementSlice {
+	for _, settlement := range settlements {
 		record := []string{
 			settlement.MerchantID,
 			settlement.Date.Format("2006-01-02"),
@@ -429,11 +1230,100 @@ func (jp *JobProcessor) createSettlementCSV(settlements map[string]*models.Settl
 			strconv.Itoa(settlement.TxnCount),
 			settlement.GeneratedAt.Format(time.RFC3339),
 			settlement.UniqueRunID.String(),
+			settlementLineType,
+			"",
 		}
 		if err := writer.Write(record); err != nil {
 			return fmt.Errorf("failed to write CSV record: %w", err)
 		}
 	}
 
+	for _, adjustment := range adjustments {
+		record := []string{
+			adjustment.MerchantID,
+			adjustment.Date.Format("2006-01-02"),
+			"0",
+			"0",
+			strconv.Itoa(adjustment.AmountCents),
+			"0",
+			adjustment.CreatedAt.Format(time.RFC3339),
+			"",
+			adjustmentLineType,
+			string(adjustment.ReasonCode),
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV record: %w", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return nil
+}
+
+// settlementParquetColumns are the columns of a Parquet settlement file, the
+// same as its CSV counterpart
+var settlementParquetColumns = []parquet.Column{
+	{Name: "merchant_id", Type: parquet.String},
+	{Name: "date", Type: parquet.Date},
+	{Name: "gross_cents", Type: parquet.Int32},
+	{Name: "fee_cents", Type: parquet.Int32},
+	{Name: "net_cents", Type: parquet.Int32},
+	{Name: "transaction_count", Type: parquet.Int32},
+	{Name: "generated_at", Type: parquet.Timestamp},
+	{Name: "unique_run_id", Type: parquet.String},
+	{Name: "line_type", Type: parquet.String},
+	{Name: "reason_code", Type: parquet.String},
+}
+
+// writeSettlementParquet writes settlements and then adjustments as Parquet
+// in the given order
+func writeSettlementParquet(w io.Writer, settlements []*models.Settlement, adjustments []*models.SettlementAdjustment) error {
+	writer, err := parquet.NewWriter(w, settlementParquetColumns)
+	if err != nil {
+		return err
+	}
+
+	for _, settlement := range settlements {
+		err := writer.Write([]interface{}{
+			settlement.MerchantID,
+			settlement.Date,
+			settlement.GrossCents,
+			settlement.FeeCents,
+			settlement.NetCents,
+			settlement.TxnCount,
+			settlement.GeneratedAt,
+			settlement.UniqueRunID.String(),
+			settlementLineType,
+			"",
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write Parquet record: %w", err)
+		}
+	}
+
+	for _, adjustment := range adjustments {
+		err := writer.Write([]interface{}{
+			adjustment.MerchantID,
+			adjustment.Date,
+			0,
+			0,
+			adjustment.AmountCents,
+			0,
+			adjustment.CreatedAt,
+			"",
+			adjustmentLineType,
+			string(adjustment.ReasonCode),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write Parquet record: %w", err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to write Parquet: %w", err)
+	}
 	return nil
 }
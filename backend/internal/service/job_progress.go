@@ -0,0 +1,197 @@
+// Package service provides job progress webhooks
+package service
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"indico-backend/internal/config"
+	"indico-backend/internal/errors"
+	"indico-backend/internal/events"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/metrics"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+	"indico-backend/internal/webhook"
+
+	"github.com/google/uuid"
+)
+
+// defaultProgressMilestones are the milestones, in percent, of a progress
+// webhook that doesn't list its own
+var defaultProgressMilestones = []int{25, 50, 75, 100}
+
+// progressNotificationBuffer is how many progress callbacks can wait for
+// delivery before more are dropped
+const progressNotificationBuffer = 100
+
+// progressNotifier sends job.progress.v1 callbacks to the progress webhooks
+// of the jobs a processor is running. Callbacks are delivered one at a time
+// off the job's worker, so a slow receiver can't hold up a job, and are not
+// retried; a receiver that misses one can read the job's status instead.
+type progressNotifier struct {
+	webhookRepo repository.WebhookRepository
+	sender      *webhook.Sender
+
+	// jobs maps the ID of each running job with a progress webhook to the
+	// milestones it has left
+	jobs          sync.Map // map[uuid.UUID]*jobMilestones
+	notifications chan *progressNotification
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// jobMilestones are a running job's milestones not yet passed
+type jobMilestones struct {
+	mu        sync.Mutex
+	webhookID uuid.UUID
+	remaining []int
+}
+
+// progressNotification is a callback waiting for delivery
+type progressNotification struct {
+	webhookID uuid.UUID
+	event     *events.Envelope
+}
+
+// newProgressNotifier creates a notifier whose callbacks are sent as
+// configured for webhooks
+func newProgressNotifier(webhookRepo repository.WebhookRepository, cfg *config.WebhookConfig) *progressNotifier {
+	return &progressNotifier{
+		webhookRepo:   webhookRepo,
+		sender:        newWebhookSender(cfg),
+		notifications: make(chan *progressNotification, progressNotificationBuffer),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+// start starts delivering callbacks
+func (n *progressNotifier) start() {
+	go n.deliver()
+}
+
+// close stops delivering once the callbacks already waiting have been sent
+func (n *progressNotifier) close() {
+	close(n.stop)
+	<-n.done
+}
+
+// track starts following a job's progress, if it has a progress webhook.
+// A retried or re-driven job passes its milestones afresh.
+func (n *progressNotifier) track(job *models.Job) {
+	if job.ProgressWebhookID == nil || len(job.ProgressMilestones) == 0 {
+		return
+	}
+
+	remaining := append([]int(nil), job.ProgressMilestones...)
+	sort.Ints(remaining)
+	n.jobs.Store(job.ID, &jobMilestones{webhookID: *job.ProgressWebhookID, remaining: remaining})
+}
+
+// untrack stops following a job's progress
+func (n *progressNotifier) untrack(jobID uuid.UUID) {
+	n.jobs.Delete(jobID)
+}
+
+// reached queues a callback when a job's progress passes one of its
+// milestones. A jump past several milestones at once sends only the highest,
+// so a receiver isn't sent a burst of stale updates.
+func (n *progressNotifier) reached(job *models.Job, progress float64, processed, total int) {
+	value, ok := n.jobs.Load(job.ID)
+	if !ok {
+		return
+	}
+	milestones := value.(*jobMilestones)
+
+	milestones.mu.Lock()
+	passed := 0
+	for len(milestones.remaining) > 0 && progress >= float64(milestones.remaining[0]) {
+		passed = milestones.remaining[0]
+		milestones.remaining = milestones.remaining[1:]
+	}
+	milestones.mu.Unlock()
+	if passed == 0 {
+		return
+	}
+
+	snapshot := *job
+	snapshot.Progress = progress
+	snapshot.Processed = processed
+	snapshot.Total = total
+
+	notification := &progressNotification{
+		webhookID: milestones.webhookID,
+		event:     events.NewJobProgress(&snapshot, passed),
+	}
+	select {
+	case n.notifications <- notification:
+	default:
+		metrics.JobProgressWebhooks.WithLabelValues("dropped").Inc()
+		logger.WithJobID(job.ID.String()).
+			WithField("milestone", passed).
+			Warn("Progress webhook queue full, dropping callback")
+	}
+}
+
+// deliver sends queued callbacks until the notifier is closed, then sends
+// the callbacks still waiting
+func (n *progressNotifier) deliver() {
+	defer close(n.done)
+
+	for {
+		select {
+		case notification := <-n.notifications:
+			n.send(notification)
+		case <-n.stop:
+			for {
+				select {
+				case notification := <-n.notifications:
+					n.send(notification)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// send delivers one callback to its endpoint
+func (n *progressNotifier) send(notification *progressNotification) {
+	data := notification.event.Data.(*events.JobProgress)
+	log := logger.WithJobID(data.JobID.String()).
+		WithField("webhook_id", notification.webhookID).
+		WithField("milestone", data.Milestone)
+
+	// Callbacks are still sent while the processor stops, so they can't
+	// use its context
+	ctx := context.Background()
+
+	endpoint, err := n.webhookRepo.GetByID(ctx, notification.webhookID)
+	if err != nil {
+		metrics.JobProgressWebhooks.WithLabelValues("failed").Inc()
+		if err == errors.ErrWebhookNotFound {
+			log.Warn("Progress webhook endpoint no longer exists")
+		} else {
+			log.WithError(err).Error("Failed to get progress webhook endpoint")
+		}
+		return
+	}
+
+	delivery, err := n.sender.Send(ctx, endpoint.URL, endpoint.Secret, notification.event)
+	if err != nil {
+		metrics.JobProgressWebhooks.WithLabelValues("failed").Inc()
+		log.WithError(err).Warn("Failed to deliver progress webhook")
+		return
+	}
+	if delivery.StatusCode < 200 || delivery.StatusCode >= 300 {
+		metrics.JobProgressWebhooks.WithLabelValues("failed").Inc()
+		log.WithField("status_code", delivery.StatusCode).Warn("Progress webhook rejected by receiver")
+		return
+	}
+
+	metrics.JobProgressWebhooks.WithLabelValues("delivered").Inc()
+	log.WithField("duration_ms", delivery.Duration.Milliseconds()).Info("Progress webhook delivered")
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"indico-backend/internal/config"
+	"indico-backend/internal/events"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+	"indico-backend/internal/webhook"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// endpointStore is a webhook repository holding a single endpoint
+type endpointStore struct {
+	repository.WebhookRepository
+	endpoint *models.WebhookEndpoint
+}
+
+func (s *endpointStore) GetByID(ctx context.Context, id uuid.UUID) (*models.WebhookEndpoint, error) {
+	return s.endpoint, nil
+}
+
+func TestProgressNotifier(t *testing.T) {
+	const secret = "whsec_0123456789abcdef0123456789abcdef"
+	var mu sync.Mutex
+	var received []events.JobProgress
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event struct {
+			Type string             `json:"type"`
+			Data events.JobProgress `json:"data"`
+		}
+		assert.Equal(t, events.JobProgressV1, r.Header.Get(webhook.EventHeader))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+
+		mu.Lock()
+		received = append(received, event.Data)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	endpoint := &models.WebhookEndpoint{ID: uuid.New(), URL: receiver.URL, Secret: secret}
+	notifier := newProgressNotifier(&endpointStore{endpoint: endpoint}, &config.WebhookConfig{DeliveryTimeout: time.Second})
+	notifier.start()
+
+	job := &models.Job{
+		ID:                 uuid.New(),
+		Type:               models.JobTypeSettlement,
+		ProgressWebhookID:  &endpoint.ID,
+		ProgressMilestones: []int{25, 50, 75, 100},
+	}
+	untracked := &models.Job{ID: uuid.New(), Type: models.JobTypeSettlement}
+	notifier.track(job)
+	notifier.track(untracked)
+
+	notifier.reached(job, 10, 10, 100)
+	notifier.reached(job, 30, 30, 100)
+	notifier.reached(job, 40, 40, 100)
+	// Passing two milestones at once sends the higher one
+	notifier.reached(job, 80, 80, 100)
+	notifier.reached(untracked, 100, 100, 100)
+	notifier.reached(job, 100, 100, 100)
+	notifier.untrack(job.ID)
+	notifier.reached(job, 100, 100, 100)
+	notifier.close()
+
+	require.Len(t, received, 3)
+	assert.Equal(t, 25, received[0].Milestone)
+	assert.Equal(t, 30.0, received[0].Progress)
+	assert.Equal(t, 75, received[1].Milestone)
+	assert.Equal(t, 100, received[2].Milestone)
+	assert.Equal(t, job.ID, received[2].JobID)
+	assert.Equal(t, 100, received[2].Total)
+}
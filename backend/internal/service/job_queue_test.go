@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// queueFullError queues a job on a processor whose queue has no room and
+// returns the rejection
+func queueFullError(t *testing.T, jp *JobProcessor) *errors.AppError {
+	t.Helper()
+	err := jp.QueueJob(context.Background(), &models.Job{ID: uuid.New(), Type: models.JobTypeSettlement})
+
+	var appErr *errors.AppError
+	require.True(t, stderrors.As(err, &appErr), "got %v", err)
+	return appErr
+}
+
+func TestQueueJobRejectsWhenFull(t *testing.T) {
+	jp := &JobProcessor{jobQueue: make(chan *models.Job)}
+
+	// Before any dequeue the hint falls back to the default
+	appErr := queueFullError(t, jp)
+	assert.Equal(t, errors.ErrCodeQueueFull, appErr.Code)
+	assert.Equal(t, http.StatusTooManyRequests, appErr.StatusCode)
+	assert.Equal(t, defaultQueueRetryAfter, appErr.RetryAfter)
+	assert.Equal(t, "retry_after_seconds=30", appErr.Details)
+
+	// A cancelled caller gets its own error, not a full queue
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, jp.QueueJob(ctx, &models.Job{ID: uuid.New()}), context.Canceled)
+}
+
+func TestQueueJobRetryAfterBounds(t *testing.T) {
+	tests := map[string]struct {
+		avgInterval time.Duration
+		sinceLast   time.Duration
+		min, max    time.Duration
+	}{
+		"fast drain is clamped up":     {avgInterval: 10 * time.Millisecond, min: minQueueRetryAfter, max: minQueueRetryAfter},
+		"slow drain is clamped down":   {avgInterval: time.Hour, min: maxQueueRetryAfter, max: maxQueueRetryAfter},
+		"one gap after the last":       {avgInterval: 20 * time.Second, min: 19 * time.Second, max: 20 * time.Second},
+		"time since the last counts":   {avgInterval: 20 * time.Second, sinceLast: 15 * time.Second, min: 4 * time.Second, max: 5 * time.Second},
+		"overdue slot waits a minimum": {avgInterval: 20 * time.Second, sinceLast: time.Minute, min: minQueueRetryAfter, max: minQueueRetryAfter},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			jp := &JobProcessor{jobQueue: make(chan *models.Job)}
+			jp.drain.avgInterval = tt.avgInterval
+			jp.drain.last = time.Now().Add(-tt.sinceLast)
+
+			appErr := queueFullError(t, jp)
+			assert.Equal(t, http.StatusTooManyRequests, appErr.StatusCode)
+			assert.GreaterOrEqual(t, appErr.RetryAfter, tt.min)
+			assert.LessOrEqual(t, appErr.RetryAfter, tt.max)
+		})
+	}
+}
+
+func TestDrainRateFollowsDequeues(t *testing.T) {
+	var d drainRate
+	d.record()
+	assert.Equal(t, defaultQueueRetryAfter, d.retryAfter(), "one dequeue gives no gap yet")
+
+	d.record()
+	assert.Equal(t, minQueueRetryAfter, d.retryAfter(), "back-to-back dequeues clamp to the minimum")
+}
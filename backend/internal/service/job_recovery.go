@@ -0,0 +1,100 @@
+// Package service provides recovery of jobs left behind by a restart
+package service
+
+import (
+	"context"
+	"time"
+
+	"indico-backend/internal/logger"
+	"indico-backend/internal/metrics"
+	"indico-backend/internal/models"
+)
+
+// jobInterruptedMessage is the error recorded on a job whose process stopped
+// while it ran
+const jobInterruptedMessage = "job interrupted by a restart"
+
+// recoverJobs picks up the jobs a previous process left behind and returns
+// those to queue again. QUEUED jobs lived only in that process's memory
+// queue; RUNNING ones go back to QUEUED for another attempt, or are
+// dead-lettered if that was their last; CANCELLING ones have no worker left
+// to stop and become CANCELLED. Only jobs last updated before since are
+// considered queued, and only those idle for RecoveryAge running, so jobs
+// another replica is working on are left alone. A queued job may still sit
+// in another replica's memory as well, but MarkStarted lets only one run it.
+func (jp *JobProcessor) recoverJobs(ctx context.Context, since time.Time) []*models.Job {
+	log := logger.WithComponent("job_processor")
+
+	var recovered []*models.Job
+
+	interrupted, err := jp.jobRepo.ListByStatus(ctx,
+		[]models.JobStatus{models.JobStatusRunning, models.JobStatusCancelling},
+		since.Add(-jp.config.RecoveryAge))
+	if err != nil {
+		log.WithError(err).Error("Failed to list interrupted jobs")
+	}
+	for _, job := range interrupted {
+		jobLog := logger.WithJobID(job.ID.String()).WithField("status", job.Status)
+
+		outcome := "requeued"
+		switch {
+		case job.Status == models.JobStatusCancelling:
+			outcome = "cancelled"
+			if err := jp.jobRepo.MarkCancelled(ctx, job.ID); err != nil {
+				jobLog.WithError(err).Error("Failed to mark interrupted job as cancelled")
+				continue
+			}
+
+		case job.Attempts >= 1+jp.config.RetryAttempts:
+			outcome = "dead_lettered"
+			if err := jp.jobRepo.MarkDeadLettered(ctx, job.ID, jobInterruptedMessage); err != nil {
+				jobLog.WithError(err).Error("Failed to dead-letter interrupted job")
+				continue
+			}
+			metrics.JobsDeadLettered.WithLabelValues(string(job.Type)).Inc()
+
+		default:
+			if err := jp.jobRepo.MarkRetrying(ctx, job.ID, jobInterruptedMessage); err != nil {
+				jobLog.WithError(err).Error("Failed to requeue interrupted job")
+				continue
+			}
+			job.Status = models.JobStatusQueued
+			recovered = append(recovered, job)
+		}
+
+		jobLog.WithField("outcome", outcome).Warn("Recovered interrupted job")
+		metrics.JobsRecovered.WithLabelValues(string(job.Type), outcome).Inc()
+	}
+
+	queued, err := jp.jobRepo.ListByStatus(ctx, []models.JobStatus{models.JobStatusQueued}, since)
+	if err != nil {
+		log.WithError(err).Error("Failed to list queued jobs")
+	}
+	for _, job := range queued {
+		metrics.JobsRecovered.WithLabelValues(string(job.Type), "requeued").Inc()
+	}
+	recovered = append(recovered, queued...)
+
+	if len(interrupted) > 0 || len(queued) > 0 {
+		log.WithField("interrupted", len(interrupted)).
+			WithField("queued", len(queued)).
+			Info("Recovered jobs left by a previous process")
+	}
+
+	return recovered
+}
+
+// requeue feeds recovered jobs to the workers. It waits for room rather
+// than rejecting jobs when there are more than the queue holds.
+func (jp *JobProcessor) requeue(jobs []*models.Job) {
+	defer jp.recovery.Done()
+
+	for _, job := range jobs {
+		select {
+		case jp.jobQueue <- job:
+			metrics.JobQueueDepth.Set(float64(len(jp.jobQueue)))
+		case <-jp.ctx.Done():
+			return
+		}
+	}
+}
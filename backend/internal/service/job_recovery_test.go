@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"indico-backend/internal/config"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recoveryStore is a job repository holding jobs in memory for recovery
+type recoveryStore struct {
+	repository.JobRepository
+
+	jobs   []*models.Job
+	errors map[uuid.UUID]string
+}
+
+func (s *recoveryStore) ListByStatus(ctx context.Context, statuses []models.JobStatus, updatedBefore time.Time) ([]*models.Job, error) {
+	var jobs []*models.Job
+	for _, job := range s.jobs {
+		for _, status := range statuses {
+			if job.Status == status && job.UpdatedAt.Before(updatedBefore) {
+				copied := *job
+				jobs = append(jobs, &copied)
+			}
+		}
+	}
+	return jobs, nil
+}
+
+func (s *recoveryStore) move(id uuid.UUID, from, to models.JobStatus, errMsg string) {
+	for _, job := range s.jobs {
+		if job.ID == id && job.Status == from {
+			job.Status = to
+			job.UpdatedAt = time.Now()
+			s.errors[id] = errMsg
+		}
+	}
+}
+
+func (s *recoveryStore) MarkRetrying(ctx context.Context, id uuid.UUID, errMsg string) error {
+	s.move(id, models.JobStatusRunning, models.JobStatusQueued, errMsg)
+	return nil
+}
+
+func (s *recoveryStore) MarkDeadLettered(ctx context.Context, id uuid.UUID, errMsg string) error {
+	s.move(id, models.JobStatusRunning, models.JobStatusDeadLettered, errMsg)
+	return nil
+}
+
+func (s *recoveryStore) MarkCancelled(ctx context.Context, id uuid.UUID) error {
+	s.move(id, models.JobStatusCancelling, models.JobStatusCancelled, "")
+	return nil
+}
+
+func TestRecoverJobs(t *testing.T) {
+	since := time.Now()
+	job := func(status models.JobStatus, attempts int, idle time.Duration) *models.Job {
+		return &models.Job{
+			ID:        uuid.New(),
+			Type:      models.JobTypeSettlement,
+			Status:    status,
+			Attempts:  attempts,
+			UpdatedAt: since.Add(-idle),
+		}
+	}
+
+	queued := job(models.JobStatusQueued, 0, time.Second)
+	interrupted := job(models.JobStatusRunning, 1, time.Hour)
+	lastAttempt := job(models.JobStatusRunning, 2, time.Hour)
+	cancelling := job(models.JobStatusCancelling, 1, time.Hour)
+	// Still running on another replica
+	busy := job(models.JobStatusRunning, 1, time.Second)
+	finished := job(models.JobStatusCompleted, 1, time.Hour)
+
+	store := &recoveryStore{
+		jobs:   []*models.Job{queued, interrupted, lastAttempt, cancelling, busy, finished},
+		errors: make(map[uuid.UUID]string),
+	}
+	jp := &JobProcessor{
+		jobRepo: store,
+		config:  &config.JobsConfig{RetryAttempts: 1, RecoveryAge: time.Minute},
+	}
+
+	recovered := jp.recoverJobs(context.Background(), since)
+
+	ids := make([]uuid.UUID, len(recovered))
+	for i, job := range recovered {
+		ids[i] = job.ID
+	}
+	assert.ElementsMatch(t, []uuid.UUID{queued.ID, interrupted.ID}, ids)
+
+	assert.Equal(t, models.JobStatusQueued, interrupted.Status)
+	assert.Equal(t, jobInterruptedMessage, store.errors[interrupted.ID])
+	assert.Equal(t, models.JobStatusDeadLettered, lastAttempt.Status)
+	assert.Equal(t, models.JobStatusCancelled, cancelling.Status)
+	assert.Equal(t, models.JobStatusRunning, busy.Status)
+	assert.Equal(t, models.JobStatusCompleted, finished.Status)
+}
+
+func TestRequeueStopsWithProcessor(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	jp := &JobProcessor{
+		jobQueue: make(chan *models.Job, 1),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	// Only one of the jobs fits; the rest wait until the processor stops
+	jp.recovery.Add(1)
+	go jp.requeue([]*models.Job{{ID: uuid.New()}, {ID: uuid.New()}, {ID: uuid.New()}})
+
+	require.Eventually(t, func() bool { return len(jp.jobQueue) == 1 }, time.Second, time.Millisecond)
+	cancel()
+	jp.recovery.Wait()
+	assert.Len(t, jp.jobQueue, 1)
+}
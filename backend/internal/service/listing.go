@@ -0,0 +1,328 @@
+// Package service provides filtered listings and row streaming for list endpoints
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// maxStreamRows caps streamed listings; larger extracts belong in an export job
+const maxStreamRows = 10000
+
+// Transaction sample sizes
+const (
+	defaultSampleSize = 100
+	maxSampleSize     = 1000
+)
+
+// inclusiveDateRange parses optional inclusive YYYY-MM-DD dates into a
+// half-open [from, to) range
+func inclusiveDateRange(from, to string) (*time.Time, *time.Time, error) {
+	var start, end *time.Time
+
+	if from != "" {
+		parsed, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			return nil, nil, errors.NewValidationError("invalid from date format, expected YYYY-MM-DD")
+		}
+		start = &parsed
+	}
+
+	if to != "" {
+		parsed, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			return nil, nil, errors.NewValidationError("invalid to date format, expected YYYY-MM-DD")
+		}
+		if start != nil && parsed.Before(*start) {
+			return nil, nil, errors.NewValidationError("to date must be after from date")
+		}
+		// Add one day to make the range inclusive
+		parsed = parsed.AddDate(0, 0, 1)
+		end = &parsed
+	}
+
+	return start, end, nil
+}
+
+// defaultOrderListMaxOffset is the deepest order list offset served when no
+// orders config is given
+const defaultOrderListMaxOffset = 10000
+
+// streamLimit clamps the row limit of a streamed listing
+func streamLimit(limit, offset int) (int, int) {
+	if limit <= 0 || limit > maxStreamRows {
+		limit = maxStreamRows
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+// StreamOrders calls fn for each order in the same order as ListOrders. The
+// order passed to fn is only valid until it returns.
+func (s *orderService) StreamOrders(ctx context.Context, limit, offset int, fn func(*models.Order) error) error {
+	limit, offset = streamLimit(limit, offset)
+	if err := s.checkListOffset(offset); err != nil {
+		return err
+	}
+
+	if err := s.orderRepo.Stream(ctx, limit, offset, fn); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to stream orders")
+		return err
+	}
+
+	return nil
+}
+
+// checkListOffset rejects an offset page deeper than the configured limit,
+// which the database can only serve by reading and discarding every row
+// before it
+func (s *orderService) checkListOffset(offset int) error {
+	if s.maxListOffset > 0 && offset > s.maxListOffset {
+		return errors.NewOffsetTooDeepError(s.maxListOffset)
+	}
+	return nil
+}
+
+// ListOrdersPage lists a page of orders, newest first, by offset or, when
+// req.Cursor is set, after the cursor. A full page carries the cursor of
+// the next one, so clients can switch to cursors at any depth.
+func (s *orderService) ListOrdersPage(ctx context.Context, req *models.ListOrdersRequest) (*models.OrderPage, error) {
+	limit := req.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 10 // Default limit
+	}
+
+	var orders []*models.Order
+	if req.Cursor != "" {
+		after, err := decodeOrderCursor(req.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		orders, err = s.orderRepo.ListAfter(ctx, after, limit)
+		if err != nil {
+			logger.WithContext(ctx).WithError(err).Error("Failed to list orders")
+			return nil, err
+		}
+	} else {
+		var err error
+		orders, err = s.ListOrders(ctx, limit, req.Offset)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	page := &models.OrderPage{Orders: orders}
+	if len(orders) == limit {
+		page.NextCursor = encodeOrderCursor(orders[len(orders)-1])
+	}
+	return page, nil
+}
+
+// CountOrders returns how many orders ListOrdersPage pages through with the
+// same filters
+func (s *orderService) CountOrders(ctx context.Context, req *models.ListOrdersRequest) (int, error) {
+	// A client reference matches at most one order
+	if req.ClientReference != "" {
+		_, err := s.orderRepo.GetByClientReference(ctx, req.ClientReference)
+		if err == errors.ErrOrderNotFound {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		return 1, nil
+	}
+
+	count, err := s.orderRepo.Count(ctx)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to count orders")
+		return 0, err
+	}
+	return count, nil
+}
+
+// encodeOrderCursor returns the cursor of the page that follows order
+func encodeOrderCursor(order *models.Order) string {
+	key := order.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + order.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+// decodeOrderCursor parses a cursor made by encodeOrderCursor
+func decodeOrderCursor(cursor string) (*models.OrderCursor, error) {
+	invalid := errors.NewValidationError("invalid cursor, expected the next_cursor of a previous page")
+
+	key, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, invalid
+	}
+	createdAt, id, ok := strings.Cut(string(key), "|")
+	if !ok {
+		return nil, invalid
+	}
+	after := &models.OrderCursor{}
+	if after.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return nil, invalid
+	}
+	if after.ID, err = uuid.Parse(id); err != nil {
+		return nil, invalid
+	}
+	return after, nil
+}
+
+// transactionFilter validates transaction list filters
+func transactionFilter(req *models.ListTransactionsRequest) (*models.TransactionFilter, error) {
+	switch req.Status {
+	case "", models.TransactionStatusPending, models.TransactionStatusCompleted,
+		models.TransactionStatusFailed, models.TransactionStatusRefunded:
+	default:
+		return nil, errors.NewValidationError("invalid status, expected PENDING, COMPLETED, FAILED or REFUNDED")
+	}
+
+	from, to, err := inclusiveDateRange(req.From, req.To)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TransactionFilter{
+		MerchantID: req.MerchantID,
+		Status:     req.Status,
+		From:       from,
+		To:         to,
+	}, nil
+}
+
+// ListTransactions lists transactions by ID, optionally filtered by merchant,
+// status and payment date
+func (s *transactionService) ListTransactions(ctx context.Context, req *models.ListTransactionsRequest, limit, offset int) ([]*models.Transaction, error) {
+	filter, err := transactionFilter(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 || limit > 100 {
+		limit = 10 // Default limit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	transactions, err := s.txRepo.List(ctx, filter, limit, offset)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to list transactions")
+		return nil, err
+	}
+
+	return transactions, nil
+}
+
+// SampleTransactions returns up to n transactions picked at random from those
+// ListTransactions would match, for spot-checking settlement inputs without
+// paging through the whole range. n defaults to 100 and is capped at 1000.
+func (s *transactionService) SampleTransactions(ctx context.Context, req *models.ListTransactionsRequest, n int) ([]*models.Transaction, error) {
+	filter, err := transactionFilter(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if n <= 0 {
+		n = defaultSampleSize
+	}
+	if n > maxSampleSize {
+		n = maxSampleSize
+	}
+
+	transactions, err := s.txRepo.Sample(ctx, filter, n)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to sample transactions")
+		return nil, err
+	}
+	if transactions == nil {
+		transactions = []*models.Transaction{}
+	}
+
+	return transactions, nil
+}
+
+// StreamTransactions calls fn for each transaction ListTransactions would
+// return. The transaction passed to fn is only valid until it returns.
+func (s *transactionService) StreamTransactions(ctx context.Context, req *models.ListTransactionsRequest, limit, offset int, fn func(*models.Transaction) error) error {
+	filter, err := transactionFilter(req)
+	if err != nil {
+		return err
+	}
+
+	limit, offset = streamLimit(limit, offset)
+
+	if err := s.txRepo.Stream(ctx, filter, limit, offset, fn); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to stream transactions")
+		return err
+	}
+
+	return nil
+}
+
+// settlementFilter validates settlement list filters
+func settlementFilter(req *models.ListSettlementsRequest) (*models.SettlementFilter, error) {
+	from, to, err := inclusiveDateRange(req.From, req.To)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.SettlementFilter{
+		MerchantID: req.MerchantID,
+		From:       from,
+		To:         to,
+	}, nil
+}
+
+// ListSettlements lists current settlements by date, optionally filtered by
+// merchant and settlement date
+func (s *settlementService) ListSettlements(ctx context.Context, req *models.ListSettlementsRequest, limit, offset int) ([]*models.Settlement, error) {
+	filter, err := settlementFilter(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 || limit > 100 {
+		limit = 10 // Default limit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	settlements, err := s.settleRepo.List(ctx, filter, limit, offset)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to list settlements")
+		return nil, err
+	}
+
+	return settlements, nil
+}
+
+// StreamSettlements calls fn for each settlement ListSettlements would
+// return. The settlement passed to fn is only valid until it returns.
+func (s *settlementService) StreamSettlements(ctx context.Context, req *models.ListSettlementsRequest, limit, offset int, fn func(*models.Settlement) error) error {
+	filter, err := settlementFilter(req)
+	if err != nil {
+		return err
+	}
+
+	limit, offset = streamLimit(limit, offset)
+
+	if err := s.settleRepo.Stream(ctx, filter, limit, offset, fn); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to stream settlements")
+		return err
+	}
+
+	return nil
+}
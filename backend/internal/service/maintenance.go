@@ -0,0 +1,180 @@
+// Package service provides the API maintenance mode
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+)
+
+// MaintenanceMode tracks whether the API is in maintenance mode. The state is
+// stored in the database and polled, so toggling it on one replica reaches
+// the others within one poll interval; the replica that made the change sees
+// it at once.
+type MaintenanceMode struct {
+	repo     repository.MaintenanceRepository
+	interval time.Duration
+	state    atomic.Pointer[models.MaintenanceState]
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewMaintenanceMode creates a maintenance mode that starts disabled until
+// Start loads the stored state
+func NewMaintenanceMode(repo repository.MaintenanceRepository, interval time.Duration) *MaintenanceMode {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	m := &MaintenanceMode{
+		repo:     repo,
+		interval: interval,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	m.state.Store(&models.MaintenanceState{})
+	return m
+}
+
+// Start loads the stored state and starts polling for changes
+func (m *MaintenanceMode) Start() {
+	m.refresh()
+
+	if m.interval <= 0 {
+		return
+	}
+
+	m.wg.Add(1)
+	go m.run()
+}
+
+// Stop stops polling
+func (m *MaintenanceMode) Stop() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+func (m *MaintenanceMode) run() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.refresh()
+		}
+	}
+}
+
+// refresh reloads the stored state, keeping the last known state if the
+// database can't be read
+func (m *MaintenanceMode) refresh() {
+	state, err := m.repo.Get(m.ctx)
+	if err != nil {
+		logger.WithComponent("maintenance").WithError(err).Warn("Failed to refresh maintenance mode")
+		return
+	}
+	m.store(state)
+}
+
+// store records a new state, logging when maintenance mode flips
+func (m *MaintenanceMode) store(state *models.MaintenanceState) {
+	previous := m.state.Swap(state)
+	if previous.Enabled != state.Enabled {
+		logger.WithComponent("maintenance").
+			WithField("enabled", state.Enabled).
+			WithField("message", state.Message).
+			Warn("Maintenance mode changed")
+	}
+}
+
+// Enabled reports whether maintenance mode is on; a nil MaintenanceMode is
+// never enabled
+func (m *MaintenanceMode) Enabled() bool {
+	if m == nil {
+		return false
+	}
+	return m.state.Load().Enabled
+}
+
+// State returns the current maintenance state
+func (m *MaintenanceMode) State() *models.MaintenanceState {
+	return m.state.Load()
+}
+
+// Set turns maintenance mode on or off for every replica
+func (m *MaintenanceMode) Set(ctx context.Context, enabled bool, message string) (*models.MaintenanceState, error) {
+	state, err := m.repo.Set(ctx, enabled, message)
+	if err != nil {
+		return nil, err
+	}
+
+	m.store(state)
+	return state, nil
+}
+
+// maintenanceService implements MaintenanceService
+type maintenanceService struct {
+	mode    *MaintenanceMode
+	jobRepo repository.JobRepository
+}
+
+// NewMaintenanceService creates a new maintenance service on the shared
+// maintenance mode, or on an unpolled one when none is provided
+func NewMaintenanceService(deps *Dependencies) MaintenanceService {
+	mode := deps.Maintenance
+	if mode == nil {
+		mode = NewMaintenanceMode(deps.MaintenanceRepo, 0)
+	}
+
+	return &maintenanceService{
+		mode:    mode,
+		jobRepo: deps.JobRepo,
+	}
+}
+
+func (s *maintenanceService) State() *models.MaintenanceState {
+	return s.mode.State()
+}
+
+// GetMaintenance returns the maintenance state with the number of jobs
+// still running, which should reach zero before a migration starts
+func (s *maintenanceService) GetMaintenance(ctx context.Context) (*models.MaintenanceStatus, error) {
+	var running int
+	for _, status := range []models.JobStatus{models.JobStatusRunning, models.JobStatusCancelling} {
+		count, err := s.jobRepo.CountByStatus(ctx, status)
+		if err != nil {
+			logger.WithContext(ctx).WithError(err).Error("Failed to count running jobs")
+			return nil, err
+		}
+		running += count
+	}
+
+	return &models.MaintenanceStatus{
+		MaintenanceState: *s.mode.State(),
+		RunningJobs:      running,
+	}, nil
+}
+
+func (s *maintenanceService) SetMaintenance(ctx context.Context, req *models.SetMaintenanceRequest) (*models.MaintenanceStatus, error) {
+	message := ""
+	if *req.Enabled {
+		message = req.Message
+	}
+
+	if _, err := s.mode.Set(ctx, *req.Enabled, message); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to set maintenance mode")
+		return nil, err
+	}
+
+	return s.GetMaintenance(ctx)
+}
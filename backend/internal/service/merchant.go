@@ -0,0 +1,126 @@
+// Package service provides business logic implementation
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+	"indico-backend/internal/validation"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// merchantService implements MerchantService
+type merchantService struct {
+	txRepo     repository.TransactionReader
+	settleRepo repository.SettlementReader
+	payoutRepo repository.PayoutRepository
+}
+
+// NewMerchantService creates a new merchant service
+func NewMerchantService(deps *Dependencies) MerchantService {
+	return &merchantService{
+		txRepo:     deps.TxRepo,
+		settleRepo: deps.SettleRepo,
+		payoutRepo: deps.PayoutRepo,
+	}
+}
+
+// GetDashboard composes a merchant's period summary. The period defaults to
+// the current calendar month (UTC); from and to are inclusive YYYY-MM-DD dates.
+func (s *merchantService) GetDashboard(ctx context.Context, merchantID, from, to string) (*models.MerchantDashboard, error) {
+	merchantID = strings.TrimSpace(merchantID)
+	if merchantID == "" {
+		return nil, errors.NewValidationError("merchant id is required")
+	}
+	if err := validation.ID("merchant id", merchantID); err != nil {
+		return nil, err
+	}
+
+	periodFrom, periodTo, err := dashboardPeriod(from, to, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	// periodTo is inclusive; the repositories take a half-open range
+	end := periodTo.AddDate(0, 0, 1)
+
+	var (
+		txSummary        *models.MerchantTransactionSummary
+		settleSummary    *models.MerchantSettlementSummary
+		unsettledSummary *models.MerchantUnsettledSummary
+		paidOutCents     int
+	)
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		var err error
+		txSummary, err = s.txRepo.SummarizeByMerchant(gctx, merchantID, periodFrom, end)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		settleSummary, err = s.settleRepo.SummarizeByMerchant(gctx, merchantID, periodFrom, end)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		unsettledSummary, err = s.txRepo.SummarizeUnsettledByMerchant(gctx, merchantID, periodFrom, end)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		paidOutCents, err = s.payoutRepo.SumExportedByMerchant(gctx, merchantID, periodFrom, end)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		logger.WithContext(ctx).WithError(err).WithField("merchant_id", merchantID).Error("Failed to build merchant dashboard")
+		return nil, err
+	}
+
+	return &models.MerchantDashboard{
+		MerchantID:         merchantID,
+		PeriodFrom:         periodFrom.Format("2006-01-02"),
+		PeriodTo:           periodTo.Format("2006-01-02"),
+		Transactions:       *txSummary,
+		Settlements:        *settleSummary,
+		Unsettled:          *unsettledSummary,
+		PendingPayoutCents: settleSummary.NetCents + settleSummary.AdjustmentCents - paidOutCents,
+		GeneratedAt:        time.Now(),
+	}, nil
+}
+
+// dashboardPeriod resolves the inclusive dashboard period, defaulting to the
+// calendar month containing now
+func dashboardPeriod(from, to string, now time.Time) (time.Time, time.Time, error) {
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	periodFrom := monthStart
+	if from != "" {
+		parsed, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.NewValidationError("invalid from date format, expected YYYY-MM-DD")
+		}
+		periodFrom = parsed
+	}
+
+	periodTo := monthStart.AddDate(0, 1, -1)
+	if to != "" {
+		parsed, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.NewValidationError("invalid to date format, expected YYYY-MM-DD")
+		}
+		periodTo = parsed
+	}
+
+	if periodTo.Before(periodFrom) {
+		return time.Time{}, time.Time{}, errors.NewValidationError("to date must be after from date")
+	}
+
+	return periodFrom, periodTo, nil
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/metrics"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+)
+
+// orderDedup absorbs repeated order requests, such as a double-clicked buy
+// button, from buyers that send no client reference: the same buyer
+// ordering the same quantity of a product again within window gets the
+// order already placed. Requests racing on one replica wait for the first
+// to finish, and orders placed by other replicas are found in Postgres.
+type orderDedup struct {
+	window time.Duration
+	orders repository.OrderReader
+
+	mu      sync.Mutex
+	entries map[orderDedupKey]*orderDedupEntry
+	swept   time.Time
+}
+
+type orderDedupKey struct {
+	buyerID   string
+	productID int
+	quantity  int
+}
+
+type orderDedupEntry struct {
+	// mu is held while an order under the key is being placed; order and
+	// at are written holding both it and orderDedup.mu, so either guards
+	// reading them
+	mu    sync.Mutex
+	order *models.Order
+	at    time.Time
+
+	// refs counts the requests holding the entry, guarded by orderDedup.mu
+	refs int
+}
+
+func newOrderDedup(window time.Duration, orders repository.OrderReader) *orderDedup {
+	return &orderDedup{
+		window:  window,
+		orders:  orders,
+		entries: make(map[orderDedupKey]*orderDedupEntry),
+	}
+}
+
+// place returns the order placed for the same buyer, product and quantity
+// within the window, or places one with fn and remembers it
+func (d *orderDedup) place(ctx context.Context, req *models.CreateOrderRequest, fn func(ctx context.Context) (*models.Order, error)) (*models.Order, error) {
+	key := orderDedupKey{buyerID: req.BuyerID, productID: req.ProductID, quantity: req.Quantity}
+	entry := d.acquire(key)
+	defer d.release(key, entry)
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if entry.order != nil && time.Since(entry.at) < d.window {
+		return d.duplicate(ctx, entry.order, "replica"), nil
+	}
+
+	order, err := d.orders.GetRecentDuplicate(ctx, req.BuyerID, req.ProductID, req.Quantity, d.window)
+	switch {
+	case err == nil:
+		return d.duplicate(ctx, order, "database"), nil
+	case err != errors.ErrOrderNotFound:
+		// Deduplication is best effort; a failed lookup places the order
+		logger.WithContext(ctx).WithError(err).Warn("Failed to look up recent duplicate order")
+	}
+
+	order, err = fn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	entry.order, entry.at = order, time.Now()
+	d.mu.Unlock()
+	return order, nil
+}
+
+func (d *orderDedup) duplicate(ctx context.Context, order *models.Order, source string) *models.Order {
+	metrics.OrdersDeduplicated.WithLabelValues(source).Inc()
+	logger.WithContext(ctx).
+		WithField("order_id", order.ID).
+		WithField("buyer_id", order.BuyerID).
+		Info("Returning order already placed by the buyer within the dedup window")
+	return order
+}
+
+// acquire returns the entry for key, dropping entries past the window at
+// most once per window
+func (d *orderDedup) acquire(key orderDedupKey) *orderDedupEntry {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if now := time.Now(); now.Sub(d.swept) >= d.window {
+		for k, e := range d.entries {
+			if e.refs == 0 && now.Sub(e.at) >= d.window {
+				delete(d.entries, k)
+			}
+		}
+		d.swept = now
+	}
+
+	entry, ok := d.entries[key]
+	if !ok {
+		entry = &orderDedupEntry{}
+		d.entries[key] = entry
+	}
+	entry.refs++
+	return entry
+}
+
+// release drops the caller's hold on entry, forgetting it right away when
+// it remembers no order
+func (d *orderDedup) release(key orderDedupKey, entry *orderDedupEntry) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entry.refs--
+	if entry.refs == 0 && entry.order == nil {
+		delete(d.entries, key)
+	}
+}
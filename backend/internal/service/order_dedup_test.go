@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// noRecentOrders finds no order placed by another replica
+type noRecentOrders struct {
+	repository.OrderReader
+}
+
+func (noRecentOrders) GetRecentDuplicate(ctx context.Context, buyerID string, productID, quantity int, within time.Duration) (*models.Order, error) {
+	return nil, errors.ErrOrderNotFound
+}
+
+func TestOrderDedupPlacesRacingDuplicatesOnce(t *testing.T) {
+	dedup := newOrderDedup(time.Minute, noRecentOrders{})
+	req := &models.CreateOrderRequest{ProductID: 1, Quantity: 2, BuyerID: "buyer_1"}
+
+	var placed atomic.Int32
+	place := func(ctx context.Context) (*models.Order, error) {
+		placed.Add(1)
+		return &models.Order{ID: uuid.New(), BuyerID: req.BuyerID}, nil
+	}
+
+	var wg sync.WaitGroup
+	ids := make([]uuid.UUID, 10)
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			order, err := dedup.place(context.Background(), req, place)
+			if assert.NoError(t, err) {
+				ids[i] = order.ID
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), placed.Load())
+	for _, id := range ids {
+		assert.Equal(t, ids[0], id)
+	}
+
+	// Another quantity is not a duplicate
+	other := &models.CreateOrderRequest{ProductID: 1, Quantity: 1, BuyerID: "buyer_1"}
+	_, err := dedup.place(context.Background(), other, place)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), placed.Load())
+}
+
+func TestOrderDedupForgetsFailuresAndExpiredOrders(t *testing.T) {
+	dedup := newOrderDedup(20*time.Millisecond, noRecentOrders{})
+	req := &models.CreateOrderRequest{ProductID: 1, Quantity: 1, BuyerID: "buyer_1"}
+
+	_, err := dedup.place(context.Background(), req, func(ctx context.Context) (*models.Order, error) {
+		return nil, errors.ErrOutOfStock
+	})
+	require.ErrorIs(t, err, errors.ErrOutOfStock)
+	assert.Empty(t, dedup.entries)
+
+	first, err := dedup.place(context.Background(), req, func(ctx context.Context) (*models.Order, error) {
+		return &models.Order{ID: uuid.New()}, nil
+	})
+	require.NoError(t, err)
+
+	time.Sleep(30 * time.Millisecond)
+	second, err := dedup.place(context.Background(), req, func(ctx context.Context) (*models.Order, error) {
+		return &models.Order{ID: uuid.New()}, nil
+	})
+	require.NoError(t, err)
+	assert.NotEqual(t, first.ID, second.ID)
+}
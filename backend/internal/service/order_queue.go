@@ -0,0 +1,140 @@
+// Package service provides per-product ordered order processing
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/metrics"
+	"indico-backend/internal/models"
+)
+
+// OrderQueue serializes order placement per product. Products are hashed
+// across a fixed number of shards, each a bounded queue drained by a single
+// worker, so orders for the same product are placed one after another
+// instead of racing for the product's row lock. Placement itself is
+// unchanged: each order still commits through the ORDER_PLACEMENT saga
+// before its caller is answered, so the queue only holds callers that are
+// waiting and nothing acknowledged is lost if the process dies.
+type OrderQueue struct {
+	shards []chan *queuedOrder
+
+	mu      sync.RWMutex
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+// queuedOrder is one order waiting for its shard's worker
+type queuedOrder struct {
+	ctx      context.Context
+	place    func(ctx context.Context) (*models.Saga, error)
+	enqueued time.Time
+	done     chan queuedResult
+}
+
+// queuedResult is the outcome of placing a queued order
+type queuedResult struct {
+	saga *models.Saga
+	err  error
+}
+
+// NewOrderQueue creates an order queue with the given number of shards,
+// each holding up to depth waiting orders
+func NewOrderQueue(shards, depth int) *OrderQueue {
+	q := &OrderQueue{
+		shards: make([]chan *queuedOrder, shards),
+	}
+	for i := range q.shards {
+		q.shards[i] = make(chan *queuedOrder, depth)
+	}
+	return q
+}
+
+// Start starts one worker per shard
+func (q *OrderQueue) Start() {
+	logger.WithComponent("order_queue").
+		WithField("shards", len(q.shards)).
+		WithField("depth", cap(q.shards[0])).
+		Info("Starting order queue")
+
+	for _, shard := range q.shards {
+		q.wg.Add(1)
+		go q.work(shard)
+	}
+}
+
+// Stop stops accepting orders and waits for the workers to place the ones
+// already queued
+func (q *OrderQueue) Stop() {
+	q.mu.Lock()
+	q.stopped = true
+	for _, shard := range q.shards {
+		close(shard)
+	}
+	q.mu.Unlock()
+
+	q.wg.Wait()
+
+	logger.WithComponent("order_queue").Info("Order queue stopped")
+}
+
+// Submit queues an order for productID and waits for place to run on the
+// product's shard. A full shard, or a stopped queue, rejects the order with
+// ORDER_QUEUE_FULL so the client retries, possibly on another replica.
+func (q *OrderQueue) Submit(ctx context.Context, productID int, place func(ctx context.Context) (*models.Saga, error)) (*models.Saga, error) {
+	order := &queuedOrder{
+		ctx:      ctx,
+		place:    place,
+		enqueued: time.Now(),
+		done:     make(chan queuedResult, 1),
+	}
+
+	q.mu.RLock()
+	if q.stopped {
+		q.mu.RUnlock()
+		return nil, errors.ErrOrderQueueFull
+	}
+	select {
+	case q.shardFor(productID) <- order:
+		metrics.OrderQueueDepth.Inc()
+		q.mu.RUnlock()
+	default:
+		q.mu.RUnlock()
+		metrics.OrdersQueueRejected.Inc()
+		return nil, errors.ErrOrderQueueFull
+	}
+
+	select {
+	case result := <-order.done:
+		return result.saga, result.err
+	case <-ctx.Done():
+		// The worker skips orders whose caller has gone away
+		return nil, ctx.Err()
+	}
+}
+
+// shardFor returns the queue a product's orders go through
+func (q *OrderQueue) shardFor(productID int) chan *queuedOrder {
+	return q.shards[uint(productID)%uint(len(q.shards))]
+}
+
+// work places a shard's orders one at a time until the shard is closed
+func (q *OrderQueue) work(shard chan *queuedOrder) {
+	defer q.wg.Done()
+
+	for order := range shard {
+		metrics.OrderQueueDepth.Dec()
+		metrics.OrderQueueWait.Observe(time.Since(order.enqueued).Seconds())
+
+		if err := order.ctx.Err(); err != nil {
+			order.done <- queuedResult{err: err}
+			continue
+		}
+
+		saga, err := order.place(order.ctx)
+		order.done <- queuedResult{saga: saga, err: err}
+	}
+}
@@ -0,0 +1,287 @@
+// Package service provides the order placement saga
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// orderPlacement is the ORDER_PLACEMENT saga payload. Steps fill in the
+// order fields as they complete, so the persisted payload always describes
+// what has to be undone.
+type orderPlacement struct {
+	OrderID    uuid.UUID          `json:"order_id"`
+	ProductID  int                `json:"product_id"`
+	BuyerID    string             `json:"buyer_id"`
+	Quantity   int                `json:"quantity"`
+	TotalCents int                `json:"total_cents"`
+	Status     models.OrderStatus `json:"status"`
+	CreatedAt  time.Time          `json:"created_at"`
+	UpdatedAt  time.Time          `json:"updated_at"`
+
+	ClientReference *string `json:"client_reference,omitempty"`
+	// Components is the stock taken from each component when the product
+	// is a bundle
+	Components []*models.OrderComponent `json:"components,omitempty"`
+	// SalesEvent is the live sales event the order was placed in
+	SalesEvent *placementSalesEvent `json:"sales_event,omitempty"`
+}
+
+// placementSalesEvent is the sales event an order counts against
+type placementSalesEvent struct {
+	ID            int       `json:"id"`
+	PerBuyerLimit int       `json:"per_buyer_limit"`
+	EndsAt        time.Time `json:"ends_at"`
+}
+
+// order returns the order described by the payload
+func (p *orderPlacement) order() *models.Order {
+	return &models.Order{
+		ID:         p.OrderID,
+		ProductID:  p.ProductID,
+		BuyerID:    p.BuyerID,
+		Quantity:   p.Quantity,
+		Status:     p.Status,
+		TotalCents: p.TotalCents,
+		CreatedAt:  p.CreatedAt,
+		UpdatedAt:  p.UpdatedAt,
+
+		ClientReference: p.ClientReference,
+		Components:      p.Components,
+		SalesEventID:    p.salesEventID(),
+	}
+}
+
+// salesEventID returns the ID of the order's sales event, or nil
+func (p *orderPlacement) salesEventID() *int {
+	if p.SalesEvent == nil {
+		return nil
+	}
+	return &p.SalesEvent.ID
+}
+
+// checkPurchaseLimit fails with ErrPurchaseLimitExceeded if the order would
+// take the buyer past its sales event's per-buyer limit. It runs under the
+// product's lock, so the buyer's concurrent orders are counted in turn.
+func (p *orderPlacement) checkPurchaseLimit(ctx context.Context, tx *repository.Tx) error {
+	if p.SalesEvent == nil {
+		return nil
+	}
+	held, err := tx.Orders.BuyerEventQuantity(ctx, p.SalesEvent.ID, p.BuyerID, p.ProductID)
+	if err != nil {
+		return err
+	}
+	if held+p.Quantity > p.SalesEvent.PerBuyerLimit {
+		return errors.ErrPurchaseLimitExceeded
+	}
+	return nil
+}
+
+// stockReservation is stock an order takes from one product, a component
+// of the ordered bundle or the ordered product itself
+type stockReservation struct {
+	product   *models.Product
+	quantity  int
+	component bool
+}
+
+// reservations locks the products an order takes stock from and checks
+// they have enough: the product itself, or each component of a bundle in
+// product ID order, so concurrent bundle orders lock them alike. A product
+// that takes backorders reports instead that the order waits for stock,
+// when it has too little or earlier backorders are still waiting for it.
+func (p *orderPlacement) reservations(ctx context.Context, tx *repository.Tx, product *models.Product) ([]stockReservation, bool, error) {
+	components, err := tx.Bundles.GetComponents(ctx, product.ID)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(components) == 0 {
+		if product.Stock < p.Quantity || product.Backordered > 0 {
+			if product.Backordered+p.Quantity > product.BackorderLimit {
+				return nil, false, errors.ErrOutOfStock
+			}
+			return nil, true, nil
+		}
+		return []stockReservation{{product: product, quantity: p.Quantity}}, false, nil
+	}
+
+	reservations := make([]stockReservation, 0, len(components))
+	for _, component := range components {
+		componentProduct, err := tx.Products.GetByIDForUpdate(ctx, component.ProductID)
+		if err != nil {
+			return nil, false, err
+		}
+		quantity := component.Quantity * p.Quantity
+		if componentProduct.Stock < quantity {
+			return nil, false, errors.ErrOutOfStock
+		}
+		reservations = append(reservations, stockReservation{product: componentProduct, quantity: quantity, component: true})
+	}
+	return reservations, false, nil
+}
+
+// reserved returns the stock the order holds, by product; a backorder
+// holds none yet
+func (p *orderPlacement) reserved() []*models.OrderComponent {
+	if p.Status == models.OrderStatusBackordered {
+		return nil
+	}
+	if len(p.Components) > 0 {
+		return p.Components
+	}
+	return []*models.OrderComponent{{ProductID: p.ProductID, Quantity: p.Quantity}}
+}
+
+// event returns an entry for the order's timeline
+func (p *orderPlacement) event(eventType models.OrderEventType, detail string) *models.OrderEvent {
+	return &models.OrderEvent{OrderID: p.OrderID, Type: eventType, Detail: detail}
+}
+
+// orderPlacementSaga defines how an order is placed: stock is reserved with
+// a pending order, from each component when the product is a bundle, then
+// the order is confirmed. An order a product takes beyond its stock is
+// left BACKORDERED instead, for the fulfillment job to confirm. Steps that
+// reach outside the database, such as capturing a payment (compensated by
+// voiding it) or booking a shipment, belong between the two so that
+// confirming stays the final step and a confirmed order never needs undoing.
+func orderPlacementSaga(pricing *Pricing) *SagaDefinition {
+	return &SagaDefinition{
+		Type:       models.SagaTypeOrderPlacement,
+		NewPayload: func() interface{} { return &orderPlacement{} },
+		Steps: []SagaStep{
+			{
+				Name: "reserve_stock",
+				Action: func(ctx context.Context, tx *repository.Tx, payload interface{}) error {
+					p := payload.(*orderPlacement)
+
+					// Get product with lock for update
+					product, err := tx.Products.GetByIDForUpdate(ctx, p.ProductID)
+					if err != nil {
+						return err
+					}
+					if err := p.checkPurchaseLimit(ctx, tx); err != nil {
+						return err
+					}
+
+					// Check stock availability; a bundle takes its
+					// components' stock
+					reservations, backorder, err := p.reservations(ctx, tx, product)
+					if err != nil {
+						return err
+					}
+
+					quote, err := pricing.Quote(ctx, product.ID, p.BuyerID, p.Quantity, product.Price)
+					if err != nil {
+						return err
+					}
+
+					order := &models.Order{
+						ID:         p.OrderID,
+						ProductID:  p.ProductID,
+						BuyerID:    p.BuyerID,
+						Quantity:   p.Quantity,
+						Status:     models.OrderStatusPending,
+						TotalCents: quote.TotalCents(),
+
+						ClientReference: p.ClientReference,
+						SalesEventID:    p.salesEventID(),
+					}
+					if backorder {
+						order.Status = models.OrderStatusBackordered
+					}
+					if err := tx.Orders.Create(ctx, order); err != nil {
+						return err
+					}
+					created := fmt.Sprintf("quantity=%d total_cents=%d", order.Quantity, order.TotalCents)
+					if err := tx.Orders.AddEvent(ctx, p.event(models.OrderEventCreated, created)); err != nil {
+						return err
+					}
+					if backorder {
+						if err := tx.Products.Backorder(ctx, product.ID, p.Quantity); err != nil {
+							return err
+						}
+						backordered := fmt.Sprintf("product_id=%d quantity=%d stock=%d", product.ID, p.Quantity, product.Stock)
+						if err := tx.Orders.AddEvent(ctx, p.event(models.OrderEventBackordered, backordered)); err != nil {
+							return err
+						}
+					}
+
+					// Update product stock with optimistic locking
+					var components []*models.OrderComponent
+					for _, r := range reservations {
+						if err := tx.Products.UpdateStock(ctx, r.product.ID, r.quantity, r.product.Version); err != nil {
+							return err
+						}
+						reserved := fmt.Sprintf("product_id=%d quantity=%d", r.product.ID, r.quantity)
+						if err := tx.Orders.AddEvent(ctx, p.event(models.OrderEventStockReserved, reserved)); err != nil {
+							return err
+						}
+						if r.component {
+							components = append(components, &models.OrderComponent{ProductID: r.product.ID, Quantity: r.quantity})
+						}
+					}
+					if len(components) > 0 {
+						if err := tx.Orders.AddComponents(ctx, p.OrderID, components); err != nil {
+							return err
+						}
+					}
+
+					p.Components = components
+					p.TotalCents = order.TotalCents
+					p.Status = order.Status
+					p.CreatedAt = order.CreatedAt
+					p.UpdatedAt = order.UpdatedAt
+					return nil
+				},
+				Compensate: func(ctx context.Context, tx *repository.Tx, payload interface{}) error {
+					p := payload.(*orderPlacement)
+
+					from := models.OrderStatusPending
+					if p.Status == models.OrderStatusBackordered {
+						from = models.OrderStatusBackordered
+						if err := tx.Products.ReleaseBackorder(ctx, p.ProductID, p.Quantity); err != nil {
+							return err
+						}
+					}
+					for _, r := range p.reserved() {
+						if err := tx.Products.ReleaseStock(ctx, r.ProductID, r.Quantity); err != nil {
+							return err
+						}
+						released := fmt.Sprintf("product_id=%d quantity=%d", r.ProductID, r.Quantity)
+						if err := tx.Orders.AddEvent(ctx, p.event(models.OrderEventStockReleased, released)); err != nil {
+							return err
+						}
+					}
+					p.Status = models.OrderStatusCancelled
+					if err := tx.Orders.UpdateStatus(ctx, p.OrderID, from, models.OrderStatusCancelled); err != nil {
+						return err
+					}
+					return tx.Orders.AddEvent(ctx, p.event(models.OrderEventCancelled, "placement rolled back"))
+				},
+			},
+			{
+				Name: "confirm_order",
+				Action: func(ctx context.Context, tx *repository.Tx, payload interface{}) error {
+					p := payload.(*orderPlacement)
+
+					// A backorder is confirmed once stock arrives
+					if p.Status == models.OrderStatusBackordered {
+						return nil
+					}
+					if err := tx.Orders.UpdateStatus(ctx, p.OrderID, models.OrderStatusPending, models.OrderStatusConfirmed); err != nil {
+						return err
+					}
+					p.Status = models.OrderStatusConfirmed
+					return tx.Orders.AddEvent(ctx, p.event(models.OrderEventConfirmed, ""))
+				},
+			},
+		},
+	}
+}
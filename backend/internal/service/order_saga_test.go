@@ -0,0 +1,335 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	apperrors "indico-backend/internal/errors"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeUnitOfWork runs work against in-memory repositories without a database
+type fakeUnitOfWork struct {
+	tx *repository.Tx
+}
+
+func (u *fakeUnitOfWork) Do(ctx context.Context, opts *sql.TxOptions, fn func(tx *repository.Tx) error) error {
+	return fn(u.tx)
+}
+
+type fakeProducts struct {
+	repository.ProductTx
+	products map[int]*models.Product
+}
+
+func (f *fakeProducts) GetByIDForUpdate(ctx context.Context, id int) (*models.Product, error) {
+	p := *f.products[id]
+	return &p, nil
+}
+
+func (f *fakeProducts) UpdateStock(ctx context.Context, id int, quantity int, version int) error {
+	f.products[id].Stock -= quantity
+	return nil
+}
+
+func (f *fakeProducts) ReleaseStock(ctx context.Context, id int, quantity int) error {
+	f.products[id].Stock += quantity
+	return nil
+}
+
+func (f *fakeProducts) Backorder(ctx context.Context, id int, quantity int) error {
+	f.products[id].Backordered += quantity
+	return nil
+}
+
+func (f *fakeProducts) ReleaseBackorder(ctx context.Context, id int, quantity int) error {
+	f.products[id].Backordered -= quantity
+	return nil
+}
+
+type fakeBundles struct {
+	repository.BundleTx
+	components map[int][]*models.BundleComponent
+}
+
+func (f *fakeBundles) GetComponents(ctx context.Context, bundleID int) ([]*models.BundleComponent, error) {
+	return f.components[bundleID], nil
+}
+
+type fakeOrders struct {
+	orders     map[uuid.UUID]*models.Order
+	placed     []uuid.UUID
+	events     []models.OrderEventType
+	components map[uuid.UUID][]*models.OrderComponent
+	confirmErr error
+}
+
+func (f *fakeOrders) Create(ctx context.Context, order *models.Order) error {
+	f.orders[order.ID] = order
+	f.placed = append(f.placed, order.ID)
+	return nil
+}
+
+func (f *fakeOrders) ListBackorderedForUpdate(ctx context.Context, productID int) ([]*models.Order, error) {
+	var orders []*models.Order
+	for _, id := range f.placed {
+		if order := f.orders[id]; order.ProductID == productID && order.Status == models.OrderStatusBackordered {
+			orders = append(orders, order)
+		}
+	}
+	return orders, nil
+}
+
+func (f *fakeOrders) GetByIDForUpdate(ctx context.Context, id uuid.UUID) (*models.Order, error) {
+	order, ok := f.orders[id]
+	if !ok {
+		return nil, apperrors.ErrOrderNotFound
+	}
+	o := *order
+	o.Components = f.components[id]
+	return &o, nil
+}
+
+func (f *fakeOrders) BuyerEventQuantity(ctx context.Context, eventID int, buyerID string, productID int) (int, error) {
+	var quantity int
+	for _, order := range f.orders {
+		if order.SalesEventID != nil && *order.SalesEventID == eventID && order.BuyerID == buyerID &&
+			order.ProductID == productID && order.Status != models.OrderStatusCancelled {
+			quantity += order.Quantity
+		}
+	}
+	return quantity, nil
+}
+
+func (f *fakeOrders) UpdateStatus(ctx context.Context, id uuid.UUID, from, to models.OrderStatus) error {
+	if to == models.OrderStatusConfirmed && f.confirmErr != nil {
+		return f.confirmErr
+	}
+	f.orders[id].Status = to
+	return nil
+}
+
+func (f *fakeOrders) AddEvent(ctx context.Context, event *models.OrderEvent) error {
+	f.events = append(f.events, event.Type)
+	return nil
+}
+
+func (f *fakeOrders) AddComponents(ctx context.Context, orderID uuid.UUID, components []*models.OrderComponent) error {
+	f.components[orderID] = components
+	return nil
+}
+
+type fakeSagas struct {
+	repository.SagaRepository
+}
+
+func (fakeSagas) Create(ctx context.Context, saga *models.Saga) error { return nil }
+
+func (fakeSagas) SetStatus(ctx context.Context, id uuid.UUID, status models.SagaStatus, failedStep, errMsg string) error {
+	return nil
+}
+
+type fakeSagaProgress struct{}
+
+func (fakeSagaProgress) SetProgress(ctx context.Context, id uuid.UUID, completedSteps int, status models.SagaStatus, payload []byte) error {
+	return nil
+}
+
+// newFakeOrderSaga places orders for product 1 with the given stock, for
+// bundle 10 made of two of product 2 and one of product 3, and for product
+// 4 with no stock and a backorder limit of 5
+func newFakeOrderSaga(stock int, confirmErr error) (*SagaOrchestrator, *fakeProducts, *fakeOrders) {
+	sagas, products, orders, _ := newFakeOrderSagaWithUnitOfWork(stock, confirmErr)
+	return sagas, products, orders
+}
+
+func newFakeOrderSagaWithUnitOfWork(stock int, confirmErr error) (*SagaOrchestrator, *fakeProducts, *fakeOrders, *fakeUnitOfWork) {
+	products := &fakeProducts{products: map[int]*models.Product{
+		1:  {ID: 1, Price: 500, Stock: stock, Version: 1},
+		2:  {ID: 2, Price: 300, Stock: stock, Version: 1},
+		3:  {ID: 3, Price: 200, Stock: stock, Version: 1},
+		4:  {ID: 4, Price: 100, Version: 1, BackorderLimit: 5},
+		10: {ID: 10, Price: 700, Version: 1},
+	}}
+	bundles := &fakeBundles{components: map[int][]*models.BundleComponent{
+		10: {{ProductID: 2, Quantity: 2}, {ProductID: 3, Quantity: 1}},
+	}}
+	orders := &fakeOrders{orders: map[uuid.UUID]*models.Order{}, components: map[uuid.UUID][]*models.OrderComponent{}, confirmErr: confirmErr}
+	uow := &fakeUnitOfWork{tx: &repository.Tx{Products: products, Bundles: bundles, Orders: orders, Sagas: fakeSagaProgress{}}}
+
+	sagas := NewSagaOrchestrator(uow, fakeSagas{})
+	sagas.Register(orderPlacementSaga(nil))
+	return sagas, products, orders, uow
+}
+
+func TestOrderPlacementSagaConfirmsOrder(t *testing.T) {
+	sagas, products, orders := newFakeOrderSaga(5, nil)
+
+	payload := &orderPlacement{OrderID: uuid.New(), ProductID: 1, BuyerID: "buyer_1", Quantity: 2}
+	saga, err := sagas.Run(context.Background(), models.SagaTypeOrderPlacement, payload)
+	require.NoError(t, err)
+
+	assert.Equal(t, models.SagaStatusCompleted, saga.Status)
+	assert.Equal(t, 3, products.products[1].Stock)
+	assert.Equal(t, models.OrderStatusConfirmed, orders.orders[payload.OrderID].Status)
+	assert.Equal(t, 1000, payload.TotalCents)
+	assert.Equal(t, []models.OrderEventType{
+		models.OrderEventCreated, models.OrderEventStockReserved, models.OrderEventConfirmed,
+	}, orders.events)
+}
+
+func TestOrderPlacementSagaReleasesStockWhenConfirmFails(t *testing.T) {
+	confirmErr := errors.New("confirm failed")
+	sagas, products, orders := newFakeOrderSaga(5, confirmErr)
+
+	payload := &orderPlacement{OrderID: uuid.New(), ProductID: 1, BuyerID: "buyer_1", Quantity: 2}
+	saga, err := sagas.Run(context.Background(), models.SagaTypeOrderPlacement, payload)
+	require.ErrorIs(t, err, confirmErr)
+
+	assert.Equal(t, models.SagaStatusCompensated, saga.Status)
+	assert.Equal(t, 5, products.products[1].Stock)
+	assert.Equal(t, models.OrderStatusCancelled, orders.orders[payload.OrderID].Status)
+	assert.Equal(t, []models.OrderEventType{
+		models.OrderEventCreated, models.OrderEventStockReserved, models.OrderEventStockReleased, models.OrderEventCancelled,
+	}, orders.events)
+}
+
+func TestOrderPlacementSagaTakesBundleComponentStock(t *testing.T) {
+	sagas, products, orders := newFakeOrderSaga(5, nil)
+
+	payload := &orderPlacement{OrderID: uuid.New(), ProductID: 10, BuyerID: "buyer_1", Quantity: 2}
+	saga, err := sagas.Run(context.Background(), models.SagaTypeOrderPlacement, payload)
+	require.NoError(t, err)
+
+	assert.Equal(t, models.SagaStatusCompleted, saga.Status)
+	assert.Equal(t, 1, products.products[2].Stock)
+	assert.Equal(t, 3, products.products[3].Stock)
+	assert.Equal(t, 1400, payload.TotalCents)
+	assert.Equal(t, []*models.OrderComponent{{ProductID: 2, Quantity: 4}, {ProductID: 3, Quantity: 2}}, orders.components[payload.OrderID])
+
+	// One component short refuses the whole bundle
+	payload = &orderPlacement{OrderID: uuid.New(), ProductID: 10, BuyerID: "buyer_1", Quantity: 1}
+	_, err = sagas.Run(context.Background(), models.SagaTypeOrderPlacement, payload)
+	require.ErrorIs(t, err, apperrors.ErrOutOfStock)
+	assert.Equal(t, 1, products.products[2].Stock)
+	assert.Equal(t, 3, products.products[3].Stock)
+}
+
+func TestOrderPlacementSagaReleasesBundleComponentStock(t *testing.T) {
+	sagas, products, orders := newFakeOrderSaga(5, errors.New("confirm failed"))
+
+	payload := &orderPlacement{OrderID: uuid.New(), ProductID: 10, BuyerID: "buyer_1", Quantity: 2}
+	saga, err := sagas.Run(context.Background(), models.SagaTypeOrderPlacement, payload)
+	require.Error(t, err)
+
+	assert.Equal(t, models.SagaStatusCompensated, saga.Status)
+	assert.Equal(t, 5, products.products[2].Stock)
+	assert.Equal(t, 5, products.products[3].Stock)
+	assert.Equal(t, models.OrderStatusCancelled, orders.orders[payload.OrderID].Status)
+}
+
+func TestOrderPlacementSagaBackordersBeyondStock(t *testing.T) {
+	sagas, products, orders := newFakeOrderSaga(5, nil)
+	products.products[4].Stock = 1
+
+	place := func(quantity int) (*orderPlacement, error) {
+		payload := &orderPlacement{OrderID: uuid.New(), ProductID: 4, BuyerID: "buyer_1", Quantity: quantity}
+		_, err := sagas.Run(context.Background(), models.SagaTypeOrderPlacement, payload)
+		return payload, err
+	}
+
+	first, err := place(2)
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderStatusBackordered, first.Status)
+	assert.Equal(t, models.OrderStatusBackordered, orders.orders[first.OrderID].Status)
+	assert.Equal(t, 1, products.products[4].Stock)
+	assert.Equal(t, 2, products.products[4].Backordered)
+	assert.Equal(t, []models.OrderEventType{models.OrderEventCreated, models.OrderEventBackordered}, orders.events)
+
+	// The stock left is owed to the waiting backorder, so this one waits too
+	second, err := place(1)
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderStatusBackordered, second.Status)
+	assert.Equal(t, 1, products.products[4].Stock)
+	assert.Equal(t, 3, products.products[4].Backordered)
+
+	// Beyond the limit
+	_, err = place(3)
+	require.ErrorIs(t, err, apperrors.ErrOutOfStock)
+	assert.Equal(t, 3, products.products[4].Backordered)
+
+	// Products without a limit still refuse orders beyond their stock
+	payload := &orderPlacement{OrderID: uuid.New(), ProductID: 1, BuyerID: "buyer_1", Quantity: 6}
+	_, err = sagas.Run(context.Background(), models.SagaTypeOrderPlacement, payload)
+	require.ErrorIs(t, err, apperrors.ErrOutOfStock)
+}
+
+func TestFulfillBackordersConfirmsOldestFirst(t *testing.T) {
+	sagas, products, orders, uow := newFakeOrderSagaWithUnitOfWork(5, nil)
+	jp := &JobProcessor{uow: uow}
+
+	var placed []*orderPlacement
+	for _, quantity := range []int{3, 1, 1} {
+		payload := &orderPlacement{OrderID: uuid.New(), ProductID: 4, BuyerID: "buyer_1", Quantity: quantity}
+		_, err := sagas.Run(context.Background(), models.SagaTypeOrderPlacement, payload)
+		require.NoError(t, err)
+		placed = append(placed, payload)
+	}
+
+	// Two units cover the second backorder but not the first, which keeps
+	// its place
+	products.products[4].Stock = 2
+	confirmed, err := jp.fulfillBackorders(context.Background(), 4)
+	require.NoError(t, err)
+	assert.Equal(t, 0, confirmed)
+	assert.Equal(t, 2, products.products[4].Stock)
+
+	products.products[4].Stock = 4
+	confirmed, err = jp.fulfillBackorders(context.Background(), 4)
+	require.NoError(t, err)
+	assert.Equal(t, 2, confirmed)
+	assert.Equal(t, 0, products.products[4].Stock)
+	assert.Equal(t, 1, products.products[4].Backordered)
+	assert.Equal(t, models.OrderStatusConfirmed, orders.orders[placed[0].OrderID].Status)
+	assert.Equal(t, models.OrderStatusConfirmed, orders.orders[placed[1].OrderID].Status)
+	assert.Equal(t, models.OrderStatusBackordered, orders.orders[placed[2].OrderID].Status)
+}
+
+func TestOrderPlacementSagaEnforcesPurchaseLimit(t *testing.T) {
+	sagas, products, orders := newFakeOrderSaga(10, nil)
+
+	place := func(buyerID string, quantity int) (*orderPlacement, error) {
+		payload := &orderPlacement{
+			OrderID:    uuid.New(),
+			ProductID:  1,
+			BuyerID:    buyerID,
+			Quantity:   quantity,
+			SalesEvent: &placementSalesEvent{ID: 7, PerBuyerLimit: 3},
+		}
+		_, err := sagas.Run(context.Background(), models.SagaTypeOrderPlacement, payload)
+		return payload, err
+	}
+
+	first, err := place("buyer_1", 2)
+	require.NoError(t, err)
+	require.NotNil(t, orders.orders[first.OrderID].SalesEventID)
+	assert.Equal(t, 7, *orders.orders[first.OrderID].SalesEventID)
+
+	_, err = place("buyer_1", 2)
+	require.ErrorIs(t, err, apperrors.ErrPurchaseLimitExceeded)
+	assert.Equal(t, 8, products.products[1].Stock)
+
+	// The limit is per buyer, and a cancelled order no longer counts
+	_, err = place("buyer_2", 3)
+	require.NoError(t, err)
+	orders.orders[first.OrderID].Status = models.OrderStatusCancelled
+	_, err = place("buyer_1", 3)
+	require.NoError(t, err)
+}
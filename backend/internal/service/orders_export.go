@@ -0,0 +1,317 @@
+// Package service provides ad-hoc order exports
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"indico-backend/internal/csvsafe"
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+	"indico-backend/internal/parquet"
+)
+
+// exportPageSize is the number of orders read per page while exporting
+const exportPageSize = 1000
+
+// ordersExportFilter converts export job parameters into a repository filter.
+// Dates are inclusive calendar days in UTC.
+func ordersExportFilter(params *models.OrdersExportJobParams) (*models.OrderExportFilter, error) {
+	filter := &models.OrderExportFilter{
+		Status:  params.Status,
+		BuyerID: params.BuyerID,
+	}
+
+	if params.From != "" {
+		from, err := time.Parse("2006-01-02", params.From)
+		if err != nil {
+			return nil, errors.NewValidationError("invalid from date format, expected YYYY-MM-DD")
+		}
+		filter.From = &from
+	}
+
+	if params.To != "" {
+		to, err := time.Parse("2006-01-02", params.To)
+		if err != nil {
+			return nil, errors.NewValidationError("invalid to date format, expected YYYY-MM-DD")
+		}
+		if filter.From != nil && to.Before(*filter.From) {
+			return nil, errors.NewValidationError("to date must be after from date")
+		}
+		// Add one day to make the range inclusive
+		end := to.AddDate(0, 0, 1)
+		filter.To = &end
+	}
+
+	return filter, nil
+}
+
+// orderExportWriter writes exported orders in one file format
+type orderExportWriter interface {
+	Write(order *models.Order) error
+	Close() error
+}
+
+// processOrdersExportJob processes an orders export job
+func (jp *JobProcessor) processOrdersExportJob(ctx context.Context, job *models.Job) error {
+	log := logger.WithJobID(job.ID.String())
+
+	// Parse job parameters
+	var params models.OrdersExportJobParams
+	if err := json.Unmarshal([]byte(job.Parameters), &params); err != nil {
+		return fmt.Errorf("failed to parse job parameters: %w", err)
+	}
+	if params.Format == "" {
+		params.Format = models.ExportFormatCSV
+	}
+
+	filter, err := ordersExportFilter(&params)
+	if err != nil {
+		return err
+	}
+
+	log.WithField("status", params.Status).
+		WithField("buyer_id", params.BuyerID).
+		WithField("from", params.From).
+		WithField("to", params.To).
+		WithField("format", params.Format).
+		Info("Processing orders export job")
+
+	totalCount, err := jp.orderRepo.CountForExport(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("failed to count orders: %w", err)
+	}
+
+	live := jp.liveState(job.ID)
+	live.setTotal(totalCount)
+
+	filePath, downloadURL, err := jp.resultLocation(job.ID, string(params.Format))
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer file.Close()
+
+	var writer orderExportWriter
+	switch params.Format {
+	case models.ExportFormatJSON:
+		writer = newJSONOrderWriter(file)
+	case models.ExportFormatParquet:
+		writer, err = newParquetOrderWriter(file)
+		if err != nil {
+			return err
+		}
+	default:
+		writer, err = newCSVOrderWriter(file)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Stream orders page by page so large extracts stay out of memory, each
+	// page starting after the last order of the previous one
+	var processed int
+	var after *models.OrderCursor
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("Job processing cancelled")
+			return ctx.Err()
+		default:
+		}
+
+		fetchStart := time.Now()
+		orders, err := jp.orderRepo.ListForExport(ctx, filter, after, exportPageSize)
+		if err != nil {
+			return fmt.Errorf("failed to list orders: %w", err)
+		}
+		live.trackDB(fetchStart)
+		if len(orders) == 0 {
+			break
+		}
+
+		for _, order := range orders {
+			if err := writer.Write(order); err != nil {
+				return fmt.Errorf("failed to write order: %w", err)
+			}
+		}
+
+		last := orders[len(orders)-1]
+		after = &models.OrderCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+
+		processed += len(orders)
+		live.recordBatch(len(orders))
+
+		progress := 100.0
+		if totalCount > 0 && processed < totalCount {
+			progress = float64(processed) / float64(totalCount) * 100
+		}
+		if err := jp.updateProgress(ctx, job, progress, processed); err != nil {
+			log.WithError(err).Error("Failed to update job progress")
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to finish export file: %w", err)
+	}
+
+	if err := jp.updateProgress(ctx, job, 100, processed); err != nil {
+		log.WithError(err).Error("Failed to update job progress")
+	}
+
+	if err := jp.jobRepo.UpdateResult(ctx, job.ID, filePath, downloadURL); err != nil {
+		return fmt.Errorf("failed to update job result: %w", err)
+	}
+
+	log.WithField("orders_count", processed).
+		WithField("file_path", filePath).
+		Info("Orders export job completed")
+
+	return nil
+}
+
+// csvOrderWriter writes orders as CSV rows
+type csvOrderWriter struct {
+	writer *csvsafe.Writer
+}
+
+func newCSVOrderWriter(file *os.File) (*csvOrderWriter, error) {
+	w := &csvOrderWriter{writer: csvsafe.NewWriter(file)}
+
+	// Write CSV header
+	header := []string{
+		"id",
+		"product_id",
+		"buyer_id",
+		"quantity",
+		"status",
+		"total_cents",
+		"created_at",
+		"updated_at",
+	}
+	if err := w.writer.Write(header); err != nil {
+		return nil, fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	return w, nil
+}
+
+func (w *csvOrderWriter) Write(order *models.Order) error {
+	return w.writer.Write([]string{
+		order.ID.String(),
+		strconv.Itoa(order.ProductID),
+		order.BuyerID,
+		strconv.Itoa(order.Quantity),
+		string(order.Status),
+		strconv.Itoa(order.TotalCents),
+		order.CreatedAt.UTC().Format(time.RFC3339),
+		order.UpdatedAt.UTC().Format(time.RFC3339),
+	})
+}
+
+func (w *csvOrderWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// jsonOrderWriter writes orders as a single JSON array
+type jsonOrderWriter struct {
+	writer *bufio.Writer
+	count  int
+}
+
+func newJSONOrderWriter(file *os.File) *jsonOrderWriter {
+	return &jsonOrderWriter{writer: bufio.NewWriter(file)}
+}
+
+func (w *jsonOrderWriter) Write(order *models.Order) error {
+	data, err := json.Marshal(order)
+	if err != nil {
+		return err
+	}
+
+	sep := ",\n"
+	if w.count == 0 {
+		sep = "[\n"
+	}
+	if _, err := w.writer.WriteString(sep); err != nil {
+		return err
+	}
+	if _, err := w.writer.Write(data); err != nil {
+		return err
+	}
+
+	w.count++
+	return nil
+}
+
+func (w *jsonOrderWriter) Close() error {
+	closing := "\n]\n"
+	if w.count == 0 {
+		closing = "[]\n"
+	}
+	if _, err := w.writer.WriteString(closing); err != nil {
+		return err
+	}
+	return w.writer.Flush()
+}
+
+// orderParquetColumns are the columns of a Parquet order export, the same as
+// its CSV counterpart
+var orderParquetColumns = []parquet.Column{
+	{Name: "id", Type: parquet.String},
+	{Name: "product_id", Type: parquet.Int32},
+	{Name: "buyer_id", Type: parquet.String},
+	{Name: "quantity", Type: parquet.Int32},
+	{Name: "status", Type: parquet.String},
+	{Name: "total_cents", Type: parquet.Int32},
+	{Name: "created_at", Type: parquet.Timestamp},
+	{Name: "updated_at", Type: parquet.Timestamp},
+}
+
+// parquetOrderWriter writes orders as Parquet rows
+type parquetOrderWriter struct {
+	writer *bufio.Writer
+	file   *parquet.Writer
+}
+
+func newParquetOrderWriter(file *os.File) (*parquetOrderWriter, error) {
+	w := &parquetOrderWriter{writer: bufio.NewWriter(file)}
+
+	var err error
+	w.file, err = parquet.NewWriter(w.writer, orderParquetColumns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start Parquet file: %w", err)
+	}
+	return w, nil
+}
+
+func (w *parquetOrderWriter) Write(order *models.Order) error {
+	return w.file.Write([]interface{}{
+		order.ID.String(),
+		order.ProductID,
+		order.BuyerID,
+		order.Quantity,
+		string(order.Status),
+		order.TotalCents,
+		order.CreatedAt,
+		order.UpdatedAt,
+	})
+}
+
+func (w *parquetOrderWriter) Close() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	return w.writer.Flush()
+}
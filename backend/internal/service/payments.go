@@ -0,0 +1,188 @@
+// Package service provides payment gateway notification handling
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"indico-backend/internal/calendar"
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/metrics"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+)
+
+// paymentEventStatus is the transaction status each gateway notification
+// moves a payment's transaction to
+var paymentEventStatus = map[string]models.TransactionStatus{
+	models.PaymentEventCaptured: models.TransactionStatusCompleted,
+	models.PaymentEventRefunded: models.TransactionStatusRefunded,
+}
+
+// ApplyPaymentEvent brings a payment's transaction in line with a gateway
+// notification. A capture of an unknown payment creates a COMPLETED
+// transaction; otherwise the transaction moves to the notified status if it
+// may, and is left alone if it is already there or past it. Each event ID is
+// applied once, in the same database transaction as its change, so
+// redeliveries are acknowledged without effect. As with manual status
+// changes, settlements the change affects are flagged stale.
+func (s *transactionService) ApplyPaymentEvent(ctx context.Context, event *models.PaymentEvent) (*models.PaymentEventResult, error) {
+	status, ok := paymentEventStatus[event.Type]
+	if !ok {
+		return nil, errors.NewValidationError(fmt.Sprintf("invalid type, expected %s or %s", models.PaymentEventCaptured, models.PaymentEventRefunded))
+	}
+
+	if event.OrderID != nil {
+		if _, err := s.orderRepo.GetByID(ctx, *event.OrderID); err != nil {
+			return nil, err
+		}
+	}
+
+	book, err := s.jobProcessor.calendarBook(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.PaymentEventResult{
+		EventID:          event.ID,
+		StaleSettlements: []*models.Settlement{},
+	}
+
+	err = s.uow.Do(ctx, nil, func(tx *repository.Tx) error {
+		recorded, err := tx.Transactions.RecordPaymentEvent(ctx, event)
+		if err != nil {
+			return err
+		}
+		if !recorded {
+			result.Duplicate = true
+			return nil
+		}
+
+		txn, err := tx.Transactions.GetByPaymentID(ctx, event.PaymentID)
+		if err == errors.ErrTransactionNotFound && event.Type == models.PaymentEventCaptured {
+			return s.createCapturedTransaction(ctx, tx, event, book, result)
+		}
+		if err != nil {
+			// A refund can arrive before its capture; the gateway retries it
+			return err
+		}
+
+		result.Transaction = txn
+		result.PreviousStatus = txn.Status
+		if txn.Status == status {
+			return nil
+		}
+		if !canTransition(txn.Status, status) {
+			logger.WithContext(ctx).
+				WithField("payment_id", event.PaymentID).
+				WithField("event_type", event.Type).
+				WithField("status", txn.Status).
+				Warn("Ignoring payment event that conflicts with the transaction status")
+			return nil
+		}
+
+		if err := tx.Transactions.UpdateStatus(ctx, txn.ID, txn.Status, status); err != nil {
+			return err
+		}
+		txn.Status = status
+		result.Applied = true
+		if err := recordOrderPayment(ctx, tx, txn, "payment event "+event.ID); err != nil {
+			return err
+		}
+
+		reason := fmt.Sprintf("transaction %d changed from %s to %s by %s", txn.ID, result.PreviousStatus, status, event.Type)
+		return s.markPaymentStale(ctx, tx, txn, book, reason, result)
+	})
+	if err != nil {
+		metrics.PaymentEvents.WithLabelValues(event.Type, "failed").Inc()
+		if appErr, ok := errors.IsAppError(err); !ok || appErr.StatusCode >= 500 {
+			logger.WithContext(ctx).WithError(err).WithField("payment_id", event.PaymentID).Error("Failed to apply payment event")
+		}
+		return nil, err
+	}
+
+	outcome := "ignored"
+	switch {
+	case result.Duplicate:
+		outcome = "duplicate"
+	case result.Applied:
+		outcome = "applied"
+	}
+	metrics.PaymentEvents.WithLabelValues(event.Type, outcome).Inc()
+
+	logger.WithContext(ctx).
+		WithField("event_id", event.ID).
+		WithField("event_type", event.Type).
+		WithField("payment_id", event.PaymentID).
+		WithField("outcome", outcome).
+		Info("Payment event received")
+
+	return result, nil
+}
+
+// createCapturedTransaction records a captured payment the gateway reported
+// before any transaction existed for it
+func (s *transactionService) createCapturedTransaction(ctx context.Context, tx *repository.Tx, event *models.PaymentEvent, book *calendar.Book, result *models.PaymentEventResult) error {
+	if event.MerchantID == "" || event.AmountCents <= 0 || event.FeeCents < 0 {
+		return errors.NewValidationError("merchant_id, a positive amount_cents and a non-negative fee_cents are required to capture an unknown payment")
+	}
+
+	paymentID := event.PaymentID
+	txn := &models.Transaction{
+		MerchantID:  event.MerchantID,
+		AmountCents: event.AmountCents,
+		FeeCents:    event.FeeCents,
+		Status:      models.TransactionStatusCompleted,
+		PaidAt:      event.OccurredAt.UTC(),
+		PaymentID:   &paymentID,
+		OrderID:     event.OrderID,
+	}
+	if err := tx.Transactions.CreateForPayment(ctx, txn); err != nil {
+		return err
+	}
+	result.Transaction = txn
+	result.Applied = true
+	if err := recordOrderPayment(ctx, tx, txn, "payment event "+event.ID); err != nil {
+		return err
+	}
+
+	// A capture for a day that was already settled makes that settlement
+	// short by this transaction
+	reason := fmt.Sprintf("transaction %d captured by %s", txn.ID, event.Type)
+	return s.markPaymentStale(ctx, tx, txn, book, reason, result)
+}
+
+// orderPaymentEvents names the order timeline step of each transaction status
+var orderPaymentEvents = map[models.TransactionStatus]models.OrderEventType{
+	models.TransactionStatusPending:   models.OrderEventPaymentPending,
+	models.TransactionStatusCompleted: models.OrderEventPaymentCaptured,
+	models.TransactionStatusFailed:    models.OrderEventPaymentFailed,
+	models.TransactionStatusRefunded:  models.OrderEventPaymentRefunded,
+}
+
+// recordOrderPayment adds the transaction's new status to the timeline of
+// the order it paid for, if it is linked to one
+func recordOrderPayment(ctx context.Context, tx *repository.Tx, txn *models.Transaction, source string) error {
+	eventType, ok := orderPaymentEvents[txn.Status]
+	if txn.OrderID == nil || !ok {
+		return nil
+	}
+	return tx.Orders.AddEvent(ctx, &models.OrderEvent{
+		OrderID: *txn.OrderID,
+		Type:    eventType,
+		Detail:  fmt.Sprintf("transaction_id=%d by %s", txn.ID, source),
+	})
+}
+
+// markPaymentStale flags the settlement of the day a transaction settles on
+func (s *transactionService) markPaymentStale(ctx context.Context, tx *repository.Tx, txn *models.Transaction, book *calendar.Book, reason string, result *models.PaymentEventResult) error {
+	settlement, err := tx.Settlements.MarkStale(ctx, txn.MerchantID, book.SettlementDate(txn.MerchantID, txn.PaidAt), reason)
+	if err != nil {
+		return err
+	}
+	if settlement != nil {
+		result.StaleSettlements = append(result.StaleSettlements, settlement)
+	}
+	return nil
+}
@@ -0,0 +1,447 @@
+// Package service provides merchant payouts and their bank files
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"indico-backend/internal/bankfile"
+	"indico-backend/internal/calendar"
+	"indico-backend/internal/config"
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+	"indico-backend/internal/validation"
+
+	"github.com/google/uuid"
+)
+
+// payoutFileExtensions are the extensions of each scheme's bank files
+var payoutFileExtensions = map[models.PayoutScheme]string{
+	models.PayoutSchemeNACHA: "ach",
+	models.PayoutSchemeSEPA:  "xml",
+}
+
+// PayoutFileURL returns the admin download URL of a payout bank file job's
+// file. Bank files are never served on the public download route.
+func PayoutFileURL(jobID uuid.UUID, scheme models.PayoutScheme) string {
+	return "/v1/payouts/files/" + resultKey(jobID, payoutFileExtensions[scheme])
+}
+
+// payoutService implements PayoutService
+type payoutService struct {
+	uow        repository.UnitOfWork
+	payoutRepo repository.PayoutRepository
+	settleRepo repository.SettlementReader
+}
+
+// NewPayoutService creates a new payout service
+func NewPayoutService(deps *Dependencies) PayoutService {
+	return &payoutService{
+		uow:        deps.unitOfWork(),
+		payoutRepo: deps.PayoutRepo,
+		settleRepo: deps.SettleRepo,
+	}
+}
+
+// SetBankAccount validates and saves the account a merchant is paid to:
+// a routing number with a valid checksum and an account number for NACHA,
+// or an IBAN with valid check digits for SEPA
+func (s *payoutService) SetBankAccount(ctx context.Context, merchantID string, req *models.SetMerchantBankAccountRequest) (*models.MerchantBankAccount, error) {
+	merchantID = strings.TrimSpace(merchantID)
+	if err := validation.ID("merchant id", merchantID); err != nil {
+		return nil, err
+	}
+
+	account := &models.MerchantBankAccount{
+		MerchantID:  merchantID,
+		Scheme:      models.PayoutScheme(strings.ToUpper(string(req.Scheme))),
+		AccountName: strings.TrimSpace(req.AccountName),
+	}
+	if account.AccountName == "" {
+		return nil, errors.NewValidationError("account_name must not be blank")
+	}
+
+	switch account.Scheme {
+	case models.PayoutSchemeNACHA:
+		account.RoutingNumber = strings.TrimSpace(req.RoutingNumber)
+		account.AccountNumber = strings.ToUpper(strings.TrimSpace(req.AccountNumber))
+		if err := bankfile.ValidateRoutingNumber(account.RoutingNumber); err != nil {
+			return nil, errors.NewValidationError("invalid routing_number: " + err.Error())
+		}
+		if err := bankfile.ValidateAccountNumber(account.AccountNumber); err != nil {
+			return nil, errors.NewValidationError("invalid account_number: " + err.Error())
+		}
+	case models.PayoutSchemeSEPA:
+		account.IBAN = bankfile.NormalizeIBAN(req.IBAN)
+		account.BIC = strings.ToUpper(strings.TrimSpace(req.BIC))
+		if err := bankfile.ValidateIBAN(account.IBAN); err != nil {
+			return nil, errors.NewValidationError("invalid iban: " + err.Error())
+		}
+		if account.BIC != "" {
+			if err := bankfile.ValidateBIC(account.BIC); err != nil {
+				return nil, errors.NewValidationError("invalid bic: " + err.Error())
+			}
+		}
+	default:
+		return nil, errors.NewValidationError("invalid scheme, expected NACHA or SEPA")
+	}
+
+	if err := s.payoutRepo.SetBankAccount(ctx, account); err != nil {
+		logger.WithContext(ctx).WithError(err).WithField("merchant_id", merchantID).Error("Failed to set merchant bank account")
+		return nil, err
+	}
+
+	logger.WithContext(ctx).
+		WithField("merchant_id", merchantID).
+		WithField("scheme", account.Scheme).
+		Info("Merchant bank account set")
+
+	return account, nil
+}
+
+func (s *payoutService) GetBankAccount(ctx context.Context, merchantID string) (*models.MerchantBankAccount, error) {
+	account, err := s.payoutRepo.GetBankAccount(ctx, merchantID)
+	if err != nil {
+		if err != errors.ErrBankAccountNotFound {
+			logger.WithContext(ctx).WithError(err).Error("Failed to get merchant bank account")
+		}
+		return nil, err
+	}
+
+	return account, nil
+}
+
+// CreatePayout creates a pending payout of a merchant's current settlements
+// and adjustments dated from req.From to req.To. The period must have
+// ended, must not overlap another of the merchant's payouts, and must not
+// hold settlements flagged for re-settlement, whose amounts may change.
+func (s *payoutService) CreatePayout(ctx context.Context, req *models.CreatePayoutRequest) (*models.Payout, error) {
+	merchantID := strings.TrimSpace(req.MerchantID)
+	if merchantID == "" {
+		return nil, errors.NewValidationError("merchant_id is required")
+	}
+
+	from, err := time.Parse("2006-01-02", req.From)
+	if err != nil {
+		return nil, errors.NewValidationError("invalid from date format, expected YYYY-MM-DD")
+	}
+	to, err := time.Parse("2006-01-02", req.To)
+	if err != nil {
+		return nil, errors.NewValidationError("invalid to date format, expected YYYY-MM-DD")
+	}
+	if to.Before(from) {
+		return nil, errors.NewValidationError("to date must not be before from date")
+	}
+	if !to.Before(time.Now().UTC().Truncate(24 * time.Hour)) {
+		return nil, errors.NewValidationError("to date must be before today")
+	}
+
+	end := to.AddDate(0, 0, 1)
+	settlements, err := s.settleRepo.ListCurrentByMerchant(ctx, merchantID, from, end)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to list settlements for payout")
+		return nil, err
+	}
+	adjustments, err := s.settleRepo.ListAdjustments(ctx, &models.SettlementFilter{MerchantID: merchantID, From: &from, To: &end})
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to list adjustments for payout")
+		return nil, err
+	}
+
+	amount := 0
+	for _, settlement := range settlements {
+		if settlement.StaleSince != nil {
+			return nil, errors.NewValidationError("the period has settlements flagged for re-settlement; re-settle them first")
+		}
+		amount += settlement.NetCents
+	}
+	for _, adjustment := range adjustments {
+		amount += adjustment.AmountCents
+	}
+	if amount <= 0 {
+		return nil, errors.NewValidationError(fmt.Sprintf("nothing to pay out: the period nets %d cents", amount))
+	}
+
+	payout := &models.Payout{
+		ID:          uuid.New(),
+		MerchantID:  merchantID,
+		PeriodFrom:  from,
+		PeriodTo:    to,
+		AmountCents: amount,
+		Status:      models.PayoutStatusPending,
+	}
+	err = s.uow.Do(ctx, nil, func(tx *repository.Tx) error {
+		if _, err := tx.Payouts.GetBankAccountForUpdate(ctx, merchantID); err != nil {
+			return err
+		}
+		return tx.Payouts.Create(ctx, payout)
+	})
+	if err != nil {
+		if _, ok := errors.IsAppError(err); !ok {
+			logger.WithContext(ctx).WithError(err).Error("Failed to create payout")
+		}
+		return nil, err
+	}
+
+	logger.WithContext(ctx).
+		WithField("payout_id", payout.ID).
+		WithField("merchant_id", merchantID).
+		WithField("from", req.From).
+		WithField("to", req.To).
+		WithField("amount_cents", amount).
+		Info("Payout created")
+
+	return payout, nil
+}
+
+func (s *payoutService) GetPayout(ctx context.Context, id uuid.UUID) (*models.Payout, error) {
+	payout, err := s.payoutRepo.GetByID(ctx, id)
+	if err != nil {
+		if err != errors.ErrPayoutNotFound {
+			logger.WithContext(ctx).WithError(err).Error("Failed to get payout")
+		}
+		return nil, err
+	}
+
+	return payout, nil
+}
+
+func (s *payoutService) ListPayouts(ctx context.Context, status models.PayoutStatus, limit, offset int) ([]*models.Payout, error) {
+	switch status {
+	case "", models.PayoutStatusPending, models.PayoutStatusApproved, models.PayoutStatusExported:
+	default:
+		return nil, errors.NewValidationError("invalid status, expected PENDING, APPROVED or EXPORTED")
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 10 // Default limit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	payouts, err := s.payoutRepo.List(ctx, status, limit, offset)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to list payouts")
+		return nil, err
+	}
+
+	return payouts, nil
+}
+
+// ApprovePayout approves a pending payout for the next bank file of its
+// merchant's scheme
+func (s *payoutService) ApprovePayout(ctx context.Context, id uuid.UUID) (*models.Payout, error) {
+	payout, err := s.payoutRepo.Approve(ctx, id)
+	if err != nil {
+		if _, ok := errors.IsAppError(err); !ok {
+			logger.WithContext(ctx).WithError(err).Error("Failed to approve payout")
+		}
+		return nil, err
+	}
+
+	logger.WithContext(ctx).
+		WithField("payout_id", id).
+		WithField("merchant_id", payout.MerchantID).
+		Info("Payout approved")
+
+	return payout, nil
+}
+
+// payoutSchemeEnabled reports whether bank files of a scheme can be written
+func payoutSchemeEnabled(cfg *config.PayoutConfig, scheme models.PayoutScheme) bool {
+	if cfg == nil {
+		return false
+	}
+	switch scheme {
+	case models.PayoutSchemeNACHA:
+		return cfg.NACHA.Enabled()
+	case models.PayoutSchemeSEPA:
+		return cfg.SEPA.Enabled()
+	}
+	return false
+}
+
+// CreatePayoutFileJob queues a job that writes the approved payouts to
+// accounts of one scheme to a bank file
+func (s *jobService) CreatePayoutFileJob(ctx context.Context, req *models.CreatePayoutFileJobRequest) (*models.Job, error) {
+	scheme := models.PayoutScheme(strings.ToUpper(string(req.Scheme)))
+	if _, ok := payoutFileExtensions[scheme]; !ok {
+		return nil, errors.NewValidationError("invalid scheme, expected NACHA or SEPA")
+	}
+	if !payoutSchemeEnabled(s.payoutCfg, scheme) {
+		return nil, errors.ErrPayoutSchemeDisabled
+	}
+
+	approved, err := s.payoutRepo.CountApproved(ctx, scheme)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to count approved payouts")
+		return nil, err
+	}
+	if approved == 0 {
+		return nil, errors.ErrNoApprovedPayouts
+	}
+
+	params := models.PayoutFileJobParams{Scheme: scheme}
+
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job parameters: %w", err)
+	}
+
+	job := &models.Job{
+		ID:         uuid.New(),
+		Type:       models.JobTypePayoutFile,
+		Status:     models.JobStatusQueued,
+		Progress:   0,
+		Processed:  0,
+		Total:      0, // Will be calculated when job starts
+		Parameters: string(paramsJSON),
+	}
+
+	if err := s.setProgressWebhook(ctx, job, req.ProgressWebhook); err != nil {
+		return nil, err
+	}
+
+	if err := s.enqueue(ctx, job); err != nil {
+		return nil, err
+	}
+
+	logger.WithContext(ctx).
+		WithField("job_id", job.ID).
+		WithField("scheme", scheme).
+		WithField("approved_payouts", approved).
+		Info("Payout file job created and queued")
+
+	return job, nil
+}
+
+// processPayoutFileJob claims the approved payouts of a scheme and writes
+// them to a bank file. The claim commits only once the file is written, so
+// a failed attempt leaves the payouts approved; a retry of a job whose claim
+// committed writes the same payouts again.
+func (jp *JobProcessor) processPayoutFileJob(ctx context.Context, job *models.Job) error {
+	log := logger.WithJobID(job.ID.String())
+
+	var params models.PayoutFileJobParams
+	if err := json.Unmarshal([]byte(job.Parameters), &params); err != nil {
+		return fmt.Errorf("failed to parse job parameters: %w", err)
+	}
+	if !payoutSchemeEnabled(jp.payoutCfg, params.Scheme) {
+		return errors.ErrPayoutSchemeDisabled
+	}
+
+	log.WithField("scheme", params.Scheme).Info("Processing payout file job")
+
+	filePath, _, err := jp.resultLocation(job.ID, payoutFileExtensions[params.Scheme])
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	var exports []*models.PayoutExport
+	err = jp.uow.Do(ctx, nil, func(tx *repository.Tx) error {
+		var err error
+		exports, err = tx.Payouts.ClaimForExport(ctx, job.ID, params.Scheme)
+		if err != nil {
+			return err
+		}
+		if len(exports) == 0 {
+			return errors.ErrNoApprovedPayouts
+		}
+		jp.liveState(job.ID).setTotal(len(exports))
+
+		file, err := os.Create(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to create payout file: %w", err)
+		}
+		defer file.Close()
+
+		if err := writePayoutFile(file, jp.payoutCfg, params.Scheme, job.ID, exports, now); err != nil {
+			return fmt.Errorf("failed to write payout file: %w", err)
+		}
+		return file.Close()
+	})
+	if err != nil {
+		return err
+	}
+
+	jp.liveState(job.ID).recordBatch(len(exports))
+	if err := jp.updateProgress(ctx, job, 100, len(exports)); err != nil {
+		log.WithError(err).Error("Failed to update job progress")
+	}
+
+	if err := jp.jobRepo.UpdateResult(ctx, job.ID, filePath, PayoutFileURL(job.ID, params.Scheme)); err != nil {
+		return fmt.Errorf("failed to update job result: %w", err)
+	}
+
+	log.WithField("payouts", len(exports)).
+		WithField("file_path", filePath).
+		Info("Payout file job completed")
+
+	return nil
+}
+
+// writePayoutFile writes the claimed payouts as a bank file of the scheme,
+// due on the next business day after now
+func writePayoutFile(w io.Writer, cfg *config.PayoutConfig, scheme models.PayoutScheme, jobID uuid.UUID, exports []*models.PayoutExport, now time.Time) error {
+	due := calendar.New("", nil).SettlementDate(now.Truncate(24*time.Hour).AddDate(0, 0, 1))
+
+	switch scheme {
+	case models.PayoutSchemeNACHA:
+		file := &bankfile.NACHAFile{
+			Originator: bankfile.NACHAOriginator{
+				DestinationRouting: cfg.NACHA.DestinationRouting,
+				DestinationName:    cfg.NACHA.DestinationName,
+				OriginRouting:      cfg.NACHA.OriginRouting,
+				OriginName:         cfg.NACHA.OriginName,
+				CompanyName:        cfg.NACHA.CompanyName,
+				CompanyID:          cfg.NACHA.CompanyID,
+			},
+			CreatedAt:     now,
+			EffectiveDate: due,
+		}
+		for _, export := range exports {
+			file.Credits = append(file.Credits, bankfile.NACHACredit{
+				RoutingNumber: export.Account.RoutingNumber,
+				AccountNumber: export.Account.AccountNumber,
+				AmountCents:   export.Payout.AmountCents,
+				ID:            export.Payout.MerchantID,
+				Name:          export.Account.AccountName,
+			})
+		}
+		return bankfile.WriteNACHA(w, file)
+
+	case models.PayoutSchemeSEPA:
+		file := &bankfile.SEPAFile{
+			MessageID:     strings.ReplaceAll(jobID.String(), "-", ""),
+			CreatedAt:     now,
+			ExecutionDate: due,
+			Debtor: bankfile.SEPADebtor{
+				Name: cfg.SEPA.DebtorName,
+				IBAN: cfg.SEPA.DebtorIBAN,
+				BIC:  cfg.SEPA.DebtorBIC,
+			},
+		}
+		for _, export := range exports {
+			file.Credits = append(file.Credits, bankfile.SEPACredit{
+				EndToEndID:  strings.ReplaceAll(export.Payout.ID.String(), "-", ""),
+				AmountCents: export.Payout.AmountCents,
+				Name:        export.Account.AccountName,
+				IBAN:        export.Account.IBAN,
+				BIC:         export.Account.BIC,
+				Remittance: fmt.Sprintf("Payout %s to %s",
+					export.Payout.PeriodFrom.Format("2006-01-02"), export.Payout.PeriodTo.Format("2006-01-02")),
+			})
+		}
+		return bankfile.WriteSEPA(w, file)
+	}
+
+	return fmt.Errorf("unknown payout scheme: %s", scheme)
+}
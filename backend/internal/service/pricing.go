@@ -0,0 +1,195 @@
+// Package service provides order pricing hooks
+package service
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+
+	"indico-backend/internal/config"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/metrics"
+)
+
+// PriceQuote is an order being priced. Hooks adjust it before its total is
+// computed.
+type PriceQuote struct {
+	ProductID      int
+	BuyerID        string
+	Quantity       int
+	UnitPriceCents int
+	// Adjustments are added to the list price in order; a discount is
+	// negative
+	Adjustments []PriceAdjustment
+}
+
+// PriceAdjustment is one hook's change to an order's price
+type PriceAdjustment struct {
+	Hook        string
+	AmountCents int
+}
+
+// Adjust records a change to the price on behalf of a hook
+func (q *PriceQuote) Adjust(hook string, amountCents int) {
+	q.Adjustments = append(q.Adjustments, PriceAdjustment{Hook: hook, AmountCents: amountCents})
+}
+
+// ListCents is the order's price before adjustments
+func (q *PriceQuote) ListCents() int {
+	return q.UnitPriceCents * q.Quantity
+}
+
+// TotalCents is the order's price after adjustments, never below zero
+func (q *PriceQuote) TotalCents() int {
+	total := q.ListCents()
+	for _, adjustment := range q.Adjustments {
+		total += adjustment.AmountCents
+	}
+	if total < 0 {
+		return 0
+	}
+	return total
+}
+
+// PricingHook adjusts an order's price. Hooks run while the product row is
+// locked for the order, and again if the order is retried, so they must be
+// fast, deterministic for the same order, and must not call other services.
+type PricingHook interface {
+	Apply(ctx context.Context, quote *PriceQuote) error
+}
+
+// PricingHookFunc adapts a function to PricingHook
+type PricingHookFunc func(ctx context.Context, quote *PriceQuote) error
+
+// Apply calls f
+func (f PricingHookFunc) Apply(ctx context.Context, quote *PriceQuote) error {
+	return f(ctx, quote)
+}
+
+// PricingHookFactory creates a hook from the pricing configuration
+type PricingHookFactory func(cfg *config.PricingConfig) PricingHook
+
+var (
+	pricingHooksMu sync.Mutex
+	pricingHooks   = map[string]PricingHookFactory{
+		"bulk_discount": newBulkDiscount,
+	}
+)
+
+// RegisterPricingHook makes a hook available under name, to be switched on
+// by listing the name in PRICING_HOOKS. Call it before NewPricing.
+func RegisterPricingHook(name string, factory PricingHookFactory) {
+	pricingHooksMu.Lock()
+	defer pricingHooksMu.Unlock()
+	pricingHooks[name] = factory
+}
+
+// pricingFlag is an enabled hook and the share of buyers it applies to
+type pricingFlag struct {
+	name    string
+	percent int
+	hook    PricingHook
+}
+
+// Pricing computes order totals, applying the hooks switched on by feature
+// flags. A hook enabled for a percentage of buyers always treats the same
+// buyer the same way, so the rest form the control group of an experiment.
+type Pricing struct {
+	flags []pricingFlag
+}
+
+// NewPricing enables the hooks named in the configuration's flags
+func NewPricing(cfg *config.PricingConfig) (*Pricing, error) {
+	flags, err := cfg.Flags()
+	if err != nil {
+		return nil, err
+	}
+
+	pricingHooksMu.Lock()
+	defer pricingHooksMu.Unlock()
+
+	p := &Pricing{}
+	for _, flag := range flags {
+		factory, ok := pricingHooks[flag.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown pricing hook %q, expected one of %v", flag.Name, registeredPricingHooks())
+		}
+		p.flags = append(p.flags, pricingFlag{name: flag.Name, percent: flag.Percent, hook: factory(cfg)})
+	}
+	return p, nil
+}
+
+// registeredPricingHooks lists the registered hook names
+func registeredPricingHooks() []string {
+	names := make([]string, 0, len(pricingHooks))
+	for name := range pricingHooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Quote prices an order at the product's unit price and runs the hooks
+// enabled for its buyer, in configuration order. A nil Pricing prices at
+// the list price.
+func (p *Pricing) Quote(ctx context.Context, productID int, buyerID string, quantity, unitPriceCents int) (*PriceQuote, error) {
+	quote := &PriceQuote{
+		ProductID:      productID,
+		BuyerID:        buyerID,
+		Quantity:       quantity,
+		UnitPriceCents: unitPriceCents,
+	}
+	if p == nil {
+		return quote, nil
+	}
+
+	for _, flag := range p.flags {
+		if !flag.enabledFor(buyerID) {
+			metrics.PricingHookVariants.WithLabelValues(flag.name, "control").Inc()
+			continue
+		}
+		metrics.PricingHookVariants.WithLabelValues(flag.name, "treatment").Inc()
+
+		if err := flag.hook.Apply(ctx, quote); err != nil {
+			return nil, fmt.Errorf("pricing hook %s failed: %w", flag.name, err)
+		}
+	}
+
+	if len(quote.Adjustments) > 0 {
+		logger.WithContext(ctx).
+			WithField("product_id", productID).
+			WithField("list_cents", quote.ListCents()).
+			WithField("total_cents", quote.TotalCents()).
+			WithField("adjustments", quote.Adjustments).
+			Debug("Order price adjusted")
+	}
+
+	return quote, nil
+}
+
+// enabledFor reports whether the flag applies to a buyer, bucketing buyers
+// by a hash of the flag and buyer so each experiment splits them afresh
+func (f *pricingFlag) enabledFor(buyerID string) bool {
+	if f.percent >= 100 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(f.name))
+	h.Write([]byte{0})
+	h.Write([]byte(buyerID))
+	return int(h.Sum32()%100) < f.percent
+}
+
+// newBulkDiscount creates the bulk_discount hook, which takes
+// BulkDiscountPercent off orders of at least BulkDiscountMinQuantity units
+func newBulkDiscount(cfg *config.PricingConfig) PricingHook {
+	minQuantity, percent := cfg.BulkDiscountMinQuantity, cfg.BulkDiscountPercent
+	return PricingHookFunc(func(ctx context.Context, quote *PriceQuote) error {
+		if quote.Quantity >= minQuantity {
+			quote.Adjust("bulk_discount", -quote.ListCents()*percent/100)
+		}
+		return nil
+	})
+}
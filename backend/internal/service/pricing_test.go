@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"indico-backend/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPricingQuote(t *testing.T) {
+	ctx := context.Background()
+	RegisterPricingHook("test_surcharge", func(cfg *config.PricingConfig) PricingHook {
+		return PricingHookFunc(func(ctx context.Context, quote *PriceQuote) error {
+			quote.Adjust("test_surcharge", 100)
+			return nil
+		})
+	})
+
+	// Without pricing, orders cost the list price
+	var none *Pricing
+	quote, err := none.Quote(ctx, 1, "buyer", 3, 1000)
+	require.NoError(t, err)
+	assert.Equal(t, 3000, quote.TotalCents())
+
+	cfg := &config.PricingConfig{
+		Hooks:                   []string{"bulk_discount", "test_surcharge"},
+		BulkDiscountMinQuantity: 10,
+		BulkDiscountPercent:     5,
+	}
+	pricing, err := NewPricing(cfg)
+	require.NoError(t, err)
+
+	// Hooks run in configuration order on top of the list price
+	quote, err = pricing.Quote(ctx, 1, "buyer", 10, 1000)
+	require.NoError(t, err)
+	assert.Equal(t, 10000, quote.ListCents())
+	assert.Equal(t, []PriceAdjustment{{Hook: "bulk_discount", AmountCents: -500}, {Hook: "test_surcharge", AmountCents: 100}}, quote.Adjustments)
+	assert.Equal(t, 9600, quote.TotalCents())
+
+	quote, err = pricing.Quote(ctx, 1, "buyer", 9, 1000)
+	require.NoError(t, err)
+	assert.Equal(t, 9100, quote.TotalCents())
+
+	_, err = NewPricing(&config.PricingConfig{Hooks: []string{"no_such_hook"}})
+	assert.Error(t, err)
+}
+
+func TestPricingExperimentSplitsBuyers(t *testing.T) {
+	ctx := context.Background()
+	pricing, err := NewPricing(&config.PricingConfig{
+		Hooks:                   []string{"bulk_discount:30"},
+		BulkDiscountMinQuantity: 1,
+		BulkDiscountPercent:     10,
+	})
+	require.NoError(t, err)
+
+	treated := 0
+	for i := 0; i < 1000; i++ {
+		buyer := fmt.Sprintf("buyer_%d", i)
+		first, err := pricing.Quote(ctx, 1, buyer, 1, 1000)
+		require.NoError(t, err)
+		again, err := pricing.Quote(ctx, 1, buyer, 1, 1000)
+		require.NoError(t, err)
+
+		// A buyer stays in their group
+		assert.Equal(t, first.TotalCents(), again.TotalCents())
+		if first.TotalCents() == 900 {
+			treated++
+		}
+	}
+	assert.InDelta(t, 300, treated, 60)
+}
@@ -0,0 +1,286 @@
+// Package service provides saga orchestration for multi-step workflows
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/metrics"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// sagaRecoveryAge is how long a saga must have been idle before recovery
+// treats it as interrupted rather than still in progress
+const sagaRecoveryAge = time.Minute
+
+// SagaStep is one step of a saga. Action and Compensate run in a unit of
+// work that also records the saga's progress, so a step and its bookkeeping
+// commit together; a step that calls an external system (such
+// as a payment provider) must make that call idempotent. Compensate may be
+// nil for steps with nothing to undo.
+type SagaStep struct {
+	Name       string
+	Action     func(ctx context.Context, tx *repository.Tx, payload interface{}) error
+	Compensate func(ctx context.Context, tx *repository.Tx, payload interface{}) error
+}
+
+// SagaDefinition describes a saga type: its steps in order and how to
+// decode its persisted payload for recovery
+type SagaDefinition struct {
+	Type       models.SagaType
+	NewPayload func() interface{}
+	Steps      []SagaStep
+}
+
+// SagaOrchestrator runs sagas step by step, persisting progress after each
+// step and running the compensating actions of completed steps in reverse
+// order when a later step fails
+type SagaOrchestrator struct {
+	uow      repository.UnitOfWork
+	sagaRepo repository.SagaRepository
+
+	mu          sync.RWMutex
+	definitions map[models.SagaType]*SagaDefinition
+}
+
+// NewSagaOrchestrator creates a new saga orchestrator. Steps run in units
+// of work from uow, which must bind the saga repository and whatever
+// repositories the registered steps use.
+func NewSagaOrchestrator(uow repository.UnitOfWork, sagaRepo repository.SagaRepository) *SagaOrchestrator {
+	return &SagaOrchestrator{
+		uow:         uow,
+		sagaRepo:    sagaRepo,
+		definitions: make(map[models.SagaType]*SagaDefinition),
+	}
+}
+
+// Register makes a saga definition available to Run and Recover
+func (o *SagaOrchestrator) Register(def *SagaDefinition) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.definitions[def.Type] = def
+}
+
+func (o *SagaOrchestrator) definition(sagaType models.SagaType) (*SagaDefinition, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	def, ok := o.definitions[sagaType]
+	return def, ok
+}
+
+// Run executes a saga of the given type with a payload that its steps read
+// and update. If a step fails, the steps before it are compensated and the
+// step's error is returned unchanged.
+func (o *SagaOrchestrator) Run(ctx context.Context, sagaType models.SagaType, payload interface{}) (*models.Saga, error) {
+	def, ok := o.definition(sagaType)
+	if !ok {
+		return nil, fmt.Errorf("unknown saga type: %s", sagaType)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal saga payload: %w", err)
+	}
+
+	saga := &models.Saga{
+		ID:      uuid.New(),
+		Type:    sagaType,
+		Status:  models.SagaStatusRunning,
+		Payload: data,
+	}
+	if err := o.sagaRepo.Create(ctx, saga); err != nil {
+		return nil, err
+	}
+
+	log := logger.WithContext(ctx).WithField("saga_id", saga.ID).WithField("saga_type", sagaType)
+
+	for i, step := range def.Steps {
+		status := models.SagaStatusRunning
+		if i == len(def.Steps)-1 {
+			status = models.SagaStatusCompleted
+		}
+
+		err := o.uow.Do(ctx, nil, func(tx *repository.Tx) error {
+			if err := step.Action(ctx, tx, payload); err != nil {
+				return err
+			}
+
+			data, err := json.Marshal(payload)
+			if err != nil {
+				return fmt.Errorf("failed to marshal saga payload: %w", err)
+			}
+			saga.Payload = data
+			return tx.Sagas.SetProgress(ctx, saga.ID, i+1, status, data)
+		})
+		if err != nil {
+			log.WithError(err).WithField("step", step.Name).Warn("Saga step failed, compensating")
+			saga.FailedStep = &step.Name
+
+			// Compensate even if the caller has gone away
+			o.compensate(context.WithoutCancel(ctx), def, saga, payload, i, step.Name, err)
+			return saga, err
+		}
+		saga.CompletedSteps = i + 1
+	}
+
+	saga.Status = models.SagaStatusCompleted
+	metrics.SagasFinished.WithLabelValues(string(sagaType), string(saga.Status)).Inc()
+
+	return saga, nil
+}
+
+// compensate undoes the first completed steps of a saga in reverse order.
+// A compensation failure stops the rollback and leaves the saga FAILED with
+// the remaining steps recorded, so recovery can resume it.
+func (o *SagaOrchestrator) compensate(ctx context.Context, def *SagaDefinition, saga *models.Saga, payload interface{}, completed int, failedStep string, cause error) {
+	log := logger.WithContext(ctx).WithField("saga_id", saga.ID).WithField("saga_type", saga.Type)
+
+	errMsg := ""
+	if cause != nil {
+		errMsg = cause.Error()
+	}
+	if err := o.sagaRepo.SetStatus(ctx, saga.ID, models.SagaStatusCompensating, failedStep, errMsg); err != nil {
+		log.WithError(err).Error("Failed to mark saga as compensating")
+	}
+
+	for i := completed - 1; i >= 0; i-- {
+		step := def.Steps[i]
+
+		err := o.uow.Do(ctx, nil, func(tx *repository.Tx) error {
+			if step.Compensate != nil {
+				if err := step.Compensate(ctx, tx, payload); err != nil {
+					return err
+				}
+			}
+			return tx.Sagas.SetProgress(ctx, saga.ID, i, models.SagaStatusCompensating, saga.Payload)
+		})
+		if err != nil {
+			log.WithError(err).WithField("step", step.Name).Error("Saga compensation failed")
+			saga.Status = models.SagaStatusFailed
+			saga.CompletedSteps = i + 1
+			if err := o.sagaRepo.SetStatus(ctx, saga.ID, models.SagaStatusFailed, "", "compensating "+step.Name+": "+err.Error()); err != nil {
+				log.WithError(err).Error("Failed to mark saga as failed")
+			}
+			metrics.SagasFinished.WithLabelValues(string(saga.Type), string(saga.Status)).Inc()
+			return
+		}
+	}
+
+	saga.Status = models.SagaStatusCompensated
+	saga.CompletedSteps = 0
+	if err := o.sagaRepo.SetStatus(ctx, saga.ID, models.SagaStatusCompensated, "", ""); err != nil {
+		log.WithError(err).Error("Failed to mark saga as compensated")
+	}
+	metrics.SagasFinished.WithLabelValues(string(saga.Type), string(saga.Status)).Inc()
+}
+
+// Recover compensates sagas left unfinished by a crash or a failed
+// compensation. Interrupted sagas are rolled back rather than resumed, so a
+// workflow never completes on recovery without its caller knowing. It
+// returns the number of sagas recovered.
+func (o *SagaOrchestrator) Recover(ctx context.Context) (int, error) {
+	log := logger.WithComponent("saga_orchestrator")
+
+	sagas, err := o.sagaRepo.ListUnfinished(ctx, time.Now().Add(-sagaRecoveryAge))
+	if err != nil {
+		return 0, err
+	}
+
+	var recovered int
+	for _, saga := range sagas {
+		def, ok := o.definition(saga.Type)
+		if !ok {
+			log.WithField("saga_id", saga.ID).WithField("saga_type", saga.Type).Warn("Skipping saga of unknown type")
+			continue
+		}
+
+		payload := def.NewPayload()
+		if err := json.Unmarshal(saga.Payload, payload); err != nil {
+			log.WithError(err).WithField("saga_id", saga.ID).Error("Failed to decode saga payload")
+			continue
+		}
+
+		failedStep := ""
+		if saga.FailedStep == nil && saga.CompletedSteps < len(def.Steps) {
+			failedStep = def.Steps[saga.CompletedSteps].Name
+		}
+
+		o.compensate(ctx, def, saga, payload, saga.CompletedSteps, failedStep, interruptedError(saga.Status))
+		if saga.Status == models.SagaStatusCompensated {
+			recovered++
+		}
+	}
+
+	if len(sagas) > 0 {
+		log.WithField("found", len(sagas)).WithField("recovered", recovered).Info("Recovered unfinished sagas")
+	}
+
+	return recovered, nil
+}
+
+// interruptedError is the error recorded for a saga found mid-run; sagas
+// whose compensation already failed keep their original error
+func interruptedError(status models.SagaStatus) error {
+	if status == models.SagaStatusRunning {
+		return fmt.Errorf("saga interrupted before completing")
+	}
+	return nil
+}
+
+// sagaService implements SagaService
+type sagaService struct {
+	sagaRepo repository.SagaRepository
+}
+
+// NewSagaService creates a new saga service
+func NewSagaService(deps *Dependencies) SagaService {
+	return &sagaService{
+		sagaRepo: deps.SagaRepo,
+	}
+}
+
+func (s *sagaService) GetSaga(ctx context.Context, id uuid.UUID) (*models.Saga, error) {
+	saga, err := s.sagaRepo.GetByID(ctx, id)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).WithField("saga_id", id).Error("Failed to get saga")
+		return nil, err
+	}
+
+	return saga, nil
+}
+
+// ListSagas lists sagas by status, defaulting to those whose compensation
+// failed and need attention
+func (s *sagaService) ListSagas(ctx context.Context, status models.SagaStatus, limit, offset int) ([]*models.Saga, error) {
+	switch status {
+	case "":
+		status = models.SagaStatusFailed
+	case models.SagaStatusRunning, models.SagaStatusCompleted, models.SagaStatusCompensating,
+		models.SagaStatusCompensated, models.SagaStatusFailed:
+	default:
+		return nil, errors.NewValidationError("invalid status, expected RUNNING, COMPLETED, COMPENSATING, COMPENSATED or FAILED")
+	}
+
+	if limit <= 0 || limit > 100 {
+		limit = 10 // Default limit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	sagas, err := s.sagaRepo.ListByStatus(ctx, status, limit, offset)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to list sagas")
+		return nil, err
+	}
+
+	return sagas, nil
+}
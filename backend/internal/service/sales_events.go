@@ -0,0 +1,360 @@
+// Package service provides flash-sale events
+package service
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/metrics"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+)
+
+// SalesEvents holds the sales event schedule every order checks. A product
+// in an event is sold only while one of its events is live; before the
+// first starts and after the last ends its orders are refused, until the
+// events are deleted. The schedule is polled from the database, so an event
+// created on one replica reaches the others within one refresh interval;
+// the replica that created it sees it at once. With the stock fast path,
+// each event's products are loaded into Redis ahead of its start.
+type SalesEvents struct {
+	repo     repository.SalesEventRepository
+	fastPath *StockFastPath
+	interval time.Duration
+	lead     time.Duration
+
+	// schedule maps a product ID to its events by start
+	schedule atomic.Pointer[map[int][]*models.SalesEvent]
+
+	// mu serializes refreshes; warmed holds the events whose stock was
+	// loaded into the fast path
+	mu     sync.Mutex
+	warmed map[int]bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewSalesEvents creates a sales event schedule that is empty until Start
+// loads it. A nil fastPath skips pre-warming.
+func NewSalesEvents(repo repository.SalesEventRepository, fastPath *StockFastPath, interval, lead time.Duration) *SalesEvents {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	e := &SalesEvents{
+		repo:     repo,
+		fastPath: fastPath,
+		interval: interval,
+		lead:     lead,
+		warmed:   make(map[int]bool),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	e.schedule.Store(&map[int][]*models.SalesEvent{})
+	return e
+}
+
+// Start loads the schedule and starts polling for changes
+func (e *SalesEvents) Start() {
+	e.Refresh()
+
+	if e.interval <= 0 {
+		return
+	}
+
+	e.wg.Add(1)
+	go e.run()
+}
+
+// Stop stops polling
+func (e *SalesEvents) Stop() {
+	e.cancel()
+	e.wg.Wait()
+}
+
+func (e *SalesEvents) run() {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case <-ticker.C:
+			e.Refresh()
+		}
+	}
+}
+
+// Refresh reloads the schedule, keeping the last known one if the database
+// can't be read, and pre-warms the events starting soon
+func (e *SalesEvents) Refresh() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	events, err := e.repo.ListSchedule(e.ctx)
+	if err != nil {
+		logger.WithComponent("sales_events").WithError(err).Warn("Failed to refresh sales events")
+		return
+	}
+
+	schedule := make(map[int][]*models.SalesEvent)
+	for _, event := range events {
+		for _, productID := range event.ProductIDs {
+			schedule[productID] = append(schedule[productID], event)
+		}
+	}
+	e.schedule.Store(&schedule)
+
+	e.prewarm(events, time.Now())
+}
+
+// prewarm loads the stock of the products of events starting within the
+// lead time into the fast path, once per event. An event whose products
+// fail to load is tried again on the next refresh.
+func (e *SalesEvents) prewarm(events []*models.SalesEvent, now time.Time) {
+	if e.fastPath == nil {
+		return
+	}
+
+	for _, event := range events {
+		if e.warmed[event.ID] || now.Add(e.lead).Before(event.StartsAt) || !now.Before(event.EndsAt) {
+			continue
+		}
+
+		log := logger.WithComponent("sales_events").WithField("event_id", event.ID)
+		warmed := true
+		for _, productID := range event.ProductIDs {
+			if err := e.fastPath.Warm(e.ctx, productID); err != nil {
+				log.WithError(err).WithField("product_id", productID).Warn("Failed to pre-warm sales event stock")
+				warmed = false
+			}
+		}
+		if warmed {
+			e.warmed[event.ID] = true
+			log.WithField("products", len(event.ProductIDs)).Info("Pre-warmed sales event stock")
+		}
+	}
+}
+
+// Check returns the live event a product's order counts against, nil when
+// the product is in no event, or ErrSaleNotStarted or ErrSaleEnded when
+// none of its events is live at now. A nil SalesEvents checks nothing.
+func (e *SalesEvents) Check(productID int, now time.Time) (*models.SalesEvent, error) {
+	if e == nil {
+		return nil, nil
+	}
+
+	events := (*e.schedule.Load())[productID]
+	if len(events) == 0 {
+		return nil, nil
+	}
+
+	upcoming := false
+	for _, event := range events {
+		switch event.Status(now) {
+		case models.SalesEventLive:
+			return event, nil
+		case models.SalesEventScheduled:
+			upcoming = true
+		}
+	}
+	if upcoming {
+		return nil, errors.ErrSaleNotStarted
+	}
+	return nil, errors.ErrSaleEnded
+}
+
+// salesEventService implements SalesEventService
+type salesEventService struct {
+	uow    repository.UnitOfWork
+	repo   repository.SalesEventRepository
+	events *SalesEvents
+}
+
+// NewSalesEventService creates a new sales event service
+func NewSalesEventService(deps *Dependencies) SalesEventService {
+	return &salesEventService{
+		uow:    deps.unitOfWork(),
+		repo:   deps.SalesEventRepo,
+		events: deps.SalesEvents,
+	}
+}
+
+// CreateSalesEvent schedules a sales event. Its products are locked while
+// it is written, so two overlapping events cannot both take a product.
+func (s *salesEventService) CreateSalesEvent(ctx context.Context, req *models.CreateSalesEventRequest) (*models.SalesEvent, error) {
+	if !req.EndsAt.After(req.StartsAt) {
+		return nil, errors.NewValidationError("ends_at must be after starts_at")
+	}
+	if !req.EndsAt.After(time.Now()) {
+		return nil, errors.NewValidationError("ends_at must be in the future")
+	}
+
+	event := &models.SalesEvent{
+		Name:          strings.TrimSpace(req.Name),
+		StartsAt:      req.StartsAt.UTC(),
+		EndsAt:        req.EndsAt.UTC(),
+		PerBuyerLimit: req.PerBuyerLimit,
+	}
+	if event.Name == "" {
+		return nil, errors.NewValidationError("name must not be blank")
+	}
+	seen := make(map[int]bool, len(req.ProductIDs))
+	for _, id := range req.ProductIDs {
+		if !seen[id] {
+			seen[id] = true
+			event.ProductIDs = append(event.ProductIDs, id)
+		}
+	}
+	sort.Ints(event.ProductIDs)
+
+	err := s.uow.Do(ctx, nil, func(tx *repository.Tx) error {
+		for _, id := range event.ProductIDs {
+			if _, err := tx.Products.GetByIDForUpdate(ctx, id); err != nil {
+				return err
+			}
+		}
+		return tx.SalesEvents.Create(ctx, event)
+	})
+	if err != nil {
+		if _, ok := errors.IsAppError(err); !ok {
+			logger.WithContext(ctx).WithError(err).Error("Failed to create sales event")
+		}
+		return nil, err
+	}
+
+	if s.events != nil {
+		s.events.Refresh()
+	}
+
+	logger.WithContext(ctx).
+		WithField("event_id", event.ID).
+		WithField("products", len(event.ProductIDs)).
+		WithField("starts_at", event.StartsAt).
+		WithField("ends_at", event.EndsAt).
+		Info("Sales event created")
+
+	return s.GetSalesEvent(ctx, event.ID)
+}
+
+func (s *salesEventService) GetSalesEvent(ctx context.Context, id int) (*models.SalesEvent, error) {
+	event, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		if err != errors.ErrSalesEventNotFound {
+			logger.WithContext(ctx).WithError(err).Error("Failed to get sales event")
+		}
+		return nil, err
+	}
+
+	return event, nil
+}
+
+func (s *salesEventService) ListSalesEvents(ctx context.Context, limit, offset int) ([]*models.SalesEvent, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 10 // Default limit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	events, err := s.repo.List(ctx, limit, offset)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to list sales events")
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// DeleteSalesEvent removes a sales event, returning its products to normal
+// sale; their orders in the event are kept
+func (s *salesEventService) DeleteSalesEvent(ctx context.Context, id int) error {
+	if err := s.repo.Delete(ctx, id); err != nil {
+		if err != errors.ErrSalesEventNotFound {
+			logger.WithContext(ctx).WithError(err).Error("Failed to delete sales event")
+		}
+		return err
+	}
+
+	if s.events != nil {
+		s.events.Refresh()
+	}
+
+	logger.WithContext(ctx).WithField("event_id", id).Info("Sales event deleted")
+	return nil
+}
+
+// GetSalesEventStats reports a sales event's orders and its products'
+// stock as of now
+func (s *salesEventService) GetSalesEventStats(ctx context.Context, id int) (*models.SalesEventStats, error) {
+	event, err := s.GetSalesEvent(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	products, err := s.repo.Stats(ctx, id)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to get sales event stats")
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	stats := &models.SalesEventStats{
+		EventID:     event.ID,
+		Status:      event.Status(now),
+		Products:    products,
+		GeneratedAt: now,
+	}
+	for _, p := range products {
+		stats.Orders += p.Orders
+		stats.Units += p.Units
+		stats.RevenueCents += p.RevenueCents
+	}
+	return stats, nil
+}
+
+// checkSalesEvent applies the product's live sales event to an order, or
+// refuses it outside the product's event windows
+func (s *orderService) checkSalesEvent(placement *orderPlacement) error {
+	event, err := s.salesEvents.Check(placement.ProductID, time.Now())
+	switch err {
+	case errors.ErrSaleNotStarted:
+		metrics.SalesEventOrders.WithLabelValues("not_started").Inc()
+		return err
+	case errors.ErrSaleEnded:
+		metrics.SalesEventOrders.WithLabelValues("ended").Inc()
+		return err
+	}
+
+	if event != nil {
+		placement.SalesEvent = &placementSalesEvent{
+			ID:            event.ID,
+			PerBuyerLimit: event.PerBuyerLimit,
+			EndsAt:        event.EndsAt,
+		}
+	}
+	return nil
+}
+
+// countSalesEventOrder counts the outcome of an order placed in a sales
+// event
+func countSalesEventOrder(placement *orderPlacement, err error) {
+	if placement.SalesEvent == nil {
+		return
+	}
+	switch err {
+	case nil:
+		metrics.SalesEventOrders.WithLabelValues("accepted").Inc()
+	case errors.ErrPurchaseLimitExceeded:
+		metrics.SalesEventOrders.WithLabelValues("limit_exceeded").Inc()
+	}
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	apperrors "indico-backend/internal/errors"
+	"indico-backend/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSalesEventsCheck(t *testing.T) {
+	start := time.Date(2026, 11, 11, 0, 0, 0, 0, time.UTC)
+	first := &models.SalesEvent{ID: 1, StartsAt: start, EndsAt: start.Add(time.Hour), PerBuyerLimit: 2, ProductIDs: []int{1, 2}}
+	second := &models.SalesEvent{ID: 2, StartsAt: start.Add(24 * time.Hour), EndsAt: start.Add(25 * time.Hour), PerBuyerLimit: 1, ProductIDs: []int{1}}
+
+	e := NewSalesEvents(nil, nil, 0, 0)
+	e.schedule.Store(&map[int][]*models.SalesEvent{
+		1: {first, second},
+		2: {first},
+	})
+
+	// Products in no event sell as usual
+	event, err := e.Check(3, start)
+	require.NoError(t, err)
+	assert.Nil(t, event)
+
+	_, err = e.Check(1, start.Add(-time.Minute))
+	assert.ErrorIs(t, err, apperrors.ErrSaleNotStarted)
+
+	event, err = e.Check(1, start)
+	require.NoError(t, err)
+	assert.Equal(t, 1, event.ID)
+
+	// Between its events a product waits for the next one; after its last
+	// it stays off sale
+	_, err = e.Check(1, start.Add(2*time.Hour))
+	assert.ErrorIs(t, err, apperrors.ErrSaleNotStarted)
+	_, err = e.Check(2, start.Add(2*time.Hour))
+	assert.ErrorIs(t, err, apperrors.ErrSaleEnded)
+
+	event, err = e.Check(1, start.Add(24*time.Hour+time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 2, event.ID)
+
+	// A nil schedule checks nothing
+	var none *SalesEvents
+	event, err = none.Check(1, start.Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Nil(t, event)
+}
@@ -0,0 +1,118 @@
+// Package service provides business logic implementation
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"indico-backend/internal/config"
+	"indico-backend/internal/database"
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+)
+
+// SettlementScheduler creates a settlement job for the previous day once a
+// day. Weekend and holiday activity is rolled into the next business day by
+// the settlement job itself, so the schedule is the same every day. With a
+// leader, only the replica holding leadership creates the job.
+type SettlementScheduler struct {
+	jobService JobService
+	config     *config.SettlementConfig
+	leader     *database.Leader
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewSettlementScheduler creates a new settlement scheduler; a nil leader
+// runs every scheduled trigger
+func NewSettlementScheduler(jobService JobService, cfg *config.SettlementConfig, leader *database.Leader) *SettlementScheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &SettlementScheduler{
+		jobService: jobService,
+		config:     cfg,
+		leader:     leader,
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// Start starts the scheduler loop
+func (s *SettlementScheduler) Start() {
+	logger.WithComponent("settlement_scheduler").
+		WithField("schedule_at", s.config.ScheduleAt).
+		Info("Starting settlement scheduler")
+
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop stops the scheduler loop
+func (s *SettlementScheduler) Stop() {
+	s.cancel()
+	s.wg.Wait()
+
+	logger.WithComponent("settlement_scheduler").Info("Settlement scheduler stopped")
+}
+
+func (s *SettlementScheduler) run() {
+	defer s.wg.Done()
+
+	for {
+		next := nextScheduledRun(time.Now().UTC(), s.config.ScheduleAt)
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			s.trigger(next)
+		}
+	}
+}
+
+// trigger creates the settlement job for the day before the scheduled run
+func (s *SettlementScheduler) trigger(scheduledAt time.Time) {
+	log := logger.WithComponent("settlement_scheduler")
+	day := scheduledAt.AddDate(0, 0, -1).Format("2006-01-02")
+
+	if s.leader != nil && !s.leader.IsLeader(s.ctx) {
+		log.WithField("date", day).Debug("Not the leader, skipping scheduled run")
+		return
+	}
+
+	job, err := s.jobService.CreateSettlementJob(s.ctx, &models.CreateSettlementJobRequest{
+		From: day,
+		To:   day,
+	})
+	if err != nil {
+		if appErr, ok := errors.IsAppError(err); ok && appErr.Code == errors.ErrCodeConflict {
+			log.WithField("date", day).Warn("Settlement for date already running, skipping scheduled run")
+			return
+		}
+		log.WithError(err).WithField("date", day).Error("Failed to create scheduled settlement job")
+		return
+	}
+
+	log.WithField("date", day).WithField("job_id", job.ID).Info("Scheduled settlement job created")
+}
+
+// nextScheduledRun returns the next UTC occurrence of the HH:MM time of day
+// strictly after now
+func nextScheduledRun(now time.Time, at string) time.Time {
+	clock, err := time.Parse("15:04", at)
+	if err != nil {
+		clock = time.Date(0, 1, 1, 2, 0, 0, 0, time.UTC)
+	}
+
+	next := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, time.UTC)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
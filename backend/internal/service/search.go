@@ -0,0 +1,305 @@
+// Package service provides product and order search backed by Elasticsearch
+// or OpenSearch
+package service
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"indico-backend/internal/config"
+	"indico-backend/internal/database"
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/metrics"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+	"indico-backend/internal/search"
+
+	"github.com/google/uuid"
+)
+
+// maxSearchWindow is the deepest page a search may reach, the default
+// index.max_result_window of both Elasticsearch and OpenSearch
+const maxSearchWindow = 10000
+
+// searchIndexes are the indexes the indexer maintains and search may query
+var searchIndexes = []string{search.IndexProducts, search.IndexOrders}
+
+// SearchService handles search queries
+type SearchService interface {
+	Search(ctx context.Context, index string, req *models.SearchRequest) (*models.SearchResult, error)
+}
+
+// searchService implements SearchService
+type searchService struct {
+	client *search.Client
+}
+
+// NewSearchService creates a new search service; without a search client
+// every query fails with ErrSearchDisabled
+func NewSearchService(deps *Dependencies) SearchService {
+	return &searchService{
+		client: deps.Search,
+	}
+}
+
+// Search runs a structured query against the products or orders index
+func (s *searchService) Search(ctx context.Context, index string, req *models.SearchRequest) (*models.SearchResult, error) {
+	if s.client == nil {
+		return nil, errors.ErrSearchDisabled
+	}
+
+	known := false
+	for _, name := range searchIndexes {
+		if index == name {
+			known = true
+		}
+	}
+	if !known {
+		return nil, errors.NewValidationError("index must be products or orders")
+	}
+
+	size := req.Size
+	if size == 0 {
+		size = 10
+	}
+	if req.From+size > maxSearchWindow {
+		return nil, errors.NewValidationError("from + size must not exceed " + strconv.Itoa(maxSearchWindow))
+	}
+
+	found, err := s.client.Search(ctx, index, &search.Query{
+		Query: req.Query,
+		Sort:  req.Sort,
+		From:  req.From,
+		Size:  size,
+	})
+	if err != nil {
+		if searchErr, ok := err.(*search.Error); ok {
+			switch {
+			case searchErr.StatusCode == http.StatusBadRequest:
+				return nil, errors.NewValidationError("Invalid search query: " + searchErr.Reason)
+			case searchErr.Type == "index_not_found_exception":
+				// Nothing has been indexed yet
+				return &models.SearchResult{Hits: []*models.SearchHit{}}, nil
+			}
+		}
+		logger.WithContext(ctx).WithError(err).WithField("index", index).Error("Failed to search")
+		return nil, errors.NewAppErrorWithCause(errors.ErrCodeServiceUnavailable, "Search is temporarily unavailable", http.StatusServiceUnavailable, err)
+	}
+
+	result := &models.SearchResult{
+		Total: found.Hits.Total.Value,
+		Hits:  make([]*models.SearchHit, 0, len(found.Hits.Hits)),
+	}
+	for _, hit := range found.Hits.Hits {
+		result.Hits = append(result.Hits, &models.SearchHit{
+			ID:       hit.ID,
+			Score:    hit.Score,
+			Document: hit.Source,
+		})
+	}
+
+	return result, nil
+}
+
+// SearchIndexer mirrors products and orders into the search indexes. Each
+// pass copies the rows whose updated_at is at or after the index's sync
+// cursor, then moves the cursor to the database time minus the configured
+// overlap. Rows near the cursor are copied again on the next pass, which
+// picks up transactions that committed after a later change was copied;
+// re-indexing a document by ID is idempotent. With a leader, only the
+// replica holding leadership indexes.
+type SearchIndexer struct {
+	client     *search.Client
+	searchRepo repository.SearchRepository
+	config     *config.SearchConfig
+	leader     *database.Leader
+
+	// created records the indexes whose mapping is known to exist
+	created map[string]bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewSearchIndexer creates a new search indexer; a nil leader indexes on
+// every tick
+func NewSearchIndexer(client *search.Client, searchRepo repository.SearchRepository, cfg *config.SearchConfig, leader *database.Leader) *SearchIndexer {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &SearchIndexer{
+		client:     client,
+		searchRepo: searchRepo,
+		config:     cfg,
+		leader:     leader,
+		created:    make(map[string]bool),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// Start starts the indexer loop
+func (i *SearchIndexer) Start() {
+	logger.WithComponent("search_indexer").
+		WithField("interval", i.config.SyncInterval.String()).
+		WithField("overlap", i.config.SyncOverlap.String()).
+		Info("Starting search indexer")
+
+	i.wg.Add(1)
+	go i.run()
+}
+
+// Stop stops the indexer loop
+func (i *SearchIndexer) Stop() {
+	i.cancel()
+	i.wg.Wait()
+
+	logger.WithComponent("search_indexer").Info("Search indexer stopped")
+}
+
+func (i *SearchIndexer) run() {
+	defer i.wg.Done()
+
+	ticker := time.NewTicker(i.config.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-i.ctx.Done():
+			return
+		case <-ticker.C:
+			i.sync()
+		}
+	}
+}
+
+// sync runs one pass over every index; a failing index is retried from its
+// unchanged cursor on the next pass
+func (i *SearchIndexer) sync() {
+	log := logger.WithComponent("search_indexer")
+
+	if i.leader != nil && !i.leader.IsLeader(i.ctx) {
+		log.Debug("Not the leader, skipping search sync")
+		return
+	}
+
+	for _, index := range searchIndexes {
+		copied, err := i.syncIndex(i.ctx, index)
+		if err != nil {
+			metrics.SearchSyncErrors.WithLabelValues(index).Inc()
+			log.WithError(err).WithField("index", index).Error("Failed to sync search index")
+			continue
+		}
+		if copied > 0 {
+			log.WithField("index", index).WithField("documents", copied).Debug("Search index synced")
+		}
+	}
+}
+
+// syncIndex copies one index's changed rows and advances its cursor
+func (i *SearchIndexer) syncIndex(ctx context.Context, index string) (int, error) {
+	if !i.created[index] {
+		if err := i.client.EnsureIndex(ctx, index); err != nil {
+			return 0, err
+		}
+		i.created[index] = true
+	}
+
+	// Read the clock before the rows so nothing committed during the pass
+	// can fall behind the new cursor
+	now, err := i.searchRepo.Now(ctx)
+	if err != nil {
+		return 0, err
+	}
+	cursor, err := i.searchRepo.GetSyncCursor(ctx, index)
+	if err != nil {
+		return 0, err
+	}
+
+	var copied int
+	switch index {
+	case search.IndexProducts:
+		copied, err = i.copyProducts(ctx, cursor)
+	case search.IndexOrders:
+		copied, err = i.copyOrders(ctx, cursor)
+	}
+	if err != nil {
+		return copied, err
+	}
+
+	if next := now.Add(-i.config.SyncOverlap); next.After(cursor) {
+		if err := i.searchRepo.SaveSyncCursor(ctx, index, next); err != nil {
+			return copied, err
+		}
+	}
+
+	return copied, nil
+}
+
+// copyProducts indexes the products changed since the cursor, in batches
+func (i *SearchIndexer) copyProducts(ctx context.Context, since time.Time) (int, error) {
+	afterTime, afterID := since, 0
+	copied := 0
+
+	for {
+		products, err := i.searchRepo.ProductsChangedAfter(ctx, afterTime, afterID, i.config.SyncBatchSize)
+		if err != nil {
+			return copied, err
+		}
+		if len(products) == 0 {
+			return copied, nil
+		}
+
+		docs := make([]search.Document, len(products))
+		for j, product := range products {
+			docs[j] = search.Document{ID: strconv.Itoa(product.ID), Body: product}
+		}
+		if err := i.client.Bulk(ctx, search.IndexProducts, docs); err != nil {
+			return copied, err
+		}
+		copied += len(docs)
+		metrics.SearchDocumentsIndexed.WithLabelValues(search.IndexProducts).Add(float64(len(docs)))
+
+		last := products[len(products)-1]
+		afterTime, afterID = last.UpdatedAt, last.ID
+		if len(products) < i.config.SyncBatchSize {
+			return copied, nil
+		}
+	}
+}
+
+// copyOrders indexes the orders changed since the cursor, in batches
+func (i *SearchIndexer) copyOrders(ctx context.Context, since time.Time) (int, error) {
+	afterTime, afterID := since, uuid.Nil
+	copied := 0
+
+	for {
+		orders, err := i.searchRepo.OrdersChangedAfter(ctx, afterTime, afterID, i.config.SyncBatchSize)
+		if err != nil {
+			return copied, err
+		}
+		if len(orders) == 0 {
+			return copied, nil
+		}
+
+		docs := make([]search.Document, len(orders))
+		for j, order := range orders {
+			docs[j] = search.Document{ID: order.ID.String(), Body: order}
+		}
+		if err := i.client.Bulk(ctx, search.IndexOrders, docs); err != nil {
+			return copied, err
+		}
+		copied += len(docs)
+		metrics.SearchDocumentsIndexed.WithLabelValues(search.IndexOrders).Add(float64(len(docs)))
+
+		last := orders[len(orders)-1]
+		afterTime, afterID = last.UpdatedAt, last.ID
+		if len(orders) < i.config.SyncBatchSize {
+			return copied, nil
+		}
+	}
+}
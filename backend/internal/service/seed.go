@@ -0,0 +1,92 @@
+// Package service provides server-side seeding for test environments
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+	"indico-backend/internal/seed"
+)
+
+// defaultSeedCount is the number of transactions seeded when the request
+// doesn't say
+const defaultSeedCount = 10000
+
+// seedService implements SeedService
+type seedService struct {
+	txRepo   repository.TransactionWriter
+	enabled  bool
+	maxCount int
+}
+
+// NewSeedService creates a new seed service; seeding stays disabled unless
+// the admin config enables it
+func NewSeedService(deps *Dependencies) SeedService {
+	s := &seedService{txRepo: deps.TxRepo}
+	if deps.AdminConfig != nil {
+		s.enabled = deps.AdminConfig.SeedEnabled
+		s.maxCount = deps.AdminConfig.SeedMaxCount
+	}
+	return s
+}
+
+// Seed inserts generated transactions the same way the seeder command does,
+// truncating the table first when the request asks for a reset. The request
+// waits for the inserts, so the count is capped to keep it within the
+// request timeout; larger volumes belong to the seeder command.
+func (s *seedService) Seed(ctx context.Context, req *models.SeedRequest) (*models.SeedResult, error) {
+	if !s.enabled {
+		return nil, errors.ErrSeedDisabled
+	}
+
+	opts := req.SeedOptions
+	if opts.Count == 0 {
+		opts.Count = defaultSeedCount
+	}
+	if opts.Count < 0 {
+		return nil, errors.NewValidationError("count must be positive")
+	}
+	if s.maxCount > 0 && opts.Count > s.maxCount {
+		return nil, errors.NewValidationError(fmt.Sprintf("count must be at most %d; seed larger volumes with the seeder command", s.maxCount))
+	}
+	if err := seed.Validate(&opts); err != nil {
+		return nil, errors.NewValidationError(err.Error())
+	}
+
+	log := logger.WithContext(ctx).
+		WithField("count", opts.Count).
+		WithField("reset", req.Reset)
+
+	if req.Reset {
+		if err := s.txRepo.Truncate(ctx); err != nil {
+			log.WithError(err).Error("Failed to reset transactions")
+			return nil, err
+		}
+	}
+
+	start := time.Now()
+	inserted := 0
+	err := seed.Transactions(ctx, s.txRepo, &opts, 0, func(n int) error {
+		inserted = n
+		return nil
+	})
+	if err != nil {
+		log.WithError(err).WithField("inserted", inserted).Error("Failed to seed transactions")
+		return nil, err
+	}
+
+	elapsed := time.Since(start)
+	log.WithField("duration", elapsed).Warn("Seeded transactions")
+
+	return &models.SeedResult{
+		Options:  opts,
+		Reset:    req.Reset,
+		Inserted: inserted,
+		Duration: elapsed.Round(time.Millisecond).String(),
+	}, nil
+}
@@ -3,33 +3,190 @@ package service
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
 	"fmt"
+	"io"
+	"sort"
 	"time"
 
+	"indico-backend/internal/config"
 	"indico-backend/internal/database"
 	"indico-backend/internal/errors"
 	"indico-backend/internal/logger"
 	"indico-backend/internal/metrics"
 	"indico-backend/internal/models"
 	"indico-backend/internal/repository"
+	"indico-backend/internal/search"
+	"indico-backend/internal/storage"
 
 	"github.com/google/uuid"
 )
 
+// ProductService handles product business logic
+type ProductService interface {
+	GetProduct(ctx context.Context, id int) (*models.Product, error)
+	GetProductBySKU(ctx context.Context, sku string) (*models.Product, error)
+	GetProducts(ctx context.Context, ids []int) ([]*models.Product, error)
+	ListProducts(ctx context.Context, limit, offset int) ([]*models.Product, error)
+	CreateProduct(ctx context.Context, req *models.CreateProductRequest) (*models.Product, error)
+	UpdateProduct(ctx context.Context, id int, req *models.UpdateProductRequest) (*models.Product, error)
+	DeleteProduct(ctx context.Context, id int) error
+	GetSnapshot(ctx context.Context, updatedSince string, limit, offset int) ([]*models.ProductSnapshot, error)
+	SyncStock(ctx context.Context, req *models.StockSyncRequest) (*models.StockSyncResult, error)
+	ListMovements(ctx context.Context, productID int, limit, offset int) ([]*models.InventoryMovement, error)
+	SetBackorderLimit(ctx context.Context, id int, limit int) (*models.Product, error)
+}
+
+// BundleService manages product bundles
+type BundleService interface {
+	CreateBundle(ctx context.Context, req *models.CreateBundleRequest) (*models.Bundle, error)
+	UpdateBundle(ctx context.Context, id int, req *models.UpdateBundleRequest) (*models.Bundle, error)
+	GetBundle(ctx context.Context, id int) (*models.Bundle, error)
+	ListBundles(ctx context.Context, limit, offset int) ([]*models.Bundle, error)
+}
+
 // OrderService handles order business logic
 type OrderService interface {
 	CreateOrder(ctx context.Context, req *models.CreateOrderRequest) (*models.Order, error)
 	GetOrder(ctx context.Context, id uuid.UUID) (*models.Order, error)
+	GetOrderByClientReference(ctx context.Context, clientReference string) (*models.Order, error)
+	GetOrderEvents(ctx context.Context, id uuid.UUID) ([]*models.OrderEvent, error)
+	CancelOrder(ctx context.Context, id uuid.UUID) (*models.Order, error)
 	ListOrders(ctx context.Context, limit, offset int) ([]*models.Order, error)
+	ListOrdersPage(ctx context.Context, req *models.ListOrdersRequest) (*models.OrderPage, error)
+	CountOrders(ctx context.Context, req *models.ListOrdersRequest) (int, error)
+	StreamOrders(ctx context.Context, limit, offset int, fn func(*models.Order) error) error
 }
 
 // JobService handles job business logic
 type JobService interface {
 	CreateSettlementJob(ctx context.Context, req *models.CreateSettlementJobRequest) (*models.Job, error)
+	CreateReorderForecastJob(ctx context.Context, req *models.CreateReorderForecastJobRequest) (*models.Job, error)
+	CreateOrdersExportJob(ctx context.Context, req *models.CreateOrdersExportJobRequest) (*models.Job, error)
+	CreateResettleJob(ctx context.Context) (*models.Job, error)
+	CreateMerchantStatementJob(ctx context.Context, req *models.CreateMerchantStatementJobRequest) (*models.Job, error)
+	ListBackfills(ctx context.Context) ([]*models.BackfillStatus, error)
+	CreateBackfillJob(ctx context.Context, req *models.CreateBackfillJobRequest) (*models.Job, error)
+	CreateBuyerErasureJob(ctx context.Context, buyerID string) (*models.Job, error)
+	CreateBackorderFulfillmentJob(ctx context.Context, req *models.CreateBackorderFulfillmentJobRequest) (*models.Job, error)
+	CreatePayoutFileJob(ctx context.Context, req *models.CreatePayoutFileJobRequest) (*models.Job, error)
+	ListJobFiles(ctx context.Context, id uuid.UUID) ([]*models.JobFile, error)
+	OpenJobFile(ctx context.Context, id uuid.UUID, name string) (io.ReadCloser, *models.JobFile, error)
+	OpenMerchantSettlement(ctx context.Context, id uuid.UUID, merchantID string) (io.ReadCloser, *models.JobFile, error)
+	OpenJobResult(ctx context.Context, id uuid.UUID, ext string) (storage.Object, *models.JobFile, error)
+	WriteJobFilesZip(w io.Writer, id uuid.UUID, files []*models.JobFile) error
+	GetReorderRecommendations(ctx context.Context, id uuid.UUID) ([]*models.ReorderRecommendation, error)
 	GetJob(ctx context.Context, id uuid.UUID) (*models.Job, error)
-	CancelJob(ctx context.Context, id uuid.UUID) error
+	GetJobs(ctx context.Context, ids []uuid.UUID) ([]*models.Job, error)
+	GetJobStats(ctx context.Context, id uuid.UUID) (*models.JobStats, error)
+	GetJobLogs(ctx context.Context, id uuid.UUID, level string, after int64, limit int) (*models.JobLogs, error)
+	CancelJob(ctx context.Context, id uuid.UUID) (models.JobStatus, error)
+	ListJobs(ctx context.Context, filter *models.JobFilter, limit, offset int) ([]*models.Job, error)
+	ListDeadLetteredJobs(ctx context.Context, limit, offset int) ([]*models.Job, error)
+	RedriveJob(ctx context.Context, id uuid.UUID) (*models.Job, error)
+}
+
+// SettlementService handles settlement business logic
+type SettlementService interface {
+	ListRuns(ctx context.Context, limit, offset int) ([]*models.SettlementRun, error)
+	GetRun(ctx context.Context, id uuid.UUID) (*models.SettlementRun, error)
+	GetLatestRun(ctx context.Context) (*models.SettlementRun, error)
+	ListRunSettlements(ctx context.Context, runID uuid.UUID) ([]*models.Settlement, error)
+	ListRunsSettlements(ctx context.Context, runIDs []uuid.UUID) ([]*models.Settlement, error)
+	ListStale(ctx context.Context, limit, offset int) ([]*models.Settlement, error)
+	DetectStale(ctx context.Context, req *models.DetectStaleSettlementsRequest) (*models.StaleDetection, error)
+	LateTransactions(ctx context.Context, from, to string) (*models.LateTransactionReport, error)
+	CreateAdjustment(ctx context.Context, req *models.CreateSettlementAdjustmentRequest) (*models.SettlementAdjustment, error)
+	ListAdjustments(ctx context.Context, req *models.ListSettlementsRequest) ([]*models.SettlementAdjustment, error)
+	ListSettlements(ctx context.Context, req *models.ListSettlementsRequest, limit, offset int) ([]*models.Settlement, error)
+	StreamSettlements(ctx context.Context, req *models.ListSettlementsRequest, limit, offset int, fn func(*models.Settlement) error) error
+}
+
+// TransactionService handles transaction lifecycle logic
+type TransactionService interface {
+	UpdateStatus(ctx context.Context, id int, req *models.UpdateTransactionStatusRequest) (*models.TransactionStatusChange, error)
+	ListTransactions(ctx context.Context, req *models.ListTransactionsRequest, limit, offset int) ([]*models.Transaction, error)
+	StreamTransactions(ctx context.Context, req *models.ListTransactionsRequest, limit, offset int, fn func(*models.Transaction) error) error
+	SampleTransactions(ctx context.Context, req *models.ListTransactionsRequest, n int) ([]*models.Transaction, error)
+	ApplyPaymentEvent(ctx context.Context, event *models.PaymentEvent) (*models.PaymentEventResult, error)
+}
+
+// MerchantService handles merchant reporting logic
+type MerchantService interface {
+	GetDashboard(ctx context.Context, merchantID, from, to string) (*models.MerchantDashboard, error)
+}
+
+// CalendarService handles settlement calendar logic
+type CalendarService interface {
+	ListHolidays(ctx context.Context, region string) ([]*models.Holiday, error)
+	CreateHoliday(ctx context.Context, req *models.CreateHolidayRequest) (*models.Holiday, error)
+	SetMerchantCalendar(ctx context.Context, merchantID string, req *models.SetMerchantCalendarRequest) (*models.MerchantCalendar, error)
+}
+
+// PayoutService handles merchant bank accounts and payouts
+type PayoutService interface {
+	SetBankAccount(ctx context.Context, merchantID string, req *models.SetMerchantBankAccountRequest) (*models.MerchantBankAccount, error)
+	GetBankAccount(ctx context.Context, merchantID string) (*models.MerchantBankAccount, error)
+	CreatePayout(ctx context.Context, req *models.CreatePayoutRequest) (*models.Payout, error)
+	GetPayout(ctx context.Context, id uuid.UUID) (*models.Payout, error)
+	ListPayouts(ctx context.Context, status models.PayoutStatus, limit, offset int) ([]*models.Payout, error)
+	ApprovePayout(ctx context.Context, id uuid.UUID) (*models.Payout, error)
+}
+
+// StatsService handles operational statistics for admin dashboards
+type StatsService interface {
+	OrdersPerMinute(ctx context.Context, minutes int) (*models.OrderRateStats, error)
+	JobThroughput(ctx context.Context, hours int) (*models.JobThroughputStats, error)
+	JobQueue(ctx context.Context) (*models.JobQueueStats, error)
+	ErrorRates(ctx context.Context, hours int) (*models.ErrorRateStats, error)
+	TopMerchants(ctx context.Context, from, to string, limit int) (*models.TopMerchantsStats, error)
+	OrderAnalytics(ctx context.Context, from, to string, productID int) (*models.OrderAnalyticsStats, error)
+}
+
+// SalesEventService manages flash-sale events
+type SalesEventService interface {
+	CreateSalesEvent(ctx context.Context, req *models.CreateSalesEventRequest) (*models.SalesEvent, error)
+	GetSalesEvent(ctx context.Context, id int) (*models.SalesEvent, error)
+	ListSalesEvents(ctx context.Context, limit, offset int) ([]*models.SalesEvent, error)
+	DeleteSalesEvent(ctx context.Context, id int) error
+	GetSalesEventStats(ctx context.Context, id int) (*models.SalesEventStats, error)
+}
+
+// WaitingRoomService issues queue tokens for products behind the waiting
+// room
+type WaitingRoomService interface {
+	CreateQueueToken(ctx context.Context, req *models.CreateQueueTokenRequest) (*models.QueueToken, error)
+}
+
+// SagaService handles saga inspection logic
+type SagaService interface {
+	GetSaga(ctx context.Context, id uuid.UUID) (*models.Saga, error)
+	ListSagas(ctx context.Context, status models.SagaStatus, limit, offset int) ([]*models.Saga, error)
+}
+
+// MaintenanceService handles maintenance mode
+type MaintenanceService interface {
+	State() *models.MaintenanceState
+	GetMaintenance(ctx context.Context) (*models.MaintenanceStatus, error)
+	SetMaintenance(ctx context.Context, req *models.SetMaintenanceRequest) (*models.MaintenanceStatus, error)
+}
+
+// SeedService populates test environments with generated transactions
+type SeedService interface {
+	Seed(ctx context.Context, req *models.SeedRequest) (*models.SeedResult, error)
+}
+
+// WebhookService manages webhook receivers and sends them signed callbacks
+type WebhookService interface {
+	CreateEndpoint(ctx context.Context, req *models.CreateWebhookEndpointRequest) (*models.WebhookEndpoint, error)
+	ListEndpoints(ctx context.Context) ([]*models.WebhookEndpoint, error)
+	DeleteEndpoint(ctx context.Context, id uuid.UUID) error
+	Ping(ctx context.Context, id uuid.UUID) (*models.WebhookPing, error)
+}
+
+// AuthService reads the audit trail of authentication bans
+type AuthService interface {
+	ListAuthBans(ctx context.Context, limit, offset int) ([]*models.AuthBan, error)
 }
 
 // HealthService handles health check logic
@@ -39,96 +196,426 @@ type HealthService interface {
 
 // Services contains all service implementations
 type Services struct {
-	Order  OrderService
-	Job    JobService
-	Health HealthService
+	Product     ProductService
+	Bundle      BundleService
+	SalesEvent  SalesEventService
+	WaitingRoom WaitingRoomService
+	Order       OrderService
+	Job         JobService
+	Settlement  SettlementService
+	Transaction TransactionService
+	Merchant    MerchantService
+	Calendar    CalendarService
+	Payout      PayoutService
+	Stats       StatsService
+	Saga        SagaService
+	Maintenance MaintenanceService
+	Seed        SeedService
+	Search      SearchService
+	Webhook     WebhookService
+	Auth        AuthService
+	Health      HealthService
+	// Pressure is nil when load shedding is disabled
+	Pressure PressureGauge
+	// AuthGuard is nil when brute-force protection is disabled
+	AuthGuard *AuthGuard
+}
+
+// PressureGauge reports whether the database connection pool is saturated
+type PressureGauge interface {
+	Saturated() bool
 }
 
 // Dependencies contains service dependencies
 type Dependencies struct {
-	DB           *database.DB
-	ProductRepo  repository.ProductRepository
-	OrderRepo    repository.OrderRepository
-	TxRepo       repository.TransactionRepository
-	SettleRepo   repository.SettlementRepository
-	JobRepo      repository.JobRepository
+	DB              *database.DB
+	ProductRepo     repository.ProductRepository
+	OrderRepo       repository.OrderRepository
+	BundleRepo      repository.BundleRepository
+	SalesEventRepo  repository.SalesEventRepository
+	TxRepo          repository.TransactionRepository
+	SettleRepo      repository.SettlementRepository
+	JobRepo         repository.JobRepository
+	ForecastRepo    repository.ForecastRepository
+	CalendarRepo    repository.CalendarRepository
+	StatsRepo       repository.StatsRepository
+	AnalyticsRepo   repository.AnalyticsRepository
+	SagaRepo        repository.SagaRepository
+	MaintenanceRepo repository.MaintenanceRepository
+	WebhookRepo     repository.WebhookRepository
+	AuthBanRepo     repository.AuthBanRepository
+	PayoutRepo      repository.PayoutRepository
+	// UnitOfWork is built from DB and the repositories above when nil
+	UnitOfWork repository.UnitOfWork
+	Sagas      *SagaOrchestrator
+	// OrderQueue is nil when orders are placed directly
+	OrderQueue *OrderQueue
+	// FastPath is nil unless orders are accepted through Redis
+	FastPath *StockFastPath
+	// SalesEvents is nil when no sales event schedule is kept
+	SalesEvents *SalesEvents
+	// WaitingRoom is nil when no product needs a queue token
+	WaitingRoom *WaitingRoom
+	// AuthGuard is nil when brute-force protection is disabled
+	AuthGuard *AuthGuard
+	// Pricing is nil to price orders at the list price
+	Pricing      *Pricing
+	Maintenance  *MaintenanceMode
 	JobProcessor *JobProcessor
+	JobsConfig   *config.JobsConfig
+	AdminConfig  *config.AdminConfig
+	// WebhookConfig defaults apply when nil
+	WebhookConfig *config.WebhookConfig
+	// PayoutConfig is nil when no bank files are written
+	PayoutConfig *config.PayoutConfig
+	// Search is nil when no search cluster is configured
+	Search *search.Client
+	// Pressure is nil when load shedding is disabled
+	Pressure *database.PressureMonitor
+	// HealthConfig defaults apply when nil
+	HealthConfig *config.HealthConfig
+	// OrdersConfig defaults apply when nil
+	OrdersConfig *config.OrdersConfig
+	// Files serves job output files; nil serves them from where
+	// JobProcessor writes them
+	Files storage.Store
+}
+
+// resultStore returns the configured job file store, or the one the job
+// processor writes to
+func (d *Dependencies) resultStore() storage.Store {
+	if d.Files != nil {
+		return d.Files
+	}
+	if d.JobProcessor != nil {
+		return d.JobProcessor.files
+	}
+	return storage.NewLocal(defaultResultDir)
+}
+
+// unitOfWork returns the configured unit of work, or one binding the
+// dependencies' repositories to transactions on DB
+func (d *Dependencies) unitOfWork() repository.UnitOfWork {
+	if d.UnitOfWork != nil {
+		return d.UnitOfWork
+	}
+	return repository.NewUnitOfWork(d.DB, repository.Writers{
+		Products:     d.ProductRepo,
+		Orders:       d.OrderRepo,
+		Bundles:      d.BundleRepo,
+		SalesEvents:  d.SalesEventRepo,
+		Transactions: d.TxRepo,
+		Settlements:  d.SettleRepo,
+		Forecasts:    d.ForecastRepo,
+		Jobs:         d.JobRepo,
+		Sagas:        d.SagaRepo,
+		Payouts:      d.PayoutRepo,
+	})
 }
 
 // NewServices creates a new services instance
 func NewServices(deps *Dependencies) *Services {
 	return &Services{
-		Order:  NewOrderService(deps),
-		Job:    NewJobService(deps),
-		Health: NewHealthService(deps),
+		Product:     NewProductService(deps),
+		Bundle:      NewBundleService(deps),
+		SalesEvent:  NewSalesEventService(deps),
+		WaitingRoom: NewWaitingRoomService(deps),
+		Order:       NewOrderService(deps),
+		Job:         NewJobService(deps),
+		Settlement:  NewSettlementService(deps),
+		Transaction: NewTransactionService(deps),
+		Merchant:    NewMerchantService(deps),
+		Calendar:    NewCalendarService(deps),
+		Payout:      NewPayoutService(deps),
+		Stats:       NewStatsService(deps),
+		Saga:        NewSagaService(deps),
+		Maintenance: NewMaintenanceService(deps),
+		Seed:        NewSeedService(deps),
+		Search:      NewSearchService(deps),
+		Webhook:     NewWebhookService(deps),
+		Auth:        NewAuthService(deps),
+		Health:      NewHealthService(deps),
+		Pressure:    deps.Pressure,
+		AuthGuard:   deps.AuthGuard,
 	}
 }
 
+// productService implements ProductService
+type productService struct {
+	uow         repository.UnitOfWork
+	productRepo repository.ProductRepository
+	// jobs queues backorder fulfillment after a stock change; nil without a
+	// job processor
+	jobs JobService
+}
+
+// NewProductService creates a new product service
+func NewProductService(deps *Dependencies) ProductService {
+	s := &productService{
+		uow:         deps.unitOfWork(),
+		productRepo: deps.ProductRepo,
+	}
+	if deps.JobProcessor != nil {
+		s.jobs = NewJobService(deps)
+	}
+	return s
+}
+
+func (s *productService) GetProduct(ctx context.Context, id int) (*models.Product, error) {
+	product, err := s.productRepo.GetByID(ctx, id)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).WithField("product_id", id).Error("Failed to get product")
+		return nil, err
+	}
+
+	return product, nil
+}
+
+func (s *productService) GetProductBySKU(ctx context.Context, sku string) (*models.Product, error) {
+	product, err := s.productRepo.GetBySKU(ctx, sku)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).WithField("sku", sku).Error("Failed to get product by SKU")
+		return nil, err
+	}
+
+	return product, nil
+}
+
+func (s *productService) GetProducts(ctx context.Context, ids []int) ([]*models.Product, error) {
+	products, err := s.productRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to get products")
+		return nil, err
+	}
+
+	return products, nil
+}
+
+func (s *productService) ListProducts(ctx context.Context, limit, offset int) ([]*models.Product, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 10 // Default limit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	products, err := s.productRepo.List(ctx, limit, offset)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to list products")
+		return nil, err
+	}
+
+	return products, nil
+}
+
+// GetSnapshot returns a page of products changed at or after updatedSince,
+// an RFC 3339 timestamp; an empty value dumps the whole catalog
+func (s *productService) GetSnapshot(ctx context.Context, updatedSince string, limit, offset int) ([]*models.ProductSnapshot, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	var since time.Time
+	if updatedSince != "" {
+		parsed, err := time.Parse(time.RFC3339, updatedSince)
+		if err != nil {
+			return nil, errors.NewValidationError("updated_since must be an RFC 3339 timestamp")
+		}
+		since = parsed
+	}
+
+	snapshots, err := s.productRepo.Snapshot(ctx, since, limit, offset)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to get product snapshot")
+		return nil, err
+	}
+
+	return snapshots, nil
+}
+
 // orderService implements OrderService
 type orderService struct {
-	db          *database.DB
-	productRepo repository.ProductRepository
-	orderRepo   repository.OrderRepository
+	uow       repository.UnitOfWork
+	orderRepo repository.OrderReader
+	sagas     *SagaOrchestrator
+	// queue places orders one at a time per product; nil places them on
+	// the caller's goroutine
+	queue *OrderQueue
+	// fastPath, when set, accepts orders in Redis ahead of Postgres
+	fastPath *StockFastPath
+	// maxListOffset rejects deeper offset pages; 0 allows any offset
+	maxListOffset int
+	// dedup answers a buyer's repeated order with the one already placed;
+	// nil places every order
+	dedup *orderDedup
+	// salesEvents refuses orders outside their products' sales events; nil
+	// checks none
+	salesEvents *SalesEvents
+	// waitingRoom refuses orders without a queue token admitting them; nil
+	// admits all
+	waitingRoom *WaitingRoom
+	// jobs queues backorder fulfillment after a cancellation frees stock;
+	// nil without a job processor
+	jobs JobService
 }
 
 // NewOrderService creates a new order service
 func NewOrderService(deps *Dependencies) OrderService {
-	return &orderService{
-		db:          deps.DB,
-		productRepo: deps.ProductRepo,
-		orderRepo:   deps.OrderRepo,
+	sagas := deps.Sagas
+	if sagas == nil {
+		sagas = NewSagaOrchestrator(deps.unitOfWork(), deps.SagaRepo)
+	}
+	sagas.Register(orderPlacementSaga(deps.Pricing))
+
+	maxListOffset := defaultOrderListMaxOffset
+	var dedup *orderDedup
+	if deps.OrdersConfig != nil {
+		maxListOffset = deps.OrdersConfig.ListMaxOffset
+		if deps.OrdersConfig.DedupWindow > 0 {
+			dedup = newOrderDedup(deps.OrdersConfig.DedupWindow, deps.OrderRepo)
+		}
+	}
+
+	s := &orderService{
+		uow:           deps.unitOfWork(),
+		orderRepo:     deps.OrderRepo,
+		sagas:         sagas,
+		queue:         deps.OrderQueue,
+		fastPath:      deps.FastPath,
+		maxListOffset: maxListOffset,
+		dedup:         dedup,
+		salesEvents:   deps.SalesEvents,
+		waitingRoom:   deps.WaitingRoom,
+	}
+	if deps.JobProcessor != nil {
+		s.jobs = NewJobService(deps)
 	}
+	return s
 }
 
+// maxOrderAttempts bounds how often an order is placed again after losing a
+// concurrent stock update
+const maxOrderAttempts = 3
+
+// CreateOrder places an order through the ORDER_PLACEMENT saga, so a failure
+// after stock has been reserved releases it again. In queued mode the saga
+// runs on the product's order queue; with the stock fast path the order is
+// accepted in Redis and returned PENDING, and the saga runs later.
 func (s *orderService) CreateOrder(ctx context.Context, req *models.CreateOrderRequest) (*models.Order, error) {
 	// Validate request
 	if req.Quantity <= 0 {
 		return nil, errors.NewValidationError("quantity must be positive")
 	}
 
-	// Create order within transaction to ensure consistency
-	var order *models.Order
-	err := s.db.WithTx(ctx, func(tx *sql.Tx) error {
-		// Get product with lock for update
-		product, err := s.productRepo.GetByIDForUpdate(ctx, tx, req.ProductID)
-		if err != nil {
-			return err
+	// A repeated request returns the order already placed under its
+	// reference, or without one the buyer's order within the dedup window
+	if req.ClientReference != "" {
+		if order, err := s.placedOrder(ctx, req); order != nil || err != nil {
+			return order, err
 		}
+	} else if s.dedup != nil {
+		return s.dedup.place(ctx, req, func(ctx context.Context) (*models.Order, error) {
+			return s.placeNew(ctx, req)
+		})
+	}
+
+	return s.placeNew(ctx, req)
+}
+
+// placeNew places req as a new order
+func (s *orderService) placeNew(ctx context.Context, req *models.CreateOrderRequest) (*models.Order, error) {
+	if err := s.waitingRoom.Admit(req.QueueToken, req.ProductID, req.BuyerID, time.Now()); err != nil {
+		return nil, err
+	}
 
-		// Check stock availability
-		if product.Stock < req.Quantity {
-			return errors.ErrOutOfStock
+	placement := &orderPlacement{
+		OrderID:   uuid.New(),
+		ProductID: req.ProductID,
+		BuyerID:   req.BuyerID,
+		Quantity:  req.Quantity,
+	}
+	if req.ClientReference != "" {
+		placement.ClientReference = &req.ClientReference
+	}
+	if err := s.checkSalesEvent(placement); err != nil {
+		return nil, err
+	}
+
+	order, err := s.place(ctx, req, placement)
+	countSalesEventOrder(placement, err)
+	return order, err
+}
+
+// place places an order on the fast path or through the saga
+func (s *orderService) place(ctx context.Context, req *models.CreateOrderRequest, placement *orderPlacement) (*models.Order, error) {
+	// The fast path answers from Redis and places the order later; bundle
+	// orders are placed right away
+	if s.fastPath != nil {
+		order, err := s.reserve(ctx, req, placement)
+		if err != errFastPathDirect {
+			return order, err
 		}
+	}
+
+	place := func(ctx context.Context) (*models.Saga, error) {
+		return placeOrder(ctx, s.sagas, placement)
+	}
 
-		// Calculate total
-		totalCents := product.Price * req.Quantity
-
-		// Create order
-		order = &models.Order{
-			ID:         uuid.New(),
-			ProductID:  req.ProductID,
-			BuyerID:    req.BuyerID,
-			Quantity:   req.Quantity,
-			Status:     models.OrderStatusPending,
-			TotalCents: totalCents,
+	var saga *models.Saga
+	var err error
+	if s.queue != nil {
+		saga, err = s.queue.Submit(ctx, req.ProductID, place)
+	} else {
+		saga, err = place(ctx)
+	}
+	if err != nil {
+		if appErr, _ := errors.IsAppError(err); appErr != nil && appErr.Code == errors.ErrCodeOutOfStock {
+			metrics.OrdersOutOfStock.WithLabelValues(metrics.ProductBucket(req.ProductID)).Inc()
 		}
 
-		if err := s.orderRepo.Create(ctx, tx, order); err != nil {
-			return err
+		// A concurrent request with the same reference won the insert
+		if err == errors.ErrClientReferenceConflict {
+			if order, placedErr := s.placedOrder(ctx, req); order != nil || placedErr != nil {
+				return order, placedErr
+			}
 		}
 
-		// Update product stock with optimistic locking
-		if err := s.productRepo.UpdateStock(ctx, tx, product.ID, req.Quantity, product.Version); err != nil {
-			return err
+		log := logger.WithContext(ctx).WithError(err)
+		if saga != nil {
+			log = log.WithField("saga_id", saga.ID).WithField("saga_status", saga.Status)
 		}
+		log.Error("Failed to create order")
+		return nil, err
+	}
 
-		// Update order status to confirmed
-		order.Status = models.OrderStatusConfirmed
-		return nil
-	})
+	logger.WithContext(ctx).
+		WithField("order_id", placement.OrderID).
+		WithField("saga_id", saga.ID).
+		WithField("buyer_id", req.BuyerID).
+		WithField("product_id", req.ProductID).
+		WithField("quantity", req.Quantity).
+		Info("Order created successfully")
+
+	return placement.order(), nil
+}
 
+// reserve accepts an order on the stock fast path
+func (s *orderService) reserve(ctx context.Context, req *models.CreateOrderRequest, placement *orderPlacement) (*models.Order, error) {
+	order, err := s.fastPath.Reserve(ctx, placement)
+	if err == errFastPathDirect {
+		return nil, err
+	}
 	if err != nil {
-		logger.WithContext(ctx).WithError(err).Error("Failed to create order")
+		if err == errors.ErrOutOfStock {
+			metrics.OrdersOutOfStock.WithLabelValues(metrics.ProductBucket(req.ProductID)).Inc()
+		}
+		logger.WithContext(ctx).WithError(err).Error("Failed to reserve order on the stock fast path")
 		return nil, err
 	}
 
@@ -137,7 +624,70 @@ func (s *orderService) CreateOrder(ctx context.Context, req *models.CreateOrderR
 		WithField("buyer_id", req.BuyerID).
 		WithField("product_id", req.ProductID).
 		WithField("quantity", req.Quantity).
-		Info("Order created successfully")
+		Info("Order accepted on the stock fast path")
+
+	return order, nil
+}
+
+// placeOrder runs the ORDER_PLACEMENT saga, placing the order again when it
+// loses a concurrent stock update, up to maxOrderAttempts times
+func placeOrder(ctx context.Context, sagas *SagaOrchestrator, placement *orderPlacement) (*models.Saga, error) {
+	bucket := metrics.ProductBucket(placement.ProductID)
+
+	for attempt := 1; ; attempt++ {
+		saga, err := sagas.Run(ctx, models.SagaTypeOrderPlacement, placement)
+		if err == nil {
+			return saga, nil
+		}
+
+		appErr, _ := errors.IsAppError(err)
+		if appErr == nil || appErr.Code != errors.ErrCodeConcurrencyConflict {
+			return saga, err
+		}
+		metrics.OrderConflicts.WithLabelValues(bucket).Inc()
+
+		// Losing a stock update to another order is transient; the failed
+		// attempt left nothing behind, so place it again
+		if attempt >= maxOrderAttempts || ctx.Err() != nil {
+			return saga, err
+		}
+		metrics.OrderRetries.WithLabelValues(bucket).Inc()
+		logger.WithContext(ctx).
+			WithField("product_id", placement.ProductID).
+			WithField("attempt", attempt).
+			Warn("Order hit a concurrency conflict, retrying")
+	}
+}
+
+// placedOrder returns the order already placed under the request's client
+// reference, or nil if there is none. A reference reused for a different
+// order is a conflict.
+func (s *orderService) placedOrder(ctx context.Context, req *models.CreateOrderRequest) (*models.Order, error) {
+	order, err := s.orderRepo.GetByClientReference(ctx, req.ClientReference)
+	if err == errors.ErrOrderNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to look up order by client reference")
+		return nil, err
+	}
+
+	if order.ProductID != req.ProductID || order.Quantity != req.Quantity || order.BuyerID != req.BuyerID {
+		return nil, errors.ErrClientReferenceConflict
+	}
+
+	logger.WithContext(ctx).WithField("order_id", order.ID).Info("Returning order already placed under client reference")
+	return order, nil
+}
+
+func (s *orderService) GetOrderByClientReference(ctx context.Context, clientReference string) (*models.Order, error) {
+	order, err := s.orderRepo.GetByClientReference(ctx, clientReference)
+	if err != nil {
+		if err != errors.ErrOrderNotFound {
+			logger.WithContext(ctx).WithError(err).Error("Failed to get order by client reference")
+		}
+		return nil, err
+	}
 
 	return order, nil
 }
@@ -152,6 +702,21 @@ func (s *orderService) GetOrder(ctx context.Context, id uuid.UUID) (*models.Orde
 	return order, nil
 }
 
+// GetOrderEvents returns an order's timeline, oldest first
+func (s *orderService) GetOrderEvents(ctx context.Context, id uuid.UUID) ([]*models.OrderEvent, error) {
+	if _, err := s.orderRepo.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+
+	events, err := s.orderRepo.ListEvents(ctx, id)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).WithField("order_id", id).Error("Failed to list order events")
+		return nil, err
+	}
+
+	return events, nil
+}
+
 func (s *orderService) ListOrders(ctx context.Context, limit, offset int) ([]*models.Order, error) {
 	if limit <= 0 || limit > 100 {
 		limit = 10 // Default limit
@@ -159,6 +724,9 @@ func (s *orderService) ListOrders(ctx context.Context, limit, offset int) ([]*mo
 	if offset < 0 {
 		offset = 0
 	}
+	if err := s.checkListOffset(offset); err != nil {
+		return nil, err
+	}
 
 	orders, err := s.orderRepo.List(ctx, limit, offset)
 	if err != nil {
@@ -173,16 +741,34 @@ func (s *orderService) ListOrders(ctx context.Context, limit, offset int) ([]*mo
 type jobService struct {
 	db           *database.DB
 	jobRepo      repository.JobRepository
+	forecastRepo repository.ForecastRepository
+	settleRepo   repository.SettlementReader
+	webhookRepo  repository.WebhookRepository
+	payoutRepo   repository.PayoutRepository
 	jobProcessor *JobProcessor
+	files        storage.Store
+	payoutCfg    *config.PayoutConfig
+
+	maxActivePerClient int
 }
 
 // NewJobService creates a new job service
 func NewJobService(deps *Dependencies) JobService {
-	return &jobService{
+	s := &jobService{
 		db:           deps.DB,
 		jobRepo:      deps.JobRepo,
+		forecastRepo: deps.ForecastRepo,
+		settleRepo:   deps.SettleRepo,
+		webhookRepo:  deps.WebhookRepo,
+		payoutRepo:   deps.PayoutRepo,
 		jobProcessor: deps.JobProcessor,
+		files:        deps.resultStore(),
+		payoutCfg:    deps.PayoutConfig,
+	}
+	if deps.JobsConfig != nil {
+		s.maxActivePerClient = deps.JobsConfig.MaxActivePerClient
 	}
+	return s
 }
 
 func (s *jobService) CreateSettlementJob(ctx context.Context, req *models.CreateSettlementJobRequest) (*models.Job, error) {
@@ -201,10 +787,38 @@ func (s *jobService) CreateSettlementJob(ctx context.Context, req *models.Create
 		return nil, errors.NewValidationError("to date must be after from date")
 	}
 
+	switch req.Split {
+	case "", models.SettlementSplitNone, models.SettlementSplitMerchant:
+	default:
+		return nil, errors.NewValidationError("invalid split, expected none or merchant")
+	}
+
+	switch req.Format {
+	case "", models.ExportFormatCSV, models.ExportFormatParquet:
+	default:
+		return nil, errors.NewValidationError("invalid format, expected csv or parquet")
+	}
+
+	// Reject early if an overlapping settlement job is already running
+	lock, err := s.jobRepo.FindOverlappingLock(ctx, models.JobTypeSettlement, from, to)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to check overlapping settlement jobs")
+		return nil, err
+	}
+	if lock != nil {
+		return nil, errors.NewJobRangeLockedError(lock.JobID.String())
+	}
+
+	if err := s.checkQuota(ctx, models.JobTypeSettlement); err != nil {
+		return nil, err
+	}
+
 	// Create job parameters
 	params := models.SettlementJobParams{
-		From: req.From,
-		To:   req.To,
+		From:   req.From,
+		To:     req.To,
+		Split:  req.Split,
+		Format: req.Format,
 	}
 
 	paramsJSON, err := json.Marshal(params)
@@ -223,29 +837,227 @@ func (s *jobService) CreateSettlementJob(ctx context.Context, req *models.Create
 		Parameters: string(paramsJSON),
 	}
 
-	if err := s.jobRepo.Create(ctx, job); err != nil {
-		logger.WithContext(ctx).WithError(err).Error("Failed to create settlement job")
-		return nil, fmt.Errorf("failed to create job: %w", err)
+	if err := s.setProgressWebhook(ctx, job, req.ProgressWebhook); err != nil {
+		return nil, err
 	}
 
-	// Queue job for processing
-	if err := s.jobProcessor.QueueJob(ctx, job); err != nil {
-		logger.WithContext(ctx).WithError(err).WithField("job_id", job.ID).Error("Failed to queue job")
-		return nil, fmt.Errorf("failed to queue job: %w", err)
+	if err := s.enqueue(ctx, job); err != nil {
+		return nil, err
 	}
 
-	// Record metrics
-	metrics.JobsCreated.WithLabelValues(string(job.Type)).Inc()
-
 	logger.WithContext(ctx).
 		WithField("job_id", job.ID).
 		WithField("from", req.From).
 		WithField("to", req.To).
+		WithField("split", req.Split).
+		WithField("format", req.Format).
 		Info("Settlement job created and queued")
 
 	return job, nil
 }
 
+func (s *jobService) CreateReorderForecastJob(ctx context.Context, req *models.CreateReorderForecastJobRequest) (*models.Job, error) {
+	switch req.Model {
+	case "", models.ForecastModelSMA, models.ForecastModelEMA:
+	default:
+		return nil, errors.NewValidationError("invalid model, expected SMA or EMA")
+	}
+
+	// Create job parameters
+	params := models.ReorderForecastJobParams{
+		Model:        req.Model,
+		LookbackDays: req.LookbackDays,
+		LeadTimeDays: req.LeadTimeDays,
+		SafetyDays:   req.SafetyDays,
+		ReviewDays:   req.ReviewDays,
+		Alpha:        req.Alpha,
+	}
+	applyForecastDefaults(&params)
+
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job parameters: %w", err)
+	}
+
+	// Create job
+	job := &models.Job{
+		ID:         uuid.New(),
+		Type:       models.JobTypeReorderForecast,
+		Status:     models.JobStatusQueued,
+		Parameters: string(paramsJSON),
+	}
+
+	if err := s.setProgressWebhook(ctx, job, req.ProgressWebhook); err != nil {
+		return nil, err
+	}
+
+	if err := s.enqueue(ctx, job); err != nil {
+		return nil, err
+	}
+
+	logger.WithContext(ctx).
+		WithField("job_id", job.ID).
+		WithField("model", params.Model).
+		WithField("lookback_days", params.LookbackDays).
+		Info("Reorder forecast job created and queued")
+
+	return job, nil
+}
+
+func (s *jobService) CreateOrdersExportJob(ctx context.Context, req *models.CreateOrdersExportJobRequest) (*models.Job, error) {
+	switch req.Status {
+	case "", models.OrderStatusPending, models.OrderStatusConfirmed, models.OrderStatusCancelled, models.OrderStatusBackordered:
+	default:
+		return nil, errors.NewValidationError("invalid status, expected PENDING, CONFIRMED, CANCELLED or BACKORDERED")
+	}
+
+	switch req.Format {
+	case "", models.ExportFormatCSV, models.ExportFormatJSON, models.ExportFormatParquet:
+	default:
+		return nil, errors.NewValidationError("invalid format, expected csv, json or parquet")
+	}
+
+	// Create job parameters; parsing validates the dates
+	params := models.OrdersExportJobParams{
+		Status:  req.Status,
+		BuyerID: req.BuyerID,
+		From:    req.From,
+		To:      req.To,
+		Format:  req.Format,
+	}
+	if params.Format == "" {
+		params.Format = models.ExportFormatCSV
+	}
+	if _, err := ordersExportFilter(&params); err != nil {
+		return nil, err
+	}
+
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job parameters: %w", err)
+	}
+
+	// Create job
+	job := &models.Job{
+		ID:         uuid.New(),
+		Type:       models.JobTypeOrdersExport,
+		Status:     models.JobStatusQueued,
+		Parameters: string(paramsJSON),
+	}
+
+	if err := s.setProgressWebhook(ctx, job, req.ProgressWebhook); err != nil {
+		return nil, err
+	}
+
+	if err := s.enqueue(ctx, job); err != nil {
+		return nil, err
+	}
+
+	logger.WithContext(ctx).
+		WithField("job_id", job.ID).
+		WithField("status", params.Status).
+		WithField("format", params.Format).
+		Info("Orders export job created and queued")
+
+	return job, nil
+}
+
+// checkQuota rejects a job when the calling client already has the maximum
+// number of queued or running jobs of that type. Requests without a
+// principal, such as the scheduler's, are not limited.
+func (s *jobService) checkQuota(ctx context.Context, jobType models.JobType) error {
+	principal, _ := ctx.Value(logger.PrincipalKey).(string)
+	if s.maxActivePerClient <= 0 || principal == "" {
+		return nil
+	}
+
+	active, err := s.jobRepo.CountActiveByPrincipal(ctx, principal, jobType)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to count active jobs")
+		return err
+	}
+	if active >= s.maxActivePerClient {
+		logger.WithContext(ctx).
+			WithField("job_type", jobType).
+			WithField("active_jobs", active).
+			Warn("Job quota exceeded")
+		return errors.NewQuotaExceededError(s.maxActivePerClient)
+	}
+
+	return nil
+}
+
+// setProgressWebhook points a new job's progress callbacks at a registered
+// webhook endpoint, at the requested milestones or the default ones
+func (s *jobService) setProgressWebhook(ctx context.Context, job *models.Job, req *models.ProgressWebhook) error {
+	if req == nil {
+		return nil
+	}
+
+	if _, err := s.webhookRepo.GetByID(ctx, req.WebhookID); err != nil {
+		if err == errors.ErrWebhookNotFound {
+			return errors.NewValidationError("progress_webhook.webhook_id is not a registered webhook endpoint")
+		}
+		logger.WithContext(ctx).WithError(err).Error("Failed to get progress webhook endpoint")
+		return err
+	}
+
+	milestones := defaultProgressMilestones
+	if len(req.Milestones) > 0 {
+		requested := append([]int(nil), req.Milestones...)
+		sort.Ints(requested)
+
+		milestones = []int{}
+		for _, milestone := range requested {
+			if milestone < 1 || milestone > 100 {
+				return errors.NewValidationError("progress_webhook.milestones must be percentages from 1 to 100")
+			}
+			if len(milestones) == 0 || milestones[len(milestones)-1] != milestone {
+				milestones = append(milestones, milestone)
+			}
+		}
+	}
+
+	webhookID := req.WebhookID
+	job.ProgressWebhookID = &webhookID
+	job.ProgressMilestones = milestones
+	return nil
+}
+
+// enqueue persists a new job and queues it for processing
+func (s *jobService) enqueue(ctx context.Context, job *models.Job) error {
+	if principal, ok := ctx.Value(logger.PrincipalKey).(string); ok && principal != "" {
+		job.CreatedBy = &principal
+	}
+
+	if err := s.jobRepo.Create(ctx, job); err != nil {
+		logger.WithContext(ctx).WithError(err).WithField("job_type", job.Type).Error("Failed to create job")
+		return fmt.Errorf("failed to create job: %w", err)
+	}
+
+	// Queue job for processing
+	if err := s.jobProcessor.QueueJob(ctx, job); err != nil {
+		logger.WithContext(ctx).WithError(err).WithField("job_id", job.ID).Error("Failed to queue job")
+
+		// The row would otherwise sit in QUEUED with no worker to pick it up
+		if markErr := s.jobRepo.UpdateStatus(ctx, job.ID, models.JobStatusFailed); markErr != nil {
+			logger.WithContext(ctx).WithError(markErr).WithField("job_id", job.ID).Error("Failed to mark unqueued job as failed")
+		} else if markErr := s.jobRepo.UpdateError(ctx, job.ID, err.Error()); markErr != nil {
+			logger.WithContext(ctx).WithError(markErr).WithField("job_id", job.ID).Error("Failed to record job queue error")
+		}
+
+		if appErr, ok := errors.IsAppError(err); ok {
+			return appErr
+		}
+		return fmt.Errorf("failed to queue job: %w", err)
+	}
+
+	// Record metrics
+	metrics.JobsCreated.WithLabelValues(string(job.Type)).Inc()
+
+	return nil
+}
+
 func (s *jobService) GetJob(ctx context.Context, id uuid.UUID) (*models.Job, error) {
 	job, err := s.jobRepo.GetByID(ctx, id)
 	if err != nil {
@@ -253,66 +1065,329 @@ func (s *jobService) GetJob(ctx context.Context, id uuid.UUID) (*models.Job, err
 		return nil, err
 	}
 
+	// Merge in-memory state over the persisted row for fresher progress
+	if job.Status == models.JobStatusRunning || job.Status == models.JobStatusCancelling {
+		if live, ok := s.jobProcessor.LiveState(id); ok {
+			job.Progress = live.Progress
+			job.Processed = live.Processed
+			job.Total = live.Total
+			job.Live = live
+		}
+	}
+
+	// Jobs with several output files list them for individual download
+	if job.Status == models.JobStatusCompleted {
+		files, err := s.jobFiles(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		job.Files = files
+	}
+
 	return job, nil
 }
 
-func (s *jobService) CancelJob(ctx context.Context, id uuid.UUID) error {
+// GetJobs returns the jobs with the given IDs, skipping any that don't
+// exist, with the live progress of those running; unlike GetJob it doesn't
+// list output files
+func (s *jobService) GetJobs(ctx context.Context, ids []uuid.UUID) ([]*models.Job, error) {
+	jobs, err := s.jobRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to get jobs")
+		return nil, err
+	}
+
+	for _, job := range jobs {
+		if job.Status != models.JobStatusRunning && job.Status != models.JobStatusCancelling {
+			continue
+		}
+		if live, ok := s.jobProcessor.LiveState(job.ID); ok {
+			job.Progress = live.Progress
+			job.Processed = live.Processed
+			job.Total = live.Total
+			job.Live = live
+		}
+	}
+
+	return jobs, nil
+}
+
+func (s *jobService) GetReorderRecommendations(ctx context.Context, id uuid.UUID) ([]*models.ReorderRecommendation, error) {
+	job, err := s.GetJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Type != models.JobTypeReorderForecast {
+		return nil, errors.NewValidationError("job is not a reorder forecast job")
+	}
+
+	recommendations, err := s.forecastRepo.ListRecommendationsByJob(ctx, id)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).WithField("job_id", id).Error("Failed to list reorder recommendations")
+		return nil, err
+	}
+
+	return recommendations, nil
+}
+
+// GetJobStats returns the execution stats saved when a job finished
+func (s *jobService) GetJobStats(ctx context.Context, id uuid.UUID) (*models.JobStats, error) {
+	if _, err := s.jobRepo.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+
+	stats, err := s.jobRepo.GetStats(ctx, id)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).WithField("job_id", id).Error("Failed to get job stats")
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// GetJobLogs returns a job's stored log lines after the line with ID after,
+// at level or more severe; an empty level returns every line
+func (s *jobService) GetJobLogs(ctx context.Context, id uuid.UUID, level string, after int64, limit int) (*models.JobLogs, error) {
+	if limit <= 0 || limit > 1000 {
+		limit = 100 // Default limit
+	}
+	if after < 0 {
+		after = 0
+	}
+
+	levels, err := jobLogLevels(level)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.jobRepo.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+
+	entries, err := s.jobRepo.ListLogs(ctx, id, levels, after, limit)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).WithField("job_id", id).Error("Failed to list job logs")
+		return nil, err
+	}
+
+	logs := &models.JobLogs{Logs: entries, NextAfter: after}
+	if len(entries) > 0 {
+		logs.NextAfter = entries[len(entries)-1].ID
+	}
+	return logs, nil
+}
+
+// CancelJob requests cancellation and returns the job's new status: CANCELLED
+// for a queued job, or CANCELLING for a running one until its worker stops
+func (s *jobService) CancelJob(ctx context.Context, id uuid.UUID) (models.JobStatus, error) {
 	// Mark job as cancelled in database
-	err := s.jobRepo.Cancel(ctx, id)
+	status, err := s.jobRepo.Cancel(ctx, id)
 	if err != nil {
 		logger.WithContext(ctx).WithError(err).WithField("job_id", id).Error("Failed to cancel job")
-		return err
+		return "", err
 	}
 
 	// Cancel the job context if it's currently running
 	s.jobProcessor.CancelJob(id)
 
-	logger.WithContext(ctx).WithField("job_id", id).Info("Job cancelled")
-	return nil
+	logger.WithContext(ctx).WithField("job_id", id).WithField("status", status).Info("Job cancellation requested")
+	return status, nil
 }
 
-// healthService implements HealthService
-type healthService struct {
-	db *database.DB
-}
+// ListJobs lists jobs newest first, optionally filtered by status and type
+func (s *jobService) ListJobs(ctx context.Context, filter *models.JobFilter, limit, offset int) ([]*models.Job, error) {
+	switch filter.Status {
+	case "", models.JobStatusQueued, models.JobStatusRunning, models.JobStatusCompleted, models.JobStatusFailed,
+		models.JobStatusCancelled, models.JobStatusCancelling, models.JobStatusDeadLettered:
+	default:
+		return nil, errors.NewValidationError("invalid status, expected QUEUED, RUNNING, COMPLETED, FAILED, CANCELLING, CANCELLED or DEAD_LETTERED")
+	}
+	switch filter.Type {
+	case "", models.JobTypeSettlement, models.JobTypeReorderForecast, models.JobTypeOrdersExport, models.JobTypeResettle,
+		models.JobTypeMerchantStatement, models.JobTypeBackfill, models.JobTypeBuyerErasure, models.JobTypeBackorderFulfillment:
+	default:
+		return nil, errors.NewValidationError("invalid type, expected SETTLEMENT, REORDER_FORECAST, ORDERS_EXPORT, RESETTLE, MERCHANT_STATEMENT, BACKFILL, BUYER_ERASURE or BACKORDER_FULFILLMENT")
+	}
+
+	if limit <= 0 || limit > 100 {
+		limit = 10 // Default limit
+	}
+	if offset < 0 {
+		offset = 0
+	}
 
-// NewHealthService creates a new health service
-func NewHealthService(deps *Dependencies) HealthService {
-	return &healthService{
-		db: deps.DB,
+	jobs, err := s.jobRepo.List(ctx, filter, limit, offset)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to list jobs")
+		return nil, err
 	}
+
+	return jobs, nil
 }
 
-var startTime = time.Now()
+func (s *jobService) ListDeadLetteredJobs(ctx context.Context, limit, offset int) ([]*models.Job, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 10 // Default limit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	jobs, err := s.jobRepo.ListDeadLettered(ctx, limit, offset)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to list dead-lettered jobs")
+		return nil, err
+	}
 
-func (s *healthService) Check(ctx context.Context) (*models.HealthCheck, error) {
-	checks := make(map[string]string)
+	return jobs, nil
+}
 
-	// Check database
-	if err := s.db.Health(ctx); err != nil {
-		checks["database"] = "unhealthy: " + err.Error()
-	} else {
-		checks["database"] = "healthy"
+func (s *jobService) RedriveJob(ctx context.Context, id uuid.UUID) (*models.Job, error) {
+	job, err := s.jobRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != models.JobStatusDeadLettered {
+		return nil, errors.ErrJobNotDeadLettered
 	}
 
-	// Determine overall status
-	status := "healthy"
-	for _, check := range checks {
-		if check != "healthy" && check[:7] != "healthy" {
-			status = "unhealthy"
-			break
+	// Reset the job for a fresh set of attempts
+	if err := s.jobRepo.Redrive(ctx, id); err != nil {
+		logger.WithContext(ctx).WithError(err).WithField("job_id", id).Error("Failed to redrive job")
+		return nil, err
+	}
+
+	if err := s.jobProcessor.QueueJob(ctx, job); err != nil {
+		logger.WithContext(ctx).WithError(err).WithField("job_id", id).Error("Failed to queue redriven job")
+
+		// Park it again so it can be re-driven once the queue has room
+		if markErr := s.jobRepo.MarkDeadLettered(ctx, id, err.Error()); markErr != nil {
+			logger.WithContext(ctx).WithError(markErr).WithField("job_id", id).Error("Failed to dead-letter unqueued job")
 		}
+
+		if appErr, ok := errors.IsAppError(err); ok {
+			return nil, appErr
+		}
+		return nil, fmt.Errorf("failed to queue job: %w", err)
+	}
+
+	metrics.JobsRedriven.WithLabelValues(string(job.Type)).Inc()
+	s.jobProcessor.refreshDeadLetterGauge(ctx)
+
+	job.Status = models.JobStatusQueued
+	job.Attempts = 0
+	job.Error = nil
+	job.DeadLetteredAt = nil
+
+	logger.WithContext(ctx).WithField("job_id", id).Info("Dead-lettered job re-driven")
+	return job, nil
+}
+
+// settlementService implements SettlementService
+type settlementService struct {
+	uow          repository.UnitOfWork
+	txRepo       repository.TransactionReader
+	settleRepo   repository.SettlementRepository
+	jobProcessor *JobProcessor
+}
+
+// NewSettlementService creates a new settlement service
+func NewSettlementService(deps *Dependencies) SettlementService {
+	return &settlementService{
+		uow:          deps.unitOfWork(),
+		txRepo:       deps.TxRepo,
+		settleRepo:   deps.SettleRepo,
+		jobProcessor: deps.JobProcessor,
+	}
+}
+
+func (s *settlementService) ListRuns(ctx context.Context, limit, offset int) ([]*models.SettlementRun, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 10 // Default limit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	runs, err := s.settleRepo.ListRuns(ctx, limit, offset)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to list settlement runs")
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+func (s *settlementService) GetRun(ctx context.Context, id uuid.UUID) (*models.SettlementRun, error) {
+	run, err := s.settleRepo.GetRun(ctx, id)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).WithField("run_id", id).Error("Failed to get settlement run")
+		return nil, err
+	}
+
+	return s.withSettlements(ctx, run)
+}
+
+func (s *settlementService) GetLatestRun(ctx context.Context) (*models.SettlementRun, error) {
+	run, err := s.settleRepo.GetLatestRun(ctx)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to get latest settlement run")
+		return nil, err
+	}
+
+	return s.withSettlements(ctx, run)
+}
+
+func (s *settlementService) ListRunSettlements(ctx context.Context, runID uuid.UUID) ([]*models.Settlement, error) {
+	settlements, err := s.settleRepo.ListByRun(ctx, runID)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).WithField("run_id", runID).Error("Failed to list run settlements")
+		return nil, err
 	}
 
-	uptime := time.Since(startTime).String()
+	return settlements, nil
+}
+
+// ListRunsSettlements lists the settlements of several runs with one query,
+// by run
+func (s *settlementService) ListRunsSettlements(ctx context.Context, runIDs []uuid.UUID) ([]*models.Settlement, error) {
+	settlements, err := s.settleRepo.ListByRuns(ctx, runIDs)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to list run settlements")
+		return nil, err
+	}
+
+	return settlements, nil
+}
+
+// ListStale lists the current settlements flagged as needing re-settlement,
+// earliest settlement date first
+func (s *settlementService) ListStale(ctx context.Context, limit, offset int) ([]*models.Settlement, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 10 // Default limit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	settlements, err := s.settleRepo.ListStale(ctx, limit, offset)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to list stale settlements")
+		return nil, err
+	}
+
+	return settlements, nil
+}
+
+// withSettlements attaches the settlement rows reported by a run
+func (s *settlementService) withSettlements(ctx context.Context, run *models.SettlementRun) (*models.SettlementRun, error) {
+	settlements, err := s.ListRunSettlements(ctx, run.ID)
+	if err != nil {
+		return nil, err
+	}
 
-	return &models.HealthCheck{
-		Status:    status,
-		Version:   "1.0.0",
-		Checks:    checks,
-		Uptime:    uptime,
-		Timestamp: time.Now(),
-	}, nil
+	run.Settlements = settlements
+	return run, nil
 }
 
 // // Utility function to create settlement CSV
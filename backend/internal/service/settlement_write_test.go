@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"indico-backend/internal/database"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// settlementWrites is a settlement writer failing the batch when batchErr is
+// set and the merchants in failing one at a time. Merchants in previous
+// have an earlier settlement to mark stale.
+type settlementWrites struct {
+	repository.SettlementTx
+
+	batchErr error
+	failing  map[string]error
+	previous map[string]bool
+
+	created []string
+	stale   []string
+}
+
+func (w *settlementWrites) CreateBatch(ctx context.Context, settlements []*models.Settlement) error {
+	if w.batchErr != nil {
+		return w.batchErr
+	}
+	for _, settlement := range settlements {
+		w.created = append(w.created, settlement.MerchantID)
+	}
+	return nil
+}
+
+func (w *settlementWrites) Create(ctx context.Context, settlement *models.Settlement) error {
+	if err := w.failing[settlement.MerchantID]; err != nil {
+		return err
+	}
+	w.created = append(w.created, settlement.MerchantID)
+	return nil
+}
+
+func (w *settlementWrites) MarkStale(ctx context.Context, merchantID string, date time.Time, reason string) (*models.Settlement, error) {
+	if !w.previous[merchantID] {
+		return nil, nil
+	}
+	w.stale = append(w.stale, merchantID)
+	return &models.Settlement{MerchantID: merchantID, Date: date}, nil
+}
+
+// createDaySettlements creates a settlement for each merchant on one day
+// through writes, as a run's save does
+func createDaySettlements(writes *settlementWrites, merchants ...string) (*models.SettlementRun, []*models.Settlement, error) {
+	date := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	var settlements []*models.Settlement
+	for _, merchant := range merchants {
+		settlements = append(settlements, &models.Settlement{MerchantID: merchant, Date: date, TxnCount: 1})
+	}
+
+	run := &models.SettlementRun{ID: uuid.New()}
+	jp := &JobProcessor{}
+	skipped, err := jp.createSettlements(context.Background(), &repository.Tx{Settlements: writes}, run, settlements)
+	return run, skipped, err
+}
+
+func TestCreateSettlementsBatch(t *testing.T) {
+	writes := &settlementWrites{}
+	run, skipped, err := createDaySettlements(writes, "m1", "m2")
+	require.NoError(t, err)
+
+	assert.Empty(t, skipped)
+	assert.Empty(t, run.Skipped)
+	assert.Equal(t, []string{"m1", "m2"}, writes.created)
+}
+
+func TestCreateSettlementsFallsBackToRows(t *testing.T) {
+	writes := &settlementWrites{
+		batchErr: errors.New("value too long"),
+		failing:  map[string]error{"m2": errors.New("value too long")},
+		previous: map[string]bool{"m2": true},
+	}
+	run, skipped, err := createDaySettlements(writes, "m1", "m2", "m3")
+	require.NoError(t, err)
+
+	// The rows around the failing one are still written
+	assert.Equal(t, []string{"m1", "m3"}, writes.created)
+
+	// The failing day is skipped, recorded on the run, and its previous
+	// settlement marked stale
+	require.Len(t, skipped, 1)
+	assert.Equal(t, "m2", skipped[0].MerchantID)
+	require.Len(t, run.Skipped, 1)
+	assert.Equal(t, "m2", run.Skipped[0].MerchantID)
+	assert.Equal(t, "2025-01-02", run.Skipped[0].Date.Format("2006-01-02"))
+	assert.Equal(t, "value too long", run.Skipped[0].Error)
+	assert.Equal(t, []string{"m2"}, writes.stale)
+}
+
+func TestCreateSettlementsFailsWithoutPreviousSettlement(t *testing.T) {
+	writes := &settlementWrites{
+		batchErr: errors.New("value too long"),
+		failing:  map[string]error{"m2": errors.New("value too long")},
+	}
+	_, _, err := createDaySettlements(writes, "m1", "m2")
+
+	// Skipping the day would drop it, so the run fails instead
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "merchant m2 on 2025-01-02")
+	assert.Contains(t, err.Error(), "value too long")
+}
+
+func TestCreateSettlementsAborts(t *testing.T) {
+	aborted := fmt.Errorf("%w: failed to roll back to savepoint", database.ErrTxAborted)
+	serialization := &pq.Error{Code: "40001"}
+
+	for name, writes := range map[string]*settlementWrites{
+		"batch aborted":       {batchErr: aborted},
+		"batch serialization": {batchErr: serialization},
+		"row aborted": {
+			batchErr: errors.New("value too long"),
+			failing:  map[string]error{"m1": aborted},
+			previous: map[string]bool{"m1": true},
+		},
+		"row serialization": {
+			batchErr: errors.New("value too long"),
+			failing:  map[string]error{"m1": serialization},
+			previous: map[string]bool{"m1": true},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			run, skipped, err := createDaySettlements(writes, "m1", "m2")
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, database.ErrTxAborted) || database.IsSerializationFailure(err))
+
+			// Nothing is skipped or marked stale in a transaction that is gone
+			assert.Empty(t, skipped)
+			assert.Empty(t, run.Skipped)
+			assert.Empty(t, writes.stale)
+			assert.NotContains(t, writes.created, "m2")
+		})
+	}
+}
@@ -0,0 +1,583 @@
+// Package service provides settlement staleness detection and re-settlement
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"indico-backend/internal/config"
+	"indico-backend/internal/database"
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// maxResettleDays caps the merchant-days a single re-settlement job covers;
+// anything left over stays flagged for the next job
+const maxResettleDays = 500
+
+// lateTransactionReason is recorded on settlements flagged by a staleness scan
+const lateTransactionReason = "transaction recorded after settlement was generated"
+
+// DetectStale flags current settlements that a completed transaction was
+// recorded for after the settlement was generated, e.g. a late-arriving
+// payment whose paid_at falls inside an already-settled day. Settlements
+// generated after their cutoff are never flagged. Dates are
+// inclusive; without them the scan covers the configured lookback window up
+// to today. Merchant-days that were never settled are not flagged.
+func (s *settlementService) DetectStale(ctx context.Context, req *models.DetectStaleSettlementsRequest) (*models.StaleDetection, error) {
+	from, to, err := s.detectionRange(req.From, req.To)
+	if err != nil {
+		return nil, err
+	}
+	end := to.AddDate(0, 0, 1)
+
+	book, err := s.jobProcessor.calendarBook(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.settleRepo.ListCurrent(ctx, from, end)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to list current settlements")
+		return nil, err
+	}
+
+	settled := make(map[string]*models.Settlement, len(current))
+	for _, settlement := range current {
+		settled[merchantDayKey(settlement.MerchantID, settlement.Date)] = settlement
+	}
+
+	// Weekend and holiday activity settles on a later day, so read from the
+	// earliest payment day that can roll into the range
+	activity, err := s.txRepo.LatestActivityDaily(ctx, book.FirstContributingDay(from), end)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to get transaction activity")
+		return nil, err
+	}
+
+	stale := make(map[string]*models.Settlement)
+	for _, day := range activity {
+		key := merchantDayKey(day.MerchantID, book.SettlementDate(day.MerchantID, day.Date))
+		settlement, ok := settled[key]
+		if !ok || settlement.StaleSince != nil {
+			continue
+		}
+		// A settlement generated after its cutoff is final: anything recorded
+		// since is late and reported, not re-settled
+		if settlement.CutoffAt != nil && settlement.GeneratedAt.After(*settlement.CutoffAt) {
+			continue
+		}
+		if day.LastCreatedAt.After(settlement.GeneratedAt) {
+			stale[key] = settlement
+		}
+	}
+
+	result := &models.StaleDetection{
+		From:    from.Format("2006-01-02"),
+		To:      to.Format("2006-01-02"),
+		Checked: len(current),
+		Flagged: []*models.Settlement{},
+	}
+
+	if len(stale) > 0 {
+		err = s.uow.Do(ctx, nil, func(tx *repository.Tx) error {
+			for _, settlement := range stale {
+				flagged, err := tx.Settlements.MarkStale(ctx, settlement.MerchantID, settlement.Date, lateTransactionReason)
+				if err != nil {
+					return err
+				}
+				if flagged != nil {
+					result.Flagged = append(result.Flagged, flagged)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			logger.WithContext(ctx).WithError(err).Error("Failed to flag stale settlements")
+			return nil, err
+		}
+	}
+
+	sortSettlements(result.Flagged)
+
+	logger.WithContext(ctx).
+		WithField("from", result.From).
+		WithField("to", result.To).
+		WithField("checked", result.Checked).
+		WithField("flagged", len(result.Flagged)).
+		Info("Settlement staleness scan completed")
+
+	return result, nil
+}
+
+// LateTransactions lists the completed transactions left out of a settlement
+// because they were recorded after the cutoff of the day they settle on.
+// Each is checked against the cutoff recorded on the day's current
+// settlement, or the configured cutoff if the day has none. Dates are
+// inclusive settlement dates defaulting to the same window as DetectStale;
+// without a configured cutoff nothing is late.
+func (s *settlementService) LateTransactions(ctx context.Context, fromDate, toDate string) (*models.LateTransactionReport, error) {
+	from, to, err := s.detectionRange(fromDate, toDate)
+	if err != nil {
+		return nil, err
+	}
+	end := to.AddDate(0, 0, 1)
+
+	report := &models.LateTransactionReport{
+		From:         from.Format("2006-01-02"),
+		To:           to.Format("2006-01-02"),
+		Transactions: []*models.LateTransaction{},
+	}
+
+	cutoff := s.jobProcessor.settleCfg.Cutoff
+	if cutoff <= 0 {
+		return report, nil
+	}
+
+	book, err := s.jobProcessor.calendarBook(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.settleRepo.ListCurrent(ctx, from, end)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to list current settlements")
+		return nil, err
+	}
+
+	settled := make(map[string]*models.Settlement, len(current))
+	for _, settlement := range current {
+		settled[merchantDayKey(settlement.MerchantID, settlement.Date)] = settlement
+	}
+
+	// A transaction settles no earlier than its payment day, so one recorded
+	// within the cutoff of its payment day is never late
+	candidates, err := s.txRepo.ListRecordedAfterPaidDay(ctx, book.FirstContributingDay(from), end, cutoff)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to list late transactions")
+		return nil, err
+	}
+
+	for _, txn := range candidates {
+		date := book.SettlementDate(txn.MerchantID, txn.PaidAt)
+		if date.Before(from) || !date.Before(end) {
+			continue
+		}
+
+		cutoffAt := *settlementCutoff(date, cutoff)
+		if settlement, ok := settled[merchantDayKey(txn.MerchantID, date)]; ok {
+			if settlement.CutoffAt == nil {
+				// Settled without a cutoff, so it includes whatever was
+				// recorded before it was generated
+				continue
+			}
+			cutoffAt = *settlement.CutoffAt
+		}
+
+		if txn.CreatedAt.After(cutoffAt) {
+			report.Transactions = append(report.Transactions, &models.LateTransaction{
+				Transaction:    txn,
+				SettlementDate: date,
+				CutoffAt:       cutoffAt,
+			})
+		}
+	}
+
+	return report, nil
+}
+
+// detectionRange resolves the inclusive settlement dates a staleness scan covers
+func (s *settlementService) detectionRange(fromDate, toDate string) (time.Time, time.Time, error) {
+	now := time.Now().UTC()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if toDate != "" {
+		parsed, err := time.Parse("2006-01-02", toDate)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.NewValidationError("invalid to date format, expected YYYY-MM-DD")
+		}
+		to = parsed
+	}
+
+	from := to.AddDate(0, 0, -s.jobProcessor.settleCfg.StaleLookbackDays)
+	if fromDate != "" {
+		parsed, err := time.Parse("2006-01-02", fromDate)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.NewValidationError("invalid from date format, expected YYYY-MM-DD")
+		}
+		from = parsed
+	}
+
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, errors.NewValidationError("to date must be after from date")
+	}
+
+	return from, to, nil
+}
+
+// CreateResettleJob queues a job that re-settles the merchant-days currently
+// flagged as stale, oldest first
+func (s *jobService) CreateResettleJob(ctx context.Context) (*models.Job, error) {
+	stale, err := s.settleRepo.ListStale(ctx, maxResettleDays, 0)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to list stale settlements")
+		return nil, err
+	}
+	if len(stale) == 0 {
+		return nil, errors.ErrNoStaleSettlements
+	}
+
+	params := models.ResettleJobParams{
+		MerchantDays: make([]models.MerchantDay, 0, len(stale)),
+	}
+	from, to := stale[0].Date, stale[0].Date
+	for _, settlement := range stale {
+		params.MerchantDays = append(params.MerchantDays, models.MerchantDay{
+			MerchantID: settlement.MerchantID,
+			Date:       settlement.Date.Format("2006-01-02"),
+		})
+		if settlement.Date.Before(from) {
+			from = settlement.Date
+		}
+		if settlement.Date.After(to) {
+			to = settlement.Date
+		}
+	}
+
+	// Re-settlement rewrites the same rows as a settlement job, so it is
+	// rejected early while one is running over the affected dates
+	lock, err := s.jobRepo.FindOverlappingLock(ctx, models.JobTypeSettlement, from, to)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to check overlapping settlement jobs")
+		return nil, err
+	}
+	if lock != nil {
+		return nil, errors.NewJobRangeLockedError(lock.JobID.String())
+	}
+
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job parameters: %w", err)
+	}
+
+	job := &models.Job{
+		ID:         uuid.New(),
+		Type:       models.JobTypeResettle,
+		Status:     models.JobStatusQueued,
+		Progress:   0,
+		Processed:  0,
+		Total:      len(params.MerchantDays),
+		Parameters: string(paramsJSON),
+	}
+
+	if err := s.enqueue(ctx, job); err != nil {
+		return nil, err
+	}
+
+	logger.WithContext(ctx).
+		WithField("job_id", job.ID).
+		WithField("merchant_days", len(params.MerchantDays)).
+		Info("Re-settlement job created and queued")
+
+	return job, nil
+}
+
+// processResettleJob recomputes the settlements for the merchant-days in the
+// job parameters and saves them as a new settlement run. Days with nothing
+// left to settle are superseded without a replacement.
+func (jp *JobProcessor) processResettleJob(ctx context.Context, job *models.Job) error {
+	log := logger.WithJobID(job.ID.String())
+
+	var params models.ResettleJobParams
+	if err := json.Unmarshal([]byte(job.Parameters), &params); err != nil {
+		return fmt.Errorf("failed to parse job parameters: %w", err)
+	}
+	if len(params.MerchantDays) == 0 {
+		return errors.NewValidationError("no merchant-days to re-settle")
+	}
+
+	type merchantDay struct {
+		merchantID string
+		date       time.Time
+	}
+	days := make([]merchantDay, 0, len(params.MerchantDays))
+	for _, md := range params.MerchantDays {
+		date, err := time.Parse("2006-01-02", md.Date)
+		if err != nil {
+			return errors.NewValidationError("invalid merchant-day date format, expected YYYY-MM-DD")
+		}
+		days = append(days, merchantDay{merchantID: md.MerchantID, date: date})
+	}
+
+	from, to := days[0].date, days[0].date
+	for _, day := range days {
+		if day.date.Before(from) {
+			from = day.date
+		}
+		if day.date.After(to) {
+			to = day.date
+		}
+	}
+
+	// Hold the same lock a settlement job would so the two never interleave
+	lock := &models.JobLock{
+		JobID:     job.ID,
+		JobType:   models.JobTypeSettlement,
+		RangeFrom: from,
+		RangeTo:   to,
+	}
+	if err := jp.uow.Do(ctx, nil, func(tx *repository.Tx) error {
+		return tx.Jobs.AcquireLock(ctx, lock)
+	}); err != nil {
+		return err
+	}
+	defer func() {
+		// Use a fresh context so the lock is released even if the job was cancelled
+		if err := jp.jobRepo.ReleaseLock(context.Background(), job.ID); err != nil {
+			log.WithError(err).Error("Failed to release job lock")
+		}
+	}()
+
+	book, err := jp.calendarBook(ctx)
+	if err != nil {
+		return err
+	}
+
+	log.WithField("merchant_days", len(days)).
+		WithField("from", from).
+		WithField("to", to).
+		Info("Processing re-settlement job")
+
+	live := jp.liveState(job.ID)
+	live.setTotal(len(days))
+
+	settlements := make(map[string]*models.Settlement)
+	var emptyDays []merchantDay
+
+	for i, day := range days {
+		select {
+		case <-ctx.Done():
+			log.Info("Job processing cancelled")
+			return ctx.Err()
+		default:
+		}
+
+		cancelled, err := jp.jobRepo.IsCancelled(ctx, job.ID)
+		if err != nil {
+			log.WithError(err).Error("Failed to check job cancellation status")
+		} else if cancelled {
+			log.Info("Job was cancelled via API")
+			return errJobCancelled
+		}
+
+		// Stamp the settlement before reading so anything recorded while it is
+		// computed is caught by the next staleness scan
+		generatedAt := time.Now()
+		cutoffAt := settlementCutoff(day.date, jp.settleCfg.Cutoff)
+		fetchFrom := book.ForMerchant(day.merchantID).FirstContributingDay(day.date)
+		daily, err := jp.txRepo.AggregateByMerchantAndDay(ctx, day.merchantID, fetchFrom, day.date.AddDate(0, 0, 1), cutoffAt)
+		if err != nil {
+			return fmt.Errorf("failed to aggregate transactions: %w", err)
+		}
+
+		settlement := &models.Settlement{
+			MerchantID:  day.merchantID,
+			Date:        day.date,
+			GeneratedAt: generatedAt,
+			CutoffAt:    cutoffAt,
+		}
+		for _, agg := range daily {
+			if !book.SettlementDate(day.merchantID, agg.Date).Equal(day.date) {
+				continue
+			}
+			settlement.GrossCents += agg.GrossCents
+			settlement.FeeCents += agg.FeeCents
+			settlement.NetCents += agg.NetCents
+			settlement.TxnCount += agg.TxnCount
+		}
+
+		if settlement.TxnCount == 0 {
+			emptyDays = append(emptyDays, day)
+		} else {
+			settlements[merchantDayKey(day.merchantID, day.date)] = settlement
+		}
+
+		live.recordBatch(1)
+		progress := float64(i+1) / float64(len(days)) * 100
+		if err := jp.updateProgress(ctx, job, progress, i+1); err != nil {
+			log.WithError(err).Error("Failed to update job progress")
+		}
+	}
+
+	jobID := job.ID
+	run := &models.SettlementRun{
+		ID:    job.ID,
+		JobID: &jobID,
+		From:  from,
+		To:    to,
+	}
+	// Repeatable read for the same reason as saveSettlements
+	err = jp.uow.Do(ctx, database.RepeatableRead, func(tx *repository.Tx) error {
+		sorted := sortedSettlements(settlements)
+		for _, settlement := range sorted {
+			settlement.UniqueRunID = run.ID
+		}
+		skipped, err := jp.createSettlements(ctx, tx, run, sorted)
+		if err != nil {
+			return err
+		}
+
+		// Skipped days keep their previous settlement and drop out of the run
+		for _, settlement := range skipped {
+			delete(settlements, merchantDayKey(settlement.MerchantID, settlement.Date))
+		}
+		run.SettlementCount = len(settlements)
+		if err := tx.Settlements.CreateRun(ctx, run); err != nil {
+			return err
+		}
+
+		for _, day := range emptyDays {
+			if err := tx.Settlements.Supersede(ctx, run.ID, day.merchantID, day.date); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save settlements: %w", err)
+	}
+
+	csvPath, downloadURL, err := jp.resultLocation(job.ID, "csv")
+	if err != nil {
+		return err
+	}
+	if err := jp.createSettlementFile(settlements, nil, csvPath, models.ExportFormatCSV); err != nil {
+		return fmt.Errorf("failed to create CSV: %w", err)
+	}
+
+	if err := jp.jobRepo.UpdateResult(ctx, job.ID, csvPath, downloadURL); err != nil {
+		return fmt.Errorf("failed to update job result: %w", err)
+	}
+
+	log.WithField("settlements_count", len(settlements)).
+		WithField("skipped_count", len(run.Skipped)).
+		WithField("superseded_count", len(emptyDays)).
+		WithField("csv_path", csvPath).
+		Info("Re-settlement job completed")
+
+	return nil
+}
+
+// merchantDayKey returns the map key for a merchant's settlement day
+func merchantDayKey(merchantID string, date time.Time) string {
+	return fmt.Sprintf("%s_%s", merchantID, date.Format("2006-01-02"))
+}
+
+// sortSettlements orders settlements by date, then merchant
+func sortSettlements(settlements []*models.Settlement) {
+	sort.Slice(settlements, func(i, j int) bool {
+		if !settlements[i].Date.Equal(settlements[j].Date) {
+			return settlements[i].Date.Before(settlements[j].Date)
+		}
+		return settlements[i].MerchantID < settlements[j].MerchantID
+	})
+}
+
+// StalenessMonitor periodically scans recent settlements for late-arriving
+// transactions and queues a re-settlement job when any are flagged. With a
+// leader, only the replica holding leadership scans.
+type StalenessMonitor struct {
+	settlementService SettlementService
+	jobService        JobService
+	config            *config.SettlementConfig
+	leader            *database.Leader
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewStalenessMonitor creates a new staleness monitor; a nil leader scans on
+// every tick
+func NewStalenessMonitor(settlementService SettlementService, jobService JobService, cfg *config.SettlementConfig, leader *database.Leader) *StalenessMonitor {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &StalenessMonitor{
+		settlementService: settlementService,
+		jobService:        jobService,
+		config:            cfg,
+		leader:            leader,
+		ctx:               ctx,
+		cancel:            cancel,
+	}
+}
+
+// Start starts the monitor loop
+func (m *StalenessMonitor) Start() {
+	logger.WithComponent("staleness_monitor").
+		WithField("interval", m.config.AutoResettleInterval.String()).
+		WithField("lookback_days", m.config.StaleLookbackDays).
+		Info("Starting settlement staleness monitor")
+
+	m.wg.Add(1)
+	go m.run()
+}
+
+// Stop stops the monitor loop
+func (m *StalenessMonitor) Stop() {
+	m.cancel()
+	m.wg.Wait()
+
+	logger.WithComponent("staleness_monitor").Info("Settlement staleness monitor stopped")
+}
+
+func (m *StalenessMonitor) run() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.config.AutoResettleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.check()
+		}
+	}
+}
+
+// check runs one staleness scan and queues a re-settlement job for whatever
+// is flagged, including settlements flagged by transaction status changes
+func (m *StalenessMonitor) check() {
+	log := logger.WithComponent("staleness_monitor")
+
+	if m.leader != nil && !m.leader.IsLeader(m.ctx) {
+		log.Debug("Not the leader, skipping staleness scan")
+		return
+	}
+
+	if _, err := m.settlementService.DetectStale(m.ctx, &models.DetectStaleSettlementsRequest{}); err != nil {
+		log.WithError(err).Error("Failed to scan settlements for staleness")
+		return
+	}
+
+	job, err := m.jobService.CreateResettleJob(m.ctx)
+	if err != nil {
+		if appErr, ok := errors.IsAppError(err); ok && appErr.Code == errors.ErrCodeConflict {
+			log.WithField("reason", appErr.Message).Debug("Skipping automatic re-settlement")
+			return
+		}
+		log.WithError(err).Error("Failed to create re-settlement job")
+		return
+	}
+
+	log.WithField("job_id", job.ID).Info("Automatic re-settlement job created")
+}
@@ -0,0 +1,273 @@
+// Package service provides merchant statement generation
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+	"indico-backend/internal/pdf"
+
+	"github.com/google/uuid"
+)
+
+// Statement layout, in points
+const (
+	statementMargin     = 50.0
+	statementLineHeight = 16.0
+	statementFontSize   = 10.0
+)
+
+// statementColumns are the right edges of the numeric statement columns
+var statementColumns = struct {
+	txnCount, gross, fees, net float64
+}{
+	txnCount: 250,
+	gross:    360,
+	fees:     450,
+	net:      pdf.PageWidth - statementMargin,
+}
+
+// statementMonth parses a YYYY-MM month into the first day of the month and
+// the first day of the next one
+func statementMonth(month string) (time.Time, time.Time, error) {
+	start, err := time.Parse("2006-01", month)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.NewValidationError("invalid month format, expected YYYY-MM")
+	}
+	return start, start.AddDate(0, 1, 0), nil
+}
+
+// CreateMerchantStatementJob queues a job that renders a merchant's monthly
+// statement PDF from its current settlements
+func (s *jobService) CreateMerchantStatementJob(ctx context.Context, req *models.CreateMerchantStatementJobRequest) (*models.Job, error) {
+	if strings.TrimSpace(req.MerchantID) == "" {
+		return nil, errors.NewValidationError("merchant_id is required")
+	}
+	if _, _, err := statementMonth(req.Month); err != nil {
+		return nil, err
+	}
+
+	params := models.MerchantStatementJobParams{
+		MerchantID: req.MerchantID,
+		Month:      req.Month,
+	}
+
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job parameters: %w", err)
+	}
+
+	job := &models.Job{
+		ID:         uuid.New(),
+		Type:       models.JobTypeMerchantStatement,
+		Status:     models.JobStatusQueued,
+		Progress:   0,
+		Processed:  0,
+		Total:      0, // Will be calculated when job starts
+		Parameters: string(paramsJSON),
+	}
+
+	if err := s.setProgressWebhook(ctx, job, req.ProgressWebhook); err != nil {
+		return nil, err
+	}
+
+	if err := s.enqueue(ctx, job); err != nil {
+		return nil, err
+	}
+
+	logger.WithContext(ctx).
+		WithField("job_id", job.ID).
+		WithField("merchant_id", req.MerchantID).
+		WithField("month", req.Month).
+		Info("Merchant statement job created and queued")
+
+	return job, nil
+}
+
+// processMerchantStatementJob renders a merchant statement PDF
+func (jp *JobProcessor) processMerchantStatementJob(ctx context.Context, job *models.Job) error {
+	log := logger.WithJobID(job.ID.String())
+
+	var params models.MerchantStatementJobParams
+	if err := json.Unmarshal([]byte(job.Parameters), &params); err != nil {
+		return fmt.Errorf("failed to parse job parameters: %w", err)
+	}
+
+	from, to, err := statementMonth(params.Month)
+	if err != nil {
+		return err
+	}
+
+	log.WithField("merchant_id", params.MerchantID).
+		WithField("month", params.Month).
+		Info("Processing merchant statement job")
+
+	settlements, err := jp.settleRepo.ListCurrentByMerchant(ctx, params.MerchantID, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to list settlements: %w", err)
+	}
+
+	adjustments, err := jp.settleRepo.ListAdjustments(ctx, &models.SettlementFilter{MerchantID: params.MerchantID, From: &from, To: &to})
+	if err != nil {
+		return fmt.Errorf("failed to list adjustments: %w", err)
+	}
+
+	jp.liveState(job.ID).setTotal(len(settlements))
+
+	filePath, downloadURL, err := jp.resultLocation(job.ID, "pdf")
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create statement file: %w", err)
+	}
+	defer file.Close()
+
+	doc := renderStatement(params.MerchantID, from, to.AddDate(0, 0, -1), settlements, adjustments, time.Now().UTC())
+	if _, err := doc.WriteTo(file); err != nil {
+		return fmt.Errorf("failed to write statement: %w", err)
+	}
+
+	jp.liveState(job.ID).recordBatch(len(settlements))
+	if err := jp.updateProgress(ctx, job, 100, len(settlements)); err != nil {
+		log.WithError(err).Error("Failed to update job progress")
+	}
+
+	if err := jp.jobRepo.UpdateResult(ctx, job.ID, filePath, downloadURL); err != nil {
+		return fmt.Errorf("failed to update job result: %w", err)
+	}
+
+	log.WithField("settlements_count", len(settlements)).
+		WithField("pages", doc.PageCount()).
+		WithField("file_path", filePath).
+		Info("Merchant statement job completed")
+
+	return nil
+}
+
+// renderStatement lays out a statement with one row per settlement day, then
+// one per adjustment, followed by the period totals, continuing on new pages
+// as needed. Adjustments count towards the net total only.
+func renderStatement(merchantID string, from, to time.Time, settlements []*models.Settlement, adjustments []*models.SettlementAdjustment, generatedAt time.Time) *pdf.Document {
+	doc := pdf.New()
+	var y float64
+
+	header := func() {
+		doc.AddPage()
+		y = pdf.PageHeight - statementMargin
+
+		doc.Text(statementMargin, y, pdf.Bold, 16, "Merchant Statement")
+		y -= 24
+		doc.Text(statementMargin, y, pdf.Regular, statementFontSize, "Merchant: "+merchantID)
+		y -= statementLineHeight
+		doc.Text(statementMargin, y, pdf.Regular, statementFontSize,
+			fmt.Sprintf("Period: %s to %s", from.Format("2006-01-02"), to.Format("2006-01-02")))
+		y -= statementLineHeight
+		doc.Text(statementMargin, y, pdf.Regular, statementFontSize, "Generated: "+generatedAt.Format(time.RFC3339))
+		y -= 28
+
+		statementRow(doc, y, pdf.Bold, "Date", "Transactions", "Gross", "Fees", "Net")
+		y -= 6
+		doc.Line(statementMargin, y, pdf.PageWidth-statementMargin, y, 0.5)
+		y -= statementLineHeight
+	}
+
+	header()
+
+	var gross, fees, net, txnCount int
+	var stale bool
+	for _, settlement := range settlements {
+		// Keep room for the totals block at the bottom of the page
+		if y < statementMargin+3*statementLineHeight {
+			header()
+		}
+
+		date := settlement.Date.Format("2006-01-02")
+		if settlement.StaleSince != nil {
+			date += " *"
+			stale = true
+		}
+		statementRow(doc, y, pdf.Regular, date,
+			strconv.Itoa(settlement.TxnCount),
+			formatCents(settlement.GrossCents),
+			formatCents(settlement.FeeCents),
+			formatCents(settlement.NetCents))
+		y -= statementLineHeight
+
+		gross += settlement.GrossCents
+		fees += settlement.FeeCents
+		net += settlement.NetCents
+		txnCount += settlement.TxnCount
+	}
+
+	if len(settlements) == 0 {
+		doc.Text(statementMargin, y, pdf.Regular, statementFontSize, "No settlements for this period.")
+		y -= statementLineHeight
+	}
+
+	for _, adjustment := range adjustments {
+		if y < statementMargin+3*statementLineHeight {
+			header()
+		}
+
+		statementRow(doc, y, pdf.Regular,
+			adjustment.Date.Format("2006-01-02")+" "+string(adjustment.ReasonCode),
+			"", "", "",
+			formatCents(adjustment.AmountCents))
+		y -= statementLineHeight
+
+		net += adjustment.AmountCents
+	}
+
+	y += statementLineHeight - 6
+	doc.Line(statementMargin, y, pdf.PageWidth-statementMargin, y, 0.5)
+	y -= statementLineHeight
+	statementRow(doc, y, pdf.Bold, "Total", strconv.Itoa(txnCount), formatCents(gross), formatCents(fees), formatCents(net))
+
+	if stale {
+		y -= 2 * statementLineHeight
+		doc.Text(statementMargin, y, pdf.Regular, 8, "* Flagged for re-settlement; amounts may change.")
+	}
+
+	return doc
+}
+
+// statementRow draws one table row with the numeric columns right-aligned
+func statementRow(doc *pdf.Document, y float64, font pdf.Font, date, txnCount, gross, fees, net string) {
+	doc.Text(statementMargin, y, font, statementFontSize, date)
+	doc.TextRight(statementColumns.txnCount, y, font, statementFontSize, txnCount)
+	doc.TextRight(statementColumns.gross, y, font, statementFontSize, gross)
+	doc.TextRight(statementColumns.fees, y, font, statementFontSize, fees)
+	doc.TextRight(statementColumns.net, y, font, statementFontSize, net)
+}
+
+// formatCents formats a minor-unit amount with thousands separators and two
+// decimals, e.g. 1234567 as "12,345.67"
+func formatCents(cents int) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+
+	whole := strconv.Itoa(cents / 100)
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(digit)
+	}
+
+	return fmt.Sprintf("%s%s.%02d", sign, grouped.String(), cents%100)
+}
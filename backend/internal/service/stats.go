@@ -0,0 +1,247 @@
+// Package service provides operational statistics for admin dashboards
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+)
+
+// Stats windows and limits; out-of-range values fall back to the default
+const (
+	defaultStatsMinutes = 60
+	maxStatsMinutes     = 24 * 60
+	defaultStatsHours   = 24
+	maxStatsHours       = 30 * 24
+	defaultTopMerchants = 10
+	maxTopMerchants     = 100
+)
+
+// statsService implements StatsService
+type statsService struct {
+	statsRepo     repository.StatsRepository
+	analyticsRepo repository.AnalyticsRepository
+	cache         *statsCache
+}
+
+// NewStatsService creates a new stats service
+func NewStatsService(deps *Dependencies) StatsService {
+	var ttl time.Duration
+	if deps.AdminConfig != nil {
+		ttl = deps.AdminConfig.StatsCacheTTL
+	}
+
+	return &statsService{
+		statsRepo:     deps.StatsRepo,
+		analyticsRepo: deps.AnalyticsRepo,
+		cache:         newStatsCache(ttl),
+	}
+}
+
+// OrdersPerMinute reports orders created per minute over the last minutes,
+// including minutes without orders
+func (s *statsService) OrdersPerMinute(ctx context.Context, minutes int) (*models.OrderRateStats, error) {
+	if minutes <= 0 || minutes > maxStatsMinutes {
+		minutes = defaultStatsMinutes
+	}
+
+	return cached(s.cache, fmt.Sprintf("orders_per_minute:%d", minutes), func() (*models.OrderRateStats, error) {
+		now := time.Now().UTC()
+		since := now.Truncate(time.Minute).Add(-time.Duration(minutes-1) * time.Minute)
+
+		counts, err := s.statsRepo.OrdersPerMinute(ctx, since)
+		if err != nil {
+			logger.WithContext(ctx).WithError(err).Error("Failed to count orders per minute")
+			return nil, err
+		}
+
+		byMinute := make(map[time.Time]int, len(counts))
+		for _, count := range counts {
+			byMinute[count.Minute.UTC()] = count.Count
+		}
+
+		stats := &models.OrderRateStats{
+			WindowMinutes: minutes,
+			Minutes:       make([]*models.MinuteCount, 0, minutes),
+			GeneratedAt:   now,
+		}
+		for i := 0; i < minutes; i++ {
+			minute := since.Add(time.Duration(i) * time.Minute)
+			stats.Minutes = append(stats.Minutes, &models.MinuteCount{Minute: minute, Count: byMinute[minute]})
+			stats.Total += byMinute[minute]
+		}
+		stats.PerMinute = float64(stats.Total) / float64(minutes)
+
+		return stats, nil
+	})
+}
+
+// JobThroughput reports, by job type, the jobs that finished in the last hours
+func (s *statsService) JobThroughput(ctx context.Context, hours int) (*models.JobThroughputStats, error) {
+	if hours <= 0 || hours > maxStatsHours {
+		hours = defaultStatsHours
+	}
+
+	return cached(s.cache, fmt.Sprintf("job_throughput:%d", hours), func() (*models.JobThroughputStats, error) {
+		now := time.Now().UTC()
+
+		types, err := s.statsRepo.JobThroughput(ctx, now.Add(-time.Duration(hours)*time.Hour))
+		if err != nil {
+			logger.WithContext(ctx).WithError(err).Error("Failed to summarize job throughput")
+			return nil, err
+		}
+		if types == nil {
+			types = []*models.JobTypeThroughput{}
+		}
+
+		return &models.JobThroughputStats{
+			WindowHours: hours,
+			Types:       types,
+			GeneratedAt: now,
+		}, nil
+	})
+}
+
+// JobQueue reports, by job type, the jobs queued and running and those that
+// failed in the last hour
+func (s *statsService) JobQueue(ctx context.Context) (*models.JobQueueStats, error) {
+	return cached(s.cache, "job_queue", func() (*models.JobQueueStats, error) {
+		now := time.Now().UTC()
+
+		types, err := s.statsRepo.JobQueue(ctx, now.Add(-jobFailureWindow))
+		if err != nil {
+			logger.WithContext(ctx).WithError(err).Error("Failed to summarize job queue")
+			return nil, err
+		}
+		if types == nil {
+			types = []*models.JobTypeQueue{}
+		}
+
+		return &models.JobQueueStats{
+			Types:       types,
+			GeneratedAt: now,
+		}, nil
+	})
+}
+
+// ErrorRates reports job, transaction and order failure rates over the last hours
+func (s *statsService) ErrorRates(ctx context.Context, hours int) (*models.ErrorRateStats, error) {
+	if hours <= 0 || hours > maxStatsHours {
+		hours = defaultStatsHours
+	}
+
+	return cached(s.cache, fmt.Sprintf("error_rates:%d", hours), func() (*models.ErrorRateStats, error) {
+		now := time.Now().UTC()
+
+		stats, err := s.statsRepo.ErrorRates(ctx, now.Add(-time.Duration(hours)*time.Hour))
+		if err != nil {
+			logger.WithContext(ctx).WithError(err).Error("Failed to count error rates")
+			return nil, err
+		}
+
+		for _, rate := range []*models.ErrorRate{&stats.Jobs, &stats.Transactions, &stats.Orders} {
+			if rate.Total > 0 {
+				rate.Rate = float64(rate.Errors) / float64(rate.Total)
+			}
+		}
+		stats.WindowHours = hours
+		stats.GeneratedAt = now
+
+		return stats, nil
+	})
+}
+
+// TopMerchants reports the merchants with the highest completed volume in
+// the period. The period defaults to the current calendar month (UTC); from
+// and to are inclusive YYYY-MM-DD dates.
+func (s *statsService) TopMerchants(ctx context.Context, from, to string, limit int) (*models.TopMerchantsStats, error) {
+	if limit <= 0 || limit > maxTopMerchants {
+		limit = defaultTopMerchants
+	}
+
+	now := time.Now().UTC()
+	periodFrom, periodTo, err := dashboardPeriod(from, to, now)
+	if err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("top_merchants:%s:%s:%d", periodFrom.Format("2006-01-02"), periodTo.Format("2006-01-02"), limit)
+	return cached(s.cache, key, func() (*models.TopMerchantsStats, error) {
+		merchants, err := s.statsRepo.TopMerchants(ctx, periodFrom, periodTo.AddDate(0, 0, 1), limit)
+		if err != nil {
+			logger.WithContext(ctx).WithError(err).Error("Failed to list top merchants")
+			return nil, err
+		}
+		if merchants == nil {
+			merchants = []*models.MerchantVolume{}
+		}
+
+		return &models.TopMerchantsStats{
+			PeriodFrom:  periodFrom.Format("2006-01-02"),
+			PeriodTo:    periodTo.Format("2006-01-02"),
+			Merchants:   merchants,
+			GeneratedAt: now,
+		}, nil
+	})
+}
+
+// statsCache keeps computed stats for a short time so dashboards polling
+// several panels don't rerun the aggregates on every request
+type statsCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]statsCacheEntry
+}
+
+type statsCacheEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// newStatsCache creates a cache; a non-positive ttl disables caching
+func newStatsCache(ttl time.Duration) *statsCache {
+	return &statsCache{
+		ttl:     ttl,
+		entries: make(map[string]statsCacheEntry),
+	}
+}
+
+// cached returns the unexpired value stored under key, or loads, stores and
+// returns a fresh one. Errors are not cached.
+func cached[T any](c *statsCache, key string, load func() (T, error)) (T, error) {
+	if c.ttl <= 0 {
+		return load()
+	}
+
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.value.(T), nil
+	}
+
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Drop expired entries so arbitrary periods can't grow the cache unbounded
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = statsCacheEntry{value: value, expires: now.Add(c.ttl)}
+
+	return value, nil
+}
@@ -0,0 +1,657 @@
+// Package service provides the Redis stock fast path for flash sales
+package service
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"indico-backend/internal/config"
+	"indico-backend/internal/database"
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/metrics"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis keys share the {indico} hash tag so the scripts touching several of
+// them run on one slot of a Redis Cluster
+const (
+	fastPathStockPrefix   = "{indico}:stock:"
+	fastPathQueueKey      = "{indico}:orders:queue"
+	fastPathProcessingKey = "{indico}:orders:processing"
+	fastPathRefPrefix     = "{indico}:orders:ref:"
+	fastPathBuyerPrefix   = "{indico}:event:"
+	// fastPathNoKey stands in for an optional key a script call does not use
+	fastPathNoKey = "{indico}:none"
+)
+
+// fastPathRefTTL is how long a client reference answers repeats of an order
+// the fast path accepted
+const fastPathRefTTL = 24 * time.Hour
+
+// fastPathReserve takes stock for an order and queues it for Postgres in one
+// step. A product's hash holds its cached stock and price, the quantity
+// reserved but not yet written (pending), and a generation bumped by every
+// reservation. KEYS[3] is the client reference, used when ARGV[4] is 1, and
+// KEYS[4] the units the buyer holds in the order's sales event, checked
+// against the per-buyer limit in ARGV[5] unless it is 0. Replies {1, price}
+// when reserved, {-1} when out of stock, {-2} when the product is not
+// cached, {-3, entry} when the client reference already reserved an order,
+// {-4} when the product is ordered directly through Postgres, {-5} when the
+// order exceeds the per-buyer limit and {-6} when the buyer's units are
+// not cached.
+var fastPathReserve = redis.NewScript(`
+if ARGV[4] == '1' then
+	local existing = redis.call('GET', KEYS[3])
+	if existing then
+		return {-3, existing}
+	end
+end
+local stock = redis.call('HGET', KEYS[1], 'stock')
+if not stock then
+	return {-2}
+end
+if redis.call('HGET', KEYS[1], 'direct') == '1' then
+	return {-4}
+end
+local qty = tonumber(ARGV[1])
+local limit = tonumber(ARGV[5])
+if limit > 0 then
+	local held = redis.call('GET', KEYS[4])
+	if not held then
+		return {-6}
+	end
+	if tonumber(held) + qty > limit then
+		return {-5}
+	end
+end
+if tonumber(stock) < qty then
+	return {-1}
+end
+redis.call('HINCRBY', KEYS[1], 'stock', -qty)
+redis.call('HINCRBY', KEYS[1], 'pending', qty)
+redis.call('HINCRBY', KEYS[1], 'gen', 1)
+redis.call('LPUSH', KEYS[2], ARGV[2])
+if ARGV[4] == '1' then
+	redis.call('SET', KEYS[3], ARGV[2], 'EX', ARGV[3])
+end
+if limit > 0 then
+	redis.call('INCRBY', KEYS[4], qty)
+end
+return {1, redis.call('HGET', KEYS[1], 'price')}
+`)
+
+// fastPathLoadBuyer caches the units a buyer holds in a sales event unless
+// another caller already has
+var fastPathLoadBuyer = redis.NewScript(`
+redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2])
+return 1
+`)
+
+// fastPathLoad caches a product's stock from Postgres unless another caller
+// already has. Products ordered directly are cached only as such, so their
+// orders skip the fast path without asking Postgres again.
+var fastPathLoad = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	redis.call('HSET', KEYS[1], 'stock', ARGV[1], 'price', ARGV[2], 'pending', 0, 'gen', 0, 'direct', ARGV[3])
+end
+return 1
+`)
+
+// fastPathFinish settles a written order: it leaves the processing list and
+// stops counting as pending, and a failed order gives its stock back, and
+// its units to the buyer's sales event count (optional third key). An
+// entry no longer in the processing list was requeued and is settled by
+// whoever writes it next.
+var fastPathFinish = redis.NewScript(`
+if redis.call('LREM', KEYS[2], 1, ARGV[1]) == 0 then
+	return 0
+end
+redis.call('HINCRBY', KEYS[1], 'pending', -tonumber(ARGV[2]))
+if ARGV[3] == '1' then
+	redis.call('HINCRBY', KEYS[1], 'stock', tonumber(ARGV[2]))
+	if #KEYS == 3 and redis.call('EXISTS', KEYS[3]) == 1 then
+		redis.call('DECRBY', KEYS[3], tonumber(ARGV[2]))
+	end
+end
+return 1
+`)
+
+// fastPathRelease gives a cancelled order's units back to a cached product
+// and, with the optional second key, takes them off the buyer's sales event
+// count; either key is skipped when not cached. The
+// generation is bumped so a reconcile that read Postgres before the
+// cancellation committed cannot overwrite the released stock.
+var fastPathRelease = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	redis.call('HINCRBY', KEYS[1], 'stock', tonumber(ARGV[1]))
+	redis.call('HINCRBY', KEYS[1], 'gen', 1)
+end
+if #KEYS == 2 and redis.call('EXISTS', KEYS[2]) == 1 then
+	redis.call('DECRBY', KEYS[2], tonumber(ARGV[1]))
+end
+return 1
+`)
+
+// fastPathRequeue moves an entry stuck in the processing list back to the
+// queue
+var fastPathRequeue = redis.NewScript(`
+if redis.call('LREM', KEYS[1], 1, ARGV[1]) == 1 then
+	redis.call('LPUSH', KEYS[2], ARGV[1])
+	return 1
+end
+return 0
+`)
+
+// fastPathReconcile overwrites the cached stock, price and direct flag with
+// Postgres' values, but only if nothing is pending and no reservation
+// happened since the generation was read, so the values read from Postgres
+// are current
+var fastPathReconcile = redis.NewScript(`
+local fields = redis.call('HMGET', KEYS[1], 'pending', 'gen')
+if fields[1] ~= '0' or fields[2] ~= ARGV[1] then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'stock', ARGV[2], 'price', ARGV[3], 'direct', ARGV[4])
+return 1
+`)
+
+// errFastPathDirect reports that the fast path does not take orders for a
+// product: a bundle's stock is its components', which only Postgres
+// reserves together, and only Postgres knows when a product taking
+// backorders has earlier backorders waiting. Such orders are placed
+// through the saga directly.
+var errFastPathDirect = stderrors.New("product is not ordered through the stock fast path")
+
+// fastPathEntry is an order the fast path accepted, queued in Redis until a
+// writer places it in Postgres
+type fastPathEntry struct {
+	Placement  orderPlacement `json:"placement"`
+	ReservedAt time.Time      `json:"reserved_at"`
+}
+
+// StockFastPath accepts orders by decrementing stock atomically in Redis
+// and places them in Postgres asynchronously. Reserving stock and queueing
+// the order happen in one Lua script, so every accepted order is in Redis
+// before its caller is answered. Writers on every replica drain the queue
+// through the ORDER_PLACEMENT saga; an order Postgres refuses is stored as
+// CANCELLED and its stock returned to the cache. The leader periodically
+// requeues orders stuck mid-write and, for products with nothing pending,
+// resets the cached stock to Postgres' value, which is how stock changed
+// outside the fast path reaches the cache.
+type StockFastPath struct {
+	rdb         *redis.Client
+	productRepo repository.ProductRepository
+	bundleRepo  repository.BundleRepository
+	orderRepo   repository.OrderRepository
+	sagas       *SagaOrchestrator
+	pricing     *Pricing
+	config      *config.OrdersConfig
+	leader      *database.Leader
+
+	// stuck holds the processing entries seen by the previous reconcile
+	// pass; those still there on the next pass are requeued
+	stuck map[string]bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Ping checks that Redis answers
+func (s *StockFastPath) Ping(ctx context.Context) error {
+	return s.rdb.Ping(ctx).Err()
+}
+
+// NewStockFastPath creates the stock fast path; a nil leader reconciles on
+// every replica
+func NewStockFastPath(rdb *redis.Client, productRepo repository.ProductRepository, bundleRepo repository.BundleRepository, orderRepo repository.OrderRepository, sagas *SagaOrchestrator, pricing *Pricing, cfg *config.OrdersConfig, leader *database.Leader) *StockFastPath {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &StockFastPath{
+		rdb:         rdb,
+		productRepo: productRepo,
+		bundleRepo:  bundleRepo,
+		orderRepo:   orderRepo,
+		sagas:       sagas,
+		pricing:     pricing,
+		config:      cfg,
+		leader:      leader,
+		stuck:       make(map[string]bool),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+}
+
+// Start starts the writers and the reconcile loop. Orders are placed
+// through the ORDER_PLACEMENT saga, so the order service must have been
+// created first.
+func (f *StockFastPath) Start() {
+	logger.WithComponent("stock_fast_path").
+		WithField("writers", f.config.FastPathWriters).
+		WithField("resync_interval", f.config.FastPathResyncInterval.String()).
+		Info("Starting stock fast path")
+
+	for i := 0; i < f.config.FastPathWriters; i++ {
+		f.wg.Add(1)
+		go f.write()
+	}
+
+	f.wg.Add(1)
+	go f.reconcileLoop()
+}
+
+// Stop stops the writers after the orders they are placing, and the
+// reconcile loop. Queued orders stay in Redis for the next start.
+func (f *StockFastPath) Stop() {
+	f.cancel()
+	f.wg.Wait()
+
+	logger.WithComponent("stock_fast_path").Info("Stock fast path stopped")
+}
+
+// Reserve takes stock for an order in Redis and queues it for Postgres,
+// returning the order as PENDING. An order repeating an accepted client
+// reference returns the accepted order.
+func (f *StockFastPath) Reserve(ctx context.Context, placement *orderPlacement) (*models.Order, error) {
+	entry := &fastPathEntry{Placement: *placement, ReservedAt: time.Now().UTC()}
+	entry.Placement.Status = models.OrderStatusPending
+	entry.Placement.CreatedAt = entry.ReservedAt
+	entry.Placement.UpdatedAt = entry.ReservedAt
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal fast path order: %w", err)
+	}
+
+	keys := []string{fastPathStockKey(placement.ProductID), fastPathQueueKey, fastPathNoKey, fastPathNoKey}
+	hasRef, limit := "0", 0
+	if placement.ClientReference != nil {
+		keys[2] = fastPathRefPrefix + *placement.ClientReference
+		hasRef = "1"
+	}
+	if placement.SalesEvent != nil {
+		keys[3] = fastPathBuyerKey(placement)
+		limit = placement.SalesEvent.PerBuyerLimit
+	}
+
+	var stockLoaded, buyerLoaded bool
+	for {
+		reply, err := fastPathReserve.Run(ctx, f.rdb, keys, placement.Quantity, data, int(fastPathRefTTL.Seconds()), hasRef, limit).Slice()
+		if err != nil {
+			return nil, fmt.Errorf("failed to reserve stock: %w", err)
+		}
+
+		switch reply[0].(int64) {
+		case 1:
+			metrics.FastPathReservations.WithLabelValues("reserved").Inc()
+			// The saga prices the order again when writing it, with the
+			// same hooks
+			price, _ := strconv.Atoi(reply[1].(string))
+			quote, err := f.pricing.Quote(ctx, placement.ProductID, placement.BuyerID, placement.Quantity, price)
+			if err != nil {
+				return nil, err
+			}
+			entry.Placement.TotalCents = quote.TotalCents()
+			return entry.Placement.order(), nil
+		case -1:
+			metrics.FastPathReservations.WithLabelValues("out_of_stock").Inc()
+			return nil, errors.ErrOutOfStock
+		case -3:
+			return acceptedOrder(reply[1].(string), placement)
+		case -4:
+			return nil, errFastPathDirect
+		case -5:
+			metrics.FastPathReservations.WithLabelValues("limit_exceeded").Inc()
+			return nil, errors.ErrPurchaseLimitExceeded
+		case -6:
+			if buyerLoaded {
+				return nil, fmt.Errorf("sales event units of buyer %s were not cached after loading them", placement.BuyerID)
+			}
+			if err := f.loadBuyer(ctx, placement); err != nil {
+				return nil, err
+			}
+			buyerLoaded = true
+			continue
+		}
+
+		if stockLoaded {
+			return nil, fmt.Errorf("product %d was not cached after loading it", placement.ProductID)
+		}
+		if err := f.load(ctx, placement.ProductID); err != nil {
+			return nil, err
+		}
+		stockLoaded = true
+	}
+}
+
+// Warm caches a product's stock ahead of the orders a sales event brings,
+// so the first of them do not all miss the cache and read Postgres
+func (f *StockFastPath) Warm(ctx context.Context, productID int) error {
+	return f.load(ctx, productID)
+}
+
+// loadBuyer caches the units a buyer holds in the order's sales event from
+// Postgres, until an hour after the event ends. Orders still queued in
+// Redis are counted by the cache only, so it must not expire while the
+// event is live.
+func (f *StockFastPath) loadBuyer(ctx context.Context, placement *orderPlacement) error {
+	held, err := f.orderRepo.BuyerEventQuantity(ctx, nil, placement.SalesEvent.ID, placement.BuyerID, placement.ProductID)
+	if err != nil {
+		return err
+	}
+
+	ttl := time.Until(placement.SalesEvent.EndsAt) + time.Hour
+	if err := fastPathLoadBuyer.Run(ctx, f.rdb, []string{fastPathBuyerKey(placement)}, held, ttl.Milliseconds()).Err(); err != nil {
+		return fmt.Errorf("failed to cache buyer's sales event units: %w", err)
+	}
+	return nil
+}
+
+// acceptedOrder returns the order an earlier request with the same client
+// reference reserved, or a conflict if it describes a different order
+func acceptedOrder(data string, placement *orderPlacement) (*models.Order, error) {
+	var entry fastPathEntry
+	if err := json.Unmarshal([]byte(data), &entry); err != nil {
+		return nil, fmt.Errorf("failed to decode fast path order: %w", err)
+	}
+
+	accepted := &entry.Placement
+	if accepted.ProductID != placement.ProductID || accepted.Quantity != placement.Quantity || accepted.BuyerID != placement.BuyerID {
+		return nil, errors.ErrClientReferenceConflict
+	}
+	return accepted.order(), nil
+}
+
+// load caches a product's stock and price from Postgres
+func (f *StockFastPath) load(ctx context.Context, productID int) error {
+	product, err := f.productRepo.GetByID(ctx, productID)
+	if err != nil {
+		return err
+	}
+	direct, err := f.direct(ctx, product)
+	if err != nil {
+		return err
+	}
+
+	if err := fastPathLoad.Run(ctx, f.rdb, []string{fastPathStockKey(productID)}, product.Stock, product.Price, direct).Err(); err != nil {
+		return fmt.Errorf("failed to cache product stock: %w", err)
+	}
+	return nil
+}
+
+// direct returns the cached flag for a product ordered directly through
+// Postgres: "1" for a bundle or a product that takes backorders
+func (f *StockFastPath) direct(ctx context.Context, product *models.Product) (string, error) {
+	if product.BackorderLimit > 0 {
+		return "1", nil
+	}
+	components, err := f.bundleRepo.GetComponents(ctx, nil, product.ID)
+	if err != nil {
+		return "", err
+	}
+	if len(components) > 0 {
+		return "1", nil
+	}
+	return "0", nil
+}
+
+// write places queued orders in Postgres until the fast path is stopped
+func (f *StockFastPath) write() {
+	defer f.wg.Done()
+	log := logger.WithComponent("stock_fast_path")
+
+	for f.ctx.Err() == nil {
+		data, err := f.rdb.BLMove(f.ctx, fastPathQueueKey, fastPathProcessingKey, "RIGHT", "LEFT", time.Second).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			if f.ctx.Err() != nil {
+				return
+			}
+			log.WithError(err).Error("Failed to take a queued order")
+			f.sleep(time.Second)
+			continue
+		}
+
+		// Finish the order even if the fast path is stopping meanwhile
+		f.place(context.WithoutCancel(f.ctx), data)
+	}
+}
+
+// place writes one queued order to Postgres and settles it in Redis.
+// Placing is idempotent by order ID, so an order requeued after a writer
+// stalled is not placed twice. An order that fails for a transient reason
+// stays in the processing list until the leader requeues it.
+func (f *StockFastPath) place(ctx context.Context, data string) {
+	var entry fastPathEntry
+	if err := json.Unmarshal([]byte(data), &entry); err != nil {
+		logger.WithComponent("stock_fast_path").WithError(err).Error("Dropping undecodable fast path order")
+		f.rdb.LRem(ctx, fastPathProcessingKey, 1, data)
+		return
+	}
+	p := &entry.Placement
+	log := logger.WithComponent("stock_fast_path").WithField("order_id", p.OrderID).WithField("product_id", p.ProductID)
+
+	if placed, err := f.orderRepo.GetByID(ctx, p.OrderID); err == nil {
+		f.finish(ctx, data, p, placed.Status == models.OrderStatusCancelled)
+		return
+	}
+
+	_, err := placeOrder(ctx, f.sagas, p)
+	if err == nil {
+		metrics.FastPathWrites.WithLabelValues("placed").Inc()
+		f.finish(ctx, data, p, false)
+		return
+	}
+
+	if appErr, _ := errors.IsAppError(err); appErr == nil || appErr.Code == errors.ErrCodeConcurrencyConflict {
+		metrics.FastPathWrites.WithLabelValues("failed").Inc()
+		log.WithError(err).Error("Failed to place fast path order, leaving it for retry")
+		return
+	}
+
+	// Postgres refused the order, e.g. the cache had more stock than the
+	// database; record it as cancelled so the client can see the outcome
+	log.WithError(err).Warn("Fast path order refused by the database, cancelling it")
+	order := p.order()
+	order.Status = models.OrderStatusCancelled
+	if err := f.orderRepo.Create(ctx, nil, order); err != nil {
+		log.WithError(err).Error("Failed to record cancelled fast path order")
+		return
+	}
+	for _, event := range []*models.OrderEvent{
+		p.event(models.OrderEventCreated, fmt.Sprintf("quantity=%d total_cents=%d", order.Quantity, order.TotalCents)),
+		p.event(models.OrderEventCancelled, "refused by the database: "+err.Error()),
+	} {
+		if err := f.orderRepo.AddEvent(ctx, nil, event); err != nil {
+			log.WithError(err).Error("Failed to record cancelled fast path order event")
+		}
+	}
+	metrics.FastPathWrites.WithLabelValues("cancelled").Inc()
+	f.finish(ctx, data, p, true)
+}
+
+// finish settles a written order in Redis, returning its stock if it was
+// not placed
+func (f *StockFastPath) finish(ctx context.Context, data string, p *orderPlacement, release bool) {
+	releaseArg := "0"
+	if release {
+		releaseArg = "1"
+	}
+	keys := []string{fastPathStockKey(p.ProductID), fastPathProcessingKey}
+	if p.SalesEvent != nil {
+		keys = append(keys, fastPathBuyerKey(p))
+	}
+	if err := fastPathFinish.Run(ctx, f.rdb, keys, data, p.Quantity, releaseArg).Err(); err != nil {
+		logger.WithComponent("stock_fast_path").WithError(err).WithField("order_id", p.OrderID).Error("Failed to settle fast path order")
+	}
+}
+
+// Release gives the stock a cancelled order held back to the cache, so it
+// can be ordered again before the next reconcile. Postgres already has it;
+// a failure is only logged and the reconcile catches up.
+func (f *StockFastPath) Release(ctx context.Context, p *orderPlacement) {
+	releases := make([][]string, 0, len(p.Components)+1)
+	quantities := make([]int, 0, len(p.Components)+1)
+	for _, r := range p.reserved() {
+		releases = append(releases, []string{fastPathStockKey(r.ProductID)})
+		quantities = append(quantities, r.Quantity)
+	}
+	if p.SalesEvent != nil {
+		releases = append(releases, []string{fastPathNoKey, fastPathBuyerKey(p)})
+		quantities = append(quantities, p.Quantity)
+	}
+
+	for i, keys := range releases {
+		if err := fastPathRelease.Run(ctx, f.rdb, keys, quantities[i]).Err(); err != nil {
+			logger.WithComponent("stock_fast_path").WithError(err).WithField("order_id", p.OrderID).Warn("Failed to release cancelled order stock")
+		}
+	}
+}
+
+func (f *StockFastPath) reconcileLoop() {
+	defer f.wg.Done()
+
+	ticker := time.NewTicker(f.config.FastPathResyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-f.ctx.Done():
+			return
+		case <-ticker.C:
+			f.reconcile()
+		}
+	}
+}
+
+// reconcile requeues stuck orders and refreshes the cached stock of
+// products with nothing pending
+func (f *StockFastPath) reconcile() {
+	log := logger.WithComponent("stock_fast_path")
+
+	if f.leader != nil && !f.leader.IsLeader(f.ctx) {
+		return
+	}
+
+	if backlog, err := f.rdb.LLen(f.ctx, fastPathQueueKey).Result(); err == nil {
+		metrics.FastPathBacklog.Set(float64(backlog))
+	}
+
+	if err := f.requeueStuck(); err != nil {
+		log.WithError(err).Error("Failed to requeue stuck fast path orders")
+	}
+
+	var cursor uint64
+	for {
+		keys, next, err := f.rdb.Scan(f.ctx, cursor, fastPathStockPrefix+"*", 100).Result()
+		if err != nil {
+			log.WithError(err).Error("Failed to scan cached stock")
+			return
+		}
+		for _, key := range keys {
+			if err := f.reconcileProduct(key); err != nil {
+				log.WithError(err).WithField("key", key).Error("Failed to reconcile cached stock")
+			}
+		}
+		if next == 0 {
+			return
+		}
+		cursor = next
+	}
+}
+
+// requeueStuck moves orders that stayed in the processing list for a whole
+// reconcile interval back to the queue
+func (f *StockFastPath) requeueStuck() error {
+	processing, err := f.rdb.LRange(f.ctx, fastPathProcessingKey, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(processing))
+	for _, data := range processing {
+		if !f.stuck[data] {
+			seen[data] = true
+			continue
+		}
+		if err := fastPathRequeue.Run(f.ctx, f.rdb, []string{fastPathProcessingKey, fastPathQueueKey}, data).Err(); err != nil {
+			return err
+		}
+		metrics.FastPathRequeued.Inc()
+	}
+	f.stuck = seen
+	return nil
+}
+
+// reconcileProduct resets one product's cached stock to Postgres' value if
+// nothing is pending for it
+func (f *StockFastPath) reconcileProduct(key string) error {
+	productID, err := strconv.Atoi(key[len(fastPathStockPrefix):])
+	if err != nil {
+		return err
+	}
+
+	fields, err := f.rdb.HMGet(f.ctx, key, "stock", "price", "pending", "gen", "direct").Result()
+	if err != nil {
+		return err
+	}
+	if fields[2] != "0" {
+		return nil
+	}
+
+	product, err := f.productRepo.GetByID(f.ctx, productID)
+	if err != nil {
+		return err
+	}
+	direct, err := f.direct(f.ctx, product)
+	if err != nil {
+		return err
+	}
+	if fields[0] == strconv.Itoa(product.Stock) && fields[1] == strconv.Itoa(product.Price) && fields[4] == direct {
+		return nil
+	}
+
+	applied, err := fastPathReconcile.Run(f.ctx, f.rdb, []string{key}, fields[3], product.Stock, product.Price, direct).Int()
+	if err != nil {
+		return err
+	}
+	if applied == 1 {
+		metrics.FastPathCorrections.Inc()
+		logger.WithComponent("stock_fast_path").
+			WithField("product_id", productID).
+			WithField("cached_stock", fields[0]).
+			WithField("stock", product.Stock).
+			Info("Corrected cached stock")
+	}
+	return nil
+}
+
+// sleep waits for d or until the fast path is stopped
+func (f *StockFastPath) sleep(d time.Duration) {
+	select {
+	case <-f.ctx.Done():
+	case <-time.After(d):
+	}
+}
+
+// fastPathStockKey is the Redis hash holding a product's cached stock
+func fastPathStockKey(productID int) string {
+	return fastPathStockPrefix + strconv.Itoa(productID)
+}
+
+// fastPathBuyerKey is the Redis counter of the units of a product a buyer
+// holds in the order's sales event
+func fastPathBuyerKey(p *orderPlacement) string {
+	return fmt.Sprintf("%s%d:buyer:%s:%d", fastPathBuyerPrefix, p.SalesEvent.ID, p.BuyerID, p.ProductID)
+}
@@ -0,0 +1,125 @@
+// Package service provides stock synchronisation from the warehouse system
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+)
+
+// SyncStock sets the stock of each listed product to the absolute level
+// reported by the warehouse system. All items are applied in one
+// transaction: an unknown or repeated SKU rejects the whole request. Each
+// product whose stock changes gets a version bump and an inventory movement.
+// Raising the stock of a product with backorders waiting queues a job to
+// confirm them.
+func (s *productService) SyncStock(ctx context.Context, req *models.StockSyncRequest) (*models.StockSyncResult, error) {
+	levels := make(map[string]int, len(req.Items))
+	skus := make([]string, 0, len(req.Items))
+	for i, item := range req.Items {
+		if item.AbsoluteStock == nil {
+			return nil, errors.NewValidationError(fmt.Sprintf("items[%d]: absolute_stock is required", i))
+		}
+		if _, ok := levels[item.SKU]; ok {
+			return nil, errors.NewValidationError(fmt.Sprintf("items[%d]: SKU %q is listed more than once", i, item.SKU))
+		}
+		levels[item.SKU] = *item.AbsoluteStock
+		skus = append(skus, item.SKU)
+	}
+
+	result := &models.StockSyncResult{}
+	var restocked []int
+
+	err := s.uow.Do(ctx, nil, func(tx *repository.Tx) error {
+		products, err := tx.Products.GetBySKUsForUpdate(ctx, skus)
+		if err != nil {
+			return err
+		}
+
+		if len(products) != len(skus) {
+			found := make(map[string]bool, len(products))
+			for _, product := range products {
+				found[*product.SKU] = true
+			}
+			for _, sku := range skus {
+				if !found[sku] {
+					return errors.NewValidationError(fmt.Sprintf("unknown SKU %q", sku))
+				}
+			}
+		}
+
+		movements := []*models.InventoryMovement{}
+		restocked = nil
+		for _, product := range products {
+			stock := levels[*product.SKU]
+			if stock == product.Stock {
+				continue
+			}
+
+			if err := tx.Products.SetStock(ctx, product.ID, stock); err != nil {
+				return err
+			}
+			if product.Backordered > 0 && stock > product.Stock {
+				restocked = append(restocked, product.ID)
+			}
+
+			movements = append(movements, &models.InventoryMovement{
+				ProductID:     product.ID,
+				SKU:           *product.SKU,
+				PreviousStock: product.Stock,
+				NewStock:      stock,
+				Delta:         stock - product.Stock,
+				Reason:        models.MovementReasonStockSync,
+			})
+		}
+
+		if err := tx.Products.CreateMovements(ctx, movements); err != nil {
+			return err
+		}
+
+		result.Updated = len(movements)
+		result.Unchanged = len(products) - len(movements)
+		result.Movements = movements
+		return nil
+	})
+	if err != nil {
+		if _, ok := errors.IsAppError(err); !ok {
+			logger.WithContext(ctx).WithError(err).Error("Failed to sync stock")
+		}
+		return nil, err
+	}
+
+	logger.WithContext(ctx).
+		WithField("items", len(skus)).
+		WithField("updated", result.Updated).
+		Info("Stock synced from warehouse")
+
+	result.FulfillmentJobID = fulfillRestocked(ctx, s.jobs, restocked)
+	return result, nil
+}
+
+// ListMovements returns a product's inventory movements, newest first
+func (s *productService) ListMovements(ctx context.Context, productID int, limit, offset int) ([]*models.InventoryMovement, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 10 // Default limit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	if _, err := s.productRepo.GetByID(ctx, productID); err != nil {
+		return nil, err
+	}
+
+	movements, err := s.productRepo.ListMovements(ctx, productID, limit, offset)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).WithField("product_id", productID).Error("Failed to list inventory movements")
+		return nil, err
+	}
+
+	return movements, nil
+}
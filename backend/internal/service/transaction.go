@@ -0,0 +1,128 @@
+// Package service provides business logic implementation
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+)
+
+// transactionTransitions lists the statuses each transaction status may move
+// to. FAILED and REFUNDED are final.
+var transactionTransitions = map[models.TransactionStatus][]models.TransactionStatus{
+	models.TransactionStatusPending:   {models.TransactionStatusCompleted, models.TransactionStatusFailed},
+	models.TransactionStatusCompleted: {models.TransactionStatusRefunded},
+}
+
+// canTransition reports whether a transaction may move from one status to another
+func canTransition(from, to models.TransactionStatus) bool {
+	for _, allowed := range transactionTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transactionService implements TransactionService
+type transactionService struct {
+	uow          repository.UnitOfWork
+	txRepo       repository.TransactionReader
+	orderRepo    repository.OrderReader
+	jobProcessor *JobProcessor
+}
+
+// NewTransactionService creates a new transaction service
+func NewTransactionService(deps *Dependencies) TransactionService {
+	return &transactionService{
+		uow:          deps.unitOfWork(),
+		txRepo:       deps.TxRepo,
+		orderRepo:    deps.OrderRepo,
+		jobProcessor: deps.JobProcessor,
+	}
+}
+
+// UpdateStatus moves a transaction to a new status. Only completed
+// transactions are settled, so a change into or out of COMPLETED flags the
+// settlement of the day the transaction settles on as stale.
+func (s *transactionService) UpdateStatus(ctx context.Context, id int, req *models.UpdateTransactionStatusRequest) (*models.TransactionStatusChange, error) {
+	switch req.Status {
+	case models.TransactionStatusPending, models.TransactionStatusCompleted,
+		models.TransactionStatusFailed, models.TransactionStatusRefunded:
+	default:
+		return nil, errors.NewValidationError("invalid status, expected PENDING, COMPLETED, FAILED or REFUNDED")
+	}
+
+	txn, err := s.txRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := txn.Status
+	if !canTransition(previous, req.Status) {
+		return nil, errors.NewInvalidTransitionError(string(previous), string(req.Status))
+	}
+
+	affectsSettlement := previous == models.TransactionStatusCompleted || req.Status == models.TransactionStatusCompleted
+
+	// Resolve the settlement day before the transaction so a calendar lookup
+	// failure leaves the status untouched
+	settlementDate := txn.PaidAt
+	if affectsSettlement {
+		book, err := s.jobProcessor.calendarBook(ctx)
+		if err != nil {
+			return nil, err
+		}
+		settlementDate = book.SettlementDate(txn.MerchantID, txn.PaidAt)
+	}
+
+	change := &models.TransactionStatusChange{
+		Transaction:      txn,
+		PreviousStatus:   previous,
+		StaleSettlements: []*models.Settlement{},
+	}
+
+	err = s.uow.Do(ctx, nil, func(tx *repository.Tx) error {
+		if err := tx.Transactions.UpdateStatus(ctx, id, previous, req.Status); err != nil {
+			return err
+		}
+		updated := *txn
+		updated.Status = req.Status
+		if err := recordOrderPayment(ctx, tx, &updated, "status update"); err != nil {
+			return err
+		}
+
+		if !affectsSettlement {
+			return nil
+		}
+
+		reason := fmt.Sprintf("transaction %d changed from %s to %s", id, previous, req.Status)
+		settlement, err := tx.Settlements.MarkStale(ctx, txn.MerchantID, settlementDate, reason)
+		if err != nil {
+			return err
+		}
+		if settlement != nil {
+			change.StaleSettlements = append(change.StaleSettlements, settlement)
+		}
+		return nil
+	})
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).WithField("transaction_id", id).Error("Failed to update transaction status")
+		return nil, err
+	}
+
+	txn.Status = req.Status
+
+	logger.WithContext(ctx).
+		WithField("transaction_id", id).
+		WithField("from", previous).
+		WithField("to", req.Status).
+		WithField("stale_settlements", len(change.StaleSettlements)).
+		Info("Transaction status updated")
+
+	return change, nil
+}
@@ -0,0 +1,268 @@
+// Package service provides the virtual waiting room for high-demand products
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"indico-backend/internal/config"
+	"indico-backend/internal/errors"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/metrics"
+	"indico-backend/internal/models"
+)
+
+// WaitingRoom spreads the rush for high-demand products over time. Orders
+// for a product behind it need a queue token, and each replica issues a
+// product's tokens with admission times spaced 1/rate apart, so however
+// many buyers arrive at once their orders reach Postgres at a steady rate.
+// Tokens are signed rather than stored: any replica can check one, and a
+// token names its product and buyer so it cannot be passed on.
+type WaitingRoom struct {
+	config   *config.WaitingRoomConfig
+	products map[int]bool
+	interval time.Duration
+
+	mu sync.Mutex
+	// next is when each product's next token is admitted; issued holds each
+	// product's unexpired tokens by buyer, so asking again keeps the place
+	next   map[int]time.Time
+	issued map[int]map[string]*models.QueueToken
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// queueTokenClaims is the signed content of a queue token
+type queueTokenClaims struct {
+	ProductID int    `json:"p"`
+	BuyerID   string `json:"b"`
+	AdmitAt   int64  `json:"a"`
+	ExpiresAt int64  `json:"e"`
+}
+
+// NewWaitingRoom creates the waiting room for the configured products
+func NewWaitingRoom(cfg *config.WaitingRoomConfig) (*WaitingRoom, error) {
+	ids, err := cfg.ProductIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	w := &WaitingRoom{
+		config:   cfg,
+		products: make(map[int]bool, len(ids)),
+		interval: time.Second / time.Duration(cfg.Rate),
+		next:     make(map[int]time.Time),
+		issued:   make(map[int]map[string]*models.QueueToken),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	for _, id := range ids {
+		w.products[id] = true
+	}
+	return w, nil
+}
+
+// Start starts dropping expired tokens
+func (w *WaitingRoom) Start() {
+	logger.WithComponent("waiting_room").
+		WithField("products", len(w.products)).
+		WithField("rate", w.config.Rate).
+		Info("Starting waiting room")
+
+	w.wg.Add(1)
+	go w.run()
+}
+
+// Stop stops dropping expired tokens
+func (w *WaitingRoom) Stop() {
+	w.cancel()
+	w.wg.Wait()
+}
+
+func (w *WaitingRoom) run() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.config.TokenTTL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case now := <-ticker.C:
+			w.prune(now)
+		}
+	}
+}
+
+// prune forgets expired tokens; their buyers get a new place if they ask
+func (w *WaitingRoom) prune(now time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for productID, tokens := range w.issued {
+		for buyerID, token := range tokens {
+			if !now.Before(token.ExpiresAt) {
+				delete(tokens, buyerID)
+			}
+		}
+		if len(tokens) == 0 {
+			delete(w.issued, productID)
+		}
+	}
+}
+
+// Guards reports whether orders for a product need a queue token; a nil
+// WaitingRoom guards none
+func (w *WaitingRoom) Guards(productID int) bool {
+	return w != nil && w.products[productID]
+}
+
+// Issue gives a buyer a place in a product's waiting room: the next
+// admission time, 1/rate after the previous token's. A buyer asking again
+// before its token expires gets the same token. It fails with a waiting
+// room full error when the place would be more than the maximum wait away.
+func (w *WaitingRoom) Issue(productID int, buyerID string, now time.Time) (*models.QueueToken, error) {
+	if !w.Guards(productID) {
+		return nil, errors.NewValidationError("the product has no waiting room")
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if token := w.issued[productID][buyerID]; token != nil && now.Before(token.ExpiresAt) {
+		metrics.WaitingRoomTokens.WithLabelValues("reissued").Inc()
+		return token, nil
+	}
+
+	admitAt := w.next[productID]
+	if admitAt.Before(now) {
+		admitAt = now
+	}
+	if wait := admitAt.Sub(now); wait > w.config.MaxWait {
+		metrics.WaitingRoomTokens.WithLabelValues("full").Inc()
+		return nil, errors.NewWaitingRoomFullError(wait - w.config.MaxWait)
+	}
+	w.next[productID] = admitAt.Add(w.interval)
+
+	token := &models.QueueToken{
+		ProductID: productID,
+		BuyerID:   buyerID,
+		AdmitAt:   admitAt.UTC(),
+		ExpiresAt: admitAt.Add(w.config.TokenTTL).UTC(),
+	}
+	token.Token = w.sign(&queueTokenClaims{
+		ProductID: productID,
+		BuyerID:   buyerID,
+		AdmitAt:   token.AdmitAt.UnixMilli(),
+		ExpiresAt: token.ExpiresAt.UnixMilli(),
+	})
+
+	if w.issued[productID] == nil {
+		w.issued[productID] = make(map[string]*models.QueueToken)
+	}
+	w.issued[productID][buyerID] = token
+	metrics.WaitingRoomTokens.WithLabelValues("issued").Inc()
+	return token, nil
+}
+
+// Admit checks that a token admits an order for productID by buyerID at
+// now. Orders for products outside the waiting room need no token.
+func (w *WaitingRoom) Admit(token string, productID int, buyerID string, now time.Time) error {
+	if !w.Guards(productID) {
+		return nil
+	}
+
+	if token == "" {
+		metrics.WaitingRoomChecks.WithLabelValues("missing").Inc()
+		return errors.ErrQueueTokenRequired
+	}
+	claims, ok := w.verify(token)
+	if !ok || claims.ProductID != productID || claims.BuyerID != buyerID {
+		metrics.WaitingRoomChecks.WithLabelValues("invalid").Inc()
+		return errors.ErrQueueTokenInvalid
+	}
+
+	admitAt := time.UnixMilli(claims.AdmitAt)
+	if now.Before(admitAt) {
+		metrics.WaitingRoomChecks.WithLabelValues("early").Inc()
+		return errors.NewNotAdmittedError(admitAt.Sub(now))
+	}
+	if !now.Before(time.UnixMilli(claims.ExpiresAt)) {
+		metrics.WaitingRoomChecks.WithLabelValues("expired").Inc()
+		return errors.ErrQueueTokenExpired
+	}
+
+	metrics.WaitingRoomChecks.WithLabelValues("admitted").Inc()
+	return nil
+}
+
+// sign encodes claims as a token: the claims and their HMAC-SHA256, each
+// base64url encoded, joined by a dot
+func (w *WaitingRoom) sign(claims *queueTokenClaims) string {
+	payload, _ := json.Marshal(claims)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(w.mac(encoded))
+}
+
+// verify decodes a token signed with the waiting room's secret
+func (w *WaitingRoom) verify(token string) (*queueTokenClaims, bool) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, false
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(decoded, w.mac(encoded)) {
+		return nil, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, false
+	}
+	var claims queueTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, false
+	}
+	return &claims, true
+}
+
+func (w *WaitingRoom) mac(payload string) []byte {
+	h := hmac.New(sha256.New, []byte(w.config.Secret))
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
+
+// waitingRoomService implements WaitingRoomService
+type waitingRoomService struct {
+	room *WaitingRoom
+}
+
+// NewWaitingRoomService creates a new waiting room service
+func NewWaitingRoomService(deps *Dependencies) WaitingRoomService {
+	return &waitingRoomService{room: deps.WaitingRoom}
+}
+
+func (s *waitingRoomService) CreateQueueToken(ctx context.Context, req *models.CreateQueueTokenRequest) (*models.QueueToken, error) {
+	token, err := s.room.Issue(req.ProductID, req.BuyerID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	logger.WithContext(ctx).
+		WithField("product_id", req.ProductID).
+		WithField("admit_at", token.AdmitAt).
+		Debug("Queue token issued")
+
+	return token, nil
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"indico-backend/internal/config"
+	apperrors "indico-backend/internal/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestWaitingRoom(t *testing.T) *WaitingRoom {
+	t.Helper()
+
+	room, err := NewWaitingRoom(&config.WaitingRoomConfig{
+		Products: []string{"1"},
+		Rate:     10,
+		TokenTTL: time.Minute,
+		MaxWait:  time.Second,
+		Secret:   "0123456789abcdef0123456789abcdef",
+	})
+	require.NoError(t, err)
+	return room
+}
+
+func TestWaitingRoomIssue(t *testing.T) {
+	room := newTestWaitingRoom(t)
+	now := time.Date(2026, 11, 11, 0, 0, 0, 0, time.UTC)
+
+	// Places are spaced 1/rate apart, starting now
+	first, err := room.Issue(1, "buyer-1", now)
+	require.NoError(t, err)
+	assert.Equal(t, now, first.AdmitAt)
+	assert.Equal(t, now.Add(time.Minute), first.ExpiresAt)
+
+	second, err := room.Issue(1, "buyer-2", now)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(100*time.Millisecond), second.AdmitAt)
+
+	// Asking again keeps the buyer's place
+	again, err := room.Issue(1, "buyer-1", now.Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, first.Token, again.Token)
+
+	// Places further than the maximum wait away are refused
+	for i := 0; i < 9; i++ {
+		_, err := room.Issue(1, fmt.Sprintf("buyer-%d", i+3), now)
+		require.NoError(t, err)
+	}
+	_, err = room.Issue(1, "buyer-late", now)
+	appErr, ok := apperrors.IsAppError(err)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusServiceUnavailable, appErr.StatusCode)
+	assert.Positive(t, appErr.RetryAfter)
+
+	// Products outside the waiting room issue no tokens
+	_, err = room.Issue(2, "buyer-1", now)
+	assert.Error(t, err)
+}
+
+func TestWaitingRoomAdmit(t *testing.T) {
+	room := newTestWaitingRoom(t)
+	now := time.Date(2026, 11, 11, 0, 0, 0, 0, time.UTC)
+
+	_, err := room.Issue(1, "buyer-1", now)
+	require.NoError(t, err)
+	token, err := room.Issue(1, "buyer-2", now)
+	require.NoError(t, err)
+
+	assert.NoError(t, room.Admit(token.Token, 1, "buyer-2", token.AdmitAt))
+
+	// Too early, the order is told when to come back
+	err = room.Admit(token.Token, 1, "buyer-2", now)
+	appErr, ok := apperrors.IsAppError(err)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusTooManyRequests, appErr.StatusCode)
+	assert.Equal(t, 100*time.Millisecond, appErr.RetryAfter)
+
+	assert.ErrorIs(t, room.Admit(token.Token, 1, "buyer-2", token.ExpiresAt), apperrors.ErrQueueTokenExpired)
+	assert.ErrorIs(t, room.Admit("", 1, "buyer-2", token.AdmitAt), apperrors.ErrQueueTokenRequired)
+
+	// Tokens are bound to their product and buyer and cannot be altered
+	assert.ErrorIs(t, room.Admit(token.Token, 1, "buyer-1", token.AdmitAt), apperrors.ErrQueueTokenInvalid)
+	tampered := []byte(token.Token)
+	tampered[0] ^= 1
+	assert.ErrorIs(t, room.Admit(string(tampered), 1, "buyer-2", token.AdmitAt), apperrors.ErrQueueTokenInvalid)
+	assert.ErrorIs(t, room.Admit("not-a-token", 1, "buyer-2", token.AdmitAt), apperrors.ErrQueueTokenInvalid)
+
+	// Other products, and a nil waiting room, need no token
+	assert.NoError(t, room.Admit("", 2, "buyer-2", now))
+	var none *WaitingRoom
+	assert.NoError(t, none.Admit("", 1, "buyer-2", now))
+}
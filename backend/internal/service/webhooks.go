@@ -0,0 +1,134 @@
+// Package service provides webhook endpoint management
+package service
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"indico-backend/internal/config"
+	"indico-backend/internal/errors"
+	"indico-backend/internal/events"
+	"indico-backend/internal/logger"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+	"indico-backend/internal/webhook"
+
+	"github.com/google/uuid"
+)
+
+// defaultWebhookDeliveryTimeout bounds callbacks when no webhook
+// configuration is given
+const defaultWebhookDeliveryTimeout = 10 * time.Second
+
+// newWebhookSender creates a sender whose callbacks time out and present a
+// client certificate as configured for webhooks
+func newWebhookSender(cfg *config.WebhookConfig) *webhook.Sender {
+	if cfg == nil {
+		return webhook.NewSender(defaultWebhookDeliveryTimeout, nil)
+	}
+	return webhook.NewSender(cfg.DeliveryTimeout, cfg.ClientTLS.TLSConfig())
+}
+
+// webhookService implements WebhookService
+type webhookService struct {
+	webhookRepo repository.WebhookRepository
+	sender      *webhook.Sender
+}
+
+// NewWebhookService creates a new webhook service
+func NewWebhookService(deps *Dependencies) WebhookService {
+	return &webhookService{
+		webhookRepo: deps.WebhookRepo,
+		sender:      newWebhookSender(deps.WebhookConfig),
+	}
+}
+
+// CreateEndpoint registers a receiver with a newly generated signing
+// secret, which is returned only here
+func (s *webhookService) CreateEndpoint(ctx context.Context, req *models.CreateWebhookEndpointRequest) (*models.WebhookEndpoint, error) {
+	target, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || (target.Scheme != "https" && target.Scheme != "http") || target.Host == "" {
+		return nil, errors.NewValidationError("url must be an absolute http or https URL")
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := &models.WebhookEndpoint{
+		ID:          uuid.New(),
+		URL:         target.String(),
+		Secret:      secret,
+		Description: strings.TrimSpace(req.Description),
+	}
+	if err := s.webhookRepo.Create(ctx, endpoint); err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to create webhook endpoint")
+		return nil, err
+	}
+
+	logger.WithContext(ctx).
+		WithField("webhook_id", endpoint.ID).
+		WithField("host", target.Host).
+		Info("Webhook endpoint created")
+
+	return endpoint, nil
+}
+
+func (s *webhookService) ListEndpoints(ctx context.Context) ([]*models.WebhookEndpoint, error) {
+	endpoints, err := s.webhookRepo.List(ctx)
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).Error("Failed to list webhook endpoints")
+		return nil, err
+	}
+
+	return endpoints, nil
+}
+
+func (s *webhookService) DeleteEndpoint(ctx context.Context, id uuid.UUID) error {
+	if err := s.webhookRepo.Delete(ctx, id); err != nil {
+		if err != errors.ErrWebhookNotFound {
+			logger.WithContext(ctx).WithError(err).Error("Failed to delete webhook endpoint")
+		}
+		return err
+	}
+
+	logger.WithContext(ctx).WithField("webhook_id", id).Info("Webhook endpoint deleted")
+	return nil
+}
+
+// Ping sends a signed webhook.ping.v1 callback to an endpoint. A receiver
+// that can't be reached, or answers with an error status, is reported in the
+// result rather than as an error, since that is what the ping checks.
+func (s *webhookService) Ping(ctx context.Context, id uuid.UUID) (*models.WebhookPing, error) {
+	endpoint, err := s.webhookRepo.GetByID(ctx, id)
+	if err != nil {
+		if err != errors.ErrWebhookNotFound {
+			logger.WithContext(ctx).WithError(err).Error("Failed to get webhook endpoint")
+		}
+		return nil, err
+	}
+
+	event := events.NewWebhookPing(endpoint.ID)
+	ping := &models.WebhookPing{EventID: event.ID}
+
+	started := time.Now()
+	delivery, err := s.sender.Send(ctx, endpoint.URL, endpoint.Secret, event)
+	ping.DurationMs = time.Since(started).Milliseconds()
+	if err != nil {
+		ping.Error = err.Error()
+	} else {
+		ping.StatusCode = delivery.StatusCode
+		ping.Delivered = delivery.StatusCode >= 200 && delivery.StatusCode < 300
+	}
+
+	logger.WithContext(ctx).
+		WithField("webhook_id", endpoint.ID).
+		WithField("delivered", ping.Delivered).
+		WithField("status_code", ping.StatusCode).
+		Info("Webhook ping sent")
+
+	return ping, nil
+}
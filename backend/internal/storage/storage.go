@@ -0,0 +1,90 @@
+// Package storage serves stored job output files by key
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// Object is an open stored file
+type Object interface {
+	io.ReadSeekCloser
+	Stat() (fs.FileInfo, error)
+}
+
+// Store opens stored files by key, a slash-separated path relative to the
+// store such as "<job id>.csv" or "<job id>/<file name>". A key can only
+// name a file inside the store: keys with ".." elements, absolute keys and
+// symlinks leading out of it are refused, whoever built the key.
+type Store interface {
+	// Open opens the file stored under key. The error wraps fs.ErrNotExist
+	// when there is none.
+	Open(key string) (Object, error)
+	// Ping checks that files can be stored
+	Ping(ctx context.Context) error
+}
+
+// Local stores files in a local directory
+type Local struct {
+	dir string
+}
+
+// NewLocal creates a store for the files under dir. The directory need not
+// exist yet; until it does, every key is missing.
+func NewLocal(dir string) *Local {
+	return &Local{dir: dir}
+}
+
+// Dir returns the directory holding the store's files, where job output
+// is written
+func (l *Local) Dir() string {
+	return l.dir
+}
+
+// Ping checks that the store's directory exists, creating it if needed,
+// and that files can be written to it
+func (l *Local) Ping(ctx context.Context) error {
+	if err := os.MkdirAll(l.dir, 0755); err != nil {
+		return fmt.Errorf("storage directory: %w", err)
+	}
+
+	f, err := os.CreateTemp(l.dir, ".health-*")
+	if err != nil {
+		return fmt.Errorf("storage directory not writable: %w", err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// Open opens a file under the store's directory. The lookup goes through an
+// os.Root, so the file system itself refuses to leave the directory.
+func (l *Local) Open(key string) (Object, error) {
+	if !fs.ValidPath(key) || key == "." {
+		return nil, &fs.PathError{Op: "open", Path: key, Err: fs.ErrNotExist}
+	}
+
+	root, err := os.OpenRoot(l.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage directory: %w", err)
+	}
+	defer root.Close()
+
+	file, err := root.Open(key)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		file.Close()
+		return nil, &fs.PathError{Op: "open", Path: key, Err: fs.ErrNotExist}
+	}
+	return file, nil
+}
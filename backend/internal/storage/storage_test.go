@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalOpen(t *testing.T) {
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "settlements")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "job"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "job.csv"), []byte("merchant_id\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "job", "a.csv"), []byte("a\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(parent, "secret"), []byte("secret\n"), 0644))
+	require.NoError(t, os.Symlink(filepath.Join(parent, "secret"), filepath.Join(dir, "link.csv")))
+
+	store := NewLocal(dir)
+
+	for key, want := range map[string]string{"job.csv": "merchant_id\n", "job/a.csv": "a\n"} {
+		object, err := store.Open(key)
+		require.NoError(t, err, key)
+		data, err := io.ReadAll(object)
+		require.NoError(t, err)
+		object.Close()
+		assert.Equal(t, want, string(data))
+	}
+
+	for _, key := range []string{"", ".", "job", "missing.csv", "../secret", "job/../../secret", "/etc/passwd", parent + "/secret"} {
+		_, err := store.Open(key)
+		assert.ErrorIs(t, err, fs.ErrNotExist, key)
+	}
+
+	// A symlink out of the directory is refused by the file system
+	_, err := store.Open("link.csv")
+	assert.Error(t, err)
+
+	_, err = NewLocal(filepath.Join(parent, "missing")).Open("job.csv")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestLocalPing(t *testing.T) {
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "settlements")
+
+	// The directory is created, and the probe file cleaned up
+	require.NoError(t, NewLocal(dir).Ping(context.Background()))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	// A file in the way of the directory fails the check
+	require.NoError(t, os.WriteFile(filepath.Join(parent, "file"), nil, 0644))
+	assert.Error(t, NewLocal(filepath.Join(parent, "file")).Ping(context.Background()))
+}
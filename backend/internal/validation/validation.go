@@ -0,0 +1,74 @@
+// Package validation provides the input checks shared by request binding
+// and the services
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"indico-backend/internal/errors"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// MaxIdentifierLength is the longest buyer or merchant ID, the width of
+// their columns
+const MaxIdentifierLength = 255
+
+// identifierPattern admits IDs such as buyer_123, merchant-7 or
+// user@example.com. Starting with a letter or digit keeps IDs from being
+// read as formulas when exported to CSV.
+var identifierPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.:@-]*$`)
+
+// IsIdentifier reports whether s is a well-formed buyer or merchant ID
+func IsIdentifier(s string) bool {
+	return len(s) <= MaxIdentifierLength && identifierPattern.MatchString(s)
+}
+
+// IsPrintable reports whether s is valid UTF-8 without control characters,
+// such as a name or a note
+func IsPrintable(s string) bool {
+	if !utf8.ValidString(s) {
+		return false
+	}
+	for _, r := range s {
+		if unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// ID checks a buyer or merchant ID that did not come through request
+// binding, such as a path parameter. It only rejects absurd lengths and
+// control characters, so IDs stored before the identifier rules still
+// resolve.
+func ID(field, value string) error {
+	if value == "" || len(value) > MaxIdentifierLength || !IsPrintable(value) {
+		return errors.NewValidationError(fmt.Sprintf("%s must be 1 to %d printable characters", field, MaxIdentifierLength))
+	}
+	return nil
+}
+
+var registerOnce sync.Once
+
+// RegisterBinding adds the identifier and printable tags to the validator
+// request bodies are bound with
+func RegisterBinding() {
+	registerOnce.Do(func() {
+		v, ok := binding.Validator.Engine().(*validator.Validate)
+		if !ok {
+			return
+		}
+		_ = v.RegisterValidation("identifier", func(fl validator.FieldLevel) bool {
+			return IsIdentifier(fl.Field().String())
+		})
+		_ = v.RegisterValidation("printable", func(fl validator.FieldLevel) bool {
+			return IsPrintable(fl.Field().String())
+		})
+	})
+}
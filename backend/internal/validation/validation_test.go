@@ -0,0 +1,42 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+
+	"indico-backend/internal/models"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsIdentifier(t *testing.T) {
+	for _, id := range []string{"buyer_123", "merchant-7", "user@example.com", "erased-3f2a", "A"} {
+		assert.True(t, IsIdentifier(id), id)
+	}
+	for _, id := range []string{"", "=HYPERLINK(\"x\")", "+1", "-1", "@SUM(A1)", "buyer 1", "buyer\n1", "bü", strings.Repeat("a", MaxIdentifierLength+1)} {
+		assert.False(t, IsIdentifier(id), id)
+	}
+}
+
+func TestIsPrintable(t *testing.T) {
+	for _, s := range []string{"", "Kaos Hitam (M)", "Café 11.11", "=1+1"} {
+		assert.True(t, IsPrintable(s), s)
+	}
+	for _, s := range []string{"line\nbreak", "tab\there", "nul\x00", "bell\a", "\x85", string([]byte{0xff})} {
+		assert.False(t, IsPrintable(s), s)
+	}
+}
+
+func TestRegisterBinding(t *testing.T) {
+	RegisterBinding()
+
+	valid := &models.CreateOrderRequest{ProductID: 1, Quantity: 1, BuyerID: "buyer_1"}
+	assert.NoError(t, binding.Validator.ValidateStruct(valid))
+
+	invalid := &models.CreateOrderRequest{ProductID: 1, Quantity: 1, BuyerID: "=cmd|'/c calc'!A1"}
+	assert.Error(t, binding.Validator.ValidateStruct(invalid))
+
+	named := &models.CreateSalesEventRequest{Name: "Sale\r\n", PerBuyerLimit: 1, ProductIDs: []int{1}}
+	assert.ErrorContains(t, binding.Validator.ValidateStruct(named), "printable")
+}
@@ -0,0 +1,353 @@
+// Package verify recomputes settlements from raw transactions and compares
+// them to stored settlements and a generated CSV
+package verify
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"indico-backend/internal/calendar"
+	"indico-backend/internal/config"
+	"indico-backend/internal/csvsafe"
+	"indico-backend/internal/database"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+)
+
+// SettlementKey identifies a settlement row
+type SettlementKey struct {
+	MerchantID string
+	Date       string
+}
+
+// totals holds the figures compared for each settlement
+type totals struct {
+	GrossCents int
+	FeeCents   int
+	NetCents   int
+	TxnCount   int
+}
+
+// Discrepancy describes one difference between expected and actual totals
+type Discrepancy struct {
+	Source   string
+	Key      SettlementKey
+	Field    string
+	Expected string
+	Actual   string
+}
+
+// Result is the outcome of verifying a range of settlements
+type Result struct {
+	Discrepancies []Discrepancy
+	// Checked is the number of settlements expected in the range
+	Checked int
+}
+
+// OK reports whether every settlement matched
+func (r *Result) OK() bool {
+	return len(r.Discrepancies) == 0
+}
+
+// Settlements recomputes the settlements dated from through to (inclusive)
+// from raw transactions and compares them to the stored settlements and,
+// when csvPath is set, to a settlement CSV generated for the same range
+func Settlements(ctx context.Context, db *database.DB, settleCfg *config.SettlementConfig, from, to time.Time, csvPath string) (*Result, error) {
+	end := to.AddDate(0, 0, 1)
+
+	stored, cutoffs, err := storedTotals(ctx, repository.NewSettlementRepository(db.DB), from, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stored settlements: %w", err)
+	}
+
+	expected, err := expectedTotals(ctx, repository.NewTransactionRepository(db.DB), repository.NewCalendarRepository(db.DB), settleCfg, cutoffs, from, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to recompute settlements: %w", err)
+	}
+
+	discrepancies := compare("stored", expected, stored)
+
+	if csvPath != "" {
+		fromCSV, err := csvTotals(csvPath, from, end)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read settlement CSV: %w", err)
+		}
+		discrepancies = append(discrepancies, compare("csv", expected, fromCSV)...)
+	}
+
+	return &Result{Discrepancies: discrepancies, Checked: len(expected)}, nil
+}
+
+// expectedTotals recomputes settlements straight from the transactions table,
+// rolling non-business days the same way the settlement job does. Like the
+// job, it leaves out transactions recorded after a day's cutoff: the cutoff
+// its stored settlement was computed with, from cutoffs, or else the
+// configured one.
+func expectedTotals(
+	ctx context.Context,
+	txRepo repository.TransactionReader,
+	calendarRepo repository.CalendarRepository,
+	settleCfg *config.SettlementConfig,
+	cutoffs map[SettlementKey]*time.Time,
+	from, end time.Time,
+) (map[SettlementKey]totals, error) {
+	holidays, err := calendarRepo.ListHolidays(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	assignments, err := calendarRepo.ListMerchantCalendars(ctx)
+	if err != nil {
+		return nil, err
+	}
+	book := calendar.NewBook(strings.ToUpper(strings.TrimSpace(settleCfg.DefaultRegion)), holidays, assignments)
+
+	daily, err := txRepo.AggregateByMerchantAndDay(ctx, "", book.FirstContributingDay(from), end, nil)
+	if err != nil {
+		return nil, err
+	}
+	result := sumByKey(book, daily, from, end)
+
+	// Only days with a transaction recorded after their cutoff differ from
+	// the totals above, and those are recomputed without the late ones
+	activity, err := txRepo.LatestActivityDaily(ctx, book.FirstContributingDay(from), end)
+	if err != nil {
+		return nil, err
+	}
+	latest := make(map[SettlementKey]time.Time)
+	for _, day := range activity {
+		date := book.SettlementDate(day.MerchantID, day.Date)
+		if date.Before(from) || !date.Before(end) {
+			continue
+		}
+		key := SettlementKey{MerchantID: day.MerchantID, Date: date.Format("2006-01-02")}
+		if day.LastCreatedAt.After(latest[key]) {
+			latest[key] = day.LastCreatedAt
+		}
+	}
+
+	for key, last := range latest {
+		date, err := time.Parse("2006-01-02", key.Date)
+		if err != nil {
+			return nil, err
+		}
+		cutoffAt, ok := cutoffs[key]
+		if !ok && settleCfg.Cutoff > 0 {
+			at := date.Add(settleCfg.Cutoff)
+			cutoffAt = &at
+		}
+		if cutoffAt == nil || !last.After(*cutoffAt) {
+			continue
+		}
+
+		first := book.ForMerchant(key.MerchantID).FirstContributingDay(date)
+		daily, err := txRepo.AggregateByMerchantAndDay(ctx, key.MerchantID, first, date.AddDate(0, 0, 1), cutoffAt)
+		if err != nil {
+			return nil, err
+		}
+		if t, ok := sumByKey(book, daily, date, date.AddDate(0, 0, 1))[key]; ok {
+			result[key] = t
+		} else {
+			delete(result, key)
+		}
+	}
+
+	return result, nil
+}
+
+// sumByKey rolls daily totals onto their settlement dates and sums those in
+// [from, end)
+func sumByKey(book *calendar.Book, daily []*models.Settlement, from, end time.Time) map[SettlementKey]totals {
+	result := make(map[SettlementKey]totals)
+	for _, day := range daily {
+		date := book.SettlementDate(day.MerchantID, day.Date)
+		if date.Before(from) || !date.Before(end) {
+			continue
+		}
+
+		key := SettlementKey{MerchantID: day.MerchantID, Date: date.Format("2006-01-02")}
+		t := result[key]
+		t.GrossCents += day.GrossCents
+		t.FeeCents += day.FeeCents
+		t.NetCents += day.NetCents
+		t.TxnCount += day.TxnCount
+		result[key] = t
+	}
+	return result
+}
+
+// storedTotals loads the current settlements in the range, along with the
+// cutoff each was computed with
+func storedTotals(ctx context.Context, settleRepo repository.SettlementReader, from, end time.Time) (map[SettlementKey]totals, map[SettlementKey]*time.Time, error) {
+	settlements, err := settleRepo.ListCurrent(ctx, from, end)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := make(map[SettlementKey]totals, len(settlements))
+	cutoffs := make(map[SettlementKey]*time.Time, len(settlements))
+	for _, s := range settlements {
+		key := SettlementKey{MerchantID: s.MerchantID, Date: s.Date.Format("2006-01-02")}
+		result[key] = totals{
+			GrossCents: s.GrossCents,
+			FeeCents:   s.FeeCents,
+			NetCents:   s.NetCents,
+			TxnCount:   s.TxnCount,
+		}
+		cutoffs[key] = s.CutoffAt
+	}
+
+	return result, cutoffs, nil
+}
+
+// settlementLineType marks the settlement lines of a settlement CSV; the
+// adjustment lines after them share their merchant and date
+const settlementLineType = "settlement"
+
+// csvTotals reads the settlement lines of a settlement CSV, keeping rows
+// dated in the range. A CSV without a line_type column has settlement lines
+// only.
+func csvTotals(path string, from, end time.Time) (map[SettlementKey]totals, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[name] = i
+	}
+	for _, name := range []string{"merchant_id", "date", "gross_cents", "fee_cents", "net_cents", "transaction_count"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("CSV is missing column %q", name)
+		}
+	}
+
+	result := make(map[SettlementKey]totals)
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if i, ok := columns["line_type"]; ok && record[i] != settlementLineType {
+			continue
+		}
+
+		date, err := time.Parse("2006-01-02", record[columns["date"]])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid date: %w", line, err)
+		}
+		if date.Before(from) || !date.Before(end) {
+			continue
+		}
+
+		var t totals
+		for field, dst := range map[string]*int{
+			"gross_cents":       &t.GrossCents,
+			"fee_cents":         &t.FeeCents,
+			"net_cents":         &t.NetCents,
+			"transaction_count": &t.TxnCount,
+		} {
+			if *dst, err = strconv.Atoi(record[columns[field]]); err != nil {
+				return nil, fmt.Errorf("line %d: invalid %s: %w", line, field, err)
+			}
+		}
+
+		// Merchant IDs a spreadsheet would evaluate were written escaped
+		key := SettlementKey{MerchantID: csvsafe.Unescape(record[columns["merchant_id"]]), Date: date.Format("2006-01-02")}
+		result[key] = t
+	}
+
+	return result, nil
+}
+
+// compare reports every difference between expected and actual totals
+func compare(source string, expected, actual map[SettlementKey]totals) []Discrepancy {
+	var discrepancies []Discrepancy
+
+	for key, want := range expected {
+		got, ok := actual[key]
+		if !ok {
+			discrepancies = append(discrepancies, Discrepancy{Source: source, Key: key, Field: "(missing)"})
+			continue
+		}
+
+		fields := []struct {
+			name      string
+			want, got int
+		}{
+			{"gross_cents", want.GrossCents, got.GrossCents},
+			{"fee_cents", want.FeeCents, got.FeeCents},
+			{"net_cents", want.NetCents, got.NetCents},
+			{"txn_count", want.TxnCount, got.TxnCount},
+		}
+		for _, f := range fields {
+			if f.want != f.got {
+				discrepancies = append(discrepancies, Discrepancy{
+					Source:   source,
+					Key:      key,
+					Field:    f.name,
+					Expected: strconv.Itoa(f.want),
+					Actual:   strconv.Itoa(f.got),
+				})
+			}
+		}
+	}
+
+	for key := range actual {
+		if _, ok := expected[key]; !ok {
+			discrepancies = append(discrepancies, Discrepancy{Source: source, Key: key, Field: "(unexpected)"})
+		}
+	}
+
+	sort.Slice(discrepancies, func(i, j int) bool {
+		a, b := discrepancies[i], discrepancies[j]
+		if a.Source != b.Source {
+			return a.Source > b.Source // stored before csv
+		}
+		if a.Key.MerchantID != b.Key.MerchantID {
+			return a.Key.MerchantID < b.Key.MerchantID
+		}
+		if a.Key.Date != b.Key.Date {
+			return a.Key.Date < b.Key.Date
+		}
+		return a.Field < b.Field
+	})
+
+	return discrepancies
+}
+
+// Report writes a diff report, or a one-line success summary
+func (r *Result) Report(w io.Writer) {
+	discrepancies, checked := r.Discrepancies, r.Checked
+	if len(discrepancies) == 0 {
+		fmt.Fprintf(w, "OK: %d settlements verified\n", checked)
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SOURCE\tMERCHANT\tDATE\tFIELD\tEXPECTED\tACTUAL")
+	for _, d := range discrepancies {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", d.Source, d.Key.MerchantID, d.Key.Date, d.Field, d.Expected, d.Actual)
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "FAIL: %d discrepancies across %d expected settlements\n", len(discrepancies), checked)
+}
@@ -0,0 +1,180 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"indico-backend/internal/config"
+	"indico-backend/internal/csvsafe"
+	"indico-backend/internal/models"
+	"indico-backend/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ledger is a transaction reader aggregating completed transactions kept in
+// memory
+type ledger struct {
+	repository.TransactionReader
+	transactions []*models.Transaction
+}
+
+func (l *ledger) AggregateByMerchantAndDay(ctx context.Context, merchantID string, from, to time.Time, cutoffAt *time.Time) ([]*models.Settlement, error) {
+	byDay := make(map[string]*models.Settlement)
+	var days []*models.Settlement
+	for _, txn := range l.transactions {
+		if (merchantID != "" && txn.MerchantID != merchantID) || txn.PaidAt.Before(from) || !txn.PaidAt.Before(to) {
+			continue
+		}
+		if cutoffAt != nil && txn.CreatedAt.After(*cutoffAt) {
+			continue
+		}
+		day := txn.PaidAt.UTC().Truncate(24 * time.Hour)
+		key := txn.MerchantID + day.String()
+		if byDay[key] == nil {
+			byDay[key] = &models.Settlement{MerchantID: txn.MerchantID, Date: day}
+			days = append(days, byDay[key])
+		}
+		byDay[key].GrossCents += txn.AmountCents
+		byDay[key].FeeCents += txn.FeeCents
+		byDay[key].NetCents += txn.AmountCents - txn.FeeCents
+		byDay[key].TxnCount++
+	}
+	return days, nil
+}
+
+func (l *ledger) LatestActivityDaily(ctx context.Context, from, to time.Time) ([]*models.MerchantDayActivity, error) {
+	var activity []*models.MerchantDayActivity
+	for _, txn := range l.transactions {
+		if txn.PaidAt.Before(from) || !txn.PaidAt.Before(to) {
+			continue
+		}
+		activity = append(activity, &models.MerchantDayActivity{
+			MerchantID:    txn.MerchantID,
+			Date:          txn.PaidAt.UTC().Truncate(24 * time.Hour),
+			LastCreatedAt: txn.CreatedAt,
+		})
+	}
+	return activity, nil
+}
+
+// noHolidays is a calendar repository where every weekday is a business day
+type noHolidays struct {
+	repository.CalendarRepository
+}
+
+func (noHolidays) ListHolidays(ctx context.Context, region string) ([]*models.Holiday, error) {
+	return nil, nil
+}
+
+func (noHolidays) ListMerchantCalendars(ctx context.Context) ([]*models.MerchantCalendar, error) {
+	return nil, nil
+}
+
+// writeCSV writes a settlement CSV with the given content
+func writeCSV(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "settlements.csv")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestCSVTotalsSkipsAdjustments(t *testing.T) {
+	path := writeCSV(t, `merchant_id,date,gross_cents,fee_cents,net_cents,transaction_count,generated_at,unique_run_id,line_type,reason_code
+m1,2025-01-02,10000,300,9700,2,2025-01-03T00:00:00Z,550e8400-e29b-41d4-a716-446655440000,settlement,
+m1,2025-01-02,0,0,-1500,0,2025-01-03T01:00:00Z,,adjustment,DISPUTE_LOST
+`)
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	fromCSV, err := csvTotals(path, from, from.AddDate(0, 0, 7))
+	require.NoError(t, err)
+
+	// The adjustment on the same day leaves the settlement's totals alone
+	want := map[SettlementKey]totals{
+		{MerchantID: "m1", Date: "2025-01-02"}: {GrossCents: 10000, FeeCents: 300, NetCents: 9700, TxnCount: 2},
+	}
+	assert.Equal(t, want, fromCSV)
+	assert.Empty(t, compare("csv", want, fromCSV))
+}
+
+func TestCSVTotalsWithoutLineType(t *testing.T) {
+	path := writeCSV(t, `merchant_id,date,gross_cents,fee_cents,net_cents,transaction_count
+m1,2025-01-02,10000,300,9700,2
+m1,2025-01-09,500,15,485,1
+`)
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	fromCSV, err := csvTotals(path, from, from.AddDate(0, 0, 7))
+	require.NoError(t, err)
+	assert.Equal(t, map[SettlementKey]totals{
+		{MerchantID: "m1", Date: "2025-01-02"}: {GrossCents: 10000, FeeCents: 300, NetCents: 9700, TxnCount: 2},
+	}, fromCSV)
+}
+
+func TestCSVTotalsUnescapesMerchantIDs(t *testing.T) {
+	// The CSV as the settlement job writes it, with formula-like IDs escaped
+	var buf bytes.Buffer
+	writer := csvsafe.NewWriter(&buf)
+	ids := []string{"=m1", "+m2", "-m3", "@m4", "m5"}
+	require.NoError(t, writer.Write([]string{"merchant_id", "date", "gross_cents", "fee_cents", "net_cents", "transaction_count", "line_type"}))
+	for _, id := range ids {
+		require.NoError(t, writer.Write([]string{id, "2025-01-02", "100", "3", "97", "1", settlementLineType}))
+	}
+	writer.Flush()
+	require.NoError(t, writer.Error())
+	path := writeCSV(t, buf.String())
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	fromCSV, err := csvTotals(path, from, from.AddDate(0, 0, 7))
+	require.NoError(t, err)
+
+	expected := make(map[SettlementKey]totals)
+	for _, id := range ids {
+		expected[SettlementKey{MerchantID: id, Date: "2025-01-02"}] = totals{GrossCents: 100, FeeCents: 3, NetCents: 97, TxnCount: 1}
+	}
+	assert.Empty(t, compare("csv", expected, fromCSV))
+}
+
+func TestExpectedTotalsLeavesOutLateTransactions(t *testing.T) {
+	// Thursday 2 January 2025, settled with a 36h cutoff
+	day := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	paid := day.Add(10 * time.Hour)
+	txns := &ledger{transactions: []*models.Transaction{
+		{MerchantID: "m1", AmountCents: 10000, FeeCents: 300, PaidAt: paid, CreatedAt: paid},
+		{MerchantID: "m1", AmountCents: 5000, FeeCents: 150, PaidAt: paid, CreatedAt: day.Add(48 * time.Hour)},
+		{MerchantID: "m2", AmountCents: 700, FeeCents: 21, PaidAt: paid, CreatedAt: day.Add(40 * time.Hour)},
+	}}
+	m1 := SettlementKey{MerchantID: "m1", Date: "2025-01-02"}
+	m2 := SettlementKey{MerchantID: "m2", Date: "2025-01-02"}
+	settleCfg := &config.SettlementConfig{Cutoff: 36 * time.Hour}
+
+	// The late transaction is left out, using the stored settlement's cutoff
+	storedCutoff := day.Add(36 * time.Hour)
+	expected, err := expectedTotals(context.Background(), txns, noHolidays{}, settleCfg,
+		map[SettlementKey]*time.Time{m1: &storedCutoff}, day, day.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	assert.Equal(t, totals{GrossCents: 10000, FeeCents: 300, NetCents: 9700, TxnCount: 1}, expected[m1])
+
+	// A day with only late transactions, which the job didn't settle, is not
+	// expected under the configured cutoff
+	_, ok := expected[m2]
+	assert.False(t, ok)
+
+	// A stored settlement computed without a cutoff counts every transaction
+	expected, err = expectedTotals(context.Background(), txns, noHolidays{}, settleCfg,
+		map[SettlementKey]*time.Time{m1: nil}, day, day.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	assert.Equal(t, totals{GrossCents: 15000, FeeCents: 450, NetCents: 14550, TxnCount: 2}, expected[m1])
+
+	// Without a cutoff configured, nothing is late
+	expected, err = expectedTotals(context.Background(), txns, noHolidays{}, &config.SettlementConfig{},
+		nil, day, day.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	assert.Equal(t, 2, expected[m1].TxnCount)
+	assert.Equal(t, 1, expected[m2].TxnCount)
+}
@@ -0,0 +1,134 @@
+package webhook
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"indico-backend/internal/events"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	const secret = "whsec_0123456789abcdef0123456789abcdef"
+	body := []byte(`{"type":"webhook.ping.v1"}`)
+	signedAt := time.Unix(1750000000, 0)
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+	signature := Sign(secret, signedAt, body)
+
+	tests := []struct {
+		name      string
+		secrets   []string
+		timestamp string
+		signature string
+		body      []byte
+		now       time.Time
+		want      error
+	}{
+		{"valid", []string{secret}, timestamp, signature, body, signedAt.Add(time.Minute), nil},
+		{"rotated secret", []string{"whsec_new", secret}, timestamp, signature, body, signedAt, nil},
+		{"one of several signatures", []string{secret}, timestamp, "v0=abc, " + signature, body, signedAt, nil},
+		{"tampered body", []string{secret}, timestamp, signature, []byte(`{"type":"other"}`), signedAt, ErrInvalidSignature},
+		{"wrong secret", []string{"whsec_other"}, timestamp, signature, body, signedAt, ErrInvalidSignature},
+		{"replayed timestamp", []string{secret}, strconv.FormatInt(signedAt.Unix()+1, 10), signature, body, signedAt, ErrInvalidSignature},
+		{"too old", []string{secret}, timestamp, signature, body, signedAt.Add(6 * time.Minute), ErrTimestampOutOfTolerance},
+		{"too far ahead", []string{secret}, timestamp, signature, body, signedAt.Add(-6 * time.Minute), ErrTimestampOutOfTolerance},
+		{"unsigned", []string{secret}, "", "", body, signedAt, ErrMissingSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secrets, tt.timestamp, tt.signature, tt.body, 5*time.Minute, tt.now)
+			assert.Equal(t, tt.want, err)
+		})
+	}
+}
+
+func TestSendSignsCallbacks(t *testing.T) {
+	const secret = "whsec_0123456789abcdef0123456789abcdef"
+	var verifyErr error
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		verifyErr = Verify([]string{secret}, r.Header.Get(TimestampHeader), r.Header.Get(SignatureHeader), body, time.Minute, time.Now())
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer receiver.Close()
+
+	delivery, err := NewSender(time.Second, nil).Send(context.Background(), receiver.URL, secret, events.NewWebhookPing(uuid.New()))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, delivery.StatusCode)
+	assert.NoError(t, verifyErr)
+}
+
+func TestSendPresentsClientCertificate(t *testing.T) {
+	// A CA issuing the sender's client certificate
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	clientDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "indico-backend"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, &clientKey.PublicKey, caKey)
+	require.NoError(t, err)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca)
+
+	var presented string
+	receiver := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		presented = r.TLS.PeerCertificates[0].Subject.CommonName
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	receiver.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	receiver.StartTLS()
+	defer receiver.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(receiver.Certificate())
+
+	// Without a client certificate the receiver refuses the connection
+	_, err = NewSender(time.Second, &tls.Config{RootCAs: roots}).Send(context.Background(), receiver.URL, "whsec_test", events.NewWebhookPing(uuid.New()))
+	require.Error(t, err)
+
+	sender := NewSender(time.Second, &tls.Config{
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{{Certificate: [][]byte{clientDER}, PrivateKey: clientKey}},
+	})
+	delivery, err := sender.Send(context.Background(), receiver.URL, "whsec_test", events.NewWebhookPing(uuid.New()))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, delivery.StatusCode)
+	assert.Equal(t, "indico-backend", presented)
+}
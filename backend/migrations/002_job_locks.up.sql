@@ -0,0 +1,12 @@
+-- Create job_locks table to prevent overlapping jobs from running concurrently
+CREATE TABLE IF NOT EXISTS job_locks (
+    job_id UUID PRIMARY KEY REFERENCES jobs (id) ON DELETE CASCADE,
+    job_type VARCHAR(50) NOT NULL,
+    range_from DATE NOT NULL,
+    range_to DATE NOT NULL,
+    acquired_at TIMESTAMP
+    WITH
+        TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_locks_type_range ON job_locks (job_type, range_from, range_to);
@@ -0,0 +1,26 @@
+-- Keep only the current version of each settlement before restoring uniqueness
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'settlements' AND column_name = 'superseded_by'
+    ) THEN
+        DELETE FROM settlements WHERE superseded_by IS NOT NULL;
+    END IF;
+END $$;
+
+DROP INDEX IF EXISTS idx_settlements_current;
+
+DROP INDEX IF EXISTS idx_settlements_merchant_date_run;
+
+DROP INDEX IF EXISTS idx_settlements_unique_run_id;
+
+ALTER TABLE settlements DROP COLUMN IF EXISTS superseded_by;
+
+ALTER TABLE settlements
+DROP CONSTRAINT IF EXISTS settlements_merchant_id_date_key;
+
+ALTER TABLE settlements
+ADD CONSTRAINT settlements_merchant_id_date_key UNIQUE (merchant_id, date);
+
+DROP TABLE IF EXISTS settlement_runs;
@@ -0,0 +1,27 @@
+-- Create settlement_runs table recording each immutable settlement run
+CREATE TABLE IF NOT EXISTS settlement_runs (
+    id UUID PRIMARY KEY,
+    job_id UUID REFERENCES jobs (id) ON DELETE CASCADE,
+    range_from DATE NOT NULL,
+    range_to DATE NOT NULL,
+    settlement_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP
+    WITH
+        TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Settlements become immutable versions: one row per merchant/date/run
+ALTER TABLE settlements
+DROP CONSTRAINT IF EXISTS settlements_merchant_id_date_key;
+
+ALTER TABLE settlements ADD COLUMN IF NOT EXISTS superseded_by UUID;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_settlements_merchant_date_run ON settlements (merchant_id, date, unique_run_id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_settlements_current ON settlements (merchant_id, date)
+WHERE
+    superseded_by IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_settlements_unique_run_id ON settlements (unique_run_id);
+
+CREATE INDEX IF NOT EXISTS idx_settlement_runs_created_at ON settlement_runs (created_at);
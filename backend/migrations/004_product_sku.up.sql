@@ -0,0 +1,12 @@
+-- Add SKU and barcode to products so they map to merchants' catalogs
+ALTER TABLE products ADD COLUMN IF NOT EXISTS sku VARCHAR(64);
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS barcode VARCHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_products_sku ON products (sku)
+WHERE
+    sku IS NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_products_barcode ON products (barcode)
+WHERE
+    barcode IS NOT NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_orders_product_id_created_at;
+
+DROP TABLE IF EXISTS reorder_recommendations;
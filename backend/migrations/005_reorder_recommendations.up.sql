@@ -0,0 +1,16 @@
+-- Create reorder_recommendations table holding reorder forecast job results
+CREATE TABLE IF NOT EXISTS reorder_recommendations (
+    id SERIAL PRIMARY KEY,
+    job_id UUID NOT NULL REFERENCES jobs (id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    daily_velocity DOUBLE PRECISION NOT NULL DEFAULT 0,
+    current_stock INTEGER NOT NULL DEFAULT 0,
+    reorder_point INTEGER NOT NULL DEFAULT 0,
+    recommended_quantity INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP
+    WITH
+        TIME ZONE NOT NULL DEFAULT NOW(),
+        UNIQUE (job_id, product_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_orders_product_id_created_at ON orders (product_id, created_at);
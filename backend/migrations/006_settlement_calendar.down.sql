@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS merchant_calendars;
+
+DROP TABLE IF EXISTS holidays;
@@ -0,0 +1,19 @@
+-- Create holidays table listing non-business days per settlement region
+CREATE TABLE IF NOT EXISTS holidays (
+    region VARCHAR(16) NOT NULL,
+    date DATE NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP
+    WITH
+        TIME ZONE NOT NULL DEFAULT NOW(),
+        PRIMARY KEY (region, date)
+);
+
+-- Create merchant_calendars table assigning merchants to settlement regions
+CREATE TABLE IF NOT EXISTS merchant_calendars (
+    merchant_id VARCHAR(255) PRIMARY KEY,
+    region VARCHAR(16) NOT NULL,
+    updated_at TIMESTAMP
+    WITH
+        TIME ZONE NOT NULL DEFAULT NOW()
+);
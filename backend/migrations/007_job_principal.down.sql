@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_jobs_created_by_status;
+
+ALTER TABLE jobs DROP COLUMN IF EXISTS created_by;
@@ -0,0 +1,6 @@
+-- Record which client created a job so active jobs can be limited per client
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS created_by VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_created_by_status ON jobs (created_by, status)
+WHERE
+    created_by IS NOT NULL;
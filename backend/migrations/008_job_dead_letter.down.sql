@@ -0,0 +1,3 @@
+ALTER TABLE jobs DROP COLUMN IF EXISTS dead_lettered_at;
+
+ALTER TABLE jobs DROP COLUMN IF EXISTS attempts;
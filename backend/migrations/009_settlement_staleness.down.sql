@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_settlements_stale;
+
+ALTER TABLE settlements DROP COLUMN IF EXISTS stale_reason;
+
+ALTER TABLE settlements DROP COLUMN IF EXISTS stale_since;
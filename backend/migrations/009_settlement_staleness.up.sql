@@ -0,0 +1,9 @@
+-- Flag current settlements invalidated by transaction status changes
+ALTER TABLE settlements ADD COLUMN IF NOT EXISTS stale_since TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE settlements ADD COLUMN IF NOT EXISTS stale_reason TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_settlements_stale ON settlements (stale_since)
+WHERE
+    stale_since IS NOT NULL
+    AND superseded_by IS NULL;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_sagas_status;
+
+DROP INDEX IF EXISTS idx_sagas_unfinished;
+
+DROP TABLE IF EXISTS sagas;
@@ -0,0 +1,23 @@
+-- Persist saga state so multi-step workflows can be compensated after a crash
+CREATE TABLE IF NOT EXISTS sagas (
+    id UUID PRIMARY KEY,
+    type VARCHAR(50) NOT NULL,
+    status VARCHAR(50) NOT NULL DEFAULT 'RUNNING',
+    payload JSONB NOT NULL DEFAULT '{}',
+    completed_steps INTEGER NOT NULL DEFAULT 0,
+    failed_step VARCHAR(100),
+    error TEXT,
+    created_at TIMESTAMP
+    WITH
+        TIME ZONE NOT NULL DEFAULT NOW(),
+        updated_at TIMESTAMP
+    WITH
+        TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Recovery scans for sagas left unfinished; completed ones are the vast majority
+CREATE INDEX IF NOT EXISTS idx_sagas_unfinished ON sagas (updated_at)
+WHERE
+    status <> 'COMPLETED';
+
+CREATE INDEX IF NOT EXISTS idx_sagas_status ON sagas (status, created_at);
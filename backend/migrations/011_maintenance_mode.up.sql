@@ -0,0 +1,11 @@
+-- Maintenance mode lives in the database so every replica sees the toggle
+CREATE TABLE IF NOT EXISTS maintenance_mode (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    message TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMP
+    WITH
+        TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO maintenance_mode (id) VALUES (TRUE) ON CONFLICT (id) DO NOTHING;
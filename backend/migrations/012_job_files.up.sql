@@ -0,0 +1,13 @@
+-- Jobs that produce several output files list them here; downloads can
+-- fetch one file or stream all of them as a ZIP
+CREATE TABLE IF NOT EXISTS job_files (
+    job_id UUID NOT NULL REFERENCES jobs (id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    path TEXT NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size_bytes BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP
+    WITH
+        TIME ZONE NOT NULL DEFAULT NOW(),
+        PRIMARY KEY (job_id, name)
+);
@@ -0,0 +1,23 @@
+-- Execution stats of each finished job, kept so pipeline performance can be
+-- compared across runs long after metrics retention has expired
+CREATE TABLE IF NOT EXISTS job_stats (
+    job_id UUID PRIMARY KEY REFERENCES jobs (id) ON DELETE CASCADE,
+    job_type VARCHAR(50) NOT NULL,
+    status VARCHAR(50) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 1,
+    rows_processed BIGINT NOT NULL DEFAULT 0,
+    batches INTEGER NOT NULL DEFAULT 0,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    db_time_ms BIGINT NOT NULL DEFAULT 0,
+    compute_time_ms BIGINT NOT NULL DEFAULT 0,
+    rows_per_second DOUBLE PRECISION NOT NULL DEFAULT 0,
+    peak_memory_bytes BIGINT NOT NULL DEFAULT 0,
+    started_at TIMESTAMP
+    WITH
+        TIME ZONE NOT NULL,
+        finished_at TIMESTAMP
+    WITH
+        TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_stats_type_finished ON job_stats (job_type, finished_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_orders_client_reference;
+
+ALTER TABLE orders DROP COLUMN IF EXISTS client_reference;
@@ -0,0 +1,7 @@
+-- Clients may tag orders with their own reference, unique across orders, so
+-- they can find an order again after a timeout without knowing its ID
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS client_reference VARCHAR(255);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_orders_client_reference ON orders (client_reference)
+WHERE
+    client_reference IS NOT NULL;
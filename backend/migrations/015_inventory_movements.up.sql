@@ -0,0 +1,16 @@
+-- Every absolute stock change applied from the warehouse system is recorded
+-- here, so stock levels can be reconciled against the WMS after the fact
+CREATE TABLE IF NOT EXISTS inventory_movements (
+    id BIGSERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products (id),
+    sku VARCHAR(64) NOT NULL,
+    previous_stock INTEGER NOT NULL,
+    new_stock INTEGER NOT NULL,
+    delta INTEGER NOT NULL,
+    reason VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP
+    WITH
+        TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_movements_product_created ON inventory_movements (product_id, created_at);
@@ -0,0 +1,2 @@
+-- Snapshot polling pages through products changed since a timestamp
+CREATE INDEX IF NOT EXISTS idx_products_updated_at ON products (updated_at, id);
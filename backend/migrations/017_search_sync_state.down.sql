@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_orders_updated_at;
+
+DROP TABLE IF EXISTS search_sync_state;
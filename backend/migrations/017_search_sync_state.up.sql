@@ -0,0 +1,13 @@
+-- How far the search indexer has copied each table, so a restart resumes
+-- where it left off instead of reindexing everything
+CREATE TABLE IF NOT EXISTS search_sync_state (
+    index_name VARCHAR(50) PRIMARY KEY,
+    synced_until TIMESTAMP
+    WITH
+        TIME ZONE NOT NULL,
+        updated_at TIMESTAMP
+    WITH
+        TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_orders_updated_at ON orders (updated_at, id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS projection_state;
+
+DROP TABLE IF EXISTS order_analytics;
@@ -0,0 +1,34 @@
+-- Denormalized copy of orders with their product and day, maintained by the
+-- background projector so dashboards don't aggregate the OLTP tables
+CREATE TABLE IF NOT EXISTS order_analytics (
+    order_id UUID PRIMARY KEY,
+    product_id INTEGER NOT NULL,
+    product_sku VARCHAR(64),
+    product_name VARCHAR(255) NOT NULL,
+    status VARCHAR(50) NOT NULL,
+    quantity INTEGER NOT NULL,
+    total_cents BIGINT NOT NULL,
+    order_day DATE NOT NULL,
+    order_created_at TIMESTAMP
+    WITH
+        TIME ZONE NOT NULL,
+        order_updated_at TIMESTAMP
+    WITH
+        TIME ZONE NOT NULL,
+        projected_at TIMESTAMP
+    WITH
+        TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_analytics_day_product ON order_analytics (order_day, product_id);
+
+-- How far each projection has read its source table
+CREATE TABLE IF NOT EXISTS projection_state (
+    name VARCHAR(50) PRIMARY KEY,
+    synced_until TIMESTAMP
+    WITH
+        TIME ZONE NOT NULL,
+        updated_at TIMESTAMP
+    WITH
+        TIME ZONE NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,5 @@
+-- Settlement jobs read only these columns of completed transactions in a
+-- paid_at range; covering them lets the scan use the index alone
+CREATE INDEX IF NOT EXISTS idx_transactions_settlement_scan ON transactions (paid_at) INCLUDE (merchant_id, amount_cents, fee_cents)
+WHERE
+    status = 'COMPLETED';
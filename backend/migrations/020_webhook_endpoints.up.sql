@@ -0,0 +1,11 @@
+-- Receivers of outgoing webhooks; each signs with its own secret so one
+-- receiver can't forge callbacks to another
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+    id UUID PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret VARCHAR(128) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP
+    WITH
+        TIME ZONE NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS payment_events;
+
+DROP INDEX IF EXISTS idx_transactions_payment_id;
+
+ALTER TABLE transactions
+DROP COLUMN IF EXISTS order_id,
+DROP COLUMN IF EXISTS payment_id;
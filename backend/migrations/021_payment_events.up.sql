@@ -0,0 +1,20 @@
+-- Transactions reported by the payment gateway carry its payment ID, and
+-- the order paid for when the gateway knows it
+ALTER TABLE transactions
+ADD COLUMN IF NOT EXISTS payment_id VARCHAR(255),
+ADD COLUMN IF NOT EXISTS order_id UUID REFERENCES orders (id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_payment_id ON transactions (payment_id)
+WHERE
+    payment_id IS NOT NULL;
+
+-- Gateway notifications already applied, so redeliveries are acknowledged
+-- without being applied twice
+CREATE TABLE IF NOT EXISTS payment_events (
+    event_id VARCHAR(255) PRIMARY KEY,
+    type VARCHAR(50) NOT NULL,
+    payment_id VARCHAR(255) NOT NULL,
+    received_at TIMESTAMP
+    WITH
+        TIME ZONE NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,14 @@
+-- Log lines written while a job ran, kept with the job so a failed run can
+-- be debugged without searching server output for its ID
+CREATE TABLE IF NOT EXISTS job_logs (
+    id BIGSERIAL PRIMARY KEY,
+    job_id UUID NOT NULL REFERENCES jobs (id) ON DELETE CASCADE,
+    level VARCHAR(10) NOT NULL,
+    message TEXT NOT NULL,
+    fields JSONB NOT NULL DEFAULT '{}',
+    logged_at TIMESTAMP
+    WITH
+        TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_logs_job ON job_logs (job_id, id);
@@ -0,0 +1,3 @@
+ALTER TABLE jobs
+DROP COLUMN IF EXISTS progress_milestones,
+DROP COLUMN IF EXISTS progress_webhook_id;
@@ -0,0 +1,7 @@
+-- Let a job name a webhook endpoint to notify as its progress passes the
+-- given milestones, in percent
+ALTER TABLE jobs
+ADD COLUMN IF NOT EXISTS progress_webhook_id UUID REFERENCES webhook_endpoints (id) ON DELETE SET NULL;
+
+ALTER TABLE jobs
+ADD COLUMN IF NOT EXISTS progress_milestones INTEGER[] NOT NULL DEFAULT '{}';
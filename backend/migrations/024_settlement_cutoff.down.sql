@@ -0,0 +1,7 @@
+CREATE INDEX IF NOT EXISTS idx_transactions_settlement_scan ON transactions (paid_at) INCLUDE (merchant_id, amount_cents, fee_cents)
+WHERE
+    status = 'COMPLETED';
+
+DROP INDEX IF EXISTS idx_transactions_settlement_cutoff_scan;
+
+ALTER TABLE settlements DROP COLUMN IF EXISTS cutoff_at;
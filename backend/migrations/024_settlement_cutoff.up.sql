@@ -0,0 +1,11 @@
+-- Record the cutoff each settlement was computed with: transactions recorded
+-- after it are left out of the settlement and reported as late
+ALTER TABLE settlements ADD COLUMN IF NOT EXISTS cutoff_at TIMESTAMP WITH TIME ZONE;
+
+-- Settlement jobs now also read created_at to apply the cutoff; keep the
+-- scan covered by the index
+CREATE INDEX IF NOT EXISTS idx_transactions_settlement_cutoff_scan ON transactions (paid_at) INCLUDE (merchant_id, amount_cents, fee_cents, created_at)
+WHERE
+    status = 'COMPLETED';
+
+DROP INDEX IF EXISTS idx_transactions_settlement_scan;
@@ -0,0 +1,18 @@
+-- Manual corrections finance posts against a merchant's settlement day. They
+-- are keyed by merchant and date rather than a settlement version, so they
+-- carry over when the day is re-settled.
+CREATE TABLE IF NOT EXISTS settlement_adjustments (
+    id BIGSERIAL PRIMARY KEY,
+    merchant_id VARCHAR(255) NOT NULL,
+    date DATE NOT NULL,
+    amount_cents BIGINT NOT NULL CHECK (amount_cents <> 0),
+    reason_code VARCHAR(50) NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP
+    WITH
+        TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_settlement_adjustments_merchant_date ON settlement_adjustments (merchant_id, date);
+
+CREATE INDEX IF NOT EXISTS idx_settlement_adjustments_date ON settlement_adjustments (date);
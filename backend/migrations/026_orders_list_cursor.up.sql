@@ -0,0 +1,2 @@
+-- Keyset pages of the order list seek on (created_at, id), newest first
+CREATE INDEX IF NOT EXISTS idx_orders_created_at_id ON orders (created_at, id);
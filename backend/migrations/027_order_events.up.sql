@@ -0,0 +1,41 @@
+-- Each step of an order's life is appended here in the transaction that
+-- takes it, so support tooling can replay an order's history
+CREATE TABLE IF NOT EXISTS order_events (
+    id BIGSERIAL PRIMARY KEY,
+    order_id UUID NOT NULL REFERENCES orders (id),
+    type VARCHAR(50) NOT NULL,
+    detail TEXT NOT NULL DEFAULT '',
+    occurred_at TIMESTAMP
+    WITH
+        TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_events_order_id ON order_events (order_id, occurred_at, id);
+
+-- Orders placed before the timeline existed get the steps their status
+-- implies
+INSERT INTO
+    order_events (order_id, type, occurred_at)
+SELECT id, 'ORDER_CREATED', created_at
+FROM orders
+WHERE
+    NOT EXISTS (
+        SELECT 1
+        FROM order_events e
+        WHERE
+            e.order_id = orders.id
+    );
+
+INSERT INTO
+    order_events (order_id, type, occurred_at)
+SELECT id, 'ORDER_' || status, updated_at
+FROM orders
+WHERE
+    status IN ('CONFIRMED', 'CANCELLED')
+    AND NOT EXISTS (
+        SELECT 1
+        FROM order_events e
+        WHERE
+            e.order_id = orders.id
+            AND e.type <> 'ORDER_CREATED'
+    );
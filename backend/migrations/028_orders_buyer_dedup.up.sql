@@ -0,0 +1,2 @@
+-- Repeated orders from a buyer are looked up by buyer, product and recency
+CREATE INDEX IF NOT EXISTS idx_orders_buyer_product_created_at ON orders (buyer_id, product_id, created_at);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS order_components;
+
+DROP TABLE IF EXISTS bundle_components;
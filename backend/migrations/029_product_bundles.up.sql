@@ -0,0 +1,20 @@
+-- A bundle is a product sold as a set of component products. It keeps no
+-- stock of its own: ordering it takes stock from each component.
+CREATE TABLE IF NOT EXISTS bundle_components (
+    bundle_id INTEGER NOT NULL REFERENCES products (id),
+    product_id INTEGER NOT NULL REFERENCES products (id),
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (bundle_id, product_id),
+    CHECK (bundle_id <> product_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_bundle_components_product_id ON bundle_components (product_id);
+
+-- The stock a bundle order took from each component, so releasing it puts
+-- back what was taken even after the bundle changes
+CREATE TABLE IF NOT EXISTS order_components (
+    order_id UUID NOT NULL REFERENCES orders (id),
+    product_id INTEGER NOT NULL REFERENCES products (id),
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (order_id, product_id)
+);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_orders_backordered;
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS backordered,
+    DROP COLUMN IF EXISTS backorder_limit;
@@ -0,0 +1,9 @@
+-- A product with a backorder limit takes orders beyond its stock, up to
+-- the limit, as BACKORDERED orders confirmed once stock arrives.
+-- backordered counts the units on such orders still awaiting stock.
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS backorder_limit INTEGER NOT NULL DEFAULT 0 CHECK (backorder_limit >= 0),
+    ADD COLUMN IF NOT EXISTS backordered INTEGER NOT NULL DEFAULT 0 CHECK (backordered >= 0);
+
+-- Backorders are confirmed oldest first
+CREATE INDEX IF NOT EXISTS idx_orders_backordered ON orders (product_id, created_at, id) WHERE status = 'BACKORDERED';
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_orders_sales_event;
+
+ALTER TABLE orders DROP COLUMN IF EXISTS sales_event_id;
+
+DROP TABLE IF EXISTS sales_event_products;
+
+DROP TABLE IF EXISTS sales_events;
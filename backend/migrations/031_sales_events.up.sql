@@ -0,0 +1,26 @@
+-- A sales event is a flash sale: its products are sold only between
+-- starts_at and ends_at, each buyer taking up to per_buyer_limit units of
+-- each product
+CREATE TABLE IF NOT EXISTS sales_events (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    starts_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    ends_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    per_buyer_limit INTEGER NOT NULL CHECK (per_buyer_limit > 0),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CHECK (ends_at > starts_at)
+);
+
+CREATE TABLE IF NOT EXISTS sales_event_products (
+    event_id INTEGER NOT NULL REFERENCES sales_events (id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL REFERENCES products (id),
+    PRIMARY KEY (event_id, product_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sales_event_products_product_id ON sales_event_products (product_id);
+
+-- Orders placed during an event, for its per-buyer limits and stats
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS sales_event_id INTEGER REFERENCES sales_events (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_orders_sales_event ON orders (sales_event_id, product_id, buyer_id) WHERE sales_event_id IS NOT NULL;
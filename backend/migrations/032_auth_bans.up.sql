@@ -0,0 +1,16 @@
+-- Audit trail of clients banned for repeated failed authentication. The
+-- subject is the client IP or, for a credential, a fingerprint of it; the
+-- credential itself is never stored.
+CREATE TABLE IF NOT EXISTS auth_bans (
+    id BIGSERIAL PRIMARY KEY,
+    scope VARCHAR(10) NOT NULL,
+    subject VARCHAR(64) NOT NULL,
+    credential VARCHAR(20) NOT NULL,
+    failures INTEGER NOT NULL,
+    client_ip VARCHAR(64) NOT NULL,
+    request_id VARCHAR(255),
+    banned_until TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_auth_bans_created_at ON auth_bans (created_at DESC);
@@ -0,0 +1,2 @@
+ALTER TABLE settlement_runs
+    DROP COLUMN IF EXISTS skipped;
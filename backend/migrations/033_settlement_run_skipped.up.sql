@@ -0,0 +1,4 @@
+-- Merchant-days a settlement run failed to write and left out. Each one
+-- keeps its previous settlement, marked stale so it is settled again.
+ALTER TABLE settlement_runs
+    ADD COLUMN IF NOT EXISTS skipped JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,2 @@
+-- Unwrapped parameters are the encoding jobs are created with now, so
+-- there is nothing to undo
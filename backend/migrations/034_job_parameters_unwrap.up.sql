@@ -0,0 +1,6 @@
+-- Jobs used to be created with their parameters JSON-encoded twice, which
+-- stored a JSON string holding the parameters object. Unwrap those rows so
+-- re-driven and recovered jobs can parse their parameters.
+UPDATE jobs
+SET parameters = (parameters #>> '{}')::jsonb
+WHERE jsonb_typeof(parameters) = 'string';
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_payouts_export_job_id;
+DROP INDEX IF EXISTS idx_payouts_status;
+DROP INDEX IF EXISTS idx_payouts_merchant_period;
+
+DROP TABLE IF EXISTS payouts;
+
+DROP TABLE IF EXISTS merchant_bank_accounts;
@@ -0,0 +1,36 @@
+-- The bank account each merchant is paid to: a US routing and account
+-- number for NACHA, or an IBAN and optional BIC for SEPA
+CREATE TABLE IF NOT EXISTS merchant_bank_accounts (
+    merchant_id VARCHAR(255) PRIMARY KEY,
+    scheme VARCHAR(10) NOT NULL CHECK (scheme IN ('NACHA', 'SEPA')),
+    account_name VARCHAR(255) NOT NULL,
+    routing_number VARCHAR(9),
+    account_number VARCHAR(17),
+    iban VARCHAR(34),
+    bic VARCHAR(11),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CHECK (scheme <> 'NACHA' OR (routing_number IS NOT NULL AND account_number IS NOT NULL)),
+    CHECK (scheme <> 'SEPA' OR iban IS NOT NULL)
+);
+
+-- A payout pays a merchant the net of its current settlements and
+-- adjustments over a period. It is approved before a bank file job claims
+-- it; the job that exported it is kept for reconciliation.
+CREATE TABLE IF NOT EXISTS payouts (
+    id UUID PRIMARY KEY,
+    merchant_id VARCHAR(255) NOT NULL REFERENCES merchant_bank_accounts (merchant_id),
+    period_from DATE NOT NULL,
+    period_to DATE NOT NULL,
+    amount_cents BIGINT NOT NULL CHECK (amount_cents > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING' CHECK (status IN ('PENDING', 'APPROVED', 'EXPORTED')),
+    approved_at TIMESTAMP WITH TIME ZONE,
+    exported_at TIMESTAMP WITH TIME ZONE,
+    export_job_id UUID REFERENCES jobs (id),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CHECK (period_to >= period_from)
+);
+
+CREATE INDEX IF NOT EXISTS idx_payouts_merchant_period ON payouts (merchant_id, period_from, period_to);
+CREATE INDEX IF NOT EXISTS idx_payouts_status ON payouts (status, created_at);
+CREATE INDEX IF NOT EXISTS idx_payouts_export_job_id ON payouts (export_job_id) WHERE export_job_id IS NOT NULL;
@@ -94,7 +94,7 @@ dev_seed() {
     sleep 3
     
     # Run seeder
-    go run cmd/seeder/main.go
+    go run ./cmd/seeder "$@"
 }
 
 dev_clean() {
@@ -148,7 +148,7 @@ test_health() {
 
 test_order() {
     log_info "Creating test order..."
-    curl -s -X POST http://localhost:8080/orders \
+    curl -s -X POST http://localhost:8080/v1/orders \
         -H "Content-Type: application/json" \
         -d '{"product_id":1,"quantity":1,"buyer_id":"test_buyer"}' | jq .
 }
@@ -157,7 +157,7 @@ test_concurrent_orders() {
     log_info "Testing concurrent orders (500 requests)..."
     
     for i in {1..500}; do
-        curl -s -X POST http://localhost:8080/orders \
+        curl -s -X POST http://localhost:8080/v1/orders \
             -H "Content-Type: application/json" \
             -d "{\"product_id\":1,\"quantity\":1,\"buyer_id\":\"buyer_$i\"}" > /dev/null &
     done
@@ -168,7 +168,7 @@ test_concurrent_orders() {
 
 test_settlement_job() {
     log_info "Creating settlement job..."
-    job_response=$(curl -s -X POST http://localhost:8080/jobs/settlement \
+    job_response=$(curl -s -X POST http://localhost:8080/v1/jobs/settlement \
         -H "Content-Type: application/json" \
         -d '{"from":"2025-01-01","to":"2025-01-31"}')
     
@@ -178,7 +178,7 @@ test_settlement_job() {
     # Poll for completion
     log_info "Polling job status..."
     while true; do
-        status=$(curl -s "http://localhost:8080/jobs/$job_id" | jq -r .status)
+        status=$(curl -s "http://localhost:8080/v1/jobs/$job_id" | jq -r .status)
         echo "Job status: $status"
         
         if [ "$status" = "COMPLETED" ] || [ "$status" = "FAILED" ]; then
@@ -189,7 +189,7 @@ test_settlement_job() {
     done
     
     # Show final result
-    curl -s "http://localhost:8080/jobs/$job_id" | jq .
+    curl -s "http://localhost:8080/v1/jobs/$job_id" | jq .
 }
 
 # Database helpers
@@ -201,7 +201,10 @@ db_migrate() {
     docker-compose up -d postgres
     
     # Apply migrations manually (since we're not using a migration tool)
-    docker-compose exec postgres psql -U postgres -d indico -f /docker-entrypoint-initdb.d/001_initial_schema.up.sql
+    for migration in migrations/*.up.sql; do
+        log_info "Applying $(basename "$migration")"
+        docker-compose exec postgres psql -U postgres -d indico -f "/docker-entrypoint-initdb.d/$(basename "$migration")"
+    done
 }
 
 db_reset() {
@@ -263,7 +266,8 @@ case "$1" in
         dev_test
         ;;
     seed)
-        dev_seed
+        shift
+        dev_seed "$@"
         ;;
     clean)
         dev_clean
@@ -2,32 +2,61 @@
 package test
 
 import (
+	"archive/zip"
 	"bytes"
 	"context"
+	"database/sql"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"indico-backend/internal/cache"
 	"indico-backend/internal/config"
 	"indico-backend/internal/database"
+	apperrors "indico-backend/internal/errors"
+	"indico-backend/internal/events"
 	"indico-backend/internal/handlers"
 	"indico-backend/internal/logger"
 	"indico-backend/internal/models"
 	"indico-backend/internal/repository"
 	"indico-backend/internal/routes"
 	"indico-backend/internal/service"
+	"indico-backend/internal/webhook"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-func setupTestDB(t *testing.T) *database.DB {
-	cfg := &config.DatabaseConfig{
+// testAdminToken authorizes admin endpoints in tests
+const testAdminToken = "test-admin-token"
+
+// testPaymentSigningKey signs gateway notifications in tests
+const testPaymentSigningKey = "test-payment-signing-key-0123456789abcdef"
+
+// Sandbox requests in tests use testSandboxKey and are served from
+// testSandboxSchema
+const (
+	testSandboxKey    = "test-sandbox-key-0123456789"
+	testSandboxSchema = "sandbox_test"
+)
+
+// testDatabaseConfig connects to the test database
+func testDatabaseConfig() *config.DatabaseConfig {
+	return &config.DatabaseConfig{
 		Host:     "localhost",
 		Port:     "5433",
 		User:     "postgres",
@@ -36,17 +65,45 @@ func setupTestDB(t *testing.T) *database.DB {
 		SSLMode:  "disable",
 		MaxConns: 10,
 		MaxIdle:  2,
+
+		BatchMaxConns: 2,
+		BatchMaxIdle:  1,
 	}
+}
 
-	db, err := database.New(cfg)
+func setupTestDB(t *testing.T) *database.DB {
+	db, err := database.New(testDatabaseConfig())
 	require.NoError(t, err)
 
 	// Clean up database
 	_, err = db.Exec(`
+		UPDATE maintenance_mode SET enabled = FALSE, message = '';
+		DELETE FROM sagas;
+		DELETE FROM auth_bans;
+		DELETE FROM webhook_endpoints;
+		DELETE FROM payment_events;
+		DELETE FROM reorder_recommendations;
+		DELETE FROM job_locks;
+		DELETE FROM settlement_runs;
+		DELETE FROM payouts;
+		DELETE FROM merchant_bank_accounts;
+		DELETE FROM job_logs;
+		DELETE FROM job_stats;
+		DELETE FROM job_files;
 		DELETE FROM jobs;
 		DELETE FROM settlements;
+		DELETE FROM settlement_adjustments;
 		DELETE FROM transactions;
+		DELETE FROM holidays;
+		DELETE FROM merchant_calendars;
+		DELETE FROM order_analytics;
+		DELETE FROM projection_state;
+		DELETE FROM order_events;
+		DELETE FROM order_components;
 		DELETE FROM orders;
+		DELETE FROM sales_events;
+		DELETE FROM bundle_components;
+		DELETE FROM inventory_movements;
 		DELETE FROM products;
 	`)
 	require.NoError(t, err)
@@ -58,48 +115,171 @@ func setupTestServer(t *testing.T) (*httptest.Server, *database.DB) {
 	logger.Init("debug", "text")
 
 	db := setupTestDB(t)
+	services := newTestServices(t, db)
+
+	// Initialize handlers and routes
+	h := handlers.New(services, testAppConfig())
+	router := routes.SetupRoutes(h)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	return server, db
+}
 
-	// Initialize repositories
-	productRepo := repository.NewProductRepository(db.DB)
-	orderRepo := repository.NewOrderRepository(db.DB)
+// testPayoutConfig is the bank configuration test payout files are written
+// with
+func testPayoutConfig() *config.PayoutConfig {
+	return &config.PayoutConfig{
+		NACHA: config.NACHAConfig{
+			DestinationRouting: "021000021",
+			DestinationName:    "Test Bank",
+			OriginRouting:      "121000248",
+			OriginName:         "Indico",
+			CompanyName:        "Indico Payouts",
+			CompanyID:          "1234567890",
+		},
+		SEPA: config.SEPAConfig{
+			DebtorName: "Indico GmbH",
+			DebtorIBAN: "DE89370400440532013000",
+		},
+	}
+}
+
+// testAppConfig is the configuration test handlers are created with
+func testAppConfig() *config.Config {
+	return &config.Config{
+		Env: config.EnvDev,
+		Server: config.ServerConfig{
+			CORSAllowedOrigins:    []string{"https://dashboard.example.com"},
+			HSTSMaxAge:            365 * 24 * time.Hour,
+			ContentSecurityPolicy: "default-src 'none'; frame-ancestors 'none'",
+			FrameOptions:          config.FrameOptionsDeny,
+		},
+		Debug: config.DebugConfig{
+			Enabled:      true,
+			RedactFields: []string{"buyer_id"},
+			MaxBodyBytes: 4096,
+		},
+		Admin: config.AdminConfig{Token: testAdminToken},
+		Webhook: config.WebhookConfig{
+			SigningKeys:        []string{testPaymentSigningKey},
+			TimestampTolerance: 5 * time.Minute,
+		},
+		Sandbox: config.SandboxConfig{
+			Keys:     []string{testSandboxKey},
+			Schema:   testSandboxSchema,
+			MaxConns: 4,
+		},
+	}
+}
+
+// newTestUnitOfWork binds repositories over db to transactions
+func newTestUnitOfWork(db *database.DB) repository.UnitOfWork {
+	return repository.NewUnitOfWork(db, repository.Writers{
+		Products:     repository.NewProductRepository(db.DB, nil),
+		Orders:       repository.NewOrderRepository(db.DB, nil),
+		Bundles:      repository.NewBundleRepository(db.DB),
+		SalesEvents:  repository.NewSalesEventRepository(db.DB),
+		Transactions: repository.NewTransactionRepository(db.DB),
+		Settlements:  repository.NewSettlementRepository(db.DB),
+		Forecasts:    repository.NewForecastRepository(db.DB),
+		Jobs:         repository.NewJobRepository(db.DB),
+		Sagas:        repository.NewSagaRepository(db.DB),
+	})
+}
+
+// newTestServices wires the services over db the way the server does,
+// stopping their background work when the test ends
+func newTestServices(t *testing.T, db *database.DB) *service.Services {
+	// Initialize repositories, with the order path on prepared statements as
+	// in production
+	stmts, err := repository.PrepareStatements(context.Background(), db.DB)
+	require.NoError(t, err)
+	productRepo := repository.NewProductRepository(db.DB, stmts)
+	orderRepo := repository.NewOrderRepository(db.DB, stmts)
+	bundleRepo := repository.NewBundleRepository(db.DB)
+	salesEventRepo := repository.NewSalesEventRepository(db.DB)
 	txRepo := repository.NewTransactionRepository(db.DB)
 	settleRepo := repository.NewSettlementRepository(db.DB)
 	jobRepo := repository.NewJobRepository(db.DB)
+	forecastRepo := repository.NewForecastRepository(db.DB)
+	calendarRepo := repository.NewCalendarRepository(db.DB)
+	statsRepo := repository.NewStatsRepository(db.DB)
+	sagaRepo := repository.NewSagaRepository(db.DB)
+	maintenanceRepo := repository.NewMaintenanceRepository(db.DB)
+	webhookRepo := repository.NewWebhookRepository(db.DB)
+	payoutRepo := repository.NewPayoutRepository(db.DB)
+
+	uow := repository.NewUnitOfWork(db, repository.Writers{
+		Products:     productRepo,
+		Orders:       orderRepo,
+		Bundles:      bundleRepo,
+		SalesEvents:  salesEventRepo,
+		Transactions: txRepo,
+		Settlements:  settleRepo,
+		Forecasts:    forecastRepo,
+		Jobs:         jobRepo,
+		Sagas:        sagaRepo,
+		Payouts:      payoutRepo,
+	})
+
+	maintenance := service.NewMaintenanceMode(maintenanceRepo, 0)
+	maintenance.Start()
+
+	salesEvents := service.NewSalesEvents(salesEventRepo, nil, 0, 0)
+	salesEvents.Start()
 
 	// Initialize job processor with test config
 	jobConfig := &config.JobsConfig{
 		Workers:   2,
 		BatchSize: 100,
 		QueueSize: 10,
+
+		MaxActivePerClient: 2,
+		LogMaxLines:        100,
 	}
-	jobProcessor := service.NewJobProcessor(db, jobConfig, txRepo, settleRepo, jobRepo)
+	payoutConfig := testPayoutConfig()
+	jobProcessor := service.NewJobProcessor(db, jobConfig, txRepo, settleRepo, jobRepo, productRepo, orderRepo, forecastRepo, calendarRepo, sagaRepo, payoutRepo, webhookRepo, &config.SettlementConfig{}, nil, payoutConfig, maintenance, nil)
 	jobProcessor.Start()
 
 	// Initialize services
 	deps := &service.Dependencies{
-		DB:           db,
-		ProductRepo:  productRepo,
-		OrderRepo:    orderRepo,
-		TxRepo:       txRepo,
-		SettleRepo:   settleRepo,
-		JobRepo:      jobRepo,
-		JobProcessor: jobProcessor,
+		DB:              db,
+		ProductRepo:     productRepo,
+		OrderRepo:       orderRepo,
+		BundleRepo:      bundleRepo,
+		SalesEventRepo:  salesEventRepo,
+		TxRepo:          txRepo,
+		SettleRepo:      settleRepo,
+		JobRepo:         jobRepo,
+		ForecastRepo:    forecastRepo,
+		CalendarRepo:    calendarRepo,
+		StatsRepo:       statsRepo,
+		AnalyticsRepo:   repository.NewAnalyticsRepository(db.DB),
+		SagaRepo:        sagaRepo,
+		MaintenanceRepo: maintenanceRepo,
+		WebhookRepo:     webhookRepo,
+		AuthBanRepo:     repository.NewAuthBanRepository(db.DB),
+		PayoutRepo:      payoutRepo,
+		UnitOfWork:      uow,
+		Sagas:           service.NewSagaOrchestrator(uow, sagaRepo),
+		SalesEvents:     salesEvents,
+		Maintenance:     maintenance,
+		JobProcessor:    jobProcessor,
+		JobsConfig:      jobConfig,
+		AdminConfig:     &config.AdminConfig{Token: testAdminToken, SeedEnabled: true, SeedMaxCount: 5000},
+		PayoutConfig:    payoutConfig,
 	}
-	services := service.NewServices(deps)
-
-	// Initialize handlers and routes
-	h := handlers.New(services)
-	router := routes.SetupRoutes(h)
-
-	server := httptest.NewServer(router)
 
 	t.Cleanup(func() {
+		salesEvents.Stop()
 		jobProcessor.Stop()
-		server.Close()
+		stmts.Close()
 		db.Close()
 	})
 
-	return server, db
+	return service.NewServices(deps)
 }
 
 func createTestProduct(t *testing.T, db *database.DB, stock int) *models.Product {
@@ -156,7 +336,7 @@ func TestConcurrentOrders(t *testing.T) {
 			}
 
 			reqBody, _ := json.Marshal(orderReq)
-			resp, err := http.Post(server.URL+"/orders", "application/json", bytes.NewBuffer(reqBody))
+			resp, err := http.Post(server.URL+"/v1/orders", "application/json", bytes.NewBuffer(reqBody))
 			if err != nil {
 				results <- orderResult{statusCode: 0, err: err}
 				return
@@ -244,7 +424,7 @@ func TestOrderCreation(t *testing.T) {
 	}
 
 	reqBody, _ := json.Marshal(orderReq)
-	resp, err := http.Post(server.URL+"/orders", "application/json", bytes.NewBuffer(reqBody))
+	resp, err := http.Post(server.URL+"/v1/orders", "application/json", bytes.NewBuffer(reqBody))
 	require.NoError(t, err)
 	defer resp.Body.Close()
 
@@ -280,7 +460,7 @@ func TestOrderRetrieval(t *testing.T) {
 	}
 
 	reqBody, _ := json.Marshal(orderReq)
-	resp, err := http.Post(server.URL+"/orders", "application/json", bytes.NewBuffer(reqBody))
+	resp, err := http.Post(server.URL+"/v1/orders", "application/json", bytes.NewBuffer(reqBody))
 	require.NoError(t, err)
 
 	var createdOrder models.Order
@@ -289,7 +469,7 @@ func TestOrderRetrieval(t *testing.T) {
 	resp.Body.Close()
 
 	// Retrieve the order
-	resp, err = http.Get(server.URL + "/orders/" + createdOrder.ID.String())
+	resp, err = http.Get(server.URL + "/v1/orders/" + createdOrder.ID.String())
 	require.NoError(t, err)
 	defer resp.Body.Close()
 
@@ -306,222 +486,3838 @@ func TestOrderRetrieval(t *testing.T) {
 	assert.Equal(t, product.Name, retrievedOrder.Product.Name)
 }
 
-func TestOutOfStockOrder(t *testing.T) {
+func TestOrderClientReference(t *testing.T) {
 	server, db := setupTestServer(t)
 
-	// Create a product with limited stock
-	product := createTestProduct(t, db, 2)
+	product := createTestProduct(t, db, 10)
 
-	// Try to order more than available stock
-	orderReq := models.CreateOrderRequest{
-		ProductID: product.ID,
-		Quantity:  5,
-		BuyerID:   "test_buyer",
+	placeOrder := func(body string) (*http.Response, models.Order) {
+		resp, err := http.Post(server.URL+"/v1/orders", "application/json", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var order models.Order
+		if resp.StatusCode == http.StatusCreated {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&order))
+		}
+		return resp, order
 	}
+	body := fmt.Sprintf(`{"product_id":%d,"quantity":2,"buyer_id":"ref_buyer","client_reference":"checkout-1"}`, product.ID)
 
-	reqBody, _ := json.Marshal(orderReq)
-	resp, err := http.Post(server.URL+"/orders", "application/json", bytes.NewBuffer(reqBody))
-	require.NoError(t, err)
-	defer resp.Body.Close()
+	resp, first := placeOrder(body)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.NotNil(t, first.ClientReference)
+	assert.Equal(t, "checkout-1", *first.ClientReference)
+
+	// Repeating the request returns the same order without taking more stock
+	resp, second := placeOrder(body)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, first.ID, second.ID)
+
+	var stock int
+	require.NoError(t, db.QueryRow("SELECT stock FROM products WHERE id = $1", product.ID).Scan(&stock))
+	assert.Equal(t, 8, stock)
 
+	// The reference can't be reused for a different order
+	resp, _ = placeOrder(fmt.Sprintf(`{"product_id":%d,"quantity":1,"buyer_id":"ref_buyer","client_reference":"checkout-1"}`, product.ID))
 	assert.Equal(t, http.StatusConflict, resp.StatusCode)
 
-	var errResp map[string]interface{}
-	err = json.NewDecoder(resp.Body).Decode(&errResp)
-	require.NoError(t, err)
+	lookup := func(reference string) []models.Order {
+		resp, err := http.Get(server.URL + "/v1/orders?client_reference=" + reference)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
 
-	errorDetail := errResp["error"].(map[string]interface{})
-	assert.Equal(t, "OUT_OF_STOCK", errorDetail["code"])
+		var result struct {
+			Orders []models.Order `json:"orders"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		return result.Orders
+	}
+
+	found := lookup("checkout-1")
+	require.Len(t, found, 1)
+	assert.Equal(t, first.ID, found[0].ID)
+	assert.Empty(t, lookup("checkout-unknown"))
 }
 
-func TestSettlementJob(t *testing.T) {
+// TestOrderEvents tests that an order's timeline records its placement and
+// the payments made for it
+func TestOrderEvents(t *testing.T) {
 	server, db := setupTestServer(t)
-
-	// Create some test transactions
 	ctx := context.Background()
+
+	product := createTestProduct(t, db, 10)
+	reqBody, _ := json.Marshal(models.CreateOrderRequest{ProductID: product.ID, Quantity: 2, BuyerID: "buyer_timeline"})
+	resp, err := http.Post(server.URL+"/v1/orders", "application/json", bytes.NewBuffer(reqBody))
+	require.NoError(t, err)
+	var order models.Order
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&order))
+	resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	// A refund of the order's payment lands on its timeline
 	txRepo := repository.NewTransactionRepository(db.DB)
+	txn := &models.Transaction{MerchantID: "merchant_timeline", AmountCents: 2000, FeeCents: 60, Status: models.TransactionStatusCompleted, PaidAt: time.Now().UTC()}
+	require.NoError(t, txRepo.Create(ctx, txn))
+	_, err = db.Exec("UPDATE transactions SET order_id = $1 WHERE id = $2", order.ID, txn.ID)
+	require.NoError(t, err)
 
-	now := time.Now()
-	transactions := []*models.Transaction{
-		{
-			MerchantID:  "merchant_1",
-			AmountCents: 10000,
-			FeeCents:    300,
-			Status:      models.TransactionStatusCompleted,
-			PaidAt:      now.AddDate(0, 0, -1),
-		},
-		{
-			MerchantID:  "merchant_1",
-			AmountCents: 20000,
-			FeeCents:    600,
-			Status:      models.TransactionStatusCompleted,
-			PaidAt:      now.AddDate(0, 0, -1),
-		},
-		{
-			MerchantID:  "merchant_2",
-			AmountCents: 15000,
-			FeeCents:    450,
-			Status:      models.TransactionStatusCompleted,
-			PaidAt:      now.AddDate(0, 0, -1),
-		},
-	}
+	req, err := http.NewRequest(http.MethodPatch, fmt.Sprintf("%s/v1/transactions/%d/status", server.URL, txn.ID), bytes.NewBufferString(`{"status":"REFUNDED"}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Admin-Token", testAdminToken)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
 
-	for _, tx := range transactions {
-		err := txRepo.Create(ctx, tx)
-		require.NoError(t, err)
+	resp, err = http.Get(server.URL + "/v1/orders/" + order.ID.String() + "/events")
+	require.NoError(t, err)
+	var timeline struct {
+		OrderID uuid.UUID           `json:"order_id"`
+		Events  []models.OrderEvent `json:"events"`
 	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&timeline))
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
 
-	// Create settlement job
-	jobReq := models.CreateSettlementJobRequest{
-		From: now.AddDate(0, 0, -2).Format("2006-01-02"),
-		To:   now.Format("2006-01-02"),
+	assert.Equal(t, order.ID, timeline.OrderID)
+	var types []models.OrderEventType
+	for _, event := range timeline.Events {
+		types = append(types, event.Type)
 	}
+	assert.Equal(t, []models.OrderEventType{
+		models.OrderEventCreated,
+		models.OrderEventStockReserved,
+		models.OrderEventConfirmed,
+		models.OrderEventPaymentRefunded,
+	}, types)
 
-	reqBody, _ := json.Marshal(jobReq)
-	resp, err := http.Post(server.URL+"/jobs/settlement", "application/json", bytes.NewBuffer(reqBody))
+	resp, err = http.Get(server.URL + "/v1/orders/" + uuid.New().String() + "/events")
 	require.NoError(t, err)
-	defer resp.Body.Close()
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
 
-	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+// TestOrderCancellation tests that cancelling an order returns its stock
+// once, and that a cancelled order cannot be cancelled again
+func TestOrderCancellation(t *testing.T) {
+	server, db := setupTestServer(t)
 
-	var jobResp map[string]interface{}
-	err = json.NewDecoder(resp.Body).Decode(&jobResp)
+	product := createTestProduct(t, db, 10)
+	reqBody, _ := json.Marshal(models.CreateOrderRequest{ProductID: product.ID, Quantity: 4, BuyerID: "buyer_cancel"})
+	resp, err := http.Post(server.URL+"/v1/orders", "application/json", bytes.NewBuffer(reqBody))
 	require.NoError(t, err)
+	var order models.Order
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&order))
+	resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
 
-	jobID := jobResp["job_id"].(string)
-	assert.NotEmpty(t, jobID)
-	assert.Equal(t, "QUEUED", jobResp["status"])
+	cancelPath := server.URL + "/v1/orders/" + order.ID.String() + "/cancel"
+	resp, err = http.Post(cancelPath, "application/json", nil)
+	require.NoError(t, err)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&order))
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, models.OrderStatusCancelled, order.Status)
 
-	// Wait for job to complete (with timeout)
-	timeout := time.After(30 * time.Second)
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
+	var stock int
+	require.NoError(t, db.QueryRow("SELECT stock FROM products WHERE id = $1", product.ID).Scan(&stock))
+	assert.Equal(t, 10, stock)
 
-	for {
-		select {
-		case <-timeout:
-			t.Fatal("Job did not complete within timeout")
-		case <-ticker.C:
-			resp, err := http.Get(server.URL + "/jobs/" + jobID)
-			require.NoError(t, err)
+	resp, err = http.Post(cancelPath, "application/json", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
 
-			var job map[string]interface{}
-			err = json.NewDecoder(resp.Body).Decode(&job)
-			resp.Body.Close()
-			require.NoError(t, err)
+	require.NoError(t, db.QueryRow("SELECT stock FROM products WHERE id = $1", product.ID).Scan(&stock))
+	assert.Equal(t, 10, stock)
 
-			status := job["status"].(string)
-			if status == "COMPLETED" {
-				assert.Contains(t, job, "download_url")
-				// Test completed successfully
-				return
-			} else if status == "FAILED" {
-				t.Fatalf("Job failed: %v", job["error"])
-			}
-		}
+	resp, err = http.Get(server.URL + "/v1/orders/" + order.ID.String() + "/events")
+	require.NoError(t, err)
+	var timeline struct {
+		Events []models.OrderEvent `json:"events"`
 	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&timeline))
+	resp.Body.Close()
+	require.NotEmpty(t, timeline.Events)
+	assert.Equal(t, models.OrderEventCancelled, timeline.Events[len(timeline.Events)-1].Type)
+
+	resp, err = http.Post(server.URL+"/v1/orders/"+uuid.New().String()+"/cancel", "application/json", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
 }
 
-func TestJobCancellation(t *testing.T) {
+// TestOrderListPaging tests cursor paging, the order count and the offset
+// depth limit of the order list
+func TestOrderListPaging(t *testing.T) {
 	server, db := setupTestServer(t)
 
-	// Create some test transactions to make the job actually have work to do
-	ctx := context.Background()
-	txRepo := repository.NewTransactionRepository(db.DB)
-
-	// Create many transactions across a wide date range to make job take time
-	now := time.Now()
-	for i := 0; i < 1000; i++ {
-		tx := &models.Transaction{
-			MerchantID:  fmt.Sprintf("merchant_%d", i%10),
-			AmountCents: 10000 + i,
-			FeeCents:    300,
-			Status:      models.TransactionStatusCompleted,
-			PaidAt:      now.AddDate(0, 0, -i%365), // Spread across a year
-		}
-		err := txRepo.Create(ctx, tx)
+	product := createTestProduct(t, db, 10)
+	for i := 0; i < 5; i++ {
+		reqBody, _ := json.Marshal(models.CreateOrderRequest{ProductID: product.ID, Quantity: 1, BuyerID: "buyer_paging"})
+		resp, err := http.Post(server.URL+"/v1/orders", "application/json", bytes.NewBuffer(reqBody))
 		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
 	}
 
-	// Create settlement job with a large date range
-	jobReq := models.CreateSettlementJobRequest{
-		From: "2020-01-01",
-		To:   "2025-12-31",
+	type page struct {
+		Orders     []models.Order `json:"orders"`
+		NextCursor string         `json:"next_cursor"`
 	}
+	get := func(path string) (int, page) {
+		resp, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
 
-	reqBody, _ := json.Marshal(jobReq)
-	resp, err := http.Post(server.URL+"/jobs/settlement", "application/json", bytes.NewBuffer(reqBody))
-	require.NoError(t, err)
+		var result page
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		return resp.StatusCode, result
+	}
 
-	var jobResp map[string]interface{}
-	err = json.NewDecoder(resp.Body).Decode(&jobResp)
-	require.NoError(t, err)
-	resp.Body.Close()
+	// Cursor pages walk every order once, matching the offset pages
+	status, first := get("/v1/orders?limit=2")
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, first.Orders, 2)
+	require.NotEmpty(t, first.NextCursor)
 
-	jobID := jobResp["job_id"].(string)
+	seen := map[uuid.UUID]bool{}
+	for _, order := range first.Orders {
+		seen[order.ID] = true
+	}
+	cursor := first.NextCursor
+	for cursor != "" {
+		status, next := get("/v1/orders?limit=2&cursor=" + cursor)
+		require.Equal(t, http.StatusOK, status)
+		for _, order := range next.Orders {
+			assert.False(t, seen[order.ID], "order %s listed twice", order.ID)
+			seen[order.ID] = true
+		}
+		cursor = next.NextCursor
+	}
+	assert.Len(t, seen, 5)
 
-	// Wait a bit to ensure job starts processing
-	time.Sleep(50 * time.Millisecond)
+	_, second := get("/v1/orders?limit=2&offset=2")
+	_, byCursor := get("/v1/orders?limit=2&cursor=" + first.NextCursor)
+	assert.Equal(t, second.Orders, byCursor.Orders)
 
-	// Cancel the job
-	resp, err = http.Post(server.URL+"/jobs/"+jobID+"/cancel", "application/json", nil)
+	resp, err := http.Get(server.URL + "/v1/orders/count")
 	require.NoError(t, err)
-	defer resp.Body.Close()
-
-	assert.Equal(t, http.StatusOK, resp.StatusCode)
-
-	// Wait for cancellation to take effect
-	time.Sleep(200 * time.Millisecond)
+	var count struct {
+		Count int `json:"count"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&count))
+	resp.Body.Close()
+	assert.Equal(t, 5, count.Count)
 
-	// Check job status multiple times to ensure cancellation
-	for attempts := 0; attempts < 5; attempts++ {
-		resp, err = http.Get(server.URL + "/jobs/" + jobID)
+	// Deep offsets and malformed cursors are rejected
+	for path, code := range map[string]string{
+		"/v1/orders?offset=10001":   apperrors.ErrCodeOffsetTooDeep,
+		"/v1/orders?cursor=garbage": apperrors.ErrCodeValidation,
+	} {
+		resp, err := http.Get(server.URL + path)
 		require.NoError(t, err)
-
-		var job map[string]interface{}
-		err = json.NewDecoder(resp.Body).Decode(&job)
+		var body apperrors.ErrorResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
 		resp.Body.Close()
-		require.NoError(t, err)
-
-		status := job["status"].(string)
-		t.Logf("Attempt %d: Job status after cancellation: %s", attempts+1, status)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, path)
+		assert.Equal(t, code, body.Error.Code, path)
+	}
+}
 
-		// Job should be cancelled, completed, or failed - but not running indefinitely
-		if status == "CANCELLED" {
-			// Perfect - job was successfully cancelled
-			return
-		} else if status == "COMPLETED" || status == "FAILED" {
-			// Job completed before cancellation could take effect - this is also acceptable
-			t.Logf("Job completed with status %s before cancellation could take effect", status)
-			return
-		} else if status == "QUEUED" {
-			// Job might still be queued and cancelled - this is acceptable
-			return
-		}
+// TestOrderDedupWindow tests that a buyer's repeated order within the dedup
+// window returns the order already placed, on the same replica and another
+func TestOrderDedupWindow(t *testing.T) {
+	_, db := setupTestServer(t)
+	ctx := context.Background()
 
-		// If still running, wait a bit more
-		time.Sleep(100 * time.Millisecond)
+	product := createTestProduct(t, db, 10)
+	newOrders := func() service.OrderService {
+		return service.NewOrderService(&service.Dependencies{
+			DB:           db,
+			ProductRepo:  repository.NewProductRepository(db.DB, nil),
+			OrderRepo:    repository.NewOrderRepository(db.DB, nil),
+			Sagas:        service.NewSagaOrchestrator(newTestUnitOfWork(db), repository.NewSagaRepository(db.DB)),
+			OrdersConfig: &config.OrdersConfig{DedupWindow: time.Minute},
+		})
 	}
+	orders := newOrders()
+	req := &models.CreateOrderRequest{ProductID: product.ID, Quantity: 2, BuyerID: "buyer_dedup"}
 
-	// If we get here, the job is still running after multiple attempts
-	t.Fatalf("Job is still running after cancellation attempts")
-}
+	// Double-clicks racing on one replica place a single order
+	var wg sync.WaitGroup
+	ids := make([]uuid.UUID, 5)
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			order, err := orders.CreateOrder(ctx, req)
+			if assert.NoError(t, err) {
+				ids[i] = order.ID
+			}
+		}(i)
+	}
+	wg.Wait()
+	for _, id := range ids {
+		assert.Equal(t, ids[0], id)
+	}
 
-func TestHealthCheck(t *testing.T) {
-	server, _ := setupTestServer(t)
+	// Another replica finds the order in the database
+	order, err := newOrders().CreateOrder(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, ids[0], order.ID)
 
-	resp, err := http.Get(server.URL + "/health")
+	// A different quantity is a new order
+	other, err := orders.CreateOrder(ctx, &models.CreateOrderRequest{ProductID: product.ID, Quantity: 1, BuyerID: "buyer_dedup"})
 	require.NoError(t, err)
-	defer resp.Body.Close()
+	assert.NotEqual(t, ids[0], other.ID)
 
-	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var stock int
+	require.NoError(t, db.QueryRow("SELECT stock FROM products WHERE id = $1", product.ID).Scan(&stock))
+	assert.Equal(t, 7, stock)
+}
 
-	var health models.HealthCheck
-	err = json.NewDecoder(resp.Body).Decode(&health)
-	require.NoError(t, err)
+// TestOrderAnalyticsProjection tests that the projector copies orders into
+// the analytics read model that the dashboard endpoint reads
+func TestOrderAnalyticsProjection(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	product := createTestProduct(t, db, 10)
+	for _, quantity := range []int{2, 3} {
+		body := fmt.Sprintf(`{"product_id":%d,"quantity":%d,"buyer_id":"analytics_buyer"}`, product.ID, quantity)
+		resp, err := http.Post(server.URL+"/v1/orders", "application/json", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+	}
+
+	projector := service.NewOrderProjector(repository.NewAnalyticsRepository(db.DB), &config.AnalyticsConfig{
+		ProjectionBatchSize: 1,
+	}, nil)
+	projected, err := projector.Project(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, projected)
+
+	today := time.Now().UTC().Format("2006-01-02")
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/admin/stats/order-analytics?from=%s&to=%s&product_id=%d", server.URL, today, today, product.ID), nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Admin-Token", testAdminToken)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var stats models.OrderAnalyticsStats
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&stats))
+	require.Len(t, stats.Days, 1)
+	assert.Equal(t, product.ID, stats.Days[0].ProductID)
+	assert.Equal(t, "Test Product", stats.Days[0].ProductName)
+	assert.Equal(t, 2, stats.Days[0].Orders)
+	assert.Equal(t, 5, stats.Days[0].Units)
+	assert.Equal(t, int64(5000), stats.Days[0].RevenueCents)
+	assert.NotNil(t, stats.ProjectedUntil)
+}
+
+// TestProductCRUD tests managing the catalog over HTTP: only the admin
+// changes it, stock updates are recorded as movements, and products with
+// orders can't be deleted
+func TestProductCRUD(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	send := func(method, path string, body interface{}, admin bool) *http.Response {
+		data, _ := json.Marshal(body)
+		req, err := http.NewRequest(method, server.URL+path, bytes.NewBuffer(data))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		if admin {
+			req.Header.Set("X-Admin-Token", testAdminToken)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	create := map[string]interface{}{"name": "Catalog Product", "sku": "CATALOG-1", "stock": 5, "price": 1500}
+	resp := send(http.MethodPost, "/v1/products", create, false)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = send(http.MethodPost, "/v1/products", create, true)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var product models.Product
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&product))
+	resp.Body.Close()
+	assert.Equal(t, 5, product.Stock)
+	assert.Equal(t, 1, product.Version)
+
+	resp = send(http.MethodPost, "/v1/products", create, true)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	path := fmt.Sprintf("/v1/products/%d", product.ID)
+	update := map[string]interface{}{"name": "Catalog Product v2", "sku": "CATALOG-1", "stock": 8, "price": 1750}
+	resp = send(http.MethodPut, path, update, true)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&product))
+	resp.Body.Close()
+	assert.Equal(t, "Catalog Product v2", product.Name)
+	assert.Equal(t, 8, product.Stock)
+	assert.Equal(t, 1750, product.Price)
+	assert.Equal(t, 2, product.Version)
+
+	resp = send(http.MethodGet, path+"/movements", nil, false)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var movements struct {
+		Movements []models.InventoryMovement `json:"movements"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&movements))
+	resp.Body.Close()
+	require.Len(t, movements.Movements, 1)
+	assert.Equal(t, models.MovementReasonAdjustment, movements.Movements[0].Reason)
+	assert.Equal(t, 3, movements.Movements[0].Delta)
+
+	resp = send(http.MethodGet, "/v1/products?limit=100", nil, false)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var list struct {
+		Products []models.Product `json:"products"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	resp.Body.Close()
+	assert.NotEmpty(t, list.Products)
+
+	// Ordered products stay for their order history
+	resp = send(http.MethodPost, "/v1/orders", models.CreateOrderRequest{ProductID: product.ID, Quantity: 1, BuyerID: "buyer_catalog"}, false)
+	resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	resp = send(http.MethodDelete, path, nil, true)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	resp = send(http.MethodPost, "/v1/products", map[string]interface{}{"name": "Unsold Product", "price": 100}, true)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&product))
+	resp.Body.Close()
+
+	path = fmt.Sprintf("/v1/products/%d", product.ID)
+	resp = send(http.MethodDelete, path, nil, true)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp = send(http.MethodGet, path, nil, false)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+// TestBundles tests that ordering a bundle takes stock from each component
+// and records them on the order, and that bundles are managed by admins
+func TestBundles(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	first := createTestProduct(t, db, 10)
+	second := createTestProduct(t, db, 3)
+
+	send := func(method, path string, body interface{}, admin bool) *http.Response {
+		data, _ := json.Marshal(body)
+		req, err := http.NewRequest(method, server.URL+path, bytes.NewBuffer(data))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		if admin {
+			req.Header.Set("X-Admin-Token", testAdminToken)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	create := models.CreateBundleRequest{
+		Name:  "Starter Kit",
+		Price: 2500,
+		Components: []*models.BundleComponent{
+			{ProductID: first.ID, Quantity: 2},
+			{ProductID: second.ID, Quantity: 1},
+		},
+	}
+	resp := send(http.MethodPost, "/v1/bundles", create, false)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = send(http.MethodPost, "/v1/bundles", create, true)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var bundle models.Bundle
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&bundle))
+	resp.Body.Close()
+	assert.Equal(t, 3, bundle.Available)
+	require.Len(t, bundle.Components, 2)
+
+	// A bundle is ordered by its ID and takes each component's stock
+	resp = send(http.MethodPost, "/v1/orders", models.CreateOrderRequest{ProductID: bundle.ID, Quantity: 2, BuyerID: "buyer_bundle"}, false)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var order models.Order
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&order))
+	resp.Body.Close()
+	assert.Equal(t, 5000, order.TotalCents)
+
+	var firstStock, secondStock int
+	require.NoError(t, db.QueryRow("SELECT stock FROM products WHERE id = $1", first.ID).Scan(&firstStock))
+	require.NoError(t, db.QueryRow("SELECT stock FROM products WHERE id = $1", second.ID).Scan(&secondStock))
+	assert.Equal(t, 6, firstStock)
+	assert.Equal(t, 1, secondStock)
+
+	resp = send(http.MethodGet, fmt.Sprintf("/v1/orders/%s", order.ID), nil, false)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&order))
+	resp.Body.Close()
+	assert.Equal(t, []*models.OrderComponent{
+		{ProductID: first.ID, Quantity: 4},
+		{ProductID: second.ID, Quantity: 2},
+	}, order.Components)
+
+	// The component running out refuses the bundle
+	resp = send(http.MethodPost, "/v1/orders", models.CreateOrderRequest{ProductID: bundle.ID, Quantity: 2, BuyerID: "buyer_bundle"}, false)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	// Replacing the components changes what is available
+	update := models.UpdateBundleRequest{
+		Name:       "Starter Kit",
+		Price:      2000,
+		Components: []*models.BundleComponent{{ProductID: first.ID, Quantity: 3}},
+	}
+	resp = send(http.MethodPut, fmt.Sprintf("/v1/bundles/%d", bundle.ID), update, true)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&bundle))
+	resp.Body.Close()
+	assert.Equal(t, 2, bundle.Available)
+	assert.Equal(t, 2000, bundle.Price)
+
+	// Bundles cannot contain bundles, and unknown bundles are not found
+	nested := create
+	nested.Name = "Kit of Kits"
+	nested.Components = []*models.BundleComponent{{ProductID: bundle.ID, Quantity: 1}}
+	resp = send(http.MethodPost, "/v1/bundles", nested, true)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = send(http.MethodGet, fmt.Sprintf("/v1/bundles/%d", first.ID), nil, false)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+// TestBackorders tests that a product with a backorder limit takes orders
+// beyond its stock and that a stock sync confirms them
+func TestBackorders(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	product := createTestProduct(t, db, 1)
+	_, err := db.Exec("UPDATE products SET sku = 'BACKORDER-1' WHERE id = $1", product.ID)
+	require.NoError(t, err)
+
+	send := func(method, path string, body interface{}, admin bool) *http.Response {
+		data, _ := json.Marshal(body)
+		req, err := http.NewRequest(method, server.URL+path, bytes.NewBuffer(data))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		if admin {
+			req.Header.Set("X-Admin-Token", testAdminToken)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	limitPath := fmt.Sprintf("/v1/products/%d/backorder-limit", product.ID)
+	resp := send(http.MethodPut, limitPath, map[string]int{"backorder_limit": 3}, false)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = send(http.MethodPut, limitPath, map[string]int{"backorder_limit": 3}, true)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(product))
+	resp.Body.Close()
+	assert.Equal(t, 3, product.BackorderLimit)
+
+	// Beyond the stock the order waits for more
+	resp = send(http.MethodPost, "/v1/orders", models.CreateOrderRequest{ProductID: product.ID, Quantity: 2, BuyerID: "buyer_backorder"}, false)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var order models.Order
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&order))
+	resp.Body.Close()
+	assert.Equal(t, models.OrderStatusBackordered, order.Status)
+
+	// Beyond the limit it is refused
+	resp = send(http.MethodPost, "/v1/orders", models.CreateOrderRequest{ProductID: product.ID, Quantity: 2, BuyerID: "buyer_backorder_2"}, false)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	var stock, backordered int
+	require.NoError(t, db.QueryRow("SELECT stock, backordered FROM products WHERE id = $1", product.ID).Scan(&stock, &backordered))
+	assert.Equal(t, 1, stock)
+	assert.Equal(t, 2, backordered)
+
+	// Stock arriving queues the fulfillment job
+	sync := map[string]interface{}{"items": []map[string]interface{}{{"sku": "BACKORDER-1", "absolute_stock": 5}}}
+	resp = send(http.MethodPut, "/v1/products/stock-sync", sync, true)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var result models.StockSyncResult
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	resp.Body.Close()
+	require.NotNil(t, result.FulfillmentJobID)
+
+	job := waitForJob(t, server, result.FulfillmentJobID.String())
+	require.Equal(t, "COMPLETED", job["status"])
+
+	resp = send(http.MethodGet, fmt.Sprintf("/v1/orders/%s", order.ID), nil, false)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&order))
+	resp.Body.Close()
+	assert.Equal(t, models.OrderStatusConfirmed, order.Status)
+
+	require.NoError(t, db.QueryRow("SELECT stock, backordered FROM products WHERE id = $1", product.ID).Scan(&stock, &backordered))
+	assert.Equal(t, 3, stock)
+	assert.Equal(t, 0, backordered)
+}
+
+// TestSalesEvents tests that a sales event's products sell only while it
+// is live, up to the per-buyer limit, and that its stats count the orders
+func TestSalesEvents(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	product := createTestProduct(t, db, 10)
+
+	send := func(method, path string, body interface{}, admin bool) *http.Response {
+		data, _ := json.Marshal(body)
+		req, err := http.NewRequest(method, server.URL+path, bytes.NewBuffer(data))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		if admin {
+			req.Header.Set("X-Admin-Token", testAdminToken)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+	order := func(buyerID string, quantity int) *http.Response {
+		return send(http.MethodPost, "/v1/orders", models.CreateOrderRequest{ProductID: product.ID, Quantity: quantity, BuyerID: buyerID}, false)
+	}
+
+	now := time.Now().UTC()
+	create := models.CreateSalesEventRequest{
+		Name:          "Midnight sale",
+		StartsAt:      now.Add(time.Hour),
+		EndsAt:        now.Add(2 * time.Hour),
+		PerBuyerLimit: 2,
+		ProductIDs:    []int{product.ID},
+	}
+	resp := send(http.MethodPost, "/v1/sales-events", create, false)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = send(http.MethodPost, "/v1/sales-events", create, true)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var event models.SalesEvent
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&event))
+	resp.Body.Close()
+	assert.Equal(t, []int{product.ID}, event.ProductIDs)
+
+	// The product is off sale until the event starts
+	resp = order("buyer_sale_1", 1)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	// An overlapping event cannot take the product
+	overlapping := create
+	overlapping.StartsAt = now.Add(90 * time.Minute)
+	overlapping.EndsAt = now.Add(3 * time.Hour)
+	resp = send(http.MethodPost, "/v1/sales-events", overlapping, true)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	// Recreate the event starting a minute ago
+	resp = send(http.MethodDelete, fmt.Sprintf("/v1/sales-events/%d", event.ID), nil, true)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	create.StartsAt = now.Add(-time.Minute)
+	resp = send(http.MethodPost, "/v1/sales-events", create, true)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&event))
+	resp.Body.Close()
+
+	resp = order("buyer_sale_1", 2)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var placed models.Order
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&placed))
+	resp.Body.Close()
+	require.NotNil(t, placed.SalesEventID)
+	assert.Equal(t, event.ID, *placed.SalesEventID)
+
+	// The buyer holds the limit; another buyer does not
+	resp = order("buyer_sale_1", 1)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	resp = order("buyer_sale_2", 1)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	resp = send(http.MethodGet, fmt.Sprintf("/v1/sales-events/%d/stats", event.ID), nil, false)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var stats models.SalesEventStats
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&stats))
+	resp.Body.Close()
+	assert.Equal(t, models.SalesEventLive, stats.Status)
+	assert.Equal(t, 2, stats.Orders)
+	assert.Equal(t, 3, stats.Units)
+	require.Len(t, stats.Products, 1)
+	assert.Equal(t, 2, stats.Products[0].Buyers)
+	assert.Equal(t, 7, stats.Products[0].Stock)
+}
+
+// TestProductSnapshot tests that the snapshot only returns products changed
+// since the given timestamp, oldest change first
+func TestProductSnapshot(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	old := createTestProduct(t, db, 10)
+	recent := createTestProduct(t, db, 20)
+	_, err := db.Exec("UPDATE products SET updated_at = NOW() - INTERVAL '2 hours' WHERE id = $1", old.ID)
+	require.NoError(t, err)
+
+	snapshot := func(query string) (*http.Response, []models.ProductSnapshot) {
+		resp, err := http.Get(server.URL + "/v1/products/snapshot" + query)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var result struct {
+			Products []models.ProductSnapshot `json:"products"`
+		}
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		}
+		return resp, result.Products
+	}
+
+	resp, all := snapshot("")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, all, 2)
+	assert.Equal(t, old.ID, all[0].ID)
+
+	since := url.QueryEscape(time.Now().Add(-time.Hour).UTC().Format(time.RFC3339))
+	resp, changed := snapshot("?updated_since=" + since)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, changed, 1)
+	assert.Equal(t, recent.ID, changed[0].ID)
+	assert.Equal(t, 20, changed[0].Stock)
+	assert.Equal(t, 1, changed[0].Version)
+
+	resp, _ = snapshot("?updated_since=yesterday")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+// TestSearchDisabled tests that search reports itself unavailable when no
+// cluster is configured
+func TestSearchDisabled(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	resp, err := http.Post(server.URL+"/v1/search/products", "application/json", bytes.NewBufferString(`{"query":{"match_all":{}}}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+// TestStockSync tests that a warehouse stock sync applies all levels in one
+// transaction, bumps versions and records a movement per changed product
+func TestStockSync(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	changed := createTestProduct(t, db, 10)
+	same := createTestProduct(t, db, 5)
+	_, err := db.Exec("UPDATE products SET sku = 'WMS-' || id WHERE id IN ($1, $2)", changed.ID, same.ID)
+	require.NoError(t, err)
+
+	sync := func(body string) *http.Response {
+		req, err := http.NewRequest(http.MethodPut, server.URL+"/v1/products/stock-sync", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Admin-Token", testAdminToken)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	// An unknown SKU rejects the whole batch
+	resp := sync(fmt.Sprintf(`{"items":[{"sku":"WMS-%d","absolute_stock":40},{"sku":"WMS-missing","absolute_stock":1}]}`, changed.ID))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	var stock, version int
+	require.NoError(t, db.QueryRow("SELECT stock, version FROM products WHERE id = $1", changed.ID).Scan(&stock, &version))
+	assert.Equal(t, 10, stock)
+	assert.Equal(t, 1, version)
+
+	resp = sync(fmt.Sprintf(`{"items":[{"sku":"WMS-%d","absolute_stock":40},{"sku":"WMS-%d","absolute_stock":5}]}`, changed.ID, same.ID))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var result models.StockSyncResult
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Equal(t, 1, result.Updated)
+	assert.Equal(t, 1, result.Unchanged)
+	require.Len(t, result.Movements, 1)
+	assert.Equal(t, changed.ID, result.Movements[0].ProductID)
+	assert.Equal(t, 30, result.Movements[0].Delta)
+	assert.Equal(t, models.MovementReasonStockSync, result.Movements[0].Reason)
+
+	require.NoError(t, db.QueryRow("SELECT stock, version FROM products WHERE id = $1", changed.ID).Scan(&stock, &version))
+	assert.Equal(t, 40, stock)
+	assert.Equal(t, 2, version)
+
+	require.NoError(t, db.QueryRow("SELECT version FROM products WHERE id = $1", same.ID).Scan(&version))
+	assert.Equal(t, 1, version)
+
+	movementsResp, err := http.Get(fmt.Sprintf("%s/v1/products/%d/movements", server.URL, changed.ID))
+	require.NoError(t, err)
+	defer movementsResp.Body.Close()
+	require.Equal(t, http.StatusOK, movementsResp.StatusCode)
+
+	var listed struct {
+		Movements []models.InventoryMovement `json:"movements"`
+	}
+	require.NoError(t, json.NewDecoder(movementsResp.Body).Decode(&listed))
+	require.Len(t, listed.Movements, 1)
+	assert.Equal(t, 10, listed.Movements[0].PreviousStock)
+	assert.Equal(t, 40, listed.Movements[0].NewStock)
+}
+
+func TestOutOfStockOrder(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	// Create a product with limited stock
+	product := createTestProduct(t, db, 2)
+
+	// Try to order more than available stock
+	orderReq := models.CreateOrderRequest{
+		ProductID: product.ID,
+		Quantity:  5,
+		BuyerID:   "test_buyer",
+	}
+
+	reqBody, _ := json.Marshal(orderReq)
+	resp, err := http.Post(server.URL+"/v1/orders", "application/json", bytes.NewBuffer(reqBody))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	var errResp map[string]interface{}
+	err = json.NewDecoder(resp.Body).Decode(&errResp)
+	require.NoError(t, err)
+
+	errorDetail := errResp["error"].(map[string]interface{})
+	assert.Equal(t, "OUT_OF_STOCK", errorDetail["code"])
+
+	// Request metrics carry the status actually returned, not a blanket 500
+	metricsResp, err := http.Get(server.URL + "/metrics")
+	require.NoError(t, err)
+	body, err := io.ReadAll(metricsResp.Body)
+	metricsResp.Body.Close()
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `http_requests_total{method="POST",path="/v1/orders",status_code="409"}`)
+	assert.Contains(t, string(body), `orders_out_of_stock_total{product_bucket="`)
+}
+
+func TestBulkOrdersPartialFailure(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	product := createTestProduct(t, db, 3)
+
+	bulkReq := models.BulkCreateOrdersRequest{
+		Orders: []models.CreateOrderRequest{
+			{ProductID: product.ID, Quantity: 2, BuyerID: "buyer_1"},
+			{ProductID: product.ID, Quantity: 5, BuyerID: "buyer_2"},
+			{ProductID: product.ID, Quantity: 0, BuyerID: "buyer_3"},
+		},
+	}
+
+	reqBody, _ := json.Marshal(bulkReq)
+	resp, err := http.Post(server.URL+"/v1/orders/bulk", "application/json", bytes.NewBuffer(reqBody))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+
+	var result apperrors.MultiStatusResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+
+	assert.Equal(t, 1, result.Succeeded)
+	assert.Equal(t, 2, result.Failed)
+	require.Len(t, result.Results, 3)
+
+	assert.Equal(t, http.StatusCreated, result.Results[0].Status)
+	assert.Nil(t, result.Results[0].Error)
+
+	assert.Equal(t, http.StatusConflict, result.Results[1].Status)
+	require.NotNil(t, result.Results[1].Error)
+	assert.Equal(t, "OUT_OF_STOCK", result.Results[1].Error.Code)
+
+	assert.Equal(t, http.StatusBadRequest, result.Results[2].Status)
+	require.NotNil(t, result.Results[2].Error)
+	assert.Equal(t, "VALIDATION_ERROR", result.Results[2].Error.Code)
+}
+
+func TestSettlementJob(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	// Create some test transactions
+	ctx := context.Background()
+	txRepo := repository.NewTransactionRepository(db.DB)
+
+	now := time.Now()
+	transactions := []*models.Transaction{
+		{
+			MerchantID:  "merchant_1",
+			AmountCents: 10000,
+			FeeCents:    300,
+			Status:      models.TransactionStatusCompleted,
+			PaidAt:      now.AddDate(0, 0, -1),
+		},
+		{
+			MerchantID:  "merchant_1",
+			AmountCents: 20000,
+			FeeCents:    600,
+			Status:      models.TransactionStatusCompleted,
+			PaidAt:      now.AddDate(0, 0, -1),
+		},
+		{
+			MerchantID:  "merchant_2",
+			AmountCents: 15000,
+			FeeCents:    450,
+			Status:      models.TransactionStatusCompleted,
+			PaidAt:      now.AddDate(0, 0, -1),
+		},
+	}
+
+	for _, tx := range transactions {
+		err := txRepo.Create(ctx, tx)
+		require.NoError(t, err)
+	}
+
+	// Create settlement job
+	jobReq := models.CreateSettlementJobRequest{
+		From: now.AddDate(0, 0, -2).Format("2006-01-02"),
+		To:   now.Format("2006-01-02"),
+	}
+
+	reqBody, _ := json.Marshal(jobReq)
+	resp, err := http.Post(server.URL+"/v1/jobs/settlement", "application/json", bytes.NewBuffer(reqBody))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	var jobResp map[string]interface{}
+	err = json.NewDecoder(resp.Body).Decode(&jobResp)
+	require.NoError(t, err)
+
+	jobID := jobResp["job_id"].(string)
+	assert.NotEmpty(t, jobID)
+	assert.Equal(t, "QUEUED", jobResp["status"])
+
+	// Wait for job to complete (with timeout)
+	timeout := time.After(30 * time.Second)
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-timeout:
+			t.Fatal("Job did not complete within timeout")
+		case <-ticker.C:
+			resp, err := http.Get(server.URL + "/v1/jobs/" + jobID)
+			require.NoError(t, err)
+
+			var job map[string]interface{}
+			err = json.NewDecoder(resp.Body).Decode(&job)
+			resp.Body.Close()
+			require.NoError(t, err)
+
+			status := job["status"].(string)
+			if status == "COMPLETED" {
+				assert.Contains(t, job, "download_url")
+
+				// The job should be recorded as the latest settlement run
+				runResp, err := http.Get(server.URL + "/v1/settlements/runs/latest")
+				require.NoError(t, err)
+
+				var run models.SettlementRun
+				err = json.NewDecoder(runResp.Body).Decode(&run)
+				runResp.Body.Close()
+				require.NoError(t, err)
+
+				assert.Equal(t, jobID, run.ID.String())
+				assert.Equal(t, 2, run.SettlementCount)
+				// Test completed successfully
+				return
+			} else if status == "FAILED" {
+				t.Fatalf("Job failed: %v", job["error"])
+			}
+		}
+	}
+}
+
+func TestJobCancellation(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	// Create some test transactions to make the job actually have work to do
+	ctx := context.Background()
+	txRepo := repository.NewTransactionRepository(db.DB)
+
+	// Create many transactions across a wide date range to make job take time
+	now := time.Now()
+	for i := 0; i < 1000; i++ {
+		tx := &models.Transaction{
+			MerchantID:  fmt.Sprintf("merchant_%d", i%10),
+			AmountCents: 10000 + i,
+			FeeCents:    300,
+			Status:      models.TransactionStatusCompleted,
+			PaidAt:      now.AddDate(0, 0, -i%365), // Spread across a year
+		}
+		err := txRepo.Create(ctx, tx)
+		require.NoError(t, err)
+	}
+
+	// Create settlement job with a large date range
+	jobReq := models.CreateSettlementJobRequest{
+		From: "2020-01-01",
+		To:   "2025-12-31",
+	}
+
+	reqBody, _ := json.Marshal(jobReq)
+	resp, err := http.Post(server.URL+"/v1/jobs/settlement", "application/json", bytes.NewBuffer(reqBody))
+	require.NoError(t, err)
+
+	var jobResp map[string]interface{}
+	err = json.NewDecoder(resp.Body).Decode(&jobResp)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	jobID := jobResp["job_id"].(string)
+
+	// Wait a bit to ensure job starts processing
+	time.Sleep(50 * time.Millisecond)
+
+	// Cancel the job
+	resp, err = http.Post(server.URL+"/v1/jobs/"+jobID+"/cancel", "application/json", nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Wait for cancellation to take effect
+	time.Sleep(200 * time.Millisecond)
+
+	// Check job status multiple times to ensure cancellation
+	for attempts := 0; attempts < 5; attempts++ {
+		resp, err = http.Get(server.URL + "/v1/jobs/" + jobID)
+		require.NoError(t, err)
+
+		var job map[string]interface{}
+		err = json.NewDecoder(resp.Body).Decode(&job)
+		resp.Body.Close()
+		require.NoError(t, err)
+
+		status := job["status"].(string)
+		t.Logf("Attempt %d: Job status after cancellation: %s", attempts+1, status)
+
+		// Job should be cancelled, completed, or failed - but not running indefinitely
+		if status == "CANCELLED" {
+			// Perfect - job was successfully cancelled
+			return
+		} else if status == "COMPLETED" || status == "FAILED" {
+			// Job completed before cancellation could take effect - this is also acceptable
+			t.Logf("Job completed with status %s before cancellation could take effect", status)
+			return
+		} else if status == "QUEUED" {
+			// Job might still be queued and cancelled - this is acceptable
+			return
+		}
+
+		// If still running, wait a bit more
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// If we get here, the job is still running after multiple attempts
+	t.Fatalf("Job is still running after cancellation attempts")
+}
+
+func TestCancelledJobStaysCancelled(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	ctx := context.Background()
+	txRepo := repository.NewTransactionRepository(db.DB)
+	now := time.Now()
+	for i := 0; i < 500; i++ {
+		require.NoError(t, txRepo.Create(ctx, &models.Transaction{
+			MerchantID:  fmt.Sprintf("merchant_%d", i%5),
+			AmountCents: 10000,
+			FeeCents:    300,
+			Status:      models.TransactionStatusCompleted,
+			PaidAt:      now.AddDate(0, 0, -i%30),
+		}))
+	}
+
+	resp, err := http.Post(server.URL+"/v1/jobs/settlement", "application/json", bytes.NewBufferString(`{"from":"2020-01-01","to":"2030-12-31"}`))
+	require.NoError(t, err)
+	var jobResp map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&jobResp))
+	resp.Body.Close()
+	jobID := jobResp["job_id"].(string)
+
+	resp, err = http.Post(server.URL+"/v1/jobs/"+jobID+"/cancel", "application/json", nil)
+	require.NoError(t, err)
+	var cancelResp map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&cancelResp))
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusConflict {
+		t.Skip("job finished before it could be cancelled")
+	}
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, []interface{}{"CANCELLED", "CANCELLING"}, cancelResp["status"])
+
+	// Once the worker stops the job is CANCELLED and stays that way
+	job := waitForJob(t, server, jobID)
+	require.Equal(t, "CANCELLED", job["status"])
+
+	time.Sleep(500 * time.Millisecond)
+	resp, err = http.Get(server.URL + "/v1/jobs/" + jobID)
+	require.NoError(t, err)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&job))
+	resp.Body.Close()
+	assert.Equal(t, "CANCELLED", job["status"])
+
+	// A second cancel is rejected
+	resp, err = http.Post(server.URL+"/v1/jobs/"+jobID+"/cancel", "application/json", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+}
+
+func TestSampleTransactions(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	ctx := context.Background()
+	txRepo := repository.NewTransactionRepository(db.DB)
+
+	paidAt := time.Date(2025, 4, 10, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		require.NoError(t, txRepo.Create(ctx, &models.Transaction{MerchantID: "merchant_sample", AmountCents: 1000 + i, FeeCents: 30, Status: models.TransactionStatusCompleted, PaidAt: paidAt}))
+	}
+	require.NoError(t, txRepo.Create(ctx, &models.Transaction{MerchantID: "merchant_sample", AmountCents: 999, FeeCents: 30, Status: models.TransactionStatusCompleted, PaidAt: paidAt.AddDate(0, 0, 5)}))
+
+	resp, err := http.Get(server.URL + "/v1/transactions/sample?from=2025-04-10&to=2025-04-10&n=5")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var result struct {
+		Transactions []models.Transaction `json:"transactions"`
+		Count        int                  `json:"count"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Equal(t, 5, result.Count)
+	require.Len(t, result.Transactions, 5)
+
+	seen := make(map[int]bool)
+	for _, txn := range result.Transactions {
+		assert.False(t, seen[txn.ID], "duplicate transaction %d in sample", txn.ID)
+		seen[txn.ID] = true
+		assert.Equal(t, "2025-04-10", txn.PaidAt.UTC().Format("2006-01-02"))
+	}
+
+	resp, err = http.Get(server.URL + "/v1/transactions/sample?from=2025-04-10&to=not-a-date")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestTransactionStatusFlagsStaleSettlements(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	ctx := context.Background()
+	txRepo := repository.NewTransactionRepository(db.DB)
+
+	paidAt := time.Date(2025, 4, 8, 10, 0, 0, 0, time.UTC)
+	completed := &models.Transaction{MerchantID: "merchant_stale", AmountCents: 10000, FeeCents: 300, Status: models.TransactionStatusCompleted, PaidAt: paidAt}
+	pending := &models.Transaction{MerchantID: "merchant_stale", AmountCents: 5000, FeeCents: 150, Status: models.TransactionStatusPending, PaidAt: paidAt}
+	require.NoError(t, txRepo.Create(ctx, completed))
+	require.NoError(t, txRepo.Create(ctx, pending))
+
+	settle := func() {
+		resp, err := http.Post(server.URL+"/v1/jobs/settlement", "application/json", bytes.NewBufferString(`{"from":"2025-04-08","to":"2025-04-08"}`))
+		require.NoError(t, err)
+		var jobResp map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&jobResp))
+		resp.Body.Close()
+		job := waitForJob(t, server, jobResp["job_id"].(string))
+		require.Equal(t, "COMPLETED", job["status"], "job error: %v", job["error"])
+	}
+
+	patchStatus := func(id int, status string) (*http.Response, models.TransactionStatusChange) {
+		req, err := http.NewRequest(http.MethodPatch, fmt.Sprintf("%s/v1/transactions/%d/status", server.URL, id), bytes.NewBufferString(`{"status":"`+status+`"}`))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Admin-Token", testAdminToken)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var change models.TransactionStatusChange
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&change))
+		}
+		return resp, change
+	}
+
+	listStale := func() []models.Settlement {
+		resp, err := http.Get(server.URL + "/v1/settlements/stale")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var result struct {
+			Settlements []models.Settlement `json:"settlements"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		return result.Settlements
+	}
+
+	settle()
+
+	// Changing a status needs the admin token
+	req, err := http.NewRequest(http.MethodPatch, fmt.Sprintf("%s/v1/transactions/%d/status", server.URL, completed.ID), bytes.NewBufferString(`{"status":"REFUNDED"}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	unauthorized, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	unauthorized.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, unauthorized.StatusCode)
+
+	// A pending transaction failing never touched a settlement
+	resp, change := patchStatus(pending.ID, "FAILED")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, change.StaleSettlements)
+	assert.Empty(t, listStale())
+
+	// Refunding a settled transaction flags its settlement
+	resp, change = patchStatus(completed.ID, "REFUNDED")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, models.TransactionStatusCompleted, change.PreviousStatus)
+	assert.Equal(t, models.TransactionStatusRefunded, change.Transaction.Status)
+	require.Len(t, change.StaleSettlements, 1)
+	assert.Equal(t, 10000, change.StaleSettlements[0].GrossCents)
+
+	stale := listStale()
+	require.Len(t, stale, 1)
+	assert.Equal(t, "merchant_stale", stale[0].MerchantID)
+	assert.NotNil(t, stale[0].StaleSince)
+
+	// Refunds are final
+	resp, _ = patchStatus(completed.ID, "COMPLETED")
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	// Re-settling the day replaces the stale row
+	settle()
+	assert.Empty(t, listStale())
+}
+
+func TestLateTransactionResettle(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	ctx := context.Background()
+	txRepo := repository.NewTransactionRepository(db.DB)
+	settleRepo := repository.NewSettlementRepository(db.DB)
+
+	day := time.Date(2025, 5, 6, 0, 0, 0, 0, time.UTC)
+	paidAt := day.Add(10 * time.Hour)
+	require.NoError(t, txRepo.Create(ctx, &models.Transaction{MerchantID: "merchant_late", AmountCents: 10000, FeeCents: 300, Status: models.TransactionStatusCompleted, PaidAt: paidAt}))
+	require.NoError(t, txRepo.Create(ctx, &models.Transaction{MerchantID: "merchant_ontime", AmountCents: 8000, FeeCents: 240, Status: models.TransactionStatusCompleted, PaidAt: paidAt}))
+
+	resp, err := http.Post(server.URL+"/v1/jobs/settlement", "application/json", bytes.NewBufferString(`{"from":"2025-05-06","to":"2025-05-06"}`))
+	require.NoError(t, err)
+	var jobResp map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&jobResp))
+	resp.Body.Close()
+	job := waitForJob(t, server, jobResp["job_id"].(string))
+	require.Equal(t, "COMPLETED", job["status"], "job error: %v", job["error"])
+
+	detect := func() models.StaleDetection {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/settlements/stale/detect", bytes.NewBufferString(`{"from":"2025-05-06","to":"2025-05-06"}`))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Admin-Token", testAdminToken)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var detection models.StaleDetection
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&detection))
+		return detection
+	}
+
+	// Flagging settlements needs the admin token
+	resp, err = http.Post(server.URL+"/v1/settlements/stale/detect", "application/json", bytes.NewBufferString(`{}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// Nothing arrived after the run
+	detection := detect()
+	assert.Equal(t, 2, detection.Checked)
+	assert.Empty(t, detection.Flagged)
+
+	resp, err = http.Post(server.URL+"/v1/jobs/resettle", "application/json", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	// A payment for the settled day arrives late
+	require.NoError(t, txRepo.Create(ctx, &models.Transaction{MerchantID: "merchant_late", AmountCents: 5000, FeeCents: 150, Status: models.TransactionStatusCompleted, PaidAt: paidAt.Add(time.Hour)}))
+
+	detection = detect()
+	require.Len(t, detection.Flagged, 1)
+	assert.Equal(t, "merchant_late", detection.Flagged[0].MerchantID)
+	assert.NotNil(t, detection.Flagged[0].StaleReason)
+
+	// Only the affected merchant-day is re-settled
+	resp, err = http.Post(server.URL+"/v1/jobs/resettle", "application/json", nil)
+	require.NoError(t, err)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&jobResp))
+	resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.EqualValues(t, 1, jobResp["total"])
+
+	job = waitForJob(t, server, jobResp["job_id"].(string))
+	require.Equal(t, "COMPLETED", job["status"], "job error: %v", job["error"])
+
+	late, err := settleRepo.GetByMerchantAndDate(ctx, "merchant_late", day)
+	require.NoError(t, err)
+	assert.Equal(t, 15000, late.GrossCents)
+	assert.Equal(t, 2, late.TxnCount)
+	assert.Nil(t, late.StaleSince)
+	assert.Equal(t, jobResp["job_id"], late.UniqueRunID.String())
+
+	ontime, err := settleRepo.GetByMerchantAndDate(ctx, "merchant_ontime", day)
+	require.NoError(t, err)
+	assert.NotEqual(t, jobResp["job_id"], ontime.UniqueRunID.String())
+	assert.Nil(t, ontime.CutoffAt)
+
+	assert.Empty(t, detect().Flagged)
+
+	// Without a cutoff, late transactions are re-settled rather than reported
+	resp, err = http.Get(server.URL + "/v1/settlements/late-transactions?from=2025-05-06&to=2025-05-06")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var report models.LateTransactionReport
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	assert.Equal(t, "2025-05-06", report.From)
+	assert.Empty(t, report.Transactions)
+}
+
+func TestSettlementJobQuota(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	// Two settlement jobs are already queued for this client
+	for i := 0; i < 2; i++ {
+		_, err := db.Exec(`
+			INSERT INTO jobs (id, type, status, parameters, created_by)
+			VALUES ($1, $2, $3, '{}', $4)`,
+			uuid.New(), models.JobTypeSettlement, models.JobStatusQueued, "quota-client")
+		require.NoError(t, err)
+	}
+
+	createJob := func(clientID string) *http.Response {
+		body := []byte(`{"from":"2001-01-01","to":"2001-01-01"}`)
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/jobs/settlement", bytes.NewBuffer(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(handlers.ClientIDHeader, clientID)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := createJob("quota-client")
+	var errResp apperrors.ErrorResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&errResp))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, apperrors.ErrCodeQuotaExceeded, errResp.Error.Code)
+
+	// Other clients are unaffected
+	resp = createJob("other-client")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+}
+
+// TestJobRangeLockConflict tests that a job can't lock a date range
+// overlapping one held by a running or cancelling job of the same type, and
+// that the conflict names the blocking job
+func TestJobRangeLockConflict(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+	jobRepo := repository.NewJobRepository(db.DB)
+
+	day := func(d int) time.Time { return time.Date(2025, 1, d, 0, 0, 0, 0, time.UTC) }
+	insertJob := func(jobType models.JobType, status models.JobStatus) uuid.UUID {
+		id := uuid.New()
+		_, err := db.Exec(`INSERT INTO jobs (id, type, status, parameters) VALUES ($1, $2, $3, '{}')`, id, jobType, status)
+		require.NoError(t, err)
+		return id
+	}
+	acquire := func(id uuid.UUID, jobType models.JobType, from, to time.Time) error {
+		return db.WithTx(ctx, nil, func(tx *sql.Tx) error {
+			return jobRepo.AcquireLock(ctx, tx, &models.JobLock{JobID: id, JobType: jobType, RangeFrom: from, RangeTo: to})
+		})
+	}
+	assertBlockedBy := func(err error, blocking uuid.UUID) {
+		t.Helper()
+		var appErr *apperrors.AppError
+		require.ErrorAs(t, err, &appErr)
+		assert.Equal(t, apperrors.ErrCodeConflict, appErr.Code)
+		assert.Equal(t, http.StatusConflict, appErr.StatusCode)
+		assert.Equal(t, "blocking_job_id="+blocking.String(), appErr.Details)
+	}
+
+	holder := insertJob(models.JobTypeSettlement, models.JobStatusRunning)
+	require.NoError(t, acquire(holder, models.JobTypeSettlement, day(10), day(20)))
+
+	// Overlapping at either end or inside is refused
+	for _, r := range [][2]int{{5, 10}, {20, 25}, {12, 15}, {1, 31}} {
+		err := acquire(insertJob(models.JobTypeSettlement, models.JobStatusRunning), models.JobTypeSettlement, day(r[0]), day(r[1]))
+		assertBlockedBy(err, holder)
+	}
+
+	// Adjacent ranges and other job types are not blocked
+	require.NoError(t, acquire(insertJob(models.JobTypeSettlement, models.JobStatusRunning), models.JobTypeSettlement, day(21), day(25)))
+	require.NoError(t, acquire(insertJob(models.JobTypeResettle, models.JobStatusRunning), models.JobTypeResettle, day(10), day(20)))
+
+	// A job being cancelled holds its range until its worker stops
+	status, err := jobRepo.Cancel(ctx, holder)
+	require.NoError(t, err)
+	require.Equal(t, models.JobStatusCancelling, status)
+	assertBlockedBy(acquire(insertJob(models.JobTypeSettlement, models.JobStatusRunning), models.JobTypeSettlement, day(15), day(16)), holder)
+
+	// Once it is cancelled its lock is stale
+	require.NoError(t, jobRepo.MarkCancelled(ctx, holder))
+	require.NoError(t, acquire(insertJob(models.JobTypeSettlement, models.JobStatusRunning), models.JobTypeSettlement, day(15), day(16)))
+}
+
+func TestHealthCheck(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	resp, err := http.Get(server.URL + "/health")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var health models.HealthCheck
+	err = json.NewDecoder(resp.Body).Decode(&health)
+	require.NoError(t, err)
 
 	assert.Equal(t, "healthy", health.Status)
 	assert.Equal(t, "1.0.0", health.Version)
 	assert.Contains(t, health.Checks, "database")
 	assert.Equal(t, "healthy", health.Checks["database"])
+	assert.Equal(t, "healthy", health.Results["database"].Status)
+	assert.Equal(t, "healthy", health.Results["storage"].Status)
+	assert.Empty(t, health.Results["database"].Error)
+}
+
+func TestErrorCatalog(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	resp, err := http.Get(server.URL + "/errors")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var result struct {
+		Errors []apperrors.CatalogEntry `json:"errors"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	require.NotEmpty(t, result.Errors)
+
+	// A problem type URI from an error response resolves to its entry
+	resp, err = http.Get(server.URL + "/errors/out-of-stock")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var entry apperrors.CatalogEntry
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&entry))
+	assert.Equal(t, "OUT_OF_STOCK", entry.Code)
+	assert.Equal(t, []int{http.StatusConflict}, entry.Statuses)
+
+	resp, err = http.Get(server.URL + "/errors/no-such-error")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestCORSAllowedOrigins(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	get := func(origin string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/health", nil)
+		require.NoError(t, err)
+		req.Header.Set("Origin", origin)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	// A listed origin is echoed back
+	resp := get("https://dashboard.example.com")
+	assert.Equal(t, "https://dashboard.example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, resp.Header.Values("Vary"), "Origin")
+
+	// Any other origin gets no grant, so browsers can't read the response
+	resp = get("https://evil.example.com")
+	assert.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+}
+
+// TestSecurityHeaders tests that responses, errors included, carry the
+// security headers
+func TestSecurityHeaders(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	for _, path := range []string{"/health", "/v1/products/999999", "/v1/missing"} {
+		resp, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, "max-age=31536000", resp.Header.Get("Strict-Transport-Security"), path)
+		assert.Equal(t, "nosniff", resp.Header.Get("X-Content-Type-Options"), path)
+		assert.Equal(t, "DENY", resp.Header.Get("X-Frame-Options"), path)
+		assert.Equal(t, "default-src 'none'; frame-ancestors 'none'", resp.Header.Get("Content-Security-Policy"), path)
+		assert.Empty(t, resp.Header.Get("Server"), path)
+	}
+}
+
+func TestLegacyRoutesDeprecated(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	// Versioned routes are served without deprecation headers
+	resp, err := http.Get(server.URL + "/v1/orders")
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "v1", resp.Header.Get("API-Version"))
+	assert.Empty(t, resp.Header.Get("Deprecation"))
+
+	// Unprefixed routes still work but point at their successor
+	resp, err = http.Get(server.URL + "/orders")
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "true", resp.Header.Get("Deprecation"))
+	assert.Equal(t, `</v1/orders>; rel="successor-version"`, resp.Header.Get("Link"))
+}
+
+func TestProductConditionalGet(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	product := createTestProduct(t, db, 10)
+	url := fmt.Sprintf("%s/v1/products/%d", server.URL, product.ID)
+
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	etag := resp.Header.Get("ETag")
+	require.NotEmpty(t, etag)
+	assert.NotEmpty(t, resp.Header.Get("Last-Modified"))
+
+	// A matching ETag yields 304 with no body
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	req.Header.Set("If-None-Match", etag)
+
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	// Once stock changes, the cached copy is no longer current
+	_, err = db.Exec("UPDATE products SET stock = stock - 1, version = version + 1, updated_at = NOW() WHERE id = $1", product.ID)
+	require.NoError(t, err)
+
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotEqual(t, etag, resp.Header.Get("ETag"))
+}
+
+func TestProductSKULookup(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	ctx := context.Background()
+	productRepo := repository.NewProductRepository(db.DB, nil)
+
+	sku := "SKU-TEST-001"
+	product := &models.Product{Name: "SKU Product", SKU: &sku, Stock: 5, Price: 500}
+	require.NoError(t, productRepo.Create(ctx, product))
+
+	// A second product can't reuse the SKU
+	duplicate := &models.Product{Name: "Duplicate", SKU: &sku, Stock: 1, Price: 100}
+	assert.Equal(t, apperrors.ErrDuplicateSKU, productRepo.Create(ctx, duplicate))
+
+	resp, err := http.Get(server.URL + "/v1/products/by-sku/" + sku)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var found models.Product
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&found))
+	assert.Equal(t, product.ID, found.ID)
+
+	resp, err = http.Get(server.URL + "/v1/products/by-sku/UNKNOWN")
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+// waitForJob polls a job until it reaches a terminal status
+func waitForJob(t *testing.T, server *httptest.Server, jobID string) map[string]interface{} {
+	t.Helper()
+
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := http.Get(server.URL + "/v1/jobs/" + jobID)
+		require.NoError(t, err)
+
+		var job map[string]interface{}
+		err = json.NewDecoder(resp.Body).Decode(&job)
+		resp.Body.Close()
+		require.NoError(t, err)
+
+		switch job["status"] {
+		case "COMPLETED", "FAILED", "CANCELLED", "DEAD_LETTERED":
+			return job
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	t.Fatal("Job did not finish within timeout")
+	return nil
+}
+
+func TestJobStats(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	ctx := context.Background()
+	txRepo := repository.NewTransactionRepository(db.DB)
+	yesterday := time.Now().AddDate(0, 0, -1)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, txRepo.Create(ctx, &models.Transaction{
+			MerchantID:  "merchant_stats",
+			AmountCents: 1000,
+			FeeCents:    30,
+			Status:      models.TransactionStatusCompleted,
+			PaidAt:      yesterday,
+		}))
+	}
+
+	reqBody, _ := json.Marshal(models.CreateSettlementJobRequest{
+		From: yesterday.Format("2006-01-02"),
+		To:   yesterday.Format("2006-01-02"),
+	})
+	resp, err := http.Post(server.URL+"/v1/jobs/settlement", "application/json", bytes.NewBuffer(reqBody))
+	require.NoError(t, err)
+	var jobResp map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&jobResp))
+	resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	jobID := jobResp["job_id"].(string)
+	job := waitForJob(t, server, jobID)
+	require.Equal(t, "COMPLETED", job["status"], "job error: %v", job["error"])
+
+	// Stats are saved just after the job is marked finished
+	var stats models.JobStats
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(server.URL + "/v1/jobs/" + jobID + "/stats")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return false
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&stats))
+		return true
+	}, 5*time.Second, 100*time.Millisecond)
+
+	assert.Equal(t, models.JobTypeSettlement, stats.JobType)
+	assert.Equal(t, models.JobStatusCompleted, stats.Status)
+	assert.Equal(t, 1, stats.Attempts)
+	assert.Equal(t, int64(3), stats.RowsProcessed)
+	assert.InDelta(t, stats.DurationMS, stats.DBTimeMS+stats.ComputeTimeMS, 1)
+	assert.NotZero(t, stats.PeakMemoryBytes)
+
+	// Unknown jobs have no stats
+	resp, err = http.Get(server.URL + "/v1/jobs/" + uuid.New().String() + "/stats")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestJobLogs(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	ctx := context.Background()
+	txRepo := repository.NewTransactionRepository(db.DB)
+	yesterday := time.Now().AddDate(0, 0, -1)
+	require.NoError(t, txRepo.Create(ctx, &models.Transaction{
+		MerchantID:  "merchant_logs",
+		AmountCents: 1000,
+		FeeCents:    30,
+		Status:      models.TransactionStatusCompleted,
+		PaidAt:      yesterday,
+	}))
+
+	reqBody, _ := json.Marshal(models.CreateSettlementJobRequest{
+		From: yesterday.Format("2006-01-02"),
+		To:   yesterday.Format("2006-01-02"),
+	})
+	resp, err := http.Post(server.URL+"/v1/jobs/settlement", "application/json", bytes.NewBuffer(reqBody))
+	require.NoError(t, err)
+	var jobResp map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&jobResp))
+	resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	jobID := jobResp["job_id"].(string)
+	job := waitForJob(t, server, jobID)
+	require.Equal(t, "COMPLETED", job["status"], "job error: %v", job["error"])
+
+	getLogs := func(query string) (int, models.JobLogs) {
+		resp, err := http.Get(server.URL + "/v1/jobs/" + jobID + "/logs" + query)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var logs models.JobLogs
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&logs))
+		}
+		return resp.StatusCode, logs
+	}
+
+	// Lines are written in the background, about once a second
+	var logs models.JobLogs
+	require.Eventually(t, func() bool {
+		_, logs = getLogs("")
+		for _, line := range logs.Logs {
+			if line.Message == "Job processing completed successfully" {
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 200*time.Millisecond)
+
+	assert.Equal(t, "Processing job", logs.Logs[0].Message)
+	assert.Equal(t, logs.Logs[len(logs.Logs)-1].ID, logs.NextAfter)
+	for _, line := range logs.Logs {
+		assert.Equal(t, jobID, line.JobID.String())
+	}
+
+	// Paging continues after the last line seen
+	status, page := getLogs("?limit=1")
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, page.Logs, 1)
+	_, next := getLogs("?limit=1&after=" + strconv.FormatInt(page.NextAfter, 10))
+	require.Len(t, next.Logs, 1)
+	assert.Greater(t, next.Logs[0].ID, page.Logs[0].ID)
+
+	// A successful job logged no errors
+	status, errorLogs := getLogs("?level=error")
+	require.Equal(t, http.StatusOK, status)
+	assert.Empty(t, errorLogs.Logs)
+
+	status, _ = getLogs("?level=verbose")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	resp, err = http.Get(server.URL + "/v1/jobs/" + uuid.New().String() + "/logs")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestReorderForecastJob(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	// Sell 14 units of a product that has 6 left
+	product := createTestProduct(t, db, 20)
+	for i := 0; i < 14; i++ {
+		orderReq := models.CreateOrderRequest{ProductID: product.ID, Quantity: 1, BuyerID: fmt.Sprintf("buyer_%d", i)}
+		reqBody, _ := json.Marshal(orderReq)
+		resp, err := http.Post(server.URL+"/v1/orders", "application/json", bytes.NewBuffer(reqBody))
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	// Orders placed today fall outside the lookback window, so backdate them
+	_, err := db.Exec("UPDATE orders SET created_at = NOW() - INTERVAL '1 day' WHERE product_id = $1", product.ID)
+	require.NoError(t, err)
+
+	reqBody := []byte(`{"model":"SMA","lookback_days":7,"lead_time_days":2,"safety_days":1,"review_days":7}`)
+	resp, err := http.Post(server.URL+"/v1/jobs/reorder-forecast", "application/json", bytes.NewBuffer(reqBody))
+	require.NoError(t, err)
+
+	var jobResp map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&jobResp))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	jobID := jobResp["job_id"].(string)
+	job := waitForJob(t, server, jobID)
+	require.Equal(t, "COMPLETED", job["status"], "job error: %v", job["error"])
+	assert.Contains(t, job, "download_url")
+
+	resp, err = http.Get(server.URL + "/v1/jobs/" + jobID + "/reorder-recommendations")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var result struct {
+		Recommendations []models.ReorderRecommendation `json:"recommendations"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+
+	// 14 units over 7 days is 2/day: reorder at 2*(2+1)=6, order up to 2*(2+1+7)=20
+	var rec *models.ReorderRecommendation
+	for i := range result.Recommendations {
+		if result.Recommendations[i].ProductID == product.ID {
+			rec = &result.Recommendations[i]
+		}
+	}
+	require.NotNil(t, rec)
+	assert.InDelta(t, 2.0, rec.DailyVelocity, 0.001)
+	assert.Equal(t, 6, rec.CurrentStock)
+	assert.Equal(t, 6, rec.ReorderPoint)
+	assert.Equal(t, 14, rec.RecommendedQuantity)
+}
+
+func TestOrdersExportJob(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	product := createTestProduct(t, db, 10)
+	for _, buyer := range []string{"export_buyer", "export_buyer", "other_buyer"} {
+		orderReq := models.CreateOrderRequest{ProductID: product.ID, Quantity: 1, BuyerID: buyer}
+		reqBody, _ := json.Marshal(orderReq)
+		resp, err := http.Post(server.URL+"/v1/orders", "application/json", bytes.NewBuffer(reqBody))
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	today := time.Now().UTC().Format("2006-01-02")
+	reqBody := []byte(fmt.Sprintf(`{"status":"CONFIRMED","buyer_id":"export_buyer","from":"%s","to":"%s","format":"json"}`, today, today))
+	resp, err := http.Post(server.URL+"/v1/jobs/orders-export", "application/json", bytes.NewBuffer(reqBody))
+	require.NoError(t, err)
+
+	var jobResp map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&jobResp))
+	resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	job := waitForJob(t, server, jobResp["job_id"].(string))
+	require.Equal(t, "COMPLETED", job["status"], "job error: %v", job["error"])
+	downloadURL := job["download_url"].(string)
+	assert.True(t, strings.HasSuffix(downloadURL, ".json"))
+
+	resp, err = http.Get(server.URL + downloadURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	var orders []models.Order
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&orders))
+	require.Len(t, orders, 2)
+	for _, order := range orders {
+		assert.Equal(t, "export_buyer", order.BuyerID)
+		assert.Equal(t, models.OrderStatusConfirmed, order.Status)
+	}
+
+	// Unknown formats are rejected up front
+	resp, err = http.Post(server.URL+"/v1/jobs/orders-export", "application/json", bytes.NewBufferString(`{"format":"xml"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestMerchantStatementJob(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	ctx := context.Background()
+	txRepo := repository.NewTransactionRepository(db.DB)
+	for _, day := range []int{6, 7} {
+		paidAt := time.Date(2025, 3, day, 10, 0, 0, 0, time.UTC)
+		require.NoError(t, txRepo.Create(ctx, &models.Transaction{MerchantID: "merchant_statement", AmountCents: 123456, FeeCents: 3704, Status: models.TransactionStatusCompleted, PaidAt: paidAt}))
+	}
+
+	resp, err := http.Post(server.URL+"/v1/jobs/settlement", "application/json", bytes.NewBufferString(`{"from":"2025-03-01","to":"2025-03-31"}`))
+	require.NoError(t, err)
+	var jobResp map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&jobResp))
+	resp.Body.Close()
+	job := waitForJob(t, server, jobResp["job_id"].(string))
+	require.Equal(t, "COMPLETED", job["status"], "job error: %v", job["error"])
+
+	resp, err = http.Post(server.URL+"/v1/jobs/merchant-statement", "application/json", bytes.NewBufferString(`{"merchant_id":"merchant_statement","month":"2025-03"}`))
+	require.NoError(t, err)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&jobResp))
+	resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	job = waitForJob(t, server, jobResp["job_id"].(string))
+	require.Equal(t, "COMPLETED", job["status"], "job error: %v", job["error"])
+	downloadURL := job["download_url"].(string)
+	assert.True(t, strings.HasSuffix(downloadURL, ".pdf"))
+
+	resp, err = http.Get(server.URL + downloadURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/pdf", resp.Header.Get("Content-Type"))
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(body, []byte("%PDF-")))
+	assert.Contains(t, string(body), "(2025-03-06)")
+	assert.Contains(t, string(body), "(2,469.12)") // total gross
+
+	// Months must be YYYY-MM
+	resp, err = http.Post(server.URL+"/v1/jobs/merchant-statement", "application/json", bytes.NewBufferString(`{"merchant_id":"merchant_statement","month":"2025-03-01"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestDeadLetterRedrive(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	// A job created through the API that can succeed once re-driven, dead
+	// lettered after it ran so its parameters are read back from the database
+	resp, err := http.Post(server.URL+"/v1/jobs/orders-export", "application/json", bytes.NewBufferString(`{"format":"csv"}`))
+	require.NoError(t, err)
+	var created map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	exportID := uuid.MustParse(created["job_id"].(string))
+	waitForJob(t, server, exportID.String())
+
+	_, err = db.Exec(`
+		UPDATE jobs SET status = $2, attempts = 4, error = 'connection reset', dead_lettered_at = NOW()
+		WHERE id = $1`, exportID, models.JobStatusDeadLettered)
+	require.NoError(t, err)
+
+	// One job that keeps failing
+	bogusID := uuid.New()
+	_, err = db.Exec(`
+		INSERT INTO jobs (id, type, status, parameters, attempts, error, dead_lettered_at)
+		VALUES ($1, 'BOGUS', $2, '{}', 4, 'connection reset', NOW())`,
+		bogusID, models.JobStatusDeadLettered)
+	require.NoError(t, err)
+
+	var stored string
+	require.NoError(t, db.QueryRow(`SELECT jsonb_typeof(parameters) FROM jobs WHERE id = $1`, exportID).Scan(&stored))
+	assert.Equal(t, "object", stored, "parameters are stored as a JSON object")
+
+	resp, err = http.Get(server.URL + "/v1/jobs/dead-letter")
+	require.NoError(t, err)
+	var list struct {
+		Jobs []models.Job `json:"jobs"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	resp.Body.Close()
+	assert.Len(t, list.Jobs, 2)
+
+	redrive := func(id uuid.UUID, token string) int {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/jobs/"+id.String()+"/redrive", nil)
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("X-Admin-Token", token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Re-driving is an admin operation
+	require.Equal(t, http.StatusUnauthorized, redrive(exportID, ""))
+
+	require.Equal(t, http.StatusAccepted, redrive(exportID, testAdminToken))
+	job := waitForJob(t, server, exportID.String())
+	assert.Equal(t, "COMPLETED", job["status"], "job error: %v", job["error"])
+
+	// A job that fails again without retries left goes back to the dead-letter queue
+	require.Equal(t, http.StatusAccepted, redrive(bogusID, testAdminToken))
+	job = waitForJob(t, server, bogusID.String())
+	assert.Equal(t, "DEAD_LETTERED", job["status"])
+	assert.Contains(t, job["error"], "unknown job type")
+
+	// Only dead-lettered jobs can be re-driven
+	assert.Equal(t, http.StatusConflict, redrive(exportID, testAdminToken))
+}
+
+func TestJobRecoveryAfterRestart(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	jobRepo := repository.NewJobRepository(db.DB)
+
+	// Jobs a previous process created and left queued or running, through
+	// the same Create the services use
+	params, err := json.Marshal(models.OrdersExportJobParams{Format: models.ExportFormatCSV})
+	require.NoError(t, err)
+	queued := &models.Job{ID: uuid.New(), Type: models.JobTypeOrdersExport, Status: models.JobStatusQueued, Parameters: string(params)}
+	running := &models.Job{ID: uuid.New(), Type: models.JobTypeOrdersExport, Status: models.JobStatusQueued, Parameters: string(params)}
+	for _, job := range []*models.Job{queued, running} {
+		require.NoError(t, jobRepo.Create(ctx, job))
+	}
+	started, err := jobRepo.MarkStarted(ctx, running.ID)
+	require.NoError(t, err)
+	require.True(t, started)
+	_, err = db.Exec(`UPDATE jobs SET updated_at = NOW() - INTERVAL '1 minute'`)
+	require.NoError(t, err)
+
+	// A new process picks both up and runs them
+	orderRepo := repository.NewOrderRepository(db.DB, nil)
+	jobConfig := &config.JobsConfig{Workers: 1, BatchSize: 100, QueueSize: 10, RetryAttempts: 1, LogMaxLines: 100}
+	jobProcessor := service.NewJobProcessor(db, jobConfig, repository.NewTransactionRepository(db.DB), repository.NewSettlementRepository(db.DB),
+		jobRepo, repository.NewProductRepository(db.DB, nil), orderRepo, repository.NewForecastRepository(db.DB), repository.NewCalendarRepository(db.DB),
+		repository.NewSagaRepository(db.DB), repository.NewPayoutRepository(db.DB), repository.NewWebhookRepository(db.DB), &config.SettlementConfig{}, nil, nil,
+		service.NewMaintenanceMode(repository.NewMaintenanceRepository(db.DB), 0), nil)
+	jobProcessor.Start()
+	t.Cleanup(jobProcessor.Stop)
+
+	for _, id := range []uuid.UUID{queued.ID, running.ID} {
+		require.Eventually(t, func() bool {
+			job, err := jobRepo.GetByID(ctx, id)
+			require.NoError(t, err)
+			return job.Status == models.JobStatusCompleted
+		}, 30*time.Second, 200*time.Millisecond, "recovered job %s did not complete", id)
+	}
+}
+
+func TestListJobs(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	// Jobs created a minute apart, oldest first
+	created := time.Now().Add(-time.Hour)
+	running, queued, export := uuid.New(), uuid.New(), uuid.New()
+	for i, job := range []struct {
+		id      uuid.UUID
+		jobType models.JobType
+		status  models.JobStatus
+	}{
+		{running, models.JobTypeSettlement, models.JobStatusRunning},
+		{queued, models.JobTypeSettlement, models.JobStatusQueued},
+		{export, models.JobTypeOrdersExport, models.JobStatusRunning},
+	} {
+		_, err := db.Exec(`
+			INSERT INTO jobs (id, type, status, parameters, created_at, updated_at)
+			VALUES ($1, $2, $3, '{}', $4, $4)`,
+			job.id, job.jobType, job.status, created.Add(time.Duration(i)*time.Minute))
+		require.NoError(t, err)
+	}
+
+	list := func(query string) (int, []uuid.UUID) {
+		resp, err := http.Get(server.URL + "/v1/jobs" + query)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var page struct {
+			Jobs []models.Job `json:"jobs"`
+		}
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+		}
+		ids := make([]uuid.UUID, len(page.Jobs))
+		for i, job := range page.Jobs {
+			ids[i] = job.ID
+		}
+		return resp.StatusCode, ids
+	}
+
+	status, ids := list("")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []uuid.UUID{export, queued, running}, ids)
+
+	_, ids = list("?status=RUNNING&type=SETTLEMENT")
+	assert.Equal(t, []uuid.UUID{running}, ids)
+
+	_, ids = list("?status=RUNNING&limit=1&offset=1")
+	assert.Equal(t, []uuid.UUID{running}, ids)
+
+	status, _ = list("?status=PAUSED")
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = list("?type=BOGUS")
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestMerchantDashboard(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	ctx := context.Background()
+	txRepo := repository.NewTransactionRepository(db.DB)
+
+	paidAt := time.Now().UTC().AddDate(0, 0, -1)
+	transactions := []*models.Transaction{
+		{MerchantID: "merchant_dash", AmountCents: 10000, FeeCents: 300, Status: models.TransactionStatusCompleted, PaidAt: paidAt},
+		{MerchantID: "merchant_dash", AmountCents: 5000, FeeCents: 150, Status: models.TransactionStatusCompleted, PaidAt: paidAt},
+		{MerchantID: "merchant_dash", AmountCents: 7000, FeeCents: 210, Status: models.TransactionStatusPending, PaidAt: paidAt},
+		{MerchantID: "merchant_other", AmountCents: 9000, FeeCents: 270, Status: models.TransactionStatusCompleted, PaidAt: paidAt},
+	}
+	for _, tx := range transactions {
+		require.NoError(t, txRepo.Create(ctx, tx))
+	}
+
+	day := paidAt.Format("2006-01-02")
+	resp, err := http.Get(server.URL + "/v1/merchants/merchant_dash/dashboard?from=" + day + "&to=" + day)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var dashboard models.MerchantDashboard
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&dashboard))
+
+	assert.Equal(t, "merchant_dash", dashboard.MerchantID)
+	assert.Equal(t, 15000, dashboard.Transactions.GrossCents)
+	assert.Equal(t, 14550, dashboard.Transactions.NetCents)
+	assert.Equal(t, 2, dashboard.Transactions.CompletedCount)
+	assert.Equal(t, 1, dashboard.Transactions.PendingCount)
+
+	// Nothing has been settled yet, so all completed volume is unsettled
+	assert.Equal(t, 0, dashboard.Settlements.SettledDays)
+	assert.Equal(t, 14550, dashboard.Unsettled.NetCents)
+	assert.Equal(t, 2, dashboard.Unsettled.TxnCount)
+	assert.Equal(t, 0, dashboard.PendingPayoutCents)
+
+	// Bad dates are rejected
+	resp2, err := http.Get(server.URL + "/v1/merchants/merchant_dash/dashboard?from=nope")
+	require.NoError(t, err)
+	resp2.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp2.StatusCode)
+}
+
+func TestSettlementAdjustments(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	ctx := context.Background()
+	txRepo := repository.NewTransactionRepository(db.DB)
+
+	day := time.Date(2025, 4, 8, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, txRepo.Create(ctx, &models.Transaction{MerchantID: "merchant_adj", AmountCents: 10000, FeeCents: 300, Status: models.TransactionStatusCompleted, PaidAt: day.Add(9 * time.Hour)}))
+
+	postAdjustment := func(body string, admin bool) *http.Response {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/v1/settlements/adjustments", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if admin {
+			req.Header.Set("X-Admin-Token", testAdminToken)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	// Posting needs the admin token
+	resp := postAdjustment(`{"merchant_id":"merchant_adj","date":"2025-04-08","amount_cents":-2500,"reason_code":"CHARGEBACK"}`, false)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = postAdjustment(`{"merchant_id":"merchant_adj","date":"2025-04-08","amount_cents":-2500,"reason_code":"LOST"}`, true)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = postAdjustment(`{"merchant_id":"merchant_adj","date":"2025-04-08","amount_cents":-2500,"reason_code":"CHARGEBACK","note":"order 4411"}`, true)
+	var adjustment models.SettlementAdjustment
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&adjustment))
+	resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, -2500, adjustment.AmountCents)
+	assert.Equal(t, models.AdjustmentReasonChargeback, adjustment.ReasonCode)
+
+	resp, err := http.Get(server.URL + "/v1/settlements/adjustments?merchant_id=merchant_adj&from=2025-04-01&to=2025-04-30")
+	require.NoError(t, err)
+	var listed struct {
+		Adjustments []*models.SettlementAdjustment `json:"adjustments"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&listed))
+	resp.Body.Close()
+	require.Len(t, listed.Adjustments, 1)
+	assert.Equal(t, adjustment.ID, listed.Adjustments[0].ID)
+
+	// The settlement file lists the adjustment as its own line
+	resp, err = http.Post(server.URL+"/v1/jobs/settlement", "application/json", bytes.NewBufferString(`{"from":"2025-04-08","to":"2025-04-08"}`))
+	require.NoError(t, err)
+	var jobResp map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&jobResp))
+	resp.Body.Close()
+	jobID := jobResp["job_id"].(string)
+	job := waitForJob(t, server, jobID)
+	require.Equal(t, "COMPLETED", job["status"], "job error: %v", job["error"])
+
+	resp, err = http.Get(server.URL + "/v1/downloads/" + jobID + ".csv")
+	require.NoError(t, err)
+	rows, err := csv.NewReader(resp.Body).ReadAll()
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, []string{"merchant_adj", "2025-04-08", "10000", "300", "9700", "1"}, rows[1][:6])
+	assert.Equal(t, "settlement", rows[1][8])
+	assert.Equal(t, []string{"merchant_adj", "2025-04-08", "0", "0", "-2500", "0"}, rows[2][:6])
+	assert.Equal(t, []string{"adjustment", "CHARGEBACK"}, rows[2][8:])
+
+	// Only the format the job wrote can be downloaded
+	resp, err = http.Get(server.URL + "/v1/downloads/" + jobID + ".pdf")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Adjustments count towards the pending payout
+	resp, err = http.Get(server.URL + "/v1/merchants/merchant_adj/dashboard?from=2025-04-08&to=2025-04-08")
+	require.NoError(t, err)
+	var dashboard models.MerchantDashboard
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&dashboard))
+	resp.Body.Close()
+	assert.Equal(t, 9700, dashboard.Settlements.NetCents)
+	assert.Equal(t, -2500, dashboard.Settlements.AdjustmentCents)
+	assert.Equal(t, 7200, dashboard.PendingPayoutCents)
+}
+
+// TestPayoutBankFiles tests that an approved payout is exported once, to a
+// bank file only the admin can download
+func TestPayoutBankFiles(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	ctx := context.Background()
+	txRepo := repository.NewTransactionRepository(db.DB)
+
+	day := time.Date(2025, 4, 8, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, txRepo.Create(ctx, &models.Transaction{MerchantID: "merchant_pay", AmountCents: 10000, FeeCents: 300, Status: models.TransactionStatusCompleted, PaidAt: day.Add(9 * time.Hour)}))
+
+	send := func(method, path, body string, admin bool) *http.Response {
+		req, _ := http.NewRequest(method, server.URL+path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if admin {
+			req.Header.Set("X-Admin-Token", testAdminToken)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+	errorCode := func(resp *http.Response) string {
+		var body apperrors.ErrorResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		resp.Body.Close()
+		return body.Error.Code
+	}
+
+	// Settle the day
+	resp := send(http.MethodPost, "/v1/jobs/settlement", `{"from":"2025-04-08","to":"2025-04-08"}`, false)
+	var jobResp map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&jobResp))
+	resp.Body.Close()
+	job := waitForJob(t, server, jobResp["job_id"].(string))
+	require.Equal(t, "COMPLETED", job["status"], "job error: %v", job["error"])
+
+	// Bank accounts need the admin token and a valid routing number
+	resp = send(http.MethodPut, "/v1/merchants/merchant_pay/bank-account", `{"scheme":"NACHA","account_name":"Pay Shop","routing_number":"011000015","account_number":"12345"}`, false)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = send(http.MethodPut, "/v1/merchants/merchant_pay/bank-account", `{"scheme":"NACHA","account_name":"Pay Shop","routing_number":"011000016","account_number":"12345"}`, true)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "VALIDATION_ERROR", errorCode(resp))
+
+	// A merchant without an account can't be paid
+	resp = send(http.MethodPost, "/v1/payouts", `{"merchant_id":"merchant_pay","from":"2025-04-08","to":"2025-04-08"}`, true)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, "BANK_ACCOUNT_NOT_FOUND", errorCode(resp))
+
+	resp = send(http.MethodPut, "/v1/merchants/merchant_pay/bank-account", `{"scheme":"NACHA","account_name":"Pay Shop","routing_number":"011000015","account_number":"12345"}`, true)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = send(http.MethodPost, "/v1/payouts", `{"merchant_id":"merchant_pay","from":"2025-04-01","to":"2025-04-08"}`, true)
+	var payout models.Payout
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&payout))
+	resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, 9700, payout.AmountCents)
+	assert.Equal(t, models.PayoutStatusPending, payout.Status)
+
+	// Periods can't be paid twice
+	resp = send(http.MethodPost, "/v1/payouts", `{"merchant_id":"merchant_pay","from":"2025-04-08","to":"2025-04-30"}`, true)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Equal(t, "PAYOUT_OVERLAP", errorCode(resp))
+
+	// Only approved payouts are exported
+	resp = send(http.MethodPost, "/v1/jobs/payout-file", `{"scheme":"NACHA"}`, true)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Equal(t, "NO_APPROVED_PAYOUTS", errorCode(resp))
+
+	resp = send(http.MethodPost, "/v1/payouts/"+payout.ID.String()+"/approve", "", true)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = send(http.MethodPost, "/v1/payouts/"+payout.ID.String()+"/approve", "", true)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Equal(t, "PAYOUT_NOT_PENDING", errorCode(resp))
+
+	resp = send(http.MethodPost, "/v1/jobs/payout-file", `{"scheme":"NACHA"}`, true)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&jobResp))
+	resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	jobID := jobResp["job_id"].(string)
+	job = waitForJob(t, server, jobID)
+	require.Equal(t, "COMPLETED", job["status"], "job error: %v", job["error"])
+	assert.Equal(t, "/v1/payouts/files/"+jobID+".ach", job["download_url"])
+
+	// The file is served on the admin route only
+	resp, err := http.Get(server.URL + "/v1/downloads/" + jobID + ".ach")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = send(http.MethodGet, "/v1/payouts/files/"+jobID+".ach", "", false)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = send(http.MethodGet, "/v1/payouts/files/"+jobID+".ach", "", true)
+	file, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	records := strings.Split(strings.TrimSuffix(string(file), "\n"), "\n")
+	require.Len(t, records, 10)
+	assert.Equal(t, "622011000015"+"12345            "+"0000009700"+"MERCHANT_PAY   "+"PAY SHOP", records[2][:62])
+
+	// The payout is exported and no longer pending on the dashboard
+	resp = send(http.MethodGet, "/v1/payouts/"+payout.ID.String(), "", true)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&payout))
+	resp.Body.Close()
+	assert.Equal(t, models.PayoutStatusExported, payout.Status)
+	require.NotNil(t, payout.ExportJobID)
+	assert.Equal(t, jobID, payout.ExportJobID.String())
+
+	resp, err = http.Get(server.URL + "/v1/merchants/merchant_pay/dashboard?from=2025-04-01&to=2025-04-30")
+	require.NoError(t, err)
+	var dashboard models.MerchantDashboard
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&dashboard))
+	resp.Body.Close()
+	assert.Equal(t, 9700, dashboard.Settlements.NetCents)
+	assert.Equal(t, 0, dashboard.PendingPayoutCents)
+
+	resp = send(http.MethodPost, "/v1/jobs/payout-file", `{"scheme":"NACHA"}`, true)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Equal(t, "NO_APPROVED_PAYOUTS", errorCode(resp))
+}
+
+// TestDownloadRejectsPaths tests that a download names a job, never a path
+func TestDownloadRejectsPaths(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	for path, status := range map[string]int{
+		"/v1/downloads/passwd":                              http.StatusBadRequest,
+		"/v1/downloads/%2E%2E.csv":                          http.StatusBadRequest,
+		"/v1/downloads/" + uuid.New().String() + ".csv.pdf": http.StatusBadRequest,
+		"/v1/downloads/" + uuid.New().String() + ".csv":     http.StatusNotFound,
+		// Encoded slashes reach no route at all
+		"/v1/downloads/..%2F..%2Fetc%2Fpasswd": http.StatusNotFound,
+	} {
+		resp, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, status, resp.StatusCode, path)
+	}
+}
+
+func TestSettlementCalendarRollsNonBusinessDays(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	ctx := context.Background()
+	txRepo := repository.NewTransactionRepository(db.DB)
+
+	// Assign the merchant to a region whose Monday 2025-03-03 is a holiday
+	body, _ := json.Marshal(models.SetMerchantCalendarRequest{Region: "id"})
+	req, _ := http.NewRequest(http.MethodPut, server.URL+"/v1/merchants/merchant_cal/settlement-calendar", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode, "assigning a region needs the admin token")
+
+	req, _ = http.NewRequest(http.MethodPut, server.URL+"/v1/merchants/merchant_cal/settlement-calendar", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Admin-Token", testAdminToken)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, _ = json.Marshal(models.CreateHolidayRequest{Region: "ID", Date: "2025-03-03", Name: "Test Holiday"})
+	resp, err = http.Post(server.URL+"/v1/calendar/holidays", "application/json", bytes.NewBuffer(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode, "adding a holiday needs the admin token")
+
+	req, _ = http.NewRequest(http.MethodPost, server.URL+"/v1/calendar/holidays", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Admin-Token", testAdminToken)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	// Saturday, Sunday, and the holiday all settle on Tuesday 2025-03-04
+	for _, day := range []string{"2025-03-01", "2025-03-02", "2025-03-03", "2025-03-04"} {
+		paidAt, _ := time.Parse("2006-01-02", day)
+		require.NoError(t, txRepo.Create(ctx, &models.Transaction{
+			MerchantID:  "merchant_cal",
+			AmountCents: 1000,
+			FeeCents:    30,
+			Status:      models.TransactionStatusCompleted,
+			PaidAt:      paidAt.Add(12 * time.Hour),
+		}))
+	}
+
+	// Merchants without a calendar keep settling on the calendar day
+	saturday, _ := time.Parse("2006-01-02", "2025-03-01")
+	require.NoError(t, txRepo.Create(ctx, &models.Transaction{
+		MerchantID:  "merchant_plain",
+		AmountCents: 5000,
+		FeeCents:    150,
+		Status:      models.TransactionStatusCompleted,
+		PaidAt:      saturday.Add(12 * time.Hour),
+	}))
+
+	body, _ = json.Marshal(models.CreateSettlementJobRequest{From: "2025-03-04", To: "2025-03-04"})
+	resp, err = http.Post(server.URL+"/v1/jobs/settlement", "application/json", bytes.NewBuffer(body))
+	require.NoError(t, err)
+	var jobResp map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&jobResp))
+	resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	jobID := jobResp["job_id"].(string)
+	job := waitForJob(t, server, jobID)
+	require.Equal(t, "COMPLETED", job["status"], "job error: %v", job["error"])
+
+	resp, err = http.Get(server.URL + "/v1/settlements/runs/" + jobID)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var run models.SettlementRun
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&run))
+
+	require.Len(t, run.Settlements, 1)
+	settlement := run.Settlements[0]
+	assert.Equal(t, "merchant_cal", settlement.MerchantID)
+	assert.Equal(t, "2025-03-04", settlement.Date.Format("2006-01-02"))
+	assert.Equal(t, 4, settlement.TxnCount)
+	assert.Equal(t, 3880, settlement.NetCents)
+}
+
+func TestLocalizedErrorMessages(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	cases := []struct {
+		acceptLanguage string
+		wantLanguage   string
+		wantMessage    string
+	}{
+		{"id-ID,id;q=0.9,en;q=0.8", "id", "Produk tidak ditemukan"},
+		{"fr-FR", "en", "Product not found"},
+		{"", "en", "Product not found"},
+	}
+
+	for _, tc := range cases {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/v1/products/999999", nil)
+		if tc.acceptLanguage != "" {
+			req.Header.Set("Accept-Language", tc.acceptLanguage)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		var errResp apperrors.ErrorResponse
+		err = json.NewDecoder(resp.Body).Decode(&errResp)
+		resp.Body.Close()
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Equal(t, tc.wantLanguage, resp.Header.Get("Content-Language"))
+		assert.Equal(t, "NOT_FOUND", errResp.Error.Code)
+		assert.Equal(t, tc.wantMessage, errResp.Error.Message)
+	}
+}
+
+func TestProblemJSONErrors(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/v1/products/999999", nil)
+	req.Header.Set("Accept", "application/problem+json")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+
+	var problem apperrors.ProblemDetails
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+
+	assert.Equal(t, "/errors/not-found", problem.Type)
+	assert.Equal(t, "Not found", problem.Title)
+	assert.Equal(t, http.StatusNotFound, problem.Status)
+	assert.Equal(t, "Product not found", problem.Detail)
+	assert.Equal(t, "urn:uuid:"+resp.Header.Get("X-Request-ID"), problem.Instance)
+	assert.Equal(t, "NOT_FOUND", problem.Code)
+}
+
+func TestPayloadLoggingToggle(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	product := createTestProduct(t, db, 10)
+
+	// Admin endpoints require the admin token
+	resp, err := http.Get(server.URL + "/admin/debug/payload-logging")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"routes":  []string{"/v1/orders"},
+		"enabled": true,
+	})
+	req, _ := http.NewRequest(http.MethodPut, server.URL+"/admin/debug/payload-logging", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Admin-Token", testAdminToken)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	var state struct {
+		Routes []string `json:"routes"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&state))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{"/v1/orders"}, state.Routes)
+
+	// Logged requests still reach the handler with their full body
+	orderReq, _ := json.Marshal(models.CreateOrderRequest{ProductID: product.ID, Quantity: 1, BuyerID: "buyer_debug"})
+	resp, err = http.Post(server.URL+"/v1/orders", "application/json", bytes.NewBuffer(orderReq))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var order models.Order
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&order))
+	assert.Equal(t, "buyer_debug", order.BuyerID)
+}
+
+func TestAdminSeed(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	ctx := context.Background()
+	txRepo := repository.NewTransactionRepository(db.DB)
+	require.NoError(t, txRepo.Create(ctx, &models.Transaction{MerchantID: "merchant_before_seed", AmountCents: 1000, FeeCents: 30, Status: models.TransactionStatusCompleted, PaidAt: time.Now()}))
+
+	seed := func(body string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/admin/seed", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Admin-Token", testAdminToken)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := seed(`{"count": 2500, "days": 7, "merchants": 3, "reset": true}`)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var result models.SeedResult
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Equal(t, 2500, result.Inserted)
+	assert.True(t, result.Reset)
+	assert.Equal(t, 7, result.Options.Days)
+	// Options left out keep the seeder defaults
+	assert.True(t, result.Options.Diurnal)
+	assert.Equal(t, 1.2, result.Options.ZipfS)
+
+	var count, merchants int
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT COUNT(*), COUNT(DISTINCT merchant_id) FROM transactions`).Scan(&count, &merchants))
+	assert.Equal(t, 2500, count, "reset removes the existing transaction")
+	assert.LessOrEqual(t, merchants, 3)
+
+	// Requests above the cap are rejected before anything is written
+	resp = seed(`{"count": 5001}`)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = seed(`{"count": 10, "pending": 0.8, "failed": 0.5}`)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestAdminStats(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	ctx := context.Background()
+	txRepo := repository.NewTransactionRepository(db.DB)
+
+	product := createTestProduct(t, db, 10)
+	for i := 0; i < 2; i++ {
+		reqBody, _ := json.Marshal(models.CreateOrderRequest{ProductID: product.ID, Quantity: 1, BuyerID: "buyer_stats"})
+		resp, err := http.Post(server.URL+"/v1/orders", "application/json", bytes.NewBuffer(reqBody))
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	paidAt := time.Date(2025, 6, 10, 10, 0, 0, 0, time.UTC)
+	for _, txn := range []*models.Transaction{
+		{MerchantID: "merchant_small", AmountCents: 1000, FeeCents: 30, Status: models.TransactionStatusCompleted, PaidAt: paidAt},
+		{MerchantID: "merchant_big", AmountCents: 9000, FeeCents: 270, Status: models.TransactionStatusCompleted, PaidAt: paidAt},
+		{MerchantID: "merchant_big", AmountCents: 5000, FeeCents: 150, Status: models.TransactionStatusFailed, PaidAt: paidAt},
+	} {
+		require.NoError(t, txRepo.Create(ctx, txn))
+	}
+
+	getStats := func(path string, out interface{}) {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		req.Header.Set("X-Admin-Token", testAdminToken)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+	}
+
+	// Stats are admin-only
+	resp, err := http.Get(server.URL + "/admin/stats/error-rates")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	var orderRate models.OrderRateStats
+	getStats("/admin/stats/orders-per-minute?minutes=5", &orderRate)
+	assert.Equal(t, 5, orderRate.WindowMinutes)
+	assert.Len(t, orderRate.Minutes, 5)
+	assert.Equal(t, 2, orderRate.Total)
+
+	var errorRates models.ErrorRateStats
+	getStats("/admin/stats/error-rates", &errorRates)
+	assert.Equal(t, 2, errorRates.Orders.Total)
+	assert.Equal(t, 3, errorRates.Transactions.Total)
+	assert.Equal(t, 1, errorRates.Transactions.Errors)
+	assert.InDelta(t, 1.0/3, errorRates.Transactions.Rate, 0.0001)
+
+	var top models.TopMerchantsStats
+	getStats("/admin/stats/top-merchants?from=2025-06-01&to=2025-06-30&limit=1", &top)
+	require.Len(t, top.Merchants, 1)
+	assert.Equal(t, "merchant_big", top.Merchants[0].MerchantID)
+	assert.Equal(t, 9000, top.Merchants[0].GrossCents)
+	assert.Equal(t, 1, top.Merchants[0].TxnCount)
+
+	var throughput models.JobThroughputStats
+	getStats("/admin/stats/job-throughput", &throughput)
+	assert.Equal(t, 24, throughput.WindowHours)
+	assert.NotNil(t, throughput.Types)
+
+	var queue models.JobQueueStats
+	getStats("/admin/stats/job-queue", &queue)
+	assert.NotNil(t, queue.Types)
+}
+
+// TestOrderPlacementSaga tests that orders are placed through a persisted saga
+// and that an interrupted saga releases its reserved stock on recovery
+func TestOrderPlacementSaga(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	ctx := context.Background()
+	product := createTestProduct(t, db, 5)
+
+	reqBody, _ := json.Marshal(models.CreateOrderRequest{ProductID: product.ID, Quantity: 2, BuyerID: "buyer_saga"})
+	resp, err := http.Post(server.URL+"/v1/orders", "application/json", bytes.NewBuffer(reqBody))
+	require.NoError(t, err)
+	var order models.Order
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&order))
+	resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	// The confirmed status is persisted, not just returned
+	var status string
+	require.NoError(t, db.QueryRow("SELECT status FROM orders WHERE id = $1", order.ID).Scan(&status))
+	assert.Equal(t, string(models.OrderStatusConfirmed), status)
+
+	// An out-of-stock order fails its first step and has nothing to undo
+	reqBody, _ = json.Marshal(models.CreateOrderRequest{ProductID: product.ID, Quantity: 10, BuyerID: "buyer_saga"})
+	resp, err = http.Post(server.URL+"/v1/orders", "application/json", bytes.NewBuffer(reqBody))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	listSagas := func(status models.SagaStatus) []*models.Saga {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/admin/sagas?status="+string(status), nil)
+		req.Header.Set("X-Admin-Token", testAdminToken)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var result struct {
+			Sagas []*models.Saga `json:"sagas"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		return result.Sagas
+	}
+
+	completed := listSagas(models.SagaStatusCompleted)
+	require.Len(t, completed, 1)
+	assert.Equal(t, models.SagaTypeOrderPlacement, completed[0].Type)
+	assert.Equal(t, 2, completed[0].CompletedSteps)
+
+	compensated := listSagas(models.SagaStatusCompensated)
+	require.Len(t, compensated, 1)
+	require.NotNil(t, compensated[0].FailedStep)
+	assert.Equal(t, "reserve_stock", *compensated[0].FailedStep)
+
+	// Simulate a crash after stock was reserved but before the order was confirmed
+	orderID := uuid.New()
+	_, err = db.Exec(`
+		INSERT INTO orders (id, product_id, buyer_id, quantity, status, total_cents, created_at, updated_at)
+		VALUES ($1, $2, 'buyer_saga', 1, 'PENDING', 1000, NOW(), NOW())`, orderID, product.ID)
+	require.NoError(t, err)
+	_, err = db.Exec("UPDATE products SET stock = stock - 1 WHERE id = $1", product.ID)
+	require.NoError(t, err)
+
+	sagaRepo := repository.NewSagaRepository(db.DB)
+	payload, _ := json.Marshal(map[string]interface{}{
+		"order_id": orderID, "product_id": product.ID, "buyer_id": "buyer_saga", "quantity": 1, "total_cents": 1000,
+	})
+	interrupted := &models.Saga{ID: uuid.New(), Type: models.SagaTypeOrderPlacement, Status: models.SagaStatusRunning, Payload: payload}
+	require.NoError(t, sagaRepo.Create(ctx, interrupted))
+	_, err = db.Exec("UPDATE sagas SET completed_steps = 1, updated_at = NOW() - INTERVAL '1 hour' WHERE id = $1", interrupted.ID)
+	require.NoError(t, err)
+
+	orchestrator := service.NewSagaOrchestrator(newTestUnitOfWork(db), sagaRepo)
+	service.NewOrderService(&service.Dependencies{DB: db, ProductRepo: repository.NewProductRepository(db.DB, nil), OrderRepo: repository.NewOrderRepository(db.DB, nil), Sagas: orchestrator})
+	recovered, err := orchestrator.Recover(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, recovered)
+
+	saga, err := sagaRepo.GetByID(ctx, interrupted.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.SagaStatusCompensated, saga.Status)
+
+	require.NoError(t, db.QueryRow("SELECT status FROM orders WHERE id = $1", orderID).Scan(&status))
+	assert.Equal(t, string(models.OrderStatusCancelled), status)
+
+	var stock int
+	require.NoError(t, db.QueryRow("SELECT stock FROM products WHERE id = $1", product.ID).Scan(&stock))
+	assert.Equal(t, 3, stock)
+}
+
+// TestListContentNegotiation tests that list endpoints stream CSV and NDJSON
+// when asked for them via Accept
+func TestListContentNegotiation(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	ctx := context.Background()
+	txRepo := repository.NewTransactionRepository(db.DB)
+
+	product := createTestProduct(t, db, 10)
+	for i := 0; i < 3; i++ {
+		reqBody, _ := json.Marshal(models.CreateOrderRequest{ProductID: product.ID, Quantity: 1, BuyerID: "buyer_extract"})
+		resp, err := http.Post(server.URL+"/v1/orders", "application/json", bytes.NewBuffer(reqBody))
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	paidAt := time.Date(2025, 6, 10, 10, 0, 0, 0, time.UTC)
+	for _, txn := range []*models.Transaction{
+		{MerchantID: "merchant_extract", AmountCents: 1000, FeeCents: 30, Status: models.TransactionStatusCompleted, PaidAt: paidAt},
+		{MerchantID: "merchant_extract", AmountCents: 2000, FeeCents: 60, Status: models.TransactionStatusPending, PaidAt: paidAt},
+		{MerchantID: "merchant_other", AmountCents: 3000, FeeCents: 90, Status: models.TransactionStatusCompleted, PaidAt: paidAt},
+	} {
+		require.NoError(t, txRepo.Create(ctx, txn))
+	}
+
+	get := func(path, accept string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		req.Header.Set("Accept", accept)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	// CSV returns a header row and every order, not just the default page size
+	resp := get("/v1/orders?limit=0", "text/csv")
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, strings.HasPrefix(resp.Header.Get("Content-Type"), "text/csv"))
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	require.Len(t, lines, 4)
+	assert.Equal(t, "id,product_id,buyer_id,quantity,status,total_cents,created_at,updated_at", lines[0])
+
+	// NDJSON returns one filtered transaction per line
+	resp = get("/v1/transactions?merchant_id=merchant_extract&from=2025-06-10&to=2025-06-10", "application/x-ndjson")
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	lines = strings.Split(strings.TrimSpace(string(body)), "\n")
+	require.Len(t, lines, 2)
+	var txn models.Transaction
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &txn))
+	assert.Equal(t, "merchant_extract", txn.MerchantID)
+
+	// JSON stays the default
+	resp = get("/v1/transactions?status=COMPLETED", "")
+	var list struct {
+		Transactions []*models.Transaction `json:"transactions"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	resp.Body.Close()
+	assert.Len(t, list.Transactions, 2)
+
+	// An empty CSV listing still has its header
+	resp = get("/v1/settlements?merchant_id=nobody", "text/csv")
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "id,merchant_id,date,gross_cents,fee_cents,net_cents,txn_count,generated_at,unique_run_id,stale_since,stale_reason\n", string(body))
+
+	// Filter errors are reported before streaming starts
+	resp = get("/v1/transactions?from=nope", "text/csv")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestMaintenanceMode(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	product := createTestProduct(t, db, 10)
+
+	setMaintenance := func(enabled bool, message string) models.MaintenanceStatus {
+		body, _ := json.Marshal(map[string]interface{}{"enabled": enabled, "message": message})
+		req, _ := http.NewRequest(http.MethodPut, server.URL+"/admin/maintenance", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Admin-Token", testAdminToken)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var status models.MaintenanceStatus
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+		return status
+	}
+
+	createOrder := func() *http.Response {
+		reqBody, _ := json.Marshal(models.CreateOrderRequest{ProductID: product.ID, Quantity: 1, BuyerID: "buyer_maintenance"})
+		resp, err := http.Post(server.URL+"/v1/orders", "application/json", bytes.NewBuffer(reqBody))
+		require.NoError(t, err)
+		return resp
+	}
+
+	status := setMaintenance(true, "schema migration")
+	assert.True(t, status.Enabled)
+	assert.Equal(t, "schema migration", status.Message)
+
+	// Writes are rejected with a retry hint
+	resp := createOrder()
+	var errResp apperrors.ErrorResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&errResp))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, apperrors.ErrCodeMaintenance, errResp.Error.Code)
+	assert.Equal(t, "schema migration", errResp.Error.Details)
+	assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+
+	// Reads keep working
+	resp, err := http.Get(fmt.Sprintf("%s/v1/products/%d", server.URL, product.ID))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	status = setMaintenance(false, "")
+	assert.False(t, status.Enabled)
+
+	resp = createOrder()
+	resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+}
+
+func TestColumnRenameExpandContract(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	_, err := db.Exec(`
+		DROP TABLE IF EXISTS rename_test;
+		CREATE TABLE rename_test (id SERIAL PRIMARY KEY, buyer_id VARCHAR(255) NOT NULL);
+		INSERT INTO rename_test (buyer_id) SELECT 'buyer_' || n FROM generate_series(1, 250) n;
+	`)
+	require.NoError(t, err)
+	defer db.Exec(`DROP TABLE IF EXISTS rename_test; DROP FUNCTION IF EXISTS rename_test_buyer_id_to_customer_id_sync()`)
+
+	rename := &database.ColumnRename{Table: "rename_test", From: "buyer_id", To: "customer_id", Type: "VARCHAR(255)", NotNull: true}
+
+	_, err = db.Exec(rename.ExpandSQL())
+	require.NoError(t, err)
+
+	// Old and new code write different columns while both are deployed
+	_, err = db.Exec(`INSERT INTO rename_test (buyer_id) VALUES ('old_code')`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO rename_test (customer_id) VALUES ('new_code')`)
+	require.NoError(t, err)
+	_, err = db.Exec(`UPDATE rename_test SET buyer_id = 'old_update' WHERE id = 1`)
+	require.NoError(t, err)
+
+	var buyerID, customerID string
+	require.NoError(t, db.QueryRow(`SELECT buyer_id, customer_id FROM rename_test WHERE customer_id = 'new_code'`).Scan(&buyerID, &customerID))
+	assert.Equal(t, "new_code", buyerID)
+	require.NoError(t, db.QueryRow(`SELECT customer_id FROM rename_test WHERE id = 1`).Scan(&customerID))
+	assert.Equal(t, "old_update", customerID)
+
+	backfill := rename.Backfill()
+	assert.Equal(t, "rename_test.customer_id", backfill.Name)
+
+	remaining, err := backfill.CountRemaining(ctx, db)
+	require.NoError(t, err)
+	assert.Equal(t, 249, remaining)
+
+	var total int
+	for {
+		n, err := backfill.RunBatch(ctx, db, 100)
+		require.NoError(t, err)
+		if n == 0 {
+			break
+		}
+		assert.LessOrEqual(t, n, 100)
+		total += n
+	}
+	assert.Equal(t, 249, total)
+
+	remaining, err = backfill.CountRemaining(ctx, db)
+	require.NoError(t, err)
+	assert.Zero(t, remaining)
+
+	_, err = db.Exec(rename.ContractSQL())
+	require.NoError(t, err)
+
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM rename_test WHERE customer_id LIKE 'buyer_%'`).Scan(&count))
+	assert.Equal(t, 249, count)
+
+	_, err = db.Exec(`SELECT buyer_id FROM rename_test`)
+	assert.Error(t, err, "old column should be dropped")
+
+	// Rolling the contract back restores the old column and the sync
+	_, err = db.Exec(rename.RevertContractSQL())
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO rename_test (buyer_id) VALUES ('after_revert')`)
+	require.NoError(t, err)
+	require.NoError(t, db.QueryRow(`SELECT customer_id FROM rename_test WHERE buyer_id = 'after_revert'`).Scan(&customerID))
+	assert.Equal(t, "after_revert", customerID)
+}
+
+func TestBuyerErasure(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	product := createTestProduct(t, db, 10)
+	for _, buyer := range []string{"buyer_erase", "buyer_erase", "buyer_keep"} {
+		reqBody, _ := json.Marshal(models.CreateOrderRequest{ProductID: product.ID, Quantity: 1, BuyerID: buyer})
+		resp, err := http.Post(server.URL+"/v1/orders", "application/json", bytes.NewBuffer(reqBody))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+	}
+
+	var revenue int
+	require.NoError(t, db.QueryRow(`SELECT SUM(total_cents) FROM orders`).Scan(&revenue))
+
+	// An export filtered to the buyer, created the way clients create it
+	resp, err := http.Post(server.URL+"/v1/jobs/orders-export", "application/json", bytes.NewBufferString(`{"buyer_id":"buyer_erase","format":"csv"}`))
+	require.NoError(t, err)
+	var exportResp map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&exportResp))
+	resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	exportID := exportResp["job_id"].(string)
+	export := waitForJob(t, server, exportID)
+	require.Equal(t, "COMPLETED", export["status"], "job error: %v", export["error"])
+	exportURL := export["download_url"].(string)
+
+	erase := func(token string) *http.Response {
+		req, _ := http.NewRequest(http.MethodDelete, server.URL+"/v1/buyers/buyer_erase/data", nil)
+		if token != "" {
+			req.Header.Set("X-Admin-Token", token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	// Erasure is an admin operation
+	resp = erase("")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = erase(testAdminToken)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	var created map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	resp.Body.Close()
+
+	job := waitForJob(t, server, created["job_id"].(string))
+	require.Equal(t, "COMPLETED", job["status"])
+
+	var remaining, kept, pseudonymized int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM orders WHERE buyer_id = 'buyer_erase'`).Scan(&remaining))
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM orders WHERE buyer_id = 'buyer_keep'`).Scan(&kept))
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM orders WHERE buyer_id = 'erased-' || $1`, created["job_id"]).Scan(&pseudonymized))
+	assert.Zero(t, remaining)
+	assert.Equal(t, 1, kept)
+	assert.Equal(t, 2, pseudonymized)
+
+	// Financial totals are unchanged
+	var revenueAfter int
+	require.NoError(t, db.QueryRow(`SELECT SUM(total_cents) FROM orders`).Scan(&revenueAfter))
+	assert.Equal(t, revenue, revenueAfter)
+
+	// Neither the sagas nor the erasure job itself keep the buyer ID
+	var mentions int
+	require.NoError(t, db.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM sagas WHERE payload->>'buyer_id' = 'buyer_erase') +
+			(SELECT COUNT(*) FROM jobs WHERE parameters->>'buyer_id' = 'buyer_erase')`).Scan(&mentions))
+	assert.Zero(t, mentions)
+
+	// The export's parameters name the pseudonym instead, and its file is gone
+	export = waitForJob(t, server, exportID)
+	var params models.OrdersExportJobParams
+	require.NoError(t, json.Unmarshal([]byte(export["parameters"].(string)), &params))
+	assert.Equal(t, "erased-"+created["job_id"].(string), params.BuyerID)
+	assert.Nil(t, export["download_url"])
+
+	resp, err = http.Get(server.URL + exportURL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestSettlementSplitByMerchant(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	ctx := context.Background()
+	txRepo := repository.NewTransactionRepository(db.DB)
+	paidAt := time.Date(2025, 5, 6, 10, 0, 0, 0, time.UTC)
+	for _, merchantID := range []string{"merchant_a", "merchant_b", "merchant/c"} {
+		tx := &models.Transaction{MerchantID: merchantID, AmountCents: 10000, FeeCents: 300, Status: models.TransactionStatusCompleted, PaidAt: paidAt}
+		require.NoError(t, txRepo.Create(ctx, tx))
+	}
+
+	body, _ := json.Marshal(models.CreateSettlementJobRequest{From: "2025-05-06", To: "2025-05-06", Split: models.SettlementSplitMerchant})
+	resp, err := http.Post(server.URL+"/v1/jobs/settlement", "application/json", bytes.NewBuffer(body))
+	require.NoError(t, err)
+	var jobResp map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&jobResp))
+	resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	jobID := jobResp["job_id"].(string)
+	job := waitForJob(t, server, jobID)
+	require.Equal(t, "COMPLETED", job["status"])
+	assert.Equal(t, "/v1/downloads/"+jobID+".zip", job["download_url"])
+
+	// The ZIP holds one CSV per merchant with only that merchant's rows
+	resp, err = http.Get(server.URL + "/v1/downloads/" + jobID + ".zip")
+	require.NoError(t, err)
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "application/zip", resp.Header.Get("Content-Type"))
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	var names []string
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+	}
+	assert.Equal(t, []string{"merchant%2Fc.csv", "merchant_a.csv", "merchant_b.csv"}, names)
+
+	// A single merchant's file can be downloaded on its own
+	resp, err = http.Get(server.URL + "/v1/jobs/" + jobID + "/merchants/merchant_a/download")
+	require.NoError(t, err)
+	rows, err := csv.NewReader(resp.Body).ReadAll()
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "merchant_a", rows[1][0])
+
+	resp, err = http.Get(server.URL + "/v1/jobs/" + jobID + "/merchants/merchant_x/download")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Each file is listed with its own download URL
+	resp, err = http.Get(server.URL + "/v1/jobs/" + jobID + "/files")
+	require.NoError(t, err)
+	var listed struct {
+		Files []struct {
+			Name        string `json:"name"`
+			DownloadURL string `json:"download_url"`
+		} `json:"files"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&listed))
+	resp.Body.Close()
+	require.Len(t, listed.Files, 3)
+	assert.Equal(t, "merchant_b.csv", listed.Files[2].Name)
+
+	resp, err = http.Get(server.URL + listed.Files[2].DownloadURL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestSettlementParquetFormat(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	ctx := context.Background()
+	txRepo := repository.NewTransactionRepository(db.DB)
+	tx := &models.Transaction{MerchantID: "merchant_a", AmountCents: 10000, FeeCents: 300, Status: models.TransactionStatusCompleted, PaidAt: time.Date(2025, 5, 6, 10, 0, 0, 0, time.UTC)}
+	require.NoError(t, txRepo.Create(ctx, tx))
+
+	// Unknown formats are rejected up front
+	body, _ := json.Marshal(models.CreateSettlementJobRequest{From: "2025-05-06", To: "2025-05-06", Format: "xlsx"})
+	resp, err := http.Post(server.URL+"/v1/jobs/settlement", "application/json", bytes.NewBuffer(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	body, _ = json.Marshal(models.CreateSettlementJobRequest{From: "2025-05-06", To: "2025-05-06", Format: models.ExportFormatParquet})
+	resp, err = http.Post(server.URL+"/v1/jobs/settlement", "application/json", bytes.NewBuffer(body))
+	require.NoError(t, err)
+	var jobResp map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&jobResp))
+	resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	jobID := jobResp["job_id"].(string)
+	job := waitForJob(t, server, jobID)
+	require.Equal(t, "COMPLETED", job["status"])
+	assert.Equal(t, "/v1/downloads/"+jobID+".parquet", job["download_url"])
+
+	resp, err = http.Get(server.URL + "/v1/downloads/" + jobID + ".parquet")
+	require.NoError(t, err)
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/vnd.apache.parquet", resp.Header.Get("Content-Type"))
+	require.Greater(t, len(data), 8)
+	assert.Equal(t, "PAR1", string(data[:4]))
+	assert.Equal(t, "PAR1", string(data[len(data)-4:]))
+
+	// Listings stream Parquet when asked for it
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/v1/transactions", nil)
+	req.Header.Set("Accept", "application/vnd.apache.parquet")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	data, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/vnd.apache.parquet", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "PAR1", string(data[len(data)-4:]))
+}
+
+// TestQueuedOrders tests that in queued mode concurrent orders for one
+// product are placed one at a time and sell exactly the stock
+func TestQueuedOrders(t *testing.T) {
+	_, db := setupTestServer(t)
+	ctx := context.Background()
+
+	product := createTestProduct(t, db, 50)
+
+	queue := service.NewOrderQueue(4, 500)
+	queue.Start()
+	defer queue.Stop()
+
+	sagaRepo := repository.NewSagaRepository(db.DB)
+	orders := service.NewOrderService(&service.Dependencies{
+		DB:          db,
+		ProductRepo: repository.NewProductRepository(db.DB, nil),
+		OrderRepo:   repository.NewOrderRepository(db.DB, nil),
+		Sagas:       service.NewSagaOrchestrator(newTestUnitOfWork(db), sagaRepo),
+		OrderQueue:  queue,
+	})
+
+	var wg sync.WaitGroup
+	var placed, outOfStock, other atomic.Int32
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := orders.CreateOrder(ctx, &models.CreateOrderRequest{ProductID: product.ID, Quantity: 1, BuyerID: fmt.Sprintf("buyer_%d", i)})
+			switch {
+			case err == nil:
+				placed.Add(1)
+			case err == apperrors.ErrOutOfStock:
+				outOfStock.Add(1)
+			default:
+				other.Add(1)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(50), placed.Load())
+	assert.Equal(t, int32(150), outOfStock.Load())
+	assert.Equal(t, int32(0), other.Load())
+
+	var stock int
+	require.NoError(t, db.QueryRow("SELECT stock FROM products WHERE id = $1", product.ID).Scan(&stock))
+	assert.Equal(t, 0, stock)
+}
+
+// TestStockFastPath tests that the Redis fast path accepts exactly the
+// stock as PENDING orders and that its writers then place them in Postgres.
+// It needs the test Redis and is skipped without it.
+func TestStockFastPath(t *testing.T) {
+	_, db := setupTestServer(t)
+	ctx := context.Background()
+
+	rdb, err := cache.New(&config.CacheConfig{Addr: "localhost:6380", DialTimeout: time.Second})
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	defer rdb.Close()
+	require.NoError(t, rdb.FlushDB(ctx).Err())
+
+	product := createTestProduct(t, db, 20)
+
+	productRepo := repository.NewProductRepository(db.DB, nil)
+	orderRepo := repository.NewOrderRepository(db.DB, nil)
+	sagas := service.NewSagaOrchestrator(newTestUnitOfWork(db), repository.NewSagaRepository(db.DB))
+	fastPath := service.NewStockFastPath(rdb, productRepo, repository.NewBundleRepository(db.DB), orderRepo, sagas, nil, &config.OrdersConfig{
+		FastPath:               true,
+		FastPathWriters:        2,
+		FastPathResyncInterval: 100 * time.Millisecond,
+	}, nil)
+	orders := service.NewOrderService(&service.Dependencies{
+		DB:          db,
+		ProductRepo: productRepo,
+		OrderRepo:   orderRepo,
+		Sagas:       sagas,
+		FastPath:    fastPath,
+	})
+	fastPath.Start()
+	defer fastPath.Stop()
+
+	var wg sync.WaitGroup
+	var accepted, outOfStock, other atomic.Int32
+	for i := 0; i < 60; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			order, err := orders.CreateOrder(ctx, &models.CreateOrderRequest{ProductID: product.ID, Quantity: 1, BuyerID: fmt.Sprintf("buyer_%d", i)})
+			switch {
+			case err == nil && order.Status == models.OrderStatusPending:
+				accepted.Add(1)
+			case err == apperrors.ErrOutOfStock:
+				outOfStock.Add(1)
+			default:
+				other.Add(1)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(20), accepted.Load())
+	assert.Equal(t, int32(40), outOfStock.Load())
+	assert.Equal(t, int32(0), other.Load())
+
+	// The writers place every accepted order in Postgres
+	require.Eventually(t, func() bool {
+		var confirmed int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM orders WHERE product_id = $1 AND status = 'CONFIRMED'", product.ID).Scan(&confirmed))
+		return confirmed == 20
+	}, 10*time.Second, 50*time.Millisecond)
+
+	var stock int
+	require.NoError(t, db.QueryRow("SELECT stock FROM products WHERE id = $1", product.ID).Scan(&stock))
+	assert.Equal(t, 0, stock)
+
+	// Stock added in Postgres reaches the cache once nothing is pending
+	_, err = db.Exec("UPDATE products SET stock = 1, version = version + 1 WHERE id = $1", product.ID)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		cached, err := rdb.HGet(ctx, fmt.Sprintf("{indico}:stock:%d", product.ID), "stock").Result()
+		return err == nil && cached == "1"
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// A repeated client reference returns the accepted order
+	req := &models.CreateOrderRequest{ProductID: product.ID, Quantity: 1, BuyerID: "buyer_ref", ClientReference: "fast-path-ref"}
+	first, err := orders.CreateOrder(ctx, req)
+	require.NoError(t, err)
+	repeat, err := orders.CreateOrder(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, repeat.ID)
+}
+
+func TestEventSchemas(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	resp, err := http.Get(server.URL + "/events/schemas")
+	require.NoError(t, err)
+	var catalog struct {
+		Events []struct {
+			Type      string `json:"type"`
+			SchemaURL string `json:"schema_url"`
+		} `json:"events"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&catalog))
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, catalog.Events, 3)
+	assert.Equal(t, "order.created.v1", catalog.Events[0].Type)
+
+	// Every listed schema is served, pinned to its event type
+	for _, event := range catalog.Events {
+		resp, err := http.Get(server.URL + event.SchemaURL)
+		require.NoError(t, err)
+		var schema map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&schema))
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/schema+json", resp.Header.Get("Content-Type"))
+		assert.Equal(t, event.SchemaURL, schema["$id"])
+
+		properties := schema["properties"].(map[string]interface{})
+		assert.Equal(t, event.Type, properties["type"].(map[string]interface{})["const"])
+	}
+
+	resp, err = http.Get(server.URL + "/events/schemas/order.created.v0")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestWebhookPing(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	// The receiver verifies callbacks the way integrators are told to
+	var secret string
+	received := make(chan string, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		err := webhook.Verify([]string{secret}, r.Header.Get(webhook.TimestampHeader), r.Header.Get(webhook.SignatureHeader), body, 5*time.Minute, time.Now())
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		received <- r.Header.Get(webhook.EventHeader)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	body, _ := json.Marshal(models.CreateWebhookEndpointRequest{URL: receiver.URL + "/hooks"})
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/v1/webhooks", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Admin-Token", testAdminToken)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	var endpoint models.WebhookEndpoint
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&endpoint))
+	resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.NotEmpty(t, endpoint.Secret)
+	secret = endpoint.Secret
+
+	ping := func(id string) (*http.Response, models.WebhookPing) {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/v1/webhooks/"+id+"/ping", nil)
+		req.Header.Set("X-Admin-Token", testAdminToken)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var result models.WebhookPing
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp, result
+	}
+
+	resp, result := ping(endpoint.ID.String())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, result.Delivered)
+	assert.Equal(t, http.StatusNoContent, result.StatusCode)
+	assert.Equal(t, "webhook.ping.v1", <-received)
+
+	// A receiver holding the wrong secret rejects the callback
+	secret = "whsec_stale"
+	_, result = ping(endpoint.ID.String())
+	assert.False(t, result.Delivered)
+	assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+
+	// Secrets are only shown on creation
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/v1/webhooks", nil)
+	req.Header.Set("X-Admin-Token", testAdminToken)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	var listed struct {
+		Webhooks []models.WebhookEndpoint `json:"webhooks"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&listed))
+	resp.Body.Close()
+	require.Len(t, listed.Webhooks, 1)
+	assert.Empty(t, listed.Webhooks[0].Secret)
+
+	resp, _ = ping(uuid.New().String())
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	req, _ = http.NewRequest(http.MethodPost, server.URL+"/v1/webhooks/"+endpoint.ID.String()+"/ping", nil)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestJobProgressWebhooks(t *testing.T) {
+	server, db := setupTestServer(t)
+
+	var secret string
+	received := make(chan events.JobProgress, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		err := webhook.Verify([]string{secret}, r.Header.Get(webhook.TimestampHeader), r.Header.Get(webhook.SignatureHeader), body, 5*time.Minute, time.Now())
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var event struct {
+			Data events.JobProgress `json:"data"`
+		}
+		json.Unmarshal(body, &event)
+		received <- event.Data
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	body, _ := json.Marshal(models.CreateWebhookEndpointRequest{URL: receiver.URL})
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/v1/webhooks", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Admin-Token", testAdminToken)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	var endpoint models.WebhookEndpoint
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&endpoint))
+	resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	secret = endpoint.Secret
+
+	ctx := context.Background()
+	txRepo := repository.NewTransactionRepository(db.DB)
+	yesterday := time.Now().AddDate(0, 0, -1)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, txRepo.Create(ctx, &models.Transaction{
+			MerchantID:  "merchant_progress",
+			AmountCents: 1000,
+			FeeCents:    30,
+			Status:      models.TransactionStatusCompleted,
+			PaidAt:      yesterday,
+		}))
+	}
+
+	createJob := func(webhookID uuid.UUID) (int, map[string]interface{}) {
+		reqBody, _ := json.Marshal(models.CreateSettlementJobRequest{
+			From:            yesterday.Format("2006-01-02"),
+			To:              yesterday.Format("2006-01-02"),
+			ProgressWebhook: &models.ProgressWebhook{WebhookID: webhookID, Milestones: []int{50, 100}},
+		})
+		resp, err := http.Post(server.URL+"/v1/jobs/settlement", "application/json", bytes.NewBuffer(reqBody))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var jobResp map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&jobResp))
+		return resp.StatusCode, jobResp
+	}
+
+	status, _ := createJob(uuid.New())
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, jobResp := createJob(endpoint.ID)
+	require.Equal(t, http.StatusAccepted, status)
+	jobID := jobResp["job_id"].(string)
+	job := waitForJob(t, server, jobID)
+	require.Equal(t, "COMPLETED", job["status"], "job error: %v", job["error"])
+
+	// The three rows fit in one batch, so the job jumps straight to 100%
+	select {
+	case progress := <-received:
+		assert.Equal(t, jobID, progress.JobID.String())
+		assert.Equal(t, string(models.JobTypeSettlement), progress.JobType)
+		assert.Equal(t, 100, progress.Milestone)
+		assert.Equal(t, 3, progress.Total)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no progress webhook received")
+	}
+	select {
+	case progress := <-received:
+		t.Fatalf("unexpected progress webhook for milestone %d", progress.Milestone)
+	case <-time.After(500 * time.Millisecond):
+	}
+}
+
+func TestPaymentWebhooks(t *testing.T) {
+	server, _ := setupTestServer(t)
+
+	send := func(key string, event map[string]interface{}) (*http.Response, models.PaymentEventResult) {
+		body, _ := json.Marshal(event)
+		now := time.Now()
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/v1/webhooks/payments", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(webhook.TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+		req.Header.Set(webhook.SignatureHeader, webhook.Sign(key, now, body))
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var result models.PaymentEventResult
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		}
+		return resp, result
+	}
+
+	paidAt := "2025-07-08T10:00:00Z"
+	captured := map[string]interface{}{
+		"id":           "evt_capture_1",
+		"type":         "payment.captured",
+		"payment_id":   "pay_1",
+		"merchant_id":  "merchant_gateway",
+		"amount_cents": 10000,
+		"fee_cents":    300,
+		"occurred_at":  paidAt,
+	}
+
+	// Unsigned or wrongly signed notifications are rejected
+	resp, _ := send("wrong-key-0123456789abcdef0123456789", captured)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// A capture of an unknown payment creates a completed transaction
+	resp, result := send(testPaymentSigningKey, captured)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, result.Applied)
+	require.NotNil(t, result.Transaction)
+	assert.Equal(t, models.TransactionStatusCompleted, result.Transaction.Status)
+	require.NotNil(t, result.Transaction.PaymentID)
+	assert.Equal(t, "pay_1", *result.Transaction.PaymentID)
+
+	// Redelivery is acknowledged without effect
+	resp, result = send(testPaymentSigningKey, captured)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, result.Duplicate)
+	assert.False(t, result.Applied)
+
+	// Settle the day, then refund: the settlement is flagged stale
+	resp, err := http.Post(server.URL+"/v1/jobs/settlement", "application/json", bytes.NewBufferString(`{"from":"2025-07-08","to":"2025-07-08"}`))
+	require.NoError(t, err)
+	var jobResp map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&jobResp))
+	resp.Body.Close()
+	job := waitForJob(t, server, jobResp["job_id"].(string))
+	require.Equal(t, "COMPLETED", job["status"], "job error: %v", job["error"])
+
+	refunded := map[string]interface{}{
+		"id":          "evt_refund_1",
+		"type":        "payment.refunded",
+		"payment_id":  "pay_1",
+		"occurred_at": "2025-07-09T09:00:00Z",
+	}
+	resp, result = send(testPaymentSigningKey, refunded)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, result.Applied)
+	assert.Equal(t, models.TransactionStatusCompleted, result.PreviousStatus)
+	assert.Equal(t, models.TransactionStatusRefunded, result.Transaction.Status)
+	require.Len(t, result.StaleSettlements, 1)
+	assert.Equal(t, 10000, result.StaleSettlements[0].GrossCents)
+
+	// A late capture for a refunded payment changes nothing
+	captured["id"] = "evt_capture_2"
+	resp, result = send(testPaymentSigningKey, captured)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.False(t, result.Applied)
+	assert.Equal(t, models.TransactionStatusRefunded, result.Transaction.Status)
+
+	// A refund for a payment not seen yet is retried by the gateway
+	refunded["id"] = "evt_refund_2"
+	refunded["payment_id"] = "pay_unknown"
+	resp, _ = send(testPaymentSigningKey, refunded)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// ...and is applied once its event is redelivered after the capture
+	_, result = send(testPaymentSigningKey, map[string]interface{}{
+		"id":           "evt_capture_3",
+		"type":         "payment.captured",
+		"payment_id":   "pay_unknown",
+		"merchant_id":  "merchant_gateway",
+		"amount_cents": 2500,
+		"occurred_at":  paidAt,
+	})
+	assert.True(t, result.Applied)
+	resp, result = send(testPaymentSigningKey, refunded)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, result.Applied)
+}
+
+// setupSandboxDB recreates the sandbox schema from the migrations, as make
+// db-sandbox does, and connects to it
+func setupSandboxDB(t *testing.T, db *database.DB) *database.DB {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "DROP SCHEMA IF EXISTS "+testSandboxSchema+" CASCADE; CREATE SCHEMA "+testSandboxSchema+"; SET search_path TO "+testSandboxSchema)
+	require.NoError(t, err)
+	migrations, err := filepath.Glob("../migrations/*.up.sql")
+	require.NoError(t, err)
+	for _, path := range migrations {
+		script, err := os.ReadFile(path)
+		require.NoError(t, err)
+		_, err = conn.ExecContext(ctx, string(script))
+		require.NoError(t, err, path)
+	}
+	_, err = conn.ExecContext(ctx, "RESET search_path")
+	require.NoError(t, err)
+
+	sandboxDB, err := database.New(testAppConfig().Sandbox.Database(testDatabaseConfig()))
+	require.NoError(t, err)
+	return sandboxDB
+}
+
+func TestSandbox(t *testing.T) {
+	logger.Init("debug", "text")
+
+	db := setupTestDB(t)
+	sandboxDB := setupSandboxDB(t, db)
+
+	appConfig := testAppConfig()
+	h := handlers.New(newTestServices(t, db), appConfig)
+	h.ServeSandbox(routes.SetupRoutes(handlers.New(newTestServices(t, sandboxDB), appConfig)))
+	server := httptest.NewServer(routes.SetupRoutes(h))
+	defer server.Close()
+
+	do := func(method, path, key string, body interface{}) *http.Response {
+		var reader io.Reader
+		if body != nil {
+			encoded, _ := json.Marshal(body)
+			reader = bytes.NewBuffer(encoded)
+		}
+		req, _ := http.NewRequest(method, server.URL+path, reader)
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set(handlers.APIKeyHeader, key)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	// An order placed with the sandbox key lands in the sandbox schema
+	product := createTestProduct(t, sandboxDB, 10)
+	resp := do(http.MethodPost, "/v1/orders", testSandboxKey, models.CreateOrderRequest{ProductID: product.ID, Quantity: 1, BuyerID: "sandbox_buyer"})
+	var order models.Order
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&order))
+	resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "true", resp.Header.Get(handlers.SandboxHeader))
+
+	var sandboxOrders, liveOrders int
+	require.NoError(t, sandboxDB.QueryRow("SELECT COUNT(*) FROM orders").Scan(&sandboxOrders))
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM orders").Scan(&liveOrders))
+	assert.Equal(t, 1, sandboxOrders)
+	assert.Equal(t, 0, liveOrders)
+
+	// It is only visible from the sandbox
+	resp = do(http.MethodGet, "/v1/orders/"+order.ID.String(), testSandboxKey, nil)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = do(http.MethodGet, "/v1/orders/"+order.ID.String(), "", nil)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get(handlers.SandboxHeader))
+
+	// An unknown key is rejected rather than served live
+	resp = do(http.MethodGet, "/v1/orders/"+order.ID.String(), "not-a-sandbox-key", nil)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+// TestAuthBans tests that repeated wrong admin tokens ban the client, even
+// with the right token, and that the ban is recorded without the token
+func TestAuthBans(t *testing.T) {
+	logger.Init("debug", "text")
+
+	db := setupTestDB(t)
+	services := newTestServices(t, db)
+	services.AuthGuard = service.NewAuthGuard(&config.AuthConfig{
+		MaxFailures: 3,
+		Window:      time.Minute,
+		BanDuration: time.Minute,
+	}, repository.NewAuthBanRepository(db.DB))
+	server := httptest.NewServer(routes.SetupRoutes(handlers.New(services, testAppConfig())))
+	defer server.Close()
+
+	get := func(token string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/admin/maintenance", nil)
+		if token != "" {
+			req.Header.Set(handlers.AdminTokenHeader, token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	// Requests without a token are not counted
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, http.StatusUnauthorized, get("").StatusCode)
+	}
+	assert.Equal(t, http.StatusOK, get(testAdminToken).StatusCode)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, get(fmt.Sprintf("guess-%d", i)).StatusCode)
+	}
+
+	resp := get(testAdminToken)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+
+	bans, err := services.Auth.ListAuthBans(context.Background(), 10, 0)
+	require.NoError(t, err)
+	require.Len(t, bans, 1)
+	assert.Equal(t, "ip", bans[0].Scope)
+	assert.Equal(t, service.AuthCredentialAdminToken, bans[0].Credential)
+	assert.Equal(t, 3, bans[0].Failures)
+	assert.NotContains(t, bans[0].Subject, "guess")
+}
+
+// TestWithSavepoint tests that a failing savepoint undoes only its own
+// writes, even after a failed statement, and that savepoints nest
+func TestWithSavepoint(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	insert := func(tx *sql.Tx, name string) error {
+		_, err := tx.ExecContext(ctx, "INSERT INTO holidays (region, date, name) VALUES ('TEST', $1, $2)", time.Date(2025, 1, len(name), 0, 0, 0, 0, time.UTC), name)
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	require.NoError(t, database.WithSavepoint(ctx, tx, func(tx *sql.Tx) error {
+		return insert(tx, "a")
+	}))
+
+	// A failed statement would abort the transaction without the savepoint
+	err = database.WithSavepoint(ctx, tx, func(tx *sql.Tx) error {
+		if err := insert(tx, "bb"); err != nil {
+			return err
+		}
+		return insert(tx, "a")
+	})
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, database.ErrTxAborted)
+
+	// The outer savepoint keeps its write when the nested one fails
+	require.NoError(t, database.WithSavepoint(ctx, tx, func(tx *sql.Tx) error {
+		if err := insert(tx, "ccc"); err != nil {
+			return err
+		}
+		nestedErr := database.WithSavepoint(ctx, tx, func(tx *sql.Tx) error {
+			if err := insert(tx, "dddd"); err != nil {
+				return err
+			}
+			return errors.New("nested failure")
+		})
+		assert.EqualError(t, nestedErr, "nested failure")
+		return nil
+	}))
+	require.NoError(t, tx.Commit())
+
+	var names []string
+	rows, err := db.QueryContext(ctx, "SELECT name FROM holidays WHERE region = 'TEST' ORDER BY date")
+	require.NoError(t, err)
+	for rows.Next() {
+		var name string
+		require.NoError(t, rows.Scan(&name))
+		names = append(names, name)
+	}
+	require.NoError(t, rows.Err())
+	rows.Close()
+	assert.Equal(t, []string{"a", "ccc"}, names)
+
+	// A savepoint on a finished transaction can't be taken
+	err = database.WithSavepoint(ctx, tx, func(tx *sql.Tx) error { return nil })
+	assert.ErrorIs(t, err, database.ErrTxAborted)
+}
+
+// TestSettlementRunSkipped tests that the merchant-days a run skipped are
+// stored with it
+func TestSettlementRunSkipped(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+	settleRepo := repository.NewSettlementRepository(db.DB)
+
+	date := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	run := &models.SettlementRun{
+		ID:      uuid.New(),
+		From:    date,
+		To:      date,
+		Skipped: []models.SkippedSettlement{{MerchantID: "m1", Date: date, Error: "value too long"}},
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	require.NoError(t, settleRepo.CreateRun(ctx, tx, run))
+	require.NoError(t, tx.Commit())
+
+	stored, err := settleRepo.GetRun(ctx, run.ID)
+	require.NoError(t, err)
+	require.Len(t, stored.Skipped, 1)
+	assert.Equal(t, "m1", stored.Skipped[0].MerchantID)
+	assert.True(t, date.Equal(stored.Skipped[0].Date))
+	assert.Equal(t, "value too long", stored.Skipped[0].Error)
+
+	runs, err := settleRepo.ListRuns(ctx, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Len(t, runs[0].Skipped, 1)
 }